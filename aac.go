@@ -0,0 +1,265 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/go-webdl/bits"
+)
+
+// MPEG-4 Audio Object Types, ISO/IEC 14496-3 1.5.1.1.
+const (
+	AudioObjectTypeAACMain uint8 = 1
+	AudioObjectTypeAACLC   uint8 = 2
+	AudioObjectTypeAACSSR  uint8 = 3
+	AudioObjectTypeAACLTP  uint8 = 4
+	AudioObjectTypeSBR     uint8 = 5
+	AudioObjectTypePS      uint8 = 29
+)
+
+// AudioObjectTypeForFourCC returns the audio object type signaled by the
+// FourCC of an AAC track: "AACL" for AAC LC, "AACH" for HE-AAC and "AACP" for
+// HE-AAC v2. It returns 0 for other FourCCs.
+func AudioObjectTypeForFourCC(fourCC string) uint8 {
+	switch strings.ToUpper(fourCC) {
+	case "AACL":
+		return AudioObjectTypeAACLC
+	case "AACH":
+		return AudioObjectTypeSBR
+	case "AACP":
+		return AudioObjectTypePS
+	}
+	return 0
+}
+
+// Sampling frequencies indexed by samplingFrequencyIndex, ISO/IEC 14496-3
+// 1.6.3.4.
+var aacSamplingFrequencies = [...]uint32{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350,
+}
+
+// AudioSpecificConfig carries the decoder configuration of an MPEG-4 audio
+// stream, ISO/IEC 14496-3 1.6.2.1. Only the GASpecificConfig of the AAC object
+// types is supported, which covers the audio tracks of Smooth Streaming.
+//
+// For HE-AAC the AudioObjectType, SamplingFrequency and ChannelConfiguration
+// fields describe the AAC core, while SBRPresent, PSPresent and
+// ExtensionSamplingFrequency describe the SBR and PS extensions and the output
+// sampling frequency.
+type AudioSpecificConfig struct {
+	AudioObjectType      uint8
+	SamplingFrequency    uint32
+	ChannelConfiguration uint8
+
+	SBRPresent                 bool
+	PSPresent                  bool
+	ExtensionSamplingFrequency uint32
+}
+
+// ParseAudioSpecificConfig parses an AudioSpecificConfig. SBR and PS are
+// detected from both explicit hierarchical signaling (audio object type 5 or
+// 29) and explicit backward compatible signaling (sync extensions following
+// the GASpecificConfig).
+func ParseAudioSpecificConfig(data []byte) (c AudioSpecificConfig, err error) {
+	r := bits.NewAccErrReader(bytes.NewReader(data))
+	c.AudioObjectType = readAudioObjectType(r)
+	c.SamplingFrequency = readSamplingFrequency(r)
+	c.ChannelConfiguration = uint8(r.Read(4))
+	if c.AudioObjectType == AudioObjectTypeSBR || c.AudioObjectType == AudioObjectTypePS {
+		c.SBRPresent = true
+		c.PSPresent = c.AudioObjectType == AudioObjectTypePS
+		c.ExtensionSamplingFrequency = readSamplingFrequency(r)
+		c.AudioObjectType = readAudioObjectType(r)
+	}
+	if err = r.AccError(); err != nil {
+		err = fmt.Errorf("AudioSpecificConfig truncated: %w", ErrInvalidParam)
+		return
+	}
+	switch c.AudioObjectType {
+	case AudioObjectTypeAACMain, AudioObjectTypeAACLC, AudioObjectTypeAACSSR, AudioObjectTypeAACLTP:
+	default:
+		err = fmt.Errorf("audio object type %d: %w", c.AudioObjectType, ErrUnknownCodec)
+		return
+	}
+	// GASpecificConfig
+	r.Read(1)         // frameLengthFlag
+	if r.ReadFlag() { // dependsOnCoreCoder
+		r.Read(14) // coreCoderDelay
+	}
+	extensionFlag := r.ReadFlag()
+	if c.ChannelConfiguration == 0 {
+		err = fmt.Errorf("AudioSpecificConfig with program_config_element not supported: %w", ErrUnknownCodec)
+		return
+	}
+	if c.AudioObjectType == AudioObjectTypeAACSSR || c.AudioObjectType == AudioObjectTypeAACLTP {
+		if extensionFlag {
+			err = fmt.Errorf("AudioSpecificConfig extension of audio object type %d not supported: %w", c.AudioObjectType, ErrUnknownCodec)
+			return
+		}
+	}
+	if err = r.AccError(); err != nil {
+		err = fmt.Errorf("AudioSpecificConfig truncated: %w", ErrInvalidParam)
+		return
+	}
+	if c.SBRPresent {
+		return
+	}
+	// backward compatible signaling of the extensions, reading stops at the
+	// end of the data
+	if r.Read(11) != 0x2b7 || readAudioObjectType(r) != AudioObjectTypeSBR || r.AccError() != nil {
+		return
+	}
+	if c.SBRPresent = r.ReadFlag(); c.SBRPresent {
+		c.ExtensionSamplingFrequency = readSamplingFrequency(r)
+		if r.Read(11) == 0x548 {
+			c.PSPresent = r.ReadFlag()
+		}
+	}
+	if c.ExtensionSamplingFrequency == 0 {
+		c.SBRPresent = false
+	}
+	return
+}
+
+// NewAACAudioSpecificConfig creates the AudioSpecificConfig of an AAC LC
+// stream with the given sampling rate and channel count.
+func NewAACAudioSpecificConfig(samplingRate uint32, channels uint16) (config AudioSpecificConfig, err error) {
+	if samplingRate == 0 {
+		err = fmt.Errorf("AAC sampling rate is 0: %w", ErrInvalidParam)
+		return
+	}
+	channelConfiguration, err := aacChannelConfiguration(channels)
+	if err != nil {
+		return
+	}
+	config = AudioSpecificConfig{
+		AudioObjectType:      AudioObjectTypeAACLC,
+		SamplingFrequency:    samplingRate,
+		ChannelConfiguration: channelConfiguration,
+	}
+	return
+}
+
+// WithSBR returns the configuration of an HE-AAC stream whose AAC core is
+// described by c and whose output sampling frequency is outputFrequency. If
+// ps is set, the PS extension is signaled and the core is mono.
+func (c AudioSpecificConfig) WithSBR(ps bool, outputFrequency uint32) AudioSpecificConfig {
+	c.SBRPresent = true
+	c.PSPresent = ps
+	if c.SamplingFrequency >= outputFrequency {
+		c.SamplingFrequency = outputFrequency / 2
+	}
+	c.ExtensionSamplingFrequency = c.SamplingFrequency * 2
+	if ps {
+		c.ChannelConfiguration = 1
+	}
+	return c
+}
+
+// OutputSamplingFrequency returns the sampling frequency of the decoded audio.
+func (c AudioSpecificConfig) OutputSamplingFrequency() uint32 {
+	if c.SBRPresent && c.ExtensionSamplingFrequency > 0 {
+		return c.ExtensionSamplingFrequency
+	}
+	return c.SamplingFrequency
+}
+
+// Bytes serializes the AudioSpecificConfig. HE-AAC configurations are written
+// with explicit hierarchical signaling.
+func (c AudioSpecificConfig) Bytes() (data []byte, err error) {
+	// 31 is the escape value of the 5-bit field and the 6-bit escaped value
+	// ends at 95
+	if c.AudioObjectType == 31 || c.AudioObjectType > 95 {
+		err = fmt.Errorf("audio object type %d: %w", c.AudioObjectType, ErrInvalidParam)
+		return
+	}
+	var buf bytes.Buffer
+	w := bits.NewWriter(&buf)
+	if c.SBRPresent {
+		if c.PSPresent {
+			writeAudioObjectType(w, AudioObjectTypePS)
+		} else {
+			writeAudioObjectType(w, AudioObjectTypeSBR)
+		}
+		writeSamplingFrequency(w, c.SamplingFrequency)
+		w.Write(uint(c.ChannelConfiguration), 4)
+		writeSamplingFrequency(w, c.ExtensionSamplingFrequency)
+		writeAudioObjectType(w, c.AudioObjectType)
+	} else {
+		writeAudioObjectType(w, c.AudioObjectType)
+		writeSamplingFrequency(w, c.SamplingFrequency)
+		w.Write(uint(c.ChannelConfiguration), 4)
+	}
+	// GASpecificConfig
+	w.Write(0, 1) // frameLengthFlag, 1024 samples per frame
+	w.Write(0, 1) // dependsOnCoreCoder
+	w.Write(0, 1) // extensionFlag
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return
+	}
+	data = buf.Bytes()
+	return
+}
+
+func writeAudioObjectType(w *bits.Writer, audioObjectType uint8) {
+	if audioObjectType > 31 {
+		w.Write(31, 5)
+		w.Write(uint(audioObjectType-32), 6)
+		return
+	}
+	w.Write(uint(audioObjectType), 5)
+}
+
+func readAudioObjectType(r *bits.AccErrReader) uint8 {
+	audioObjectType := uint8(r.Read(5))
+	if audioObjectType == 31 {
+		audioObjectType = 32 + uint8(r.Read(6))
+	}
+	return audioObjectType
+}
+
+func readSamplingFrequency(r *bits.AccErrReader) uint32 {
+	index := r.Read(4)
+	if index == 0xf {
+		return uint32(r.Read(24))
+	}
+	if int(index) >= len(aacSamplingFrequencies) {
+		return 0
+	}
+	return aacSamplingFrequencies[index]
+}
+
+func writeSamplingFrequency(w *bits.Writer, frequency uint32) {
+	if index, ok := aacSamplingFrequencyIndex(frequency); ok {
+		w.Write(uint(index), 4)
+		return
+	}
+	w.Write(0xf, 4)
+	w.Write(uint(frequency), 24)
+}
+
+func aacSamplingFrequencyIndex(frequency uint32) (index uint8, ok bool) {
+	for i, f := range aacSamplingFrequencies {
+		if f == frequency {
+			return uint8(i), true
+		}
+	}
+	return
+}
+
+// aacChannelConfiguration maps a channel count to the channelConfiguration
+// field, ISO/IEC 14496-3 1.6.3.5. Layouts that can only be described by a
+// program_config_element are not supported.
+func aacChannelConfiguration(channels uint16) (channelConfiguration uint8, err error) {
+	switch {
+	case channels >= 1 && channels <= 6:
+		channelConfiguration = uint8(channels)
+	case channels == 8:
+		channelConfiguration = 7
+	default:
+		err = fmt.Errorf("no AAC channel configuration for %d channels: %w", channels, ErrInvalidParam)
+	}
+	return
+}
@@ -0,0 +1,196 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAudioSpecificConfigRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AudioSpecificConfig
+		data   []byte
+	}{
+		{
+			name:   "AAC LC",
+			config: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 44100, ChannelConfiguration: 2},
+			data:   []byte{0x12, 0x10},
+		},
+		{
+			name:   "explicit sampling frequency",
+			config: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 1000, ChannelConfiguration: 1},
+			data:   []byte{0x17, 0x80, 0x01, 0xf4, 0x08},
+		},
+		{
+			name:   "escaped audio object type",
+			config: AudioSpecificConfig{AudioObjectType: 32, SamplingFrequency: 48000, ChannelConfiguration: 2},
+			data:   []byte{0xf8, 0x06, 0x40},
+		},
+		{
+			name:   "HE-AAC",
+			config: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 24000, ChannelConfiguration: 2, SBRPresent: true, ExtensionSamplingFrequency: 48000},
+			data:   []byte{0x2b, 0x11, 0x88, 0x00},
+		},
+		{
+			name:   "HE-AAC v2",
+			config: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 24000, ChannelConfiguration: 1, SBRPresent: true, PSPresent: true, ExtensionSamplingFrequency: 48000},
+			data:   []byte{0xeb, 0x09, 0x88, 0x00},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.config.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("Bytes() = %x, want %x", data, tt.data)
+			}
+			if tt.config.AudioObjectType > AudioObjectTypeAACLTP {
+				// only the AAC object types carry a GASpecificConfig
+				return
+			}
+			config, err := ParseAudioSpecificConfig(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config != tt.config {
+				t.Errorf("ParseAudioSpecificConfig() = %+v, want %+v", config, tt.config)
+			}
+		})
+	}
+}
+
+func TestParseAudioSpecificConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    AudioSpecificConfig
+		wantErr error
+	}{
+		{
+			name: "backward compatible SBR and PS signaling",
+			data: []byte{0x13, 0x10, 0x56, 0xe5, 0x9d, 0x48, 0x80},
+			want: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 24000, ChannelConfiguration: 2, SBRPresent: true, PSPresent: true, ExtensionSamplingFrequency: 48000},
+		},
+		{
+			name: "truncated sync extension",
+			data: []byte{0x13, 0x10, 0x56},
+			want: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 24000, ChannelConfiguration: 2},
+		},
+		{
+			name:    "truncated",
+			data:    []byte{0x12},
+			wantErr: ErrInvalidParam,
+		},
+		{
+			name:    "unsupported audio object type",
+			data:    []byte{0x32, 0x10},
+			wantErr: ErrUnknownCodec,
+		},
+		{
+			name:    "program config element",
+			data:    []byte{0x12, 0x00},
+			wantErr: ErrUnknownCodec,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAudioSpecificConfig(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseAudioSpecificConfig() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ParseAudioSpecificConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioSpecificConfigBytesInvalidAudioObjectType(t *testing.T) {
+	for _, audioObjectType := range []uint8{31, 96} {
+		config := AudioSpecificConfig{AudioObjectType: audioObjectType, SamplingFrequency: 48000, ChannelConfiguration: 2}
+		if _, err := config.Bytes(); !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("Bytes() of audio object type %d error = %v, want %v", audioObjectType, err, ErrInvalidParam)
+		}
+	}
+}
+
+func TestAACConfigWithSBR(t *testing.T) {
+	config, err := NewAACAudioSpecificConfig(48000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		ps   bool
+		want AudioSpecificConfig
+	}{
+		{
+			name: "HE-AAC",
+			want: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 24000, ChannelConfiguration: 2, SBRPresent: true, ExtensionSamplingFrequency: 48000},
+		},
+		{
+			name: "HE-AAC v2",
+			ps:   true,
+			want: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 24000, ChannelConfiguration: 1, SBRPresent: true, PSPresent: true, ExtensionSamplingFrequency: 48000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := config.WithSBR(tt.ps, 48000)
+			if got != tt.want {
+				t.Errorf("WithSBR() = %+v, want %+v", got, tt.want)
+			}
+			if got.OutputSamplingFrequency() != 48000 {
+				t.Errorf("OutputSamplingFrequency() = %d, want 48000", got.OutputSamplingFrequency())
+			}
+		})
+	}
+}
+
+func TestNewAACAudioSpecificConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		samplingRate uint32
+		channels     uint16
+		want         AudioSpecificConfig
+		wantErr      error
+	}{
+		{
+			name:         "stereo",
+			samplingRate: 44100,
+			channels:     2,
+			want:         AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 44100, ChannelConfiguration: 2},
+		},
+		{
+			name:         "7.1",
+			samplingRate: 48000,
+			channels:     8,
+			want:         AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 48000, ChannelConfiguration: 7},
+		},
+		{name: "no sampling rate", channels: 2, wantErr: ErrInvalidParam},
+		{name: "no channels", samplingRate: 48000, wantErr: ErrInvalidParam},
+		{name: "7 channels", samplingRate: 48000, channels: 7, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewAACAudioSpecificConfig(tt.samplingRate, tt.channels)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAACAudioSpecificConfig() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("NewAACAudioSpecificConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioObjectTypeForFourCC(t *testing.T) {
+	for fourCC, want := range map[string]uint8{"AACL": AudioObjectTypeAACLC, "aach": AudioObjectTypeSBR, "AACP": AudioObjectTypePS, "H264": 0} {
+		if got := AudioObjectTypeForFourCC(fourCC); got != want {
+			t.Errorf("AudioObjectTypeForFourCC(%q) = %d, want %d", fourCC, got, want)
+		}
+	}
+}
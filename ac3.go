@@ -0,0 +1,128 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Subformat GUIDs of the WAVEFORMATEXTENSIBLE structure of Dolby audio tracks,
+// in their little-endian byte order.
+var (
+	// MEDIASUBTYPE_DOLBY_AC3, e06d802c-db46-11cf-b4d1-00805f6cbbea
+	ac3SubFormat = []byte{0x2c, 0x80, 0x6d, 0xe0, 0x46, 0xdb, 0xcf, 0x11, 0xb4, 0xd1, 0x00, 0x80, 0x5f, 0x6c, 0xbb, 0xea}
+	// KSDATAFORMAT_SUBTYPE_IEC61937_DOLBY_DIGITAL, 00000092-0000-0010-8000-00aa00389b71
+	ac3SPDIFSubFormat = []byte{0x92, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71}
+	// MEDIASUBTYPE_DOLBY_DDPLUS, a7fb87af-2d02-42fb-a4d4-05cd93843bdd
+	ec3SubFormat = []byte{0xaf, 0x87, 0xfb, 0xa7, 0x02, 0x2d, 0xfb, 0x42, 0xa4, 0xd4, 0x05, 0xcd, 0x93, 0x84, 0x3b, 0xdd}
+)
+
+// ac3BitRates is the nominal bit rate table of ETSI TS 102 366 Table F.4.1 in
+// kbit/s, indexed by bit_rate_code.
+var ac3BitRates = []uint32{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 576, 640}
+
+// dolbySpecificData returns the dac3 or dec3 payload carried by the
+// CodecPrivateData of an AC-3 or E-AC-3 track. Packagers either store the
+// payload as is or append it to the WAVEFORMATEXTENSIBLE structure, or only
+// its extension, identified by the Dolby subformat GUID.
+func dolbySpecificData(codecPrivateData []byte) []byte {
+	for _, subFormat := range [][]byte{ec3SubFormat, ac3SubFormat, ac3SPDIFSubFormat} {
+		if i := bytes.Index(codecPrivateData, subFormat); i >= 0 {
+			return codecPrivateData[i+len(subFormat):]
+		}
+	}
+	return codecPrivateData
+}
+
+// ac3Fscod returns the sample rate code of the given sampling rate.
+func ac3Fscod(samplingRate uint32) (fscod uint8, err error) {
+	switch samplingRate {
+	case 48000:
+		fscod = 0
+	case 44100:
+		fscod = 1
+	case 32000:
+		fscod = 2
+	default:
+		err = fmt.Errorf("AC-3 sampling rate %d: %w", samplingRate, ErrInvalidParam)
+	}
+	return
+}
+
+// ac3ChannelMode returns the audio coding mode and the low frequency effects
+// flag of the given channel count, assuming the conventional layouts from
+// mono up to 5.1.
+func ac3ChannelMode(channels uint16) (acmod uint8, lfeon bool, err error) {
+	switch channels {
+	case 1:
+		acmod = 1 // 1/0
+	case 0, 2:
+		acmod = 2 // 2/0
+	case 3:
+		acmod = 3 // 3/0
+	case 4:
+		acmod = 6 // 2/2
+	case 5:
+		acmod = 7 // 3/2
+	case 6:
+		acmod, lfeon = 7, true // 3/2 + LFE
+	default:
+		err = fmt.Errorf("AC-3 channel count %d: %w", channels, ErrInvalidParam)
+	}
+	return
+}
+
+// NewAC3SpecificBox creates the dac3 box of an AC-3 stream with the given
+// sampling rate, channel count and bit rate in bit/s.
+func NewAC3SpecificBox(samplingRate uint32, channels uint16, bitrate uint32) (dac3 *AC3SpecificBox, err error) {
+	fscod, err := ac3Fscod(samplingRate)
+	if err != nil {
+		return
+	}
+	acmod, lfeon, err := ac3ChannelMode(channels)
+	if err != nil {
+		return
+	}
+	code := -1
+	for i, rate := range ac3BitRates {
+		if rate*1000 >= bitrate {
+			code = i
+			break
+		}
+	}
+	if code < 0 {
+		err = fmt.Errorf("AC-3 bit rate %d: %w", bitrate, ErrInvalidParam)
+		return
+	}
+	dac3 = &AC3SpecificBox{
+		Fscod:       fscod,
+		Bsid:        8,
+		Acmod:       acmod,
+		Lfeon:       lfeon,
+		BitRateCode: uint8(code),
+	}
+	return
+}
+
+// NewEC3SpecificBox creates the dec3 box of an E-AC-3 stream consisting of a
+// single independent substream with the given sampling rate, channel count
+// and bit rate in bit/s.
+func NewEC3SpecificBox(samplingRate uint32, channels uint16, bitrate uint32) (dec3 *EC3SpecificBox, err error) {
+	fscod, err := ac3Fscod(samplingRate)
+	if err != nil {
+		return
+	}
+	acmod, lfeon, err := ac3ChannelMode(channels)
+	if err != nil {
+		return
+	}
+	dec3 = &EC3SpecificBox{
+		DataRate: uint16(bitrate / 1000),
+		IndependentSubstreams: []EC3IndependentSubstream{{
+			Fscod: fscod,
+			Bsid:  16,
+			Acmod: acmod,
+			Lfeon: lfeon,
+		}},
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewAC3SpecificBox(t *testing.T) {
+	tests := []struct {
+		name         string
+		samplingRate uint32
+		channels     uint16
+		bitrate      uint32
+		want         AC3SpecificBox
+		wantErr      error
+	}{
+		{
+			name:         "5.1",
+			samplingRate: 48000,
+			channels:     6,
+			bitrate:      448000,
+			want:         AC3SpecificBox{Fscod: 0, Bsid: 8, Acmod: 7, Lfeon: true, BitRateCode: 15},
+		},
+		{
+			name:         "stereo rounded up to the nominal bit rate",
+			samplingRate: 44100,
+			channels:     2,
+			bitrate:      190000,
+			want:         AC3SpecificBox{Fscod: 1, Bsid: 8, Acmod: 2, BitRateCode: 10},
+		},
+		{name: "unsupported sampling rate", samplingRate: 22050, channels: 2, bitrate: 192000, wantErr: ErrInvalidParam},
+		{name: "unsupported channel count", samplingRate: 48000, channels: 8, bitrate: 192000, wantErr: ErrInvalidParam},
+		{name: "bit rate beyond the table", samplingRate: 48000, channels: 2, bitrate: 700000, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewAC3SpecificBox(tt.samplingRate, tt.channels, tt.bitrate)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAC3SpecificBox() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("NewAC3SpecificBox() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEC3SpecificBox(t *testing.T) {
+	got, err := NewEC3SpecificBox(48000, 6, 384000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := EC3SpecificBox{DataRate: 384, IndependentSubstreams: []EC3IndependentSubstream{{Fscod: 0, Bsid: 16, Acmod: 7, Lfeon: true}}}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("NewEC3SpecificBox() = %+v, want %+v", *got, want)
+	}
+	if _, err = NewEC3SpecificBox(96000, 2, 192000); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("NewEC3SpecificBox() of 96 kHz error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestDolbySpecificData(t *testing.T) {
+	dec3 := []byte{0x0c, 0x00, 0x20, 0x0f, 0x00}
+	waveFormat := decodeHex(t, "feff0600803e0000")
+	tests := []struct {
+		name             string
+		codecPrivateData []byte
+	}{
+		{name: "payload only", codecPrivateData: dec3},
+		{name: "WAVEFORMATEXTENSIBLE", codecPrivateData: append(append(append([]byte{}, waveFormat...), ec3SubFormat...), dec3...)},
+		{name: "extension only", codecPrivateData: append(append([]byte{}, ec3SubFormat...), dec3...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dolbySpecificData(tt.codecPrivateData); !bytes.Equal(got, dec3) {
+				t.Errorf("dolbySpecificData() = %x, want %x", got, dec3)
+			}
+		})
+	}
+}
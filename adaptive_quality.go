@@ -0,0 +1,116 @@
+package smoothstreaming
+
+import (
+	"sort"
+	"time"
+)
+
+// AdaptiveQuality adapts the video tracks of the recording of a live
+// presentation to the throughput of the downloads, see ThroughputEstimator,
+// so that the recording keeps up with the live edge rather than lagging ever
+// further behind it. When a track lags behind the live edge by more than
+// MaxLag and the throughput is below its bitrate, the recording steps down to
+// the track of the next lower bitrate of the stream; when it has caught up
+// and the throughput allows it, it steps back up, never above the track
+// selected by the Tracks of the Downloader.
+//
+// Each track is written to its own output, which must differ, e.g. with the
+// {Bitrate} placeholder of the NameTemplate: a recording yields an output per
+// track recorded, covering the fragments recorded in that track, each one
+// starting where the previous one stopped.
+type AdaptiveQuality struct {
+	// the media duration by which a video track may lag behind the live edge
+	// before stepping down, adaptive quality being disabled if 0. The
+	// recording steps up once the lag is less than half of it.
+	MaxLag time.Duration
+
+	// the ratio of the throughput to the bitrate of a track required to step
+	// up to it, 1.5 if 0.
+	Headroom float64
+}
+
+// adaptation is the state of the AdaptiveQuality of a recording.
+type adaptation struct {
+	AdaptiveQuality
+	throughput *ThroughputEstimator
+	// the adaptation of each selected track, by index.
+	tracks []adaptiveTrack
+}
+
+// adaptiveTrack is the adaptation of a selected track.
+type adaptiveTrack struct {
+	// the bitrate of the track selected, the highest recorded.
+	ceiling uint32
+	// whether the recording switched tracks, then the time of the first
+	// fragment recorded and the end of the fragments recorded before the last
+	// switch, from which the current track is recorded.
+	switched    bool
+	first, from uint64
+	// the outputs of the previous tracks recorded.
+	outputs []DownloadedTrack
+}
+
+// newAdaptation returns the adaptation of the selected tracks, nil if the
+// AdaptiveQuality q is disabled.
+func newAdaptation(q AdaptiveQuality, throughput *ThroughputEstimator, selected []SelectedTrack) (a *adaptation) {
+	if q.MaxLag <= 0 {
+		return
+	}
+	a = &adaptation{AdaptiveQuality: q, throughput: throughput, tracks: make([]adaptiveTrack, len(selected))}
+	for i, track := range selected {
+		a.tracks[i].ceiling = track.Track.Bitrate
+	}
+	return
+}
+
+// choose returns the track to record instead of the ith selected track, given
+// its lag behind the live edge, or the track itself.
+func (a *adaptation) choose(i int, current SelectedTrack, lag time.Duration) SelectedTrack {
+	estimate, ok := a.throughput.Estimate()
+	if !ok || current.Stream.Type != VideoStream {
+		return current
+	}
+	var candidates []*Track
+	for _, t := range current.Stream.Tracks {
+		if t.Bitrate <= a.tracks[i].ceiling {
+			candidates = append(candidates, t)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Bitrate < candidates[j].Bitrate
+	})
+	j := sort.Search(len(candidates), func(j int) bool {
+		return candidates[j].Bitrate >= current.Track.Bitrate
+	})
+	headroom := a.Headroom
+	if headroom <= 0 {
+		headroom = 1.5
+	}
+	switch {
+	case lag > a.MaxLag && estimate < int64(current.Track.Bitrate) && j > 0:
+		current.Track = candidates[j-1]
+	case lag < a.MaxLag/2 && j+1 < len(candidates) && float64(estimate) >= float64(candidates[j+1].Bitrate)*headroom:
+		current.Track = candidates[j+1]
+	}
+	return current
+}
+
+// outputs returns the outputs of all tracks recorded, those of the previous
+// tracks of each selected track followed by the current one, tracks, once
+// each.
+func (a *adaptation) outputs(tracks []DownloadedTrack) (outputs []DownloadedTrack) {
+	for i, current := range tracks {
+		seen := map[string]bool{current.Path: true}
+		var previous []DownloadedTrack
+		// the last output of each path is the most recent
+		for j := len(a.tracks[i].outputs) - 1; j >= 0; j-- {
+			if output := a.tracks[i].outputs[j]; !seen[output.Path] {
+				seen[output.Path] = true
+				previous = append([]DownloadedTrack{output}, previous...)
+			}
+		}
+		outputs = append(outputs, previous...)
+		outputs = append(outputs, current)
+	}
+	return
+}
@@ -0,0 +1,94 @@
+package smoothstreaming
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testAdaptiveStream returns a video stream of tracks of the given bitrates.
+func testAdaptiveStream(bitrates ...uint32) *StreamIndex {
+	s := &StreamIndex{Type: VideoStream}
+	for _, bitrate := range bitrates {
+		s.Tracks = append(s.Tracks, &Track{Bitrate: bitrate})
+	}
+	return s
+}
+
+// testThroughput returns an estimator of the given throughput, in bits per
+// second.
+func testThroughput(bitsPerSecond int64) *ThroughputEstimator {
+	e := NewThroughputEstimator(0)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	e.observe(bitsPerSecond/8, start, start.Add(time.Second))
+	return e
+}
+
+func TestNewAdaptation(t *testing.T) {
+	s := testAdaptiveStream(1000, 500)
+	selected := []SelectedTrack{{Stream: s, Track: s.Tracks[0]}}
+	if a := newAdaptation(AdaptiveQuality{}, nil, selected); a != nil {
+		t.Errorf("newAdaptation() without MaxLag = %+v, want nil", a)
+	}
+	a := newAdaptation(AdaptiveQuality{MaxLag: time.Second}, nil, selected)
+	if a == nil || len(a.tracks) != 1 || a.tracks[0].ceiling != 1000 {
+		t.Errorf("newAdaptation() = %+v, want the selected bitrate as ceiling", a)
+	}
+}
+
+func TestAdaptationChoose(t *testing.T) {
+	video := testAdaptiveStream(3000000, 500000, 1000000, 2000000)
+	audio := &StreamIndex{Type: AudioStream, Tracks: []*Track{{Bitrate: 128000}, {Bitrate: 64000}}}
+	tests := []struct {
+		name        string
+		stream      *StreamIndex
+		ceiling     uint32
+		current     uint32
+		throughput  int64
+		lag         time.Duration
+		wantBitrate uint32
+	}{
+		{name: "keeping up", stream: video, ceiling: 2000000, current: 2000000, throughput: 1500000, lag: time.Second, wantBitrate: 2000000},
+		{name: "step down", stream: video, ceiling: 2000000, current: 2000000, throughput: 1500000, lag: 10 * time.Second, wantBitrate: 1000000},
+		{name: "enough throughput", stream: video, ceiling: 2000000, current: 2000000, throughput: 2500000, lag: 10 * time.Second, wantBitrate: 2000000},
+		{name: "lowest track", stream: video, ceiling: 2000000, current: 500000, throughput: 100000, lag: 10 * time.Second, wantBitrate: 500000},
+		{name: "step up", stream: video, ceiling: 2000000, current: 500000, throughput: 1500000, lag: time.Second, wantBitrate: 1000000},
+		{name: "not enough headroom", stream: video, ceiling: 2000000, current: 500000, throughput: 1400000, lag: time.Second, wantBitrate: 500000},
+		{name: "still lagging", stream: video, ceiling: 2000000, current: 500000, throughput: 1500000, lag: 3 * time.Second, wantBitrate: 500000},
+		{name: "ceiling", stream: video, ceiling: 2000000, current: 2000000, throughput: 10000000, lag: time.Second, wantBitrate: 2000000},
+		{name: "no estimate", stream: video, ceiling: 2000000, current: 2000000, lag: 10 * time.Second, wantBitrate: 2000000},
+		{name: "audio", stream: audio, ceiling: 128000, current: 128000, throughput: 1000, lag: 10 * time.Second, wantBitrate: 128000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var throughput *ThroughputEstimator
+			if tt.throughput > 0 {
+				throughput = testThroughput(tt.throughput)
+			}
+			var current SelectedTrack
+			for _, track := range tt.stream.Tracks {
+				if track.Bitrate == tt.current {
+					current = SelectedTrack{Stream: tt.stream, Track: track}
+				}
+			}
+			a := newAdaptation(AdaptiveQuality{MaxLag: 4 * time.Second}, throughput, []SelectedTrack{current})
+			a.tracks[0].ceiling = tt.ceiling
+			got := a.choose(0, current, tt.lag)
+			if got.Stream != tt.stream || got.Track.Bitrate != tt.wantBitrate {
+				t.Errorf("choose() = track of %d, want %d", got.Track.Bitrate, tt.wantBitrate)
+			}
+		})
+	}
+}
+
+func TestAdaptationOutputs(t *testing.T) {
+	a := &adaptation{tracks: []adaptiveTrack{
+		{outputs: []DownloadedTrack{{Path: "video_2000.mp4"}, {Path: "video_1000.mp4"}, {Path: "video_2000.mp4", Moov: MoovProcessor{TrackID: 2}}}},
+		{},
+	}}
+	got := a.outputs([]DownloadedTrack{{Path: "video_1000.mp4", Moov: MoovProcessor{TrackID: 1}}, {Path: "audio.mp4"}})
+	want := []DownloadedTrack{{Path: "video_2000.mp4", Moov: MoovProcessor{TrackID: 2}}, {Path: "video_1000.mp4", Moov: MoovProcessor{TrackID: 1}}, {Path: "audio.mp4"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("outputs() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"io"
+)
+
+// ADTSHeader returns the 7-byte ADTS header, without CRC, of an AAC frame of
+// the given size, ISO/IEC 14496-3 1.A.2.2. For HE-AAC the header describes
+// the AAC core and the extensions are signaled implicitly.
+func (c AudioSpecificConfig) ADTSHeader(frameSize int) (header []byte, err error) {
+	switch c.AudioObjectType {
+	case AudioObjectTypeAACMain, AudioObjectTypeAACLC, AudioObjectTypeAACSSR, AudioObjectTypeAACLTP:
+	default:
+		err = fmt.Errorf("ADTS header of audio object type %d: %w", c.AudioObjectType, ErrUnknownCodec)
+		return
+	}
+	index, ok := aacSamplingFrequencyIndex(c.SamplingFrequency)
+	if !ok {
+		err = fmt.Errorf("ADTS header of sampling frequency %d: %w", c.SamplingFrequency, ErrInvalidParam)
+		return
+	}
+	if c.ChannelConfiguration == 0 || c.ChannelConfiguration > 7 {
+		err = fmt.Errorf("ADTS header of channel configuration %d: %w", c.ChannelConfiguration, ErrInvalidParam)
+		return
+	}
+	frameLength := frameSize + 7
+	if frameLength > 0x1fff {
+		err = fmt.Errorf("AAC frame of %d bytes exceeds the ADTS frame length: %w", frameSize, ErrInvalidParam)
+		return
+	}
+	header = []byte{
+		0xff,
+		0xf1, // syncword, MPEG-4, layer 0, protection_absent
+		(c.AudioObjectType-1)<<6 | index<<2 | c.ChannelConfiguration>>2,
+		c.ChannelConfiguration<<6 | byte(frameLength>>11),
+		byte(frameLength >> 3),
+		byte(frameLength<<5) | 0x1f, // adts_buffer_fullness 0x7ff, variable bitrate
+		0xfc,                        // number_of_raw_data_blocks_in_frame 0
+	}
+	return
+}
+
+// ADTSWriter writes the samples of the fragments of an AAC track as a raw
+// ADTS stream, the .aac files read by most audio tools, each sample preceded
+// by the ADTS header derived from the AudioSpecificConfig of the track.
+type ADTSWriter struct {
+	w      io.Writer
+	p      MoovProcessor
+	config AudioSpecificConfig
+}
+
+// NewADTSWriter returns an ADTSWriter writing the samples of the AAC track p
+// to w.
+func NewADTSWriter(w io.Writer, p MoovProcessor) (aw *ADTSWriter, err error) {
+	if p.Codec != Mp4aFourCC {
+		err = fmt.Errorf("ADTS stream of %s: %w", p.Codec, ErrUnknownCodec)
+		return
+	}
+	data, err := p.CreateAudioSpecificConfig()
+	if err != nil {
+		return
+	}
+	config, err := ParseAudioSpecificConfig(data)
+	if err != nil {
+		return
+	}
+	aw = &ADTSWriter{w: w, p: p, config: config}
+	return
+}
+
+// WriteFragment writes the samples of a clear fragment of the track, see
+// Decrypter.
+func (aw *ADTSWriter) WriteFragment(f Fragment) (err error) {
+	samples, err := f.Samples(aw.p)
+	if err != nil {
+		return
+	}
+	for i, sample := range samples {
+		if sample.Encryption != nil {
+			err = fmt.Errorf("track %d sample %d is encrypted: %w", sample.TrackID, i, ErrInvalidParam)
+			return
+		}
+		var header []byte
+		if header, err = aw.config.ADTSHeader(len(sample.Data)); err != nil {
+			return
+		}
+		if _, err = aw.w.Write(append(header, sample.Data...)); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestAudioSpecificConfigADTSHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    AudioSpecificConfig
+		frameSize int
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "AAC-LC stereo",
+			config:    AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 44100, ChannelConfiguration: 2},
+			frameSize: 10,
+			want:      "fff15080023ffc",
+		},
+		{
+			name:      "HE-AAC core",
+			config:    AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 24000, ChannelConfiguration: 1, SBRPresent: true, ExtensionSamplingFrequency: 48000},
+			frameSize: 0x1ff8,
+			want:      "fff15843fffffc",
+		},
+		{name: "USAC", config: AudioSpecificConfig{AudioObjectType: 42, SamplingFrequency: 48000, ChannelConfiguration: 2}, wantErr: ErrUnknownCodec},
+		{name: "explicit frequency", config: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 44000, ChannelConfiguration: 2}, wantErr: ErrInvalidParam},
+		{name: "program config element", config: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 48000}, wantErr: ErrInvalidParam},
+		{name: "frame too large", config: AudioSpecificConfig{AudioObjectType: AudioObjectTypeAACLC, SamplingFrequency: 48000, ChannelConfiguration: 2}, frameSize: 0x1ff9, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := tt.config.ADTSHeader(tt.frameSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ADTSHeader() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := hex.EncodeToString(header); got != tt.want {
+				t.Errorf("ADTSHeader() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestADTSWriter(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(f.Data, "0123456789abcdef")
+	var buf bytes.Buffer
+	aw, err := NewADTSWriter(&buf, MoovProcessor{Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 44100, Channels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = aw.WriteFragment(f); err != nil {
+		t.Fatal(err)
+	}
+	want := append(decodeHex(t, "fff15080023ffc"), "0123456789"...)
+	want = append(want, decodeHex(t, "fff1508001bffc")...)
+	want = append(want, "abcdef"...)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("stream = %x, want %x", buf.Bytes(), want)
+	}
+}
+
+func TestADTSWriterErrors(t *testing.T) {
+	if _, err := NewADTSWriter(&bytes.Buffer{}, MoovProcessor{Codec: Ac3FourCC}); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("NewADTSWriter() error = %v, want %v", err, ErrUnknownCodec)
+	}
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, testSampleEncryptionBox(8, false))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aw, err := NewADTSWriter(&bytes.Buffer{}, MoovProcessor{Codec: Mp4aFourCC, SamplingRate: 44100, Channels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = aw.WriteFragment(f); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("WriteFragment() of an encrypted fragment error = %v, want %v", err, ErrInvalidParam)
+	}
+}
@@ -0,0 +1,69 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// AnnexBWriter writes the samples of the fragments of an H.264 or HEVC track
+// as a raw Annex-B elementary stream, the .h264 and .h265 files read by
+// encoders and analyzers. The NAL units of the samples get start codes
+// instead of their lengths, and the parameter sets of the CodecPrivateData of
+// the track precede every sync sample, so that decoding can start from any
+// of them.
+type AnnexBWriter struct {
+	w             io.Writer
+	p             MoovProcessor
+	parameterSets []byte
+}
+
+// NewAnnexBWriter returns an AnnexBWriter writing the samples of the track p
+// to w.
+func NewAnnexBWriter(w io.Writer, p MoovProcessor) (aw *AnnexBWriter, err error) {
+	if nalUnitHeaderSize(p.Codec) == 0 {
+		err = fmt.Errorf("Annex-B stream of %s: %w", p.Codec, ErrUnknownCodec)
+		return
+	}
+	aw = &AnnexBWriter{w: w, p: p}
+	for _, nalu := range bytes.Split(p.CodecPrivateData, []byte{0, 0, 0, 1}) {
+		if len(nalu) > 0 {
+			aw.parameterSets = appendAnnexB(aw.parameterSets, nalu)
+		}
+	}
+	if len(aw.parameterSets) == 0 {
+		err = fmt.Errorf("no parameter sets in CodecPrivateData: %w", ErrInvalidParam)
+	}
+	return
+}
+
+// WriteFragment writes the samples of a clear fragment of the track, see
+// Decrypter.
+func (aw *AnnexBWriter) WriteFragment(f Fragment) (err error) {
+	samples, err := f.Samples(aw.p)
+	if err != nil {
+		return
+	}
+	for i, sample := range samples {
+		if sample.Encryption != nil {
+			err = fmt.Errorf("track %d sample %d is encrypted: %w", sample.TrackID, i, ErrInvalidParam)
+			return
+		}
+		var nalus [][]byte
+		if nalus, err = splitNALUnits(sample.Data); err != nil {
+			err = fmt.Errorf("track %d sample %d: %w", sample.TrackID, i, err)
+			return
+		}
+		var data []byte
+		if sample.IsSync() {
+			data = append(data, aw.parameterSets...)
+		}
+		for _, nalu := range nalus {
+			data = appendAnnexB(data, nalu)
+		}
+		if _, err = aw.w.Write(data); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestAnnexBWriter(t *testing.T) {
+	f, data := testNALUnitFragment(t)
+	// the second sample is not a sync sample
+	run := f.Tracks[0].Runs[0]
+	run.Mp4BoxSetFlags(run.Mp4BoxFlags() | mp4.FLAG_TRUN_FIRST_SAMPLE_FLAGS)
+	run.FirstSampleFlags = DefaultSyncSampleFlags
+	f.Tracks[0].Header.DefaultSampleFlags = DefaultVideoSampleFlags
+
+	codecPrivateData := decodeHex(t, testH264CodecPrivateData)
+	var buf bytes.Buffer
+	aw, err := NewAnnexBWriter(&buf, MoovProcessor{Codec: mp4.Avc1FourCC, StreamType: VideoStream, CodecPrivateData: codecPrivateData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = aw.WriteFragment(f); err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	want = append(want, codecPrivateData...)
+	for _, nalu := range [][]byte{data[4:37], data[41:47], data[51:]} {
+		want = appendAnnexB(want, nalu)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("stream = %x, want %x", buf.Bytes(), want)
+	}
+}
+
+func TestNewAnnexBWriterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       MoovProcessor
+		wantErr error
+	}{
+		{name: "audio", p: MoovProcessor{Codec: Mp4aFourCC}, wantErr: ErrUnknownCodec},
+		{name: "no parameter sets", p: MoovProcessor{Codec: mp4.Avc1FourCC}, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAnnexBWriter(&bytes.Buffer{}, tt.p); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewAnnexBWriter() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnnexBWriterWriteFragmentErrors(t *testing.T) {
+	p := MoovProcessor{Codec: mp4.Avc1FourCC, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+	tests := []struct {
+		name string
+		senc *SampleEncryptionBox
+	}{
+		{name: "encrypted", senc: testSampleEncryptionBox(8, false)},
+		// the 0xaa sample data is not a sequence of NAL units
+		{name: "not NAL units"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, tt.senc)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			aw, err := NewAnnexBWriter(&bytes.Buffer{}, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = aw.WriteFragment(f); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("WriteFragment() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
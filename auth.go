@@ -0,0 +1,111 @@
+package smoothstreaming
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Credentials authorize the requests of a Downloader, e.g. the token of a
+// tokenized CDN, which expires during a long recording.
+type Credentials struct {
+	// the query parameters set on the URL of every request, replacing the
+	// parameters of the same name.
+	Query url.Values
+
+	// the headers added to every request, replacing the Header of the
+	// Downloader of the same name.
+	Header http.Header
+}
+
+// CredentialsFunc returns fresh Credentials, e.g. by signing a new token or
+// by asking the origin for one.
+type CredentialsFunc func(ctx context.Context) (Credentials, error)
+
+// Authorizer supplies the Credentials of the requests of a Downloader, and
+// refreshes them when the server rejects a request with 401 Unauthorized or
+// 403 Forbidden, or periodically, so that recordings outlive the tokens.
+// The request rejected is retried once with the fresh credentials, besides
+// the retries of the RetryPolicy. An Authorizer may be shared by several
+// Downloaders, refreshing the credentials once for all of them.
+type Authorizer struct {
+	refresh  CredentialsFunc
+	interval time.Duration
+
+	mu sync.Mutex
+	// the current credentials, refreshed at the given time.
+	credentials Credentials
+	refreshed   time.Time
+	// the number of refreshes, so that the requests rejected concurrently
+	// with the same credentials refresh them once.
+	generation int
+}
+
+// NewAuthorizer returns an Authorizer whose credentials are returned by
+// refresh, called before the first request, when a request is rejected, and
+// every interval if it is not 0.
+func NewAuthorizer(refresh CredentialsFunc, interval time.Duration) *Authorizer {
+	return &Authorizer{refresh: refresh, interval: interval}
+}
+
+// current returns the current credentials, refreshing them if they are due,
+// and their generation. A nil Authorizer has empty credentials.
+func (a *Authorizer) current(ctx context.Context) (c Credentials, generation int, err error) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.refreshed.IsZero() || (a.interval > 0 && time.Since(a.refreshed) >= a.interval) {
+		var fresh Credentials
+		if fresh, err = a.refresh(ctx); err != nil {
+			err = fmt.Errorf("refresh credentials: %w", err)
+			return
+		}
+		a.credentials, a.refreshed = fresh, time.Now()
+		a.generation++
+	}
+	return a.credentials, a.generation, nil
+}
+
+// reject marks the credentials of the given generation as rejected by the
+// server, so that they are refreshed before the next request, unless they
+// already were.
+func (a *Authorizer) reject(generation int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if generation == a.generation {
+		a.refreshed = time.Time{}
+	}
+}
+
+// authorize returns u with the query parameters of c, and the headers of c
+// followed by header.
+func (c Credentials) authorize(u *url.URL, header http.Header) (authorized *url.URL, merged http.Header) {
+	authorized, merged = u, header
+	if len(c.Query) > 0 {
+		copied := *u
+		query := copied.Query()
+		for name, values := range c.Query {
+			query[name] = values
+		}
+		copied.RawQuery = query.Encode()
+		authorized = &copied
+	}
+	if len(c.Header) > 0 {
+		merged = c.Header.Clone()
+		for name, values := range header {
+			merged[name] = values
+		}
+	}
+	return
+}
+
+// rejectedStatus reports whether a response of the given status rejects the
+// credentials of the request.
+func rejectedStatus(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
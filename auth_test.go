@@ -0,0 +1,135 @@
+package smoothstreaming
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCredentialsAuthorize(t *testing.T) {
+	u, err := url.Parse("http://example.com/movie.ism/Manifest?token=old&v=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Credentials{
+		Query:  url.Values{"token": {"new"}},
+		Header: http.Header{"Authorization": {"Bearer t"}, "X-Test": {"credentials"}},
+	}
+	authorized, header := c.authorize(u, http.Header{"X-Test": {"request"}})
+	if got := authorized.Query(); !reflect.DeepEqual(got, url.Values{"token": {"new"}, "v": {"1"}}) {
+		t.Errorf("authorized query %v, want the token replaced", got)
+	}
+	if u.Query().Get("token") != "old" {
+		t.Error("authorize modified the URL")
+	}
+	want := http.Header{"Authorization": {"Bearer t"}, "X-Test": {"request"}}
+	if !reflect.DeepEqual(header, want) {
+		t.Errorf("authorized header %v, want %v", header, want)
+	}
+
+	// empty credentials leave the request unchanged
+	if authorized, header = (Credentials{}).authorize(u, nil); authorized != u || header != nil {
+		t.Errorf("authorize() with empty credentials = %v, %v, want the request", authorized, header)
+	}
+}
+
+func TestAuthorizerCurrent(t *testing.T) {
+	var refreshes int
+	a := NewAuthorizer(func(ctx context.Context) (Credentials, error) {
+		refreshes++
+		return Credentials{Query: url.Values{"token": {strconv.Itoa(refreshes)}}}, nil
+	}, 0)
+	for i := 0; i < 2; i++ {
+		c, generation, err := a.current(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Query.Get("token") != "1" || generation != 1 {
+			t.Errorf("current() = %v, %d, want the first credentials", c.Query, generation)
+		}
+	}
+
+	// a rejection of former credentials does not refresh them again
+	a.reject(0)
+	if _, generation, _ := a.current(context.Background()); generation != 1 {
+		t.Errorf("generation %d after rejecting former credentials, want 1", generation)
+	}
+	a.reject(1)
+	if c, generation, _ := a.current(context.Background()); c.Query.Get("token") != "2" || generation != 2 {
+		t.Errorf("current() = %v, %d after a rejection, want the second credentials", c.Query, generation)
+	}
+
+	// periodic refreshes
+	a.interval = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, generation, _ := a.current(context.Background()); generation != 3 {
+		t.Errorf("generation %d after the interval, want 3", generation)
+	}
+
+	var nilAuthorizer *Authorizer
+	if c, generation, err := nilAuthorizer.current(context.Background()); err != nil || generation != 0 || c.Query != nil || c.Header != nil {
+		t.Errorf("current() of a nil Authorizer = %v, %d, %v, want empty credentials", c, generation, err)
+	}
+
+	errRefresh := errors.New("refresh failed")
+	failing := NewAuthorizer(func(ctx context.Context) (Credentials, error) {
+		return Credentials{}, errRefresh
+	}, 0)
+	if _, _, err := failing.current(context.Background()); !errors.Is(err, errRefresh) {
+		t.Errorf("current() error = %v, want %v", err, errRefresh)
+	}
+}
+
+func TestDownloaderAuth(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		validFrom    int
+		wantRequests int
+		wantTokens   int
+		wantErr      error
+	}{
+		{name: "valid", status: http.StatusForbidden, validFrom: 1, wantRequests: 1, wantTokens: 1},
+		{name: "expired", status: http.StatusForbidden, validFrom: 2, wantRequests: 2, wantTokens: 2},
+		{name: "unauthorized", status: http.StatusUnauthorized, validFrom: 2, wantRequests: 2, wantTokens: 2},
+		{name: "rejected again", status: http.StatusForbidden, validFrom: 3, wantRequests: 2, wantTokens: 2, wantErr: ErrUnexpectedStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if token, _ := strconv.Atoi(r.URL.Query().Get("token")); token < tt.validFrom {
+					w.WriteHeader(tt.status)
+					return
+				}
+				io.WriteString(w, testManifest)
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tokens int
+			auth := NewAuthorizer(func(ctx context.Context) (Credentials, error) {
+				tokens++
+				return Credentials{Query: url.Values{"token": {strconv.Itoa(tokens)}}}, nil
+			}, 0)
+			// the retry with fresh credentials is not one of the policy
+			d := Downloader{Auth: auth}
+			if _, err = d.FetchManifest(context.Background(), u); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FetchManifest() error = %v, want %v", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests || tokens != tt.wantTokens {
+				t.Errorf("sent %d requests with %d tokens, want %d with %d", requests, tokens, tt.wantRequests, tt.wantTokens)
+			}
+		})
+	}
+}
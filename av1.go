@@ -0,0 +1,342 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-webdl/bits"
+)
+
+// AV1 OBU types of AV1 Bitstream & Decoding Process Specification 6.2.2.
+const (
+	OBUSequenceHeader       uint8 = 1
+	OBUTemporalDelimiter    uint8 = 2
+	OBUFrameHeader          uint8 = 3
+	OBUTileGroup            uint8 = 4
+	OBUMetadata             uint8 = 5
+	OBUFrame                uint8 = 6
+	OBURedundantFrameHeader uint8 = 7
+	OBUTileList             uint8 = 8
+	OBUPadding              uint8 = 15
+)
+
+// AV1OBU is an open bitstream unit of an AV1 stream.
+type AV1OBU struct {
+	Type uint8
+
+	// the OBU header, including the extension header when present, but
+	// without the size field.
+	Header []byte
+
+	Payload []byte
+}
+
+// Bytes serializes the OBU in the low overhead bitstream format, with its
+// size field as required in the configOBUs of the av1C box.
+func (o AV1OBU) Bytes() []byte {
+	data := append([]byte{}, o.Header...)
+	data[0] |= 0x02 // obu_has_size_field
+	data = append(data, leb128(uint64(len(o.Payload)))...)
+	return append(data, o.Payload...)
+}
+
+// ParseAV1OBUs splits a sequence of OBUs. The last OBU may omit its size
+// field, in which case it extends to the end of data.
+func ParseAV1OBUs(data []byte) (obus []AV1OBU, err error) {
+	for len(data) > 0 {
+		var obu AV1OBU
+		if data[0]&0x80 != 0 {
+			err = fmt.Errorf("AV1 OBU forbidden bit set: %w", ErrInvalidParam)
+			return
+		}
+		obu.Type = data[0] >> 3 & 0x0f
+		headerSize := 1
+		if data[0]&0x04 != 0 { // obu_extension_flag
+			headerSize++
+		}
+		if len(data) < headerSize {
+			err = fmt.Errorf("AV1 OBU header truncated: %w", ErrInvalidParam)
+			return
+		}
+		obu.Header = append([]byte{}, data[:headerSize]...)
+		obu.Header[0] &^= 0x02
+		rest := data[headerSize:]
+		size := uint64(len(rest))
+		if data[0]&0x02 != 0 { // obu_has_size_field
+			var n int
+			if size, n = readLEB128(rest); n == 0 {
+				err = fmt.Errorf("AV1 OBU size truncated: %w", ErrInvalidParam)
+				return
+			}
+			rest = rest[n:]
+		}
+		if size > uint64(len(rest)) {
+			err = fmt.Errorf("AV1 OBU payload truncated: %w", ErrInvalidParam)
+			return
+		}
+		obu.Payload = rest[:size]
+		obus = append(obus, obu)
+		data = rest[size:]
+	}
+	return
+}
+
+func leb128(v uint64) (data []byte) {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(data, b)
+		}
+		data = append(data, b|0x80)
+	}
+}
+
+// readLEB128 returns the decoded value and the number of bytes consumed, 0 if
+// data is truncated.
+func readLEB128(data []byte) (v uint64, n int) {
+	for i := 0; i < 8 && i < len(data); i++ {
+		v |= uint64(data[i]&0x7f) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// AV1SequenceHeader holds the fields of an AV1 sequence header OBU that
+// describe the stream, AV1 specification 5.5.
+type AV1SequenceHeader struct {
+	SeqProfile   uint8
+	StillPicture bool
+	SeqLevelIdx0 uint8
+	SeqTier0     uint8
+
+	MaxFrameWidth  uint32
+	MaxFrameHeight uint32
+
+	BitDepth             uint8
+	HighBitdepth         bool
+	TwelveBit            bool
+	Monochrome           bool
+	SubsamplingX         bool
+	SubsamplingY         bool
+	ChromaSamplePosition uint8
+
+	ColorDescriptionPresent bool
+	ColorPrimaries          uint8
+	TransferCharacteristics uint8
+	MatrixCoefficients      uint8
+	ColorRange              bool
+
+	InitialDisplayDelayPresent  bool
+	InitialDisplayDelayMinusOne uint8
+}
+
+// ParseAV1SequenceHeader parses the payload of a sequence header OBU.
+func ParseAV1SequenceHeader(payload []byte) (h AV1SequenceHeader, err error) {
+	r := bits.NewAccErrReader(bytes.NewReader(payload))
+	h.SeqProfile = uint8(r.Read(3))
+	h.StillPicture = r.ReadFlag()
+	reducedStillPictureHeader := r.ReadFlag()
+	if reducedStillPictureHeader {
+		h.SeqLevelIdx0 = uint8(r.Read(5))
+	} else {
+		var decoderModelInfoPresent bool
+		var bufferDelayLength int
+		if r.ReadFlag() { // timing_info_present_flag
+			r.Read(32)        // num_units_in_display_tick
+			r.Read(32)        // time_scale
+			if r.ReadFlag() { // equal_picture_interval
+				readUVLC(r) // num_ticks_per_picture_minus_1
+			}
+			if decoderModelInfoPresent = r.ReadFlag(); decoderModelInfoPresent {
+				bufferDelayLength = int(r.Read(5)) + 1
+				r.Read(32) // num_units_in_decoding_tick
+				r.Read(5)  // buffer_removal_time_length_minus_1
+				r.Read(5)  // frame_presentation_time_length_minus_1
+			}
+		}
+		initialDisplayDelayPresent := r.ReadFlag()
+		operatingPoints := int(r.Read(5)) + 1
+		for i := 0; i < operatingPoints; i++ {
+			r.Read(12) // operating_point_idc
+			seqLevelIdx := uint8(r.Read(5))
+			var seqTier uint8
+			if seqLevelIdx > 7 {
+				seqTier = uint8(r.Read(1))
+			}
+			if decoderModelInfoPresent && r.ReadFlag() { // decoder_model_present_for_this_op
+				r.Read(bufferDelayLength) // decoder_buffer_delay
+				r.Read(bufferDelayLength) // encoder_buffer_delay
+				r.Read(1)                 // low_delay_mode_flag
+			}
+			var delayPresent bool
+			var delayMinusOne uint8
+			if initialDisplayDelayPresent {
+				if delayPresent = r.ReadFlag(); delayPresent {
+					delayMinusOne = uint8(r.Read(4))
+				}
+			}
+			if i == 0 {
+				h.SeqLevelIdx0 = seqLevelIdx
+				h.SeqTier0 = seqTier
+				h.InitialDisplayDelayPresent = delayPresent
+				h.InitialDisplayDelayMinusOne = delayMinusOne
+			}
+		}
+	}
+	frameWidthBits := int(r.Read(4)) + 1
+	frameHeightBits := int(r.Read(4)) + 1
+	h.MaxFrameWidth = uint32(r.Read(frameWidthBits)) + 1
+	h.MaxFrameHeight = uint32(r.Read(frameHeightBits)) + 1
+	if !reducedStillPictureHeader && r.ReadFlag() { // frame_id_numbers_present_flag
+		r.Read(4) // delta_frame_id_length_minus_2
+		r.Read(3) // additional_frame_id_length_minus_1
+	}
+	r.Read(1) // use_128x128_superblock
+	r.Read(1) // enable_filter_intra
+	r.Read(1) // enable_intra_edge_filter
+	if !reducedStillPictureHeader {
+		r.Read(1) // enable_interintra_compound
+		r.Read(1) // enable_masked_compound
+		r.Read(1) // enable_warped_motion
+		r.Read(1) // enable_dual_filter
+		enableOrderHint := r.ReadFlag()
+		if enableOrderHint {
+			r.Read(1) // enable_jnt_comp
+			r.Read(1) // enable_ref_frame_mvs
+		}
+		seqForceScreenContentTools := uint(2) // SELECT_SCREEN_CONTENT_TOOLS
+		if !r.ReadFlag() {                    // seq_choose_screen_content_tools
+			seqForceScreenContentTools = r.Read(1)
+		}
+		if seqForceScreenContentTools > 0 {
+			if !r.ReadFlag() { // seq_choose_integer_mv
+				r.Read(1) // seq_force_integer_mv
+			}
+		}
+		if enableOrderHint {
+			r.Read(3) // order_hint_bits_minus_1
+		}
+	}
+	r.Read(1) // enable_superres
+	r.Read(1) // enable_cdef
+	r.Read(1) // enable_restoration
+	h.readColorConfig(r)
+	if err = r.AccError(); err != nil {
+		err = fmt.Errorf("AV1 sequence header truncated: %w", ErrInvalidParam)
+		return
+	}
+	return
+}
+
+func (h *AV1SequenceHeader) readColorConfig(r *bits.AccErrReader) {
+	h.HighBitdepth = r.ReadFlag()
+	h.BitDepth = 8
+	if h.SeqProfile == 2 && h.HighBitdepth {
+		h.TwelveBit = r.ReadFlag()
+		h.BitDepth = 10
+		if h.TwelveBit {
+			h.BitDepth = 12
+		}
+	} else if h.HighBitdepth {
+		h.BitDepth = 10
+	}
+	if h.SeqProfile != 1 {
+		h.Monochrome = r.ReadFlag()
+	}
+	h.ColorPrimaries, h.TransferCharacteristics, h.MatrixCoefficients = 2, 2, 2 // unspecified
+	if h.ColorDescriptionPresent = r.ReadFlag(); h.ColorDescriptionPresent {
+		h.ColorPrimaries = uint8(r.Read(8))
+		h.TransferCharacteristics = uint8(r.Read(8))
+		h.MatrixCoefficients = uint8(r.Read(8))
+	}
+	if h.Monochrome {
+		h.ColorRange = r.ReadFlag()
+		h.SubsamplingX, h.SubsamplingY = true, true
+		return
+	}
+	if h.ColorPrimaries == 1 && h.TransferCharacteristics == 13 && h.MatrixCoefficients == 0 { // sRGB
+		h.ColorRange = true
+		return
+	}
+	h.ColorRange = r.ReadFlag()
+	switch {
+	case h.SeqProfile == 0:
+		h.SubsamplingX, h.SubsamplingY = true, true
+	case h.SeqProfile == 1:
+	case h.BitDepth == 12:
+		if h.SubsamplingX = r.ReadFlag(); h.SubsamplingX {
+			h.SubsamplingY = r.ReadFlag()
+		}
+	default:
+		h.SubsamplingX = true
+	}
+	if h.SubsamplingX && h.SubsamplingY {
+		h.ChromaSamplePosition = uint8(r.Read(2))
+	}
+}
+
+func readUVLC(r *bits.AccErrReader) uint32 {
+	leadingZeros := 0
+	for !r.ReadFlag() {
+		if r.AccError() != nil {
+			return 0
+		}
+		leadingZeros++
+	}
+	if leadingZeros >= 32 {
+		return 1<<32 - 1
+	}
+	return uint32(r.Read(leadingZeros)) + 1<<leadingZeros - 1
+}
+
+// NewAV1CodecConfigurationBox creates the av1C box of an AV1 track from its
+// CodecPrivateData, which holds either an AV1CodecConfigurationRecord or the
+// OBUs of the sequence header, optionally preceded by a temporal delimiter.
+func NewAV1CodecConfigurationBox(codecPrivateData []byte) (av1C *AV1CodecConfigurationBox, header AV1SequenceHeader, err error) {
+	data := codecPrivateData
+	if len(data) >= 4 && data[0] == 0x81 { // marker and version 1
+		data = data[4:]
+	}
+	obus, err := ParseAV1OBUs(data)
+	if err != nil {
+		return
+	}
+	var configOBUs []byte
+	found := false
+	for _, obu := range obus {
+		switch obu.Type {
+		case OBUSequenceHeader:
+			if !found {
+				if header, err = ParseAV1SequenceHeader(obu.Payload); err != nil {
+					return
+				}
+				found = true
+			}
+			configOBUs = append(configOBUs, obu.Bytes()...)
+		case OBUMetadata:
+			configOBUs = append(configOBUs, obu.Bytes()...)
+		}
+	}
+	if !found {
+		err = fmt.Errorf("AV1 CodecPrivateData has no sequence header OBU: %w", ErrInvalidParam)
+		return
+	}
+	av1C = &AV1CodecConfigurationBox{
+		SeqProfile:                       header.SeqProfile,
+		SeqLevelIdx0:                     header.SeqLevelIdx0,
+		SeqTier0:                         header.SeqTier0,
+		HighBitdepth:                     header.HighBitdepth,
+		TwelveBit:                        header.TwelveBit,
+		Monochrome:                       header.Monochrome,
+		ChromaSubsamplingX:               header.SubsamplingX,
+		ChromaSubsamplingY:               header.SubsamplingY,
+		ChromaSamplePosition:             header.ChromaSamplePosition,
+		InitialPresentationDelayPresent:  header.InitialDisplayDelayPresent,
+		InitialPresentationDelayMinusOne: header.InitialDisplayDelayMinusOne,
+		ConfigOBUs:                       configOBUs,
+	}
+	return
+}
@@ -0,0 +1,171 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// sequence header OBU payloads of a 1920x1080 stream: of level 4.0 with color
+// description, of level 4.1 high tier at 10 bits with timing and decoder model
+// information of two operating points, and of a still picture with a reduced
+// header.
+const (
+	testAV1SequenceHeader           = "00000042abbfc373ffe640404041"
+	testAV1SequenceHeaderTiming     = "0400000fa40003a9839000000004008400138c6c80010aaeff0dcfff9ca4"
+	testAV1SequenceHeaderStillImage = "1b2abbfc376c02"
+)
+
+// testAV1OBU returns the hex of an OBU of the given type with a size field.
+func testAV1OBU(obuType uint8, payload string) string {
+	return fmt.Sprintf("%02x%02x%s", obuType<<3|0x02, len(payload)/2, payload)
+}
+
+func TestParseAV1SequenceHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    AV1SequenceHeader
+		wantErr error
+	}{
+		{
+			name:    "color description",
+			payload: testAV1SequenceHeader,
+			want: AV1SequenceHeader{
+				SeqLevelIdx0: 8, MaxFrameWidth: 1920, MaxFrameHeight: 1080, BitDepth: 8, SubsamplingX: true, SubsamplingY: true,
+				ColorDescriptionPresent: true, ColorPrimaries: 1, TransferCharacteristics: 1, MatrixCoefficients: 1,
+			},
+		},
+		{
+			name:    "timing and decoder model information",
+			payload: testAV1SequenceHeaderTiming,
+			want: AV1SequenceHeader{
+				SeqLevelIdx0: 9, SeqTier0: 1, MaxFrameWidth: 1920, MaxFrameHeight: 1080,
+				BitDepth: 10, HighBitdepth: true, SubsamplingX: true, SubsamplingY: true, ChromaSamplePosition: 1,
+				ColorPrimaries: 2, TransferCharacteristics: 2, MatrixCoefficients: 2, ColorRange: true,
+				InitialDisplayDelayPresent: true, InitialDisplayDelayMinusOne: 9,
+			},
+		},
+		{
+			name:    "reduced still picture header",
+			payload: testAV1SequenceHeaderStillImage,
+			want: AV1SequenceHeader{
+				StillPicture: true, SeqLevelIdx0: 12, MaxFrameWidth: 1920, MaxFrameHeight: 1080, BitDepth: 8, SubsamplingX: true, SubsamplingY: true,
+				ColorPrimaries: 2, TransferCharacteristics: 2, MatrixCoefficients: 2,
+			},
+		},
+		{
+			name:    "truncated",
+			payload: testAV1SequenceHeader[:10],
+			wantErr: ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAV1SequenceHeader(decodeHex(t, tt.payload))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseAV1SequenceHeader() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ParseAV1SequenceHeader() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAV1OBUs(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []AV1OBU
+		wantErr error
+	}{
+		{
+			name: "size fields",
+			data: "1200" + "0a020102",
+			want: []AV1OBU{{Type: OBUTemporalDelimiter, Header: []byte{0x10}, Payload: []byte{}}, {Type: OBUSequenceHeader, Header: []byte{0x08}, Payload: []byte{1, 2}}},
+		},
+		{
+			name: "last OBU without size field",
+			data: "0a0101" + "28aabb",
+			want: []AV1OBU{{Type: OBUSequenceHeader, Header: []byte{0x08}, Payload: []byte{1}}, {Type: OBUMetadata, Header: []byte{0x28}, Payload: []byte{0xaa, 0xbb}}},
+		},
+		{
+			name: "extension header",
+			data: "2e200101",
+			want: []AV1OBU{{Type: OBUMetadata, Header: []byte{0x2c, 0x20}, Payload: []byte{1}}},
+		},
+		{name: "forbidden bit", data: "8a00", wantErr: ErrInvalidParam},
+		{name: "truncated extension header", data: "2e", wantErr: ErrInvalidParam},
+		{name: "truncated size", data: "0a80", wantErr: ErrInvalidParam},
+		{name: "truncated payload", data: "0a0301", wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAV1OBUs(decodeHex(t, tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseAV1OBUs() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAV1OBUs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLEB128(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 300, 1<<32 - 1} {
+		data := leb128(v)
+		got, n := readLEB128(data)
+		if got != v || n != len(data) {
+			t.Errorf("readLEB128(leb128(%d)) = %d, %d, want %d, %d", v, got, n, v, len(data))
+		}
+	}
+	if got := leb128(300); !bytes.Equal(got, []byte{0xac, 0x02}) {
+		t.Errorf("leb128(300) = %x, want ac02", got)
+	}
+}
+
+func TestNewAV1CodecConfigurationBox(t *testing.T) {
+	sequenceHeader := testAV1OBU(OBUSequenceHeader, testAV1SequenceHeaderTiming)
+	metadata := testAV1OBU(OBUMetadata, "0401")
+	tests := []struct {
+		name             string
+		codecPrivateData string
+		wantOBUs         string
+		wantErr          error
+	}{
+		{name: "OBUs", codecPrivateData: "1200" + sequenceHeader + metadata, wantOBUs: sequenceHeader + metadata},
+		{name: "AV1CodecConfigurationRecord", codecPrivateData: "8129c000" + sequenceHeader + metadata, wantOBUs: sequenceHeader + metadata},
+		{name: "sequence header without size field", codecPrivateData: "1200" + metadata + "08" + testAV1SequenceHeaderTiming, wantOBUs: metadata + sequenceHeader},
+		{name: "no sequence header", codecPrivateData: "1200" + metadata, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			av1C, header, err := NewAV1CodecConfigurationBox(decodeHex(t, tt.codecPrivateData))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewAV1CodecConfigurationBox() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if header.MaxFrameWidth != 1920 || header.MaxFrameHeight != 1080 {
+				t.Errorf("size %dx%d, want 1920x1080", header.MaxFrameWidth, header.MaxFrameHeight)
+			}
+			want := AV1CodecConfigurationBox{
+				SeqLevelIdx0: 9, SeqTier0: 1, HighBitdepth: true, ChromaSubsamplingX: true, ChromaSubsamplingY: true, ChromaSamplePosition: 1,
+				InitialPresentationDelayPresent: true, InitialPresentationDelayMinusOne: 9,
+			}
+			configOBUs := av1C.ConfigOBUs
+			av1C.ConfigOBUs = nil
+			if !reflect.DeepEqual(*av1C, want) {
+				t.Errorf("av1C = %+v, want %+v", *av1C, want)
+			}
+			if !bytes.Equal(configOBUs, decodeHex(t, tt.wantOBUs)) {
+				t.Errorf("ConfigOBUs = %x, want %s", configOBUs, tt.wantOBUs)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package smoothstreaming
+
+import "github.com/go-webdl/mp4"
+
+// Box types and four-character codes of the boxes and sample entries that are
+// defined by this package rather than by the mp4 package.
+var (
+	Ac3BoxType  = mp4.BoxType{'a', 'c', '-', '3'}
+	Av01BoxType = mp4.BoxType{'a', 'v', '0', '1'}
+	Av1CBoxType = mp4.BoxType{'a', 'v', '1', 'C'}
+	ChnlBoxType = mp4.BoxType{'c', 'h', 'n', 'l'}
+	ClliBoxType = mp4.BoxType{'c', 'l', 'l', 'i'}
+	Co64BoxType = mp4.BoxType{'c', 'o', '6', '4'}
+	Dac3BoxType = mp4.BoxType{'d', 'a', 'c', '3'}
+	DataBoxType = mp4.BoxType{'d', 'a', 't', 'a'}
+	DdtsBoxType = mp4.BoxType{'d', 'd', 't', 's'}
+	Dec3BoxType = mp4.BoxType{'d', 'e', 'c', '3'}
+	DtscBoxType = mp4.BoxType{'d', 't', 's', 'c'}
+	DtseBoxType = mp4.BoxType{'d', 't', 's', 'e'}
+	DtshBoxType = mp4.BoxType{'d', 't', 's', 'h'}
+	DtslBoxType = mp4.BoxType{'d', 't', 's', 'l'}
+	Dvc1BoxType = mp4.BoxType{'d', 'v', 'c', '1'}
+	EdtsBoxType = mp4.BoxType{'e', 'd', 't', 's'}
+	ElstBoxType = mp4.BoxType{'e', 'l', 's', 't'}
+	Ec3BoxType  = mp4.BoxType{'e', 'c', '-', '3'}
+	EmsgBoxType = mp4.BoxType{'e', 'm', 's', 'g'}
+	EsdsBoxType = mp4.BoxType{'e', 's', 'd', 's'}
+	IlstBoxType = mp4.BoxType{'i', 'l', 's', 't'}
+	MdcvBoxType = mp4.BoxType{'m', 'd', 'c', 'v'}
+	MehdBoxType = mp4.BoxType{'m', 'e', 'h', 'd'}
+	MetaBoxType = mp4.BoxType{'m', 'e', 't', 'a'}
+	MettBoxType = mp4.BoxType{'m', 'e', 't', 't'}
+	MfraBoxType = mp4.BoxType{'m', 'f', 'r', 'a'}
+	MfroBoxType = mp4.BoxType{'m', 'f', 'r', 'o'}
+	Mp4aBoxType = mp4.BoxType{'m', 'p', '4', 'a'}
+	NamBoxType  = mp4.BoxType{0xa9, 'n', 'a', 'm'} // '©nam'
+	OwmaBoxType = mp4.BoxType{'o', 'w', 'm', 'a'}
+	PrftBoxType = mp4.BoxType{'p', 'r', 'f', 't'}
+	SaioBoxType = mp4.BoxType{'s', 'a', 'i', 'o'}
+	SaizBoxType = mp4.BoxType{'s', 'a', 'i', 'z'}
+	SbgpBoxType = mp4.BoxType{'s', 'b', 'g', 'p'}
+	SgpdBoxType = mp4.BoxType{'s', 'g', 'p', 'd'}
+	SidxBoxType = mp4.BoxType{'s', 'i', 'd', 'x'}
+	SkipBoxType = mp4.BoxType{'s', 'k', 'i', 'p'}
+	SthdBoxType = mp4.BoxType{'s', 't', 'h', 'd'}
+	StppBoxType = mp4.BoxType{'s', 't', 'p', 'p'}
+	StypBoxType = mp4.BoxType{'s', 't', 'y', 'p'}
+	TfdtBoxType = mp4.BoxType{'t', 'f', 'd', 't'}
+	TfraBoxType = mp4.BoxType{'t', 'f', 'r', 'a'}
+	UdtaBoxType = mp4.BoxType{'u', 'd', 't', 'a'}
+	UriBoxType  = mp4.BoxType{'u', 'r', 'i', ' '}
+	UriIBoxType = mp4.BoxType{'u', 'r', 'i', 'I'}
+	UrimBoxType = mp4.BoxType{'u', 'r', 'i', 'm'}
+	Vc1BoxType  = mp4.BoxType{'v', 'c', '-', '1'}
+	Vp09BoxType = mp4.BoxType{'v', 'p', '0', '9'}
+	VpcCBoxType = mp4.BoxType{'v', 'p', 'c', 'C'}
+	WfexBoxType = mp4.BoxType{'w', 'f', 'e', 'x'}
+
+	Ac3FourCC  = mp4.FourCC{'a', 'c', '-', '3'}
+	Av01FourCC = mp4.FourCC{'a', 'v', '0', '1'}
+	Cbc1FourCC = mp4.FourCC{'c', 'b', 'c', '1'}
+	CbcsFourCC = mp4.FourCC{'c', 'b', 'c', 's'}
+	CmfcFourCC = mp4.FourCC{'c', 'm', 'f', 'c'}
+	CmfsFourCC = mp4.FourCC{'c', 'm', 'f', 's'}
+	DtscFourCC = mp4.FourCC{'d', 't', 's', 'c'}
+	DtseFourCC = mp4.FourCC{'d', 't', 's', 'e'}
+	DtshFourCC = mp4.FourCC{'d', 't', 's', 'h'}
+	DtslFourCC = mp4.FourCC{'d', 't', 's', 'l'}
+	Ec3FourCC  = mp4.FourCC{'e', 'c', '-', '3'}
+	IsmlFourCC = mp4.FourCC{'i', 's', 'm', 'l'}
+	MdirFourCC = mp4.FourCC{'m', 'd', 'i', 'r'}
+	MettFourCC = mp4.FourCC{'m', 'e', 't', 't'}
+	Mp4aFourCC = mp4.FourCC{'m', 'p', '4', 'a'}
+	OwmaFourCC = mp4.FourCC{'o', 'w', 'm', 'a'}
+	PiffFourCC = mp4.FourCC{'p', 'i', 'f', 'f'}
+	SeigFourCC = mp4.FourCC{'s', 'e', 'i', 'g'}
+	StppFourCC = mp4.FourCC{'s', 't', 'p', 'p'}
+	SubtFourCC = mp4.FourCC{'s', 'u', 'b', 't'}
+	UrimFourCC = mp4.FourCC{'u', 'r', 'i', 'm'}
+	Vc1FourCC  = mp4.FourCC{'v', 'c', '-', '1'}
+	Vp09FourCC = mp4.FourCC{'v', 'p', '0', '9'}
+
+	// user type of the PIFF ProtectionSystemSpecificHeaderBox
+	PiffPsshBoxUserType = mp4.UserType{0xd0, 0x8a, 0x4f, 0x18, 0x10, 0xf3, 0x4a, 0x82, 0xb6, 0xc8, 0x32, 0xd8, 0xab, 0xa1, 0x83, 0xd3}
+
+	// user types of the uuid boxes of [MS-SSTR]
+	TfrfBoxUserType = mp4.UserType{0xd4, 0x80, 0x7e, 0xf2, 0xca, 0x39, 0x46, 0x95, 0x8e, 0x54, 0x26, 0xcb, 0x9e, 0x46, 0xa7, 0x9f}
+	TfxdBoxUserType = mp4.UserType{0x6d, 0x1d, 0x9b, 0x05, 0x42, 0xd5, 0x44, 0xe6, 0x80, 0xe2, 0x14, 0x1d, 0xaf, 0xf7, 0x57, 0xb2}
+
+	// user type of the LiveServerManifestBox of the live ingest of Smooth
+	// Streaming
+	LiveServerManifestBoxUserType = mp4.UserType{0xa5, 0xd4, 0x0b, 0x30, 0xe8, 0x14, 0x11, 0xdd, 0xba, 0x2f, 0x08, 0x00, 0x20, 0x0c, 0x9a, 0x66}
+)
@@ -0,0 +1,106 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 12.2.3 Audio Sample entry
+
+// Audio tracks use AudioSampleEntryBox. Only version 0 of the sample entry is
+// supported, so the samplerate field is limited to 65535 Hz; for higher rates
+// it is set to 0 and the decoder configuration is authoritative.
+type AudioSampleEntryBox struct {
+	mp4.SampleEntry
+
+	ChannelCount uint16
+	SampleSize   uint16
+	SampleRate   uint32
+}
+
+var _ mp4.Box = (*AudioSampleEntryBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[Mp4aBoxType] = func() mp4.Box { return &AudioSampleEntryBox{} }
+	mp4.BoxRegistry[Ac3BoxType] = func() mp4.Box { return &AudioSampleEntryBox{} }
+	mp4.BoxRegistry[Ec3BoxType] = func() mp4.Box { return &AudioSampleEntryBox{} }
+	mp4.BoxRegistry[mp4.EncaBoxType] = func() mp4.Box { return &AudioSampleEntryBox{} }
+}
+
+func (b *AudioSampleEntryBox) AudioSampleEntrySize() (size uint32) {
+	size = b.SampleEntrySize()
+	size += 4 * 2 // const unsigned int(32)[2] reserved = 0;
+	size += 2     // template unsigned int(16) channelcount = 2;
+	size += 2     // template unsigned int(16) samplesize = 16;
+	size += 2     // unsigned int(16) pre_defined = 0;
+	size += 2     // const unsigned int(16) reserved = 0 ;
+	size += 4     // template unsigned int(32) samplerate = { default samplerate of media}<<16;
+	return
+}
+
+func (b *AudioSampleEntryBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.AudioSampleEntrySize()
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *AudioSampleEntryBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.SampleEntry.Mp4BoxRead(r, header); err != nil {
+		return
+	}
+	var reserved [2]uint32
+	if err = binary.Read(r, binary.BigEndian, &reserved); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.ChannelCount); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.SampleSize); err != nil {
+		return
+	}
+	var reserved2 [2]uint16
+	if err = binary.Read(r, binary.BigEndian, &reserved2); err != nil {
+		return
+	}
+	var sampleRate uint32
+	if err = binary.Read(r, binary.BigEndian, &sampleRate); err != nil {
+		return
+	}
+	b.SampleRate = sampleRate >> 16
+	if err = b.Mp4BoxReadChildren(r, b.Size-b.AudioSampleEntrySize()); err != nil {
+		return
+	}
+	return
+}
+
+func (b *AudioSampleEntryBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.SampleEntry.Mp4BoxWrite(w); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, [2]uint32{}); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.ChannelCount); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.SampleSize); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, [2]uint16{}); err != nil {
+		return
+	}
+	var sampleRate uint32
+	if b.SampleRate <= 0xffff {
+		sampleRate = b.SampleRate << 16
+	}
+	if err = binary.Write(w, binary.BigEndian, sampleRate); err != nil {
+		return
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,121 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/go-webdl/bits"
+	"github.com/go-webdl/mp4"
+)
+
+// AV1 Codec ISO Media File Format Binding 2.3 AV1 Codec Configuration Box
+
+// Box Type: 'av1C'
+// Container: AV1 Sample Entry ('av01')
+
+// The AV1CodecConfigurationBox carries the AV1CodecConfigurationRecord: a
+// summary of the sequence header followed by the OBUs, including the sequence
+// header OBU, that configure the decoder.
+type AV1CodecConfigurationBox struct {
+	mp4.Header
+	mp4.NullContainer
+
+	SeqProfile           uint8
+	SeqLevelIdx0         uint8
+	SeqTier0             uint8
+	HighBitdepth         bool
+	TwelveBit            bool
+	Monochrome           bool
+	ChromaSubsamplingX   bool
+	ChromaSubsamplingY   bool
+	ChromaSamplePosition uint8
+
+	InitialPresentationDelayPresent  bool
+	InitialPresentationDelayMinusOne uint8
+
+	// the configuration OBUs, each with its size field.
+	ConfigOBUs []byte
+}
+
+var _ mp4.Box = (*AV1CodecConfigurationBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[Av1CBoxType] = func() mp4.Box { return &AV1CodecConfigurationBox{} }
+	mp4.BoxRegistry[Av01BoxType] = func() mp4.Box { return &mp4.VisualSampleEntryBox{} }
+}
+
+func (b AV1CodecConfigurationBox) Mp4BoxType() mp4.BoxType {
+	return Av1CBoxType
+}
+
+func (b *AV1CodecConfigurationBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += 1 // unsigned int (1) marker = 1; unsigned int (7) version = 1;
+	b.Size += 1 // unsigned int (3) seq_profile; unsigned int (5) seq_level_idx_0;
+	b.Size += 1 // unsigned int (1) seq_tier_0; unsigned int (1) high_bitdepth; unsigned int (1) twelve_bit; unsigned int (1) monochrome; unsigned int (1) chroma_subsampling_x; unsigned int (1) chroma_subsampling_y; unsigned int (2) chroma_sample_position;
+	b.Size += 1 // unsigned int (3) reserved = 0; unsigned int (1) initial_presentation_delay_present; unsigned int (4) initial_presentation_delay_minus_one or reserved = 0;
+	b.Size += uint32(len(b.ConfigOBUs))
+	return b.Size
+}
+
+func (b *AV1CodecConfigurationBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	data := make([]byte, b.Size-b.HeaderSize())
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	if len(data) < 4 {
+		err = io.ErrUnexpectedEOF
+		return
+	}
+	b.readRecord(data)
+	return
+}
+
+func (b *AV1CodecConfigurationBox) readRecord(data []byte) {
+	br := bits.NewAccErrReader(bytes.NewReader(data[:4]))
+	br.Read(8) // marker, version
+	b.SeqProfile = uint8(br.Read(3))
+	b.SeqLevelIdx0 = uint8(br.Read(5))
+	b.SeqTier0 = uint8(br.Read(1))
+	b.HighBitdepth = br.ReadFlag()
+	b.TwelveBit = br.ReadFlag()
+	b.Monochrome = br.ReadFlag()
+	b.ChromaSubsamplingX = br.ReadFlag()
+	b.ChromaSubsamplingY = br.ReadFlag()
+	b.ChromaSamplePosition = uint8(br.Read(2))
+	br.Read(3) // reserved
+	b.InitialPresentationDelayPresent = br.ReadFlag()
+	b.InitialPresentationDelayMinusOne = uint8(br.Read(4))
+	b.ConfigOBUs = data[4:]
+}
+
+func (b *AV1CodecConfigurationBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	bw := bits.NewWriter(w)
+	bw.Write(1, 1) // marker
+	bw.Write(1, 7) // version
+	bw.Write(uint(b.SeqProfile), 3)
+	bw.Write(uint(b.SeqLevelIdx0), 5)
+	bw.Write(uint(b.SeqTier0), 1)
+	bw.Write(boolBit(b.HighBitdepth), 1)
+	bw.Write(boolBit(b.TwelveBit), 1)
+	bw.Write(boolBit(b.Monochrome), 1)
+	bw.Write(boolBit(b.ChromaSubsamplingX), 1)
+	bw.Write(boolBit(b.ChromaSubsamplingY), 1)
+	bw.Write(uint(b.ChromaSamplePosition), 2)
+	bw.Write(0, 3)
+	bw.Write(boolBit(b.InitialPresentationDelayPresent), 1)
+	bw.Write(uint(b.InitialPresentationDelayMinusOne), 4)
+	bw.Flush()
+	if err = bw.Error(); err != nil {
+		return
+	}
+	_, err = w.Write(b.ConfigOBUs)
+	return
+}
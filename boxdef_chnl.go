@@ -0,0 +1,172 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 12.2.4 Channel layout
+
+// Box Type: 'chnl'
+// Container: Audio sample entry
+
+// The ChannelLayoutBox describes the spatial layout of the channels of an
+// audio stream, either as one of the channel configurations of ISO/IEC 23091-3
+// (CICP) or as a list of speaker positions, and the number of audio objects.
+type ChannelLayoutBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// ChannelStructured, ObjectStructured or both.
+	StreamStructure uint8
+
+	// the ISO/IEC 23091-3 ChannelConfiguration of the channels, 0 if the
+	// layout is given by SpeakerPositions.
+	DefinedLayout uint8
+
+	// the channels of the defined layout that are not present, one bit per
+	// channel in the order of the layout, starting at the least significant
+	// bit.
+	OmittedChannelsMap uint64
+
+	// the positions of the channels if DefinedLayout is 0.
+	SpeakerPositions []SpeakerPosition
+
+	// the number of audio objects of object structured streams.
+	ObjectCount uint8
+}
+
+// SpeakerPosition is the ISO/IEC 23091-3 OutputChannelPosition of a channel,
+// or the explicit direction of the speaker if the position is
+// SpeakerPositionExplicit.
+type SpeakerPosition struct {
+	Position  uint8
+	Azimuth   int16
+	Elevation int8
+}
+
+// Values of the stream_structure field.
+const (
+	ChannelStructured uint8 = 1
+	ObjectStructured  uint8 = 2
+)
+
+// SpeakerPositionExplicit signals a speaker position given by its azimuth and
+// elevation.
+const SpeakerPositionExplicit uint8 = 126
+
+var _ mp4.Box = (*ChannelLayoutBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[ChnlBoxType] = func() mp4.Box { return &ChannelLayoutBox{} }
+}
+
+func (b ChannelLayoutBox) Mp4BoxType() mp4.BoxType {
+	return ChnlBoxType
+}
+
+func (b *ChannelLayoutBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += 1 // unsigned int(8) stream_structure;
+	if b.StreamStructure&ChannelStructured != 0 {
+		b.Size += 1 // unsigned int(8) definedLayout;
+		if b.DefinedLayout == 0 {
+			for _, speaker := range b.SpeakerPositions {
+				b.Size += 1 // unsigned int(8) speaker_position;
+				if speaker.Position == SpeakerPositionExplicit {
+					b.Size += 2 // signed int(16) azimuth;
+					b.Size += 1 // signed int(8) elevation;
+				}
+			}
+		} else {
+			b.Size += 8 // unsigned int(64) omittedChannelsMap;
+		}
+	}
+	if b.StreamStructure&ObjectStructured != 0 {
+		b.Size += 1 // unsigned int(8) object_count;
+	}
+	return b.Size
+}
+
+func (b *ChannelLayoutBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	payload := make([]byte, b.Size-b.HeaderSize()-4)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	pr := bytes.NewReader(payload)
+	if err = binary.Read(pr, binary.BigEndian, &b.StreamStructure); err != nil {
+		return
+	}
+	if b.StreamStructure&ChannelStructured != 0 {
+		if err = binary.Read(pr, binary.BigEndian, &b.DefinedLayout); err != nil {
+			return
+		}
+		if b.DefinedLayout == 0 {
+			// the channel count is that of the sample entry, so the speaker
+			// positions take the payload up to the object count
+			end := 0
+			if b.StreamStructure&ObjectStructured != 0 {
+				end = 1
+			}
+			for pr.Len() > end {
+				var speaker SpeakerPosition
+				if speaker.Position, err = pr.ReadByte(); err != nil {
+					return
+				}
+				if speaker.Position == SpeakerPositionExplicit {
+					if err = binary.Read(pr, binary.BigEndian, &speaker.Azimuth); err != nil {
+						return
+					}
+					if err = binary.Read(pr, binary.BigEndian, &speaker.Elevation); err != nil {
+						return
+					}
+				}
+				b.SpeakerPositions = append(b.SpeakerPositions, speaker)
+			}
+		} else if err = binary.Read(pr, binary.BigEndian, &b.OmittedChannelsMap); err != nil {
+			return
+		}
+	}
+	if b.StreamStructure&ObjectStructured != 0 {
+		if err = binary.Read(pr, binary.BigEndian, &b.ObjectCount); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *ChannelLayoutBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	fields := []interface{}{b.StreamStructure}
+	if b.StreamStructure&ChannelStructured != 0 {
+		fields = append(fields, b.DefinedLayout)
+		if b.DefinedLayout == 0 {
+			for _, speaker := range b.SpeakerPositions {
+				fields = append(fields, speaker.Position)
+				if speaker.Position == SpeakerPositionExplicit {
+					fields = append(fields, speaker.Azimuth, speaker.Elevation)
+				}
+			}
+		} else {
+			fields = append(fields, b.OmittedChannelsMap)
+		}
+	}
+	if b.StreamStructure&ObjectStructured != 0 {
+		fields = append(fields, b.ObjectCount)
+	}
+	for _, field := range fields {
+		if err = binary.Write(w, binary.BigEndian, field); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package smoothstreaming
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannelLayoutBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		box      ChannelLayoutBox
+		wantSize uint32
+	}{
+		{
+			name:     "defined layout",
+			box:      ChannelLayoutBox{StreamStructure: ChannelStructured, DefinedLayout: 6, OmittedChannelsMap: 0x8},
+			wantSize: 22,
+		},
+		{
+			name: "speaker positions",
+			box: ChannelLayoutBox{
+				StreamStructure: ChannelStructured,
+				SpeakerPositions: []SpeakerPosition{
+					{Position: 0},
+					{Position: 1},
+					{Position: SpeakerPositionExplicit, Azimuth: -110, Elevation: 35},
+				},
+			},
+			wantSize: 20,
+		},
+		{
+			name: "speaker positions and objects",
+			box: ChannelLayoutBox{
+				StreamStructure:  ChannelStructured | ObjectStructured,
+				SpeakerPositions: []SpeakerPosition{{Position: 0}, {Position: 1}},
+				ObjectCount:      4,
+			},
+			wantSize: 17,
+		},
+		{
+			name:     "objects only",
+			box:      ChannelLayoutBox{StreamStructure: ObjectStructured, ObjectCount: 16},
+			wantSize: 14,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := tt.box
+			read, ok := roundTripBox(t, &box).(*ChannelLayoutBox)
+			if !ok {
+				t.Fatalf("read %T, want *ChannelLayoutBox", read)
+			}
+			if read.Size != tt.wantSize {
+				t.Errorf("size %d, want %d", read.Size, tt.wantSize)
+			}
+			if read.StreamStructure != tt.box.StreamStructure || read.DefinedLayout != tt.box.DefinedLayout ||
+				read.OmittedChannelsMap != tt.box.OmittedChannelsMap || read.ObjectCount != tt.box.ObjectCount {
+				t.Errorf("read %+v, want %+v", *read, tt.box)
+			}
+			if !reflect.DeepEqual(read.SpeakerPositions, tt.box.SpeakerPositions) {
+				t.Errorf("speaker positions %+v, want %+v", read.SpeakerPositions, tt.box.SpeakerPositions)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 12.1.6 Content light level
+
+// Box Type: 'clli'
+// Container: Visual Sample Entry
+
+// The ContentLightLevelBox carries the maximum light levels of the content of
+// an HDR video stream.
+type ContentLightLevelBox struct {
+	mp4.Header
+	mp4.NullContainer
+	ContentLightLevel
+}
+
+var _ mp4.Box = (*ContentLightLevelBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[ClliBoxType] = func() mp4.Box { return &ContentLightLevelBox{} }
+}
+
+func (b ContentLightLevelBox) Mp4BoxType() mp4.BoxType {
+	return ClliBoxType
+}
+
+func (b *ContentLightLevelBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += 2 // unsigned int(16) max_content_light_level;
+	b.Size += 2 // unsigned int(16) max_pic_average_light_level;
+	return b.Size
+}
+
+func (b *ContentLightLevelBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.MaxContentLightLevel); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.MaxPicAverageLightLevel); err != nil {
+		return
+	}
+	return
+}
+
+func (b *ContentLightLevelBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.MaxContentLightLevel); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.MaxPicAverageLightLevel); err != nil {
+		return
+	}
+	return
+}
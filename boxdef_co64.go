@@ -0,0 +1,70 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.7.5 Chunk Offset Box
+
+// Box Type: 'co64'
+// Container: Sample Table Box ('stbl')
+
+// The ChunkLargeOffsetBox is the 64-bit variant of the stco box, for files
+// whose chunks lie beyond 4 GiB.
+type ChunkLargeOffsetBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the offset of each chunk from the start of the file.
+	ChunkOffsets []uint64
+}
+
+var _ mp4.Box = (*ChunkLargeOffsetBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[Co64BoxType] = func() mp4.Box { return &ChunkLargeOffsetBox{} }
+}
+
+func (b ChunkLargeOffsetBox) Mp4BoxType() mp4.BoxType {
+	return Co64BoxType
+}
+
+func (b *ChunkLargeOffsetBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += 4                               // unsigned int(32) entry_count;
+	b.Size += 8 * uint32(len(b.ChunkOffsets)) // unsigned int(64) chunk_offset[entry_count];
+	return b.Size
+}
+
+func (b *ChunkLargeOffsetBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	var entryCount uint32
+	if err = binary.Read(r, binary.BigEndian, &entryCount); err != nil {
+		return
+	}
+	if uint64(entryCount)*8 > uint64(b.Size) {
+		err = fmt.Errorf("%d chunk offsets exceed the co64 box: %w", entryCount, mp4.ErrInvalidFormat)
+		return
+	}
+	b.ChunkOffsets = make([]uint64, entryCount)
+	err = binary.Read(r, binary.BigEndian, b.ChunkOffsets)
+	return
+}
+
+func (b *ChunkLargeOffsetBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, uint32(len(b.ChunkOffsets))); err != nil {
+		return
+	}
+	err = binary.Write(w, binary.BigEndian, b.ChunkOffsets)
+	return
+}
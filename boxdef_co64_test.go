@@ -0,0 +1,28 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestChunkLargeOffsetBoxRoundTrip(t *testing.T) {
+	box := &ChunkLargeOffsetBox{ChunkOffsets: []uint64{0x20, 0x1_0000_0020}}
+	read, ok := roundTripBox(t, box).(*ChunkLargeOffsetBox)
+	if !ok {
+		t.Fatalf("read %T, want *ChunkLargeOffsetBox", read)
+	}
+	if read.Size != 32 || !reflect.DeepEqual(read.ChunkOffsets, box.ChunkOffsets) {
+		t.Errorf("read size %d with offsets %v, want 32 with %v", read.Size, read.ChunkOffsets, box.ChunkOffsets)
+	}
+}
+
+func TestChunkLargeOffsetBoxTooManyEntries(t *testing.T) {
+	data := []byte{0, 0, 0, 16, 'c', 'o', '6', '4', 0, 0, 0, 0, 0, 0, 0x10, 0}
+	if _, err := mp4.ReadBox(bytes.NewReader(data)); !errors.Is(err, mp4.ErrInvalidFormat) {
+		t.Errorf("ReadBox() error = %v, want %v", err, mp4.ErrInvalidFormat)
+	}
+}
@@ -0,0 +1,96 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/go-webdl/bits"
+	"github.com/go-webdl/mp4"
+)
+
+// ETSI TS 102 366 F.4 AC3SpecificBox
+
+// Box Type: 'dac3'
+// Container: AC3SampleEntry ('ac-3')
+
+// The AC3SpecificBox carries the fields of the AC-3 bit stream information
+// that describe the elementary stream.
+type AC3SpecificBox struct {
+	mp4.Header
+	mp4.NullContainer
+
+	// sample rate code, 0 for 48 kHz, 1 for 44.1 kHz and 2 for 32 kHz.
+	Fscod uint8
+
+	Bsid  uint8
+	Bsmod uint8
+
+	// audio coding mode, the arrangement of the full bandwidth channels.
+	Acmod uint8
+
+	// set if the low frequency effects channel is present.
+	Lfeon bool
+
+	// index into the nominal bit rate table of ETSI TS 102 366 Table F.4.1.
+	BitRateCode uint8
+}
+
+var _ mp4.Box = (*AC3SpecificBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[Dac3BoxType] = func() mp4.Box { return &AC3SpecificBox{} }
+}
+
+func (b AC3SpecificBox) Mp4BoxType() mp4.BoxType {
+	return Dac3BoxType
+}
+
+func (b *AC3SpecificBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += 3 // bit(2) fscod; bit(5) bsid; bit(3) bsmod; bit(3) acmod; bit(1) lfeon; bit(5) bit_rate_code; bit(5) reserved = 0;
+	return b.Size
+}
+
+func (b *AC3SpecificBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	data := make([]byte, b.Size-b.HeaderSize())
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	br := bits.NewAccErrReader(bytes.NewReader(data))
+	b.Fscod = uint8(br.Read(2))
+	b.Bsid = uint8(br.Read(5))
+	b.Bsmod = uint8(br.Read(3))
+	b.Acmod = uint8(br.Read(3))
+	b.Lfeon = br.ReadFlag()
+	b.BitRateCode = uint8(br.Read(5))
+	err = br.AccError()
+	return
+}
+
+func (b *AC3SpecificBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	bw := bits.NewWriter(w)
+	bw.Write(uint(b.Fscod), 2)
+	bw.Write(uint(b.Bsid), 5)
+	bw.Write(uint(b.Bsmod), 3)
+	bw.Write(uint(b.Acmod), 3)
+	bw.Write(boolBit(b.Lfeon), 1)
+	bw.Write(uint(b.BitRateCode), 5)
+	bw.Write(0, 5)
+	bw.Flush()
+	err = bw.Error()
+	return
+}
+
+func boolBit(v bool) uint {
+	if v {
+		return 1
+	}
+	return 0
+}
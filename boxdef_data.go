@@ -0,0 +1,96 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+	"golang.org/x/text/language"
+)
+
+// iTunes metadata value
+
+// Box Type: 'data'
+// Container: metadata item of the Metadata Item List Box ('ilst')
+
+// The MetadataDataBox holds the value of a metadata item and its type, e.g.
+// MetadataTypeUTF8 for text, optionally for a specific language.
+type MetadataDataBox struct {
+	mp4.Header
+	mp4.NullContainer
+
+	// the well-known type of the value.
+	DataType uint32
+
+	// the language of the value, the zero Base if the value applies to all
+	// languages.
+	Language language.Base
+
+	Value []byte
+}
+
+// Well-known types of metadata values.
+const (
+	MetadataTypeUTF8 uint32 = 1
+)
+
+var _ mp4.Box = (*MetadataDataBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[DataBoxType] = func() mp4.Box { return &MetadataDataBox{} }
+}
+
+func (b MetadataDataBox) Mp4BoxType() mp4.BoxType {
+	return DataBoxType
+}
+
+func (b *MetadataDataBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += 4                    // unsigned int(8) reserved = 0; unsigned int(24) type;
+	b.Size += 2                    // unsigned int(16) country = 0;
+	b.Size += 2                    // unsigned int(16) language;
+	b.Size += uint32(len(b.Value)) // unsigned int(8) value[];
+	return b.Size
+}
+
+func (b *MetadataDataBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	var fields struct {
+		DataType uint32
+		Country  uint16
+		Language uint16
+	}
+	if err = binary.Read(r, binary.BigEndian, &fields); err != nil {
+		return
+	}
+	b.DataType = fields.DataType & 0xffffff
+	// values below 0x400 are Macintosh language codes
+	if fields.Language >= 0x400 {
+		b.Language = unpackLanguage(fields.Language)
+	}
+	b.Value = make([]byte, b.Size-b.HeaderSize()-8)
+	_, err = io.ReadFull(r, b.Value)
+	return
+}
+
+func (b *MetadataDataBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	var lang uint16
+	if b.Language != (language.Base{}) {
+		lang = packLanguage(b.Language)
+	}
+	if err = binary.Write(w, binary.BigEndian, struct {
+		DataType uint32
+		Country  uint16
+		Language uint16
+	}{b.DataType & 0xffffff, 0, lang}); err != nil {
+		return
+	}
+	_, err = w.Write(b.Value)
+	return
+}
@@ -0,0 +1,129 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/go-webdl/bits"
+	"github.com/go-webdl/mp4"
+)
+
+// ETSI TS 102 114 E.2.2 DTSSpecificBox
+
+// Box Type: 'ddts'
+// Container: DTSSampleEntry ('dtsc', 'dtsh', 'dtsl' or 'dtse')
+
+// The DTSSpecificBox describes the configuration of a DTS elementary stream.
+type DTSSpecificBox struct {
+	mp4.Header
+	mp4.NullContainer
+
+	DTSSamplingFrequency uint32
+	MaxBitrate           uint32
+	AvgBitrate           uint32
+
+	// the bit depth of the decoded PCM samples, 16 or 24.
+	PCMSampleDepth uint8
+
+	// the number of samples of each frame at DTSSamplingFrequency, 512 <<
+	// FrameDuration.
+	FrameDuration uint8
+
+	StreamConstruction uint8
+	CoreLFEPresent     bool
+
+	// the audio channel arrangement of the core substream.
+	CoreLayout uint8
+
+	// the size of the core substream of each access unit in bytes.
+	CoreSize uint16
+
+	StereoDownmix      bool
+	RepresentationType uint8
+
+	// the channel mask of ETSI TS 102 114 Table C-11 of the decoded stream.
+	ChannelLayout uint16
+
+	MultiAssetFlag     bool
+	LBRDurationMod     bool
+	ReservedBoxPresent bool
+}
+
+var _ mp4.Box = (*DTSSpecificBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[DdtsBoxType] = func() mp4.Box { return &DTSSpecificBox{} }
+	mp4.BoxRegistry[DtscBoxType] = func() mp4.Box { return &AudioSampleEntryBox{} }
+	mp4.BoxRegistry[DtshBoxType] = func() mp4.Box { return &AudioSampleEntryBox{} }
+	mp4.BoxRegistry[DtslBoxType] = func() mp4.Box { return &AudioSampleEntryBox{} }
+	mp4.BoxRegistry[DtseBoxType] = func() mp4.Box { return &AudioSampleEntryBox{} }
+}
+
+func (b DTSSpecificBox) Mp4BoxType() mp4.BoxType {
+	return DdtsBoxType
+}
+
+func (b *DTSSpecificBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += 4 // unsigned int(32) DTSSamplingFrequency;
+	b.Size += 4 // unsigned int(32) maxBitrate;
+	b.Size += 4 // unsigned int(32) avgBitrate;
+	b.Size += 1 // unsigned int(8) pcmSampleDepth;
+	b.Size += 7 // bit(2) FrameDuration; bit(5) StreamConstruction; bit(1) CoreLFEPresent; bit(6) CoreLayout; bit(14) CoreSize; bit(1) StereoDownmix; bit(3) RepresentationType; bit(16) ChannelLayout; bit(1) MultiAssetFlag; bit(1) LBRDurationMod; bit(1) ReservedBoxPresent; bit(5) Reserved;
+	return b.Size
+}
+
+func (b *DTSSpecificBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	data := make([]byte, b.Size-b.HeaderSize())
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	br := bits.NewAccErrReader(bytes.NewReader(data))
+	b.DTSSamplingFrequency = uint32(br.Read(32))
+	b.MaxBitrate = uint32(br.Read(32))
+	b.AvgBitrate = uint32(br.Read(32))
+	b.PCMSampleDepth = uint8(br.Read(8))
+	b.FrameDuration = uint8(br.Read(2))
+	b.StreamConstruction = uint8(br.Read(5))
+	b.CoreLFEPresent = br.ReadFlag()
+	b.CoreLayout = uint8(br.Read(6))
+	b.CoreSize = uint16(br.Read(14))
+	b.StereoDownmix = br.ReadFlag()
+	b.RepresentationType = uint8(br.Read(3))
+	b.ChannelLayout = uint16(br.Read(16))
+	b.MultiAssetFlag = br.ReadFlag()
+	b.LBRDurationMod = br.ReadFlag()
+	b.ReservedBoxPresent = br.ReadFlag()
+	err = br.AccError()
+	return
+}
+
+func (b *DTSSpecificBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	bw := bits.NewWriter(w)
+	bw.Write(uint(b.DTSSamplingFrequency), 32)
+	bw.Write(uint(b.MaxBitrate), 32)
+	bw.Write(uint(b.AvgBitrate), 32)
+	bw.Write(uint(b.PCMSampleDepth), 8)
+	bw.Write(uint(b.FrameDuration), 2)
+	bw.Write(uint(b.StreamConstruction), 5)
+	bw.Write(boolBit(b.CoreLFEPresent), 1)
+	bw.Write(uint(b.CoreLayout), 6)
+	bw.Write(uint(b.CoreSize), 14)
+	bw.Write(boolBit(b.StereoDownmix), 1)
+	bw.Write(uint(b.RepresentationType), 3)
+	bw.Write(uint(b.ChannelLayout), 16)
+	bw.Write(boolBit(b.MultiAssetFlag), 1)
+	bw.Write(boolBit(b.LBRDurationMod), 1)
+	bw.Write(boolBit(b.ReservedBoxPresent), 1)
+	bw.Write(0, 5)
+	bw.Flush()
+	err = bw.Error()
+	return
+}
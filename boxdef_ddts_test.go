@@ -0,0 +1,59 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDTSSpecificBoxRoundTrip(t *testing.T) {
+	box := DTSSpecificBox{
+		DTSSamplingFrequency: 48000,
+		MaxBitrate:           1509000,
+		AvgBitrate:           1509000,
+		PCMSampleDepth:       24,
+		FrameDuration:        1,
+		StreamConstruction:   17,
+		CoreLFEPresent:       true,
+		CoreLayout:           9,
+		CoreSize:             2012,
+		StereoDownmix:        true,
+		RepresentationType:   5,
+		ChannelLayout:        0x004f,
+		MultiAssetFlag:       true,
+		LBRDurationMod:       true,
+		ReservedBoxPresent:   true,
+	}
+	want := box
+	read, ok := roundTripBox(t, &box).(*DTSSpecificBox)
+	if !ok {
+		t.Fatalf("read %T, want *DTSSpecificBox", read)
+	}
+	if read.Size != 28 {
+		t.Errorf("size %d, want 28", read.Size)
+	}
+	want.Header = read.Header
+	if *read != want {
+		t.Errorf("read %+v, want %+v", *read, want)
+	}
+}
+
+func TestDTSSpecificBoxPayload(t *testing.T) {
+	box := &DTSSpecificBox{
+		DTSSamplingFrequency: 48000,
+		MaxBitrate:           768000,
+		AvgBitrate:           768000,
+		PCMSampleDepth:       16,
+		CoreLFEPresent:       true,
+		CoreLayout:           9,
+		ChannelLayout:        0x000f,
+	}
+	box.Mp4BoxUpdate()
+	var buf bytes.Buffer
+	if err := box.Mp4BoxWrite(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := decodeHex(t, "0000001c64647473"+"0000bb80"+"000bb800"+"000bb800"+"10"+"01240000000f00")
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("ddts = %x, want %x", buf.Bytes(), want)
+	}
+}
@@ -0,0 +1,152 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/go-webdl/bits"
+	"github.com/go-webdl/mp4"
+)
+
+// ETSI TS 102 366 F.6 EC3SpecificBox
+
+// Box Type: 'dec3'
+// Container: EC3SampleEntry ('ec-3')
+
+// The EC3SpecificBox carries the fields of the E-AC-3 bit stream information
+// that describe each independent substream of the elementary stream.
+type EC3SpecificBox struct {
+	mp4.Header
+	mp4.NullContainer
+
+	// the data rate of the stream in kbit/s.
+	DataRate uint16
+
+	IndependentSubstreams []EC3IndependentSubstream
+
+	// trailing fields defined by later revisions of the specification, e.g.
+	// the Dolby Atmos signaling of flag_ec3_extension_type_a, kept verbatim.
+	Extension []byte
+}
+
+// EC3IndependentSubstream describes an independent substream of an E-AC-3
+// stream and its dependent substreams.
+type EC3IndependentSubstream struct {
+	Fscod uint8
+	Bsid  uint8
+	Asvc  bool
+	Bsmod uint8
+	Acmod uint8
+	Lfeon bool
+
+	// the number of dependent substreams associated with the substream.
+	NumDepSub uint8
+
+	// the channel locations of the dependent substreams, ETSI TS 102 366
+	// Table F.6.1. Only meaningful if NumDepSub is not 0.
+	ChanLoc uint16
+}
+
+var _ mp4.Box = (*EC3SpecificBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[Dec3BoxType] = func() mp4.Box { return &EC3SpecificBox{} }
+}
+
+func (b EC3SpecificBox) Mp4BoxType() mp4.BoxType {
+	return Dec3BoxType
+}
+
+func (b *EC3SpecificBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += 2 // unsigned int(13) data_rate; unsigned int(3) num_ind_sub;
+	for _, s := range b.IndependentSubstreams {
+		b.Size += s.size()
+	}
+	b.Size += uint32(len(b.Extension))
+	return b.Size
+}
+
+func (s EC3IndependentSubstream) size() uint32 {
+	// unsigned int(2) fscod; unsigned int(5) bsid; unsigned int(1) reserved = 0; unsigned int(1) asvc;
+	// unsigned int(3) bsmod; unsigned int(3) acmod; unsigned int(1) lfeon; unsigned int(3) reserved = 0;
+	// unsigned int(4) num_dep_sub;
+	if s.NumDepSub > 0 {
+		return 4 // unsigned int(9) chan_loc;
+	}
+	return 3 // unsigned int(1) reserved = 0;
+}
+
+func (b *EC3SpecificBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	data := make([]byte, b.Size-b.HeaderSize())
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	br := bits.NewAccErrReader(bytes.NewReader(data))
+	b.DataRate = uint16(br.Read(13))
+	numIndSub := int(br.Read(3)) + 1
+	b.IndependentSubstreams = make([]EC3IndependentSubstream, numIndSub)
+	for i := range b.IndependentSubstreams {
+		s := &b.IndependentSubstreams[i]
+		s.Fscod = uint8(br.Read(2))
+		s.Bsid = uint8(br.Read(5))
+		br.Read(1) // reserved
+		s.Asvc = br.ReadFlag()
+		s.Bsmod = uint8(br.Read(3))
+		s.Acmod = uint8(br.Read(3))
+		s.Lfeon = br.ReadFlag()
+		br.Read(3) // reserved
+		s.NumDepSub = uint8(br.Read(4))
+		if s.NumDepSub > 0 {
+			s.ChanLoc = uint16(br.Read(9))
+		} else {
+			br.Read(1) // reserved
+		}
+	}
+	if err = br.AccError(); err != nil {
+		return
+	}
+	n := uint32(2)
+	for _, s := range b.IndependentSubstreams {
+		n += s.size()
+	}
+	if uint32(len(data)) > n {
+		b.Extension = data[n:]
+	}
+	return
+}
+
+func (b *EC3SpecificBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	bw := bits.NewWriter(w)
+	bw.Write(uint(b.DataRate), 13)
+	bw.Write(uint(len(b.IndependentSubstreams)-1), 3)
+	for _, s := range b.IndependentSubstreams {
+		bw.Write(uint(s.Fscod), 2)
+		bw.Write(uint(s.Bsid), 5)
+		bw.Write(0, 1)
+		bw.Write(boolBit(s.Asvc), 1)
+		bw.Write(uint(s.Bsmod), 3)
+		bw.Write(uint(s.Acmod), 3)
+		bw.Write(boolBit(s.Lfeon), 1)
+		bw.Write(0, 3)
+		bw.Write(uint(s.NumDepSub), 4)
+		if s.NumDepSub > 0 {
+			bw.Write(uint(s.ChanLoc), 9)
+		} else {
+			bw.Write(0, 1)
+		}
+	}
+	bw.Flush()
+	if err = bw.Error(); err != nil {
+		return
+	}
+	_, err = w.Write(b.Extension)
+	return
+}
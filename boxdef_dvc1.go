@@ -0,0 +1,123 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/bits"
+	"github.com/go-webdl/mp4"
+)
+
+// SMPTE RP 2025 VC-1 Specific Box
+
+// Box Type: 'dvc1'
+// Container: VC1SampleEntry ('vc-1')
+
+// The VC1SpecificBox carries the decoder configuration of an advanced profile
+// VC-1 stream: a summary of the stream properties followed by the sequence
+// header and entry point header.
+type VC1SpecificBox struct {
+	mp4.Header
+	mp4.NullContainer
+
+	Profile uint8
+	Level   uint8
+
+	// set if the stream is coded at a constant bit rate.
+	CBR bool
+
+	NoInterlace        bool
+	NoMultipleSequence bool
+	NoMultipleEntry    bool
+	NoSliceCode        bool
+	NoBFrame           bool
+
+	// frames per second rounded down, 0xffffffff if unknown or variable.
+	Framerate uint32
+
+	// the sequence header and entry point header bitstream data units,
+	// including their start codes.
+	SequenceHeader []byte
+}
+
+var _ mp4.Box = (*VC1SpecificBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[Dvc1BoxType] = func() mp4.Box { return &VC1SpecificBox{} }
+	mp4.BoxRegistry[Vc1BoxType] = func() mp4.Box { return &mp4.VisualSampleEntryBox{} }
+}
+
+func (b VC1SpecificBox) Mp4BoxType() mp4.BoxType {
+	return Dvc1BoxType
+}
+
+func (b *VC1SpecificBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += 1 // unsigned int(4) profile; unsigned int(3) level; unsigned int(1) reserved = 0;
+	b.Size += 2 // unsigned int(3) level; unsigned int(1) cbr; unsigned int(6) reserved = 0; unsigned int(1) no_interlace; unsigned int(1) no_multiple_seq; unsigned int(1) no_multiple_entry; unsigned int(1) no_slice_code; unsigned int(1) no_bframe; unsigned int(1) reserved = 0;
+	b.Size += 4 // unsigned int(32) framerate;
+	b.Size += uint32(len(b.SequenceHeader))
+	return b.Size
+}
+
+func (b *VC1SpecificBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	data := make([]byte, b.Size-b.HeaderSize())
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	if len(data) < 7 {
+		err = io.ErrUnexpectedEOF
+		return
+	}
+	br := bits.NewAccErrReader(bytes.NewReader(data[:3]))
+	b.Profile = uint8(br.Read(4))
+	b.Level = uint8(br.Read(3))
+	br.Read(1) // reserved
+	br.Read(3) // level
+	b.CBR = br.ReadFlag()
+	br.Read(6) // reserved
+	b.NoInterlace = br.ReadFlag()
+	b.NoMultipleSequence = br.ReadFlag()
+	b.NoMultipleEntry = br.ReadFlag()
+	b.NoSliceCode = br.ReadFlag()
+	b.NoBFrame = br.ReadFlag()
+	if err = br.AccError(); err != nil {
+		return
+	}
+	b.Framerate = binary.BigEndian.Uint32(data[3:7])
+	b.SequenceHeader = data[7:]
+	return
+}
+
+func (b *VC1SpecificBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	bw := bits.NewWriter(w)
+	bw.Write(uint(b.Profile), 4)
+	bw.Write(uint(b.Level), 3)
+	bw.Write(0, 1)
+	bw.Write(uint(b.Level), 3)
+	bw.Write(boolBit(b.CBR), 1)
+	bw.Write(0, 6)
+	bw.Write(boolBit(b.NoInterlace), 1)
+	bw.Write(boolBit(b.NoMultipleSequence), 1)
+	bw.Write(boolBit(b.NoMultipleEntry), 1)
+	bw.Write(boolBit(b.NoSliceCode), 1)
+	bw.Write(boolBit(b.NoBFrame), 1)
+	bw.Write(0, 1)
+	bw.Flush()
+	if err = bw.Error(); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.Framerate); err != nil {
+		return
+	}
+	_, err = w.Write(b.SequenceHeader)
+	return
+}
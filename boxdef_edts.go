@@ -0,0 +1,56 @@
+package smoothstreaming
+
+import (
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.6.5 Edit Box
+
+// Box Type: 'edts'
+// Container: Track Box ('trak')
+
+// An Edit Box maps the presentation time‐line to the media time‐line as it is
+// stored in the file. The Edit Box is a container for the edit lists.
+type EditBox struct {
+	mp4.Header
+	mp4.Container
+}
+
+var _ mp4.Box = (*EditBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[EdtsBoxType] = func() mp4.Box { return &EditBox{} }
+}
+
+func (b EditBox) Mp4BoxType() mp4.BoxType {
+	return EdtsBoxType
+}
+
+func (b *EditBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *EditBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = b.Mp4BoxReadChildren(r, b.Size-b.HeaderSize()); err != nil {
+		return
+	}
+	return
+}
+
+func (b *EditBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
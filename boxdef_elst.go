@@ -0,0 +1,143 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.6.6 Edit List Box
+
+// Box Type: 'elst'
+// Container: Edit Box ('edts')
+
+// This box contains an explicit timeline map. Each entry defines part of the
+// track time‐line: by mapping part of the media time‐line, or by indicating
+// 'empty' time, or by defining a 'dwell', where a single time‐point in the
+// media is held for a period.
+type EditListBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+	Entries []EditListEntry
+}
+
+type EditListEntry struct {
+	// specifies the duration of this edit in units of the timescale in the
+	// Movie Header Box.
+	SegmentDuration uint64
+
+	// the starting time within the media of this edit segment, in media time
+	// scale units. If this field is set to –1, it is an empty edit.
+	MediaTime int64
+
+	// the relative rate at which to play the media corresponding to this edit
+	// segment, as a 16.16 fixed point number.
+	MediaRateInteger  int16
+	MediaRateFraction int16
+}
+
+var _ mp4.Box = (*EditListBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[ElstBoxType] = func() mp4.Box { return &EditListBox{} }
+}
+
+func (b EditListBox) Mp4BoxType() mp4.BoxType {
+	return ElstBoxType
+}
+
+func (b *EditListBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += 4 // unsigned int(32) entry_count;
+	// for (i=1; i <= entry_count; i++) {
+	//     if (version==1) {
+	//         unsigned int(64) segment_duration;
+	//         int(64) media_time;
+	//     } else { // version==0
+	//         unsigned int(32) segment_duration;
+	//         int(32) media_time;
+	//     }
+	//     int(16) media_rate_integer;
+	//     int(16) media_rate_fraction = 0;
+	// }
+	if b.Version == 1 {
+		b.Size += 20 * uint32(len(b.Entries))
+	} else {
+		b.Size += 12 * uint32(len(b.Entries))
+	}
+	return b.Size
+}
+
+func (b *EditListBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	var entryCount uint32
+	if err = binary.Read(r, binary.BigEndian, &entryCount); err != nil {
+		return
+	}
+	b.Entries = make([]EditListEntry, entryCount)
+	for i := range b.Entries {
+		e := &b.Entries[i]
+		if b.Version == 1 {
+			if err = binary.Read(r, binary.BigEndian, &e.SegmentDuration); err != nil {
+				return
+			}
+			if err = binary.Read(r, binary.BigEndian, &e.MediaTime); err != nil {
+				return
+			}
+		} else {
+			var entry struct {
+				SegmentDuration uint32
+				MediaTime       int32
+			}
+			if err = binary.Read(r, binary.BigEndian, &entry); err != nil {
+				return
+			}
+			e.SegmentDuration = uint64(entry.SegmentDuration)
+			e.MediaTime = int64(entry.MediaTime)
+		}
+		if err = binary.Read(r, binary.BigEndian, &e.MediaRateInteger); err != nil {
+			return
+		}
+		if err = binary.Read(r, binary.BigEndian, &e.MediaRateFraction); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *EditListBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, uint32(len(b.Entries))); err != nil {
+		return
+	}
+	for _, e := range b.Entries {
+		if b.Version == 1 {
+			if err = binary.Write(w, binary.BigEndian, e.SegmentDuration); err != nil {
+				return
+			}
+			if err = binary.Write(w, binary.BigEndian, e.MediaTime); err != nil {
+				return
+			}
+		} else {
+			if err = binary.Write(w, binary.BigEndian, uint32(e.SegmentDuration)); err != nil {
+				return
+			}
+			if err = binary.Write(w, binary.BigEndian, int32(e.MediaTime)); err != nil {
+				return
+			}
+		}
+		if err = binary.Write(w, binary.BigEndian, e.MediaRateInteger); err != nil {
+			return
+		}
+		if err = binary.Write(w, binary.BigEndian, e.MediaRateFraction); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,174 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// ISO/IEC 23009-1 5.10.3.3 Event Message Box
+
+// Box Type: 'emsg'
+// Container: File
+
+// The Event Message Box carries an event of a DASH media segment, in-band
+// signaling such as ad insertion markers, placed before the first moof box of
+// the segment. Version 0 locates the event relative to the start of the
+// segment, version 1 on the media timeline with an absolute time.
+type EventMessageBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// identifies the message scheme, and with Value and ID the event.
+	SchemeIDURI mp4.NullTerminatedString
+
+	// the value of the event, whose semantics are defined by the scheme.
+	Value mp4.NullTerminatedString
+
+	// the timescale of PresentationTime and EventDuration, in ticks per
+	// second.
+	Timescale uint32
+
+	// the presentation time of the event on the media timeline for version 1,
+	// or the presentation_time_delta of the event from the earliest
+	// presentation time of the segment for version 0, which is 32 bits.
+	PresentationTime uint64
+
+	// the duration of the event, 0xffffffff if it is unknown.
+	EventDuration uint32
+
+	// identifies the instance of the message; events with the same scheme,
+	// value and ID are the same event.
+	ID uint32
+
+	// the body of the message.
+	MessageData []byte
+}
+
+var _ mp4.Box = (*EventMessageBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[EmsgBoxType] = func() mp4.Box { return &EventMessageBox{} }
+}
+
+func (b EventMessageBox) Mp4BoxType() mp4.BoxType {
+	return EmsgBoxType
+}
+
+func (b *EventMessageBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += b.SchemeIDURI.Size() // string scheme_id_uri;
+	b.Size += b.Value.Size()       // string value;
+	b.Size += 4                    // unsigned int(32) timescale;
+	if b.Version == 1 {
+		b.Size += 8 // unsigned int(64) presentation_time;
+	} else {
+		b.Size += 4 // unsigned int(32) presentation_time_delta;
+	}
+	b.Size += 4                          // unsigned int(32) event_duration;
+	b.Size += 4                          // unsigned int(32) id;
+	b.Size += uint32(len(b.MessageData)) // unsigned int(8) message_data[];
+	return b.Size
+}
+
+func (b *EventMessageBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	lr := &io.LimitedReader{R: r, N: int64(b.Size - b.HeaderSize() - 4)}
+	if b.Version == 0 {
+		if err = b.readStrings(lr); err != nil {
+			return
+		}
+	}
+	if err = binary.Read(lr, binary.BigEndian, &b.Timescale); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Read(lr, binary.BigEndian, &b.PresentationTime); err != nil {
+			return
+		}
+	} else {
+		var presentationTimeDelta uint32
+		if err = binary.Read(lr, binary.BigEndian, &presentationTimeDelta); err != nil {
+			return
+		}
+		b.PresentationTime = uint64(presentationTimeDelta)
+	}
+	if err = binary.Read(lr, binary.BigEndian, &b.EventDuration); err != nil {
+		return
+	}
+	if err = binary.Read(lr, binary.BigEndian, &b.ID); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = b.readStrings(lr); err != nil {
+			return
+		}
+	}
+	b.MessageData = make([]byte, lr.N)
+	if _, err = io.ReadFull(lr, b.MessageData); err != nil {
+		return
+	}
+	return
+}
+
+func (b *EventMessageBox) readStrings(r io.Reader) (err error) {
+	if err = readNullTerminatedString(r, &b.SchemeIDURI); err != nil {
+		return
+	}
+	if err = readNullTerminatedString(r, &b.Value); err != nil {
+		return
+	}
+	return
+}
+
+func (b *EventMessageBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if b.Version == 0 {
+		if err = b.writeStrings(w); err != nil {
+			return
+		}
+	}
+	if err = binary.Write(w, binary.BigEndian, b.Timescale); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Write(w, binary.BigEndian, b.PresentationTime); err != nil {
+			return
+		}
+	} else {
+		if err = binary.Write(w, binary.BigEndian, uint32(b.PresentationTime)); err != nil {
+			return
+		}
+	}
+	if err = binary.Write(w, binary.BigEndian, b.EventDuration); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.ID); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = b.writeStrings(w); err != nil {
+			return
+		}
+	}
+	if _, err = w.Write(b.MessageData); err != nil {
+		return
+	}
+	return
+}
+
+func (b *EventMessageBox) writeStrings(w io.Writer) (err error) {
+	if err = b.SchemeIDURI.Write(w); err != nil {
+		return
+	}
+	if err = b.Value.Write(w); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestEventMessageBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  uint8
+		time     uint64
+		wantSize uint32
+	}{
+		{name: "version 0 delta", version: 0, time: 900, wantSize: 58},
+		{name: "version 1 time", version: 1, time: 160000000000, wantSize: 62},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := &EventMessageBox{
+				SchemeIDURI:      "urn:scte:scte35:2013:bin",
+				Value:            "1",
+				Timescale:        90000,
+				PresentationTime: tt.time,
+				EventDuration:    0xffffffff,
+				ID:               7,
+				MessageData:      []byte{0xfc, 0x30, 0x11},
+			}
+			box.Version = tt.version
+			read, ok := roundTripBox(t, box).(*EventMessageBox)
+			if !ok {
+				t.Fatalf("read %T, want *EventMessageBox", read)
+			}
+			if read.Size != tt.wantSize || read.Version != tt.version || read.SchemeIDURI != box.SchemeIDURI || read.Value != box.Value ||
+				read.Timescale != 90000 || read.PresentationTime != tt.time || read.EventDuration != 0xffffffff || read.ID != 7 ||
+				!bytes.Equal(read.MessageData, box.MessageData) {
+				t.Errorf("read %+v, want %+v of %d bytes", read, box, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestEventMessageBoxPayload(t *testing.T) {
+	box := &EventMessageBox{SchemeIDURI: "a", Value: "", Timescale: 1000, PresentationTime: 2000, EventDuration: 500, ID: 1, MessageData: []byte{0xee}}
+	box.Version = 1
+	box.Mp4BoxUpdate()
+	var buf bytes.Buffer
+	if err := box.Mp4BoxWrite(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "00000024656d7367" + "01000000" + "000003e8" + "00000000000007d0" + "000001f4" + "00000001" + "6100" + "00" + "ee"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+}
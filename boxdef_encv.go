@@ -0,0 +1,11 @@
+package smoothstreaming
+
+import "github.com/go-webdl/mp4"
+
+// 8.12 Support for Protected Streams
+
+// Encrypted video tracks use the VisualSampleEntryBox of their original format
+// with the 'encv' box type, which the mp4 package does not register.
+func init() {
+	mp4.BoxRegistry[mp4.EncvBoxType] = func() mp4.Box { return &mp4.VisualSampleEntryBox{} }
+}
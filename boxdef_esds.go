@@ -0,0 +1,287 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// ISO/IEC 14496-14 5.6 Sample Description Boxes
+
+// Box Type: 'esds'
+// Container: MP4AudioSampleEntry ('mp4a')
+
+// The ES Descriptor Box carries the ES_Descriptor of an MPEG-4 elementary
+// stream, of which only the fields relevant to file format usage are exposed:
+// the DecoderConfigDescriptor with its DecoderSpecificInfo, e.g. the AAC
+// AudioSpecificConfig.
+type ESDBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the ES_ID of the ES_Descriptor, 0 in stored files as required by
+	// ISO/IEC 14496-14.
+	ESID uint16
+
+	// identifies the coding format, e.g. 0x40 for ISO/IEC 14496-3 audio.
+	ObjectTypeIndication uint8
+
+	// identifies the type of the stream, e.g. 0x05 for audio streams.
+	StreamType uint8
+
+	BufferSizeDB uint32
+	MaxBitrate   uint32
+	AvgBitrate   uint32
+
+	DecoderSpecificInfo []byte
+}
+
+// Descriptor tags of ISO/IEC 14496-1 7.2.2.1.
+const (
+	ESDescrTag              uint8 = 0x03
+	DecoderConfigDescrTag   uint8 = 0x04
+	DecSpecificInfoTag      uint8 = 0x05
+	SLConfigDescrTag        uint8 = 0x06
+	ObjectTypeAudioISO14496 uint8 = 0x40
+	StreamTypeAudio         uint8 = 0x05
+)
+
+var _ mp4.Box = (*ESDBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[EsdsBoxType] = func() mp4.Box { return &ESDBox{} }
+}
+
+func (b ESDBox) Mp4BoxType() mp4.BoxType {
+	return EsdsBoxType
+}
+
+func (b *ESDBox) decoderSpecificInfoSize() uint32 {
+	if len(b.DecoderSpecificInfo) == 0 {
+		return 0
+	}
+	return descriptorSize(uint32(len(b.DecoderSpecificInfo)))
+}
+
+func (b *ESDBox) decoderConfigPayloadSize() uint32 {
+	size := uint32(1) // bit(8) objectTypeIndication;
+	size += 1         // bit(6) streamType; bit(1) upStream; const bit(1) reserved=1;
+	size += 3         // bit(24) bufferSizeDB;
+	size += 4         // bit(32) maxBitrate;
+	size += 4         // bit(32) avgBitrate;
+	size += b.decoderSpecificInfoSize()
+	return size
+}
+
+func (b *ESDBox) esDescriptorPayloadSize() uint32 {
+	size := uint32(2)                                    // bit(16) ES_ID;
+	size += 1                                            // bit(1) streamDependenceFlag; bit(1) URL_Flag; bit(1) OCRstreamFlag; bit(5) streamPriority;
+	size += descriptorSize(b.decoderConfigPayloadSize()) // DecoderConfigDescriptor decConfigDescr;
+	size += descriptorSize(1)                            // SLConfigDescriptor slConfigDescr;
+	return size
+}
+
+func (b *ESDBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += descriptorSize(b.esDescriptorPayloadSize()) // ES_Descriptor ES;
+	return b.Size
+}
+
+func (b *ESDBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	data := make([]byte, b.Size-b.HeaderSize()-4)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	dr := bytes.NewReader(data)
+	tag, size, err := readDescriptorHeader(dr)
+	if err != nil {
+		return
+	}
+	if tag != ESDescrTag {
+		err = fmt.Errorf("esds box does not start with an ES_Descriptor: %w", mp4.ErrInvalidFormat)
+		return
+	}
+	es := io.LimitReader(dr, int64(size))
+	var flags uint8
+	if err = binary.Read(es, binary.BigEndian, &b.ESID); err != nil {
+		return
+	}
+	if err = binary.Read(es, binary.BigEndian, &flags); err != nil {
+		return
+	}
+	if flags&0x80 > 0 { // streamDependenceFlag
+		if _, err = io.CopyN(io.Discard, es, 2); err != nil {
+			return
+		}
+	}
+	if flags&0x40 > 0 { // URL_Flag
+		var urlLength uint8
+		if err = binary.Read(es, binary.BigEndian, &urlLength); err != nil {
+			return
+		}
+		if _, err = io.CopyN(io.Discard, es, int64(urlLength)); err != nil {
+			return
+		}
+	}
+	if flags&0x20 > 0 { // OCRstreamFlag
+		if _, err = io.CopyN(io.Discard, es, 2); err != nil {
+			return
+		}
+	}
+	for {
+		if tag, size, err = readDescriptorHeader(es); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			return
+		}
+		payload := make([]byte, size)
+		if _, err = io.ReadFull(es, payload); err != nil {
+			return
+		}
+		if tag == DecoderConfigDescrTag {
+			if err = b.readDecoderConfig(payload); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (b *ESDBox) readDecoderConfig(payload []byte) (err error) {
+	if len(payload) < 13 {
+		err = fmt.Errorf("DecoderConfigDescriptor too short: %w", mp4.ErrInvalidFormat)
+		return
+	}
+	b.ObjectTypeIndication = payload[0]
+	b.StreamType = payload[1] >> 2
+	b.BufferSizeDB = uint32(payload[2])<<16 | uint32(payload[3])<<8 | uint32(payload[4])
+	b.MaxBitrate = binary.BigEndian.Uint32(payload[5:9])
+	b.AvgBitrate = binary.BigEndian.Uint32(payload[9:13])
+	r := bytes.NewReader(payload[13:])
+	for {
+		var tag uint8
+		var size uint32
+		if tag, size, err = readDescriptorHeader(r); err == io.EOF {
+			err = nil
+			return
+		} else if err != nil {
+			return
+		}
+		data := make([]byte, size)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return
+		}
+		if tag == DecSpecificInfoTag {
+			b.DecoderSpecificInfo = data
+		}
+	}
+}
+
+func (b *ESDBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = writeDescriptorHeader(w, ESDescrTag, b.esDescriptorPayloadSize()); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.ESID); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, uint8(0)); err != nil {
+		return
+	}
+	if err = writeDescriptorHeader(w, DecoderConfigDescrTag, b.decoderConfigPayloadSize()); err != nil {
+		return
+	}
+	config := []byte{
+		b.ObjectTypeIndication,
+		b.StreamType<<2 | 0x01,
+		byte(b.BufferSizeDB >> 16), byte(b.BufferSizeDB >> 8), byte(b.BufferSizeDB),
+	}
+	if _, err = w.Write(config); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.MaxBitrate); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.AvgBitrate); err != nil {
+		return
+	}
+	if len(b.DecoderSpecificInfo) > 0 {
+		if err = writeDescriptorHeader(w, DecSpecificInfoTag, uint32(len(b.DecoderSpecificInfo))); err != nil {
+			return
+		}
+		if _, err = w.Write(b.DecoderSpecificInfo); err != nil {
+			return
+		}
+	}
+	if err = writeDescriptorHeader(w, SLConfigDescrTag, 1); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, uint8(2)); err != nil { // predefined = 2, reserved for use in MP4 files
+		return
+	}
+	return
+}
+
+// descriptorHeaderSize returns the size of the tag and the expandable size
+// field of a descriptor with a payload of the given size.
+func descriptorHeaderSize(payloadSize uint32) uint32 {
+	size := uint32(2)
+	for payloadSize >= 0x80 {
+		payloadSize >>= 7
+		size++
+	}
+	return size
+}
+
+// descriptorSize returns the complete size of a descriptor with a payload of
+// the given size.
+func descriptorSize(payloadSize uint32) uint32 {
+	return descriptorHeaderSize(payloadSize) + payloadSize
+}
+
+func readDescriptorHeader(r io.Reader) (tag uint8, size uint32, err error) {
+	var b [1]byte
+	if _, err = io.ReadFull(r, b[:]); err != nil {
+		return
+	}
+	tag = b[0]
+	for i := 0; i < 4; i++ {
+		if _, err = io.ReadFull(r, b[:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		size = size<<7 | uint32(b[0]&0x7f)
+		if b[0]&0x80 == 0 {
+			return
+		}
+	}
+	err = fmt.Errorf("descriptor size field exceeds 4 bytes: %w", mp4.ErrInvalidFormat)
+	return
+}
+
+func writeDescriptorHeader(w io.Writer, tag uint8, payloadSize uint32) (err error) {
+	n := descriptorHeaderSize(payloadSize) - 1
+	header := make([]byte, 1+n)
+	header[0] = tag
+	for i := n; i > 0; i-- {
+		header[i] = byte(payloadSize & 0x7f)
+		if i < n {
+			header[i] |= 0x80
+		}
+		payloadSize >>= 7
+	}
+	_, err = w.Write(header)
+	return
+}
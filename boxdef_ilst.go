@@ -0,0 +1,93 @@
+package smoothstreaming
+
+import (
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// iTunes metadata item list
+
+// Box Type: 'ilst'
+// Container: Meta Box ('meta') with handler type 'mdir'
+
+// The MetadataItemListBox contains the metadata items of a presentation or
+// track. Each item is a MetadataItemBox whose box type identifies the item,
+// e.g. '©nam' for the title, holding the value in a MetadataDataBox.
+type MetadataItemListBox struct {
+	mp4.Header
+	mp4.Container
+}
+
+var _ mp4.Box = (*MetadataItemListBox)(nil)
+
+// MetadataItemBox is an item of the metadata item list, whose type is set by
+// its header.
+type MetadataItemBox struct {
+	mp4.Header
+	mp4.Container
+}
+
+var _ mp4.Box = (*MetadataItemBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[IlstBoxType] = func() mp4.Box { return &MetadataItemListBox{} }
+	mp4.BoxRegistry[NamBoxType] = func() mp4.Box { return &MetadataItemBox{} }
+}
+
+func (b MetadataItemListBox) Mp4BoxType() mp4.BoxType {
+	return IlstBoxType
+}
+
+func (b *MetadataItemListBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *MetadataItemListBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = b.Mp4BoxReadChildren(r, b.Size-b.HeaderSize()); err != nil {
+		return
+	}
+	return
+}
+
+func (b *MetadataItemListBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
+
+func (b *MetadataItemBox) Mp4BoxUpdate() uint32 {
+	b.Size = b.HeaderSize()
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *MetadataItemBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = b.Mp4BoxReadChildren(r, b.Size-b.HeaderSize()); err != nil {
+		return
+	}
+	return
+}
+
+func (b *MetadataItemBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
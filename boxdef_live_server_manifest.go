@@ -0,0 +1,62 @@
+package smoothstreaming
+
+import (
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// Fragmented MP4 live ingest of Smooth Streaming, Live Server Manifest Box
+
+// Box Type: 'uuid' with the LiveServerManifestBoxUserType user type
+// Container: File
+
+// The LiveServerManifestBox follows the ftyp box of a stream pushed to a
+// publishing point, and describes its tracks to the server with a SMIL
+// document in the format of a server manifest, see ServerManifest.
+type LiveServerManifestBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the SMIL document, in UTF-8.
+	Manifest []byte
+}
+
+var _ mp4.Box = (*LiveServerManifestBox)(nil)
+
+func init() {
+	mp4.UUIDBoxRegistry[LiveServerManifestBoxUserType] = func() mp4.Box { return &LiveServerManifestBox{} }
+}
+
+func (b LiveServerManifestBox) Mp4BoxType() mp4.BoxType {
+	return mp4.UuidBoxType
+}
+
+func (b LiveServerManifestBox) Mp4BoxUserType() mp4.UserType {
+	return LiveServerManifestBoxUserType
+}
+
+func (b *LiveServerManifestBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.UserType = b.Mp4BoxUserType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += uint32(len(b.Manifest)) // string manifest;
+	return b.Size
+}
+
+func (b *LiveServerManifestBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	b.Manifest = make([]byte, b.Size-b.HeaderSize()-4)
+	_, err = io.ReadFull(r, b.Manifest)
+	return
+}
+
+func (b *LiveServerManifestBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	_, err = w.Write(b.Manifest)
+	return
+}
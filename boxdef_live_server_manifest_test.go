@@ -0,0 +1,17 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLiveServerManifestBoxRoundTrip(t *testing.T) {
+	manifest := []byte(`<?xml version="1.0" encoding="UTF-8"?><smil xmlns="http://www.w3.org/2001/SMIL20/Language"/>`)
+	read, ok := roundTripBox(t, &LiveServerManifestBox{Manifest: manifest}).(*LiveServerManifestBox)
+	if !ok {
+		t.Fatalf("read %T, want *LiveServerManifestBox", read)
+	}
+	if read.Size != uint32(28+len(manifest)) || read.UserType != LiveServerManifestBoxUserType || !bytes.Equal(read.Manifest, manifest) {
+		t.Errorf("read size %d, user type %x, manifest %q", read.Size, read.UserType, read.Manifest)
+	}
+}
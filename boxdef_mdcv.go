@@ -0,0 +1,96 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 12.1.7 Mastering display colour volume
+
+// Box Type: 'mdcv'
+// Container: Visual Sample Entry
+
+// The MasteringDisplayColourVolumeBox carries the colour volume of the display
+// used to master the content of an HDR video stream.
+type MasteringDisplayColourVolumeBox struct {
+	mp4.Header
+	mp4.NullContainer
+	MasteringDisplayColourVolume
+}
+
+var _ mp4.Box = (*MasteringDisplayColourVolumeBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[MdcvBoxType] = func() mp4.Box { return &MasteringDisplayColourVolumeBox{} }
+}
+
+func (b MasteringDisplayColourVolumeBox) Mp4BoxType() mp4.BoxType {
+	return MdcvBoxType
+}
+
+func (b *MasteringDisplayColourVolumeBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += 3 * 4 // for (c = 0; c < 3; c++) { unsigned int(16) display_primaries_x; unsigned int(16) display_primaries_y; }
+	b.Size += 2     // unsigned int(16) white_point_x;
+	b.Size += 2     // unsigned int(16) white_point_y;
+	b.Size += 4     // unsigned int(32) max_display_mastering_luminance;
+	b.Size += 4     // unsigned int(32) min_display_mastering_luminance;
+	return b.Size
+}
+
+func (b *MasteringDisplayColourVolumeBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	for c := 0; c < 3; c++ {
+		if err = binary.Read(r, binary.BigEndian, &b.DisplayPrimariesX[c]); err != nil {
+			return
+		}
+		if err = binary.Read(r, binary.BigEndian, &b.DisplayPrimariesY[c]); err != nil {
+			return
+		}
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.WhitePointX); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.WhitePointY); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.MaxDisplayMasteringLuminance); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.MinDisplayMasteringLuminance); err != nil {
+		return
+	}
+	return
+}
+
+func (b *MasteringDisplayColourVolumeBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	for c := 0; c < 3; c++ {
+		if err = binary.Write(w, binary.BigEndian, b.DisplayPrimariesX[c]); err != nil {
+			return
+		}
+		if err = binary.Write(w, binary.BigEndian, b.DisplayPrimariesY[c]); err != nil {
+			return
+		}
+	}
+	if err = binary.Write(w, binary.BigEndian, b.WhitePointX); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.WhitePointY); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.MaxDisplayMasteringLuminance); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.MinDisplayMasteringLuminance); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,141 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+	"golang.org/x/text/language"
+)
+
+// 8.4.2 Media Header Box
+
+// Box Type: 'mdhd'
+// Container: Media Box ('mdia')
+
+// MediaHeaderBox replaces the mdhd box of the mp4 package, which packs the
+// first letter of the language code three times and fails to read back
+// language fields that are zero or not a known ISO 639-2/T code. It is read
+// by readMp4Box.
+type MediaHeaderBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	CreationTime     uint64
+	ModificationTime uint64
+	Timescale        uint32
+	Duration         uint64
+
+	// the ISO 639-2/T language code of the media. Unknown codes are read as
+	// the zero Base and written as "und".
+	Language language.Base
+}
+
+var _ mp4.Box = (*MediaHeaderBox)(nil)
+
+func (b MediaHeaderBox) Mp4BoxType() mp4.BoxType {
+	return mp4.MdhdBoxType
+}
+
+func (b *MediaHeaderBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	if b.Version == 1 {
+		b.Size += 8 // unsigned int(64) creation_time;
+		b.Size += 8 // unsigned int(64) modification_time;
+		b.Size += 4 // unsigned int(32) timescale;
+		b.Size += 8 // unsigned int(64) duration;
+	} else {
+		b.Size += 4 // unsigned int(32) creation_time;
+		b.Size += 4 // unsigned int(32) modification_time;
+		b.Size += 4 // unsigned int(32) timescale;
+		b.Size += 4 // unsigned int(32) duration;
+	}
+	b.Size += 2 // bit(1) pad = 0; unsigned int(5)[3] language;
+	b.Size += 2 // unsigned int(16) pre_defined = 0;
+	return b.Size
+}
+
+func (b *MediaHeaderBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		var fields struct {
+			CreationTime     uint64
+			ModificationTime uint64
+			Timescale        uint32
+			Duration         uint64
+		}
+		if err = binary.Read(r, binary.BigEndian, &fields); err != nil {
+			return
+		}
+		b.CreationTime, b.ModificationTime = fields.CreationTime, fields.ModificationTime
+		b.Timescale, b.Duration = fields.Timescale, fields.Duration
+	} else {
+		var fields [4]uint32
+		if err = binary.Read(r, binary.BigEndian, &fields); err != nil {
+			return
+		}
+		b.CreationTime, b.ModificationTime = uint64(fields[0]), uint64(fields[1])
+		b.Timescale, b.Duration = fields[2], uint64(fields[3])
+		if fields[3] == 0xffffffff {
+			b.Duration = 0xffffffffffffffff
+		}
+	}
+	var lang [2]uint16
+	if err = binary.Read(r, binary.BigEndian, &lang); err != nil {
+		return
+	}
+	b.Language = unpackLanguage(lang[0])
+	return
+}
+
+func (b *MediaHeaderBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		fields := struct {
+			CreationTime     uint64
+			ModificationTime uint64
+			Timescale        uint32
+			Duration         uint64
+		}{b.CreationTime, b.ModificationTime, b.Timescale, b.Duration}
+		if err = binary.Write(w, binary.BigEndian, fields); err != nil {
+			return
+		}
+	} else {
+		fields := [4]uint32{uint32(b.CreationTime), uint32(b.ModificationTime), b.Timescale, uint32(b.Duration)}
+		if err = binary.Write(w, binary.BigEndian, fields); err != nil {
+			return
+		}
+	}
+	lang := packLanguage(b.Language)
+	if err = binary.Write(w, binary.BigEndian, [2]uint16{lang, 0}); err != nil {
+		return
+	}
+	return
+}
+
+// packLanguage packs the ISO 639-2/T code of a language into three 5-bit
+// letters, 'und' for the zero Base.
+func packLanguage(lang language.Base) uint16 {
+	code := lang.ISO3()
+	if len(code) != 3 {
+		code = "und"
+	}
+	return uint16(code[0]-0x60)<<10 | uint16(code[1]-0x60)<<5 | uint16(code[2]-0x60)
+}
+
+// unpackLanguage unpacks an ISO 639-2/T code, returning the zero Base if it is
+// not a known language.
+func unpackLanguage(packed uint16) (lang language.Base) {
+	code := []byte{
+		byte(packed>>10&0x1f) + 0x60,
+		byte(packed>>5&0x1f) + 0x60,
+		byte(packed&0x1f) + 0x60,
+	}
+	lang, _ = language.ParseBase(string(code))
+	return
+}
@@ -0,0 +1,86 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+	"golang.org/x/text/language"
+)
+
+func TestMediaHeaderBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		box          MediaHeaderBox
+		wantLanguage string
+	}{
+		{
+			name:         "version 0",
+			box:          MediaHeaderBox{Timescale: 48000, Duration: 2880000, Language: language.MustParseBase("fr")},
+			wantLanguage: "fra",
+		},
+		{
+			name:         "version 1",
+			box:          MediaHeaderBox{FullHeader: mp4.FullHeader{Version: 1}, Timescale: 10000000, Duration: 0x100000000, Language: language.MustParseBase("eng")},
+			wantLanguage: "eng",
+		},
+		{
+			name:         "no language",
+			box:          MediaHeaderBox{Timescale: 1000},
+			wantLanguage: "und",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := tt.box
+			size := box.Mp4BoxUpdate()
+			var buf bytes.Buffer
+			if err := box.Mp4BoxWrite(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if uint32(buf.Len()) != size {
+				t.Fatalf("wrote %d bytes, Mp4BoxUpdate() = %d", buf.Len(), size)
+			}
+			header, err := mp4.ReadHeader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, err := readMp4Box(&buf, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mdhd, ok := read.(*MediaHeaderBox)
+			if !ok {
+				t.Fatalf("read %T, want *MediaHeaderBox", read)
+			}
+			if mdhd.Version != box.Version || mdhd.Timescale != box.Timescale || mdhd.Duration != box.Duration {
+				t.Errorf("read version %d, timescale %d, duration %d, want %d, %d, %d",
+					mdhd.Version, mdhd.Timescale, mdhd.Duration, box.Version, box.Timescale, box.Duration)
+			}
+			if got := mdhd.Language.ISO3(); got != tt.wantLanguage {
+				t.Errorf("read language %s, want %s", got, tt.wantLanguage)
+			}
+		})
+	}
+}
+
+func TestMediaHeaderBoxUnknownLanguage(t *testing.T) {
+	// version 0 mdhd with the language code "zzz" and an unknown duration
+	data := decodeHex(t, "000000206d646864000000000000000000000000000003e8ffffffff6b5a0000")
+	r := bytes.NewReader(data)
+	header, err := mp4.ReadHeader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := readMp4Box(r, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mdhd := box.(*MediaHeaderBox)
+	if mdhd.Language != (language.Base{}) {
+		t.Errorf("language = %s, want the zero Base", mdhd.Language)
+	}
+	if mdhd.Duration != 0xffffffffffffffff {
+		t.Errorf("duration = %x, want all ones", mdhd.Duration)
+	}
+}
@@ -0,0 +1,84 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.8.2 Movie Extends Header Box
+
+// Box Type: 'mehd'
+// Container: Movie Extends Box ('mvex')
+
+// The Movie Extends Header is optional, and provides the overall duration,
+// including fragments, of a fragmented movie. If this box is not present, the
+// overall duration must be computed by examining each fragment.
+type MovieExtendsHeaderBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// declares length of the presentation of the whole movie including
+	// fragments (in the timescale indicated in the Movie Header Box). Version
+	// 1 is selected by Mp4BoxUpdate when the duration exceeds 32 bits.
+	FragmentDuration uint64
+}
+
+var _ mp4.Box = (*MovieExtendsHeaderBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[MehdBoxType] = func() mp4.Box { return &MovieExtendsHeaderBox{} }
+}
+
+func (b MovieExtendsHeaderBox) Mp4BoxType() mp4.BoxType {
+	return MehdBoxType
+}
+
+func (b *MovieExtendsHeaderBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	if b.FragmentDuration > 0xffffffff {
+		b.Version = 1
+	}
+	b.Size = b.HeaderSize() + 4
+	if b.Version == 1 {
+		b.Size += 8 // unsigned int(64) fragment_duration;
+	} else {
+		b.Size += 4 // unsigned int(32) fragment_duration;
+	}
+	return b.Size
+}
+
+func (b *MovieExtendsHeaderBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Read(r, binary.BigEndian, &b.FragmentDuration); err != nil {
+			return
+		}
+	} else {
+		var fragmentDuration uint32
+		if err = binary.Read(r, binary.BigEndian, &fragmentDuration); err != nil {
+			return
+		}
+		b.FragmentDuration = uint64(fragmentDuration)
+	}
+	return
+}
+
+func (b *MovieExtendsHeaderBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Write(w, binary.BigEndian, b.FragmentDuration); err != nil {
+			return
+		}
+	} else {
+		if err = binary.Write(w, binary.BigEndian, uint32(b.FragmentDuration)); err != nil {
+			return
+		}
+	}
+	return
+}
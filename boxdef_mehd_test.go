@@ -0,0 +1,28 @@
+package smoothstreaming
+
+import "testing"
+
+func TestMovieExtendsHeaderBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		duration    uint64
+		wantVersion uint8
+		wantSize    uint32
+	}{
+		{name: "32-bit duration", duration: 600000000, wantVersion: 0, wantSize: 16},
+		{name: "64-bit duration", duration: 0x100000000, wantVersion: 1, wantSize: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := &MovieExtendsHeaderBox{FragmentDuration: tt.duration}
+			read, ok := roundTripBox(t, box).(*MovieExtendsHeaderBox)
+			if !ok {
+				t.Fatalf("read %T, want *MovieExtendsHeaderBox", read)
+			}
+			if read.Version != tt.wantVersion || read.Size != tt.wantSize || read.FragmentDuration != tt.duration {
+				t.Errorf("read version %d, size %d, duration %d, want %d, %d, %d",
+					read.Version, read.Size, read.FragmentDuration, tt.wantVersion, tt.wantSize, tt.duration)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.11.1 The Meta box
+
+// Box Type: 'meta'
+// Container: File, Movie Box ('moov'), Track Box ('trak') or User Data Box
+// ('udta')
+
+// The MetaBox contains a handler box declaring the format of the metadata,
+// e.g. 'mdir' for the iTunes metadata item list, followed by the metadata. The
+// QuickTime form of the box, which lacks the version and flags, is accepted
+// when reading.
+type MetaBox struct {
+	mp4.FullHeader
+	mp4.Container
+}
+
+var _ mp4.Box = (*MetaBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[MetaBoxType] = func() mp4.Box { return &MetaBox{} }
+}
+
+func (b MetaBox) Mp4BoxType() mp4.BoxType {
+	return MetaBoxType
+}
+
+func (b *MetaBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *MetaBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.Header.ReadHeader(r, header); err != nil {
+		return
+	}
+	payload := make([]byte, b.Size-b.HeaderSize())
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	// the QuickTime meta box starts with the size and type of its hdlr box
+	if len(payload) < 8 || !bytes.Equal(payload[4:8], mp4.HdlrBoxType[:]) {
+		if len(payload) < 4 {
+			err = io.ErrUnexpectedEOF
+			return
+		}
+		b.Version = payload[0]
+		copy(b.Flags[:], payload[1:4])
+		payload = payload[4:]
+	}
+	if err = b.Mp4BoxReadChildren(bytes.NewReader(payload), uint32(len(payload))); err != nil {
+		return
+	}
+	return
+}
+
+func (b *MetaBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package smoothstreaming
+
+import (
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 12.3.3 Text metadata sample entry
+
+// Timed metadata tracks with text or binary samples of a MIME type, e.g. the
+// application events of Smooth Streaming DATA, SCMD and CTRL streams, use
+// TextMetaDataSampleEntryBox.
+type TextMetaDataSampleEntryBox struct {
+	mp4.SampleEntry
+
+	// the MIME type of the content encoding applied to the samples, e.g.
+	// "application/zip", empty if the samples are not encoded.
+	ContentEncoding mp4.NullTerminatedString
+
+	// the MIME type of the samples, e.g. "text/xml".
+	MIMEFormat mp4.NullTerminatedString
+}
+
+var _ mp4.Box = (*TextMetaDataSampleEntryBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[MettBoxType] = func() mp4.Box { return &TextMetaDataSampleEntryBox{} }
+}
+
+func (b TextMetaDataSampleEntryBox) Mp4BoxType() mp4.BoxType {
+	return MettBoxType
+}
+
+func (b *TextMetaDataSampleEntryBox) TextMetaDataSampleEntrySize() (size uint32) {
+	size = b.SampleEntrySize()
+	size += b.ContentEncoding.Size() // string content_encoding;
+	size += b.MIMEFormat.Size()      // string mime_format;
+	return
+}
+
+func (b *TextMetaDataSampleEntryBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.TextMetaDataSampleEntrySize()
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *TextMetaDataSampleEntryBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.SampleEntry.Mp4BoxRead(r, header); err != nil {
+		return
+	}
+	lr := io.LimitReader(r, int64(b.Size-b.SampleEntrySize()))
+	for _, s := range []*mp4.NullTerminatedString{&b.ContentEncoding, &b.MIMEFormat} {
+		if err = readNullTerminatedString(lr, s); err != nil {
+			return
+		}
+	}
+	if err = b.Mp4BoxReadChildren(r, b.Size-b.TextMetaDataSampleEntrySize()); err != nil {
+		return
+	}
+	return
+}
+
+func (b *TextMetaDataSampleEntryBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.SampleEntry.Mp4BoxWrite(w); err != nil {
+		return
+	}
+	for _, s := range []mp4.NullTerminatedString{b.ContentEncoding, b.MIMEFormat} {
+		if err = s.Write(w); err != nil {
+			return
+		}
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
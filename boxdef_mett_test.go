@@ -0,0 +1,42 @@
+package smoothstreaming
+
+import (
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestTextMetaDataSampleEntryBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		box  TextMetaDataSampleEntryBox
+	}{
+		{name: "MIME format", box: TextMetaDataSampleEntryBox{MIMEFormat: "text/xml"}},
+		{name: "content encoding", box: TextMetaDataSampleEntryBox{ContentEncoding: "application/zip", MIMEFormat: "application/octet-stream"}},
+		{name: "empty strings", box: TextMetaDataSampleEntryBox{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := tt.box
+			box.DataReferenceIndex = 1
+			if err := box.Mp4BoxReplaceChildren([]mp4.Box{&mp4.BitRateBox{AvgBitrate: 1000, MaxBitrate: 2000}}); err != nil {
+				t.Fatal(err)
+			}
+			read, ok := roundTripBox(t, &box).(*TextMetaDataSampleEntryBox)
+			if !ok {
+				t.Fatalf("read %T, want *TextMetaDataSampleEntryBox", read)
+			}
+			if read.DataReferenceIndex != 1 || read.ContentEncoding != tt.box.ContentEncoding || read.MIMEFormat != tt.box.MIMEFormat {
+				t.Errorf("read index %d, encoding %q, format %q, want 1, %q, %q",
+					read.DataReferenceIndex, read.ContentEncoding, read.MIMEFormat, tt.box.ContentEncoding, tt.box.MIMEFormat)
+			}
+			children := read.Mp4BoxChildren()
+			if len(children) != 1 {
+				t.Fatalf("got %d children, want the btrt box", len(children))
+			}
+			if btrt, ok := children[0].(*mp4.BitRateBox); !ok || btrt.AvgBitrate != 1000 {
+				t.Errorf("child %+v, want the btrt box", children[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package smoothstreaming
+
+import (
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.8.9 Movie Fragment Random Access Box
+
+// Box Type: 'mfra'
+// Container: File
+
+// The Movie Fragment Random Access Box provides a table which may assist
+// readers in finding sync samples in a file using movie fragments. It is
+// usually placed at the end of the file; the last box within the Movie
+// Fragment Random Access Box is a Movie Fragment Random Access Offset Box,
+// which provides a copy of the length of the Movie Fragment Random Access
+// Box, so that readers can find it by scanning from the end of the file.
+type MovieFragmentRandomAccessBox struct {
+	mp4.Header
+	mp4.Container
+}
+
+var _ mp4.Box = (*MovieFragmentRandomAccessBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[MfraBoxType] = func() mp4.Box { return &MovieFragmentRandomAccessBox{} }
+}
+
+func (b MovieFragmentRandomAccessBox) Mp4BoxType() mp4.BoxType {
+	return MfraBoxType
+}
+
+func (b *MovieFragmentRandomAccessBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *MovieFragmentRandomAccessBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = b.Mp4BoxReadChildren(r, b.Size-b.HeaderSize()); err != nil {
+		return
+	}
+	return
+}
+
+func (b *MovieFragmentRandomAccessBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestMfraBoxRoundTrip(t *testing.T) {
+	tfra := &TrackFragmentRandomAccessBox{TrackID: 1, Entries: []TfraEntry{{0, 1024, 1, 1, 1}}}
+	mfro := &MovieFragmentRandomAccessOffsetBox{}
+	mfra := &MovieFragmentRandomAccessBox{}
+	if err := mfra.Mp4BoxReplaceChildren([]mp4.Box{tfra, mfro}); err != nil {
+		t.Fatal(err)
+	}
+	mfro.MfraSize = mfra.Mp4BoxUpdate()
+	read, ok := roundTripBox(t, mfra).(*MovieFragmentRandomAccessBox)
+	if !ok {
+		t.Fatalf("read %T, want *MovieFragmentRandomAccessBox", read)
+	}
+	children := read.Mp4BoxChildren()
+	if len(children) != 2 {
+		t.Fatalf("read %d children, want 2", len(children))
+	}
+	if got, ok := children[0].(*TrackFragmentRandomAccessBox); !ok || !reflect.DeepEqual(got.Entries, tfra.Entries) {
+		t.Errorf("first child %+v, want the tfra box", children[0])
+	}
+	got, ok := children[1].(*MovieFragmentRandomAccessOffsetBox)
+	if !ok || got.MfraSize != read.Size {
+		t.Errorf("last child %+v, want an mfro box of size %d", children[1], read.Size)
+	}
+
+	// the size of the mfra box ends it
+	var buf bytes.Buffer
+	if err := mfra.Mp4BoxWrite(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if end := buf.Bytes()[buf.Len()-4:]; !bytes.Equal(end, []byte{0, 0, 0, byte(read.Size)}) {
+		t.Errorf("mfra box ends with %x, want its size %d", end, read.Size)
+	}
+}
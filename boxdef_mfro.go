@@ -0,0 +1,58 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.8.11 Movie Fragment Random Access Offset Box
+
+// Box Type: 'mfro'
+// Container: Movie Fragment Random Access Box ('mfra')
+
+// The Movie Fragment Random Access Offset Box provides a copy of the length
+// field from the enclosing Movie Fragment Random Access Box. It is placed last
+// within that box, so that the size field is also last in the enclosing Movie
+// Fragment Random Access Box.
+type MovieFragmentRandomAccessOffsetBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the number of bytes of the enclosing mfra box.
+	MfraSize uint32
+}
+
+var _ mp4.Box = (*MovieFragmentRandomAccessOffsetBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[MfroBoxType] = func() mp4.Box { return &MovieFragmentRandomAccessOffsetBox{} }
+}
+
+func (b MovieFragmentRandomAccessOffsetBox) Mp4BoxType() mp4.BoxType {
+	return MfroBoxType
+}
+
+func (b *MovieFragmentRandomAccessOffsetBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += 4 // unsigned int(32) size;
+	return b.Size
+}
+
+func (b *MovieFragmentRandomAccessOffsetBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	err = binary.Read(r, binary.BigEndian, &b.MfraSize)
+	return
+}
+
+func (b *MovieFragmentRandomAccessOffsetBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	err = binary.Write(w, binary.BigEndian, b.MfraSize)
+	return
+}
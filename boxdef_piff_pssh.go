@@ -0,0 +1,83 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+	"github.com/google/uuid"
+)
+
+// PIFF 1.3 5.3.1 ProtectionSystemSpecificHeaderBox
+
+// Box Type: 'uuid' with the PiffPsshBoxUserType user type
+// Container: Movie Box ('moov') or Movie Fragment Box ('moof')
+
+// The PiffProtectionSystemSpecificHeaderBox is the PIFF predecessor of the
+// pssh box, found in the ismv files and fragments of PlayReady protected
+// Smooth Streaming presentations.
+type PiffProtectionSystemSpecificHeaderBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the content protection system of the data.
+	SystemID uuid.UUID
+
+	// the data of the content protection system, a PlayReady Object for
+	// PlayReady.
+	Data []byte
+}
+
+var _ mp4.Box = (*PiffProtectionSystemSpecificHeaderBox)(nil)
+
+func init() {
+	mp4.UUIDBoxRegistry[PiffPsshBoxUserType] = func() mp4.Box { return &PiffProtectionSystemSpecificHeaderBox{} }
+}
+
+func (b PiffProtectionSystemSpecificHeaderBox) Mp4BoxType() mp4.BoxType {
+	return mp4.UuidBoxType
+}
+
+func (b PiffProtectionSystemSpecificHeaderBox) Mp4BoxUserType() mp4.UserType {
+	return PiffPsshBoxUserType
+}
+
+func (b *PiffProtectionSystemSpecificHeaderBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.UserType = b.Mp4BoxUserType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += 16                  // unsigned int(8)[16] SystemID;
+	b.Size += 4                   // unsigned int(32) DataSize;
+	b.Size += uint32(len(b.Data)) // unsigned int(8)[DataSize] Data;
+	return b.Size
+}
+
+func (b *PiffProtectionSystemSpecificHeaderBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if _, err = io.ReadFull(r, b.SystemID[:]); err != nil {
+		return
+	}
+	var dataSize uint32
+	if err = binary.Read(r, binary.BigEndian, &dataSize); err != nil {
+		return
+	}
+	b.Data = make([]byte, dataSize)
+	_, err = io.ReadFull(r, b.Data)
+	return
+}
+
+func (b *PiffProtectionSystemSpecificHeaderBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if _, err = w.Write(b.SystemID[:]); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, uint32(len(b.Data))); err != nil {
+		return
+	}
+	_, err = w.Write(b.Data)
+	return
+}
@@ -0,0 +1,17 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPiffProtectionSystemSpecificHeaderBoxRoundTrip(t *testing.T) {
+	box := &PiffProtectionSystemSpecificHeaderBox{SystemID: PlayReadySystemID, Data: []byte{1, 2, 3}}
+	read, ok := roundTripBox(t, box).(*PiffProtectionSystemSpecificHeaderBox)
+	if !ok {
+		t.Fatalf("read %T, want *PiffProtectionSystemSpecificHeaderBox", read)
+	}
+	if read.Size != 51 || read.UserType != PiffPsshBoxUserType || read.SystemID != PlayReadySystemID || !bytes.Equal(read.Data, box.Data) {
+		t.Errorf("read %+v, want %+v of 51 bytes", read, box)
+	}
+}
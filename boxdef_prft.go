@@ -0,0 +1,107 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.16.5 Producer Reference Time Box
+
+// Box Type: 'prft'
+// Container: File
+
+// The Producer Reference Time Box supplies relative wall-clock times at which
+// movie fragments, or files containing movie fragments, were produced, for
+// low latency players to measure their distance to the live edge. It precedes
+// the moof box of the fragment whose track it references.
+type ProducerReferenceTimeBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the track ID of the reference track.
+	ReferenceTrackID uint32
+
+	// the UTC time at which MediaTime was produced, in NTP format: the
+	// seconds since 1900 in the upper 32 bits and their fraction in the
+	// lower 32 bits.
+	NTPTimestamp uint64
+
+	// the time of the reference track that corresponds to NTPTimestamp, in
+	// its timescale. Version 1 is selected by Mp4BoxUpdate when the time
+	// exceeds 32 bits.
+	MediaTime uint64
+}
+
+var _ mp4.Box = (*ProducerReferenceTimeBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[PrftBoxType] = func() mp4.Box { return &ProducerReferenceTimeBox{} }
+}
+
+func (b ProducerReferenceTimeBox) Mp4BoxType() mp4.BoxType {
+	return PrftBoxType
+}
+
+func (b *ProducerReferenceTimeBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	if b.MediaTime > 0xffffffff {
+		b.Version = 1
+	}
+	b.Size = b.HeaderSize() + 4
+	b.Size += 4 // unsigned int(32) reference_track_ID;
+	b.Size += 8 // unsigned int(64) ntp_timestamp;
+	if b.Version == 1 {
+		b.Size += 8 // unsigned int(64) media_time;
+	} else {
+		b.Size += 4 // unsigned int(32) media_time;
+	}
+	return b.Size
+}
+
+func (b *ProducerReferenceTimeBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.ReferenceTrackID); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.NTPTimestamp); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Read(r, binary.BigEndian, &b.MediaTime); err != nil {
+			return
+		}
+	} else {
+		var mediaTime uint32
+		if err = binary.Read(r, binary.BigEndian, &mediaTime); err != nil {
+			return
+		}
+		b.MediaTime = uint64(mediaTime)
+	}
+	return
+}
+
+func (b *ProducerReferenceTimeBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.ReferenceTrackID); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.NTPTimestamp); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Write(w, binary.BigEndian, b.MediaTime); err != nil {
+			return
+		}
+	} else {
+		if err = binary.Write(w, binary.BigEndian, uint32(b.MediaTime)); err != nil {
+			return
+		}
+	}
+	return
+}
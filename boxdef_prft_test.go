@@ -0,0 +1,27 @@
+package smoothstreaming
+
+import "testing"
+
+func TestProducerReferenceTimeBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		time        uint64
+		wantVersion uint8
+		wantSize    uint32
+	}{
+		{name: "32-bit time", time: 90000, wantVersion: 0, wantSize: 28},
+		{name: "64-bit time", time: 160000000000, wantVersion: 1, wantSize: 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := &ProducerReferenceTimeBox{ReferenceTrackID: 2, NTPTimestamp: 0xe6c1f0a180000000, MediaTime: tt.time}
+			read, ok := roundTripBox(t, box).(*ProducerReferenceTimeBox)
+			if !ok {
+				t.Fatalf("read %T, want *ProducerReferenceTimeBox", read)
+			}
+			if read.Version != tt.wantVersion || read.Size != tt.wantSize || read.ReferenceTrackID != 2 || read.NTPTimestamp != box.NTPTimestamp || read.MediaTime != tt.time {
+				t.Errorf("read %+v, want version %d, size %d of %+v", read, tt.wantVersion, tt.wantSize, box)
+			}
+		})
+	}
+}
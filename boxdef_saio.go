@@ -0,0 +1,131 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.7.9 Sample Auxiliary Information Offsets Box
+
+// Box Type: 'saio'
+// Container: Sample Table Box ('stbl') or Track Fragment Box ('traf')
+
+// The SampleAuxiliaryInformationOffsetsBox locates the auxiliary information
+// of the samples, whose sizes are declared by the
+// SampleAuxiliaryInformationSizesBox. In a track fragment the offsets are
+// relative to the base data offset of the track fragment, which is the moof
+// box for fragments written by Fragment.WriteTo.
+type SampleAuxiliaryInformationOffsetsBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the type of the auxiliary information and its parameter, present if
+	// flag 0x1 is set.
+	AuxInfoType          mp4.FourCC
+	AuxInfoTypeParameter uint32
+
+	// the offset of the information of each chunk, a single offset for the
+	// contiguous information of a track fragment. Version 1 is selected by
+	// Mp4BoxUpdate when an offset exceeds 32 bits.
+	Offsets []uint64
+}
+
+var _ mp4.Box = (*SampleAuxiliaryInformationOffsetsBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[SaioBoxType] = func() mp4.Box { return &SampleAuxiliaryInformationOffsetsBox{} }
+}
+
+func (b SampleAuxiliaryInformationOffsetsBox) Mp4BoxType() mp4.BoxType {
+	return SaioBoxType
+}
+
+func (b *SampleAuxiliaryInformationOffsetsBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	for _, offset := range b.Offsets {
+		if offset > 0xffffffff {
+			b.Version = 1
+		}
+	}
+	b.Size = b.HeaderSize() + 4
+	if b.Mp4BoxFlags()&1 > 0 {
+		b.Size += 4 + 4 // unsigned int(32) aux_info_type; unsigned int(32) aux_info_type_parameter;
+	}
+	b.Size += 4 // unsigned int(32) entry_count;
+	if b.Version == 1 {
+		b.Size += 8 * uint32(len(b.Offsets)) // unsigned int(64) offset[entry_count];
+	} else {
+		b.Size += 4 * uint32(len(b.Offsets)) // unsigned int(32) offset[entry_count];
+	}
+	return b.Size
+}
+
+func (b *SampleAuxiliaryInformationOffsetsBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if b.Mp4BoxFlags()&1 > 0 {
+		if err = binary.Read(r, binary.BigEndian, &b.AuxInfoType); err != nil {
+			return
+		}
+		if err = binary.Read(r, binary.BigEndian, &b.AuxInfoTypeParameter); err != nil {
+			return
+		}
+	}
+	var entryCount uint32
+	if err = binary.Read(r, binary.BigEndian, &entryCount); err != nil {
+		return
+	}
+	entrySize := uint64(4)
+	if b.Version == 1 {
+		entrySize = 8
+	}
+	if uint64(entryCount)*entrySize > uint64(b.Size) {
+		err = mp4.ErrInvalidFormat
+		return
+	}
+	b.Offsets = make([]uint64, entryCount)
+	for i := range b.Offsets {
+		if b.Version == 1 {
+			err = binary.Read(r, binary.BigEndian, &b.Offsets[i])
+		} else {
+			var offset uint32
+			err = binary.Read(r, binary.BigEndian, &offset)
+			b.Offsets[i] = uint64(offset)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *SampleAuxiliaryInformationOffsetsBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if b.Mp4BoxFlags()&1 > 0 {
+		if err = binary.Write(w, binary.BigEndian, b.AuxInfoType); err != nil {
+			return
+		}
+		if err = binary.Write(w, binary.BigEndian, b.AuxInfoTypeParameter); err != nil {
+			return
+		}
+	}
+	if err = binary.Write(w, binary.BigEndian, uint32(len(b.Offsets))); err != nil {
+		return
+	}
+	for _, offset := range b.Offsets {
+		if b.Version == 1 {
+			err = binary.Write(w, binary.BigEndian, offset)
+		} else {
+			err = binary.Write(w, binary.BigEndian, uint32(offset))
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
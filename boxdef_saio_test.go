@@ -0,0 +1,62 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestSampleAuxiliaryInformationOffsetsBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		box         func() *SampleAuxiliaryInformationOffsetsBox
+		wantVersion uint8
+		wantSize    uint32
+	}{
+		{
+			name: "32-bit offset",
+			box: func() *SampleAuxiliaryInformationOffsetsBox {
+				return &SampleAuxiliaryInformationOffsetsBox{Offsets: []uint64{120}}
+			},
+			wantSize: 20,
+		},
+		{
+			name: "64-bit offsets",
+			box: func() *SampleAuxiliaryInformationOffsetsBox {
+				return &SampleAuxiliaryInformationOffsetsBox{Offsets: []uint64{120, 0x100000000}}
+			},
+			wantVersion: 1,
+			wantSize:    32,
+		},
+		{
+			name: "aux info type",
+			box: func() *SampleAuxiliaryInformationOffsetsBox {
+				saio := &SampleAuxiliaryInformationOffsetsBox{AuxInfoType: CbcsFourCC, Offsets: []uint64{64}}
+				saio.Mp4BoxSetFlags(1)
+				return saio
+			},
+			wantSize: 28,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read, ok := roundTripBox(t, tt.box()).(*SampleAuxiliaryInformationOffsetsBox)
+			if !ok {
+				t.Fatalf("read %T, want *SampleAuxiliaryInformationOffsetsBox", read)
+			}
+			want := tt.box()
+			if read.Version != tt.wantVersion || read.Size != tt.wantSize || read.AuxInfoType != want.AuxInfoType || !reflect.DeepEqual(read.Offsets, want.Offsets) {
+				t.Errorf("read version %d, size %d, %+v, want %d, %d, %+v", read.Version, read.Size, read, tt.wantVersion, tt.wantSize, want)
+			}
+		})
+	}
+}
+
+func TestSampleAuxiliaryInformationOffsetsBoxEntryCountTooLarge(t *testing.T) {
+	data := decodeHex(t, "00000014"+"7361696f"+"00000000"+"ffffffff"+"00000000")
+	if _, err := mp4.ReadBox(bytes.NewReader(data)); err == nil {
+		t.Error("read a saio box of 2^32-1 entries in 20 bytes")
+	}
+}
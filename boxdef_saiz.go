@@ -0,0 +1,119 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.7.8 Sample Auxiliary Information Sizes Box
+
+// Box Type: 'saiz'
+// Container: Sample Table Box ('stbl') or Track Fragment Box ('traf')
+
+// The SampleAuxiliaryInformationSizesBox declares the size of the auxiliary
+// information of each sample, e.g. the sample encryption data of Common
+// Encryption located by the SampleAuxiliaryInformationOffsetsBox.
+type SampleAuxiliaryInformationSizesBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the type of the auxiliary information and its parameter, present if
+	// flag 0x1 is set. If absent, the type is the protection scheme of the
+	// track for Common Encryption.
+	AuxInfoType          mp4.FourCC
+	AuxInfoTypeParameter uint32
+
+	// the size of the information of every sample, or 0 if the sizes vary and
+	// are given by SampleInfoSizes.
+	DefaultSampleInfoSize uint8
+
+	SampleCount     uint32
+	SampleInfoSizes []uint8
+}
+
+var _ mp4.Box = (*SampleAuxiliaryInformationSizesBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[SaizBoxType] = func() mp4.Box { return &SampleAuxiliaryInformationSizesBox{} }
+}
+
+func (b SampleAuxiliaryInformationSizesBox) Mp4BoxType() mp4.BoxType {
+	return SaizBoxType
+}
+
+func (b *SampleAuxiliaryInformationSizesBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	if b.Mp4BoxFlags()&1 > 0 {
+		b.Size += 4 + 4 // unsigned int(32) aux_info_type; unsigned int(32) aux_info_type_parameter;
+	}
+	b.Size += 1 // unsigned int(8) default_sample_info_size;
+	b.Size += 4 // unsigned int(32) sample_count;
+	if b.DefaultSampleInfoSize == 0 {
+		b.Size += uint32(len(b.SampleInfoSizes)) // unsigned int(8) sample_info_size[sample_count];
+	}
+	return b.Size
+}
+
+func (b *SampleAuxiliaryInformationSizesBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if b.Mp4BoxFlags()&1 > 0 {
+		if err = binary.Read(r, binary.BigEndian, &b.AuxInfoType); err != nil {
+			return
+		}
+		if err = binary.Read(r, binary.BigEndian, &b.AuxInfoTypeParameter); err != nil {
+			return
+		}
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.DefaultSampleInfoSize); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.SampleCount); err != nil {
+		return
+	}
+	if b.DefaultSampleInfoSize == 0 {
+		b.SampleInfoSizes = make([]uint8, b.Size-b.HeaderSize()-4-b.fieldsSize())
+		if _, err = io.ReadFull(r, b.SampleInfoSizes); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// fieldsSize returns the size of the fields preceding the sample info sizes.
+func (b *SampleAuxiliaryInformationSizesBox) fieldsSize() (size uint32) {
+	if b.Mp4BoxFlags()&1 > 0 {
+		size += 8
+	}
+	return size + 1 + 4
+}
+
+func (b *SampleAuxiliaryInformationSizesBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if b.Mp4BoxFlags()&1 > 0 {
+		if err = binary.Write(w, binary.BigEndian, b.AuxInfoType); err != nil {
+			return
+		}
+		if err = binary.Write(w, binary.BigEndian, b.AuxInfoTypeParameter); err != nil {
+			return
+		}
+	}
+	if err = binary.Write(w, binary.BigEndian, b.DefaultSampleInfoSize); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.SampleCount); err != nil {
+		return
+	}
+	if b.DefaultSampleInfoSize == 0 {
+		if _, err = w.Write(b.SampleInfoSizes); err != nil {
+			return
+		}
+	}
+	return
+}
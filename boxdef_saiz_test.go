@@ -0,0 +1,52 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSampleAuxiliaryInformationSizesBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		box      func() *SampleAuxiliaryInformationSizesBox
+		wantSize uint32
+	}{
+		{
+			name: "default size",
+			box: func() *SampleAuxiliaryInformationSizesBox {
+				return &SampleAuxiliaryInformationSizesBox{DefaultSampleInfoSize: 16, SampleCount: 30}
+			},
+			wantSize: 17,
+		},
+		{
+			name: "sizes per sample",
+			box: func() *SampleAuxiliaryInformationSizesBox {
+				return &SampleAuxiliaryInformationSizesBox{SampleCount: 3, SampleInfoSizes: []uint8{16, 22, 16}}
+			},
+			wantSize: 20,
+		},
+		{
+			name: "aux info type",
+			box: func() *SampleAuxiliaryInformationSizesBox {
+				saiz := &SampleAuxiliaryInformationSizesBox{AuxInfoType: CbcsFourCC, AuxInfoTypeParameter: 1, SampleCount: 2, SampleInfoSizes: []uint8{8, 14}}
+				saiz.Mp4BoxSetFlags(1)
+				return saiz
+			},
+			wantSize: 27,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read, ok := roundTripBox(t, tt.box()).(*SampleAuxiliaryInformationSizesBox)
+			if !ok {
+				t.Fatalf("read %T, want *SampleAuxiliaryInformationSizesBox", read)
+			}
+			want := tt.box()
+			if read.Size != tt.wantSize || read.AuxInfoType != want.AuxInfoType || read.AuxInfoTypeParameter != want.AuxInfoTypeParameter ||
+				read.DefaultSampleInfoSize != want.DefaultSampleInfoSize || read.SampleCount != want.SampleCount ||
+				!bytes.Equal(read.SampleInfoSizes, want.SampleInfoSizes) {
+				t.Errorf("read size %d, %+v, want %d, %+v", read.Size, read, tt.wantSize, want)
+			}
+		})
+	}
+}
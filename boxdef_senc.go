@@ -0,0 +1,177 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// ISO/IEC 23001-7 7.2 Sample Encryption Box, PIFF 5.3.2
+
+// Box Type: 'senc', or 'uuid' with the PIFF SampleEncryptionBox user type
+// Container: Track Fragment Box ('traf')
+
+// SampleEncryptionBox replaces the sample encryption box of the mp4 package,
+// which assumes 8-byte IVs unless they are overridden by the box. The IV size
+// of the track is declared by its tenc box, so unless it is overridden the
+// size is inferred from the size of the box when it is read by readMp4Box.
+type SampleEncryptionBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the PIFF override of the tenc parameters, present if the
+	// FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS flag is set.
+	AlgorithmID mp4.PiffAlgorithmID
+	KID         [16]byte
+
+	// the size of the per-sample IVs, 0 for tracks with a constant IV.
+	IVSize uint8
+
+	Samples []mp4.SampleEncryptionSampleEntry
+}
+
+var _ mp4.Box = (*SampleEncryptionBox)(nil)
+
+func (b SampleEncryptionBox) Mp4BoxType() mp4.BoxType {
+	if b.Type == mp4.UuidBoxType {
+		return mp4.UuidBoxType
+	}
+	return mp4.SencBoxType
+}
+
+// IsPIFF reports whether the box is the PIFF uuid box rather than a senc box.
+func (b SampleEncryptionBox) IsPIFF() bool {
+	return b.Type == mp4.UuidBoxType
+}
+
+func (b *SampleEncryptionBox) hasSubsamples() bool {
+	return b.Mp4BoxFlags()&mp4.FLAG_SENC_USE_SUBSAMPLE_ENCRYPTION > 0
+}
+
+func (b *SampleEncryptionBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	if b.Type == mp4.UuidBoxType {
+		b.UserType = mp4.SampleEncryptionBoxUserType
+	}
+	b.Size = b.HeaderSize() + 4
+	if b.Mp4BoxFlags()&mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS > 0 {
+		b.Size += 3 + 1 + 16 // unsigned int(24) AlgorithmID; unsigned int(8) IV_size; unsigned int(8)[16] KID;
+	}
+	b.Size += 4 // unsigned int(32) sample_count;
+	for _, sample := range b.Samples {
+		b.Size += uint32(b.IVSize) // unsigned int(Per_Sample_IV_Size*8) InitializationVector;
+		if b.hasSubsamples() {
+			b.Size += 2                                  // unsigned int(16) subsample_count;
+			b.Size += 6 * uint32(len(sample.Subsamples)) // BytesOfClearData, BytesOfProtectedData
+		}
+	}
+	return b.Size
+}
+
+func (b *SampleEncryptionBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	payload := make([]byte, b.Size-b.HeaderSize()-4)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return
+	}
+	ivSizes := []uint8{8, 16, 0}
+	if b.Mp4BoxFlags()&mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS > 0 {
+		if len(payload) < 20 {
+			err = fmt.Errorf("sample encryption box truncated: %w", mp4.ErrInvalidFormat)
+			return
+		}
+		params := binary.BigEndian.Uint32(payload)
+		b.AlgorithmID = mp4.PiffAlgorithmID(params >> 8)
+		copy(b.KID[:], payload[4:20])
+		payload = payload[20:]
+		ivSizes = []uint8{uint8(params)}
+	}
+	for _, ivSize := range ivSizes {
+		if b.Samples, err = readSampleEncryptionEntries(payload, ivSize, b.hasSubsamples()); err == nil {
+			b.IVSize = ivSize
+			return
+		}
+	}
+	return
+}
+
+// readSampleEncryptionEntries reads the sample entries of a sample encryption
+// box, which must use up the payload exactly.
+func readSampleEncryptionEntries(payload []byte, ivSize uint8, subsamples bool) (samples []mp4.SampleEncryptionSampleEntry, err error) {
+	r := bytes.NewReader(payload)
+	var sampleCount uint32
+	if err = binary.Read(r, binary.BigEndian, &sampleCount); err != nil {
+		return
+	}
+	if uint64(sampleCount)*uint64(ivSize) > uint64(r.Len()) {
+		err = fmt.Errorf("%d samples of %d-byte IVs exceed the sample encryption box: %w", sampleCount, ivSize, mp4.ErrInvalidFormat)
+		return
+	}
+	samples = make([]mp4.SampleEncryptionSampleEntry, sampleCount)
+	for i := range samples {
+		samples[i].InitializationVector = make([]byte, ivSize)
+		if _, err = io.ReadFull(r, samples[i].InitializationVector); err != nil {
+			return
+		}
+		if !subsamples {
+			continue
+		}
+		var subsampleCount uint16
+		if err = binary.Read(r, binary.BigEndian, &subsampleCount); err != nil {
+			return
+		}
+		if int(subsampleCount)*6 > r.Len() {
+			err = fmt.Errorf("%d subsamples exceed the sample encryption box: %w", subsampleCount, mp4.ErrInvalidFormat)
+			return
+		}
+		samples[i].Subsamples = make([]mp4.SampleEncryptionSubsampleEntry, subsampleCount)
+		if err = binary.Read(r, binary.BigEndian, samples[i].Subsamples); err != nil {
+			return
+		}
+	}
+	if r.Len() > 0 {
+		err = fmt.Errorf("%d bytes left in the sample encryption box: %w", r.Len(), mp4.ErrInvalidFormat)
+	}
+	return
+}
+
+func (b *SampleEncryptionBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if b.Mp4BoxFlags()&mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS > 0 {
+		if err = binary.Write(w, binary.BigEndian, uint32(b.AlgorithmID)<<8|uint32(b.IVSize)); err != nil {
+			return
+		}
+		if err = binary.Write(w, binary.BigEndian, b.KID); err != nil {
+			return
+		}
+	}
+	if err = binary.Write(w, binary.BigEndian, uint32(len(b.Samples))); err != nil {
+		return
+	}
+	for _, sample := range b.Samples {
+		if len(sample.InitializationVector) != int(b.IVSize) {
+			err = fmt.Errorf("IV of %d bytes in sample encryption box of %d-byte IVs: %w", len(sample.InitializationVector), b.IVSize, ErrInvalidParam)
+			return
+		}
+		if _, err = w.Write(sample.InitializationVector); err != nil {
+			return
+		}
+		if !b.hasSubsamples() {
+			continue
+		}
+		if err = binary.Write(w, binary.BigEndian, uint16(len(sample.Subsamples))); err != nil {
+			return
+		}
+		if err = binary.Write(w, binary.BigEndian, sample.Subsamples); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,106 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestSampleEncryptionBoxRoundTrip(t *testing.T) {
+	subsamples := []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 5, BytesOfProtectedData: 32}}
+	tests := []struct {
+		name string
+		box  func() *SampleEncryptionBox
+	}{
+		{
+			name: "16-byte IVs without subsamples",
+			box: func() *SampleEncryptionBox {
+				return &SampleEncryptionBox{IVSize: 16, Samples: []mp4.SampleEncryptionSampleEntry{
+					{InitializationVector: bytes.Repeat([]byte{1}, 16)},
+					{InitializationVector: bytes.Repeat([]byte{2}, 16)},
+				}}
+			},
+		},
+		{
+			name: "constant IV with subsamples",
+			box: func() *SampleEncryptionBox {
+				senc := &SampleEncryptionBox{Samples: []mp4.SampleEncryptionSampleEntry{{InitializationVector: []byte{}, Subsamples: subsamples}}}
+				senc.Mp4BoxSetFlags(mp4.FLAG_SENC_USE_SUBSAMPLE_ENCRYPTION)
+				return senc
+			},
+		},
+		{
+			name: "PIFF override of the tenc parameters",
+			box: func() *SampleEncryptionBox {
+				senc := &SampleEncryptionBox{AlgorithmID: 1, KID: [16]byte(testPlayReadyKID), IVSize: 8, Samples: []mp4.SampleEncryptionSampleEntry{
+					{InitializationVector: bytes.Repeat([]byte{3}, 8), Subsamples: subsamples},
+				}}
+				senc.Type = mp4.UuidBoxType
+				senc.Mp4BoxSetFlags(mp4.FLAG_SENC_USE_SUBSAMPLE_ENCRYPTION | mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS)
+				return senc
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := tt.box()
+			size := box.Mp4BoxUpdate()
+			var buf bytes.Buffer
+			if err := box.Mp4BoxWrite(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if uint32(buf.Len()) != size {
+				t.Fatalf("wrote %d bytes, Mp4BoxUpdate() = %d", buf.Len(), size)
+			}
+			header, err := mp4.ReadHeader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, err := readMp4Box(&buf, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			senc, ok := read.(*SampleEncryptionBox)
+			if !ok {
+				t.Fatalf("read %T, want *SampleEncryptionBox", read)
+			}
+			want := tt.box()
+			if senc.IsPIFF() != want.IsPIFF() || senc.IVSize != want.IVSize || senc.AlgorithmID != want.AlgorithmID ||
+				senc.KID != want.KID || !reflect.DeepEqual(senc.Samples, want.Samples) {
+				t.Errorf("read %+v, want %+v", senc, want)
+			}
+		})
+	}
+}
+
+func TestSampleEncryptionBoxIVSizeMismatch(t *testing.T) {
+	senc := &SampleEncryptionBox{IVSize: 16, Samples: []mp4.SampleEncryptionSampleEntry{{InitializationVector: make([]byte, 8)}}}
+	senc.Mp4BoxUpdate()
+	if err := senc.Mp4BoxWrite(&bytes.Buffer{}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Mp4BoxWrite() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestReadSampleEncryptionEntriesErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    []byte
+		ivSize     uint8
+		subsamples bool
+	}{
+		{name: "no sample count", payload: []byte{0, 0}},
+		{name: "IVs beyond the box", payload: decodeHex(t, "00000002"+"0102030405060708"), ivSize: 8},
+		{name: "subsamples beyond the box", payload: decodeHex(t, "00000001"+"0002"+"000100000010"), subsamples: true},
+		{name: "trailing bytes", payload: decodeHex(t, "00000001"+"0102030405060708"+"ff"), ivSize: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readSampleEncryptionEntries(tt.payload, tt.ivSize, tt.subsamples); err == nil {
+				t.Error("readSampleEncryptionEntries() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.16.3 Segment Index Box
+
+// Box Type: 'sidx'
+// Container: File
+
+// The SegmentIndexBox indexes the subsegments of a media segment, giving
+// their sizes, durations and stream access points, so that DASH clients can
+// request single subsegments of an on-demand file by byte range.
+type SegmentIndexBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the track ID of the indexed track.
+	ReferenceID uint32
+
+	// the timescale of the times and durations of the box.
+	Timescale uint32
+
+	// the earliest presentation time of the first subsegment, and the
+	// distance from the end of the box to the first subsegment. Version 1 is
+	// selected by Mp4BoxUpdate when either exceeds 32 bits.
+	EarliestPresentationTime uint64
+	FirstOffset              uint64
+
+	References []SegmentIndexReference
+}
+
+// SegmentIndexReference is a subsegment, or another sidx box, indexed by a
+// SegmentIndexBox.
+type SegmentIndexReference struct {
+	// whether the reference is to a sidx box rather than to media.
+	ReferencesIndex bool
+
+	// the size of the referenced material, in bytes.
+	ReferencedSize uint32
+
+	// the duration of the subsegment in the timescale of the box.
+	SubsegmentDuration uint32
+
+	// whether the subsegment starts with a stream access point of the type
+	// SAPType, at SAPDeltaTime after its earliest presentation time.
+	StartsWithSAP bool
+	SAPType       uint8
+	SAPDeltaTime  uint32
+}
+
+var _ mp4.Box = (*SegmentIndexBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[SidxBoxType] = func() mp4.Box { return &SegmentIndexBox{} }
+}
+
+func (b SegmentIndexBox) Mp4BoxType() mp4.BoxType {
+	return SidxBoxType
+}
+
+func (b *SegmentIndexBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	if b.EarliestPresentationTime > 0xffffffff || b.FirstOffset > 0xffffffff {
+		b.Version = 1
+	}
+	b.Size = b.HeaderSize() + 4
+	b.Size += 4 // unsigned int(32) reference_ID;
+	b.Size += 4 // unsigned int(32) timescale;
+	if b.Version == 0 {
+		b.Size += 4 + 4 // unsigned int(32) earliest_presentation_time; unsigned int(32) first_offset;
+	} else {
+		b.Size += 8 + 8 // unsigned int(64) earliest_presentation_time; unsigned int(64) first_offset;
+	}
+	b.Size += 2 + 2                          // unsigned int(16) reserved = 0; unsigned int(16) reference_count;
+	b.Size += 12 * uint32(len(b.References)) // reference_type, referenced_size, subsegment_duration, SAP fields
+	return b.Size
+}
+
+func (b *SegmentIndexBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.ReferenceID); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.Timescale); err != nil {
+		return
+	}
+	if b.Version == 0 {
+		var times [2]uint32
+		if err = binary.Read(r, binary.BigEndian, &times); err != nil {
+			return
+		}
+		b.EarliestPresentationTime, b.FirstOffset = uint64(times[0]), uint64(times[1])
+	} else {
+		var times [2]uint64
+		if err = binary.Read(r, binary.BigEndian, &times); err != nil {
+			return
+		}
+		b.EarliestPresentationTime, b.FirstOffset = times[0], times[1]
+	}
+	var counts [2]uint16 // reserved, reference_count
+	if err = binary.Read(r, binary.BigEndian, &counts); err != nil {
+		return
+	}
+	if uint64(counts[1])*12 > uint64(b.Size) {
+		err = fmt.Errorf("%d references exceed the sidx box: %w", counts[1], mp4.ErrInvalidFormat)
+		return
+	}
+	b.References = make([]SegmentIndexReference, counts[1])
+	for i := range b.References {
+		var fields [3]uint32
+		if err = binary.Read(r, binary.BigEndian, &fields); err != nil {
+			return
+		}
+		b.References[i] = SegmentIndexReference{
+			ReferencesIndex:    fields[0]>>31 == 1,
+			ReferencedSize:     fields[0] & 0x7fffffff,
+			SubsegmentDuration: fields[1],
+			StartsWithSAP:      fields[2]>>31 == 1,
+			SAPType:            uint8(fields[2] >> 28 & 0x7),
+			SAPDeltaTime:       fields[2] & 0x0fffffff,
+		}
+	}
+	return
+}
+
+func (b *SegmentIndexBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.ReferenceID); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.Timescale); err != nil {
+		return
+	}
+	if b.Version == 0 {
+		err = binary.Write(w, binary.BigEndian, [2]uint32{uint32(b.EarliestPresentationTime), uint32(b.FirstOffset)})
+	} else {
+		err = binary.Write(w, binary.BigEndian, [2]uint64{b.EarliestPresentationTime, b.FirstOffset})
+	}
+	if err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, [2]uint16{0, uint16(len(b.References))}); err != nil {
+		return
+	}
+	for _, ref := range b.References {
+		fields := [3]uint32{
+			ref.ReferencedSize & 0x7fffffff,
+			ref.SubsegmentDuration,
+			uint32(ref.SAPType&0x7)<<28 | ref.SAPDeltaTime&0x0fffffff,
+		}
+		if ref.ReferencesIndex {
+			fields[0] |= 1 << 31
+		}
+		if ref.StartsWithSAP {
+			fields[2] |= 1 << 31
+		}
+		if err = binary.Write(w, binary.BigEndian, fields); err != nil {
+			return
+		}
+	}
+	return
+}
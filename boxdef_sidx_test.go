@@ -0,0 +1,60 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestSegmentIndexBoxRoundTrip(t *testing.T) {
+	references := []SegmentIndexReference{
+		{ReferencedSize: 1000, SubsegmentDuration: 2000, StartsWithSAP: true, SAPType: 1},
+		{ReferencesIndex: true, ReferencedSize: 0x7fffffff, SubsegmentDuration: 3000, SAPType: 3, SAPDeltaTime: 0x0fffffff},
+	}
+	tests := []struct {
+		name        string
+		time        uint64
+		firstOffset uint64
+		wantVersion uint8
+		wantSize    uint32
+	}{
+		{name: "32-bit times", time: 90000, firstOffset: 0, wantVersion: 0, wantSize: 56},
+		{name: "64-bit time", time: 160000000000, wantVersion: 1, wantSize: 64},
+		{name: "64-bit offset", time: 90000, firstOffset: 0x1_0000_0000, wantVersion: 1, wantSize: 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := &SegmentIndexBox{ReferenceID: 1, Timescale: 10000000, EarliestPresentationTime: tt.time, FirstOffset: tt.firstOffset, References: references}
+			read, ok := roundTripBox(t, box).(*SegmentIndexBox)
+			if !ok {
+				t.Fatalf("read %T, want *SegmentIndexBox", read)
+			}
+			if read.Version != tt.wantVersion || read.Size != tt.wantSize {
+				t.Errorf("read version %d, size %d, want %d, %d", read.Version, read.Size, tt.wantVersion, tt.wantSize)
+			}
+			if read.ReferenceID != 1 || read.Timescale != 10000000 || read.EarliestPresentationTime != tt.time || read.FirstOffset != tt.firstOffset {
+				t.Errorf("read %+v, want %+v", read, box)
+			}
+			if !reflect.DeepEqual(read.References, references) {
+				t.Errorf("read references %+v, want %+v", read.References, references)
+			}
+		})
+	}
+}
+
+func TestSegmentIndexBoxPayload(t *testing.T) {
+	box := &SegmentIndexBox{ReferenceID: 1, Timescale: 90000, EarliestPresentationTime: 3000, References: []SegmentIndexReference{
+		{ReferencedSize: 1000, SubsegmentDuration: 2000, StartsWithSAP: true, SAPType: 1},
+	}}
+	box.Mp4BoxUpdate()
+	var buf bytes.Buffer
+	if err := box.Mp4BoxWrite(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "0000002c73696478" + "00000000" + "00000001" + "00015f90" + "00000bb8" + "00000000" + "00000001" +
+		"000003e8" + "000007d0" + "90000000"
+	if got := hex.EncodeToString(buf.Bytes()); got != want {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,48 @@
+package smoothstreaming
+
+import (
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 12.6.2 Subtitle media header
+
+// Box Types: 'sthd'
+// Container: Media Information Box ('minf')
+
+// Subtitle tracks use the SubtitleMediaHeaderBox in the media information box.
+type SubtitleMediaHeaderBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+}
+
+var _ mp4.Box = (*SubtitleMediaHeaderBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[SthdBoxType] = func() mp4.Box { return &SubtitleMediaHeaderBox{} }
+}
+
+func (b SubtitleMediaHeaderBox) Mp4BoxType() mp4.BoxType {
+	return SthdBoxType
+}
+
+func (b *SubtitleMediaHeaderBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	return b.Size
+}
+
+func (b *SubtitleMediaHeaderBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	return
+}
+
+func (b *SubtitleMediaHeaderBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 12.6.3 Subtitle Sample entry
+
+// XML subtitle tracks, e.g. TTML, use XMLSubtitleSampleEntryBox.
+type XMLSubtitleSampleEntryBox struct {
+	mp4.SampleEntry
+
+	// one or more white-space separated XML namespaces to which the sample
+	// documents conform.
+	Namespace mp4.NullTerminatedString
+
+	// zero or more white-space separated URLs of the schemas of the
+	// namespaces.
+	SchemaLocation mp4.NullTerminatedString
+
+	// the MIME types of the auxiliary resources stored as subsamples.
+	AuxiliaryMIMETypes mp4.NullTerminatedString
+}
+
+// TTMLNamespace is the XML namespace of TTML documents, used for both the
+// TTML and DFXP tracks of Smooth Streaming.
+const TTMLNamespace = "http://www.w3.org/ns/ttml"
+
+var _ mp4.Box = (*XMLSubtitleSampleEntryBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[StppBoxType] = func() mp4.Box { return &XMLSubtitleSampleEntryBox{} }
+}
+
+func (b XMLSubtitleSampleEntryBox) Mp4BoxType() mp4.BoxType {
+	return StppBoxType
+}
+
+func (b *XMLSubtitleSampleEntryBox) XMLSubtitleSampleEntrySize() (size uint32) {
+	size = b.SampleEntrySize()
+	size += b.Namespace.Size()          // string namespace;
+	size += b.SchemaLocation.Size()     // string schema_location;
+	size += b.AuxiliaryMIMETypes.Size() // string auxiliary_mime_types;
+	return
+}
+
+func (b *XMLSubtitleSampleEntryBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.XMLSubtitleSampleEntrySize()
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *XMLSubtitleSampleEntryBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.SampleEntry.Mp4BoxRead(r, header); err != nil {
+		return
+	}
+	lr := io.LimitReader(r, int64(b.Size-b.SampleEntrySize()))
+	for _, s := range []*mp4.NullTerminatedString{&b.Namespace, &b.SchemaLocation, &b.AuxiliaryMIMETypes} {
+		if err = readNullTerminatedString(lr, s); err != nil {
+			return
+		}
+	}
+	if err = b.Mp4BoxReadChildren(r, b.Size-b.XMLSubtitleSampleEntrySize()); err != nil {
+		return
+	}
+	return
+}
+
+func (b *XMLSubtitleSampleEntryBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.SampleEntry.Mp4BoxWrite(w); err != nil {
+		return
+	}
+	for _, s := range []mp4.NullTerminatedString{b.Namespace, b.SchemaLocation, b.AuxiliaryMIMETypes} {
+		if err = s.Write(w); err != nil {
+			return
+		}
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
+
+// readNullTerminatedString reads a string of unknown length up to and
+// including its terminating null byte, without reading ahead.
+func readNullTerminatedString(r io.Reader, s *mp4.NullTerminatedString) (err error) {
+	var b []byte
+	var c [1]byte
+	for {
+		if _, err = io.ReadFull(r, c[:]); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("string not null-terminated: %w", mp4.ErrInvalidFormat)
+			}
+			return
+		}
+		if c[0] == 0 {
+			break
+		}
+		b = append(b, c[0])
+	}
+	*s = mp4.NullTerminatedString(b)
+	return
+}
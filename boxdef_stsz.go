@@ -0,0 +1,92 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.7.3.2 Sample Size Box
+
+// Box Type: 'stsz'
+// Container: Sample Table Box ('stbl')
+
+// SampleSizeBox replaces the stsz box of the mp4 package, which reads and
+// writes 12 bytes for each sample size instead of 4. It is read by
+// readMp4Box.
+type SampleSizeBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the size of every sample if they all have the same size, 0 if the
+	// sizes are given by EntrySizes.
+	SampleSize uint32
+
+	// the number of samples of the track.
+	SampleCount uint32
+
+	// the size of each sample, present if SampleSize is 0.
+	EntrySizes []uint32
+}
+
+var _ mp4.Box = (*SampleSizeBox)(nil)
+
+func (b SampleSizeBox) Mp4BoxType() mp4.BoxType {
+	return mp4.StszBoxType
+}
+
+func (b *SampleSizeBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	if b.SampleSize == 0 {
+		b.SampleCount = uint32(len(b.EntrySizes))
+	}
+	b.Size = b.HeaderSize() + 4
+	b.Size += 4 // unsigned int(32) sample_size;
+	b.Size += 4 // unsigned int(32) sample_count;
+	if b.SampleSize == 0 {
+		b.Size += 4 * uint32(len(b.EntrySizes)) // unsigned int(32) entry_size[sample_count];
+	}
+	return b.Size
+}
+
+func (b *SampleSizeBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.SampleSize); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &b.SampleCount); err != nil {
+		return
+	}
+	if b.SampleSize != 0 {
+		return
+	}
+	if uint64(b.SampleCount)*4 > uint64(b.Size) {
+		err = fmt.Errorf("%d sample sizes exceed the stsz box: %w", b.SampleCount, mp4.ErrInvalidFormat)
+		return
+	}
+	b.EntrySizes = make([]uint32, b.SampleCount)
+	err = binary.Read(r, binary.BigEndian, b.EntrySizes)
+	return
+}
+
+func (b *SampleSizeBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.SampleSize); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.SampleCount); err != nil {
+		return
+	}
+	if b.SampleSize == 0 {
+		if err = binary.Write(w, binary.BigEndian, b.EntrySizes); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,60 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestSampleSizeBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		box      SampleSizeBox
+		wantSize uint32
+	}{
+		{name: "entry sizes", box: SampleSizeBox{EntrySizes: []uint32{10, 6, 300}}, wantSize: 32},
+		{name: "constant size", box: SampleSizeBox{SampleSize: 4, SampleCount: 100}, wantSize: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := tt.box
+			size := box.Mp4BoxUpdate()
+			var buf bytes.Buffer
+			if err := box.Mp4BoxWrite(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if uint32(buf.Len()) != size || size != tt.wantSize {
+				t.Fatalf("wrote %d bytes, Mp4BoxUpdate() = %d, want %d", buf.Len(), size, tt.wantSize)
+			}
+			header, err := mp4.ReadHeader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, err := readMp4Box(&buf, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stsz, ok := read.(*SampleSizeBox)
+			if !ok {
+				t.Fatalf("read %T, want *SampleSizeBox", read)
+			}
+			if stsz.SampleSize != box.SampleSize || stsz.SampleCount != box.SampleCount || !reflect.DeepEqual(stsz.EntrySizes, box.EntrySizes) {
+				t.Errorf("read %+v, want %+v", stsz, box)
+			}
+		})
+	}
+}
+
+func TestSampleSizeBoxTooManyEntries(t *testing.T) {
+	data := []byte{0, 0, 0, 20, 's', 't', 's', 'z', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10, 0}
+	header, err := mp4.ReadHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readMp4Box(bytes.NewReader(data[8:]), header); !errors.Is(err, mp4.ErrInvalidFormat) {
+		t.Errorf("readMp4Box() error = %v, want %v", err, mp4.ErrInvalidFormat)
+	}
+}
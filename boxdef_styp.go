@@ -0,0 +1,34 @@
+package smoothstreaming
+
+import "github.com/go-webdl/mp4"
+
+// 8.16.2 Segment Type Box
+
+// Box Type: 'styp'
+// Container: File
+
+// If segments are stored in separate files (e.g. on a standard HTTP server)
+// it is recommended that these 'segment files' contain a segment-type box,
+// which must be first if present, to enable identification of those files,
+// and declaration of the specifications with which they are compliant. Its
+// syntax is the one of the File Type Box, which the mp4 package only reads
+// and writes as ftyp.
+type SegmentTypeBox struct {
+	mp4.FileTypeBox
+}
+
+var _ mp4.Box = (*SegmentTypeBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[StypBoxType] = func() mp4.Box { return &SegmentTypeBox{} }
+}
+
+func (b SegmentTypeBox) Mp4BoxType() mp4.BoxType {
+	return StypBoxType
+}
+
+func (b *SegmentTypeBox) Mp4BoxUpdate() uint32 {
+	b.FileTypeBox.Mp4BoxUpdate()
+	b.Type = b.Mp4BoxType()
+	return b.Size
+}
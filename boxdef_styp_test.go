@@ -0,0 +1,23 @@
+package smoothstreaming
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestSegmentTypeBoxRoundTrip(t *testing.T) {
+	cmfs := mp4.FourCC{'c', 'm', 'f', 's'}
+	box := &SegmentTypeBox{}
+	box.MajorBrand = cmfs
+	box.CompatibleBrands = []mp4.FourCC{cmfs, {'m', 's', 'd', 'h'}}
+	read, ok := roundTripBox(t, box).(*SegmentTypeBox)
+	if !ok {
+		t.Fatalf("read %T, want *SegmentTypeBox", read)
+	}
+	if read.Type != StypBoxType || read.Size != 24 || read.MajorBrand != cmfs || !reflect.DeepEqual(read.CompatibleBrands, box.CompatibleBrands) {
+		t.Errorf("read %s box of %d bytes with brands %s %v, want styp of 24 bytes with %s %v",
+			read.Type, read.Size, read.MajorBrand, read.CompatibleBrands, box.MajorBrand, box.CompatibleBrands)
+	}
+}
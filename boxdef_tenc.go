@@ -0,0 +1,132 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// ISO/IEC 23001-7 8.2 Track Encryption Box
+
+// Box Type: 'tenc'
+// Container: Scheme Information Box ('schi')
+
+// TrackEncryptionBox replaces the tenc box of the mp4 package, which does not
+// write the default constant IV of the 'cbcs' scheme and misreads its
+// protection pattern. The tenc boxes read by the mp4 package are converted by
+// newTrackEncryptionBox.
+type TrackEncryptionBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	DefaultCryptByteBlock  uint8
+	DefaultSkipByteBlock   uint8
+	DefaultIsProtected     uint8
+	DefaultPerSampleIVSize uint8
+	DefaultKID             [16]byte
+	DefaultConstantIV      []byte
+}
+
+var _ mp4.Box = (*TrackEncryptionBox)(nil)
+
+// newTrackEncryptionBox returns the tenc box b read by the mp4 package as a
+// TrackEncryptionBox. The mp4 package reads the reserved byte of version 1
+// boxes as the crypt byte block and the pattern byte as the skip byte block.
+func newTrackEncryptionBox(b *mp4.TrackEncryptionBox) *TrackEncryptionBox {
+	tenc := &TrackEncryptionBox{
+		FullHeader:             b.FullHeader,
+		DefaultIsProtected:     b.DefaultIsProtected,
+		DefaultPerSampleIVSize: b.DefaultPerSampleIVSize,
+		DefaultKID:             b.DefaultKID,
+		DefaultConstantIV:      b.DefaultConstantIV,
+	}
+	if b.Version != 0 {
+		tenc.DefaultCryptByteBlock = b.DefaultSkipByteBlock >> 4
+		tenc.DefaultSkipByteBlock = b.DefaultSkipByteBlock & 0x0f
+	}
+	return tenc
+}
+
+func (b TrackEncryptionBox) Mp4BoxType() mp4.BoxType {
+	return mp4.TencBoxType
+}
+
+func (b *TrackEncryptionBox) hasConstantIV() bool {
+	return b.DefaultIsProtected == 1 && b.DefaultPerSampleIVSize == 0
+}
+
+func (b *TrackEncryptionBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += 1  // unsigned int(8) reserved = 0;
+	b.Size += 1  // unsigned int(8) reserved = 0; or unsigned int(4) default_crypt_byte_block; unsigned int(4) default_skip_byte_block;
+	b.Size += 1  // unsigned int(8) default_isProtected;
+	b.Size += 1  // unsigned int(8) default_Per_Sample_IV_Size;
+	b.Size += 16 // unsigned int(8)[16] default_KID;
+	if b.hasConstantIV() {
+		b.Size += 1                                // unsigned int(8) default_constant_IV_size;
+		b.Size += uint32(len(b.DefaultConstantIV)) // unsigned int(8)[default_constant_IV_size] default_constant_IV;
+	}
+	return b.Size
+}
+
+func (b *TrackEncryptionBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	var fields [4]uint8
+	if err = binary.Read(r, binary.BigEndian, &fields); err != nil {
+		return
+	}
+	if b.Version != 0 {
+		b.DefaultCryptByteBlock = fields[1] >> 4
+		b.DefaultSkipByteBlock = fields[1] & 0x0f
+	}
+	b.DefaultIsProtected = fields[2]
+	b.DefaultPerSampleIVSize = fields[3]
+	if err = binary.Read(r, binary.BigEndian, &b.DefaultKID); err != nil {
+		return
+	}
+	if b.hasConstantIV() {
+		var size uint8
+		if err = binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		b.DefaultConstantIV = make([]byte, size)
+		if _, err = io.ReadFull(r, b.DefaultConstantIV); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *TrackEncryptionBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	fields := [4]uint8{0, 0, b.DefaultIsProtected, b.DefaultPerSampleIVSize}
+	if b.Version != 0 {
+		fields[1] = b.DefaultCryptByteBlock<<4 | b.DefaultSkipByteBlock&0x0f
+	}
+	if err = binary.Write(w, binary.BigEndian, fields); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, b.DefaultKID); err != nil {
+		return
+	}
+	if b.hasConstantIV() {
+		if len(b.DefaultConstantIV) > 0xff {
+			err = fmt.Errorf("tenc constant IV of %d bytes: %w", len(b.DefaultConstantIV), mp4.ErrInvalidFormat)
+			return
+		}
+		if err = binary.Write(w, binary.BigEndian, uint8(len(b.DefaultConstantIV))); err != nil {
+			return
+		}
+		if _, err = w.Write(b.DefaultConstantIV); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,66 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestTrackEncryptionBoxRoundTrip(t *testing.T) {
+	kid := [16]byte{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
+	tests := []struct {
+		name string
+		box  *TrackEncryptionBox
+	}{
+		{
+			name: "cenc",
+			box:  &TrackEncryptionBox{DefaultIsProtected: 1, DefaultPerSampleIVSize: 8, DefaultKID: kid},
+		},
+		{
+			name: "cbcs with per-sample IVs",
+			box: &TrackEncryptionBox{
+				FullHeader:            mp4.FullHeader{Version: 1},
+				DefaultCryptByteBlock: 1, DefaultSkipByteBlock: 9,
+				DefaultIsProtected: 1, DefaultPerSampleIVSize: 16, DefaultKID: kid,
+			},
+		},
+		{
+			name: "cbcs with a constant IV",
+			box: &TrackEncryptionBox{
+				FullHeader:            mp4.FullHeader{Version: 1},
+				DefaultCryptByteBlock: 1, DefaultSkipByteBlock: 9,
+				DefaultIsProtected: 1, DefaultKID: kid,
+				DefaultConstantIV: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read, ok := roundTripBox(t, tt.box).(*mp4.TrackEncryptionBox)
+			if !ok {
+				t.Fatalf("read %T, want *mp4.TrackEncryptionBox", read)
+			}
+			got := newTrackEncryptionBox(read)
+			if got.Version != tt.box.Version ||
+				got.DefaultCryptByteBlock != tt.box.DefaultCryptByteBlock ||
+				got.DefaultSkipByteBlock != tt.box.DefaultSkipByteBlock ||
+				got.DefaultIsProtected != tt.box.DefaultIsProtected ||
+				got.DefaultPerSampleIVSize != tt.box.DefaultPerSampleIVSize ||
+				got.DefaultKID != tt.box.DefaultKID ||
+				!bytes.Equal(got.DefaultConstantIV, tt.box.DefaultConstantIV) {
+				t.Errorf("read %+v, want %+v", got, tt.box)
+			}
+		})
+	}
+}
+
+func TestTrackEncryptionBoxConstantIVTooLong(t *testing.T) {
+	box := &TrackEncryptionBox{DefaultIsProtected: 1, DefaultConstantIV: make([]byte, 256)}
+	box.Mp4BoxUpdate()
+	var buf bytes.Buffer
+	if err := box.Mp4BoxWrite(&buf); !errors.Is(err, mp4.ErrInvalidFormat) {
+		t.Errorf("Mp4BoxWrite() error = %v, want %v", err, mp4.ErrInvalidFormat)
+	}
+}
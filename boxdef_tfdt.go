@@ -0,0 +1,85 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.8.12 Track fragment decode time
+
+// Box Type: 'tfdt'
+// Container: Track Fragment Box ('traf')
+
+// The Track Fragment Base Media Decode Time Box provides the absolute decode
+// time, measured on the media timeline, of the first sample in decode order
+// in the track fragment. Smooth Streaming fragments carry the time in the
+// TfxdBox instead, which players of ISO BMFF fragments do not understand.
+type TrackFragmentDecodeTimeBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the sum of the decode durations of all earlier samples in the media, in
+	// the timescale of the track. Version 1 is selected by Mp4BoxUpdate when
+	// the time exceeds 32 bits.
+	BaseMediaDecodeTime uint64
+}
+
+var _ mp4.Box = (*TrackFragmentDecodeTimeBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[TfdtBoxType] = func() mp4.Box { return &TrackFragmentDecodeTimeBox{} }
+}
+
+func (b TrackFragmentDecodeTimeBox) Mp4BoxType() mp4.BoxType {
+	return TfdtBoxType
+}
+
+func (b *TrackFragmentDecodeTimeBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	if b.BaseMediaDecodeTime > 0xffffffff {
+		b.Version = 1
+	}
+	b.Size = b.HeaderSize() + 4
+	if b.Version == 1 {
+		b.Size += 8 // unsigned int(64) baseMediaDecodeTime;
+	} else {
+		b.Size += 4 // unsigned int(32) baseMediaDecodeTime;
+	}
+	return b.Size
+}
+
+func (b *TrackFragmentDecodeTimeBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Read(r, binary.BigEndian, &b.BaseMediaDecodeTime); err != nil {
+			return
+		}
+	} else {
+		var baseMediaDecodeTime uint32
+		if err = binary.Read(r, binary.BigEndian, &baseMediaDecodeTime); err != nil {
+			return
+		}
+		b.BaseMediaDecodeTime = uint64(baseMediaDecodeTime)
+	}
+	return
+}
+
+func (b *TrackFragmentDecodeTimeBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Write(w, binary.BigEndian, b.BaseMediaDecodeTime); err != nil {
+			return
+		}
+	} else {
+		if err = binary.Write(w, binary.BigEndian, uint32(b.BaseMediaDecodeTime)); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,27 @@
+package smoothstreaming
+
+import "testing"
+
+func TestTrackFragmentDecodeTimeBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		time        uint64
+		wantVersion uint8
+		wantSize    uint32
+	}{
+		{name: "32-bit time", time: 90000, wantVersion: 0, wantSize: 16},
+		{name: "64-bit time", time: 160000000000, wantVersion: 1, wantSize: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read, ok := roundTripBox(t, &TrackFragmentDecodeTimeBox{BaseMediaDecodeTime: tt.time}).(*TrackFragmentDecodeTimeBox)
+			if !ok {
+				t.Fatalf("read %T, want *TrackFragmentDecodeTimeBox", read)
+			}
+			if read.Version != tt.wantVersion || read.Size != tt.wantSize || read.BaseMediaDecodeTime != tt.time {
+				t.Errorf("read version %d, size %d, time %d, want %d, %d, %d",
+					read.Version, read.Size, read.BaseMediaDecodeTime, tt.wantVersion, tt.wantSize, tt.time)
+			}
+		})
+	}
+}
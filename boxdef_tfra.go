@@ -0,0 +1,224 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.8.10 Track Fragment Random Access Box
+
+// Box Type: 'tfra'
+// Container: Movie Fragment Random Access Box ('mfra')
+
+// Each entry contains the location and the presentation time of the sync
+// sample. Note that not every sync sample in the track needs to be listed in
+// the table. The absence of this box does not mean that all the samples are
+// sync samples.
+type TrackFragmentRandomAccessBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the track ID of the track whose sync samples are listed.
+	TrackID uint32
+
+	// the sync samples, in increasing order of time. Version 1 is selected by
+	// Mp4BoxUpdate when a time or an offset exceeds 32 bits.
+	Entries []TfraEntry
+
+	// the sizes in bytes of the traf, trun and sample numbers of the entries,
+	// the smallest holding all of them as selected by Mp4BoxUpdate.
+	trafNumberSize, trunNumberSize, sampleNumberSize uint32
+}
+
+// TfraEntry is a sync sample listed by a TrackFragmentRandomAccessBox.
+type TfraEntry struct {
+	// the presentation time of the sync sample, in the timescale of the
+	// track.
+	Time uint64
+
+	// the offset of the moof box of the fragment containing the sync sample
+	// from the beginning of the file.
+	MoofOffset uint64
+
+	// the numbers, counted from 1, of the traf box within the moof box, of
+	// the trun box within the traf box, and of the sample within the trun box
+	// of the sync sample.
+	TrafNumber   uint32
+	TrunNumber   uint32
+	SampleNumber uint32
+}
+
+var _ mp4.Box = (*TrackFragmentRandomAccessBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[TfraBoxType] = func() mp4.Box { return &TrackFragmentRandomAccessBox{} }
+}
+
+func (b TrackFragmentRandomAccessBox) Mp4BoxType() mp4.BoxType {
+	return TfraBoxType
+}
+
+func (b *TrackFragmentRandomAccessBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	var maxTraf, maxTrun, maxSample uint32
+	for _, e := range b.Entries {
+		if e.Time > 0xffffffff || e.MoofOffset > 0xffffffff {
+			b.Version = 1
+		}
+		maxTraf = maxUint32(maxTraf, e.TrafNumber)
+		maxTrun = maxUint32(maxTrun, e.TrunNumber)
+		maxSample = maxUint32(maxSample, e.SampleNumber)
+	}
+	b.trafNumberSize = uintSize(maxTraf)
+	b.trunNumberSize = uintSize(maxTrun)
+	b.sampleNumberSize = uintSize(maxSample)
+	b.Size = b.HeaderSize() + 4
+	b.Size += 4 // unsigned int(32) track_ID;
+	b.Size += 4 // const unsigned int(26) reserved = 0;
+	// unsigned int(2) length_size_of_traf_num;
+	// unsigned int(2) length_size_of_trun_num;
+	// unsigned int(2) length_size_of_sample_num;
+	b.Size += 4 // unsigned int(32) number_of_entry;
+	// for(i=1; i <= number_of_entry; i++){
+	//     if(version==1){
+	//         unsigned int(64) time;
+	//         unsigned int(64) moof_offset;
+	//     }else{
+	//         unsigned int(32) time;
+	//         unsigned int(32) moof_offset;
+	//     }
+	//     unsigned int((length_size_of_traf_num+1) * 8) traf_number;
+	//     unsigned int((length_size_of_trun_num+1) * 8) trun_number;
+	//     unsigned int((length_size_of_sample_num+1) * 8) sample_number;
+	// }
+	entrySize := b.trafNumberSize + b.trunNumberSize + b.sampleNumberSize
+	if b.Version == 1 {
+		entrySize += 16
+	} else {
+		entrySize += 8
+	}
+	b.Size += entrySize * uint32(len(b.Entries))
+	return b.Size
+}
+
+func (b *TrackFragmentRandomAccessBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	var fields struct {
+		TrackID     uint32
+		LengthSizes uint32
+		EntryCount  uint32
+	}
+	if err = binary.Read(r, binary.BigEndian, &fields); err != nil {
+		return
+	}
+	b.TrackID = fields.TrackID
+	b.trafNumberSize = (fields.LengthSizes>>4)&3 + 1
+	b.trunNumberSize = (fields.LengthSizes>>2)&3 + 1
+	b.sampleNumberSize = fields.LengthSizes&3 + 1
+	b.Entries = make([]TfraEntry, fields.EntryCount)
+	for i := range b.Entries {
+		e := &b.Entries[i]
+		if b.Version == 1 {
+			if err = binary.Read(r, binary.BigEndian, &e.Time); err != nil {
+				return
+			}
+			if err = binary.Read(r, binary.BigEndian, &e.MoofOffset); err != nil {
+				return
+			}
+		} else {
+			var entry struct {
+				Time       uint32
+				MoofOffset uint32
+			}
+			if err = binary.Read(r, binary.BigEndian, &entry); err != nil {
+				return
+			}
+			e.Time = uint64(entry.Time)
+			e.MoofOffset = uint64(entry.MoofOffset)
+		}
+		if e.TrafNumber, err = readUintN(r, b.trafNumberSize); err != nil {
+			return
+		}
+		if e.TrunNumber, err = readUintN(r, b.trunNumberSize); err != nil {
+			return
+		}
+		if e.SampleNumber, err = readUintN(r, b.sampleNumberSize); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *TrackFragmentRandomAccessBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	lengthSizes := (b.trafNumberSize-1)<<4 | (b.trunNumberSize-1)<<2 | (b.sampleNumberSize - 1)
+	if err = binary.Write(w, binary.BigEndian, [3]uint32{b.TrackID, lengthSizes, uint32(len(b.Entries))}); err != nil {
+		return
+	}
+	for _, e := range b.Entries {
+		if b.Version == 1 {
+			if err = binary.Write(w, binary.BigEndian, [2]uint64{e.Time, e.MoofOffset}); err != nil {
+				return
+			}
+		} else {
+			if err = binary.Write(w, binary.BigEndian, [2]uint32{uint32(e.Time), uint32(e.MoofOffset)}); err != nil {
+				return
+			}
+		}
+		if err = writeUintN(w, e.TrafNumber, b.trafNumberSize); err != nil {
+			return
+		}
+		if err = writeUintN(w, e.TrunNumber, b.trunNumberSize); err != nil {
+			return
+		}
+		if err = writeUintN(w, e.SampleNumber, b.sampleNumberSize); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// uintSize returns the smallest number of bytes holding v.
+func uintSize(v uint32) uint32 {
+	switch {
+	case v > 0xffffff:
+		return 4
+	case v > 0xffff:
+		return 3
+	case v > 0xff:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func maxUint32(a, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// readUintN reads a big-endian unsigned integer of size bytes.
+func readUintN(r io.Reader, size uint32) (v uint32, err error) {
+	var buf [4]byte
+	if _, err = io.ReadFull(r, buf[4-size:]); err != nil {
+		return
+	}
+	v = binary.BigEndian.Uint32(buf[:])
+	return
+}
+
+// writeUintN writes v as a big-endian unsigned integer of size bytes.
+func writeUintN(w io.Writer, v uint32, size uint32) (err error) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	_, err = w.Write(buf[4-size:])
+	return
+}
@@ -0,0 +1,34 @@
+package smoothstreaming
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTfraBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		entries     []TfraEntry
+		wantVersion uint8
+		wantSize    uint32
+	}{
+		{name: "no entries", wantSize: 24},
+		{name: "32-bit fields", entries: []TfraEntry{{0, 1024, 1, 1, 1}, {20000000, 4096, 1, 1, 1}}, wantSize: 46},
+		{name: "64-bit time", entries: []TfraEntry{{160000000000, 1024, 1, 1, 1}}, wantVersion: 1, wantSize: 43},
+		{name: "wide numbers", entries: []TfraEntry{{0, 1024, 0x100, 0x10000, 0x1000000}}, wantSize: 41},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read, ok := roundTripBox(t, &TrackFragmentRandomAccessBox{TrackID: 2, Entries: tt.entries}).(*TrackFragmentRandomAccessBox)
+			if !ok {
+				t.Fatalf("read %T, want *TrackFragmentRandomAccessBox", read)
+			}
+			if read.Version != tt.wantVersion || read.Size != tt.wantSize || read.TrackID != 2 {
+				t.Errorf("read version %d, size %d, track %d, want %d, %d, 2", read.Version, read.Size, read.TrackID, tt.wantVersion, tt.wantSize)
+			}
+			if len(read.Entries) != len(tt.entries) || (len(tt.entries) > 0 && !reflect.DeepEqual(read.Entries, tt.entries)) {
+				t.Errorf("read entries %v, want %v", read.Entries, tt.entries)
+			}
+		})
+	}
+}
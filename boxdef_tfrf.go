@@ -0,0 +1,122 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// [MS-SSTR] 2.2.4.5 TfrfBox
+
+// Box Type: 'uuid' with the TfrfBoxUserType user type
+// Container: Track Fragment Box ('traf')
+
+// The TfrfBox of live presentations announces the absolute time and the
+// duration of fragments that follow the fragment, in the timescale of its
+// stream, so that clients can request them without refreshing the manifest.
+type TfrfBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the announced fragments. Version 1 is selected by Mp4BoxUpdate when a
+	// field exceeds 32 bits.
+	Fragments []TfrfFragment
+}
+
+// TfrfFragment is a fragment announced by a TfrfBox.
+type TfrfFragment struct {
+	FragmentAbsoluteTime uint64
+	FragmentDuration     uint64
+}
+
+var _ mp4.Box = (*TfrfBox)(nil)
+
+func init() {
+	mp4.UUIDBoxRegistry[TfrfBoxUserType] = func() mp4.Box { return &TfrfBox{} }
+}
+
+func (b TfrfBox) Mp4BoxType() mp4.BoxType {
+	return mp4.UuidBoxType
+}
+
+func (b TfrfBox) Mp4BoxUserType() mp4.UserType {
+	return TfrfBoxUserType
+}
+
+func (b *TfrfBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.UserType = b.Mp4BoxUserType()
+	for _, fragment := range b.Fragments {
+		if fragment.FragmentAbsoluteTime > 0xffffffff || fragment.FragmentDuration > 0xffffffff {
+			b.Version = 1
+		}
+	}
+	b.Size = b.HeaderSize() + 4
+	b.Size += 1 // unsigned int(8) FragmentCount;
+	if b.Version == 1 {
+		b.Size += 16 * uint32(len(b.Fragments)) // unsigned int(64) FragmentAbsoluteTime; unsigned int(64) FragmentDuration;
+	} else {
+		b.Size += 8 * uint32(len(b.Fragments)) // unsigned int(32) FragmentAbsoluteTime; unsigned int(32) FragmentDuration;
+	}
+	return b.Size
+}
+
+func (b *TfrfBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	var fragmentCount uint8
+	if err = binary.Read(r, binary.BigEndian, &fragmentCount); err != nil {
+		return
+	}
+	b.Fragments = make([]TfrfFragment, fragmentCount)
+	for i := range b.Fragments {
+		if b.Version == 1 {
+			err = binary.Read(r, binary.BigEndian, &b.Fragments[i])
+		} else {
+			var fields [2]uint32
+			err = binary.Read(r, binary.BigEndian, &fields)
+			b.Fragments[i] = TfrfFragment{uint64(fields[0]), uint64(fields[1])}
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b *TfrfBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if len(b.Fragments) > 0xff {
+		err = fmt.Errorf("tfrf box of %d fragments: %w", len(b.Fragments), ErrInvalidParam)
+		return
+	}
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = binary.Write(w, binary.BigEndian, uint8(len(b.Fragments))); err != nil {
+		return
+	}
+	for _, fragment := range b.Fragments {
+		if b.Version == 1 {
+			err = binary.Write(w, binary.BigEndian, fragment)
+		} else {
+			err = binary.Write(w, binary.BigEndian, [2]uint32{uint32(fragment.FragmentAbsoluteTime), uint32(fragment.FragmentDuration)})
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// StreamFragments returns the announced fragments as the fragment entries of
+// a manifest, to extend the fragments of the stream of a live presentation.
+func (b TfrfBox) StreamFragments() (fragments []*StreamFragment) {
+	for _, fragment := range b.Fragments {
+		time, duration := fragment.FragmentAbsoluteTime, fragment.FragmentDuration
+		fragments = append(fragments, &StreamFragment{Time: &time, Duration: &duration})
+	}
+	return
+}
@@ -0,0 +1,60 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTfrfBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		fragments   []TfrfFragment
+		wantVersion uint8
+		wantSize    uint32
+	}{
+		{name: "no fragments", wantSize: 29},
+		{name: "32-bit fields", fragments: []TfrfFragment{{20000000, 20000000}, {40000000, 20000000}}, wantSize: 45},
+		{name: "64-bit time", fragments: []TfrfFragment{{160000000000, 20000000}}, wantVersion: 1, wantSize: 45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read, ok := roundTripBox(t, &TfrfBox{Fragments: tt.fragments}).(*TfrfBox)
+			if !ok {
+				t.Fatalf("read %T, want *TfrfBox", read)
+			}
+			if read.Version != tt.wantVersion || read.Size != tt.wantSize || read.UserType != TfrfBoxUserType {
+				t.Errorf("read version %d, size %d, user type %x, want %d, %d, %x", read.Version, read.Size, read.UserType, tt.wantVersion, tt.wantSize, TfrfBoxUserType)
+			}
+			if len(read.Fragments) != len(tt.fragments) || (len(tt.fragments) > 0 && !reflect.DeepEqual(read.Fragments, tt.fragments)) {
+				t.Errorf("read fragments %v, want %v", read.Fragments, tt.fragments)
+			}
+		})
+	}
+}
+
+func TestTfrfBoxTooManyFragments(t *testing.T) {
+	box := &TfrfBox{Fragments: make([]TfrfFragment, 256)}
+	box.Mp4BoxUpdate()
+	if err := box.Mp4BoxWrite(&bytes.Buffer{}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Mp4BoxWrite() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestTfrfBoxStreamFragments(t *testing.T) {
+	box := TfrfBox{Fragments: []TfrfFragment{{20000000, 20000000}, {40000000, 19000000}}}
+	got := box.StreamFragments()
+	if len(got) != 2 {
+		t.Fatalf("got %d fragments, want 2", len(got))
+	}
+	for i, fragment := range got {
+		if *fragment.Time != box.Fragments[i].FragmentAbsoluteTime || *fragment.Duration != box.Fragments[i].FragmentDuration {
+			t.Errorf("fragment %d t=%d d=%d, want %+v", i, *fragment.Time, *fragment.Duration, box.Fragments[i])
+		}
+	}
+	// the fragments do not share the time and duration
+	if got[0].Time == got[1].Time {
+		t.Error("fragments share their time")
+	}
+}
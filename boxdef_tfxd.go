@@ -0,0 +1,94 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// [MS-SSTR] 2.2.4.4 TfxdBox
+
+// Box Type: 'uuid' with the TfxdBoxUserType user type
+// Container: Track Fragment Box ('traf')
+
+// The TfxdBox carries the absolute time and the duration of a fragment, in the
+// timescale of its stream.
+type TfxdBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	// the absolute timestamp of the first sample of the fragment. Version 1
+	// is selected by Mp4BoxUpdate when either field exceeds 32 bits.
+	FragmentAbsoluteTime uint64
+
+	// the duration of the fragment.
+	FragmentDuration uint64
+}
+
+var _ mp4.Box = (*TfxdBox)(nil)
+
+func init() {
+	mp4.UUIDBoxRegistry[TfxdBoxUserType] = func() mp4.Box { return &TfxdBox{} }
+}
+
+func (b TfxdBox) Mp4BoxType() mp4.BoxType {
+	return mp4.UuidBoxType
+}
+
+func (b TfxdBox) Mp4BoxUserType() mp4.UserType {
+	return TfxdBoxUserType
+}
+
+func (b *TfxdBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.UserType = b.Mp4BoxUserType()
+	if b.FragmentAbsoluteTime > 0xffffffff || b.FragmentDuration > 0xffffffff {
+		b.Version = 1
+	}
+	b.Size = b.HeaderSize() + 4
+	if b.Version == 1 {
+		b.Size += 8 + 8 // unsigned int(64) FragmentAbsoluteTime; unsigned int(64) FragmentDuration;
+	} else {
+		b.Size += 4 + 4 // unsigned int(32) FragmentAbsoluteTime; unsigned int(32) FragmentDuration;
+	}
+	return b.Size
+}
+
+func (b *TfxdBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Read(r, binary.BigEndian, &b.FragmentAbsoluteTime); err != nil {
+			return
+		}
+		if err = binary.Read(r, binary.BigEndian, &b.FragmentDuration); err != nil {
+			return
+		}
+	} else {
+		var fields [2]uint32
+		if err = binary.Read(r, binary.BigEndian, &fields); err != nil {
+			return
+		}
+		b.FragmentAbsoluteTime = uint64(fields[0])
+		b.FragmentDuration = uint64(fields[1])
+	}
+	return
+}
+
+func (b *TfxdBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if b.Version == 1 {
+		if err = binary.Write(w, binary.BigEndian, [2]uint64{b.FragmentAbsoluteTime, b.FragmentDuration}); err != nil {
+			return
+		}
+	} else {
+		if err = binary.Write(w, binary.BigEndian, [2]uint32{uint32(b.FragmentAbsoluteTime), uint32(b.FragmentDuration)}); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,28 @@
+package smoothstreaming
+
+import "testing"
+
+func TestTfxdBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		box         TfxdBox
+		wantVersion uint8
+		wantSize    uint32
+	}{
+		{name: "32-bit fields", box: TfxdBox{FragmentAbsoluteTime: 20000000, FragmentDuration: 20000000}, wantVersion: 0, wantSize: 36},
+		{name: "64-bit time", box: TfxdBox{FragmentAbsoluteTime: 160000000000, FragmentDuration: 20000000}, wantVersion: 1, wantSize: 44},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := tt.box
+			read, ok := roundTripBox(t, &box).(*TfxdBox)
+			if !ok {
+				t.Fatalf("read %T, want *TfxdBox", read)
+			}
+			if read.Version != tt.wantVersion || read.Size != tt.wantSize || read.UserType != TfxdBoxUserType ||
+				read.FragmentAbsoluteTime != tt.box.FragmentAbsoluteTime || read.FragmentDuration != tt.box.FragmentDuration {
+				t.Errorf("read %+v, want version %d, size %d, %+v", *read, tt.wantVersion, tt.wantSize, tt.box)
+			}
+		})
+	}
+}
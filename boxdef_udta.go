@@ -0,0 +1,56 @@
+package smoothstreaming
+
+import (
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 8.10.1 User Data Box
+
+// Box Type: 'udta'
+// Container: Movie Box ('moov'), Track Box ('trak')
+
+// The UserDataBox contains objects that declare user information about the
+// containing box and its data, e.g. the metadata of a presentation or track.
+type UserDataBox struct {
+	mp4.Header
+	mp4.Container
+}
+
+var _ mp4.Box = (*UserDataBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[UdtaBoxType] = func() mp4.Box { return &UserDataBox{} }
+}
+
+func (b UserDataBox) Mp4BoxType() mp4.BoxType {
+	return UdtaBoxType
+}
+
+func (b *UserDataBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *UserDataBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = b.Mp4BoxReadChildren(r, b.Size-b.HeaderSize()); err != nil {
+		return
+	}
+	return
+}
+
+func (b *UserDataBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
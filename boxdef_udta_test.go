@@ -0,0 +1,75 @@
+package smoothstreaming
+
+import (
+	"testing"
+
+	"github.com/go-webdl/mp4"
+	"golang.org/x/text/language"
+)
+
+func TestMetadataUdtaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		lang  language.Base
+	}{
+		{name: "all languages", title: "Big Buck Bunny"},
+		{name: "language", title: "Commentaire", lang: language.MustParseBase("fr")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			udta, err := createMetadataUdtaMp4Box(tt.title, tt.lang)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read := roundTripBox(t, udta)
+			if _, ok := read.(*UserDataBox); !ok {
+				t.Fatalf("read %T, want *UserDataBox", read)
+			}
+			if hdlr, ok := findMp4Box(read, MetaBoxType, mp4.HdlrBoxType).(*mp4.HandlerBox); !ok || hdlr.HandlerType != MdirFourCC {
+				t.Errorf("meta handler = %v, want mdir", hdlr)
+			}
+			data, ok := findMp4Box(read, MetaBoxType, IlstBoxType, NamBoxType, DataBoxType).(*MetadataDataBox)
+			if !ok {
+				t.Fatal("no ©nam data box")
+			}
+			if string(data.Value) != tt.title || data.DataType != MetadataTypeUTF8 || data.Language != tt.lang {
+				t.Errorf("data = %q type %d language %s, want %q type %d language %s",
+					data.Value, data.DataType, data.Language, tt.title, MetadataTypeUTF8, tt.lang)
+			}
+			if title, ok := metadataTitle(&mp4.TrackBox{}); ok {
+				t.Errorf("metadataTitle() of an empty trak = %q", title)
+			}
+		})
+	}
+}
+
+func TestMetadataDataBoxAllLanguages(t *testing.T) {
+	// a value for all languages is written with the language code 0, which is
+	// read as a Macintosh language code rather than a packed ISO 639-2/T code
+	data := roundTripBox(t, &MetadataDataBox{DataType: MetadataTypeUTF8, Value: []byte("title")}).(*MetadataDataBox)
+	if data.Language != (language.Base{}) || string(data.Value) != "title" {
+		t.Errorf("read language %s, value %q, want the zero Base, \"title\"", data.Language, data.Value)
+	}
+}
+
+func TestPackLanguage(t *testing.T) {
+	tests := []struct {
+		lang language.Base
+		want uint16
+	}{
+		{lang: language.MustParseBase("eng"), want: 0x15c7},
+		{lang: language.MustParseBase("fr"), want: 0x1a41},
+		{lang: language.Base{}, want: 0x55c4},
+	}
+	for _, tt := range tests {
+		if got := packLanguage(tt.lang); got != tt.want {
+			t.Errorf("packLanguage(%s) = %#x, want %#x", tt.lang, got, tt.want)
+		}
+		if tt.lang != (language.Base{}) {
+			if got := unpackLanguage(tt.want); got != tt.lang {
+				t.Errorf("unpackLanguage(%#x) = %s, want %s", tt.want, got, tt.lang)
+			}
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package smoothstreaming
+
+import (
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// 12.3.3 URI metadata sample entry
+
+// Timed metadata tracks whose sample format is identified by a URI use
+// URIMetaSampleEntryBox. The URI is carried by the URIBox child, optionally
+// followed by a URIInitBox with initialization data for the format.
+type URIMetaSampleEntryBox struct {
+	mp4.SampleEntry
+}
+
+var _ mp4.Box = (*URIMetaSampleEntryBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[UrimBoxType] = func() mp4.Box { return &URIMetaSampleEntryBox{} }
+}
+
+func (b URIMetaSampleEntryBox) Mp4BoxType() mp4.BoxType {
+	return UrimBoxType
+}
+
+func (b *URIMetaSampleEntryBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.SampleEntrySize()
+	b.Size += b.Mp4BoxUpdateChildren()
+	return b.Size
+}
+
+func (b *URIMetaSampleEntryBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.SampleEntry.Mp4BoxRead(r, header); err != nil {
+		return
+	}
+	if err = b.Mp4BoxReadChildren(r, b.Size-b.SampleEntrySize()); err != nil {
+		return
+	}
+	return
+}
+
+func (b *URIMetaSampleEntryBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.SampleEntry.Mp4BoxWrite(w); err != nil {
+		return
+	}
+	if err = b.Mp4BoxWriteChildren(w); err != nil {
+		return
+	}
+	return
+}
+
+// Box Type: 'uri '
+// Container: URI Meta Sample Entry ('urim')
+
+// The URIBox holds the URI that identifies the format of the metadata samples.
+type URIBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	TheURI mp4.NullTerminatedString
+}
+
+var _ mp4.Box = (*URIBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[UriBoxType] = func() mp4.Box { return &URIBox{} }
+}
+
+func (b URIBox) Mp4BoxType() mp4.BoxType {
+	return UriBoxType
+}
+
+func (b *URIBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += b.TheURI.Size() // string theURI;
+	return b.Size
+}
+
+func (b *URIBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if err = readNullTerminatedString(io.LimitReader(r, int64(b.Size-b.HeaderSize()-4)), &b.TheURI); err != nil {
+		return
+	}
+	return
+}
+
+func (b *URIBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if err = b.TheURI.Write(w); err != nil {
+		return
+	}
+	return
+}
+
+// Box Type: 'uriI'
+// Container: URI Meta Sample Entry ('urim')
+
+// The URIInitBox holds the initialization data of the metadata format, in the
+// format identified by the URI.
+type URIInitBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	URIInitializationData []byte
+}
+
+var _ mp4.Box = (*URIInitBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[UriIBoxType] = func() mp4.Box { return &URIInitBox{} }
+}
+
+func (b URIInitBox) Mp4BoxType() mp4.BoxType {
+	return UriIBoxType
+}
+
+func (b *URIInitBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize() + 4
+	b.Size += uint32(len(b.URIInitializationData)) // unsigned int(8) uri_initialization_data[];
+	return b.Size
+}
+
+func (b *URIInitBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	b.URIInitializationData = make([]byte, b.Size-b.HeaderSize()-4)
+	if _, err = io.ReadFull(r, b.URIInitializationData); err != nil {
+		return
+	}
+	return
+}
+
+func (b *URIInitBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	if _, err = w.Write(b.URIInitializationData); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestURIMetaSampleEntryBoxRoundTrip(t *testing.T) {
+	box := &URIMetaSampleEntryBox{SampleEntry: mp4.SampleEntry{DataReferenceIndex: 1}}
+	children := []mp4.Box{
+		&URIBox{TheURI: "urn:scte:scte35:2013:bin"},
+		&URIInitBox{URIInitializationData: []byte{1, 2, 3}},
+	}
+	if err := box.Mp4BoxReplaceChildren(children); err != nil {
+		t.Fatal(err)
+	}
+	read, ok := roundTripBox(t, box).(*URIMetaSampleEntryBox)
+	if !ok {
+		t.Fatalf("read %T, want *URIMetaSampleEntryBox", read)
+	}
+	got := read.Mp4BoxChildren()
+	if len(got) != 2 {
+		t.Fatalf("got %d children, want uri and uriI", len(got))
+	}
+	if uri, ok := got[0].(*URIBox); !ok || uri.TheURI != "urn:scte:scte35:2013:bin" {
+		t.Errorf("first child %+v, want the uri box", got[0])
+	}
+	if init, ok := got[1].(*URIInitBox); !ok || !bytes.Equal(init.URIInitializationData, []byte{1, 2, 3}) {
+		t.Errorf("second child %+v, want the uriI box", got[1])
+	}
+}
+
+func TestURIBoxRoundTrip(t *testing.T) {
+	read, ok := roundTripBox(t, &URIBox{TheURI: "urn:mpeg:dash:event:2012"}).(*URIBox)
+	if !ok {
+		t.Fatalf("read %T, want *URIBox", read)
+	}
+	if read.TheURI != "urn:mpeg:dash:event:2012" || read.Size != 37 {
+		t.Errorf("read URI %q, size %d, want urn:mpeg:dash:event:2012, 37", read.TheURI, read.Size)
+	}
+}
+
+func TestURIInitBoxRoundTrip(t *testing.T) {
+	for _, data := range [][]byte{nil, {0xde, 0xad, 0xbe, 0xef}} {
+		read, ok := roundTripBox(t, &URIInitBox{URIInitializationData: data}).(*URIInitBox)
+		if !ok {
+			t.Fatalf("read %T, want *URIInitBox", read)
+		}
+		if !bytes.Equal(read.URIInitializationData, data) {
+			t.Errorf("read %x, want %x", read.URIInitializationData, data)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// VP Codec ISO Media File Format Binding 2.2 VP Codec Configuration Box
+
+// Box Type: 'vpcC'
+// Container: VP Sample Entry ('vp08' or 'vp09')
+
+// The VPCodecConfigurationBox carries the VPCodecConfigurationRecord of a VP8
+// or VP9 stream. Only version 1 of the box is supported.
+type VPCodecConfigurationBox struct {
+	mp4.FullHeader
+	mp4.NullContainer
+
+	Profile  uint8
+	Level    uint8
+	BitDepth uint8
+
+	// 0 for 4:2:0 vertical, 1 for 4:2:0 colocated with luma, 2 for 4:2:2 and
+	// 3 for 4:4:4.
+	ChromaSubsampling uint8
+
+	VideoFullRangeFlag bool
+
+	// ISO/IEC 23091-2 colour description.
+	ColourPrimaries         uint8
+	TransferCharacteristics uint8
+	MatrixCoefficients      uint8
+
+	// not used for VP8 and VP9, should be empty.
+	CodecInitializationData []byte
+}
+
+var _ mp4.Box = (*VPCodecConfigurationBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[VpcCBoxType] = func() mp4.Box { return &VPCodecConfigurationBox{} }
+	mp4.BoxRegistry[Vp09BoxType] = func() mp4.Box { return &mp4.VisualSampleEntryBox{} }
+}
+
+func (b VPCodecConfigurationBox) Mp4BoxType() mp4.BoxType {
+	return VpcCBoxType
+}
+
+func (b *VPCodecConfigurationBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Version = 1
+	b.Size = b.HeaderSize() + 4
+	b.Size += 1 // unsigned int(8) profile;
+	b.Size += 1 // unsigned int(8) level;
+	b.Size += 1 // unsigned int(4) bitDepth; unsigned int(3) chromaSubsampling; unsigned int(1) videoFullRangeFlag;
+	b.Size += 1 // unsigned int(8) colourPrimaries;
+	b.Size += 1 // unsigned int(8) transferCharacteristics;
+	b.Size += 1 // unsigned int(8) matrixCoefficients;
+	b.Size += 2 // unsigned int(16) codecIntializationDataSize;
+	b.Size += uint32(len(b.CodecInitializationData))
+	return b.Size
+}
+
+func (b *VPCodecConfigurationBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	if b.Version != 1 {
+		err = fmt.Errorf("vpcC version %d: %w", b.Version, mp4.ErrInvalidFormat)
+		return
+	}
+	data := make([]byte, b.Size-b.HeaderSize()-4)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	err = b.readRecord(data)
+	return
+}
+
+// readRecord parses the VPCodecConfigurationRecord.
+func (b *VPCodecConfigurationBox) readRecord(data []byte) (err error) {
+	if len(data) < 8 || int(binary.BigEndian.Uint16(data[6:])) != len(data)-8 {
+		err = fmt.Errorf("invalid VPCodecConfigurationRecord: %w", mp4.ErrInvalidFormat)
+		return
+	}
+	b.Profile = data[0]
+	b.Level = data[1]
+	b.BitDepth = data[2] >> 4
+	b.ChromaSubsampling = data[2] >> 1 & 0x07
+	b.VideoFullRangeFlag = data[2]&0x01 != 0
+	b.ColourPrimaries = data[3]
+	b.TransferCharacteristics = data[4]
+	b.MatrixCoefficients = data[5]
+	b.CodecInitializationData = data[8:]
+	return
+}
+
+func (b *VPCodecConfigurationBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	record := []byte{
+		b.Profile,
+		b.Level,
+		b.BitDepth<<4 | b.ChromaSubsampling<<1 | uint8(boolBit(b.VideoFullRangeFlag)),
+		b.ColourPrimaries,
+		b.TransferCharacteristics,
+		b.MatrixCoefficients,
+		byte(len(b.CodecInitializationData) >> 8), byte(len(b.CodecInitializationData)),
+	}
+	if _, err = w.Write(record); err != nil {
+		return
+	}
+	_, err = w.Write(b.CodecInitializationData)
+	return
+}
@@ -0,0 +1,56 @@
+package smoothstreaming
+
+import (
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// PIFF 1.3 5.3.1 WMA Audio Sample Entry
+
+// Box Type: 'wfex'
+// Container: WMAAudioSampleEntry ('owma')
+
+// The WaveFormatExBox carries the WAVEFORMATEX structure, including its
+// codec specific extra data, of a Windows Media Audio stream.
+type WaveFormatExBox struct {
+	mp4.Header
+	mp4.NullContainer
+
+	WaveFormatEx []byte
+}
+
+var _ mp4.Box = (*WaveFormatExBox)(nil)
+
+func init() {
+	mp4.BoxRegistry[WfexBoxType] = func() mp4.Box { return &WaveFormatExBox{} }
+	mp4.BoxRegistry[OwmaBoxType] = func() mp4.Box { return &AudioSampleEntryBox{} }
+}
+
+func (b WaveFormatExBox) Mp4BoxType() mp4.BoxType {
+	return WfexBoxType
+}
+
+func (b *WaveFormatExBox) Mp4BoxUpdate() uint32 {
+	b.Type = b.Mp4BoxType()
+	b.Size = b.HeaderSize()
+	b.Size += uint32(len(b.WaveFormatEx)) // WAVEFORMATEX waveformatex;
+	return b.Size
+}
+
+func (b *WaveFormatExBox) Mp4BoxRead(r io.Reader, header *mp4.Header) (err error) {
+	if err = b.ReadHeader(r, header); err != nil {
+		return
+	}
+	b.WaveFormatEx = make([]byte, b.Size-b.HeaderSize())
+	_, err = io.ReadFull(r, b.WaveFormatEx)
+	return
+}
+
+func (b *WaveFormatExBox) Mp4BoxWrite(w io.Writer) (err error) {
+	if err = b.WriteHeader(w); err != nil {
+		return
+	}
+	_, err = w.Write(b.WaveFormatEx)
+	return
+}
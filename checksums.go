@@ -0,0 +1,183 @@
+package smoothstreaming
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-webdl/mp4"
+)
+
+// ChecksumsExt is the extension appended to the path of the output of a track
+// to name its checksum manifest, see ChecksumManifest.
+const ChecksumsExt = ".sha256.json"
+
+// ChecksumManifest lists the SHA-256 digests of the files of the output of a
+// track, and of each fragment within them, as integrity evidence of captured
+// streams for archival pipelines. It is written as JSON next to the output,
+// named after it with the ChecksumsExt.
+type ChecksumManifest struct {
+	// the files of the output: the output file, or the init segment followed
+	// by the media segments in the SegmentedLayout.
+	Files []FileChecksum `json:"files"`
+}
+
+// FileChecksum is the digest of a file of the output of a track.
+type FileChecksum struct {
+	// the path of the file, relative to the directory of the manifest, with
+	// forward slashes.
+	Path string `json:"path"`
+
+	// the size of the file.
+	Size int64 `json:"size"`
+
+	// the hex-encoded SHA-256 digest of the file.
+	SHA256 string `json:"sha256"`
+
+	// the fragments of the file, none for a subtitle sidecar file.
+	Fragments []FragmentChecksum `json:"fragments,omitempty"`
+}
+
+// FragmentChecksum is the digest of a fragment within a file, from its first
+// box, the moof box or the styp, prft or emsg box preceding it, to the end of
+// its mdat box.
+type FragmentChecksum struct {
+	// the decode time of the fragment, in the timescale of the track.
+	Time uint64 `json:"time"`
+
+	// the offset of the fragment in the file.
+	Offset int64 `json:"offset"`
+
+	// the size of the fragment.
+	Size int64 `json:"size"`
+
+	// the hex-encoded SHA-256 digest of the fragment.
+	SHA256 string `json:"sha256"`
+}
+
+// ComputeChecksums returns the checksum manifest of the output of a track at
+// path, a file or, in the SegmentedLayout, a directory.
+func ComputeChecksums(path string) (m ChecksumManifest, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	names := []string{filepath.Base(path)}
+	if info.IsDir() {
+		if names, err = segmentFileNames(path); err != nil {
+			return
+		}
+	}
+	dir := filepath.Dir(path)
+	for _, name := range names {
+		var file FileChecksum
+		if file, err = checksumFile(filepath.Join(dir, name)); err != nil {
+			return
+		}
+		file.Path = filepath.ToSlash(name)
+		m.Files = append(m.Files, file)
+	}
+	return
+}
+
+// WriteChecksums computes the checksum manifest of the output of a track at
+// path, see ComputeChecksums, and writes it next to the output.
+func WriteChecksums(path string) (err error) {
+	m, err := ComputeChecksums(path)
+	if err != nil {
+		return
+	}
+	return writeFile(strings.TrimRight(path, `/\`)+ChecksumsExt, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(m)
+	})
+}
+
+// segmentFileNames returns the names of the files of a track written in the
+// SegmentedLayout to dir, relative to the parent of dir: the init segment
+// followed by the media segments in the order of their numbers.
+func segmentFileNames(dir string) (names []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var numbers []int
+	for _, entry := range entries {
+		if number, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), mediaSegmentExt)); err == nil && strings.HasSuffix(entry.Name(), mediaSegmentExt) {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	base := filepath.Base(dir)
+	names = append(names, filepath.Join(base, initSegmentName))
+	for _, number := range numbers {
+		names = append(names, filepath.Join(base, fmt.Sprintf("%d%s", number, mediaSegmentExt)))
+	}
+	return
+}
+
+// checksumFile returns the digests of the file at path and of its fragments.
+func checksumFile(path string) (c FileChecksum, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	h := sha256.New()
+	if c.Size, err = io.Copy(h, file); err != nil {
+		return
+	}
+	c.SHA256 = hex.EncodeToString(h.Sum(nil))
+	switch filepath.Ext(path) {
+	case ".mp4", mediaSegmentExt:
+	default:
+		// a sidecar file has no fragments
+		return
+	}
+
+	for offset := int64(0); offset < c.Size; {
+		section := io.NewSectionReader(file, offset, c.Size-offset)
+		var header *mp4.Header
+		if header, err = mp4.ReadHeader(section); err != nil {
+			return
+		}
+		switch header.Type {
+		case mp4.MoofBoxType, StypBoxType, PrftBoxType, EmsgBoxType:
+		default:
+			var n int64
+			if n, err = skipMp4Box(section, header); err != nil {
+				return
+			}
+			offset += n
+			continue
+		}
+
+		cr := &countingReader{r: bufio.NewReader(io.NewSectionReader(file, offset, c.Size-offset))}
+		var f Fragment
+		if f, err = ParseFragment(cr); err != nil {
+			err = fmt.Errorf("%s: fragment at %d: %w", path, offset, err)
+			return
+		}
+		fragment := FragmentChecksum{Offset: offset, Size: cr.n}
+		if len(f.Tracks) > 0 {
+			fragment.Time, _ = f.Tracks[0].DecodeTime()
+		}
+		h.Reset()
+		if _, err = io.Copy(h, io.NewSectionReader(file, offset, cr.n)); err != nil {
+			return
+		}
+		fragment.SHA256 = hex.EncodeToString(h.Sum(nil))
+		c.Fragments = append(c.Fragments, fragment)
+		offset += cr.n
+	}
+	return
+}
@@ -0,0 +1,114 @@
+package smoothstreaming
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sha256Hex returns the hex-encoded SHA-256 digest of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloaderChecksums(t *testing.T) {
+	fragment := testFragment(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/movie.ism/Manifest" {
+			io.WriteString(w, testManifest)
+			return
+		}
+		w.Write(fragment)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		layout    OutputLayout
+		wantFiles []string
+	}{
+		{name: "single file", layout: SingleFileLayout, wantFiles: []string{"video_1000.mp4"}},
+		{name: "segmented", layout: SegmentedLayout, wantFiles: []string{"video_1000/init.mp4", "video_1000/1.m4s", "video_1000/2.m4s", "video_1000/3.m4s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tracks, err := Downloader{OutputDir: dir, Layout: tt.layout, Checksums: true}.Download(context.Background(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(tracks[0].Path + ChecksumsExt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m ChecksumManifest
+			if err = json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Files) != len(tt.wantFiles) {
+				t.Fatalf("got %d files, want %d", len(m.Files), len(tt.wantFiles))
+			}
+			var fragments []FragmentChecksum
+			for i, file := range m.Files {
+				if file.Path != tt.wantFiles[i] {
+					t.Errorf("file %d is %s, want %s", i, file.Path, tt.wantFiles[i])
+				}
+				data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Path)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if file.Size != int64(len(data)) || file.SHA256 != sha256Hex(data) {
+					t.Errorf("file %s of size %d, digest %s, want %d, %s", file.Path, file.Size, file.SHA256, len(data), sha256Hex(data))
+				}
+				for _, fragment := range file.Fragments {
+					if got := sha256Hex(data[fragment.Offset : fragment.Offset+fragment.Size]); fragment.SHA256 != got {
+						t.Errorf("fragment at %d of %s of digest %s, want %s", fragment.Offset, file.Path, fragment.SHA256, got)
+					}
+				}
+				fragments = append(fragments, file.Fragments...)
+			}
+			if len(fragments) != 3 {
+				t.Fatalf("got %d fragments, want 3", len(fragments))
+			}
+			for i, fragment := range fragments {
+				if fragment.Time != uint64(i)*20000000 {
+					t.Errorf("fragment %d at time %d, want %d", i, fragment.Time, i*20000000)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloaderChecksumsDisabled(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "video_1000.mp4")
+	if err := os.WriteFile(path, testFragment(t, nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Downloader{}).writeChecksums([]DownloadedTrack{{Path: path}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ChecksumsExt); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("checksum manifest written while disabled: %v", err)
+	}
+}
+
+func TestComputeChecksumsMissing(t *testing.T) {
+	if _, err := ComputeChecksums(filepath.Join(t.TempDir(), "missing.mp4")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ComputeChecksums() error = %v, want %v", err, fs.ErrNotExist)
+	}
+}
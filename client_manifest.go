@@ -0,0 +1,263 @@
+package smoothstreaming
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/go-webdl/mp4"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+)
+
+// FragmentedTrack is a track of a fragmented MP4 file, e.g. an ismv or isma
+// file or a CMAF track file, with the timeline of its fragments.
+type FragmentedTrack struct {
+	// the path of the file holding the track.
+	Path string
+
+	// the configuration of the track, from the moov box of the file.
+	Moov MoovProcessor
+
+	// the fragments of the track in the timescale of the track, in the order
+	// of the file.
+	Fragments []TimelineFragment
+
+	// the average bitrate of the track, in bits per second: the one of its
+	// btrt box, or the size of its samples over their duration.
+	Bitrate uint32
+}
+
+// ScanTrackFile reads the moov box and the moof boxes of the fragmented MP4
+// file at path, and returns its tracks with the timeline of their fragments.
+// A track fragment without a tfdt box nor a TfxdBox follows the previous one.
+func ScanTrackFile(path string) (tracks []FragmentedTrack, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+	size := info.Size()
+	moov, err := ParseInitSegment(bufio.NewReader(file))
+	if err != nil {
+		err = fmt.Errorf("%s: %w", path, err)
+		return
+	}
+	index := make(map[uint32]int)
+	sizes := make([]uint64, len(moov.Tracks))
+	for i, p := range moov.Tracks {
+		index[p.TrackID] = i
+		tracks = append(tracks, FragmentedTrack{Path: path, Moov: p})
+	}
+
+	for offset := int64(0); offset < size; {
+		section := io.NewSectionReader(file, offset, size-offset)
+		var header *mp4.Header
+		if header, err = mp4.ReadHeader(section); err != nil {
+			return
+		}
+		switch header.Type {
+		case mp4.MoofBoxType, StypBoxType, PrftBoxType, EmsgBoxType:
+		default:
+			var n int64
+			if n, err = skipMp4Box(section, header); err != nil {
+				return
+			}
+			offset += n
+			continue
+		}
+
+		cr := &countingReader{r: bufio.NewReader(io.NewSectionReader(file, offset, size-offset))}
+		var f Fragment
+		if f, err = ParseFragment(cr); err != nil {
+			err = fmt.Errorf("%s: fragment at %d: %w", path, offset, err)
+			return
+		}
+		offset += cr.n
+		for _, t := range f.Tracks {
+			i, ok := index[t.Header.TrackID]
+			if !ok {
+				err = fmt.Errorf("%s: fragment at %d of unknown track %d: %w", path, offset, t.Header.TrackID, ErrInvalidParam)
+				return
+			}
+			var samples []fragmentSample
+			if samples, err = t.samples(tracks[i].Moov); err != nil {
+				err = fmt.Errorf("%s: fragment at %d: %w", path, offset, err)
+				return
+			}
+			fragment := TimelineFragment{Number: uint32(len(tracks[i].Fragments))}
+			if n := len(tracks[i].Fragments); n > 0 {
+				fragment.Time = tracks[i].Fragments[n-1].End()
+			}
+			if decodeTime, ok := t.DecodeTime(); ok {
+				fragment.Time = decodeTime
+			}
+			for _, sample := range samples {
+				fragment.Duration += uint64(sample.duration)
+				sizes[i] += uint64(sample.size)
+			}
+			tracks[i].Fragments = append(tracks[i].Fragments, fragment)
+		}
+	}
+
+	for i := range tracks {
+		t := &tracks[i]
+		if t.Bitrate = t.Moov.Bitrate; t.Bitrate > 0 || len(t.Fragments) == 0 {
+			continue
+		}
+		first, last := t.Fragments[0], t.Fragments[len(t.Fragments)-1]
+		if duration := last.End() - first.Time; duration > 0 {
+			t.Bitrate = uint32(sizes[i] * 8 * t.Moov.Timescale / duration)
+		}
+	}
+	return
+}
+
+// NewClientManifest returns the client manifest of an on-demand presentation
+// of the given tracks, e.g. scanned from its ismv and isma files by
+// ScanTrackFile. The tracks are grouped into streams by type, language and,
+// for audio, codec; each stream is named after its type followed by its
+// language, numbered from 2 if several streams share them, unless its tracks
+// have a stream name. The tracks of a stream, ordered by bitrate, must share
+// the timescale and the timeline of their fragments, and their bitrates are
+// made unique. The url pattern of each stream is the one of IIS,
+// QualityLevels({bitrate})/Fragments(name={start time}), and the protection
+// systems of the protected tracks become the Protection element.
+func NewClientManifest(tracks []FragmentedTrack) (m *SmoothStreamingMedia, err error) {
+	m, _, err = newClientManifest(tracks)
+	return
+}
+
+// newClientManifest returns the client manifest of the tracks, see
+// NewClientManifest, along with the index in tracks of each of its tracks.
+func newClientManifest(tracks []FragmentedTrack) (m *SmoothStreamingMedia, sources map[*Track]int, err error) {
+	type stream struct {
+		key     string
+		indexes []int
+	}
+	var streams []*stream
+	for i, t := range tracks {
+		if t.Moov.StreamType == "" {
+			err = fmt.Errorf("%s: track %d is not video, audio nor text: %w", t.Path, t.Moov.TrackID, ErrInvalidParam)
+			return
+		}
+		key := fmt.Sprintf("%s/%s/%s", t.Moov.StreamType, t.Moov.Language, t.Moov.StreamName)
+		if t.Moov.StreamType == AudioStream {
+			key += "/" + string(t.Moov.Codec[:])
+		}
+		var s *stream
+		for _, candidate := range streams {
+			if candidate.key == key {
+				s = candidate
+			}
+		}
+		if s == nil {
+			s = &stream{key: key}
+			streams = append(streams, s)
+		}
+		s.indexes = append(s.indexes, i)
+	}
+
+	m = &SmoothStreamingMedia{MajorVersion: 2, MinorVersion: 2}
+	sources = make(map[*Track]int)
+	var systems []ProtectionSystem
+	count := make(map[string]int)
+	for _, stream := range streams {
+		indexes := stream.indexes
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return tracks[indexes[i]].Bitrate < tracks[indexes[j]].Bitrate
+		})
+		first := tracks[indexes[0]]
+		name := first.Moov.streamName()
+		if count[name]++; count[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, count[name])
+		}
+		pattern := smoothURLPattern(name)
+		timescale := first.Moov.Timescale
+		s := &StreamIndex{Type: first.Moov.StreamType, Name: &name, URL: &pattern, TimeScale: &timescale}
+		if lang := first.Moov.Language; lang != (language.Base{}) {
+			code := lang.String()
+			s.Language = &code
+		}
+		if s.Type == TextStream {
+			subtype := "SUBT"
+			s.Subtype = &subtype
+		}
+		s.Fragments = smoothFragments(first.Fragments)
+		chunks := uint32(len(first.Fragments))
+		s.NumberOfFragments = &chunks
+
+		var bitrate uint32
+		for i, index := range indexes {
+			t := tracks[index]
+			if t.Moov.Timescale != timescale || !sameTimeline(t.Fragments, first.Fragments) {
+				err = fmt.Errorf("%s: fragments of track %d are not aligned with those of %s: %w", t.Path, t.Moov.TrackID, first.Path, ErrInvalidParam)
+				m, sources = nil, nil
+				return
+			}
+			if i > 0 && t.Bitrate <= bitrate {
+				t.Bitrate = bitrate + 1
+			}
+			bitrate = t.Bitrate
+			var track *Track
+			if track, err = smoothTrack(uint32(i), t.Bitrate, t.Moov); err != nil {
+				err = fmt.Errorf("%s: track %d: %w", t.Path, t.Moov.TrackID, err)
+				m, sources = nil, nil
+				return
+			}
+			s.addTrack(track)
+			sources[track] = index
+			if t.Moov.Protected {
+				for _, system := range t.Moov.protectionSystems() {
+					if system.SystemID != uuid.Nil && !containsProtectionSystem(systems, system.SystemID) {
+						systems = append(systems, system)
+					}
+				}
+			}
+		}
+		m.Streams = append(m.Streams, s)
+
+		if n := len(first.Fragments); n > 0 {
+			// the duration of the presentation is the end of its longest stream
+			if end := rescaleTime(first.Fragments[n-1].End(), timescale, DefaultTimeScale); end > m.Duration {
+				m.Duration = end
+			}
+		}
+	}
+	m.Protection = smoothProtection(systems)
+	return
+}
+
+// streamName returns the name of the stream of the track: its StreamName, or
+// its type followed by its language.
+func (p MoovProcessor) streamName() string {
+	if p.StreamName != "" {
+		return p.StreamName
+	}
+	name := string(p.StreamType)
+	if p.Language != (language.Base{}) {
+		name += "_" + p.Language.String()
+	}
+	return name
+}
+
+// sameTimeline reports whether the fragments a start at the times of the
+// fragments b.
+func sameTimeline(a, b []TimelineFragment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Time != b[i].Time {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+	"golang.org/x/text/language"
+)
+
+// writeTestTrackFile writes a fragmented MP4 file of the track p with a
+// testFragment at each of the given times, without a TfxdBox for the times
+// that are 0 but the first.
+func writeTestTrackFile(t *testing.T, path string, p MoovProcessor, times ...uint64) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err = p.WriteInitSegment(file); err != nil {
+		t.Fatal(err)
+	}
+	for i, time := range times {
+		fragment := testFragment(t, nil)
+		if i == 0 || time > 0 {
+			fragment = withTfxd(t, fragment, time)
+		}
+		if _, err = file.Write(fragment); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanTrackFile(t *testing.T) {
+	dir := t.TempDir()
+	p := MoovProcessor{TrackID: 1, Timescale: 10000000, StreamType: VideoStream, Codec: mp4.Avc1FourCC, Width: 1280, Height: 720, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+	path := filepath.Join(dir, "video.ismv")
+	writeTestTrackFile(t, path, p, 10000, 0, 20000)
+	tracks, err := ScanTrackFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 || tracks[0].Path != path || tracks[0].Moov.TrackID != 1 || tracks[0].Moov.Codec != mp4.Avc1FourCC {
+		t.Fatalf("tracks %+v, want the video track", tracks)
+	}
+	// the fragment without a TfxdBox follows the previous one
+	want := []TimelineFragment{{Number: 0, Time: 10000, Duration: 2000}, {Number: 1, Time: 12000, Duration: 2000}, {Number: 2, Time: 20000, Duration: 2000}}
+	if len(tracks[0].Fragments) != len(want) {
+		t.Fatalf("fragments %+v, want %+v", tracks[0].Fragments, want)
+	}
+	for i, f := range tracks[0].Fragments {
+		if f != want[i] {
+			t.Errorf("fragment %d = %+v, want %+v", i, f, want[i])
+		}
+	}
+	// 48 bytes of samples over 12000 units of the timescale
+	if tracks[0].Bitrate != 320000 {
+		t.Errorf("bitrate %d, want 320000", tracks[0].Bitrate)
+	}
+
+	other := p
+	other.TrackID = 2
+	writeTestTrackFile(t, path, other, 0)
+	if _, err = ScanTrackFile(path); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ScanTrackFile() of a fragment of an unknown track error = %v, want %v", err, ErrInvalidParam)
+	}
+	if _, err = ScanTrackFile(filepath.Join(dir, "missing.ismv")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ScanTrackFile() of a missing file error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+// testFragmentedTracks returns a video track at 500 and 1000 bits per second
+// and an English audio track, of three fragments of two seconds.
+func testFragmentedTracks(t *testing.T) []FragmentedTrack {
+	video := MoovProcessor{TrackID: 1, Timescale: 10000000, StreamType: VideoStream, Codec: mp4.Avc1FourCC, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+	audio := MoovProcessor{TrackID: 1, Timescale: 48000, StreamType: AudioStream, Codec: Mp4aFourCC, SamplingRate: 48000, Channels: 2,
+		CodecPrivateData: decodeHex(t, testAACCodecPrivateData), Language: language.MustParseBase("en")}
+	timeline := func(timescale uint64) (fragments []TimelineFragment) {
+		for i := uint64(0); i < 3; i++ {
+			fragments = append(fragments, TimelineFragment{Number: uint32(i), Time: 2 * i * timescale, Duration: 2 * timescale})
+		}
+		return
+	}
+	high, low := video, video
+	high.Width, high.Height = 1280, 720
+	low.Width, low.Height = 640, 360
+	return []FragmentedTrack{
+		{Path: "video_1000.ismv", Moov: high, Fragments: timeline(10000000), Bitrate: 1000},
+		{Path: "audio.isma", Moov: audio, Fragments: timeline(48000), Bitrate: 128000},
+		{Path: "video_500.ismv", Moov: low, Fragments: timeline(10000000), Bitrate: 500},
+	}
+}
+
+func TestNewClientManifest(t *testing.T) {
+	m, err := NewClientManifest(testFragmentedTracks(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Duration != 60000000 || m.Protection != nil || len(m.Streams) != 2 {
+		t.Fatalf("manifest of duration %d, protection %v, %d streams", m.Duration, m.Protection, len(m.Streams))
+	}
+
+	video := m.Streams[0]
+	if *video.Name != "video" || *video.URL != "QualityLevels({bitrate})/Fragments(video={start time})" || *video.TimeScale != 10000000 || *video.NumberOfTracks != 2 {
+		t.Errorf("video stream %+v", video)
+	}
+	if *video.MaxWidth != 1280 || *video.MaxHeight != 720 || len(video.Fragments) != 1 || *video.Fragments[0].Repeat != 3 {
+		t.Errorf("video stream of picture size %dx%d, c elements %d", *video.MaxWidth, *video.MaxHeight, len(video.Fragments))
+	}
+	// the tracks are ordered by bitrate
+	for i, want := range []uint32{500, 1000} {
+		if tr := video.Tracks[i]; tr.Index != uint32(i) || tr.Bitrate != want || *tr.FourCC != "H264" {
+			t.Errorf("video track %d %+v, want bitrate %d", i, tr, want)
+		}
+	}
+
+	audio := m.Streams[1]
+	if *audio.Name != "audio_en" || *audio.Language != "en" || *audio.TimeScale != 48000 || *audio.NumberOfFragments != 3 {
+		t.Errorf("audio stream %+v", audio)
+	}
+	if tr := audio.Tracks[0]; tr.Bitrate != 128000 || *tr.FourCC != "AACL" || *tr.SamplingRate != 48000 {
+		t.Errorf("audio track %+v", tr)
+	}
+
+	data, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseManifest(data); err != nil {
+		t.Errorf("ParseManifest() of the client manifest error = %v", err)
+	}
+}
+
+func TestNewClientManifestStreams(t *testing.T) {
+	tracks := testFragmentedTracks(t)
+	// the bitrates are made unique
+	tracks[2].Bitrate = 1000
+	// a text track and a second English audio track of another codec
+	text := tracks[1]
+	text.Moov = MoovProcessor{TrackID: 1, Timescale: 48000, StreamType: TextStream, Codec: StppFourCC, StreamName: "captions"}
+	commentary := tracks[1]
+	commentary.Moov.Codec = Ec3FourCC
+	pro := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(testWRMHeader40)}}}.Bytes()
+	tracks[0].Moov.Protected, tracks[0].Moov.SystemID, tracks[0].Moov.ProtectionInitData = true, PlayReadySystemID, pro
+	tracks = append(tracks, text, commentary)
+
+	m, err := NewClientManifest(tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Streams) != 4 {
+		t.Fatalf("got %d streams, want 4", len(m.Streams))
+	}
+	if b := m.Streams[0].Tracks; b[0].Bitrate != 1000 || b[1].Bitrate != 1001 {
+		t.Errorf("video bitrates %d and %d, want 1000 and 1001", b[0].Bitrate, b[1].Bitrate)
+	}
+	if s := m.Streams[2]; *s.Name != "captions" || s.Type != TextStream || *s.Subtype != "SUBT" {
+		t.Errorf("text stream %+v", s)
+	}
+	if s := m.Streams[3]; *s.Name != "audio_en_2" || *s.Tracks[0].FourCC != "EC-3" {
+		t.Errorf("second audio stream %+v", s)
+	}
+	if m.Protection == nil || len(m.Protection.ProtectionHeaders) != 1 || m.Protection.ProtectionHeaders[0].SystemID != PlayReadySystemID {
+		t.Errorf("protection %+v, want the PlayReady header", m.Protection)
+	}
+}
+
+func TestNewClientManifestInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(tracks []FragmentedTrack)
+	}{
+		{name: "stream type", modify: func(tracks []FragmentedTrack) { tracks[1].Moov.StreamType = "" }},
+		{name: "timescale", modify: func(tracks []FragmentedTrack) { tracks[2].Moov.Timescale = 1000 }},
+		{name: "timeline", modify: func(tracks []FragmentedTrack) { tracks[2].Fragments = tracks[2].Fragments[1:] }},
+		{name: "codec", modify: func(tracks []FragmentedTrack) { tracks[2].Moov.Codec = mp4.FourCC{} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks := testFragmentedTracks(t)
+			tt.modify(tracks)
+			m, err := NewClientManifest(tracks)
+			if !errors.Is(err, ErrInvalidParam) {
+				t.Fatalf("NewClientManifest() error = %v, want %v", err, ErrInvalidParam)
+			}
+			if m != nil {
+				t.Errorf("NewClientManifest() = %+v, want nil", m)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+// CMAFChunker splits fragments into CMAF chunks, ISO/IEC 23000-19 7.3.2.3:
+// moof and mdat pairs of a few samples each, which low latency DASH and HLS
+// origins forward to players as soon as they are produced rather than once
+// the whole segment is. The chunks of a fragment, written in order, form the
+// CMAF segment.
+type CMAFChunker struct {
+	// the number of samples of each chunk, 1 if 0. The last chunk of a
+	// fragment holds the remaining samples.
+	SamplesPerChunk uint32
+
+	// ProducerReferenceTime precedes each chunk by a prft box relating its
+	// decode time to the wall-clock time of its production, taken from the
+	// TfxdBox of the fragment, or its decode time if it has none.
+	ProducerReferenceTime bool
+
+	// the wall-clock time of the time 0 of the fragments, the Unix epoch of
+	// the absolute times of live Smooth Streaming encoders if zero.
+	ProducerTimeOrigin time.Time
+}
+
+// Split returns the chunks of a fragment of the track p, which provides the
+// trex defaults of its samples and its timescale. The fragment must have a
+// single track fragment, as Smooth Streaming fragments do, and a decode time,
+// see FragmentRewriter. The first chunk carries the styp and emsg boxes of
+// the fragment. Each chunk declares its decode time in a tfdt box and the
+// values of all its samples in its track run; the sample encryption data of
+// the fragment is split along with its samples, while its sample groups are
+// not carried over. The chunks are numbered from the sequence number of the
+// fragment, see FragmentSequencer.
+func (c CMAFChunker) Split(f Fragment, p MoovProcessor) (chunks []Fragment, err error) {
+	if len(f.Tracks) != 1 {
+		err = fmt.Errorf("chunking a fragment of %d tracks: %w", len(f.Tracks), ErrInvalidParam)
+		return
+	}
+	t := f.Tracks[0]
+	samples, err := t.samples(p)
+	if err != nil {
+		return
+	}
+	decodeTime, ok := t.DecodeTime()
+	if !ok {
+		err = fmt.Errorf("track %d fragment has no decode time: %w", t.Header.TrackID, ErrInvalidParam)
+		return
+	}
+	var entries []mp4.SampleEncryptionSampleEntry
+	if t.SampleEncryption != nil {
+		entries = t.SampleEncryption.Samples
+		if len(entries) != len(samples) {
+			err = fmt.Errorf("track %d has %d samples but sample encryption data for %d: %w", t.Header.TrackID, len(samples), len(entries), ErrInvalidParam)
+			return
+		}
+	}
+	producerTime := decodeTime
+	if t.Tfxd != nil {
+		producerTime = t.Tfxd.FragmentAbsoluteTime
+	}
+	size := int(c.SamplesPerChunk)
+	if size == 0 {
+		size = 1
+	}
+
+	for start := 0; start < len(samples); start += size {
+		end := start + size
+		if end > len(samples) {
+			end = len(samples)
+		}
+		var chunk Fragment
+		if chunk, err = createChunk(f, samples[start:end], decodeTime, f.SequenceNumber()+uint32(len(chunks))); err != nil {
+			return
+		}
+		if entries != nil {
+			if err = chunk.addSampleEncryption(t.SampleEncryption, entries[start:end]); err != nil {
+				return
+			}
+		}
+		if start == 0 {
+			chunk.SegmentType, chunk.Events = f.SegmentType, f.Events
+		}
+		if c.ProducerReferenceTime {
+			chunk.ProducerReferenceTime = &ProducerReferenceTimeBox{
+				ReferenceTrackID: t.Header.TrackID,
+				NTPTimestamp:     ntpTimestamp(c.producerTime(producerTime, p.Timescale)),
+				MediaTime:        decodeTime,
+			}
+		}
+		chunks = append(chunks, chunk)
+		for _, sample := range samples[start:end] {
+			decodeTime += uint64(sample.duration)
+			producerTime += uint64(sample.duration)
+		}
+	}
+	return
+}
+
+// createChunk returns a fragment of the samples of the track fragment of f,
+// decoded from decodeTime.
+func createChunk(f Fragment, samples []fragmentSample, decodeTime uint64, sequenceNumber uint32) (chunk Fragment, err error) {
+	t := f.Tracks[0]
+	tfhd := &mp4.TrackFragmentHeaderBox{}
+	*tfhd = *t.Header
+	trun := &mp4.TrackRunBox{SampleCount: uint32(len(samples))}
+	flags := mp4.FLAG_TRUN_DATA_OFFSET | mp4.FLAG_TRUN_SAMPLE_DURATION | mp4.FLAG_TRUN_SAMPLE_SIZE | mp4.FLAG_TRUN_SAMPLE_FLAGS
+	for _, sample := range samples {
+		trun.Samples = append(trun.Samples, mp4.TrackRunSampleEntry{
+			SampleDuration:              sample.duration,
+			SampleSize:                  uint32(sample.size),
+			SampleFlags:                 sample.flags,
+			SampleCompositionTimeOffset: sample.compositionTimeOffset,
+		})
+		if sample.compositionTimeOffset != 0 {
+			flags |= mp4.FLAG_TRUN_SAMPLE_COMPOSITION_TIME_OFFSET
+		}
+		if sample.compositionTimeOffset < 0 {
+			trun.Version = 1
+		}
+		chunk.Data = append(chunk.Data, f.Data[sample.offset:sample.offset+sample.size]...)
+	}
+	trun.Mp4BoxSetFlags(flags)
+
+	traf := &mp4.TrackFragmentBox{}
+	if err = traf.Mp4BoxReplaceChildren([]mp4.Box{tfhd, &TrackFragmentDecodeTimeBox{BaseMediaDecodeTime: decodeTime}, trun}); err != nil {
+		return
+	}
+	moof := &mp4.MovieFragmentBox{}
+	if err = moof.Mp4BoxReplaceChildren([]mp4.Box{&mp4.MovieFragmentHeaderBox{SequenceNumber: sequenceNumber}, traf}); err != nil {
+		return
+	}
+	chunk.Moof = moof
+	if err = chunk.parseMoofMp4Box(); err != nil {
+		return
+	}
+	chunk.Tracks[0].runOffsets = []int64{0}
+	return
+}
+
+// addSampleEncryption adds to the track fragment of the chunk a copy of the
+// sample encryption box senc with the entries of the samples of the chunk,
+// located by saiz and saio boxes unless it is a PIFF box.
+func (f *Fragment) addSampleEncryption(senc *SampleEncryptionBox, entries []mp4.SampleEncryptionSampleEntry) (err error) {
+	t := &f.Tracks[0]
+	t.SampleEncryption = &SampleEncryptionBox{}
+	*t.SampleEncryption = *senc
+	t.SampleEncryption.Samples = entries
+	if err = t.Traf.Mp4BoxReplaceChildren(append(t.Traf.Mp4BoxChildren(), t.SampleEncryption)); err != nil {
+		return
+	}
+	if !senc.IsPIFF() {
+		err = t.locateSampleEncryption()
+	}
+	return
+}
+
+// producerTime returns the wall-clock time of a time of the fragments in the
+// given timescale.
+func (c CMAFChunker) producerTime(t, timescale uint64) time.Time {
+	origin := c.ProducerTimeOrigin
+	if origin.IsZero() {
+		origin = time.Unix(0, 0)
+	}
+	if timescale == 0 {
+		return origin
+	}
+	seconds, remainder := t/timescale, t%timescale
+	return origin.Add(time.Duration(seconds)*time.Second + time.Duration(rescaleTime(remainder, timescale, uint64(time.Second))))
+}
+
+// ntpEpochOffset is the number of seconds from the NTP epoch, 1900, to the
+// Unix epoch.
+const ntpEpochOffset = 2208988800
+
+// ntpTimestamp returns the 64-bit NTP timestamp of t.
+func ntpTimestamp(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := rescaleTime(uint64(t.Nanosecond()), uint64(time.Second), 1<<32)
+	return seconds<<32 | fraction
+}
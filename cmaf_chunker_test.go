@@ -0,0 +1,139 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestCMAFChunker(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, testSampleEncryptionBox(8, false)), 10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(f.Data, "0123456789abcdef")
+	f.SegmentType = &SegmentTypeBox{}
+	f.SegmentType.MajorBrand = mp4.FourCC{'c', 'm', 'f', 's'}
+
+	c := CMAFChunker{ProducerReferenceTime: true}
+	chunks, err := c.Split(f, MoovProcessor{Timescale: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	tests := []struct {
+		data           string
+		decodeTime     uint64
+		sequenceNumber uint32
+		ivByte         byte
+	}{
+		{data: "0123456789", decodeTime: 10000, sequenceNumber: 7, ivByte: 0},
+		{data: "abcdef", decodeTime: 11000, sequenceNumber: 8, ivByte: 1},
+	}
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		if _, err = chunks[i].WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		chunk, err := ParseFragment(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		track := chunk.Tracks[0]
+		if string(chunk.Data) != tt.data || track.SampleCount() != 1 || chunk.SequenceNumber() != tt.sequenceNumber {
+			t.Errorf("chunk %d = %q of %d samples numbered %d, want %q of 1 sample numbered %d",
+				i, chunk.Data, track.SampleCount(), chunk.SequenceNumber(), tt.data, tt.sequenceNumber)
+		}
+		if decodeTime, _ := track.DecodeTime(); decodeTime != tt.decodeTime {
+			t.Errorf("chunk %d decoded at %d, want %d", i, decodeTime, tt.decodeTime)
+		}
+		senc := track.SampleEncryption
+		if senc == nil || len(senc.Samples) != 1 || !bytes.Equal(senc.Samples[0].InitializationVector, bytes.Repeat([]byte{tt.ivByte}, 8)) || track.AuxInfoOffsets == nil {
+			t.Errorf("chunk %d senc %+v, saio %v, want the IV of its sample located by saio", i, senc, track.AuxInfoOffsets)
+		}
+		prft := chunk.ProducerReferenceTime
+		wantNTP := uint64(ntpEpochOffset+tt.decodeTime/1000) << 32
+		if prft == nil || prft.ReferenceTrackID != 1 || prft.MediaTime != tt.decodeTime || prft.NTPTimestamp != wantNTP {
+			t.Errorf("chunk %d prft %+v, want track 1 at %d produced at %#x", i, prft, tt.decodeTime, wantNTP)
+		}
+		if (chunk.SegmentType != nil) != (i == 0) {
+			t.Errorf("chunk %d styp box %+v, want one in the first chunk only", i, chunk.SegmentType)
+		}
+	}
+}
+
+func TestCMAFChunkerSamplesPerChunk(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), 10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := CMAFChunker{SamplesPerChunk: 5}.Split(f, MoovProcessor{Timescale: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || chunks[0].Tracks[0].SampleCount() != 2 || len(chunks[0].Data) != 16 {
+		t.Errorf("got %d chunks, want 1 of the 2 samples", len(chunks))
+	}
+	if chunks[0].ProducerReferenceTime != nil || chunks[0].Tracks[0].SampleEncryption != nil {
+		t.Error("chunk has a prft or senc box")
+	}
+}
+
+func TestCMAFChunkerErrors(t *testing.T) {
+	senc := testSampleEncryptionBox(8, false)
+	senc.Samples = senc.Samples[:1]
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "no decode time", data: testFragment(t, nil)},
+		{name: "sample encryption mismatch", data: withTfxd(t, testFragment(t, senc), 10000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = (CMAFChunker{}).Split(f, MoovProcessor{Timescale: 1000}); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("Split() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+	if _, err := (CMAFChunker{}).Split(Fragment{}, MoovProcessor{}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Split() of a fragment without tracks error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestCMAFChunkerProducerTime(t *testing.T) {
+	origin := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		c         CMAFChunker
+		time      uint64
+		timescale uint64
+		want      time.Time
+	}{
+		{name: "Unix epoch", time: 15000000, timescale: 10000000, want: time.Unix(1, 500000000)},
+		{name: "origin", c: CMAFChunker{ProducerTimeOrigin: origin}, time: 90000 * 3, timescale: 90000, want: origin.Add(3 * time.Second)},
+		{name: "no timescale", c: CMAFChunker{ProducerTimeOrigin: origin}, time: 5, want: origin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.producerTime(tt.time, tt.timescale); !got.Equal(tt.want) {
+				t.Errorf("producerTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNTPTimestamp(t *testing.T) {
+	if got, want := ntpTimestamp(time.Unix(1, 500000000)), uint64(ntpEpochOffset+1)<<32|0x80000000; got != want {
+		t.Errorf("ntpTimestamp() = %#x, want %#x", got, want)
+	}
+}
@@ -0,0 +1,221 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-webdl/media-codec/dovi"
+	"github.com/go-webdl/mp4"
+)
+
+// SampleEntryCodec returns the FourCC of the MP4 sample entry that carries a
+// track with the given manifest FourCC, suitable for MoovProcessor.Codec.
+func SampleEntryCodec(fourCC string) (codec mp4.FourCC, err error) {
+	switch strings.ToUpper(fourCC) {
+	case "H264", "AVC1", "DAVC":
+		codec = mp4.Avc1FourCC
+	case "HVC1", "HEVC":
+		codec = mp4.Hvc1FourCC
+	case "HEV1":
+		codec = mp4.Hev1FourCC
+	case "DVH1":
+		codec = mp4.Dvh1FourCC
+	case "DVHE":
+		codec = mp4.DvheFourCC
+	case "AV01", "AV1":
+		codec = Av01FourCC
+	case "VP09", "VP90", "VP9":
+		codec = Vp09FourCC
+	case "WVC1":
+		codec = Vc1FourCC
+	case "AACL", "AACH", "AACP", "MP4A":
+		codec = Mp4aFourCC
+	case "WMAP", "WMA2":
+		codec = OwmaFourCC
+	case "AC-3", "AC3":
+		codec = Ac3FourCC
+	case "EC-3", "EC3":
+		codec = Ec3FourCC
+	case "DTSC", "DTS":
+		codec = DtscFourCC
+	case "DTSH":
+		codec = DtshFourCC
+	case "DTSL":
+		codec = DtslFourCC
+	case "DTSE":
+		codec = DtseFourCC
+	case "TTML", "DFXP":
+		codec = StppFourCC
+	case "METT":
+		codec = MettFourCC
+	case "URIM":
+		codec = UrimFourCC
+	default:
+		err = fmt.Errorf("FourCC %q: %w", fourCC, ErrUnknownCodec)
+	}
+	return
+}
+
+// ManifestFourCC returns the FourCC of the track in a Smooth Streaming
+// manifest, the reverse of SampleEntryCodec: e.g. "H264" for avc1 and, for
+// AAC, "AACL", "AACH" or "AACP" by the audio object type of its
+// AudioSpecificConfig.
+func (p MoovProcessor) ManifestFourCC() (fourCC string, err error) {
+	switch p.Codec {
+	case mp4.Avc1FourCC, mp4.FourCC{'a', 'v', 'c', '3'}:
+		fourCC = "H264"
+	case Mp4aFourCC:
+		fourCC = "AACL"
+		var config []byte
+		if config, err = p.CreateAudioSpecificConfig(); err != nil {
+			return
+		}
+		var asc AudioSpecificConfig
+		if asc, err = ParseAudioSpecificConfig(config); err != nil {
+			return
+		}
+		if asc.PSPresent || asc.AudioObjectType == AudioObjectTypePS {
+			fourCC = "AACP"
+		} else if asc.SBRPresent || asc.AudioObjectType == AudioObjectTypeSBR {
+			fourCC = "AACH"
+		}
+	case Vc1FourCC:
+		fourCC = "WVC1"
+	case OwmaFourCC:
+		fourCC = "WMAP"
+	case StppFourCC:
+		fourCC = "TTML"
+	case mp4.FourCC{}:
+		err = fmt.Errorf("track has no codec: %w", ErrInvalidParam)
+	default:
+		// the sample entry type, e.g. HVC1, AV01 or EC-3
+		fourCC = strings.ToUpper(string(p.Codec[:]))
+	}
+	return
+}
+
+// CodecString returns the RFC 6381 codecs parameter of the track, e.g.
+// "avc1.64001F", "hvc1.2.4.L123.B0", "mp4a.40.2" or "ec-3", as used in DASH
+// manifests, HLS playlists and MSE. Codecs without a registered parameter
+// format are identified by their sample entry FourCC alone.
+func (p MoovProcessor) CodecString() (codecs string, err error) {
+	switch p.Codec {
+	case mp4.Avc1FourCC:
+		var info TrackInfo
+		if info, err = p.TrackInfo(); err != nil {
+			return
+		}
+		codecs = fmt.Sprintf("avc1.%02X%02X%02X", info.Profile, info.ProfileCompatibility, info.Level)
+	case mp4.Hvc1FourCC, mp4.Hev1FourCC:
+		var info TrackInfo
+		if info, err = p.TrackInfo(); err != nil {
+			return
+		}
+		codecs = hevcCodecString(p.Codec, info)
+	case mp4.Dvh1FourCC, mp4.DvheFourCC:
+		var config *dovi.DOVIDecoderConfigurationRecord
+		if config, err = p.DolbyVisionConfig(); err != nil {
+			return
+		}
+		codecs = fmt.Sprintf("%s.%02d.%02d", p.Codec, config.Profile, config.Level)
+	case Av01FourCC:
+		var header AV1SequenceHeader
+		if _, header, err = NewAV1CodecConfigurationBox(p.CodecPrivateData); err != nil {
+			return
+		}
+		tier := "M"
+		if header.SeqTier0 > 0 {
+			tier = "H"
+		}
+		codecs = fmt.Sprintf("av01.%d.%02d%s.%02d", header.SeqProfile, header.SeqLevelIdx0, tier, header.BitDepth)
+	case Vp09FourCC:
+		var vpcC *VPCodecConfigurationBox
+		if vpcC, err = NewVPCodecConfigurationBox(p.CodecPrivateData, p.CustomAttributes, p.Width, p.Height); err != nil {
+			return
+		}
+		codecs = fmt.Sprintf("vp09.%02d.%02d.%02d", vpcC.Profile, vpcC.Level, vpcC.BitDepth)
+	case Mp4aFourCC:
+		var config []byte
+		if config, err = p.CreateAudioSpecificConfig(); err != nil {
+			return
+		}
+		var asc AudioSpecificConfig
+		if asc, err = ParseAudioSpecificConfig(config); err != nil {
+			return
+		}
+		audioObjectType := asc.AudioObjectType
+		if asc.PSPresent {
+			audioObjectType = AudioObjectTypePS
+		} else if asc.SBRPresent {
+			audioObjectType = AudioObjectTypeSBR
+		}
+		codecs = fmt.Sprintf("mp4a.40.%d", audioObjectType)
+	case mp4.FourCC{}:
+		err = fmt.Errorf("track has no codec: %w", ErrInvalidParam)
+	default:
+		codecs = string(p.Codec[:])
+	}
+	return
+}
+
+// hevcCodecString formats the codecs parameter of an HEVC track, ISO/IEC
+// 14496-15 E.3.
+func hevcCodecString(codec mp4.FourCC, info TrackInfo) string {
+	var b strings.Builder
+	b.WriteString(string(codec[:]))
+	b.WriteByte('.')
+	if info.ProfileSpace > 0 {
+		b.WriteByte('A' + info.ProfileSpace - 1)
+	}
+	fmt.Fprintf(&b, "%d", info.Profile)
+	// the compatibility flags in reverse bit order
+	var compatibility uint32
+	for i := 0; i < 32; i++ {
+		compatibility |= (info.ProfileCompatibility >> i & 1) << (31 - i)
+	}
+	fmt.Fprintf(&b, ".%X", compatibility)
+	tier := 'L'
+	if info.HighTier {
+		tier = 'H'
+	}
+	fmt.Fprintf(&b, ".%c%d", tier, info.Level)
+	// the constraint bytes, trailing zero bytes omitted
+	constraints := make([]byte, 6)
+	for i := range constraints {
+		constraints[i] = byte(info.ConstraintFlags >> (40 - 8*i))
+	}
+	for len(constraints) > 0 && constraints[len(constraints)-1] == 0 {
+		constraints = constraints[:len(constraints)-1]
+	}
+	for _, c := range constraints {
+		fmt.Fprintf(&b, ".%X", c)
+	}
+	return b.String()
+}
+
+// CodecString returns the RFC 6381 codecs parameter of a track of the
+// manifest, see MoovProcessor.CodecString.
+func (t *Track) CodecString() (codecs string, err error) {
+	if t.FourCC == nil {
+		err = fmt.Errorf("track %d has no FourCC: %w", t.Index, ErrInvalidParam)
+		return
+	}
+	p := MoovProcessor{
+		CodecPrivateData: t.CodecPrivateData,
+		AudioObjectType:  AudioObjectTypeForFourCC(*t.FourCC),
+		CustomAttributes: t.CustomAttributes,
+	}
+	if p.Codec, err = SampleEntryCodec(*t.FourCC); err != nil {
+		return
+	}
+	if t.MaxWidth != nil && t.MaxHeight != nil {
+		p.Width, p.Height = *t.MaxWidth, *t.MaxHeight
+	}
+	if t.SamplingRate != nil {
+		p.SamplingRate = *t.SamplingRate
+	}
+	if t.Channels != nil {
+		p.Channels = *t.Channels
+	}
+	return p.CodecString()
+}
@@ -0,0 +1,182 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestSampleEntryCodec(t *testing.T) {
+	tests := []struct {
+		fourCC  string
+		want    mp4.FourCC
+		wantErr error
+	}{
+		{fourCC: "H264", want: mp4.Avc1FourCC},
+		{fourCC: "avc1", want: mp4.Avc1FourCC},
+		{fourCC: "HVC1", want: mp4.Hvc1FourCC},
+		{fourCC: "HEV1", want: mp4.Hev1FourCC},
+		{fourCC: "DVH1", want: mp4.Dvh1FourCC},
+		{fourCC: "dvhe", want: mp4.DvheFourCC},
+		{fourCC: "AACH", want: Mp4aFourCC},
+		{fourCC: "AC-3", want: Ac3FourCC},
+		{fourCC: "EC-3", want: Ec3FourCC},
+		{fourCC: "DTS", want: DtscFourCC},
+		{fourCC: "DTSH", want: DtshFourCC},
+		{fourCC: "dtsl", want: DtslFourCC},
+		{fourCC: "DTSE", want: DtseFourCC},
+		{fourCC: "AV01", want: Av01FourCC},
+		{fourCC: "VP90", want: Vp09FourCC},
+		{fourCC: "WVC1", want: Vc1FourCC},
+		{fourCC: "WMAP", want: OwmaFourCC},
+		{fourCC: "TTML", want: StppFourCC},
+		{fourCC: "METT", want: MettFourCC},
+		{fourCC: "urim", want: UrimFourCC},
+		{fourCC: "FLAC", wantErr: ErrUnknownCodec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fourCC, func(t *testing.T) {
+			got, err := SampleEntryCodec(tt.fourCC)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SampleEntryCodec() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SampleEntryCodec() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodecString(t *testing.T) {
+	vp9 := &CustomAttributes{Attributes: []*Attribute{{Name: "codecs", Value: "vp09.02.10.10.01.09.16.09.01"}}}
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "H.264",
+			processor: MoovProcessor{Codec: mp4.Avc1FourCC, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)},
+			want:      "avc1.64001F",
+		},
+		{
+			name:      "HEVC",
+			processor: MoovProcessor{Codec: mp4.Hvc1FourCC, CodecPrivateData: decodeHex(t, testHEVCCodecPrivateData)},
+			want:      "hvc1.1.6.L120.90",
+		},
+		{
+			name: "HEVC Main 10 high tier",
+			processor: MoovProcessor{Codec: mp4.Hev1FourCC, CodecPrivateData: decodeHex(t, "00000001"+"40010c01ffff016000000300900000030000030078959809"+
+				"00000001"+testHEVCSubLayerSPS+"00000001"+"4401c172b46240")},
+			want: "hev1.2.4.H153.90",
+		},
+		{
+			name:      "Dolby Vision",
+			processor: MoovProcessor{Codec: mp4.Dvh1FourCC, Width: 1920, Height: 1080},
+			want:      "dvh1.05.04",
+		},
+		{
+			name:      "AV1",
+			processor: MoovProcessor{Codec: Av01FourCC, CodecPrivateData: decodeHex(t, "1200"+testAV1OBU(OBUSequenceHeader, testAV1SequenceHeaderTiming))},
+			want:      "av01.0.09H.10",
+		},
+		{
+			name:      "VP9",
+			processor: MoovProcessor{Codec: Vp09FourCC, CustomAttributes: vp9},
+			want:      "vp09.02.10.10",
+		},
+		{
+			name:      "AAC LC",
+			processor: MoovProcessor{Codec: Mp4aFourCC, AudioObjectType: AudioObjectTypeAACLC, SamplingRate: 44100, CodecPrivateData: []byte{0x12, 0x10}},
+			want:      "mp4a.40.2",
+		},
+		{
+			name:      "HE-AAC",
+			processor: MoovProcessor{Codec: Mp4aFourCC, AudioObjectType: AudioObjectTypeSBR, SamplingRate: 48000, CodecPrivateData: []byte{0x13, 0x10}},
+			want:      "mp4a.40.5",
+		},
+		{
+			name:      "HE-AAC v2",
+			processor: MoovProcessor{Codec: Mp4aFourCC, AudioObjectType: AudioObjectTypePS, SamplingRate: 48000, Channels: 2},
+			want:      "mp4a.40.29",
+		},
+		{
+			name:      "E-AC-3",
+			processor: MoovProcessor{Codec: Ec3FourCC},
+			want:      "ec-3",
+		},
+		{
+			name:      "no codec",
+			processor: MoovProcessor{},
+			wantErr:   ErrInvalidParam,
+		},
+		{
+			name:      "H.264 without SPS",
+			processor: MoovProcessor{Codec: mp4.Avc1FourCC, CodecPrivateData: decodeHex(t, "0000000168ebe3cb22c0")},
+			wantErr:   ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.processor.CodecString()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CodecString() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CodecString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackCodecString(t *testing.T) {
+	fourCC := "AACH"
+	samplingRate := uint32(48000)
+	channels := uint16(2)
+	track := &Track{FourCC: &fourCC, SamplingRate: &samplingRate, Channels: &channels, CodecPrivateData: []byte{0x13, 0x10}}
+	if got, err := track.CodecString(); err != nil || got != "mp4a.40.5" {
+		t.Errorf("CodecString() = %q, %v, want mp4a.40.5", got, err)
+	}
+	fourCC = "H264"
+	track = &Track{FourCC: &fourCC, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+	if got, err := track.CodecString(); err != nil || got != "avc1.64001F" {
+		t.Errorf("CodecString() = %q, %v, want avc1.64001F", got, err)
+	}
+	if _, err := (&Track{}).CodecString(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("CodecString() of no FourCC error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestManifestFourCC(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		want      string
+		wantErr   error
+	}{
+		{name: "avc1", processor: MoovProcessor{Codec: mp4.Avc1FourCC}, want: "H264"},
+		{name: "avc3", processor: MoovProcessor{Codec: mp4.FourCC{'a', 'v', 'c', '3'}}, want: "H264"},
+		{name: "AAC LC", processor: MoovProcessor{Codec: Mp4aFourCC, CodecPrivateData: []byte{0x12, 0x10}}, want: "AACL"},
+		{name: "HE-AAC", processor: MoovProcessor{Codec: Mp4aFourCC, AudioObjectType: AudioObjectTypeSBR, SamplingRate: 48000, CodecPrivateData: []byte{0x11, 0x90}}, want: "AACH"},
+		{name: "HE-AAC v2", processor: MoovProcessor{Codec: Mp4aFourCC, AudioObjectType: AudioObjectTypePS, SamplingRate: 48000, Channels: 2}, want: "AACP"},
+		{name: "VC-1", processor: MoovProcessor{Codec: Vc1FourCC}, want: "WVC1"},
+		{name: "WMA Pro", processor: MoovProcessor{Codec: OwmaFourCC}, want: "WMAP"},
+		{name: "TTML", processor: MoovProcessor{Codec: StppFourCC}, want: "TTML"},
+		{name: "HEVC", processor: MoovProcessor{Codec: mp4.Hvc1FourCC}, want: "HVC1"},
+		{name: "E-AC-3", processor: MoovProcessor{Codec: Ec3FourCC}, want: "EC-3"},
+		{name: "no codec", processor: MoovProcessor{}, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.processor.ManifestFourCC()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ManifestFourCC() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ManifestFourCC() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
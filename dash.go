@@ -0,0 +1,522 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webdl/mp4"
+	"github.com/google/uuid"
+)
+
+// MPD is a DASH Media Presentation Description, ISO/IEC 23009-1, limited to
+// the elements and attributes of a presentation converted from Smooth
+// Streaming, see SmoothStreamingMedia.DASH, or to Smooth Streaming, see
+// MPD.SmoothStreamingMedia.
+type MPD struct {
+	XMLName   xml.Name `xml:"MPD"`
+	XMLNS     string   `xml:"xmlns,attr,omitempty"`
+	XMLNSCenc string   `xml:"xmlns:cenc,attr,omitempty"`
+	XMLNSMspr string   `xml:"xmlns:mspr,attr,omitempty"`
+
+	Profiles string `xml:"profiles,attr"`
+
+	// static for on-demand presentations, dynamic for live ones.
+	Type string `xml:"type,attr"`
+
+	MediaPresentationDuration string `xml:"mediaPresentationDuration,attr,omitempty"`
+	MinBufferTime             string `xml:"minBufferTime,attr"`
+
+	// the attributes of dynamic presentations.
+	AvailabilityStartTime string `xml:"availabilityStartTime,attr,omitempty"`
+	PublishTime           string `xml:"publishTime,attr,omitempty"`
+	MinimumUpdatePeriod   string `xml:"minimumUpdatePeriod,attr,omitempty"`
+	TimeShiftBufferDepth  string `xml:"timeShiftBufferDepth,attr,omitempty"`
+
+	BaseURL string `xml:"BaseURL,omitempty"`
+
+	Periods []*Period `xml:"Period"`
+}
+
+// Period is a Period of an MPD.
+type Period struct {
+	ID             string           `xml:"id,attr,omitempty"`
+	Start          string           `xml:"start,attr,omitempty"`
+	BaseURL        string           `xml:"BaseURL,omitempty"`
+	AdaptationSets []*AdaptationSet `xml:"AdaptationSet"`
+}
+
+// AdaptationSet is an AdaptationSet of an MPD, the tracks of a stream.
+type AdaptationSet struct {
+	ID                 string               `xml:"id,attr,omitempty"`
+	ContentType        string               `xml:"contentType,attr,omitempty"`
+	MimeType           string               `xml:"mimeType,attr,omitempty"`
+	Lang               string               `xml:"lang,attr,omitempty"`
+	SegmentAlignment   bool                 `xml:"segmentAlignment,attr,omitempty"`
+	StartWithSAP       uint8                `xml:"startWithSAP,attr,omitempty"`
+	MaxWidth           uint32               `xml:"maxWidth,attr,omitempty"`
+	MaxHeight          uint32               `xml:"maxHeight,attr,omitempty"`
+	BaseURL            string               `xml:"BaseURL,omitempty"`
+	ContentProtections []*ContentProtection `xml:"ContentProtection"`
+
+	// the Role of the text streams, from their Subtype.
+	Role *Descriptor `xml:"Role"`
+
+	SegmentTemplate *SegmentTemplate  `xml:"SegmentTemplate"`
+	Representations []*Representation `xml:"Representation"`
+}
+
+// Descriptor is a descriptor element of an MPD, e.g. a Role or an
+// AudioChannelConfiguration.
+type Descriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr,omitempty"`
+}
+
+// ContentProtection is a ContentProtection descriptor of an MPD: the
+// protection scheme and default KID of the tracks, or the pssh data of a
+// protection system.
+type ContentProtection struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr,omitempty"`
+	DefaultKID  string `xml:"cenc:default_KID,attr,omitempty"`
+
+	// the base64-encoded pssh box of the protection system.
+	Pssh string `xml:"cenc:pssh,omitempty"`
+
+	// the base64-encoded PlayReady Object of PlayReady.
+	Pro string `xml:"mspr:pro,omitempty"`
+}
+
+// UnmarshalXML decodes the ContentProtection element, resolving the
+// namespaces of its cenc and mspr attributes and elements, whatever their
+// prefixes.
+func (cp *ContentProtection) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
+	var v struct {
+		SchemeIDURI string `xml:"schemeIdUri,attr"`
+		Value       string `xml:"value,attr,omitempty"`
+		DefaultKID  string `xml:"urn:mpeg:cenc:2013 default_KID,attr,omitempty"`
+		Pssh        string `xml:"urn:mpeg:cenc:2013 pssh,omitempty"`
+		Pro         string `xml:"urn:microsoft:playready pro,omitempty"`
+	}
+	if err = d.DecodeElement(&v, &start); err != nil {
+		return
+	}
+	*cp = ContentProtection(v)
+	cp.Pssh, cp.Pro = strings.TrimSpace(cp.Pssh), strings.TrimSpace(cp.Pro)
+	return
+}
+
+// SegmentTemplate is a SegmentTemplate of an MPD.
+type SegmentTemplate struct {
+	Timescale              uint64           `xml:"timescale,attr,omitempty"`
+	PresentationTimeOffset uint64           `xml:"presentationTimeOffset,attr,omitempty"`
+	StartNumber            *uint64          `xml:"startNumber,attr"`
+	Initialization         string           `xml:"initialization,attr,omitempty"`
+	Media                  string           `xml:"media,attr,omitempty"`
+	SegmentTimeline        *SegmentTimeline `xml:"SegmentTimeline"`
+}
+
+// SegmentTimeline is a SegmentTimeline of an MPD, its S elements repeating
+// the segments of the same duration.
+type SegmentTimeline struct {
+	Segments []SegmentTimelineEntry `xml:"S"`
+}
+
+// SegmentTimelineEntry is an S element of a SegmentTimeline: R+1 segments of
+// duration D, the first starting at T if it is not nil, otherwise where the
+// previous one ends. A negative R repeats the segment until the next S
+// element, or the end of the Period.
+type SegmentTimelineEntry struct {
+	T *uint64 `xml:"t,attr"`
+	D uint64  `xml:"d,attr"`
+	R int64   `xml:"r,attr,omitempty"`
+}
+
+// Representation is a Representation of an MPD, a track of a stream.
+type Representation struct {
+	ID                        string               `xml:"id,attr"`
+	Bandwidth                 uint32               `xml:"bandwidth,attr"`
+	MimeType                  string               `xml:"mimeType,attr,omitempty"`
+	Codecs                    string               `xml:"codecs,attr,omitempty"`
+	Width                     uint32               `xml:"width,attr,omitempty"`
+	Height                    uint32               `xml:"height,attr,omitempty"`
+	AudioSamplingRate         uint32               `xml:"audioSamplingRate,attr,omitempty"`
+	BaseURL                   string               `xml:"BaseURL,omitempty"`
+	ContentProtections        []*ContentProtection `xml:"ContentProtection"`
+	AudioChannelConfiguration *Descriptor          `xml:"AudioChannelConfiguration"`
+	SegmentTemplate           *SegmentTemplate     `xml:"SegmentTemplate"`
+}
+
+// DASH namespaces and scheme URIs.
+const (
+	dashNamespace             = "urn:mpeg:dash:schema:mpd:2011"
+	dashProfileLive           = "urn:mpeg:dash:profile:isoff-live:2011"
+	dashNamespaceCenc         = "urn:mpeg:cenc:2013"
+	dashNamespaceMspr         = "urn:microsoft:playready"
+	dashSchemeMp4Protection   = "urn:mpeg:dash:mp4protection:2011"
+	dashSchemeChannels        = "urn:mpeg:dash:23003:3:audio_channel_configuration:2011"
+	dashSchemeRole            = "urn:mpeg:dash:role:2011"
+	dashDefaultInitialization = "$RepresentationID$/init.mp4"
+)
+
+// DASHOptions are the options of the conversion of a Smooth Streaming
+// presentation to DASH, see SmoothStreamingMedia.DASH.
+type DASHOptions struct {
+	// the BaseURL of the MPD, e.g. the url of the manifest for the segments
+	// to be requested from the Smooth Streaming origin, none if empty.
+	BaseURL string
+
+	// the template of the url of the init segments, relative to the BaseURL,
+	// "$RepresentationID$/init.mp4" if empty.
+	Initialization string
+
+	// the availabilityStartTime of a live presentation. If zero, it is set so
+	// that the live edge of the manifest is the current time; a proxy should
+	// then keep the AvailabilityStartTime of the first MPD converted for the
+	// following refreshes.
+	AvailabilityStartTime time.Time
+
+	// the minBufferTime of the MPD, the duration of the longest fragment if 0.
+	MinBufferTime time.Duration
+}
+
+// DASH converts the presentation to a DASH MPD of the isoff-live profile, so
+// that a Smooth Streaming origin can be played by DASH players through a thin
+// proxy. Each stream becomes an AdaptationSet whose SegmentTemplate lists the
+// timeline of the stream and requests the fragments with the url pattern of
+// the stream, $Bandwidth$ and $Time$ replacing its placeholders; each track a
+// Representation with the RFC 6381 codecs of its CodecString. The Protection
+// element becomes the ContentProtection descriptors of the AdaptationSets.
+// Timed metadata streams, see StreamIndex.IsTimedMetadata, and streams
+// without url pattern are left out.
+//
+// Smooth Streaming origins serve neither the init segments nor fragments with
+// a tfdt box: the proxy serves the init segment of each Representation at the
+// Initialization template, building it with the MoovProcessor of the track of
+// DASHRepresentation, and rewrites the fragments with a FragmentRewriter.
+func (m *SmoothStreamingMedia) DASH(opts DASHOptions) (mpd *MPD, err error) {
+	live := m.IsLive != nil && *m.IsLive
+	mpd = &MPD{XMLNS: dashNamespace, Profiles: dashProfileLive, Type: "static", BaseURL: opts.BaseURL}
+	period := &Period{ID: "0", Start: formatDASHDuration(0)}
+	mpd.Periods = []*Period{period}
+
+	// the start of the presentation, at which the streams are aligned, and
+	// its live edge, in the presentation timescale
+	timescale := m.PresentationTimeScale()
+	var start, edge uint64
+	first := true
+	var longest time.Duration
+	for _, s := range m.Streams {
+		if !isDASHStream(s) {
+			continue
+		}
+		var fragments []TimelineFragment
+		if fragments, err = m.StreamTimeline(s); err != nil {
+			mpd = nil
+			return
+		}
+		if len(fragments) == 0 {
+			continue
+		}
+		streamTimescale := m.StreamTimeScale(s)
+		if t := rescaleTime(fragments[0].Time, streamTimescale, timescale); first || t < start {
+			start = t
+		}
+		if t := rescaleTime(fragments[len(fragments)-1].End(), streamTimescale, timescale); t > edge {
+			edge = t
+		}
+		first = false
+		for _, f := range fragments {
+			if d := mediaDuration(f.Duration, streamTimescale); d > longest {
+				longest = d
+			}
+		}
+	}
+
+	minBufferTime := opts.MinBufferTime
+	if minBufferTime <= 0 {
+		minBufferTime = longest
+	}
+	mpd.MinBufferTime = formatDASHDuration(minBufferTime)
+	if live {
+		mpd.Type = "dynamic"
+		now := time.Now().UTC()
+		ast := opts.AvailabilityStartTime
+		if ast.IsZero() {
+			ast = now.Add(-mediaDuration(edge-start, timescale))
+		}
+		mpd.AvailabilityStartTime = ast.UTC().Format(time.RFC3339Nano)
+		mpd.PublishTime = now.Format(time.RFC3339Nano)
+		mpd.MinimumUpdatePeriod = formatDASHDuration(longest)
+		if m.DVRWindowLength != nil && *m.DVRWindowLength > 0 {
+			mpd.TimeShiftBufferDepth = formatDASHDuration(mediaDuration(*m.DVRWindowLength, timescale))
+		}
+	} else {
+		duration := m.Duration
+		if duration == 0 {
+			duration = edge - start
+		}
+		mpd.MediaPresentationDuration = formatDASHDuration(mediaDuration(duration, timescale))
+	}
+
+	var protections []*ContentProtection
+	if m.Protection != nil && len(m.Protection.ProtectionHeaders) > 0 {
+		if protections, err = m.dashContentProtections(); err != nil {
+			mpd = nil
+			return
+		}
+		mpd.XMLNSCenc, mpd.XMLNSMspr = dashNamespaceCenc, dashNamespaceMspr
+	}
+
+	initialization := opts.Initialization
+	if initialization == "" {
+		initialization = dashDefaultInitialization
+	}
+	for i, s := range m.Streams {
+		if !isDASHStream(s) {
+			continue
+		}
+		var set *AdaptationSet
+		if set, err = m.dashAdaptationSet(s, rescaleTime(start, timescale, m.StreamTimeScale(s))); err != nil {
+			mpd = nil
+			return
+		}
+		set.ID = strconv.Itoa(i)
+		set.ContentProtections = protections
+		set.SegmentTemplate.Initialization = initialization
+		period.AdaptationSets = append(period.AdaptationSets, set)
+	}
+	return
+}
+
+// DASHRepresentation returns the stream and the track of the Representation
+// of the given id in the MPD converted from the presentation, see DASH.
+func (m *SmoothStreamingMedia) DASHRepresentation(id string) (s *StreamIndex, t *Track, ok bool) {
+	for _, s := range m.Streams {
+		if !isDASHStream(s) {
+			continue
+		}
+		for _, t := range s.Tracks {
+			if dashRepresentationID(s, t) == id {
+				return s, t, true
+			}
+		}
+	}
+	return
+}
+
+// Bytes returns the XML document of the MPD.
+func (mpd *MPD) Bytes() (data []byte, err error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&b)
+	encoder.Indent("", "  ")
+	if err = encoder.Encode(mpd); err != nil {
+		return
+	}
+	b.WriteByte('\n')
+	data = b.Bytes()
+	return
+}
+
+// isDASHStream reports whether the stream is converted to an AdaptationSet.
+func isDASHStream(s *StreamIndex) bool {
+	return s.URL != nil && !s.IsTimedMetadata()
+}
+
+// dashRepresentationID returns the id of the Representation of a track.
+func dashRepresentationID(s *StreamIndex, t *Track) string {
+	return fmt.Sprintf("%s_%d", strings.Join(strings.Fields(s.streamName()), "_"), t.Index)
+}
+
+// dashAdaptationSet converts a stream to an AdaptationSet, its timeline
+// starting at the presentation time offset pto.
+func (m *SmoothStreamingMedia) dashAdaptationSet(s *StreamIndex, pto uint64) (set *AdaptationSet, err error) {
+	set = &AdaptationSet{
+		ContentType:      string(s.Type),
+		MimeType:         string(s.Type) + "/mp4",
+		SegmentAlignment: true,
+		StartWithSAP:     1,
+	}
+	if s.Type == TextStream {
+		set.MimeType = "application/mp4"
+		if s.Subtype != nil {
+			switch strings.ToUpper(*s.Subtype) {
+			case "CAPT":
+				set.Role = &Descriptor{SchemeIDURI: dashSchemeRole, Value: "caption"}
+			case "SUBT":
+				set.Role = &Descriptor{SchemeIDURI: dashSchemeRole, Value: "subtitle"}
+			case "DESC":
+				set.Role = &Descriptor{SchemeIDURI: dashSchemeRole, Value: "description"}
+			}
+		}
+	}
+	if s.Language != nil {
+		set.Lang = *s.Language
+	}
+
+	fragments, err := m.StreamTimeline(s)
+	if err != nil {
+		return
+	}
+	set.SegmentTemplate = &SegmentTemplate{
+		Timescale:              m.StreamTimeScale(s),
+		PresentationTimeOffset: pto,
+		SegmentTimeline:        dashSegmentTimeline(fragments),
+	}
+
+	// the media template is shared by the Representations unless they differ
+	// by their custom attributes
+	shared := true
+	for i, t := range s.Tracks {
+		media := dashMediaTemplate(*s.URL, t)
+		if i == 0 {
+			set.SegmentTemplate.Media = media
+		} else if media != set.SegmentTemplate.Media {
+			shared = false
+		}
+	}
+	for _, t := range s.Tracks {
+		var r *Representation
+		if r, err = m.dashRepresentation(s, t); err != nil {
+			return
+		}
+		if !shared {
+			r.SegmentTemplate = &SegmentTemplate{Media: dashMediaTemplate(*s.URL, t)}
+		}
+		if r.Width > set.MaxWidth {
+			set.MaxWidth = r.Width
+		}
+		if r.Height > set.MaxHeight {
+			set.MaxHeight = r.Height
+		}
+		set.Representations = append(set.Representations, r)
+	}
+	if !shared {
+		set.SegmentTemplate.Media = ""
+	}
+	return
+}
+
+// dashRepresentation converts a track of the stream s to a Representation.
+func (m *SmoothStreamingMedia) dashRepresentation(s *StreamIndex, t *Track) (r *Representation, err error) {
+	p, err := m.MoovProcessor(s, t)
+	if err != nil {
+		return
+	}
+	r = &Representation{ID: dashRepresentationID(s, t), Bandwidth: t.Bitrate}
+	if r.Codecs, err = p.CodecString(); err != nil {
+		err = fmt.Errorf("track %d of stream %s: %w", t.Index, s.streamName(), err)
+		return
+	}
+	switch s.Type {
+	case VideoStream:
+		r.Width, r.Height = p.Width, p.Height
+	case AudioStream:
+		r.AudioSamplingRate = p.SamplingRate
+		if p.Channels > 0 {
+			r.AudioChannelConfiguration = &Descriptor{SchemeIDURI: dashSchemeChannels, Value: strconv.Itoa(int(p.Channels))}
+		}
+	}
+	return
+}
+
+// dashSegmentTimeline returns the SegmentTimeline of the fragments, repeating
+// the contiguous fragments of the same duration. Fragments whose duration is
+// not known yet, at the live edge, are left out as segments cannot be empty.
+func dashSegmentTimeline(fragments []TimelineFragment) (timeline *SegmentTimeline) {
+	timeline = &SegmentTimeline{}
+	var next uint64
+	for _, f := range fragments {
+		if f.Duration == 0 {
+			continue
+		}
+		n := len(timeline.Segments)
+		if n > 0 && f.Time == next && f.Duration == timeline.Segments[n-1].D {
+			timeline.Segments[n-1].R++
+		} else {
+			entry := SegmentTimelineEntry{D: f.Duration}
+			if n == 0 || f.Time != next {
+				t := f.Time
+				entry.T = &t
+			}
+			timeline.Segments = append(timeline.Segments, entry)
+		}
+		next = f.End()
+	}
+	return
+}
+
+// dashMediaTemplate converts the url pattern of a stream to the media
+// template of the Representation of track t.
+func dashMediaTemplate(pattern string, t *Track) string {
+	c := strings.ReplaceAll(escapeURLPattern(pattern), "$", "$$")
+	c = strings.ReplaceAll(c, "{bitrate}", "$Bandwidth$")
+	c = strings.ReplaceAll(c, "{Bitrate}", "$Bandwidth$")
+	c = strings.ReplaceAll(c, "{start time}", "$Time$")
+	c = strings.ReplaceAll(c, "{start_time}", "$Time$")
+	if customAttributesStr := escapeCustomAttributes(t.CustomAttributes); customAttributesStr != "" {
+		c = strings.ReplaceAll(c, "{CustomAttributes}", strings.ReplaceAll(customAttributesStr, "$", "$$"))
+	} else {
+		c = strings.ReplaceAll(c, ",{CustomAttributes}", "")
+		c = strings.ReplaceAll(c, "{CustomAttributes}", "")
+	}
+	return c
+}
+
+// dashContentProtections converts the Protection element to the
+// ContentProtection descriptors of the protected AdaptationSets: the
+// mp4protection descriptor of the scheme and default KID, followed by one
+// descriptor per protection system.
+func (m *SmoothStreamingMedia) dashContentProtections() (protections []*ContentProtection, err error) {
+	var p MoovProcessor
+	if err = m.setProtection(&p); err != nil {
+		return
+	}
+	scheme, err := p.encryptionScheme()
+	if err != nil {
+		return
+	}
+	mp4Protection := &ContentProtection{SchemeIDURI: dashSchemeMp4Protection, Value: string(scheme[:])}
+	if p.KID != ([16]byte{}) {
+		mp4Protection.DefaultKID = uuid.UUID(p.KID).String()
+	}
+	protections = append(protections, mp4Protection)
+	for _, system := range p.protectionSystems() {
+		pssh := &mp4.ProtectionSystemSpecificHeaderBox{SystemID: system.SystemID, Data: system.Data}
+		pssh.Mp4BoxUpdate()
+		var b bytes.Buffer
+		if err = pssh.Mp4BoxWrite(&b); err != nil {
+			return
+		}
+		protection := &ContentProtection{
+			SchemeIDURI: "urn:uuid:" + system.SystemID.String(),
+			Pssh:        base64.StdEncoding.EncodeToString(b.Bytes()),
+		}
+		switch system.SystemID {
+		case PlayReadySystemID:
+			protection.Value = "MSPR 2.0"
+			protection.Pro = base64.StdEncoding.EncodeToString(system.Data)
+		case WidevineSystemID:
+			protection.Value = "Widevine"
+		}
+		protections = append(protections, protection)
+	}
+	return
+}
+
+// mediaDuration converts a duration in the given timescale to a
+// time.Duration.
+func mediaDuration(d, timescale uint64) time.Duration {
+	return time.Duration(rescaleTime(d, timescale, uint64(time.Second)))
+}
+
+// formatDASHDuration formats a duration as an xs:duration, e.g. "PT2.5S".
+func formatDASHDuration(d time.Duration) string {
+	return "PT" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+}
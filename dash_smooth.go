@@ -0,0 +1,622 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webdl/mp4"
+	"github.com/google/uuid"
+)
+
+// InitSegmentFunc returns the configuration of the track of the init segment
+// at u, e.g. the first track of the ParseInitSegment of its response.
+type InitSegmentFunc func(u *url.URL) (p MoovProcessor, err error)
+
+// ParseMPD parses a DASH MPD, see MPD.
+func ParseMPD(data []byte) (mpd *MPD, err error) {
+	mpd = &MPD{}
+	if err = xml.Unmarshal(data, mpd); err != nil {
+		mpd = nil
+		err = fmt.Errorf("invalid MPD: %v: %w", err, ErrInvalidParam)
+	}
+	return
+}
+
+// SmoothStreamingMedia converts an MPD of a single Period whose
+// AdaptationSets list their segments with a SegmentTimeline to a Smooth
+// Streaming client manifest, so that legacy Smooth Streaming clients can be
+// served from a DASH origin. Each AdaptationSet becomes a stream, its
+// SegmentTimeline the c elements of the stream, and each Representation a
+// QualityLevel of its bandwidth, which must differ within the AdaptationSet.
+// The codec, its CodecPrivateData and the picture size or audio format of
+// the Representations are not in the MPD but in their init segments, at the
+// urls of the initialization templates resolved against mpdURL, which init
+// returns the configuration of. The ContentProtection descriptors of the
+// pssh data of protection systems, or the protection systems of the init
+// segments, become the Protection element.
+//
+// The url pattern of each stream is QualityLevels({bitrate})/Fragments(name=
+// {start time}), name being the name of the stream: a proxy serves the
+// fragments, requested from the DASH origin at their SegmentURL.
+func (mpd *MPD) SmoothStreamingMedia(mpdURL *url.URL, init InitSegmentFunc) (m *SmoothStreamingMedia, err error) {
+	period, err := mpd.period()
+	if err != nil {
+		return
+	}
+	streams, err := mpd.smoothStreams()
+	if err != nil {
+		return
+	}
+	m = &SmoothStreamingMedia{MajorVersion: 2, MinorVersion: 2}
+	if mpd.Type == "dynamic" {
+		live := true
+		m.IsLive = &live
+		if mpd.TimeShiftBufferDepth != "" {
+			var depth time.Duration
+			if depth, err = parseDASHDuration(mpd.TimeShiftBufferDepth); err != nil {
+				m = nil
+				return
+			}
+			window := rescaleTime(uint64(depth), uint64(time.Second), DefaultTimeScale)
+			m.DVRWindowLength = &window
+		}
+	}
+	duration, err := mpd.duration()
+	if err != nil {
+		m = nil
+		return
+	}
+
+	var systems []ProtectionSystem
+	for _, stream := range streams {
+		var s *StreamIndex
+		var streamSystems []ProtectionSystem
+		if s, streamSystems, err = mpd.smoothStream(mpdURL, period, stream, duration, init); err != nil {
+			m = nil
+			return
+		}
+		m.Streams = append(m.Streams, s)
+		for _, system := range streamSystems {
+			if !containsProtectionSystem(systems, system.SystemID) {
+				systems = append(systems, system)
+			}
+		}
+		if m.IsLive == nil && duration == 0 && len(s.Fragments) > 0 {
+			// the duration of the presentation is the end of its longest stream
+			if fragments, err := s.Timeline(); err == nil && len(fragments) > 0 {
+				last := fragments[len(fragments)-1]
+				if end := rescaleTime(last.End(), *s.TimeScale, DefaultTimeScale); end > m.Duration {
+					m.Duration = end
+				}
+			}
+		}
+	}
+	if duration > 0 && m.IsLive == nil {
+		m.Duration = rescaleTime(uint64(duration), uint64(time.Second), DefaultTimeScale)
+	}
+	m.Protection = smoothProtection(systems)
+	return
+}
+
+// smoothProtection returns the Protection element of the given protection
+// systems, nil if there are none.
+func smoothProtection(systems []ProtectionSystem) (protection *Protection) {
+	if len(systems) == 0 {
+		return
+	}
+	protection = &Protection{}
+	for _, system := range systems {
+		protection.ProtectionHeaders = append(protection.ProtectionHeaders, &ProtectionHeader{
+			SystemID: system.SystemID,
+			Content:  base64.StdEncoding.EncodeToString(system.Data),
+		})
+	}
+	return
+}
+
+// SegmentURL returns the url of the segment of the MPD requested by a Smooth
+// Streaming client of the manifest converted from it, see
+// SmoothStreamingMedia: the segment at the given time of the Representation
+// of the given bitrate of the AdaptationSet of the named stream, resolved
+// against mpdURL.
+func (mpd *MPD) SegmentURL(mpdURL *url.URL, stream string, bitrate uint32, t uint64) (u *url.URL, err error) {
+	period, err := mpd.period()
+	if err != nil {
+		return
+	}
+	streams, err := mpd.smoothStreams()
+	if err != nil {
+		return
+	}
+	duration, err := mpd.duration()
+	if err != nil {
+		return
+	}
+	for _, s := range streams {
+		if s.name != stream {
+			continue
+		}
+		for _, r := range s.set.Representations {
+			if r.Bandwidth != bitrate {
+				continue
+			}
+			template := s.set.segmentTemplate(r)
+			var fragments []TimelineFragment
+			if fragments, err = template.timeline(template.end(duration)); err != nil {
+				return
+			}
+			for i, f := range fragments {
+				if f.Time != t {
+					continue
+				}
+				number := uint64(1)
+				if template.StartNumber != nil {
+					number = *template.StartNumber
+				}
+				return mpd.resolve(mpdURL, period, s.set, r, expandDASHTemplate(template.Media, r, number+uint64(i), t))
+			}
+			err = fmt.Errorf("no segment at %d in representation %s: %w", t, r.ID, ErrInvalidParam)
+			return
+		}
+		err = fmt.Errorf("no representation of bitrate %d in stream %s: %w", bitrate, stream, ErrInvalidParam)
+		return
+	}
+	err = fmt.Errorf("no stream %s: %w", stream, ErrInvalidParam)
+	return
+}
+
+// Bytes returns the XML document of the manifest.
+func (m *SmoothStreamingMedia) Bytes() (data []byte, err error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&b)
+	encoder.Indent("", "  ")
+	if err = encoder.Encode(m); err != nil {
+		return
+	}
+	b.WriteByte('\n')
+	data = b.Bytes()
+	return
+}
+
+// duration returns the mediaPresentationDuration of the MPD, 0 if it has
+// none.
+func (mpd *MPD) duration() (d time.Duration, err error) {
+	if mpd.MediaPresentationDuration != "" {
+		d, err = parseDASHDuration(mpd.MediaPresentationDuration)
+	}
+	return
+}
+
+// end returns the end of the Period of the given duration in the timescale
+// of the template.
+func (template SegmentTemplate) end(duration time.Duration) uint64 {
+	return template.PresentationTimeOffset + rescaleTime(uint64(duration), uint64(time.Second), template.Timescale)
+}
+
+// smoothAdaptationSet is an AdaptationSet converted to the stream of the
+// given name.
+type smoothAdaptationSet struct {
+	name string
+	set  *AdaptationSet
+}
+
+// period returns the single Period of the MPD.
+func (mpd *MPD) period() (period *Period, err error) {
+	if len(mpd.Periods) != 1 {
+		err = fmt.Errorf("MPD of %d periods instead of 1: %w", len(mpd.Periods), ErrInvalidParam)
+		return
+	}
+	return mpd.Periods[0], nil
+}
+
+// smoothStreams returns the AdaptationSets of the Period of the MPD with the
+// names of their streams: their content type, followed by their language if
+// any, and numbered from 2 if several AdaptationSets share them.
+func (mpd *MPD) smoothStreams() (streams []smoothAdaptationSet, err error) {
+	period, err := mpd.period()
+	if err != nil {
+		return
+	}
+	count := make(map[string]int)
+	for _, set := range period.AdaptationSets {
+		var streamType StreamType
+		if streamType, err = set.streamType(); err != nil {
+			return
+		}
+		name := string(streamType)
+		if set.Lang != "" {
+			name += "_" + set.Lang
+		}
+		if count[name]++; count[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, count[name])
+		}
+		streams = append(streams, smoothAdaptationSet{name: name, set: set})
+	}
+	return
+}
+
+// streamType returns the type of the stream of the AdaptationSet, from its
+// content type or the MIME type of its Representations.
+func (set *AdaptationSet) streamType() (streamType StreamType, err error) {
+	kind := set.ContentType
+	if kind == "" {
+		mimeType := set.MimeType
+		if mimeType == "" && len(set.Representations) > 0 {
+			mimeType = set.Representations[0].MimeType
+		}
+		kind = strings.SplitN(mimeType, "/", 2)[0]
+	}
+	switch kind {
+	case "video":
+		streamType = VideoStream
+	case "audio":
+		streamType = AudioStream
+	case "text", "application":
+		streamType = TextStream
+	default:
+		err = fmt.Errorf("adaptation set %s of content type %q: %w", set.ID, kind, ErrInvalidParam)
+	}
+	return
+}
+
+// segmentTemplate returns the SegmentTemplate of the Representation r of the
+// AdaptationSet, its attributes and timeline inherited from the one of the
+// AdaptationSet.
+func (set *AdaptationSet) segmentTemplate(r *Representation) (template SegmentTemplate) {
+	if set.SegmentTemplate != nil {
+		template = *set.SegmentTemplate
+	}
+	if own := r.SegmentTemplate; own != nil {
+		if own.Timescale != 0 {
+			template.Timescale = own.Timescale
+		}
+		if own.PresentationTimeOffset != 0 {
+			template.PresentationTimeOffset = own.PresentationTimeOffset
+		}
+		if own.StartNumber != nil {
+			template.StartNumber = own.StartNumber
+		}
+		if own.Initialization != "" {
+			template.Initialization = own.Initialization
+		}
+		if own.Media != "" {
+			template.Media = own.Media
+		}
+		if own.SegmentTimeline != nil {
+			template.SegmentTimeline = own.SegmentTimeline
+		}
+	}
+	if template.Timescale == 0 {
+		template.Timescale = 1
+	}
+	return
+}
+
+// timeline resolves the SegmentTimeline of the template into its segments,
+// the last S element repeated until end, in the timescale of the template, if
+// its repeat count is negative.
+func (template SegmentTemplate) timeline(end uint64) (fragments []TimelineFragment, err error) {
+	if template.SegmentTimeline == nil {
+		err = fmt.Errorf("segment template %q has no SegmentTimeline: %w", template.Media, ErrInvalidParam)
+		return
+	}
+	entries := template.SegmentTimeline.Segments
+	var next uint64
+	for i, s := range entries {
+		if s.T != nil {
+			next = *s.T
+		}
+		if s.D == 0 {
+			err = fmt.Errorf("S element %d of duration 0: %w", i, ErrInvalidTimeline)
+			return
+		}
+		count := uint64(s.R) + 1
+		if s.R < 0 {
+			// until the next S element or the end of the Period
+			until := end
+			if i+1 < len(entries) && entries[i+1].T != nil {
+				until = *entries[i+1].T
+			}
+			count = 0
+			if until > next {
+				count = (until - next + s.D - 1) / s.D
+			}
+		}
+		for j := uint64(0); j < count; j++ {
+			fragments = append(fragments, TimelineFragment{Number: uint32(len(fragments)), Time: next, Duration: s.D})
+			next += s.D
+		}
+	}
+	return
+}
+
+// smoothStream converts an AdaptationSet to a stream, returning the
+// protection systems of its Representations.
+func (mpd *MPD) smoothStream(mpdURL *url.URL, period *Period, stream smoothAdaptationSet, duration time.Duration, init InitSegmentFunc) (s *StreamIndex, systems []ProtectionSystem, err error) {
+	set := stream.set
+	if len(set.Representations) == 0 {
+		err = fmt.Errorf("adaptation set %s has no representations: %w", set.ID, ErrInvalidParam)
+		return
+	}
+	streamType, err := set.streamType()
+	if err != nil {
+		return
+	}
+	name := stream.name
+	pattern := smoothURLPattern(name)
+	s = &StreamIndex{Type: streamType, Name: &name, URL: &pattern}
+	if set.Lang != "" {
+		lang := set.Lang
+		s.Language = &lang
+	}
+	if streamType == TextStream {
+		subtype := "SUBT"
+		if set.Role != nil {
+			switch set.Role.Value {
+			case "caption":
+				subtype = "CAPT"
+			case "description":
+				subtype = "DESC"
+			}
+		}
+		s.Subtype = &subtype
+	}
+
+	// the timeline of the stream is the one of its first Representation
+	template := set.segmentTemplate(set.Representations[0])
+	timescale := template.Timescale
+	s.TimeScale = &timescale
+	fragments, err := template.timeline(template.end(duration))
+	if err != nil {
+		return
+	}
+	s.Fragments = smoothFragments(fragments)
+	chunks := uint32(len(fragments))
+	s.NumberOfFragments = &chunks
+
+	seen := make(map[uint32]bool)
+	for i, r := range set.Representations {
+		if seen[r.Bandwidth] {
+			err = fmt.Errorf("representations of adaptation set %s share the bandwidth %d: %w", set.ID, r.Bandwidth, ErrInvalidParam)
+			return
+		}
+		seen[r.Bandwidth] = true
+		var u *url.URL
+		if u, err = mpd.resolve(mpdURL, period, set, r, expandDASHTemplate(set.segmentTemplate(r).Initialization, r, 0, 0)); err != nil {
+			return
+		}
+		var p MoovProcessor
+		if p, err = init(u); err != nil {
+			err = fmt.Errorf("init segment of representation %s: %w", r.ID, err)
+			return
+		}
+		var t *Track
+		if t, err = smoothTrack(uint32(i), r.Bandwidth, p); err != nil {
+			err = fmt.Errorf("representation %s: %w", r.ID, err)
+			return
+		}
+		s.addTrack(t)
+		var protections []ProtectionSystem
+		if protections, err = dashProtectionSystems(append(append([]*ContentProtection(nil), set.ContentProtections...), r.ContentProtections...)); err != nil {
+			return
+		}
+		if len(protections) == 0 && p.Protected {
+			protections = p.protectionSystems()
+		}
+		for _, system := range protections {
+			if !containsProtectionSystem(systems, system.SystemID) {
+				systems = append(systems, system)
+			}
+		}
+	}
+	return
+}
+
+// addTrack appends t to the tracks of the stream, whose maximum picture size
+// is that of its largest video track.
+func (s *StreamIndex) addTrack(t *Track) {
+	s.Tracks = append(s.Tracks, t)
+	tracks := uint32(len(s.Tracks))
+	s.NumberOfTracks = &tracks
+	if s.Type == VideoStream && t.MaxWidth != nil && t.MaxHeight != nil {
+		if s.MaxWidth == nil || *t.MaxWidth > *s.MaxWidth {
+			s.MaxWidth, s.DisplayWidth = t.MaxWidth, t.MaxWidth
+		}
+		if s.MaxHeight == nil || *t.MaxHeight > *s.MaxHeight {
+			s.MaxHeight, s.DisplayHeight = t.MaxHeight, t.MaxHeight
+		}
+	}
+}
+
+// smoothURLPattern returns the url pattern of the stream of the given name,
+// the one of IIS.
+func smoothURLPattern(name string) string {
+	return fmt.Sprintf("QualityLevels({bitrate})/Fragments(%s={start time})", name)
+}
+
+// smoothTrack returns the track of the given index and bitrate configured by
+// the init segment p.
+func smoothTrack(index, bitrate uint32, p MoovProcessor) (t *Track, err error) {
+	fourCC, err := p.ManifestFourCC()
+	if err != nil {
+		return
+	}
+	t = &Track{Index: index, Bitrate: bitrate, FourCC: &fourCC, CodecPrivateData: p.CodecPrivateData}
+	switch {
+	case p.Width > 0 || p.Height > 0:
+		width, height := p.Width, p.Height
+		t.MaxWidth, t.MaxHeight = &width, &height
+	case p.SamplingRate > 0:
+		samplingRate, channels := p.SamplingRate, p.Channels
+		bitsPerSample, packetSize, audioTag := p.BitsPerSample, p.PacketSize, uint32(p.AudioTag)
+		if bitsPerSample == 0 {
+			bitsPerSample = 16
+		}
+		if packetSize == 0 {
+			packetSize = 4
+		}
+		if audioTag == 0 && p.Codec == Mp4aFourCC {
+			audioTag = 255
+		}
+		t.SamplingRate, t.Channels, t.BitsPerSample, t.PacketSize, t.AudioTag = &samplingRate, &channels, &bitsPerSample, &packetSize, &audioTag
+	}
+	return
+}
+
+// smoothFragments returns the c elements of the fragments, repeating the
+// contiguous fragments of the same duration.
+func smoothFragments(fragments []TimelineFragment) (elements []*StreamFragment) {
+	var next uint64
+	for i, f := range fragments {
+		if n := len(elements); i > 0 && f.Time == next && f.Duration == *elements[n-1].Duration {
+			repeat := uint64(2)
+			if elements[n-1].Repeat != nil {
+				repeat = *elements[n-1].Repeat + 1
+			}
+			elements[n-1].Repeat = &repeat
+		} else {
+			duration := f.Duration
+			element := &StreamFragment{Duration: &duration}
+			if i == 0 || f.Time != next {
+				t := f.Time
+				element.Time = &t
+			}
+			elements = append(elements, element)
+		}
+		next = f.End()
+	}
+	return
+}
+
+// dashProtectionSystems returns the protection systems of the pssh data of
+// the ContentProtection descriptors, or of their PlayReady Objects.
+func dashProtectionSystems(protections []*ContentProtection) (systems []ProtectionSystem, err error) {
+	for _, cp := range protections {
+		var system ProtectionSystem
+		switch {
+		case cp.Pssh != "":
+			var data []byte
+			if data, err = base64.StdEncoding.DecodeString(cp.Pssh); err != nil {
+				err = fmt.Errorf("pssh of %s: %w", cp.SchemeIDURI, ErrInvalidParam)
+				return
+			}
+			r := bytes.NewReader(data)
+			var header *mp4.Header
+			if header, err = mp4.ReadHeader(r); err != nil {
+				return
+			}
+			var box mp4.Box
+			if box, err = mp4.ReadBoxAfterHeader(r, header); err != nil {
+				return
+			}
+			var ok bool
+			if system, ok = psshProtectionSystem(box); !ok {
+				err = fmt.Errorf("pssh of %s is a %s box: %w", cp.SchemeIDURI, box.Mp4BoxType(), ErrInvalidParam)
+				return
+			}
+		case cp.Pro != "" && strings.EqualFold(cp.SchemeIDURI, "urn:uuid:"+PlayReadySystemID.String()):
+			system.SystemID = PlayReadySystemID
+			if system.Data, err = base64.StdEncoding.DecodeString(cp.Pro); err != nil {
+				err = fmt.Errorf("PlayReady Object of %s: %w", cp.SchemeIDURI, ErrInvalidParam)
+				return
+			}
+		default:
+			continue
+		}
+		if !containsProtectionSystem(systems, system.SystemID) {
+			systems = append(systems, system)
+		}
+	}
+	return
+}
+
+// containsProtectionSystem reports whether systems contains the system of
+// the given ID.
+func containsProtectionSystem(systems []ProtectionSystem, id uuid.UUID) bool {
+	for _, system := range systems {
+		if system.SystemID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve resolves the url ref of a segment of the Representation r against
+// mpdURL and the BaseURLs of the MPD, of the Period, of the AdaptationSet and
+// of r in turn.
+func (mpd *MPD) resolve(mpdURL *url.URL, period *Period, set *AdaptationSet, r *Representation, ref string) (u *url.URL, err error) {
+	u = mpdURL
+	for _, base := range []string{mpd.BaseURL, period.BaseURL, set.BaseURL, r.BaseURL, ref} {
+		if base = strings.TrimSpace(base); base == "" {
+			continue
+		}
+		var rel *url.URL
+		if rel, err = url.Parse(base); err != nil {
+			err = fmt.Errorf("url %q: %w", base, ErrInvalidParam)
+			u = nil
+			return
+		}
+		u = u.ResolveReference(rel)
+	}
+	return
+}
+
+// dashTemplateIdentifier matches the identifiers of a DASH segment template,
+// with their optional width format tag.
+var dashTemplateIdentifier = regexp.MustCompile(`\$(RepresentationID|Bandwidth|Number|Time|)(?:%0(\d+)d)?\$`)
+
+// expandDASHTemplate substitutes the identifiers of a DASH segment template
+// for the segment of the given number and time of the Representation r.
+func expandDASHTemplate(template string, r *Representation, number, t uint64) string {
+	return dashTemplateIdentifier.ReplaceAllStringFunc(template, func(identifier string) string {
+		match := dashTemplateIdentifier.FindStringSubmatch(identifier)
+		var value string
+		switch match[1] {
+		case "":
+			return "$"
+		case "RepresentationID":
+			return r.ID
+		case "Bandwidth":
+			value = strconv.FormatUint(uint64(r.Bandwidth), 10)
+		case "Number":
+			value = strconv.FormatUint(number, 10)
+		case "Time":
+			value = strconv.FormatUint(t, 10)
+		}
+		if width, _ := strconv.Atoi(match[2]); len(value) < width {
+			value = strings.Repeat("0", width-len(value)) + value
+		}
+		return value
+	})
+}
+
+// dashDuration matches the xs:duration values of an MPD, without years and
+// months.
+var dashDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d*)?)S)?)?$`)
+
+// parseDASHDuration parses an xs:duration of an MPD, e.g. "PT1H2M3.5S".
+func parseDASHDuration(s string) (d time.Duration, err error) {
+	match := dashDuration.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		err = fmt.Errorf("duration %q: %w", s, ErrInvalidParam)
+		return
+	}
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute} {
+		if n, _ := strconv.ParseInt(match[i+1], 10, 64); n > 0 {
+			d += time.Duration(n) * unit
+		}
+	}
+	if match[4] != "" {
+		seconds, _ := strconv.ParseFloat(match[4], 64)
+		d += time.Duration(seconds * float64(time.Second))
+	}
+	return
+}
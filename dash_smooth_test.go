@@ -0,0 +1,366 @@
+package smoothstreaming
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+const testMPD = `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:m="urn:microsoft:playready" type="static" mediaPresentationDuration="PT6S">
+  <BaseURL>http://cdn.example.com/dash/</BaseURL>
+  <Period id="0">
+    <AdaptationSet id="0" contentType="video">
+      <ContentProtection schemeIdUri="urn:uuid:9a04f079-9840-4286-ab92-e65be0885f95"><m:pro>PRO</m:pro></ContentProtection>
+      <SegmentTemplate timescale="10000000" startNumber="1" initialization="$RepresentationID$/init.mp4" media="$RepresentationID$/$Number%05d$.m4s">
+        <SegmentTimeline><S t="0" d="20000000" r="1"/><S d="15000000"/><S t="60000000" d="5000000"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="v1" bandwidth="1000"/>
+      <Representation id="v2" bandwidth="500"><BaseURL>low/</BaseURL></Representation>
+    </AdaptationSet>
+    <AdaptationSet id="1" mimeType="audio/mp4" lang="en">
+      <SegmentTemplate timescale="48000" initialization="a/init.mp4" media="a/$Time$.m4s">
+        <SegmentTimeline><S t="0" d="96000" r="-1"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="a1" bandwidth="128000"/>
+    </AdaptationSet>
+    <AdaptationSet id="2" lang="en">
+      <Role schemeIdUri="urn:mpeg:dash:role:2011" value="caption"/>
+      <SegmentTemplate timescale="1000" initialization="t/init.mp4" media="t/$Time$.m4s">
+        <SegmentTimeline><S t="0" d="6000"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="t1" bandwidth="1000" mimeType="application/mp4"/>
+    </AdaptationSet>
+    <AdaptationSet id="3" contentType="audio" lang="en">
+      <SegmentTemplate timescale="48000" initialization="a2/init.mp4" media="a2/$Time$.m4s">
+        <SegmentTimeline><S t="0" d="288000"/></SegmentTimeline>
+      </SegmentTemplate>
+      <Representation id="a2" bandwidth="64000"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+
+// testMPDInit returns the configuration of the init segments of testMPD.
+func testMPDInit(t *testing.T, requested *[]string) InitSegmentFunc {
+	return func(u *url.URL) (p MoovProcessor, err error) {
+		*requested = append(*requested, u.String())
+		switch path.Base(path.Dir(u.Path)) {
+		case "v1":
+			p = MoovProcessor{Codec: mp4.Avc1FourCC, Width: 1280, Height: 720, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+		case "v2":
+			p = MoovProcessor{Codec: mp4.Avc1FourCC, Width: 640, Height: 360, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+		case "a", "a2":
+			p = MoovProcessor{Codec: Mp4aFourCC, SamplingRate: 48000, Channels: 2, CodecPrivateData: decodeHex(t, testAACCodecPrivateData)}
+		case "t":
+			p = MoovProcessor{Codec: StppFourCC}
+		default:
+			err = ErrUnexpectedStatus
+		}
+		return
+	}
+}
+
+func testParseMPD(t *testing.T, data string) *MPD {
+	t.Helper()
+	pro := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(testWRMHeader40)}}}.Bytes()
+	mpd, err := ParseMPD([]byte(strings.Replace(data, "PRO", base64.StdEncoding.EncodeToString(pro), 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mpd
+}
+
+func TestParseMPD(t *testing.T) {
+	mpd := testParseMPD(t, testMPD)
+	if mpd.Type != "static" || mpd.BaseURL != "http://cdn.example.com/dash/" || len(mpd.Periods) != 1 || len(mpd.Periods[0].AdaptationSets) != 4 {
+		t.Fatalf("MPD %+v", mpd)
+	}
+	video := mpd.Periods[0].AdaptationSets[0]
+	if len(video.ContentProtections) != 1 || video.ContentProtections[0].Pro == "" {
+		t.Errorf("content protections %+v, want the PlayReady Object of any prefix", video.ContentProtections)
+	}
+	if tmpl := video.SegmentTemplate; tmpl.StartNumber == nil || *tmpl.StartNumber != 1 || len(tmpl.SegmentTimeline.Segments) != 3 {
+		t.Errorf("segment template %+v", tmpl)
+	}
+	if r := mpd.Periods[0].AdaptationSets[1].SegmentTemplate.SegmentTimeline.Segments[0].R; r != -1 {
+		t.Errorf("repeat count %d, want -1", r)
+	}
+
+	if _, err := ParseMPD([]byte("<MPD>")); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ParseMPD() of a truncated MPD error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestMPDSmoothStreamingMedia(t *testing.T) {
+	mpdURL, _ := url.Parse("http://example.com/movie/manifest.mpd")
+	var requested []string
+	m, err := testParseMPD(t, testMPD).SmoothStreamingMedia(mpdURL, testMPDInit(t, &requested))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRequested := []string{
+		"http://cdn.example.com/dash/v1/init.mp4",
+		"http://cdn.example.com/dash/low/v2/init.mp4",
+		"http://cdn.example.com/dash/a/init.mp4",
+		"http://cdn.example.com/dash/t/init.mp4",
+		"http://cdn.example.com/dash/a2/init.mp4",
+	}
+	if strings.Join(requested, " ") != strings.Join(wantRequested, " ") {
+		t.Errorf("requested init segments %v, want %v", requested, wantRequested)
+	}
+	if m.Duration != 60000000 || m.IsLive != nil || len(m.Streams) != 4 {
+		t.Fatalf("manifest of duration %d, live %v, %d streams", m.Duration, m.IsLive, len(m.Streams))
+	}
+
+	names := make([]string, len(m.Streams))
+	for i, s := range m.Streams {
+		names[i] = *s.Name
+		if want := "QualityLevels({bitrate})/Fragments(" + names[i] + "={start time})"; *s.URL != want {
+			t.Errorf("stream %s url %s, want %s", names[i], *s.URL, want)
+		}
+	}
+	if got := strings.Join(names, " "); got != "video audio_en text_en audio_en_2" {
+		t.Errorf("stream names %s", got)
+	}
+
+	video := m.Streams[0]
+	if *video.TimeScale != 10000000 || *video.NumberOfTracks != 2 || *video.MaxWidth != 1280 || *video.MaxHeight != 720 {
+		t.Errorf("video stream %+v", video)
+	}
+	if tr := video.Tracks[1]; tr.Index != 1 || tr.Bitrate != 500 || *tr.FourCC != "H264" || *tr.MaxWidth != 640 || *tr.MaxHeight != 360 {
+		t.Errorf("video track %+v", tr)
+	}
+	fragments, err := video.Timeline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTimes := []uint64{0, 20000000, 40000000, 60000000}
+	if len(fragments) != len(wantTimes) || *video.NumberOfFragments != 4 {
+		t.Fatalf("got %d video fragments, want %d", len(fragments), len(wantTimes))
+	}
+	for i, f := range fragments {
+		if f.Time != wantTimes[i] {
+			t.Errorf("video fragment %d at %d, want %d", i, f.Time, wantTimes[i])
+		}
+	}
+	if c := video.Fragments; len(c) != 3 || c[0].Repeat == nil || *c[0].Repeat != 2 || c[1].Time != nil || c[2].Time == nil || *c[2].Time != 60000000 {
+		t.Errorf("video c elements %d, want the repeated fragments merged", len(c))
+	}
+
+	// the negative repeat count lasts until the end of the Period
+	audio := m.Streams[1]
+	if *audio.Language != "en" || *audio.NumberOfFragments != 3 || len(audio.Fragments) != 1 || *audio.Fragments[0].Repeat != 3 {
+		t.Errorf("audio stream %+v", audio)
+	}
+	if tr := audio.Tracks[0]; *tr.FourCC != "AACL" || *tr.SamplingRate != 48000 || *tr.Channels != 2 || *tr.BitsPerSample != 16 || *tr.PacketSize != 4 || *tr.AudioTag != 255 {
+		t.Errorf("audio track %+v", tr)
+	}
+	if text := m.Streams[2]; text.Type != TextStream || *text.Subtype != "CAPT" || *text.Tracks[0].FourCC != "TTML" {
+		t.Errorf("text stream %+v", text)
+	}
+
+	if m.Protection == nil || len(m.Protection.ProtectionHeaders) != 1 || m.Protection.ProtectionHeaders[0].SystemID != PlayReadySystemID {
+		t.Fatalf("protection %+v, want the PlayReady header", m.Protection)
+	}
+	system, err := m.Protection.ProtectionHeaders[0].ProtectionSystem()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParsePlayReadyObject(system.Data); err != nil {
+		t.Errorf("protection header of an invalid PlayReady Object: %v", err)
+	}
+
+	data, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseManifest(data); err != nil {
+		t.Errorf("ParseManifest() of the converted manifest error = %v", err)
+	}
+}
+
+func TestMPDSmoothStreamingMediaLive(t *testing.T) {
+	live := strings.Replace(testMPD, `type="static" mediaPresentationDuration="PT6S"`, `type="dynamic" timeShiftBufferDepth="PT30S"`, 1)
+	var requested []string
+	m, err := testParseMPD(t, live).SmoothStreamingMedia(&url.URL{}, testMPDInit(t, &requested))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.IsLive == nil || !*m.IsLive || m.DVRWindowLength == nil || *m.DVRWindowLength != 300000000 || m.Duration != 0 {
+		t.Errorf("manifest live %v, DVR window %v, duration %d", m.IsLive, m.DVRWindowLength, m.Duration)
+	}
+	// without the end of the Period the negative repeat count ends the timeline
+	if n := *m.Streams[1].NumberOfFragments; n != 0 {
+		t.Errorf("got %d audio fragments, want 0", n)
+	}
+}
+
+func TestMPDSmoothStreamingMediaInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		wantErr error
+	}{
+		{name: "periods", old: `</Period>`, new: `</Period><Period id="1"/>`, wantErr: ErrInvalidParam},
+		{name: "content type", old: `contentType="video"`, new: `contentType="image"`, wantErr: ErrInvalidParam},
+		{name: "shared bandwidth", old: `bandwidth="500"`, new: `bandwidth="1000"`, wantErr: ErrInvalidParam},
+		{name: "no timeline", old: `<SegmentTimeline><S t="0" d="6000"/></SegmentTimeline>`, new: ``, wantErr: ErrInvalidParam},
+		{name: "zero duration", old: `d="6000"`, new: `d="0"`, wantErr: ErrInvalidTimeline},
+		{name: "duration", old: `PT6S`, new: `P1Y`, wantErr: ErrInvalidParam},
+		{name: "init segment", old: `a2/init.mp4`, new: `x/init.mp4`, wantErr: ErrUnexpectedStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			m, err := testParseMPD(t, strings.Replace(testMPD, tt.old, tt.new, 1)).SmoothStreamingMedia(&url.URL{}, testMPDInit(t, &requested))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SmoothStreamingMedia() error = %v, want %v", err, tt.wantErr)
+			}
+			if m != nil {
+				t.Errorf("SmoothStreamingMedia() = %+v, want nil", m)
+			}
+		})
+	}
+}
+
+func TestMPDSmoothStreamingMediaRoundTrip(t *testing.T) {
+	pro := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(testWRMHeader40)}}}.Bytes()
+	source, err := ParseManifest([]byte(strings.Replace(testDASHManifest, `</SmoothStreamingMedia>`,
+		`<Protection><ProtectionHeader SystemID="9a04f079-9840-4286-ab92-e65be0885f95">`+base64.StdEncoding.EncodeToString(pro)+`</ProtectionHeader></Protection></SmoothStreamingMedia>`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := source.DASH(DASHOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := converted.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mpd, err := ParseMPD(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mpdURL, _ := url.Parse("http://example.com/movie.mpd")
+	m, err := mpd.SmoothStreamingMedia(mpdURL, func(u *url.URL) (p MoovProcessor, err error) {
+		s, track, ok := source.DASHRepresentation(path.Base(path.Dir(u.Path)))
+		if !ok {
+			err = ErrUnexpectedStatus
+			return
+		}
+		return source.MoovProcessor(s, track)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Duration != source.Duration || len(m.Streams) != 3 {
+		t.Fatalf("manifest of duration %d, %d streams", m.Duration, len(m.Streams))
+	}
+	for i, want := range []*StreamIndex{source.Streams[0], source.Streams[1], source.Streams[2]} {
+		s := m.Streams[i]
+		if s.Type != want.Type || *s.NumberOfTracks != uint32(len(want.Tracks)) {
+			t.Errorf("stream %d of type %s and %d tracks, want %s and %d", i, s.Type, *s.NumberOfTracks, want.Type, len(want.Tracks))
+			continue
+		}
+		for j, tr := range s.Tracks {
+			if tr.Bitrate != want.Tracks[j].Bitrate || *tr.FourCC != *want.Tracks[j].FourCC {
+				t.Errorf("track %d of stream %d of bitrate %d and FourCC %s, want %d and %s", j, i, tr.Bitrate, *tr.FourCC, want.Tracks[j].Bitrate, *want.Tracks[j].FourCC)
+			}
+		}
+		got, err := s.Timeline()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantFragments, err := want.Timeline()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(wantFragments) || got[0].Time != wantFragments[0].Time || got[0].Duration != wantFragments[0].Duration {
+			t.Errorf("stream %d timeline %+v, want %+v", i, got, wantFragments)
+		}
+	}
+	if m.Protection == nil || len(m.Protection.ProtectionHeaders) != 1 || m.Protection.ProtectionHeaders[0].Content != base64.StdEncoding.EncodeToString(pro) {
+		t.Errorf("protection %+v, want the PlayReady Object of the pssh", m.Protection)
+	}
+}
+
+func TestMPDSegmentURL(t *testing.T) {
+	mpd := testParseMPD(t, testMPD)
+	mpdURL, _ := url.Parse("http://example.com/movie/manifest.mpd")
+	tests := []struct {
+		stream  string
+		bitrate uint32
+		t       uint64
+		want    string
+		wantErr error
+	}{
+		{stream: "video", bitrate: 1000, t: 0, want: "http://cdn.example.com/dash/v1/00001.m4s"},
+		{stream: "video", bitrate: 500, t: 60000000, want: "http://cdn.example.com/dash/low/v2/00004.m4s"},
+		{stream: "audio_en", bitrate: 128000, t: 192000, want: "http://cdn.example.com/dash/a/192000.m4s"},
+		{stream: "audio_en_2", bitrate: 64000, t: 0, want: "http://cdn.example.com/dash/a2/0.m4s"},
+		{stream: "video", bitrate: 1000, t: 10000000, wantErr: ErrInvalidParam},
+		{stream: "video", bitrate: 750, t: 0, wantErr: ErrInvalidParam},
+		{stream: "audio", bitrate: 128000, t: 0, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		u, err := mpd.SegmentURL(mpdURL, tt.stream, tt.bitrate, tt.t)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("SegmentURL(%s, %d, %d) error = %v, want %v", tt.stream, tt.bitrate, tt.t, err, tt.wantErr)
+			continue
+		}
+		if err == nil && u.String() != tt.want {
+			t.Errorf("SegmentURL(%s, %d, %d) = %s, want %s", tt.stream, tt.bitrate, tt.t, u, tt.want)
+		}
+	}
+}
+
+func TestExpandDASHTemplate(t *testing.T) {
+	r := &Representation{ID: "v1", Bandwidth: 500000}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"$RepresentationID$/$Number$.m4s", "v1/7.m4s"},
+		{"$Bandwidth$/$Time$.m4s", "500000/90000.m4s"},
+		{"$Number%05d$-$Time%03d$", "00007-90000"},
+		{"cost$$.mp4", "cost$.mp4"},
+	}
+	for _, tt := range tests {
+		if got := expandDASHTemplate(tt.template, r, 7, 90000); got != tt.want {
+			t.Errorf("expandDASHTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestParseDASHDuration(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr error
+	}{
+		{s: "PT6S", want: 6 * time.Second},
+		{s: "PT1H2M3.5S", want: time.Hour + 2*time.Minute + 3500*time.Millisecond},
+		{s: "P1DT0.25S", want: 24*time.Hour + 250*time.Millisecond},
+		{s: " PT0S ", want: 0},
+		{s: "P1Y", wantErr: ErrInvalidParam},
+		{s: "6s", wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		got, err := parseDASHDuration(tt.s)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("parseDASHDuration(%q) error = %v, want %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDASHDuration(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
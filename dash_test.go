@@ -0,0 +1,297 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestDashSegmentTimeline(t *testing.T) {
+	tests := []struct {
+		name      string
+		fragments []TimelineFragment
+		want      string
+	}{
+		{
+			name:      "contiguous fragments of the same duration",
+			fragments: []TimelineFragment{{0, 0, 20}, {1, 20, 20}, {2, 40, 20}, {3, 60, 10}},
+			want:      `<SegmentTimeline><S t="0" d="20" r="2"></S><S d="10"></S></SegmentTimeline>`,
+		},
+		{
+			name:      "gap",
+			fragments: []TimelineFragment{{0, 0, 20}, {1, 50, 20}},
+			want:      `<SegmentTimeline><S t="0" d="20"></S><S t="50" d="20"></S></SegmentTimeline>`,
+		},
+		{
+			name:      "live window",
+			fragments: []TimelineFragment{{7, 140, 20}, {8, 160, 20}},
+			want:      `<SegmentTimeline><S t="140" d="20" r="1"></S></SegmentTimeline>`,
+		},
+		{
+			name:      "fragment of unknown duration at the live edge",
+			fragments: []TimelineFragment{{7, 140, 20}, {8, 160, 20}, {9, 180, 0}},
+			want:      `<SegmentTimeline><S t="140" d="20" r="1"></S></SegmentTimeline>`,
+		},
+		{
+			name:      "fragment of unknown duration only",
+			fragments: []TimelineFragment{{9, 180, 0}},
+			want:      `<SegmentTimeline></SegmentTimeline>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := xml.Marshal(dashSegmentTimeline(tt.fragments))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); got != tt.want {
+				t.Errorf("dashSegmentTimeline() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDashMediaTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		pattern    string
+		attributes []*Attribute
+		want       string
+	}{
+		{
+			name:    "placeholders",
+			pattern: "QualityLevels({bitrate})/Fragments(video={start time})",
+			want:    "QualityLevels($Bandwidth$)/Fragments(video=$Time$)",
+		},
+		{
+			name:    "alternative placeholders",
+			pattern: "QualityLevels({Bitrate})/Fragments(video={start_time})",
+			want:    "QualityLevels($Bandwidth$)/Fragments(video=$Time$)",
+		},
+		{
+			name:    "dollar sign",
+			pattern: "QualityLevels({bitrate})/Fragments(video$1={start time})",
+			want:    "QualityLevels($Bandwidth$)/Fragments(video$$1=$Time$)",
+		},
+		{
+			name:       "custom attributes",
+			pattern:    "QualityLevels({bitrate},{CustomAttributes})/Fragments(video={start time})",
+			attributes: []*Attribute{{Name: "hardware profile", Value: "1"}},
+			want:       "QualityLevels($Bandwidth$,hardware%20profile=1)/Fragments(video=$Time$)",
+		},
+		{
+			name:    "no custom attributes",
+			pattern: "QualityLevels({bitrate},{CustomAttributes})/Fragments(video={start time})",
+			want:    "QualityLevels($Bandwidth$)/Fragments(video=$Time$)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track := &Track{}
+			if tt.attributes != nil {
+				track.CustomAttributes = &CustomAttributes{Attributes: tt.attributes}
+			}
+			if got := dashMediaTemplate(tt.pattern, track); got != tt.want {
+				t.Errorf("dashMediaTemplate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDASHDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{2500 * time.Millisecond, "PT2.5S"},
+		{time.Hour, "PT3600S"},
+	}
+	for _, tt := range tests {
+		if got := formatDASHDuration(tt.d); got != tt.want {
+			t.Errorf("formatDASHDuration(%v) = %s, want %s", tt.d, got, tt.want)
+		}
+	}
+}
+
+const testDASHManifest = `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+	`<StreamIndex Type="video" Name="video" QualityLevels="2" Url="QualityLevels({bitrate})/Fragments(video={start time})">` +
+	`<QualityLevel Index="0" Bitrate="1000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+	`<QualityLevel Index="1" Bitrate="500" FourCC="H264" MaxWidth="640" MaxHeight="360" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+	`<c t="20000000" d="20000000" r="3"/>` +
+	`</StreamIndex>` +
+	`<StreamIndex Type="audio" Name="audio eng" Language="en" QualityLevels="1" TimeScale="48000" Url="QualityLevels({bitrate})/Fragments(audio={start time})">` +
+	`<QualityLevel Index="0" Bitrate="128000" FourCC="AACL" SamplingRate="48000" Channels="2" CodecPrivateData="1190"/>` +
+	`<c t="96000" d="96000" r="3"/>` +
+	`</StreamIndex>` +
+	`<StreamIndex Type="text" Name="subtitles" Subtype="SUBT" QualityLevels="1" Url="QualityLevels({bitrate})/Fragments(subtitles={start time})">` +
+	`<QualityLevel Index="0" Bitrate="1000" FourCC="TTML"/>` +
+	`<c t="20000000" d="60000000"/>` +
+	`</StreamIndex>` +
+	`<StreamIndex Type="text" Name="events" Subtype="DATA" QualityLevels="1" Url="QualityLevels({bitrate})/Fragments(events={start time})">` +
+	`<QualityLevel Index="0" Bitrate="1000" FourCC="DATA"/>` +
+	`<c t="20000000" d="60000000"/>` +
+	`</StreamIndex>` +
+	`<StreamIndex Type="video" Name="no url" QualityLevels="1">` +
+	`<QualityLevel Index="0" Bitrate="1000" FourCC="H264" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+	`</StreamIndex>` +
+	`</SmoothStreamingMedia>`
+
+func TestSmoothStreamingMediaDASH(t *testing.T) {
+	m, err := ParseManifest([]byte(testDASHManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mpd, err := m.DASH(DASHOptions{BaseURL: "http://example.com/movie.ism/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mpd.Type != "static" || mpd.MediaPresentationDuration != "PT6S" || mpd.MinBufferTime != "PT6S" || mpd.BaseURL != "http://example.com/movie.ism/" {
+		t.Errorf("MPD type %s, duration %s, min buffer time %s, base URL %s", mpd.Type, mpd.MediaPresentationDuration, mpd.MinBufferTime, mpd.BaseURL)
+	}
+	if mpd.AvailabilityStartTime != "" || mpd.XMLNSCenc != "" {
+		t.Errorf("static MPD of availability start time %q, cenc namespace %q", mpd.AvailabilityStartTime, mpd.XMLNSCenc)
+	}
+	if len(mpd.Periods) != 1 {
+		t.Fatalf("got %d periods, want 1", len(mpd.Periods))
+	}
+	// timed metadata and streams without url pattern are left out
+	sets := mpd.Periods[0].AdaptationSets
+	if len(sets) != 3 {
+		t.Fatalf("got %d adaptation sets, want 3", len(sets))
+	}
+
+	video := sets[0]
+	if video.ID != "0" || video.ContentType != "video" || video.MimeType != "video/mp4" || video.MaxWidth != 1280 || video.MaxHeight != 720 {
+		t.Errorf("video adaptation set %+v", video)
+	}
+	tmpl := video.SegmentTemplate
+	if tmpl.Timescale != 10000000 || tmpl.PresentationTimeOffset != 20000000 || tmpl.Media != "QualityLevels($Bandwidth$)/Fragments(video=$Time$)" || tmpl.Initialization != dashDefaultInitialization {
+		t.Errorf("video segment template %+v", tmpl)
+	}
+	if len(tmpl.SegmentTimeline.Segments) != 1 || tmpl.SegmentTimeline.Segments[0].R != 2 {
+		t.Errorf("video segment timeline %+v, want 3 segments", tmpl.SegmentTimeline.Segments)
+	}
+	if len(video.Representations) != 2 {
+		t.Fatalf("got %d video representations, want 2", len(video.Representations))
+	}
+	if r := video.Representations[1]; r.ID != "video_1" || r.Bandwidth != 500 || r.Codecs != "avc1.64001F" || r.Width != 640 || r.Height != 360 || r.SegmentTemplate != nil {
+		t.Errorf("video representation %+v", r)
+	}
+
+	audio := sets[1]
+	if audio.ID != "1" || audio.Lang != "en" || audio.SegmentTemplate.Timescale != 48000 || audio.SegmentTemplate.PresentationTimeOffset != 96000 {
+		t.Errorf("audio adaptation set %+v, template %+v", audio, audio.SegmentTemplate)
+	}
+	if r := audio.Representations[0]; r.ID != "audio_eng_0" || r.Codecs != "mp4a.40.2" || r.AudioSamplingRate != 48000 || r.AudioChannelConfiguration == nil || r.AudioChannelConfiguration.Value != "2" {
+		t.Errorf("audio representation %+v", r)
+	}
+
+	text := sets[2]
+	if text.MimeType != "application/mp4" || text.Role == nil || text.Role.Value != "subtitle" {
+		t.Errorf("text adaptation set %+v", text)
+	}
+
+	s, track, ok := m.DASHRepresentation("audio_eng_0")
+	if !ok || s != m.Streams[1] || track != m.Streams[1].Tracks[0] {
+		t.Errorf("DASHRepresentation(audio_eng_0) = %v, %v, %v, want the audio track", s, track, ok)
+	}
+	if _, _, ok = m.DASHRepresentation("events_0"); ok {
+		t.Error("DASHRepresentation(events_0) found the track of a timed metadata stream")
+	}
+
+	data, err := mpd.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed MPD
+	if err = xml.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Periods) != 1 || len(parsed.Periods[0].AdaptationSets) != 3 {
+		t.Errorf("parsed MPD %+v, want the adaptation sets", parsed)
+	}
+}
+
+func TestSmoothStreamingMediaDASHLive(t *testing.T) {
+	m, err := ParseManifest([]byte(strings.Replace(testDASHManifest, `Duration="60000000">`, `Duration="0" IsLive="TRUE" DVRWindowLength="300000000">`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mpd, err := m.DASH(DASHOptions{AvailabilityStartTime: ast, MinBufferTime: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mpd.Type != "dynamic" || mpd.MediaPresentationDuration != "" || mpd.MinBufferTime != "PT1S" {
+		t.Errorf("MPD type %s, duration %q, min buffer time %s", mpd.Type, mpd.MediaPresentationDuration, mpd.MinBufferTime)
+	}
+	if mpd.AvailabilityStartTime != "2024-03-01T12:00:00Z" || mpd.MinimumUpdatePeriod != "PT6S" || mpd.TimeShiftBufferDepth != "PT30S" || mpd.PublishTime == "" {
+		t.Errorf("MPD availability start time %s, minimum update period %s, time shift buffer depth %s, publish time %s", mpd.AvailabilityStartTime, mpd.MinimumUpdatePeriod, mpd.TimeShiftBufferDepth, mpd.PublishTime)
+	}
+
+	// the live edge of the manifest is the current time by default
+	before := time.Now()
+	if mpd, err = m.DASH(DASHOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := time.Parse(time.RFC3339Nano, mpd.AvailabilityStartTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edge := got.Add(6 * time.Second); edge.Before(before.Add(-time.Millisecond)) || edge.After(time.Now().Add(time.Millisecond)) {
+		t.Errorf("availability start time %s, want 6s before now", got)
+	}
+}
+
+func TestSmoothStreamingMediaDASHProtection(t *testing.T) {
+	pro := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(testWRMHeader40)}}}.Bytes()
+	m, err := ParseManifest([]byte(strings.Replace(testDASHManifest, `</SmoothStreamingMedia>`,
+		`<Protection><ProtectionHeader SystemID="9a04f079-9840-4286-ab92-e65be0885f95">`+base64.StdEncoding.EncodeToString(pro)+`</ProtectionHeader></Protection></SmoothStreamingMedia>`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mpd, err := m.DASH(DASHOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mpd.XMLNSCenc != dashNamespaceCenc || mpd.XMLNSMspr != dashNamespaceMspr {
+		t.Errorf("MPD namespaces %q, %q", mpd.XMLNSCenc, mpd.XMLNSMspr)
+	}
+	for _, set := range mpd.Periods[0].AdaptationSets {
+		protections := set.ContentProtections
+		if len(protections) != 2 {
+			t.Fatalf("got %d content protections, want 2", len(protections))
+		}
+		if p := protections[0]; p.SchemeIDURI != dashSchemeMp4Protection || p.Value != "cenc" || p.DefaultKID != testPlayReadyKID.String() {
+			t.Errorf("mp4protection descriptor %+v", p)
+		}
+		p := protections[1]
+		if p.SchemeIDURI != "urn:uuid:"+PlayReadySystemID.String() || p.Value != "MSPR 2.0" || p.Pro != base64.StdEncoding.EncodeToString(pro) {
+			t.Errorf("PlayReady descriptor %+v", p)
+		}
+		data, err := base64.StdEncoding.DecodeString(p.Pssh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		box, err := mp4.ReadBox(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pssh, ok := box.(*mp4.ProtectionSystemSpecificHeaderBox); !ok || pssh.SystemID != PlayReadySystemID || !bytes.Equal(pssh.Data, pro) {
+			t.Errorf("pssh %+v, want the PlayReady Object", box)
+		}
+	}
+	data, err := mpd.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`xmlns:cenc="urn:mpeg:cenc:2013"`)) || !bytes.Contains(data, []byte(`cenc:default_KID="`+testPlayReadyKID.String()+`"`)) {
+		t.Errorf("MPD document without the cenc namespace or default KID:\n%s", data)
+	}
+}
@@ -0,0 +1,286 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+)
+
+// Decrypter decrypts the samples of protected fragments with the content keys
+// of their KIDs, turning a downloaded presentation into a clear one. Both the
+// 'cenc' AES-CTR scheme and the 'cbcs' AES-CBC pattern scheme are supported,
+// as well as both PIFF algorithms.
+type Decrypter struct {
+	// the 16-byte AES content keys by KID.
+	Keys map[[16]byte][]byte
+
+	// returns the content key of the KIDs missing from the Keys, e.g. from a
+	// license server, none if nil. It is called concurrently by the
+	// Downloader, and should cache the keys it returns.
+	KeyFunc func(kid [16]byte) (key []byte, err error)
+}
+
+// DecryptFragment decrypts in place the samples of a fragment of the
+// protected track p, as described by the senc box or the PIFF sample
+// encryption box of the fragment, and removes the boxes of the sample
+// encryption data from the fragment. The KID of the samples is the KID of p
+// unless the PIFF box overrides it.
+//
+// The decrypted fragments follow the init segment of p.WithoutProtection(),
+// which reverts the encv and enca sample entries to their original format.
+func (d Decrypter) DecryptFragment(f *Fragment, p MoovProcessor) (err error) {
+	for i := range f.Tracks {
+		t := &f.Tracks[i]
+		if t.SampleEncryption == nil {
+			continue
+		}
+		if err = d.decryptFragmentTrack(f.Data, t, p); err != nil {
+			return
+		}
+		if err = t.removeSampleEncryption(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (d Decrypter) decryptFragmentTrack(data []byte, t *FragmentTrack, p MoovProcessor) (err error) {
+	senc := t.SampleEncryption
+	scheme, err := p.encryptionScheme()
+	if err != nil {
+		return
+	}
+	cryptByteBlock, skipByteBlock, err := p.encryptionPattern()
+	if err != nil {
+		return
+	}
+	// cbcs restarts the cipher block chain at each subsample, the PIFF
+	// AES-CBC mode chains the subsamples of a sample like cbc1.
+	chained := false
+	kid := p.KID
+	if senc.Mp4BoxFlags()&mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS > 0 {
+		kid = senc.KID
+		switch senc.AlgorithmID {
+		case mp4.PiffNotEncrypted:
+			return
+		case mp4.PiffAES128CTR:
+			scheme = mp4.CencFourCC
+		case mp4.PiffAES128CBC:
+			scheme = CbcsFourCC
+			cryptByteBlock, skipByteBlock, chained = 0, 0, true
+		default:
+			err = fmt.Errorf("PIFF algorithm %d: %w", senc.AlgorithmID, ErrUnknownCodec)
+			return
+		}
+	}
+	key, err := d.key(kid)
+	if err != nil {
+		return
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = fmt.Errorf("key of KID %x: %w", kid, ErrInvalidParam)
+		return
+	}
+	ranges, err := t.sampleRanges()
+	if err != nil {
+		return
+	}
+	if len(senc.Samples) != len(ranges) {
+		err = fmt.Errorf("track %d has %d samples but sample encryption data for %d: %w", t.Header.TrackID, len(ranges), len(senc.Samples), ErrInvalidParam)
+		return
+	}
+	for i, r := range ranges {
+		sample := data[r.offset : r.offset+r.size]
+		entry := senc.Samples[i]
+		iv := entry.InitializationVector
+		switch scheme {
+		case mp4.CencFourCC:
+			err = cryptSampleCTR(block, iv, entry.Subsamples, sample)
+		case CbcsFourCC:
+			if len(iv) == 0 {
+				iv = p.ConstantIV
+			}
+			err = cryptSampleCBC(cipher.NewCBCDecrypter, block, iv, entry.Subsamples, sample, cryptByteBlock, skipByteBlock, chained)
+		}
+		if err != nil {
+			err = fmt.Errorf("track %d sample %d: %w", t.Header.TrackID, i, err)
+			return
+		}
+	}
+	return
+}
+
+// key returns the content key of kid, from the Keys or the KeyFunc.
+func (d Decrypter) key(kid [16]byte) (key []byte, err error) {
+	if key, ok := d.Keys[kid]; ok {
+		return key, nil
+	}
+	if d.KeyFunc == nil {
+		err = fmt.Errorf("KID %x: %w", kid, ErrKeyNotFound)
+		return
+	}
+	if key, err = d.KeyFunc(kid); err != nil {
+		err = fmt.Errorf("KID %x: %w", kid, err)
+	}
+	return
+}
+
+// cryptSampleCTR encrypts or decrypts in place a sample of the 'cenc' scheme,
+// ISO/IEC 23001-7 10.1. The protected ranges of the subsamples, or the whole
+// sample if it has none, form a single key stream.
+func cryptSampleCTR(block cipher.Block, iv []byte, subsamples []mp4.SampleEncryptionSubsampleEntry, sample []byte) (err error) {
+	if len(iv) != 8 && len(iv) != 16 {
+		err = fmt.Errorf("IV of %d bytes: %w", len(iv), ErrInvalidParam)
+		return
+	}
+	stream := newCTRStream(block, iv)
+	if len(subsamples) == 0 {
+		stream.XORKeyStream(sample, sample)
+		return
+	}
+	var offset int64
+	for _, subsample := range subsamples {
+		offset += int64(subsample.BytesOfClearData)
+		end := offset + int64(subsample.BytesOfProtectedData)
+		if end > int64(len(sample)) {
+			err = fmt.Errorf("subsamples exceed the sample of %d bytes: %w", len(sample), ErrInvalidParam)
+			return
+		}
+		stream.XORKeyStream(sample[offset:end], sample[offset:end])
+		offset = end
+	}
+	return
+}
+
+// cryptSampleCBC encrypts or decrypts in place, depending on newMode, a
+// sample of the 'cbcs' scheme, ISO/IEC 23001-7 10.4. In each protected range
+// of the subsamples, or in the whole sample if it has none, the first
+// cryptByteBlock of every cryptByteBlock+skipByteBlock 16-byte blocks are
+// encrypted, or every block with the 0:0 pattern, and a trailing partial
+// block is left clear. The cipher block chain restarts with the IV at each
+// protected range unless chained.
+func cryptSampleCBC(newMode func(cipher.Block, []byte) cipher.BlockMode, block cipher.Block, iv []byte, subsamples []mp4.SampleEncryptionSubsampleEntry, sample []byte, cryptByteBlock, skipByteBlock uint8, chained bool) (err error) {
+	if len(iv) != 8 && len(iv) != 16 {
+		err = fmt.Errorf("IV of %d bytes: %w", len(iv), ErrInvalidParam)
+		return
+	}
+	iv16 := make([]byte, aes.BlockSize)
+	copy(iv16, iv)
+	if len(subsamples) == 0 {
+		subsamples = []mp4.SampleEncryptionSubsampleEntry{{BytesOfProtectedData: uint32(len(sample))}}
+	}
+	cryptSize, patternSize := len(sample), len(sample)
+	if cryptByteBlock > 0 {
+		cryptSize = int(cryptByteBlock) * aes.BlockSize
+		patternSize = int(cryptByteBlock+skipByteBlock) * aes.BlockSize
+	}
+	var mode cipher.BlockMode
+	var offset int64
+	for _, subsample := range subsamples {
+		offset += int64(subsample.BytesOfClearData)
+		end := offset + int64(subsample.BytesOfProtectedData)
+		if end > int64(len(sample)) {
+			err = fmt.Errorf("subsamples exceed the sample of %d bytes: %w", len(sample), ErrInvalidParam)
+			return
+		}
+		if mode == nil || !chained {
+			mode = newMode(block, iv16)
+		}
+		protected := sample[offset:end]
+		for i := 0; i < len(protected); i += patternSize {
+			n := len(protected) - i
+			if n > cryptSize {
+				n = cryptSize
+			}
+			n -= n % aes.BlockSize
+			mode.CryptBlocks(protected[i:i+n], protected[i:i+n])
+		}
+		offset = end
+	}
+	return
+}
+
+// ctrStream is the AES-CTR key stream of Common Encryption, whose block
+// counter is the lower 8 bytes of the counter block and wraps around without
+// carrying into the IV, unlike the counter of cipher.NewCTR.
+type ctrStream struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+}
+
+func newCTRStream(block cipher.Block, iv []byte) *ctrStream {
+	s := &ctrStream{block: block, used: aes.BlockSize}
+	copy(s.counter[:], iv)
+	return s
+}
+
+func (s *ctrStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == aes.BlockSize {
+			s.block.Encrypt(s.stream[:], s.counter[:])
+			binary.BigEndian.PutUint64(s.counter[8:], binary.BigEndian.Uint64(s.counter[8:])+1)
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.stream[s.used]
+		s.used++
+	}
+}
+
+// removeSampleEncryption removes the sample encryption data from the track
+// fragment: the senc or PIFF box, the saiz and saio boxes locating it, and
+// the sample groups of the 'seig' type that vary the encryption parameters.
+func (t *FragmentTrack) removeSampleEncryption() (err error) {
+	var children []mp4.Box
+	for _, child := range t.Traf.Mp4BoxChildren() {
+		switch child.(type) {
+		case *SampleEncryptionBox, *SampleAuxiliaryInformationSizesBox, *SampleAuxiliaryInformationOffsetsBox:
+			continue
+		}
+		if isSeigSampleGroup(child) {
+			continue
+		}
+		children = append(children, child)
+	}
+	if err = t.Traf.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	t.SampleEncryption, t.AuxInfoSizes, t.AuxInfoOffsets = nil, nil, nil
+	return
+}
+
+// isSeigSampleGroup reports whether the box is a sbgp or sgpd box of the
+// 'seig' grouping type, which the mp4 package reads as unknown boxes.
+func isSeigSampleGroup(box mp4.Box) bool {
+	unknown, ok := box.(*mp4.UnknownBox)
+	if !ok || (unknown.Type != SbgpBoxType && unknown.Type != SgpdBoxType) {
+		return false
+	}
+	// grouping_type follows the version and flags
+	return len(unknown.Data) >= 8 && bytes.Equal(unknown.Data[4:8], SeigFourCC[:])
+}
+
+// WithoutProtection returns the track with its protection removed, whose
+// init segment carries the original sample entry of the codec instead of the
+// encv or enca sample entry and no pssh boxes, for decrypted fragments.
+func (p MoovProcessor) WithoutProtection() MoovProcessor {
+	p.Protected = false
+	return p
+}
+
+// WithoutProtection returns the tracks with their protection removed, see
+// MoovProcessor.WithoutProtection.
+func (m MultiTrackMoovProcessor) WithoutProtection() MultiTrackMoovProcessor {
+	tracks := make([]MoovProcessor, len(m.Tracks))
+	for i, track := range m.Tracks {
+		tracks[i] = track.WithoutProtection()
+	}
+	m.Tracks = tracks
+	return m
+}
@@ -0,0 +1,549 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+// the AES-128 key and blocks of the examples of NIST SP 800-38A, appendix F.
+const (
+	nistKey        = "2b7e151628aed2a6abf7158809cf4f3c"
+	nistPlaintext  = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+	nistCTRCounter = "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"
+	nistCTR        = "874d6191b620e3261bef6864990db6ce9806f66b7970fdff8617187bb9fffdff5ae4df3edbd5d35e5b4f09020db03eab1e031dda2fbe03d1792170a0f3009cee"
+	nistCBCIV      = "000102030405060708090a0b0c0d0e0f"
+	nistCBC        = "7649abac8119b246cee98e9b12e9197d5086cb9b507219ee95db113a917678b273bed6b8e3c1743b7116e69e222295163ff1caa1681fac09120eca307586e1a7"
+)
+
+func TestCryptSampleCTR(t *testing.T) {
+	block, err := aes.NewCipher(decodeHex(t, nistKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		iv         string
+		subsamples []mp4.SampleEncryptionSubsampleEntry
+		sample     string
+		want       string
+		wantErr    error
+	}{
+		{
+			name:   "whole sample",
+			iv:     nistCTRCounter,
+			sample: nistPlaintext,
+			want:   nistCTR,
+		},
+		{
+			name:   "8-byte IV",
+			iv:     "0001020304050607",
+			sample: strings.Repeat("00", 32),
+			want:   "720f9ee37b13a7c8b98e955d56b0f313" + "a4311323030ec025f9378c50b39e26dc",
+		},
+		{
+			name:   "block counter wraps without carrying into the IV",
+			iv:     "0001020304050607ffffffffffffffff",
+			sample: strings.Repeat("00", 32),
+			want:   "3d88a68db0f3e3c66e7fd8c1b1cb797a" + "720f9ee37b13a7c8b98e955d56b0f313",
+		},
+		{
+			name:       "subsamples form a single key stream",
+			iv:         nistCTRCounter,
+			subsamples: []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 2, BytesOfProtectedData: 10}, {BytesOfClearData: 3, BytesOfProtectedData: 22}},
+			sample:     "c1c1" + nistPlaintext[:20] + "c2c2c2" + nistPlaintext[20:64],
+			want:       "c1c1" + nistCTR[:20] + "c2c2c2" + nistCTR[20:64],
+		},
+		{
+			name:       "subsamples exceed the sample",
+			iv:         nistCTRCounter,
+			subsamples: []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 2, BytesOfProtectedData: 16}},
+			sample:     strings.Repeat("00", 16),
+			wantErr:    ErrInvalidParam,
+		},
+		{
+			name:    "12-byte IV",
+			iv:      "000102030405060708090a0b",
+			sample:  strings.Repeat("00", 16),
+			wantErr: ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample := decodeHex(t, tt.sample)
+			err := cryptSampleCTR(block, decodeHex(t, tt.iv), tt.subsamples, sample)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("cryptSampleCTR() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if want := decodeHex(t, tt.want); !bytes.Equal(sample, want) {
+				t.Errorf("cryptSampleCTR() = %x, want %x", sample, want)
+			}
+		})
+	}
+}
+
+// testKey is the content key of testPlayReadyKID in the tests.
+var testKey = bytes.Repeat([]byte{0x3c}, 16)
+
+// testCTRKeyStream returns the first bytes of the 'cenc' key stream of
+// testKey and the 8-byte IV iv.
+func testCTRKeyStream(t *testing.T, iv []byte, n int) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counter, stream [aes.BlockSize]byte
+	copy(counter[:], iv)
+	block.Encrypt(stream[:], counter[:])
+	return stream[:n]
+}
+
+func TestDecrypterDecryptFragment(t *testing.T) {
+	tests := []struct {
+		name        string
+		senc        func() *SampleEncryptionBox
+		cenc        bool
+		overrideKID bool
+	}{
+		{name: "PIFF", senc: func() *SampleEncryptionBox { return testSampleEncryptionBox(8, true) }},
+		{name: "senc", senc: func() *SampleEncryptionBox { return testSampleEncryptionBox(8, false) }},
+		{name: "senc with saiz and saio", senc: func() *SampleEncryptionBox { return testSampleEncryptionBox(8, true) }, cenc: true},
+		{
+			name: "PIFF override of the KID",
+			senc: func() *SampleEncryptionBox {
+				senc := testSampleEncryptionBox(8, true)
+				senc.AlgorithmID, senc.KID = mp4.PiffAES128CTR, [16]byte(testPlayReadyKID)
+				senc.Mp4BoxSetFlags(senc.Mp4BoxFlags() | mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS)
+				return senc
+			},
+			overrideKID: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, tt.senc())))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.cenc {
+				if err = f.ConvertToCENC(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p := MoovProcessor{Protected: true, KID: [16]byte(testPlayReadyKID)}
+			if tt.overrideKID {
+				p.KID = [16]byte{}
+			}
+			d := Decrypter{Keys: map[[16]byte][]byte{[16]byte(testPlayReadyKID): testKey}}
+			if err = d.DecryptFragment(&f, p); err != nil {
+				t.Fatal(err)
+			}
+			// the first sample has 8 protected bytes after 2 clear ones, the
+			// second is clear
+			want := bytes.Repeat([]byte{0xaa}, 16)
+			for i, k := range testCTRKeyStream(t, make([]byte, 8), 8) {
+				want[2+i] ^= k
+			}
+			if !bytes.Equal(f.Data, want) {
+				t.Errorf("Data = %x, want %x", f.Data, want)
+			}
+			track := f.Tracks[0]
+			if track.SampleEncryption != nil || track.AuxInfoSizes != nil || track.AuxInfoOffsets != nil {
+				t.Error("sample encryption data kept")
+			}
+			for _, child := range track.Traf.Mp4BoxChildren() {
+				switch child.Mp4BoxType() {
+				case mp4.UuidBoxType, mp4.SencBoxType, SaizBoxType, SaioBoxType:
+					t.Errorf("%s box kept in the traf box", child.Mp4BoxType())
+				}
+			}
+		})
+	}
+}
+
+func TestDecrypterDecryptFragmentErrors(t *testing.T) {
+	kid := [16]byte(testPlayReadyKID)
+	tests := []struct {
+		name    string
+		senc    *SampleEncryptionBox
+		p       MoovProcessor
+		keys    map[[16]byte][]byte
+		wantErr error
+	}{
+		{name: "no key", senc: testSampleEncryptionBox(8, true), p: MoovProcessor{KID: kid}, keys: map[[16]byte][]byte{}, wantErr: ErrKeyNotFound},
+		{name: "short key", senc: testSampleEncryptionBox(8, true), p: MoovProcessor{KID: kid}, keys: map[[16]byte][]byte{kid: {1, 2, 3}}, wantErr: ErrInvalidParam},
+		{
+			name: "unknown PIFF algorithm",
+			senc: func() *SampleEncryptionBox {
+				senc := testSampleEncryptionBox(8, true)
+				senc.AlgorithmID, senc.KID = 3, kid
+				senc.Mp4BoxSetFlags(senc.Mp4BoxFlags() | mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS)
+				return senc
+			}(),
+			wantErr: ErrUnknownCodec,
+		},
+		{name: "cbcs pattern over 4 bits", senc: testSampleEncryptionBox(16, false), p: MoovProcessor{KID: kid, EncryptionScheme: CbcsFourCC, CryptByteBlock: 16}, wantErr: ErrInvalidParam},
+		{
+			name: "sample count",
+			senc: &SampleEncryptionBox{IVSize: 8, Samples: []mp4.SampleEncryptionSampleEntry{{InitializationVector: make([]byte, 8)}}},
+			p:    MoovProcessor{KID: kid}, wantErr: ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, tt.senc)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := tt.keys
+			if keys == nil {
+				keys = map[[16]byte][]byte{kid: testKey}
+			}
+			if err = (Decrypter{Keys: keys}).DecryptFragment(&f, tt.p); !errors.Is(err, tt.wantErr) {
+				t.Errorf("DecryptFragment() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecrypterDecryptFragmentNotEncrypted(t *testing.T) {
+	senc := testSampleEncryptionBox(8, true)
+	senc.AlgorithmID = mp4.PiffNotEncrypted
+	senc.Mp4BoxSetFlags(senc.Mp4BoxFlags() | mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS)
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, senc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = (Decrypter{}).DecryptFragment(&f, MoovProcessor{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, bytes.Repeat([]byte{0xaa}, 16)) || f.Tracks[0].SampleEncryption != nil {
+		t.Errorf("Data = %x, sample encryption %v, want the data unchanged without sample encryption", f.Data, f.Tracks[0].SampleEncryption)
+	}
+}
+
+func TestRemoveSampleEncryptionSeigGroups(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, testSampleEncryptionBox(8, false))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seig := func(boxType mp4.BoxType, groupingType string) mp4.Box {
+		return &mp4.UnknownBox{Header: mp4.Header{Type: boxType}, Data: append([]byte{0, 0, 0, 0}, groupingType...)}
+	}
+	track := &f.Tracks[0]
+	children := append(track.Traf.Mp4BoxChildren(), seig(SbgpBoxType, "seig"), seig(SgpdBoxType, "seig"), seig(SbgpBoxType, "roll"))
+	if err = track.Traf.Mp4BoxReplaceChildren(children); err != nil {
+		t.Fatal(err)
+	}
+	if err = track.removeSampleEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	var types []mp4.BoxType
+	for _, child := range track.Traf.Mp4BoxChildren() {
+		types = append(types, child.Mp4BoxType())
+	}
+	want := []mp4.BoxType{mp4.TfhdBoxType, mp4.TrunBoxType, SbgpBoxType}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("traf children %v, want %v", types, want)
+	}
+}
+
+func TestWithoutProtection(t *testing.T) {
+	m := MultiTrackMoovProcessor{Tracks: []MoovProcessor{{Protected: true, KID: [16]byte{1}}, {Protected: true}}}
+	clear := m.WithoutProtection()
+	for i, track := range clear.Tracks {
+		if track.Protected {
+			t.Errorf("track %d still protected", i)
+		}
+	}
+	if !m.Tracks[0].Protected {
+		t.Error("WithoutProtection() modified the tracks of the receiver")
+	}
+	if clear.Tracks[0].KID != m.Tracks[0].KID {
+		t.Errorf("KID %x, want %x", clear.Tracks[0].KID, m.Tracks[0].KID)
+	}
+}
+
+func TestCryptSampleCBC(t *testing.T) {
+	block, err := aes.NewCipher(decodeHex(t, nistKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1, p2 := nistPlaintext[:32], nistPlaintext[32:64]
+	c1, c2 := nistCBC[:32], nistCBC[32:64]
+	tests := []struct {
+		name                          string
+		iv                            string
+		subsamples                    []mp4.SampleEncryptionSubsampleEntry
+		cryptByteBlock, skipByteBlock uint8
+		chained                       bool
+		sample                        string
+		want                          string
+		wantErr                       error
+	}{
+		{
+			name:   "whole sample with a trailing partial block",
+			iv:     nistCBCIV,
+			sample: nistPlaintext + "eeeeeeeeee",
+			want:   nistCBC + "eeeeeeeeee",
+		},
+		{
+			name:           "1:9 pattern chains across skipped blocks",
+			iv:             nistCBCIV,
+			cryptByteBlock: 1,
+			skipByteBlock:  9,
+			sample:         p1 + strings.Repeat("00", 9*16) + p2,
+			want:           c1 + strings.Repeat("00", 9*16) + c2,
+		},
+		{
+			name:       "each subsample restarts with the IV",
+			iv:         nistCBCIV,
+			subsamples: []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 3, BytesOfProtectedData: 16}, {BytesOfClearData: 2, BytesOfProtectedData: 16}},
+			sample:     "aaaaaa" + p1 + "bbbb" + p1,
+			want:       "aaaaaa" + c1 + "bbbb" + c1,
+		},
+		{
+			name:       "chained subsamples",
+			iv:         nistCBCIV,
+			subsamples: []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 3, BytesOfProtectedData: 16}, {BytesOfClearData: 2, BytesOfProtectedData: 16}},
+			chained:    true,
+			sample:     "aaaaaa" + p1 + "bbbb" + p2,
+			want:       "aaaaaa" + c1 + "bbbb" + c2,
+		},
+		{
+			name:   "8-byte IV padded with zeros",
+			iv:     "0001020304050607",
+			sample: p1,
+			want:   "d85382de04a76fe6e606fa5ee0c739f0",
+		},
+		{
+			name:    "12-byte IV",
+			iv:      "000102030405060708090a0b",
+			sample:  p1,
+			wantErr: ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iv := decodeHex(t, tt.iv)
+			sample := decodeHex(t, tt.sample)
+			err := cryptSampleCBC(cipher.NewCBCEncrypter, block, iv, tt.subsamples, sample, tt.cryptByteBlock, tt.skipByteBlock, tt.chained)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("cryptSampleCBC() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if want := decodeHex(t, tt.want); !bytes.Equal(sample, want) {
+				t.Fatalf("cryptSampleCBC() = %x, want %x", sample, want)
+			}
+			if err = cryptSampleCBC(cipher.NewCBCDecrypter, block, iv, tt.subsamples, sample, tt.cryptByteBlock, tt.skipByteBlock, tt.chained); err != nil {
+				t.Fatal(err)
+			}
+			if want := decodeHex(t, tt.sample); !bytes.Equal(sample, want) {
+				t.Errorf("decrypted sample = %x, want %x", sample, want)
+			}
+		})
+	}
+}
+
+func TestEncryptionPattern(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		wantCrypt uint8
+		wantSkip  uint8
+		wantErr   error
+	}{
+		{name: "video default", processor: MoovProcessor{StreamType: VideoStream}, wantCrypt: 1, wantSkip: 9},
+		{name: "audio default", processor: MoovProcessor{StreamType: AudioStream}},
+		{name: "set", processor: MoovProcessor{StreamType: VideoStream, CryptByteBlock: 5, SkipByteBlock: 5}, wantCrypt: 5, wantSkip: 5},
+		{name: "over 4 bits", processor: MoovProcessor{SkipByteBlock: 16}, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crypt, skip, err := tt.processor.encryptionPattern()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("encryptionPattern() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (crypt != tt.wantCrypt || skip != tt.wantSkip) {
+				t.Errorf("encryptionPattern() = %d:%d, want %d:%d", crypt, skip, tt.wantCrypt, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestDecrypterDecryptFragmentCBCS(t *testing.T) {
+	block, err := aes.NewCipher(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	constantIV := bytes.Repeat([]byte{0x42}, 16)
+	plaintext := bytes.Repeat([]byte{0xaa}, 16)
+	encrypted := make([]byte, 16)
+	cipher.NewCBCEncrypter(block, constantIV).CryptBlocks(encrypted, plaintext)
+
+	// the samples of testFragment are merged into one of a single block
+	senc := &SampleEncryptionBox{Samples: []mp4.SampleEncryptionSampleEntry{{InitializationVector: []byte{}}, {InitializationVector: []byte{}}}}
+	senc.Type = mp4.SencBoxType
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, senc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	track := f.Tracks[0]
+	track.Runs[0].SampleCount, track.Runs[0].Samples = 1, []mp4.TrackRunSampleEntry{{SampleDuration: 2000, SampleSize: 16}}
+	track.SampleEncryption.Samples = track.SampleEncryption.Samples[:1]
+	copy(f.Data, encrypted)
+
+	p := MoovProcessor{StreamType: AudioStream, KID: [16]byte(testPlayReadyKID), EncryptionScheme: CbcsFourCC, ConstantIV: constantIV}
+	d := Decrypter{Keys: map[[16]byte][]byte{[16]byte(testPlayReadyKID): testKey}}
+	if err = d.DecryptFragment(&f, p); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, plaintext) {
+		t.Errorf("Data = %x, want %x", f.Data, plaintext)
+	}
+}
+
+func TestDecrypterKey(t *testing.T) {
+	kid := [16]byte(testPlayReadyKID)
+	errLicense := errors.New("license denied")
+	tests := []struct {
+		name    string
+		d       Decrypter
+		want    []byte
+		wantErr error
+	}{
+		{name: "keys", d: Decrypter{Keys: map[[16]byte][]byte{kid: testKey}}, want: testKey},
+		{name: "key function", d: Decrypter{KeyFunc: func(k [16]byte) ([]byte, error) { return testKey, nil }}, want: testKey},
+		{name: "keys before the key function", d: Decrypter{Keys: map[[16]byte][]byte{kid: testKey}, KeyFunc: func(k [16]byte) ([]byte, error) { return nil, errLicense }}, want: testKey},
+		{name: "key function error", d: Decrypter{KeyFunc: func(k [16]byte) ([]byte, error) { return nil, errLicense }}, wantErr: errLicense},
+		{name: "no key", d: Decrypter{Keys: map[[16]byte][]byte{}}, wantErr: ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.d.key(kid)
+			if !errors.Is(err, tt.wantErr) || !bytes.Equal(key, tt.want) {
+				t.Errorf("key() = %x, %v, want %x, %v", key, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testProtectedServer serves testManifest protected by PlayReady, of the KID
+// testPlayReadyKID, with fragments encrypted by testKey.
+func testProtectedServer(t *testing.T) (srv *httptest.Server, manifestURL *url.URL) {
+	t.Helper()
+	pro := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(testWRMHeader40)}}}.Bytes()
+	manifest := strings.Replace(testManifest, `</SmoothStreamingMedia>`,
+		`<Protection><ProtectionHeader SystemID="9a04f079-9840-4286-ab92-e65be0885f95">`+base64.StdEncoding.EncodeToString(pro)+`</ProtectionHeader></Protection></SmoothStreamingMedia>`, 1)
+	fragment := testFragment(t, testSampleEncryptionBox(8, true))
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/movie.ism/Manifest" {
+			io.WriteString(w, manifest)
+			return
+		}
+		w.Write(fragment)
+	}))
+	manifestURL, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return
+}
+
+// checkDecryptedOutput checks that the output file at path holds the clear
+// track of testProtectedServer.
+func checkDecryptedOutput(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(data)
+	init, err := ParseInitSegment(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := init.Tracks[0]; p.Protected || p.Codec != mp4.Avc1FourCC {
+		t.Errorf("init segment protected %v, codec %s, want a clear avc1 track", p.Protected, p.Codec)
+	}
+	want := bytes.Repeat([]byte{0xaa}, 16)
+	for i, k := range testCTRKeyStream(t, make([]byte, 8), 8) {
+		want[2+i] ^= k
+	}
+	var n int
+	for ; r.Len() > 0; n++ {
+		f, err := ParseFragment(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(f.Data, want) || f.Tracks[0].SampleEncryption != nil {
+			t.Errorf("fragment %d of data %x, sample encryption %v, want %x in the clear", n, f.Data, f.Tracks[0].SampleEncryption, want)
+		}
+	}
+	if n != 3 {
+		t.Errorf("got %d fragments, want 3", n)
+	}
+}
+
+func TestDownloaderDecrypt(t *testing.T) {
+	srv, u := testProtectedServer(t)
+	defer srv.Close()
+	kid := [16]byte(testPlayReadyKID)
+	var mu sync.Mutex
+	var requested int
+	tests := []struct {
+		name    string
+		d       Decrypter
+		wantErr error
+	}{
+		{name: "keys", d: Decrypter{Keys: map[[16]byte][]byte{kid: testKey}}},
+		{
+			name: "key function",
+			d: Decrypter{KeyFunc: func(k [16]byte) ([]byte, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if k != kid {
+					t.Errorf("requested the key of %x, want %x", k, kid)
+				}
+				requested++
+				return testKey, nil
+			}},
+		},
+		{name: "no key", d: Decrypter{Keys: map[[16]byte][]byte{}}, wantErr: ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks, err := Downloader{OutputDir: t.TempDir(), Decrypter: &tt.d}.Download(context.Background(), u)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Download() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				checkDecryptedOutput(t, tracks[0].Path)
+			}
+		})
+	}
+	if requested != 3 {
+		t.Errorf("requested the key %d times, want once per fragment", requested)
+	}
+}
@@ -0,0 +1,368 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// Defragmenter writes the fragments of the tracks of a presentation as a
+// progressive MP4 file, whose moov box indexes every sample in the sample
+// tables of its tracks, for players and tools that do not support fragmented
+// files.
+//
+// The sample data of the fragments is written as they are added, in a single
+// mdat box following the ftyp box. Close then writes the moov box after it and
+// patches the size of the mdat box, hence the io.WriteSeeker.
+type Defragmenter struct {
+	// Faststart places the moov box before the mdat box, so that the file
+	// can be played while it is downloaded over HTTP. Close then moves the
+	// sample data after the moov box, which requires the writer to be an
+	// io.ReaderAt as well, as an os.File is.
+	Faststart bool
+
+	w      io.WriteSeeker
+	tracks MultiTrackMoovProcessor
+	tables []sampleTable
+
+	// the offset of the mdat box and the current offset in w.
+	mdatOffset uint64
+	offset     uint64
+}
+
+// sampleTable accumulates the sample tables of a track of a Defragmenter.
+// Each track run of a fragment becomes a chunk.
+type sampleTable struct {
+	timeToSample       []mp4.TimeToSampleEntry
+	compositionOffsets []mp4.CompositionOffsetEntry
+	sampleToChunk      []mp4.SampleToChunkEntry
+	sampleSizes        []uint32
+	chunkOffsets       []uint64
+	syncSamples        []uint32
+
+	sampleCount uint32
+	duration    uint64
+}
+
+// NewDefragmenter writes the ftyp box and the header of the mdat box of a
+// progressive MP4 file with the given tracks to w. The file is written from
+// the current offset of w. Unless the brands of the first track are set, the
+// file is branded isom, compatible with isom and iso2.
+//
+// The fragments must be clear, as the file carries the original sample entry
+// of the protected tracks, see Decrypter.
+func NewDefragmenter(w io.WriteSeeker, tracks MultiTrackMoovProcessor) (d *Defragmenter, err error) {
+	d = &Defragmenter{
+		w:      w,
+		tracks: tracks.WithoutProtection(),
+		tables: make([]sampleTable, len(tracks.Tracks)),
+	}
+	if len(d.tracks.Tracks) > 0 && d.tracks.Tracks[0].MajorBrand == (mp4.FourCC{}) {
+		d.tracks.Tracks[0].MajorBrand = mp4.IsomFourCC
+		d.tracks.Tracks[0].CompatibleBrands = []mp4.FourCC{mp4.IsomFourCC, mp4.Iso2FourCC}
+	}
+	ftyp, err := d.tracks.CreateFtypMp4Box()
+	if err != nil {
+		return
+	}
+	offset, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	n, err := writeMp4Boxes(w, ftyp)
+	if err != nil {
+		return
+	}
+	d.mdatOffset = uint64(offset + n)
+	if err = d.writeMdatHeader(0); err != nil {
+		return
+	}
+	d.offset = d.mdatOffset + 16
+	return
+}
+
+// writeMdatHeader writes at the current offset the 16-byte header of an mdat
+// box with a payload of the given size: a free box followed by the mdat box
+// header, or the mdat box header with a largesize if the size exceeds 32
+// bits. The free box reserves the room of the largesize, so the header is
+// written before the size of the payload is known.
+func (d *Defragmenter) writeMdatHeader(size uint64) (err error) {
+	header := make([]byte, 16)
+	if size+8 <= 0xffffffff {
+		binary.BigEndian.PutUint32(header, 8)
+		copy(header[4:], mp4.FreeBoxType[:])
+		binary.BigEndian.PutUint32(header[8:], uint32(size+8))
+		copy(header[12:], mp4.MdatBoxType[:])
+	} else {
+		binary.BigEndian.PutUint32(header, 1)
+		copy(header[4:], mp4.MdatBoxType[:])
+		binary.BigEndian.PutUint64(header[8:], size+16)
+	}
+	_, err = d.w.Write(header)
+	return
+}
+
+// WriteFragment appends the samples of a fragment to the mdat box. Its track
+// fragments are matched to the tracks of the file by track ID, and must
+// follow the preceding fragments of their track in decode order.
+func (d *Defragmenter) WriteFragment(f Fragment) (err error) {
+	for _, t := range f.Tracks {
+		if t.SampleEncryption != nil {
+			err = fmt.Errorf("track %d fragment is encrypted: %w", t.Header.TrackID, ErrInvalidParam)
+			return
+		}
+		if d.trackIndex(t.Header.TrackID) < 0 {
+			err = fmt.Errorf("track %d not in the file: %w", t.Header.TrackID, ErrInvalidParam)
+			return
+		}
+	}
+	for _, t := range f.Tracks {
+		i := d.trackIndex(t.Header.TrackID)
+		var samples []fragmentSample
+		if samples, err = t.samples(d.tracks.Tracks[i]); err != nil {
+			return
+		}
+		for j, run := range t.Runs {
+			d.tables[i].addChunk(d.offset+uint64(t.runOffsets[j]), samples[:run.SampleCount])
+			samples = samples[run.SampleCount:]
+		}
+	}
+	if _, err = d.w.Write(f.Data); err != nil {
+		return
+	}
+	d.offset += uint64(len(f.Data))
+	return
+}
+
+func (d *Defragmenter) trackIndex(trackID uint32) int {
+	for i, track := range d.tracks.Tracks {
+		if track.TrackID == trackID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Close patches the size of the mdat box and writes the moov box, whose
+// tracks last as long as their samples. It does not close the underlying
+// writer.
+func (d *Defragmenter) Close() (err error) {
+	if d.Faststart {
+		return d.closeFaststart()
+	}
+	moov, err := d.createMoovMp4Box(0)
+	if err != nil {
+		return
+	}
+	if _, err = d.w.Seek(int64(d.mdatOffset), io.SeekStart); err != nil {
+		return
+	}
+	if err = d.writeMdatHeader(d.offset - d.mdatOffset - 16); err != nil {
+		return
+	}
+	if _, err = d.w.Seek(int64(d.offset), io.SeekStart); err != nil {
+		return
+	}
+	_, err = writeMp4Boxes(d.w, moov)
+	return
+}
+
+// closeFaststart moves the mdat box after the room of the moov box and
+// writes the moov box before it. The chunk offsets are shifted by the size of
+// the moov box, which grows if the shift requires a co64 box.
+func (d *Defragmenter) closeFaststart() (err error) {
+	r, ok := d.w.(io.ReaderAt)
+	if !ok {
+		err = fmt.Errorf("faststart requires an io.ReaderAt writer: %w", ErrInvalidParam)
+		return
+	}
+	var shift uint64
+	moov, err := d.createMoovMp4Box(shift)
+	for err == nil && uint64(moov.Mp4BoxSize()) != shift {
+		shift = uint64(moov.Mp4BoxSize())
+		moov, err = d.createMoovMp4Box(shift)
+	}
+	if err != nil {
+		return
+	}
+
+	// move the data from the end, as the ranges overlap
+	buf := make([]byte, 1<<20)
+	for end := d.offset; end > d.mdatOffset; {
+		n := uint64(len(buf))
+		if end-d.mdatOffset < n {
+			n = end - d.mdatOffset
+		}
+		end -= n
+		if _, err = r.ReadAt(buf[:n], int64(end)); err != nil {
+			return
+		}
+		if _, err = d.w.Seek(int64(end+shift), io.SeekStart); err != nil {
+			return
+		}
+		if _, err = d.w.Write(buf[:n]); err != nil {
+			return
+		}
+	}
+
+	if _, err = d.w.Seek(int64(d.mdatOffset), io.SeekStart); err != nil {
+		return
+	}
+	if _, err = writeMp4Boxes(d.w, moov); err != nil {
+		return
+	}
+	if err = d.writeMdatHeader(d.offset - d.mdatOffset - 16); err != nil {
+		return
+	}
+	_, err = d.w.Seek(int64(d.offset+shift), io.SeekStart)
+	return
+}
+
+// createMoovMp4Box returns the moov box of the file: the moov box of the
+// init segment of the tracks, without mvex box, with the sample tables of the
+// tracks filled in and their chunk offsets shifted by shift.
+func (d *Defragmenter) createMoovMp4Box(shift uint64) (moov mp4.Box, err error) {
+	m := d.tracks
+	m.Tracks = make([]MoovProcessor, len(d.tracks.Tracks))
+	for i, track := range d.tracks.Tracks {
+		track.Duration = d.tables[i].duration
+		track.DurationInTimescale = true
+		track.EmitMehd = false
+		m.Tracks[i] = track
+	}
+	if moov, err = m.CreateMoovMp4Box(); err != nil {
+		return
+	}
+	var children []mp4.Box
+	var traks int
+	for _, child := range moov.Mp4BoxChildren() {
+		switch child.Mp4BoxType() {
+		case mp4.MvexBoxType:
+			continue
+		case mp4.TrakBoxType:
+			stbl := findMp4Box(child, mp4.MdiaBoxType, mp4.MinfBoxType, mp4.StblBoxType)
+			if stbl == nil {
+				err = fmt.Errorf("track %d has no stbl box: %w", m.Tracks[traks].TrackID, ErrInvalidParam)
+				return
+			}
+			if err = d.tables[traks].fillStblMp4Box(stbl, shift); err != nil {
+				return
+			}
+			traks++
+		}
+		children = append(children, child)
+	}
+	if err = moov.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	moov.Mp4BoxUpdate()
+	return
+}
+
+// addChunk adds a chunk of samples at the given offset of the file.
+func (s *sampleTable) addChunk(offset uint64, samples []fragmentSample) {
+	if len(samples) == 0 {
+		return
+	}
+	s.chunkOffsets = append(s.chunkOffsets, offset)
+	if n := len(s.sampleToChunk); n == 0 || s.sampleToChunk[n-1].SamplesPerChunk != uint32(len(samples)) {
+		s.sampleToChunk = append(s.sampleToChunk, mp4.SampleToChunkEntry{
+			FirstChunk:            uint32(len(s.chunkOffsets)),
+			SamplesPerChunk:       uint32(len(samples)),
+			SampleDescrptionIndex: 1,
+		})
+	}
+	for _, sample := range samples {
+		s.sampleCount++
+		s.duration += uint64(sample.duration)
+		s.sampleSizes = append(s.sampleSizes, uint32(sample.size))
+		if sample.flags&SampleFlagsIsNonSync == 0 {
+			s.syncSamples = append(s.syncSamples, s.sampleCount)
+		}
+		if n := len(s.timeToSample); n > 0 && s.timeToSample[n-1].SampleDelta == sample.duration {
+			s.timeToSample[n-1].SampleCount++
+		} else {
+			s.timeToSample = append(s.timeToSample, mp4.TimeToSampleEntry{SampleCount: 1, SampleDelta: sample.duration})
+		}
+		if n := len(s.compositionOffsets); n > 0 && s.compositionOffsets[n-1].SampleOffset == sample.compositionTimeOffset {
+			s.compositionOffsets[n-1].SampleCount++
+		} else {
+			s.compositionOffsets = append(s.compositionOffsets, mp4.CompositionOffsetEntry{SampleCount: 1, SampleOffset: sample.compositionTimeOffset})
+		}
+	}
+}
+
+// fillStblMp4Box replaces the empty sample tables of an stbl box of an init
+// segment. The stss box is omitted when every sample is a sync sample, and
+// the ctts box when no sample has a composition time offset. Chunk offsets
+// beyond 4 GiB, after adding shift, are written in a co64 box.
+func (s sampleTable) fillStblMp4Box(stbl mp4.Box, shift uint64) (err error) {
+	var stsd mp4.Box
+	for _, child := range stbl.Mp4BoxChildren() {
+		if child.Mp4BoxType() == mp4.StsdBoxType {
+			stsd = child
+		}
+	}
+	if stsd == nil {
+		err = fmt.Errorf("stbl box has no stsd box: %w", ErrInvalidParam)
+		return
+	}
+	children := []mp4.Box{stsd, &mp4.TimeToSampleBox{Entries: s.timeToSample}}
+
+	var version uint8
+	var hasOffsets bool
+	for _, entry := range s.compositionOffsets {
+		if entry.SampleOffset != 0 {
+			hasOffsets = true
+		}
+		if entry.SampleOffset < 0 {
+			version = 1
+		}
+	}
+	if hasOffsets {
+		ctts := &mp4.CompositionOffsetBox{Entries: s.compositionOffsets}
+		ctts.Version = version
+		children = append(children, ctts)
+	}
+
+	if len(s.syncSamples) < int(s.sampleCount) {
+		children = append(children, &mp4.SyncSampleBox{SampleNumbers: s.syncSamples})
+	}
+
+	stsz := &SampleSizeBox{EntrySizes: s.sampleSizes}
+	if len(s.sampleSizes) > 0 && allEqualSizes(s.sampleSizes) {
+		stsz.SampleSize = s.sampleSizes[0]
+		stsz.SampleCount = uint32(len(s.sampleSizes))
+		stsz.EntrySizes = nil
+	}
+	children = append(children, &mp4.SampleToChunkBox{Entries: s.sampleToChunk}, stsz)
+
+	offsets := make([]uint64, len(s.chunkOffsets))
+	var largeOffsets bool
+	for i, offset := range s.chunkOffsets {
+		offsets[i] = offset + shift
+		largeOffsets = largeOffsets || offsets[i] > 0xffffffff
+	}
+	if largeOffsets {
+		children = append(children, &ChunkLargeOffsetBox{ChunkOffsets: offsets})
+	} else {
+		stco := &mp4.ChunkOffsetBox{Entries: make([]mp4.ChunkOffsetEntry, len(offsets))}
+		for i, offset := range offsets {
+			stco.Entries[i].ChunkOffset = uint32(offset)
+		}
+		children = append(children, stco)
+	}
+	err = stbl.Mp4BoxReplaceChildren(children)
+	return
+}
+
+func allEqualSizes(sizes []uint32) bool {
+	for _, size := range sizes[1:] {
+		if size != sizes[0] {
+			return false
+		}
+	}
+	return true
+}
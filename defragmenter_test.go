@@ -0,0 +1,272 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+// readDefragmentedMp4 returns the types of the top-level boxes of a file
+// written by a Defragmenter, without the free box reserving the largesize of
+// the mdat box, and its moov box.
+func readDefragmentedMp4(t *testing.T, file *os.File) (boxes []mp4.BoxType, moov mp4.Box) {
+	t.Helper()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		header, err := mp4.ReadHeader(file)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch header.Type {
+		case mp4.FreeBoxType:
+		case mp4.MoovBoxType:
+			if moov, err = readMp4Box(file, header); err != nil {
+				t.Fatal(err)
+			}
+			boxes = append(boxes, header.Type)
+		default:
+			if _, err = file.Seek(int64(header.Size)-int64(header.HeaderSize()), io.SeekCurrent); err != nil {
+				t.Fatal(err)
+			}
+			boxes = append(boxes, header.Type)
+		}
+	}
+}
+
+func TestDefragmenter(t *testing.T) {
+	tests := []struct {
+		name      string
+		faststart bool
+		boxes     []mp4.BoxType
+	}{
+		{name: "moov after mdat", boxes: []mp4.BoxType{mp4.FtypBoxType, mp4.MdatBoxType, mp4.MoovBoxType}},
+		{name: "faststart", faststart: true, boxes: []mp4.BoxType{mp4.FtypBoxType, mp4.MoovBoxType, mp4.MdatBoxType}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDefragmenter(t, tt.faststart, tt.boxes)
+		})
+	}
+}
+
+func testDefragmenter(t *testing.T, faststart bool, wantBoxes []mp4.BoxType) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	p := MoovProcessor{TrackID: 1, Codec: Mp4aFourCC, Timescale: 48000, SamplingRate: 48000, Channels: 2, Bitrate: 128000, StreamType: AudioStream}
+	d, err := NewDefragmenter(file, MultiTrackMoovProcessor{Tracks: []MoovProcessor{p}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Faststart = faststart
+	var data []byte
+	for i := 0; i < 2; i++ {
+		f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(f.Data, fmt.Sprintf("%d123456789abcdef", i))
+		data = append(data, f.Data...)
+		if err = d.WriteFragment(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	boxes, moov := readDefragmentedMp4(t, file)
+	if !reflect.DeepEqual(boxes, wantBoxes) {
+		t.Errorf("boxes = %v, want %v", boxes, wantBoxes)
+	}
+	stbl := findMp4Box(moov, mp4.TrakBoxType, mp4.MdiaBoxType, mp4.MinfBoxType, mp4.StblBoxType)
+	if stbl == nil {
+		t.Fatal("moov box has no stbl box")
+	}
+	stts, _ := findMp4Box(stbl, mp4.SttsBoxType).(*mp4.TimeToSampleBox)
+	if stts == nil || !reflect.DeepEqual(stts.Entries, []mp4.TimeToSampleEntry{{SampleCount: 4, SampleDelta: 1000}}) {
+		t.Errorf("stts box %+v, want 4 samples lasting 1000", stts)
+	}
+	stsz, _ := findMp4Box(stbl, mp4.StszBoxType).(*SampleSizeBox)
+	if stsz == nil || !reflect.DeepEqual(stsz.EntrySizes, []uint32{10, 6, 10, 6}) {
+		t.Fatalf("stsz box %+v, want samples of 10, 6, 10 and 6 bytes", stsz)
+	}
+	stco, _ := findMp4Box(stbl, mp4.StcoBoxType).(*mp4.ChunkOffsetBox)
+	if stco == nil || len(stco.Entries) != 2 {
+		t.Fatalf("stco box %+v, want 2 chunks", stco)
+	}
+	if findMp4Box(stbl, mp4.StssBoxType) != nil || findMp4Box(stbl, mp4.CttsBoxType) != nil {
+		t.Error("stss or ctts box written for sync samples without composition time offsets")
+	}
+	for i, entry := range stco.Entries {
+		got := make([]byte, 16)
+		if _, err = file.ReadAt(got, int64(entry.ChunkOffset)); err != nil {
+			t.Fatal(err)
+		}
+		if want := data[i*16 : (i+1)*16]; !bytes.Equal(got, want) {
+			t.Errorf("chunk %d = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestDefragmenterWriteFragmentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		senc *SampleEncryptionBox
+		p    MoovProcessor
+	}{
+		{name: "encrypted", senc: testSampleEncryptionBox(8, false), p: MoovProcessor{TrackID: 1, Codec: Mp4aFourCC, StreamType: AudioStream}},
+		{name: "unknown track", p: MoovProcessor{TrackID: 2, Codec: Mp4aFourCC, StreamType: AudioStream}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := os.Create(filepath.Join(t.TempDir(), "out.mp4"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			d, err := NewDefragmenter(file, MultiTrackMoovProcessor{Tracks: []MoovProcessor{tt.p}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, tt.senc)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = d.WriteFragment(f); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("WriteFragment() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestDefragmenterFaststartRequiresReaderAt(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	p := MoovProcessor{TrackID: 1, Codec: Mp4aFourCC, Timescale: 48000, SamplingRate: 48000, Channels: 2, StreamType: AudioStream}
+	d, err := NewDefragmenter(struct{ io.WriteSeeker }{file}, MultiTrackMoovProcessor{Tracks: []MoovProcessor{p}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Faststart = true
+	if err = d.Close(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Close() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestSampleTableFillStblMp4BoxShift(t *testing.T) {
+	var s sampleTable
+	s.addChunk(0x20, []fragmentSample{{sampleRange: sampleRange{size: 4}, duration: 1000}})
+	s.addChunk(0xffff_fff0, []fragmentSample{{sampleRange: sampleRange{size: 4}, duration: 1000}})
+	tests := []struct {
+		name  string
+		shift uint64
+		want  []uint64
+	}{
+		{name: "within 4 GiB", shift: 0x8, want: []uint64{0x28, 0xffff_fff8}},
+		{name: "shifted beyond 4 GiB", shift: 0x20, want: []uint64{0x40, 0x1_0000_0010}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stbl := &mp4.SampleTableBox{}
+			if err := stbl.Mp4BoxReplaceChildren([]mp4.Box{&mp4.SampleDescriptionBox{}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.fillStblMp4Box(stbl, tt.shift); err != nil {
+				t.Fatal(err)
+			}
+			var got []uint64
+			if co64, ok := findMp4Box(stbl, Co64BoxType).(*ChunkLargeOffsetBox); ok {
+				got = co64.ChunkOffsets
+			} else if stco, ok := findMp4Box(stbl, mp4.StcoBoxType).(*mp4.ChunkOffsetBox); ok {
+				for _, entry := range stco.Entries {
+					got = append(got, uint64(entry.ChunkOffset))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunk offsets %#x, want %#x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampleTableFillStblMp4Box(t *testing.T) {
+	var s sampleTable
+	s.addChunk(0x20, []fragmentSample{
+		{sampleRange: sampleRange{size: 10}, duration: 1000, flags: DefaultSyncSampleFlags},
+		{sampleRange: sampleRange{size: 6}, duration: 1000, flags: DefaultVideoSampleFlags, compositionTimeOffset: -500},
+	})
+	s.addChunk(0x1_0000_0000, []fragmentSample{
+		{sampleRange: sampleRange{size: 10}, duration: 500, flags: DefaultVideoSampleFlags},
+	})
+	stbl := &mp4.SampleTableBox{}
+	if err := stbl.Mp4BoxReplaceChildren([]mp4.Box{&mp4.SampleDescriptionBox{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.fillStblMp4Box(stbl, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	stts, _ := findMp4Box(stbl, mp4.SttsBoxType).(*mp4.TimeToSampleBox)
+	if want := []mp4.TimeToSampleEntry{{SampleCount: 2, SampleDelta: 1000}, {SampleCount: 1, SampleDelta: 500}}; stts == nil || !reflect.DeepEqual(stts.Entries, want) {
+		t.Errorf("stts box %+v, want entries %+v", stts, want)
+	}
+	ctts, _ := findMp4Box(stbl, mp4.CttsBoxType).(*mp4.CompositionOffsetBox)
+	if ctts == nil || ctts.Version != 1 || len(ctts.Entries) != 3 {
+		t.Errorf("ctts box %+v, want version 1 with 3 entries", ctts)
+	}
+	stss, _ := findMp4Box(stbl, mp4.StssBoxType).(*mp4.SyncSampleBox)
+	if stss == nil || !reflect.DeepEqual(stss.SampleNumbers, []uint32{1}) {
+		t.Errorf("stss box %+v, want sample 1", stss)
+	}
+	stsc, _ := findMp4Box(stbl, mp4.StscBoxType).(*mp4.SampleToChunkBox)
+	if stsc == nil || len(stsc.Entries) != 2 || stsc.Entries[1].FirstChunk != 2 || stsc.Entries[1].SamplesPerChunk != 1 {
+		t.Errorf("stsc box %+v, want chunk 2 of 1 sample", stsc)
+	}
+	co64, _ := findMp4Box(stbl, Co64BoxType).(*ChunkLargeOffsetBox)
+	if co64 == nil || !reflect.DeepEqual(co64.ChunkOffsets, []uint64{0x20, 0x1_0000_0000}) {
+		t.Errorf("co64 box %+v, want the chunk offsets beyond 4 GiB", co64)
+	}
+	if findMp4Box(stbl, mp4.StcoBoxType) != nil {
+		t.Error("stco box written with a co64 box")
+	}
+}
+
+func TestSampleTableFillStblMp4BoxEqualSizes(t *testing.T) {
+	var s sampleTable
+	s.addChunk(0x20, []fragmentSample{
+		{sampleRange: sampleRange{size: 4}, duration: 1000},
+		{sampleRange: sampleRange{size: 4}, duration: 1000},
+	})
+	stbl := &mp4.SampleTableBox{}
+	if err := stbl.Mp4BoxReplaceChildren([]mp4.Box{&mp4.SampleDescriptionBox{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.fillStblMp4Box(stbl, 0); err != nil {
+		t.Fatal(err)
+	}
+	stsz, _ := findMp4Box(stbl, mp4.StszBoxType).(*SampleSizeBox)
+	if stsz == nil || stsz.SampleSize != 4 || stsz.SampleCount != 2 || stsz.EntrySizes != nil {
+		t.Errorf("stsz box %+v, want 2 samples of 4 bytes", stsz)
+	}
+	if err := s.fillStblMp4Box(&mp4.SampleTableBox{}, 0); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("fillStblMp4Box() without stsd error = %v, want %v", err, ErrInvalidParam)
+	}
+}
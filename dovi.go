@@ -0,0 +1,112 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-webdl/media-codec/dovi"
+	"github.com/go-webdl/mp4"
+)
+
+// ParseDolbyVisionCodecString parses the codecs parameter of a Dolby Vision
+// track, e.g. "dvh1.05.06", into a Dolby Vision configuration record.
+func ParseDolbyVisionCodecString(codec string) (config dovi.DOVIDecoderConfigurationRecord, err error) {
+	fields := strings.Split(codec, ".")
+	if len(fields) != 3 || !isDolbyVisionFourCC(fields[0]) {
+		err = fmt.Errorf("Dolby Vision codec string %q: %w", codec, ErrInvalidParam)
+		return
+	}
+	profile, err := strconv.ParseUint(fields[1], 10, 7)
+	if err != nil {
+		err = fmt.Errorf("Dolby Vision codec string %q: %w", codec, ErrInvalidParam)
+		return
+	}
+	level, err := strconv.ParseUint(fields[2], 10, 6)
+	if err != nil {
+		err = fmt.Errorf("Dolby Vision codec string %q: %w", codec, ErrInvalidParam)
+		return
+	}
+	config = newDolbyVisionConfig(uint8(profile), uint8(level))
+	return
+}
+
+func isDolbyVisionFourCC(fourCC string) bool {
+	switch strings.ToLower(fourCC) {
+	case "dvh1", "dvhe", "dva1", "dvav":
+		return true
+	}
+	return false
+}
+
+// newDolbyVisionConfig creates the configuration record of a single track
+// stream of the given profile, with the base layer signal compatibility that
+// is conventional for the profile.
+func newDolbyVisionConfig(profile, level uint8) dovi.DOVIDecoderConfigurationRecord {
+	config := dovi.DOVIDecoderConfigurationRecord{
+		VersionMajor: 1,
+		VersionMinor: 0,
+		Profile:      profile,
+		Level:        level,
+		RPUPresent:   true,
+		BLPresent:    true,
+	}
+	switch profile {
+	case 4, 7:
+		config.ELPresent = true
+		config.BLSignalCompatibilityID = 6 // Blu-ray HDR10 compatible
+	case 8:
+		config.BLSignalCompatibilityID = 1 // HDR10 compatible
+	}
+	return config
+}
+
+// dolbyVisionLevel estimates the Dolby Vision level of a stream of the given
+// picture size at up to 30 frames per second.
+func dolbyVisionLevel(width, height uint32) uint8 {
+	switch {
+	case width*height <= 1280*720:
+		return 2
+	case width*height <= 1920*1080:
+		return 4
+	default:
+		return 7
+	}
+}
+
+// DolbyVisionConfig returns the Dolby Vision configuration of the track, if
+// any: DolbyVision when set, otherwise the configuration signaled by a
+// "codecs" custom attribute, otherwise a profile 5 configuration for tracks
+// with a dvh1 or dvhe sample entry.
+func (p MoovProcessor) DolbyVisionConfig() (config *dovi.DOVIDecoderConfigurationRecord, err error) {
+	if p.DolbyVision != nil {
+		config = p.DolbyVision
+		return
+	}
+	if codec, ok := p.CustomAttributes.Value("codecs"); ok && isDolbyVisionFourCC(strings.SplitN(codec, ".", 2)[0]) {
+		var record dovi.DOVIDecoderConfigurationRecord
+		if record, err = ParseDolbyVisionCodecString(codec); err != nil {
+			return
+		}
+		config = &record
+		return
+	}
+	if p.Codec == mp4.Dvh1FourCC || p.Codec == mp4.DvheFourCC {
+		record := newDolbyVisionConfig(5, dolbyVisionLevel(p.Width, p.Height))
+		config = &record
+	}
+	return
+}
+
+// dolbyVisionConfigurationBoxType returns the box type of the configuration
+// box of the given profile.
+func dolbyVisionConfigurationBoxType(profile uint8) mp4.BoxType {
+	switch {
+	case profile <= 7:
+		return mp4.DvcCBoxType
+	case profile <= 10:
+		return mp4.DvvCBoxType
+	default:
+		return mp4.DvwCBoxType
+	}
+}
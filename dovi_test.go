@@ -0,0 +1,98 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/media-codec/dovi"
+	"github.com/go-webdl/mp4"
+)
+
+func TestParseDolbyVisionCodecString(t *testing.T) {
+	tests := []struct {
+		codec   string
+		want    dovi.DOVIDecoderConfigurationRecord
+		wantErr error
+	}{
+		{
+			codec: "dvh1.05.06",
+			want:  dovi.DOVIDecoderConfigurationRecord{VersionMajor: 1, Profile: 5, Level: 6, RPUPresent: true, BLPresent: true},
+		},
+		{
+			codec: "DVHE.08.07",
+			want:  dovi.DOVIDecoderConfigurationRecord{VersionMajor: 1, Profile: 8, Level: 7, RPUPresent: true, BLPresent: true, BLSignalCompatibilityID: 1},
+		},
+		{
+			codec: "dvhe.07.06",
+			want:  dovi.DOVIDecoderConfigurationRecord{VersionMajor: 1, Profile: 7, Level: 6, RPUPresent: true, ELPresent: true, BLPresent: true, BLSignalCompatibilityID: 6},
+		},
+		{codec: "hvc1.05.06", wantErr: ErrInvalidParam},
+		{codec: "dvh1.05", wantErr: ErrInvalidParam},
+		{codec: "dvh1.128.06", wantErr: ErrInvalidParam},
+		{codec: "dvh1.05.64", wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			got, err := ParseDolbyVisionCodecString(tt.codec)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseDolbyVisionCodecString() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("ParseDolbyVisionCodecString() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDolbyVisionConfig(t *testing.T) {
+	explicit := &dovi.DOVIDecoderConfigurationRecord{VersionMajor: 1, Profile: 8, Level: 9}
+	codecs := &CustomAttributes{Attributes: []*Attribute{{Name: "codecs", Value: "dvh1.05.09"}}}
+	tests := []struct {
+		name        string
+		processor   MoovProcessor
+		wantProfile uint8
+		wantLevel   uint8
+		wantNone    bool
+	}{
+		{name: "explicit configuration", processor: MoovProcessor{Codec: mp4.Hvc1FourCC, DolbyVision: explicit, CustomAttributes: codecs}, wantProfile: 8, wantLevel: 9},
+		{name: "codecs attribute", processor: MoovProcessor{Codec: mp4.Hvc1FourCC, CustomAttributes: codecs}, wantProfile: 5, wantLevel: 9},
+		{name: "dvh1 sample entry", processor: MoovProcessor{Codec: mp4.Dvh1FourCC, Width: 1920, Height: 1080}, wantProfile: 5, wantLevel: 4},
+		{name: "dvhe sample entry of 720p", processor: MoovProcessor{Codec: mp4.DvheFourCC, Width: 1280, Height: 720}, wantProfile: 5, wantLevel: 2},
+		{name: "dvh1 sample entry of 2160p", processor: MoovProcessor{Codec: mp4.Dvh1FourCC, Width: 3840, Height: 2160}, wantProfile: 5, wantLevel: 7},
+		{name: "HEVC", processor: MoovProcessor{Codec: mp4.Hvc1FourCC}, wantNone: true},
+		{
+			name:      "codecs attribute of HEVC",
+			processor: MoovProcessor{Codec: mp4.Hvc1FourCC, CustomAttributes: &CustomAttributes{Attributes: []*Attribute{{Name: "codecs", Value: "hvc1.2.4.L150.90"}}}},
+			wantNone:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.processor.DolbyVisionConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantNone {
+				if config != nil {
+					t.Errorf("DolbyVisionConfig() = %+v, want nil", config)
+				}
+				return
+			}
+			if config == nil || config.Profile != tt.wantProfile || config.Level != tt.wantLevel {
+				t.Errorf("DolbyVisionConfig() = %+v, want profile %d level %d", config, tt.wantProfile, tt.wantLevel)
+			}
+		})
+	}
+	p := MoovProcessor{CustomAttributes: &CustomAttributes{Attributes: []*Attribute{{Name: "codecs", Value: "dvh1.5"}}}}
+	if _, err := p.DolbyVisionConfig(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("DolbyVisionConfig() of an invalid codecs attribute error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestDolbyVisionConfigurationBoxType(t *testing.T) {
+	for profile, want := range map[uint8]mp4.BoxType{5: mp4.DvcCBoxType, 7: mp4.DvcCBoxType, 8: mp4.DvvCBoxType, 10: mp4.DvvCBoxType, 11: mp4.DvwCBoxType} {
+		if got := dolbyVisionConfigurationBoxType(profile); got != want {
+			t.Errorf("dolbyVisionConfigurationBoxType(%d) = %s, want %s", profile, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package smoothstreaming
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+)
+
+// DownloadRange downloads the part of the selected tracks of the presentation
+// of the manifest at manifestURL from start to end, times counted from the
+// first fragment of the manifest, the start of the DVR window of a live
+// presentation. The window is widened to the boundaries of the fragments it
+// overlaps, and the decode times of the fragments are rebased so that the
+// output clip starts at 0. A live presentation is not refreshed: the window
+// must lie within the timeline of the manifest fetched.
+func (d Downloader) DownloadRange(ctx context.Context, manifestURL *url.URL, start, end time.Duration) (tracks []DownloadedTrack, err error) {
+	if start < 0 || end <= start {
+		err = fmt.Errorf("invalid range from %s to %s: %w", start, end, ErrInvalidParam)
+		return
+	}
+	d.inits, d.keys = &initSegmentCache{}, &keyring{}
+	m, err := d.FetchManifest(ctx, manifestURL)
+	if err != nil {
+		return
+	}
+	state, err := d.loadState()
+	if err != nil {
+		return
+	}
+	selected, err := d.selectTracks(m, state)
+	if err != nil {
+		return
+	}
+	ranges, err := clipRanges(m, selected, start, end)
+	if err != nil {
+		return
+	}
+	for i := range selected {
+		var track DownloadedTrack
+		if track, err = d.downloadTrack(ctx, manifestURL, m, selected[i], state, ranges[i]); err != nil {
+			return
+		}
+		tracks = append(tracks, track)
+	}
+	err = d.writeChecksums(tracks)
+	return
+}
+
+// clipRanges returns the fragment ranges of the selected tracks from start to
+// end, counted from the earliest fragment of their streams, with rebasers
+// shifting the earliest fragment of the clip to 0.
+func clipRanges(m *SmoothStreamingMedia, selected []SelectedTrack, start, end time.Duration) (ranges []fragmentRange, err error) {
+	timescale := m.PresentationTimeScale()
+	timelines := make([][]TimelineFragment, len(selected))
+	origin := uint64(math.MaxUint64)
+	for i := range selected {
+		if timelines[i], err = m.StreamTimeline(selected[i].Stream); err != nil {
+			return
+		}
+		if len(timelines[i]) == 0 {
+			continue
+		}
+		if first := rescaleTime(timelines[i][0].Time, m.StreamTimeScale(selected[i].Stream), timescale); first < origin {
+			origin = first
+		}
+	}
+	if origin == math.MaxUint64 {
+		err = fmt.Errorf("no fragments to download: %w", ErrInvalidParam)
+		return
+	}
+
+	ranges = make([]fragmentRange, len(selected))
+	clipStart := uint64(math.MaxUint64)
+	for i := range selected {
+		streamTimescale := m.StreamTimeScale(selected[i].Stream)
+		ranges[i] = fragmentRange{
+			start: rescaleTime(origin+rescaleTime(uint64(start), uint64(time.Second), timescale), timescale, streamTimescale),
+			end:   rescaleTime(origin+rescaleTime(uint64(end), uint64(time.Second), timescale), timescale, streamTimescale),
+		}
+		for _, f := range timelines[i] {
+			if !ranges[i].contains(f) {
+				continue
+			}
+			if first := rescaleTime(f.Time, streamTimescale, timescale); first < clipStart {
+				clipStart = first
+			}
+			break
+		}
+	}
+	if clipStart == math.MaxUint64 {
+		err = fmt.Errorf("no fragments from %s to %s: %w", start, end, ErrInvalidParam)
+		return
+	}
+	// every output file holds a single track of the same ID, so each has its
+	// own rebaser, all of the same origin to keep the tracks in sync
+	for i := range ranges {
+		ranges[i].rebaser = &FragmentRebaser{
+			originTime:      clipStart,
+			originTimescale: timescale,
+			tracks:          make(map[uint32]*rebasedTrack),
+		}
+	}
+	return
+}
@@ -0,0 +1,157 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloaderDownloadRange(t *testing.T) {
+	tests := []struct {
+		name          string
+		start, end    time.Duration
+		wantRequests  []string
+		wantDuration  uint64
+		wantFragments int
+	}{
+		{
+			name:  "within a fragment",
+			start: 2500 * time.Millisecond, end: 3500 * time.Millisecond,
+			wantRequests:  []string{"/movie.ism/QualityLevels(1000)/Fragments(video=20000000)"},
+			wantDuration:  20000000,
+			wantFragments: 1,
+		},
+		{
+			name:  "fragment boundaries",
+			start: 2 * time.Second, end: 4 * time.Second,
+			wantRequests:  []string{"/movie.ism/QualityLevels(1000)/Fragments(video=20000000)"},
+			wantDuration:  20000000,
+			wantFragments: 1,
+		},
+		{
+			name:  "overlapping fragments",
+			start: time.Second, end: 5 * time.Second,
+			wantRequests: []string{
+				"/movie.ism/QualityLevels(1000)/Fragments(video=0)",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=20000000)",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=40000000)",
+			},
+			wantDuration:  60000000,
+			wantFragments: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fragment := testFragment(t, nil)
+			var mu sync.Mutex
+			var requests []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/movie.ism/Manifest" {
+					io.WriteString(w, testManifest)
+					return
+				}
+				mu.Lock()
+				requests = append(requests, r.URL.Path)
+				mu.Unlock()
+				w.Write(fragment)
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracks, err := Downloader{OutputDir: t.TempDir()}.DownloadRange(context.Background(), u, tt.start, tt.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(requests)
+			if !reflect.DeepEqual(requests, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", requests, tt.wantRequests)
+			}
+			if len(tracks) != 1 || tracks[0].Moov.Duration != tt.wantDuration {
+				t.Fatalf("tracks %+v, want one of duration %d", tracks, tt.wantDuration)
+			}
+
+			// the clip starts at 0
+			data, err := os.ReadFile(tracks[0].Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := bytes.NewReader(data)
+			if _, err = ParseInitSegment(r); err != nil {
+				t.Fatal(err)
+			}
+			var n int
+			for ; r.Len() > 0; n++ {
+				f, err := ParseFragment(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decodeTime, ok := f.Tracks[0].DecodeTime(); !ok || decodeTime != uint64(n)*20000000 {
+					t.Errorf("fragment %d decoded at %d, want %d", n, decodeTime, n*20000000)
+				}
+			}
+			if n != tt.wantFragments {
+				t.Errorf("got %d fragments, want %d", n, tt.wantFragments)
+			}
+		})
+	}
+}
+
+func TestDownloaderDownloadRangeErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, testManifest)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		start, end time.Duration
+	}{
+		{name: "negative start", start: -time.Second, end: time.Second},
+		{name: "empty", start: time.Second, end: time.Second},
+		{name: "after the timeline", start: 10 * time.Second, end: 20 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (Downloader{OutputDir: t.TempDir()}).DownloadRange(context.Background(), u, tt.start, tt.end); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("DownloadRange() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestFragmentRangeContains(t *testing.T) {
+	rng := fragmentRange{start: 1000, end: 3000}
+	tests := []struct {
+		name     string
+		fragment TimelineFragment
+		want     bool
+	}{
+		{name: "before", fragment: TimelineFragment{Time: 0, Duration: 1000}, want: false},
+		{name: "overlapping the start", fragment: TimelineFragment{Time: 500, Duration: 1000}, want: true},
+		{name: "at the start", fragment: TimelineFragment{Time: 1000, Duration: 1000}, want: true},
+		{name: "overlapping the end", fragment: TimelineFragment{Time: 2500, Duration: 1000}, want: true},
+		{name: "at the end", fragment: TimelineFragment{Time: 3000, Duration: 1000}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rng.contains(tt.fragment); got != tt.want {
+				t.Errorf("contains(%+v) = %v, want %v", tt.fragment, got, tt.want)
+			}
+		})
+	}
+}
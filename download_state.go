@@ -0,0 +1,104 @@
+package smoothstreaming
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DownloadState records the progress of a Downloader, so that an interrupted
+// download or live recording resumes after the last fragment written rather
+// than from the start. It is persisted as JSON in the StateFile of the
+// Downloader.
+type DownloadState struct {
+	// the time at which the download started, the {Time} of the names of
+	// its output files, see Downloader.NameTemplate.
+	Started time.Time `json:"started"`
+
+	Tracks []*TrackDownloadState `json:"tracks"`
+}
+
+// TrackDownloadState is the progress of the download of a track.
+type TrackDownloadState struct {
+	// the path of the output file.
+	Path string `json:"path"`
+
+	// the size of the output file after the init segment and the fragments
+	// written. Data written past it, such as an incomplete fragment, is
+	// discarded when resuming.
+	Size int64 `json:"size"`
+
+	// the number of fragments written.
+	Fragments uint32 `json:"fragments"`
+
+	// the time of the first fragment written, in the timescale of the stream.
+	FirstTime uint64 `json:"firstTime"`
+
+	// the time of the last fragment written, in the timescale of the stream.
+	// Fragments up to this time are skipped when resuming.
+	LastTime uint64 `json:"lastTime"`
+
+	// the end time of the last fragment written, in the timescale of the
+	// stream.
+	EndTime uint64 `json:"endTime"`
+}
+
+// LoadDownloadState reads the state file at path. A missing file is the state
+// of a new download.
+func LoadDownloadState(path string) (state *DownloadState, err error) {
+	state = &DownloadState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, state)
+	return
+}
+
+// Save writes the state file at path. The file is replaced atomically, so
+// that an interruption leaves the previous state intact.
+func (s *DownloadState) Save(path string) (err error) {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	err = os.Rename(tmp.Name(), path)
+	return
+}
+
+// Track returns the progress of the track written to path, adding it to the
+// state if it is new.
+func (s *DownloadState) Track(path string) *TrackDownloadState {
+	for _, track := range s.Tracks {
+		if track.Path == path {
+			return track
+		}
+	}
+	track := &TrackDownloadState{Path: path}
+	s.Tracks = append(s.Tracks, track)
+	return track
+}
+
+// done reports whether the fragment at the given time was written.
+func (t *TrackDownloadState) done(fragmentTime uint64) bool {
+	return t.Fragments > 0 && fragmentTime <= t.LastTime
+}
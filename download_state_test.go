@@ -0,0 +1,69 @@
+package smoothstreaming
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDownloadStateSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, err := LoadDownloadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Tracks) != 0 {
+		t.Fatalf("state of a missing file %+v, want no tracks", state)
+	}
+	track := state.Track("video_1000.mp4")
+	track.Size, track.Fragments, track.LastTime = 2048, 2, 20000000
+	if state.Track("video_1000.mp4") != track {
+		t.Error("Track() added a track of the same path")
+	}
+	state.Track("audio_128000.mp4")
+	if err = state.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadDownloadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, state) {
+		t.Errorf("LoadDownloadState() = %+v, want %+v", loaded, state)
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) != 0 {
+		t.Errorf("temporary files %q left behind", matches)
+	}
+}
+
+func TestLoadDownloadStateInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDownloadState(path); err == nil {
+		t.Error("LoadDownloadState() of invalid JSON succeeded")
+	}
+}
+
+func TestTrackDownloadStateDone(t *testing.T) {
+	tests := []struct {
+		name         string
+		state        TrackDownloadState
+		fragmentTime uint64
+		want         bool
+	}{
+		{name: "new track", fragmentTime: 0, want: false},
+		{name: "first fragment written", state: TrackDownloadState{Fragments: 1}, fragmentTime: 0, want: true},
+		{name: "earlier fragment", state: TrackDownloadState{Fragments: 2, LastTime: 20000000}, fragmentTime: 10000000, want: true},
+		{name: "later fragment", state: TrackDownloadState{Fragments: 2, LastTime: 20000000}, fragmentTime: 40000000, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.done(tt.fragmentTime); got != tt.want {
+				t.Errorf("done(%d) = %v, want %v", tt.fragmentTime, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,672 @@
+package smoothstreaming
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Downloader downloads the tracks of a presentation into playable fragmented
+// MP4 files, one per track: it fetches the manifest, selects the tracks, and
+// writes for each the init segment built from the manifest, see
+// SmoothStreamingMedia.MoovProcessor, followed by all fragments of the track,
+// rewritten to declare their decode time, see FragmentRewriter. Protected
+// tracks are written encrypted, with the protection systems of the manifest
+// in their init segment, unless a Decrypter or a LicenseClient is set.
+type Downloader struct {
+	// the HTTP client of the requests, http.DefaultClient if nil.
+	Client *http.Client
+
+	// the transport of the requests, replacing the one of the Client if not
+	// nil.
+	Transport http.RoundTripper
+
+	// the cookie jar of the requests, replacing the one of the Client if not
+	// nil, so that the session cookies set by the manifest response are sent
+	// with the fragment requests.
+	Jar http.CookieJar
+
+	// the headers added to every request, e.g. User-Agent, Referer or
+	// Authorization.
+	Header http.Header
+
+	// the supplier of the credentials of the requests, refreshing them when
+	// they expire, see Authorizer, none if nil.
+	Auth *Authorizer
+
+	// the rules choosing the tracks to download, the track of the highest
+	// bitrate of every stream if zero.
+	Tracks TrackSelector
+
+	// the directory of the output files, the working directory if empty.
+	OutputDir string
+
+	// the layout of the output of each track, a single file by default.
+	Layout OutputLayout
+
+	// the template of the names of the outputs of the tracks,
+	// DefaultNameTemplate if empty.
+	NameTemplate string
+
+	// the format of the output of the subtitle tracks, in the Layout if
+	// zero, see SubtitleFormat.
+	Subtitles SubtitleFormat
+
+	// the limit of the rate of the responses of all requests together, shared
+	// with other Downloaders to limit their total rate, no limit if nil.
+	RateLimiter *RateLimiter
+
+	// the limit of the rate of the response of each request, in bytes per
+	// second, no limit if 0.
+	ConnectionRate int64
+
+	// the number of fragments of a track fetched in parallel, 1 if 0. The
+	// fragments are written in order regardless.
+	Concurrency int
+
+	// the retries of failed requests, none if zero. The fragments of a live
+	// presentation requested before they are available are retried when they
+	// are due rather than after the delays of the policy.
+	Retry RetryPolicy
+
+	// the interval between the refreshes of the manifest of a live
+	// presentation, the duration of the last fragment of its timeline if 0.
+	RefreshInterval time.Duration
+
+	// the receiver of the measurements of the requests and the progress of
+	// the download, none if nil.
+	Metrics Metrics
+
+	// the conditions ending the recording of a live presentation.
+	Stop LiveStopConditions
+
+	// the estimator of the throughput of the fragment downloads, none if nil.
+	// A recording with AdaptiveQuality uses its own if nil.
+	Throughput *ThroughputEstimator
+
+	// the adaptation of the video tracks of the recording of a live
+	// presentation to the throughput, see AdaptiveQuality, disabled if zero.
+	Adaptive AdaptiveQuality
+
+	// the cache of the fragments, see SegmentCache, none if nil. Cached
+	// fragments are read from it rather than requested.
+	Cache *SegmentCache
+
+	// the verification of the fragments downloaded against their requests,
+	// none if zero.
+	Verify FragmentVerification
+
+	// the decrypter of the protected tracks, which are then written clear,
+	// with the original sample entries of their codecs and without tenc and
+	// pssh boxes, none if nil.
+	Decrypter *Decrypter
+
+	// the client acquiring the content keys of the protected tracks missing
+	// from the Keys of the Decrypter, which are then written clear as well,
+	// none if nil.
+	License LicenseClient
+
+	// whether a checksum manifest of the output of each track is written next
+	// to it once the download completes, see WriteChecksums. Streamed tracks
+	// have none.
+	Checksums bool
+
+	// the writer to which DownloadTo streams the track, instead of writing it
+	// to the output file.
+	stream io.Writer
+
+	// the init segments built for the tracks of the download.
+	inits *initSegmentCache
+
+	// the content keys acquired from the License for the download.
+	keys *keyring
+
+	// the path of the file recording the progress of the download, see
+	// DownloadState, none if empty. A download started with an existing state
+	// file resumes after the fragments it records.
+	StateFile string
+}
+
+// DownloadedTrack is a track written by a Downloader.
+type DownloadedTrack struct {
+	SelectedTrack
+
+	// the configuration of the init segment of the track.
+	Moov MoovProcessor
+
+	// the path of the output file, or of the output directory in the
+	// SegmentedLayout.
+	Path string
+}
+
+// Download downloads the selected tracks of the presentation of the manifest
+// at manifestURL, see ManifestURL, one after the other, or records them if the
+// presentation is live, see record. Canceling ctx aborts the download between
+// two fragments and the request in flight, leaving the fragments written so
+// far, from which a download with a StateFile resumes; it ends a live
+// recording without error, see LiveStopConditions.
+func (d Downloader) Download(ctx context.Context, manifestURL *url.URL) (tracks []DownloadedTrack, err error) {
+	d.inits, d.keys = &initSegmentCache{}, &keyring{}
+	m, err := d.FetchManifest(ctx, manifestURL)
+	if err != nil {
+		return
+	}
+	state, err := d.loadState()
+	if err != nil {
+		return
+	}
+	if m.IsLive != nil && *m.IsLive {
+		return d.record(ctx, manifestURL, m, state)
+	}
+	selected, err := d.selectTracks(m, state)
+	if err != nil {
+		return
+	}
+	for i := range selected {
+		var track DownloadedTrack
+		if track, err = d.downloadTrack(ctx, manifestURL, m, selected[i], state, fragmentRange{end: math.MaxUint64}); err != nil {
+			return
+		}
+		tracks = append(tracks, track)
+	}
+	err = d.writeChecksums(tracks)
+	return
+}
+
+// DownloadTo downloads the single track selected from the presentation of the
+// manifest at manifestURL like Download, but streams it to w, e.g. the
+// standard output piped into a muxer or a socket, instead of writing it to a
+// file. At most Concurrency fragments are buffered, and the data is written
+// to w after each fragment. A stream cannot be resumed, so the StateFile and
+// Layout are ignored.
+func (d Downloader) DownloadTo(ctx context.Context, manifestURL *url.URL, w io.Writer) (track DownloadedTrack, err error) {
+	d.stream = w
+	d.StateFile = ""
+	tracks, err := d.Download(ctx, manifestURL)
+	if len(tracks) > 0 {
+		track = tracks[0]
+		track.Path = ""
+	}
+	return
+}
+
+// FetchManifest requests and parses the manifest at manifestURL.
+func (d Downloader) FetchManifest(ctx context.Context, manifestURL *url.URL) (m *SmoothStreamingMedia, err error) {
+	data, err := d.fetch(ctx, manifestURL, d.Retry.delay)
+	if err != nil {
+		return
+	}
+	return ParseManifest(data)
+}
+
+// FetchFragment requests and parses a fragment, or reads it from the Cache.
+func (d Downloader) FetchFragment(ctx context.Context, u *url.URL) (f Fragment, err error) {
+	return d.fetchFragment(ctx, u, nil, d.Retry.delay)
+}
+
+// fetchFragment is FetchFragment with the delays before the retries of the
+// request returned by delay. The fragment is verified against its entry in
+// the timeline, expected, if it is not nil and the Verify is enabled.
+func (d Downloader) fetchFragment(ctx context.Context, u *url.URL, expected *TimelineFragment, delay retryDelayFunc) (f Fragment, err error) {
+	var data []byte
+	var cached bool
+	if d.Cache != nil {
+		if data, cached, err = d.Cache.Get(u); err != nil {
+			return
+		}
+	}
+	verify := d.Verify.Enabled && expected != nil
+	var parsed bool
+	for retry := 0; ; retry++ {
+		if !cached {
+			if data, err = d.fetchData(ctx, u, delay); err != nil {
+				return
+			}
+		}
+		f, err = ParseFragment(bytes.NewReader(data))
+		switch parsed = err == nil; {
+		case !parsed:
+			err = fmt.Errorf("fragment %s: %w", u, err)
+		case verify:
+			err = ValidateFragment(f, *expected).Err(d.Verify.Tolerance)
+		}
+		if err == nil || !verify || retry >= d.Retry.MaxRetries {
+			break
+		}
+		// the fragment is requested again, even if it was cached
+		cached = false
+		timer := time.NewTimer(d.Retry.delay(retry, nil))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+			return
+		case <-timer.C:
+		}
+	}
+	if err != nil {
+		if !parsed || !verify || d.Verify.Flag == nil {
+			return
+		}
+		d.Verify.Flag(u, err)
+		err = nil
+		return
+	}
+	if d.Cache != nil && !cached {
+		// only fragments that parse and pass the verification are cached
+		err = d.Cache.Put(u, data)
+	}
+	return
+}
+
+// fetchData requests the fragment at u and returns its data, recording the
+// throughput of the transfer.
+func (d Downloader) fetchData(ctx context.Context, u *url.URL, delay retryDelayFunc) (data []byte, err error) {
+	resp, err := d.get(ctx, u, nil, delay)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	start := time.Now()
+	if data, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+	d.Throughput.observe(int64(len(data)), start, time.Now())
+	return
+}
+
+// fetch requests u and returns the body of the response, with the delays
+// before the retries of the request returned by delay.
+func (d Downloader) fetch(ctx context.Context, u *url.URL, delay retryDelayFunc) (data []byte, err error) {
+	resp, err := d.get(ctx, u, nil, delay)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// fragmentRange selects the fragments of a track to download.
+type fragmentRange struct {
+	// the fragments overlapping the range from start to end, in the timescale
+	// of the stream, are downloaded.
+	start, end uint64
+
+	// the rebaser of the decode times of the fragments, nil to keep them.
+	rebaser *FragmentRebaser
+
+	// the live edge of the track, nil if the presentation is not live.
+	edge *liveEdge
+}
+
+// contains reports whether a fragment overlaps the range.
+func (r fragmentRange) contains(f TimelineFragment) bool {
+	return f.Time < r.end && (f.Time >= r.start || f.End() > r.start)
+}
+
+// downloadTrack writes the init segment and the fragments of a track within
+// rng to its output file, resuming after the fragments recorded by state.
+func (d Downloader) downloadTrack(ctx context.Context, manifestURL *url.URL, m *SmoothStreamingMedia, selected SelectedTrack, state *DownloadState, rng fragmentRange) (track DownloadedTrack, err error) {
+	track.SelectedTrack = selected
+	if track.Moov, err = m.MoovProcessor(selected.Stream, selected.Track); err != nil {
+		return
+	}
+	// the configuration of the fragments as downloaded
+	protected := track.Moov
+	var decrypter *Decrypter
+	if protected.Protected {
+		if decrypter, err = d.decrypter(ctx, protected); err != nil {
+			return
+		}
+	}
+	if decrypter != nil {
+		track.Moov = protected.WithoutProtection()
+	}
+	requests, err := m.ChunkURLs(manifestURL, selected.Stream, selected.Track)
+	if err != nil {
+		return
+	}
+	if track.Path, err = d.outputPath(state, selected); err != nil {
+		return
+	}
+	progress := state.Track(track.Path)
+	tw, err := d.openTrackWriter(selected, progress)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := tw.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	var pending []ChunkRequest
+	var duration uint64
+	for _, request := range requests {
+		if !rng.contains(request.Fragment) {
+			continue
+		}
+		duration += request.Fragment.Duration
+		if !progress.done(request.Fragment.Time) {
+			pending = append(pending, request)
+		}
+	}
+	if rng.rebaser != nil {
+		// the clip lasts as long as its fragments rather than the presentation
+		track.Moov.Duration = duration
+	}
+	if progress.Size == 0 {
+		var n int64
+		if n, err = tw.writeInit(track.Moov); err != nil {
+			return
+		}
+		progress.Size = n
+		if err = d.saveState(state); err != nil {
+			return
+		}
+	}
+	rw := FragmentRewriter{TrackID: track.Moov.TrackID}
+	err = d.fetchFragments(ctx, pending, rng.edge, func(request ChunkRequest, f Fragment) (err error) {
+		if decrypter != nil {
+			if err = decrypter.DecryptFragment(&f, protected); err != nil {
+				err = fmt.Errorf("fragment %s: %w", request.URL, err)
+				return
+			}
+		}
+		if err = rw.Rewrite(&f, request.Fragment.Time); err != nil {
+			return
+		}
+		if rng.rebaser != nil {
+			if err = rng.rebaser.Rebase(&f, track.Moov); err != nil {
+				return
+			}
+		}
+		var n int64
+		if n, err = tw.writeFragment(&f); err != nil {
+			return
+		}
+		if progress.Fragments == 0 {
+			progress.FirstTime = request.Fragment.Time
+		}
+		progress.Size += n
+		progress.Fragments++
+		progress.LastTime = request.Fragment.Time
+		progress.EndTime = request.Fragment.End()
+		if request.Fragment.Duration == 0 && len(f.Tracks) > 0 {
+			// the duration of the last fragment of a live manifest is unknown
+			progress.EndTime += f.Tracks[0].Duration()
+		}
+		err = d.saveState(state)
+		return
+	})
+	return
+}
+
+// fetchFragments fetches the fragments of the requests, up to Concurrency at
+// a time, and passes them to write in the order of the requests. The
+// fragments fetched ahead of the one written wait in memory, at most
+// Concurrency of them. The fragments of a live track, whose edge is not nil,
+// that are not available yet are retried when they are due.
+func (d Downloader) fetchFragments(ctx context.Context, requests []ChunkRequest, edge *liveEdge, write func(ChunkRequest, Fragment) error) (err error) {
+	type result struct {
+		f   Fragment
+		err error
+	}
+	concurrency := d.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// the results of the requests in flight, in the order of the requests.
+	// Along with the one awaited by the writer, they number concurrency.
+	queue := make(chan chan result, concurrency-1)
+	go func() {
+		defer close(queue)
+		for _, request := range requests {
+			c := make(chan result, 1)
+			select {
+			case queue <- c:
+			case <-fetchCtx.Done():
+				return
+			}
+			go func(request ChunkRequest) {
+				f, err := d.fetchFragment(fetchCtx, request.URL, &request.Fragment, func(retry int, resp *http.Response) time.Duration {
+					return edge.retryDelay(d.Retry, request.Fragment, retry, resp)
+				})
+				if err == nil && edge != nil {
+					edge.fetched(time.Now(), request.Fragment, f)
+				}
+				c <- result{f, err}
+			}(request)
+		}
+	}()
+	i := 0
+	for c := range queue {
+		r := <-c
+		if err = r.err; err != nil {
+			return
+		}
+		if err = write(requests[i], r.f); err != nil {
+			return
+		}
+		i++
+	}
+	err = ctx.Err()
+	return
+}
+
+// selectTracks returns the tracks of m selected for download, a single one
+// when streaming, each with its own output path.
+func (d Downloader) selectTracks(m *SmoothStreamingMedia, state *DownloadState) (selected []SelectedTrack, err error) {
+	selected = d.Tracks.Select(m)
+	if d.stream != nil && len(selected) != 1 {
+		err = fmt.Errorf("%d tracks selected to stream instead of 1: %w", len(selected), ErrInvalidParam)
+		return
+	}
+	paths := make(map[string]bool, len(selected))
+	for _, track := range selected {
+		var path string
+		if path, err = d.outputPath(state, track); err != nil {
+			return
+		}
+		if paths[path] {
+			err = fmt.Errorf("output path %s of several tracks: %w", path, ErrInvalidParam)
+			return
+		}
+		paths[path] = true
+	}
+	return
+}
+
+// openTrackWriter opens the output of the selected track in the Layout, or
+// in the Subtitles format, for writing after the data recorded by its
+// progress.
+func (d Downloader) openTrackWriter(selected SelectedTrack, progress *TrackDownloadState) (tw trackWriter, err error) {
+	if d.stream != nil {
+		return &streamTrackWriter{w: bufio.NewWriter(d.stream), inits: d.inits}, nil
+	}
+	if d.isSidecar(selected) {
+		return openSidecarTrackWriter(d.Subtitles, progress)
+	}
+	switch d.Layout {
+	case SingleFileLayout:
+		var fw *fileTrackWriter
+		if fw, err = openFileTrackWriter(progress); err != nil {
+			return
+		}
+		fw.inits = d.inits
+		return fw, nil
+	case SegmentedLayout:
+		var sw *segmentTrackWriter
+		if sw, err = openSegmentTrackWriter(progress); err != nil {
+			return
+		}
+		sw.inits = d.inits
+		return sw, nil
+	default:
+		err = fmt.Errorf("output layout %d: %w", d.Layout, ErrInvalidParam)
+		return
+	}
+}
+
+// do sends a request for u with the given headers added to the Header. A
+// response of a status other than 200, or 304 to a conditional request, is
+// closed and returned along with an error.
+func (d Downloader) do(ctx context.Context, u *url.URL, header http.Header) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return
+	}
+	for _, h := range []http.Header{d.Header, header} {
+		for name, values := range h {
+			req.Header[name] = append([]string(nil), values...)
+		}
+	}
+	if host := d.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	if resp, err = d.client().Do(req); err != nil {
+		return
+	}
+	conditional := req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+	if resp.StatusCode != http.StatusOK && !(resp.StatusCode == http.StatusNotModified && conditional) {
+		resp.Body.Close()
+		err = fmt.Errorf("GET %s: %s: %w", u, resp.Status, ErrUnexpectedStatus)
+	}
+	return
+}
+
+// client returns the HTTP client of the requests.
+func (d Downloader) client() *http.Client {
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if d.Transport == nil && d.Jar == nil {
+		return client
+	}
+	custom := *client
+	if d.Transport != nil {
+		custom.Transport = d.Transport
+	}
+	if d.Jar != nil {
+		custom.Jar = d.Jar
+	}
+	return &custom
+}
+
+// writeChecksums writes the checksum manifests of the outputs of the tracks
+// if the Checksums are enabled.
+func (d Downloader) writeChecksums(tracks []DownloadedTrack) (err error) {
+	if !d.Checksums || d.stream != nil {
+		return
+	}
+	for _, track := range tracks {
+		if track.Path == "" {
+			continue
+		}
+		if err = WriteChecksums(track.Path); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// metrics returns the Metrics of the Downloader, discarding the measurements
+// if it has none.
+func (d Downloader) metrics() Metrics {
+	if d.Metrics == nil {
+		return noMetrics{}
+	}
+	return d.Metrics
+}
+
+// loadState reads the StateFile, if any, or starts a new download.
+func (d Downloader) loadState() (state *DownloadState, err error) {
+	state = &DownloadState{}
+	if d.StateFile != "" {
+		if state, err = LoadDownloadState(d.StateFile); err != nil {
+			return
+		}
+	}
+	if state.Started.IsZero() {
+		state.Started = time.Now().UTC().Truncate(time.Second)
+	}
+	return
+}
+
+// saveState writes state to the StateFile, if any.
+func (d Downloader) saveState(state *DownloadState) (err error) {
+	if d.StateFile != "" {
+		err = state.Save(d.StateFile)
+	}
+	return
+}
+
+// retryDelayFunc returns the delay before the given retry of a request whose
+// last response, nil after a network error, was resp.
+type retryDelayFunc func(retry int, resp *http.Response) time.Duration
+
+// get requests u with the given headers and returns a successful response,
+// whose body is to be closed, retrying failed requests as decided by the Retry
+// policy after the delays returned by delay, usually the ones of the policy.
+// The requests are authorized by the Auth credentials, a request rejected by
+// the server being retried once with fresh ones.
+func (d Downloader) get(ctx context.Context, u *url.URL, header http.Header, delay retryDelayFunc) (resp *http.Response, err error) {
+	metrics := d.metrics()
+	var start time.Time
+	var request RequestMetrics
+	// the attempts retried with fresh credentials, not counted by the policy
+	var reauthorized int
+	for retry := 0; ; retry++ {
+		var credentials Credentials
+		var generation int
+		if credentials, generation, err = d.Auth.current(ctx); err != nil {
+			return
+		}
+		authorized, authorizedHeader := credentials.authorize(u, header)
+		metrics.RequestStarted(u, retry)
+		start = time.Now()
+		resp, err = d.do(ctx, authorized, authorizedHeader)
+		request = RequestMetrics{URL: u, Retry: retry, Latency: time.Since(start), Err: err}
+		if resp != nil {
+			request.Status = resp.StatusCode
+		}
+		if err == nil {
+			break
+		}
+		request.Duration = request.Latency
+		metrics.RequestFinished(request)
+		if d.Auth != nil && reauthorized == 0 && resp != nil && rejectedStatus(resp.StatusCode) && ctx.Err() == nil {
+			d.Auth.reject(generation)
+			reauthorized++
+			continue
+		}
+		if retry-reauthorized >= d.Retry.MaxRetries || ctx.Err() != nil || resp != nil && !d.Retry.retryStatus(resp.StatusCode) {
+			return
+		}
+		timer := time.NewTimer(delay(retry-reauthorized, resp))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+			return
+		case <-timer.C:
+		}
+	}
+	resp.Body = &metricsReader{r: resp.Body, metrics: metrics, request: request, start: start}
+	if d.RateLimiter != nil || d.ConnectionRate > 0 {
+		resp.Body = &rateLimitedReader{
+			ctx:      ctx,
+			r:        resp.Body,
+			limiters: []*RateLimiter{d.RateLimiter, NewRateLimiter(d.ConnectionRate)},
+		}
+	}
+	return
+}
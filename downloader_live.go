@@ -0,0 +1,371 @@
+package smoothstreaming
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultRefreshInterval is the interval between the refreshes of a live
+// manifest whose fragment durations are not known.
+const defaultRefreshInterval = 2 * time.Second
+
+// LiveStopConditions holds the conditions ending the recording of a live
+// presentation, which otherwise continues until the presentation ends or the
+// context of the download is done. The recording ends without error at the
+// first condition met, or when the context is done, keeping the fragments
+// written.
+type LiveStopConditions struct {
+	// the wall-clock duration of the recording, no limit if 0.
+	Duration time.Duration
+
+	// the wall-clock time at which the recording ends, no limit if zero.
+	EndTime time.Time
+
+	// the media duration recorded of each track, no limit if 0. The fragments
+	// starting that long after the first fragment recorded are not recorded.
+	MediaDuration time.Duration
+
+	// the time, in the timescale of the presentation, from which fragments
+	// are not recorded, no limit if 0.
+	FragmentTime uint64
+
+	// the number of consecutive refreshes of the manifest publishing no new
+	// fragment after which the presentation is considered ended, e.g. when the
+	// encoder stopped without the server marking the manifest as not live, no
+	// limit if 0.
+	IdleRefreshes int
+}
+
+// deadline returns the wall-clock time at which a recording started at start
+// ends.
+func (c LiveStopConditions) deadline(start time.Time) (deadline time.Time, ok bool) {
+	if c.Duration > 0 {
+		deadline, ok = start.Add(c.Duration), true
+	}
+	if !c.EndTime.IsZero() && (!ok || c.EndTime.Before(deadline)) {
+		deadline, ok = c.EndTime, true
+	}
+	return
+}
+
+// end returns the time, in the timescale of the stream s, from which the
+// fragments of a track whose first fragment recorded is at first are not
+// recorded, math.MaxUint64 if there is no limit.
+func (c LiveStopConditions) end(m *SmoothStreamingMedia, s *StreamIndex, first uint64) (end uint64) {
+	end = math.MaxUint64
+	timescale := m.StreamTimeScale(s)
+	if c.MediaDuration > 0 {
+		end = first + rescaleTime(uint64(c.MediaDuration), uint64(time.Second), timescale)
+	}
+	if c.FragmentTime > 0 {
+		if fragmentTime := rescaleTime(c.FragmentTime, m.PresentationTimeScale(), timescale); fragmentTime < end {
+			end = fragmentTime
+		}
+	}
+	return
+}
+
+// record records the selected tracks of a live presentation: it downloads the
+// fragments of the timeline of the manifest, then refreshes the manifest at
+// the RefreshInterval and appends the fragments published since to the
+// output files, the fragments already written being recorded by state, until
+// a stop condition is met or ctx is done. The refreshes are conditional on
+// the ETag and Last-Modified validators of the last manifest, so that an
+// unchanged manifest is neither sent again nor parsed.
+//
+// The recording also ends when the presentation does: when the refreshed
+// manifest is no longer live, its last fragments being recorded first, when
+// the server answers the refresh with 404 Not Found or 410 Gone, or after the
+// IdleRefreshes of the Stop conditions. The outputs are then finalized, see
+// finalize.
+//
+// With AdaptiveQuality, the video tracks recorded change with the throughput,
+// the outputs of all tracks recorded being returned.
+func (d Downloader) record(ctx context.Context, manifestURL *url.URL, m *SmoothStreamingMedia, state *DownloadState) (tracks []DownloadedTrack, err error) {
+	if deadline, ok := d.Stop.deadline(time.Now()); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	var adaptive *adaptation
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = nil
+		}
+		if adaptive != nil {
+			tracks = adaptive.outputs(tracks)
+		}
+		if err == nil {
+			err = d.finalize(state, tracks)
+		}
+		if err == nil {
+			err = d.writeChecksums(tracks)
+		}
+	}()
+	selected, err := d.selectTracks(m, state)
+	if err != nil {
+		return
+	}
+	tracks = make([]DownloadedTrack, len(selected))
+	if d.Adaptive.MaxLag > 0 && d.Throughput == nil {
+		d.Throughput = NewThroughputEstimator(0)
+	}
+	adaptive = newAdaptation(d.Adaptive, d.Throughput, selected)
+	// m was fetched right before the recording started
+	edges := make([]*liveEdge, len(selected))
+	for i := range selected {
+		if edges[i], err = newLiveEdge(m, selected[i].Stream, time.Now()); err != nil {
+			return
+		}
+	}
+	var validators manifestValidators
+	modified, live := true, true
+	published := lastFragmentTimes(m, selected)
+	var idle int
+	for {
+		if modified {
+			var ended bool
+			if ended, err = d.recordTracks(ctx, manifestURL, m, state, selected, edges, adaptive, tracks); ended || err != nil {
+				return
+			}
+		}
+		if !live || (d.Stop.IdleRefreshes > 0 && idle >= d.Stop.IdleRefreshes) {
+			return
+		}
+
+		timer := time.NewTimer(d.refreshInterval(m, selected))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		var refreshed *SmoothStreamingMedia
+		var gone bool
+		if refreshed, gone, err = d.pollManifest(ctx, manifestURL, &validators); err != nil {
+			if gone {
+				// the presentation was removed once over
+				err = nil
+			}
+			return
+		}
+		if modified = refreshed != nil; !modified {
+			idle++
+			continue
+		}
+		m = refreshed
+		live = m.IsLive != nil && *m.IsLive
+		fetched := time.Now()
+		for i := range selected {
+			if selected[i], err = findSelectedTrack(m, selected[i]); err != nil {
+				return
+			}
+			if err = edges[i].refresh(m, selected[i].Stream, fetched); err != nil {
+				return
+			}
+		}
+		idle++
+		for i, t := range lastFragmentTimes(m, selected) {
+			if t != published[i] {
+				published, idle = lastFragmentTimes(m, selected), 0
+				break
+			}
+		}
+	}
+}
+
+// lastFragmentTimes returns the time of the last fragment published of each
+// selected track, 0 if none is.
+func lastFragmentTimes(m *SmoothStreamingMedia, selected []SelectedTrack) (times []uint64) {
+	times = make([]uint64, len(selected))
+	for i := range selected {
+		if fragments, err := m.StreamTimeline(selected[i].Stream); err == nil && len(fragments) > 0 {
+			times[i] = fragments[len(fragments)-1].Time
+		}
+	}
+	return
+}
+
+// finalize completes the outputs of the recorded tracks once the recording
+// ended, rewriting their init segments with the duration recorded and, in the
+// SingleFileLayout, appending an mfra box indexing their fragments so that
+// players can seek in them.
+func (d Downloader) finalize(state *DownloadState, tracks []DownloadedTrack) (err error) {
+	for _, track := range tracks {
+		if track.Path == "" {
+			continue
+		}
+		progress := state.Track(track.Path)
+		if progress.Fragments == 0 {
+			continue
+		}
+		var tw trackWriter
+		if tw, err = d.openTrackWriter(track.SelectedTrack, progress); err != nil {
+			return
+		}
+		p := track.Moov
+		p.Duration = progress.EndTime - progress.FirstTime
+		err = tw.finalize(p)
+		if closeErr := tw.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			err = fmt.Errorf("finalize %s: %w", track.Path, err)
+			return
+		}
+	}
+	return
+}
+
+// recordTracks appends the fragments of the selected tracks of the manifest m
+// not yet written to their outputs, updating tracks, and reports whether the
+// recording ended, every track having reached its end. The selected tracks
+// are switched as decided by adaptive, unless it is nil.
+func (d Downloader) recordTracks(ctx context.Context, manifestURL *url.URL, m *SmoothStreamingMedia, state *DownloadState, selected []SelectedTrack, edges []*liveEdge, adaptive *adaptation, tracks []DownloadedTrack) (ended bool, err error) {
+	ended = true
+	for i := range selected {
+		var fragments []TimelineFragment
+		if fragments, err = m.StreamTimeline(selected[i].Stream); err != nil {
+			return
+		}
+		if len(fragments) == 0 {
+			ended = false
+			continue
+		}
+		var path string
+		if path, err = d.outputPath(state, selected[i]); err != nil {
+			return
+		}
+		first, written := fragments[0].Time, fragments[0].Time
+		progress := state.Track(path)
+		if progress.Fragments > 0 {
+			first, written = progress.FirstTime, progress.LastTime
+		}
+		var from uint64
+		if adaptive != nil && adaptive.tracks[i].switched {
+			first, from = adaptive.tracks[i].first, adaptive.tracks[i].from
+			if from > written {
+				written = from
+			}
+		}
+		last := fragments[len(fragments)-1]
+		var lag time.Duration
+		if last.Time >= written {
+			lag = time.Duration(rescaleTime(last.Time-written, m.StreamTimeScale(selected[i].Stream), uint64(time.Second)))
+			d.metrics().LiveEdgeLag(selected[i], lag)
+		}
+		if adaptive != nil && progress.Fragments > 0 && tracks[i].Path == path {
+			next := adaptive.choose(i, selected[i], lag)
+			var nextPath string
+			if nextPath, err = d.outputPath(state, next); err != nil {
+				return
+			}
+			if nextPath != path {
+				t := &adaptive.tracks[i]
+				if !t.switched {
+					t.first = progress.FirstTime
+				}
+				t.switched, t.from = true, progress.EndTime
+				t.outputs = append(t.outputs, tracks[i])
+				selected[i], first, from = next, t.first, t.from
+			}
+		}
+		end := d.Stop.end(m, selected[i].Stream, first)
+		if tracks[i], err = d.downloadTrack(ctx, manifestURL, m, selected[i], state, fragmentRange{start: from, end: end, edge: edges[i]}); err != nil {
+			return
+		}
+		ended = ended && last.Duration > 0 && last.End() >= end
+	}
+	return
+}
+
+// manifestValidators are the validators of the last manifest fetched by a
+// live recording, sent with the next refresh so that the server answers 304
+// Not Modified rather than sending the manifest again if it has not changed.
+type manifestValidators struct {
+	etag         string
+	lastModified string
+}
+
+// pollManifest refreshes the manifest at manifestURL, with a conditional
+// request if validators of a previous refresh are known, and updates the
+// validators. It returns a nil manifest if the manifest has not changed, and
+// reports whether the manifest is gone, the server answering 404 Not Found or
+// 410 Gone, along with the error.
+func (d Downloader) pollManifest(ctx context.Context, manifestURL *url.URL, validators *manifestValidators) (m *SmoothStreamingMedia, gone bool, err error) {
+	header := http.Header{}
+	if validators.etag != "" {
+		header.Set("If-None-Match", validators.etag)
+	}
+	if validators.lastModified != "" {
+		header.Set("If-Modified-Since", validators.lastModified)
+	}
+	resp, err := d.get(ctx, manifestURL, header, d.Retry.delay)
+	if err != nil {
+		gone = resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if m, err = ParseManifest(data); err != nil {
+		return
+	}
+	validators.etag = resp.Header.Get("ETag")
+	validators.lastModified = resp.Header.Get("Last-Modified")
+	return
+}
+
+// refreshInterval returns the interval before the next refresh of the
+// manifest m, by default the duration of the last fragment of the selected
+// streams, the rate at which the server publishes fragments.
+func (d Downloader) refreshInterval(m *SmoothStreamingMedia, selected []SelectedTrack) (interval time.Duration) {
+	if d.RefreshInterval > 0 {
+		return d.RefreshInterval
+	}
+	for _, track := range selected {
+		fragments, err := m.StreamTimeline(track.Stream)
+		if err != nil || len(fragments) == 0 {
+			continue
+		}
+		last := fragments[len(fragments)-1]
+		duration := time.Duration(rescaleTime(last.Duration, m.StreamTimeScale(track.Stream), uint64(time.Second)))
+		if duration > 0 && (interval == 0 || duration < interval) {
+			interval = duration
+		}
+	}
+	if interval == 0 {
+		interval = defaultRefreshInterval
+	}
+	return
+}
+
+// findSelectedTrack returns the stream and track of a refreshed manifest m
+// that correspond to a track selected in an earlier version of it: the track
+// of the same bitrate of the stream of the same name.
+func findSelectedTrack(m *SmoothStreamingMedia, selected SelectedTrack) (found SelectedTrack, err error) {
+	name := selected.Stream.streamName()
+	for _, s := range m.Streams {
+		if s.streamName() != name || s.Type != selected.Stream.Type {
+			continue
+		}
+		for _, t := range s.Tracks {
+			if t.Bitrate == selected.Track.Bitrate {
+				return SelectedTrack{Stream: s, Track: t}, nil
+			}
+		}
+	}
+	err = fmt.Errorf("track %s_%d not in the refreshed manifest: %w", name, selected.Track.Bitrate, ErrInvalidParam)
+	return
+}
@@ -0,0 +1,424 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+// testLiveManifest returns a live manifest of a video stream whose timeline
+// has n fragments of 2 seconds.
+func testLiveManifest(n int) string {
+	return `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="0" IsLive="TRUE">` +
+		`<StreamIndex Type="video" Name="video" QualityLevels="1" Url="QualityLevels({bitrate})/Fragments(video={start time})">` +
+		`<QualityLevel Index="0" Bitrate="1000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+		strings.Repeat(`<c d="20000000"/>`, n) +
+		`</StreamIndex></SmoothStreamingMedia>`
+}
+
+func TestDownloaderRecord(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fragment := testFragment(t, nil)
+	var mu sync.Mutex
+	var manifests int
+	fragments := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/live.isml/Manifest" {
+			fragments[r.URL.Path]++
+			w.Write(fragment)
+			return
+		}
+		// a fragment is published with every refresh, until the recording
+		// stops at the fourth one
+		if manifests++; manifests > 3 {
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		io.WriteString(w, testLiveManifest(manifests))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/live.isml/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	// canceling ctx ends the recording without error
+	if _, err = (Downloader{OutputDir: dir, RefreshInterval: 10 * time.Millisecond}).Download(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		path := fmt.Sprintf("/live.isml/QualityLevels(1000)/Fragments(video=%d)", i*20000000)
+		if fragments[path] != 1 {
+			t.Errorf("requested %s %d times, want once", path, fragments[path])
+		}
+	}
+	if len(fragments) != 3 {
+		t.Errorf("requested fragments %v, want 3", fragments)
+	}
+
+	// the fragments published by the refreshes follow the first one, the
+	// recording being finalized with its duration and an mfra box
+	data, err := os.ReadFile(filepath.Join(dir, "video_1000.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfraSize := binary.BigEndian.Uint32(data[len(data)-4:])
+	box, err := mp4.ReadBox(bytes.NewReader(data[len(data)-int(mfraSize):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfra, ok := box.(*MovieFragmentRandomAccessBox)
+	if !ok || len(mfra.Mp4BoxChildren()) != 2 {
+		t.Fatalf("read %T at the end of the file, want *MovieFragmentRandomAccessBox", box)
+	}
+	tfra, ok := mfra.Mp4BoxChildren()[0].(*TrackFragmentRandomAccessBox)
+	if !ok {
+		t.Fatalf("first child of the mfra box is %T, want *TrackFragmentRandomAccessBox", mfra.Mp4BoxChildren()[0])
+	}
+
+	r := bytes.NewReader(data[:len(data)-int(mfraSize)])
+	init, err := ParseInitSegment(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := init.Tracks[0]; p.mediaDuration() != 60000000 {
+		t.Errorf("init segment of duration %d, want %d", p.mediaDuration(), 60000000)
+	}
+	var n int
+	for ; r.Len() > 0; n++ {
+		offset := uint64(r.Size()) - uint64(r.Len())
+		f, err := ParseFragment(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decodeTime, ok := f.Tracks[0].DecodeTime(); !ok || decodeTime != uint64(n)*20000000 {
+			t.Errorf("fragment %d decoded at %d, want %d", n, decodeTime, n*20000000)
+		}
+		want := TfraEntry{Time: uint64(n) * 20000000, MoofOffset: offset, TrafNumber: 1, TrunNumber: 1, SampleNumber: 1}
+		if n < len(tfra.Entries) && tfra.Entries[n] != want {
+			t.Errorf("tfra entry %d = %+v, want %+v", n, tfra.Entries[n], want)
+		}
+	}
+	if n != 3 || len(tfra.Entries) != 3 {
+		t.Errorf("got %d fragments, %d tfra entries, want 3", n, len(tfra.Entries))
+	}
+}
+
+func TestDownloaderRecordStop(t *testing.T) {
+	tests := []struct {
+		name          string
+		stop          LiveStopConditions
+		endIn         time.Duration
+		wantFragments int
+	}{
+		{name: "media duration", stop: LiveStopConditions{MediaDuration: 4 * time.Second}, wantFragments: 2},
+		{name: "fragment time", stop: LiveStopConditions{FragmentTime: 60000000}, wantFragments: 3},
+		{name: "wall-clock duration", stop: LiveStopConditions{Duration: 50 * time.Millisecond}, wantFragments: 1},
+		{name: "end time", endIn: 50 * time.Millisecond, wantFragments: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fragment := testFragment(t, nil)
+			var mu sync.Mutex
+			var manifests, fragments int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.URL.Path != "/live.isml/Manifest" {
+					fragments++
+					w.Write(fragment)
+					return
+				}
+				// the media conditions publish a fragment with every refresh,
+				// the wall-clock ones none after the first
+				if manifests++; tt.stop.MediaDuration == 0 && tt.stop.FragmentTime == 0 {
+					manifests = 1
+				}
+				io.WriteString(w, testLiveManifest(manifests))
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/live.isml/Manifest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if tt.endIn > 0 {
+				tt.stop.EndTime = time.Now().Add(tt.endIn)
+			}
+			d := Downloader{OutputDir: t.TempDir(), RefreshInterval: 5 * time.Millisecond, Stop: tt.stop}
+			if _, err = d.Download(ctx, u); err != nil {
+				t.Fatal(err)
+			}
+			if ctx.Err() != nil {
+				t.Fatal("the recording did not stop")
+			}
+			if fragments != tt.wantFragments {
+				t.Errorf("recorded %d fragments, want %d", fragments, tt.wantFragments)
+			}
+		})
+	}
+}
+
+func TestDownloaderRecordEnd(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		notLive       bool
+		idle          int
+		wantFragments int
+	}{
+		{name: "not live", notLive: true, wantFragments: 4},
+		{name: "not found", status: http.StatusNotFound, wantFragments: 3},
+		{name: "gone", status: http.StatusGone, wantFragments: 3},
+		{name: "idle refreshes", idle: 2, wantFragments: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fragment := testFragment(t, nil)
+			var mu sync.Mutex
+			var manifests, fragments int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.URL.Path != "/live.isml/Manifest" {
+					fragments++
+					w.Write(fragment)
+					return
+				}
+				// a fragment is published with each of the first three
+				// refreshes, the presentation ending after them
+				manifests++
+				switch {
+				case manifests <= 3:
+					io.WriteString(w, testLiveManifest(manifests))
+				case tt.idle > 0:
+					io.WriteString(w, testLiveManifest(3))
+				case tt.notLive:
+					io.WriteString(w, strings.Replace(testLiveManifest(4), `IsLive="TRUE"`, `IsLive="FALSE"`, 1))
+				default:
+					w.WriteHeader(tt.status)
+				}
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/live.isml/Manifest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			dir := t.TempDir()
+			d := Downloader{OutputDir: dir, RefreshInterval: 5 * time.Millisecond, Stop: LiveStopConditions{IdleRefreshes: tt.idle}}
+			if _, err = d.Download(ctx, u); err != nil {
+				t.Fatal(err)
+			}
+			if ctx.Err() != nil {
+				t.Fatal("the recording did not end")
+			}
+			if fragments != tt.wantFragments {
+				t.Errorf("recorded %d fragments, want %d", fragments, tt.wantFragments)
+			}
+			if tt.idle > 0 && manifests != 3+tt.idle {
+				t.Errorf("requested the manifest %d times, want %d", manifests, 3+tt.idle)
+			}
+
+			// the recording is finalized
+			data, err := os.ReadFile(filepath.Join(dir, "video_1000.mp4"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data[len(data)-12:len(data)-8], MfroBoxType[:]) {
+				t.Errorf("the recording ends with %q, want an mfro box", data[len(data)-12:len(data)-8])
+			}
+		})
+	}
+}
+
+func TestLiveStopConditionsDeadline(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		conditions LiveStopConditions
+		want       time.Time
+		wantOK     bool
+	}{
+		{name: "none"},
+		{name: "duration", conditions: LiveStopConditions{Duration: time.Hour}, want: start.Add(time.Hour), wantOK: true},
+		{name: "end time", conditions: LiveStopConditions{EndTime: start.Add(time.Minute)}, want: start.Add(time.Minute), wantOK: true},
+		{name: "earlier end time", conditions: LiveStopConditions{Duration: time.Hour, EndTime: start.Add(time.Minute)}, want: start.Add(time.Minute), wantOK: true},
+		{name: "earlier duration", conditions: LiveStopConditions{Duration: time.Minute, EndTime: start.Add(time.Hour)}, want: start.Add(time.Minute), wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadline, ok := tt.conditions.deadline(start)
+			if ok != tt.wantOK || !deadline.Equal(tt.want) {
+				t.Errorf("deadline() = %v, %v, want %v, %v", deadline, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLiveStopConditionsEnd(t *testing.T) {
+	m, err := ParseManifest([]byte(`<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="0" IsLive="TRUE">` +
+		`<StreamIndex Type="audio" Name="audio" TimeScale="48000"><QualityLevel Index="0" Bitrate="128000"/></StreamIndex>` +
+		`</SmoothStreamingMedia>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		conditions LiveStopConditions
+		want       uint64
+	}{
+		{name: "none", want: math.MaxUint64},
+		{name: "media duration", conditions: LiveStopConditions{MediaDuration: 10 * time.Second}, want: 96000 + 480000},
+		{name: "fragment time", conditions: LiveStopConditions{FragmentTime: 50000000}, want: 240000},
+		{name: "earlier fragment time", conditions: LiveStopConditions{MediaDuration: 10 * time.Second, FragmentTime: 50000000}, want: 240000},
+		{name: "earlier media duration", conditions: LiveStopConditions{MediaDuration: time.Second, FragmentTime: 50000000}, want: 96000 + 48000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conditions.end(m, m.Streams[0], 96000); got != tt.want {
+				t.Errorf("end() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloaderRefreshInterval(t *testing.T) {
+	m, err := ParseManifest([]byte(`<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="0" IsLive="TRUE">` +
+		`<StreamIndex Type="video" Name="video"><QualityLevel Index="0" Bitrate="1000"/><c t="0" d="20000000"/><c d="40000000"/></StreamIndex>` +
+		`<StreamIndex Type="audio" Name="audio" TimeScale="48000"><QualityLevel Index="0" Bitrate="128000"/><c t="0" d="96000"/></StreamIndex>` +
+		`<StreamIndex Type="text" Name="text"><QualityLevel Index="0" Bitrate="0"/></StreamIndex>` +
+		`</SmoothStreamingMedia>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	video := SelectedTrack{Stream: m.Streams[0], Track: m.Streams[0].Tracks[0]}
+	audio := SelectedTrack{Stream: m.Streams[1], Track: m.Streams[1].Tracks[0]}
+	text := SelectedTrack{Stream: m.Streams[2], Track: m.Streams[2].Tracks[0]}
+	tests := []struct {
+		name       string
+		downloader Downloader
+		selected   []SelectedTrack
+		want       time.Duration
+	}{
+		{name: "last fragment", selected: []SelectedTrack{video}, want: 4 * time.Second},
+		{name: "shortest last fragment", selected: []SelectedTrack{video, audio}, want: 2 * time.Second},
+		{name: "no fragments", selected: []SelectedTrack{text}, want: defaultRefreshInterval},
+		{name: "configured", downloader: Downloader{RefreshInterval: time.Second}, selected: []SelectedTrack{video}, want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.downloader.refreshInterval(m, tt.selected); got != tt.want {
+				t.Errorf("refreshInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindSelectedTrack(t *testing.T) {
+	m, err := ParseManifest([]byte(testLiveManifest(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := ParseManifest([]byte(testLiveManifest(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	selected := SelectedTrack{Stream: m.Streams[0], Track: m.Streams[0].Tracks[0]}
+	found, err := findSelectedTrack(refreshed, selected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Stream != refreshed.Streams[0] || found.Track != refreshed.Streams[0].Tracks[0] {
+		t.Errorf("findSelectedTrack() = %+v, want the track of the refreshed manifest", found)
+	}
+
+	refreshed.Streams[0].Tracks[0].Bitrate = 2000
+	if _, err = findSelectedTrack(refreshed, selected); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("findSelectedTrack() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestDownloaderPollManifest(t *testing.T) {
+	const lastModified = "Fri, 01 Mar 2024 12:00:00 GMT"
+	version := 1
+	var conditions []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditions = append(conditions, r.Header.Get("If-None-Match")+"|"+r.Header.Get("If-Modified-Since"))
+		etag := fmt.Sprintf(`"v%d"`, version)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		io.WriteString(w, testLiveManifest(version))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/live.isml/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Downloader{}
+	var validators manifestValidators
+	m, _, err := d.pollManifest(context.Background(), u, &validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || len(m.Streams[0].Fragments) != 1 {
+		t.Fatalf("first poll = %+v, want the manifest of a fragment", m)
+	}
+	if validators != (manifestValidators{etag: `"v1"`, lastModified: lastModified}) {
+		t.Errorf("validators %+v after the first poll", validators)
+	}
+	if m, _, err = d.pollManifest(context.Background(), u, &validators); err != nil || m != nil {
+		t.Fatalf("poll of an unchanged manifest = %+v, %v, want nil", m, err)
+	}
+	version = 2
+	if m, _, err = d.pollManifest(context.Background(), u, &validators); err != nil || m == nil || len(m.Streams[0].Fragments) != 2 {
+		t.Fatalf("poll of a changed manifest = %+v, %v, want the manifest of two fragments", m, err)
+	}
+	if validators.etag != `"v2"` {
+		t.Errorf("ETag %s after the third poll, want \"v2\"", validators.etag)
+	}
+	want := []string{"|", `"v1"|` + lastModified, `"v1"|` + lastModified}
+	if !reflect.DeepEqual(conditions, want) {
+		t.Errorf("conditions %q, want %q", conditions, want)
+	}
+}
+
+func TestDownloaderNotModifiedUnconditional(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/live.isml/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (Downloader{}).FetchManifest(context.Background(), u); !errors.Is(err, ErrUnexpectedStatus) {
+		t.Errorf("FetchManifest() error = %v, want %v", err, ErrUnexpectedStatus)
+	}
+}
@@ -0,0 +1,638 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+const testManifest = `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+	`<StreamIndex Type="video" Name="video" Chunks="3" QualityLevels="2" Url="QualityLevels({bitrate})/Fragments(video={start time})">` +
+	`<QualityLevel Index="0" Bitrate="1000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="000000016764001facd9405005bb011000000300100000030320f18319600000000168ebe3cb22c0"/>` +
+	`<QualityLevel Index="1" Bitrate="500" FourCC="H264" MaxWidth="640" MaxHeight="360" CodecPrivateData="000000016764001facd9405005bb011000000300100000030320f18319600000000168ebe3cb22c0"/>` +
+	`<c t="0" d="20000000" r="3"/>` +
+	`</StreamIndex></SmoothStreamingMedia>`
+
+func TestDownloaderDownload(t *testing.T) {
+	tests := []struct {
+		name     string
+		tracks   TrackSelector
+		requests []string
+		bitrate  uint32
+	}{
+		{
+			name: "highest bitrate",
+			requests: []string{
+				"/movie.ism/Manifest",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=0)",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=20000000)",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=40000000)",
+			},
+			bitrate: 1000,
+		},
+		{
+			name:   "maximum height",
+			tracks: TrackSelector{MaxHeight: 400},
+			requests: []string{
+				"/movie.ism/Manifest",
+				"/movie.ism/QualityLevels(500)/Fragments(video=0)",
+				"/movie.ism/QualityLevels(500)/Fragments(video=20000000)",
+				"/movie.ism/QualityLevels(500)/Fragments(video=40000000)",
+			},
+			bitrate: 500,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fragment := testFragment(t, nil)
+			var mu sync.Mutex
+			var requests []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests = append(requests, r.URL.Path)
+				mu.Unlock()
+				if r.URL.Path == "/movie.ism/Manifest" {
+					io.WriteString(w, testManifest)
+					return
+				}
+				w.Write(fragment)
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tracks, err := Downloader{OutputDir: t.TempDir(), Tracks: tt.tracks}.Download(context.Background(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(requests)
+			if !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("requests = %q, want %q", requests, tt.requests)
+			}
+			if len(tracks) != 1 {
+				t.Fatalf("got %d tracks, want 1", len(tracks))
+			}
+			if tracks[0].Track.Bitrate != tt.bitrate {
+				t.Errorf("track of bitrate %d, want %d", tracks[0].Track.Bitrate, tt.bitrate)
+			}
+
+			data, err := os.ReadFile(tracks[0].Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := bytes.NewReader(data)
+			m, err := ParseInitSegment(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Tracks) != 1 || m.Tracks[0].Codec != mp4.Avc1FourCC {
+				t.Errorf("init segment tracks = %+v, want an avc1 track", m.Tracks)
+			}
+			var n int
+			for ; r.Len() > 0; n++ {
+				f, err := ParseFragment(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decodeTime, ok := f.Tracks[0].DecodeTime(); !ok || decodeTime != uint64(n)*20000000 {
+					t.Errorf("fragment %d decoded at %d, want %d", n, decodeTime, n*20000000)
+				}
+			}
+			if n != 3 {
+				t.Errorf("got %d fragments, want 3", n)
+			}
+		})
+	}
+}
+
+func TestDownloaderDownloadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		status   int
+		wantErr  error
+	}{
+		{name: "manifest not found", manifest: testManifest, status: http.StatusNotFound, wantErr: ErrUnexpectedStatus},
+		{name: "invalid manifest", manifest: "<SmoothStreamingMedia", status: http.StatusOK, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.manifest)
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = (Downloader{OutputDir: t.TempDir()}).Download(context.Background(), u); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Download() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloaderFetchFragmentErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "not a fragment")
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/QualityLevels(1000)/Fragments(video=0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (Downloader{}).FetchFragment(context.Background(), u); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("FetchFragment() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestDownloaderDownloadCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fragment := testFragment(t, nil)
+	var fragments int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/movie.ism/Manifest" {
+			io.WriteString(w, testManifest)
+			return
+		}
+		// the download stops while requesting the second fragment
+		if fragments++; fragments > 1 {
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		w.Write(fragment)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if _, err = (Downloader{OutputDir: dir}).Download(ctx, u); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Download() error = %v, want %v", err, context.Canceled)
+	}
+
+	// the fragment written before the cancellation is kept whole
+	data, err := os.ReadFile(filepath.Join(dir, "video_1000.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(data)
+	if _, err = ParseInitSegment(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseFragment(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d bytes after the first fragment, want none", r.Len())
+	}
+}
+
+func TestDownloaderDownloadResume(t *testing.T) {
+	tests := []struct {
+		name         string
+		tail         []byte
+		truncate     bool
+		wantRequests []string
+	}{
+		{
+			name: "incomplete fragment",
+			tail: []byte{0, 0, 0, 100, 'm', 'o', 'o', 'f'},
+			wantRequests: []string{
+				"/movie.ism/Manifest",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=20000000)",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=40000000)",
+			},
+		},
+		{
+			name:     "file shorter than recorded",
+			truncate: true,
+			wantRequests: []string{
+				"/movie.ism/Manifest",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=0)",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=20000000)",
+				"/movie.ism/QualityLevels(1000)/Fragments(video=40000000)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			fragment := testFragment(t, nil)
+			var mu sync.Mutex
+			var requests []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests = append(requests, r.URL.Path)
+				mu.Unlock()
+				if r.URL.Path == "/movie.ism/Manifest" {
+					io.WriteString(w, testManifest)
+					return
+				}
+				// the first download stops while requesting the second fragment
+				if r.URL.Path == "/movie.ism/QualityLevels(1000)/Fragments(video=20000000)" && ctx.Err() == nil {
+					cancel()
+					<-r.Context().Done()
+					return
+				}
+				w.Write(fragment)
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			d := Downloader{OutputDir: dir, StateFile: filepath.Join(dir, "state.json")}
+			if _, err = d.Download(ctx, u); !errors.Is(err, context.Canceled) {
+				t.Fatalf("Download() error = %v, want %v", err, context.Canceled)
+			}
+			path := filepath.Join(dir, "video_1000.mp4")
+			if tt.truncate {
+				err = os.Truncate(path, 10)
+			} else {
+				var file *os.File
+				if file, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0); err == nil {
+					file.Write(tt.tail)
+					err = file.Close()
+				}
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			requests = nil
+			mu.Unlock()
+			tracks, err := d.Download(context.Background(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(requests)
+			if !reflect.DeepEqual(requests, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", requests, tt.wantRequests)
+			}
+			data, err := os.ReadFile(tracks[0].Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := bytes.NewReader(data)
+			if _, err = ParseInitSegment(r); err != nil {
+				t.Fatal(err)
+			}
+			var n int
+			for ; r.Len() > 0; n++ {
+				f, err := ParseFragment(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decodeTime, ok := f.Tracks[0].DecodeTime(); !ok || decodeTime != uint64(n)*20000000 {
+					t.Errorf("fragment %d decoded at %d, want %d", n, decodeTime, n*20000000)
+				}
+			}
+			if n != 3 {
+				t.Errorf("got %d fragments, want 3", n)
+			}
+			state, err := LoadDownloadState(d.StateFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if progress := state.Track(path); progress.Size != int64(len(data)) || progress.Fragments != 3 || progress.LastTime != 40000000 {
+				t.Errorf("state %+v, want 3 fragments of %d bytes up to 40000000", progress, len(data))
+			}
+		})
+	}
+}
+
+// countingTransport counts the requests it sends.
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDownloaderRequestOptions(t *testing.T) {
+	fragment := testFragment(t, nil)
+	var mu sync.Mutex
+	var failures []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if got := r.Header.Get("User-Agent"); got != "player/1.0" {
+			failures = append(failures, fmt.Sprintf("%s: User-Agent %q", r.URL.Path, got))
+		}
+		if r.Host != "cdn.example.com" {
+			failures = append(failures, fmt.Sprintf("%s: Host %q", r.URL.Path, r.Host))
+		}
+		if r.URL.Path == "/movie.ism/Manifest" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "1234", Path: "/"})
+			io.WriteString(w, testManifest)
+			return
+		}
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "1234" {
+			failures = append(failures, fmt.Sprintf("%s: no session cookie", r.URL.Path))
+		}
+		w.Write(fragment)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{}
+	transport := &countingTransport{}
+	d := Downloader{
+		Client:    client,
+		Transport: transport,
+		Jar:       jar,
+		Header:    http.Header{"User-Agent": {"player/1.0"}, "Host": {"cdn.example.com"}},
+		OutputDir: t.TempDir(),
+	}
+	if _, err = d.Download(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	for _, failure := range failures {
+		t.Error(failure)
+	}
+	if transport.requests != 4 {
+		t.Errorf("transport sent %d requests, want 4", transport.requests)
+	}
+	if client.Transport != nil || client.Jar != nil {
+		t.Error("Download() modified the Client")
+	}
+}
+
+func TestDownloaderRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		failures     int
+		maxRetries   int
+		wantRequests int
+		wantErr      error
+	}{
+		{name: "recovered", status: http.StatusServiceUnavailable, failures: 2, maxRetries: 2, wantRequests: 3},
+		{name: "retries exhausted", status: http.StatusServiceUnavailable, failures: 2, maxRetries: 1, wantRequests: 2, wantErr: ErrUnexpectedStatus},
+		{name: "status not retried", status: http.StatusForbidden, failures: 1, maxRetries: 2, wantRequests: 1, wantErr: ErrUnexpectedStatus},
+		{name: "no retries", status: http.StatusServiceUnavailable, failures: 1, wantRequests: 1, wantErr: ErrUnexpectedStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests++; requests <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				io.WriteString(w, testManifest)
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := Downloader{Retry: RetryPolicy{MaxRetries: tt.maxRetries, InitialDelay: time.Millisecond}}
+			if _, err = d.FetchManifest(context.Background(), u); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FetchManifest() error = %v, want %v", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestDownloaderRetryCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	d := Downloader{Retry: RetryPolicy{MaxRetries: 5, InitialDelay: time.Minute}}
+	start := time.Now()
+	if _, err = d.FetchManifest(ctx, u); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FetchManifest() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("canceled retries took %v", elapsed)
+	}
+}
+
+func TestDownloaderFetchFragments(t *testing.T) {
+	tests := []struct {
+		name         string
+		concurrency  int
+		failAt       int
+		wantInFlight int
+		wantWritten  int
+		wantErr      error
+	}{
+		{name: "sequential", concurrency: 0, failAt: -1, wantInFlight: 1, wantWritten: 8},
+		{name: "concurrent", concurrency: 3, failAt: -1, wantInFlight: 3, wantWritten: 8},
+		{name: "failed fragment", concurrency: 3, failAt: 5, wantInFlight: 3, wantWritten: 5, wantErr: ErrUnexpectedStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fragment := testFragment(t, nil)
+			var mu sync.Mutex
+			var inFlight, maxInFlight int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				if inFlight++; inFlight > maxInFlight {
+					maxInFlight = inFlight
+				}
+				mu.Unlock()
+				defer func() {
+					mu.Lock()
+					inFlight--
+					mu.Unlock()
+				}()
+				// the earlier fragments take longer, completing out of order
+				var i int
+				fmt.Sscanf(r.URL.Path, "/%d", &i)
+				time.Sleep(time.Duration(8-i) * 2 * time.Millisecond)
+				if i == tt.failAt {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Write(fragment)
+			}))
+			defer srv.Close()
+			var requests []ChunkRequest
+			for i := 0; i < 8; i++ {
+				u, err := url.Parse(fmt.Sprintf("%s/%d", srv.URL, i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				requests = append(requests, ChunkRequest{Fragment: TimelineFragment{Time: uint64(i) * 1000}, URL: u})
+			}
+			var written []uint64
+			err := Downloader{Concurrency: tt.concurrency}.fetchFragments(context.Background(), requests, nil, func(request ChunkRequest, f Fragment) error {
+				written = append(written, request.Fragment.Time)
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("fetchFragments() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(written) != tt.wantWritten {
+				t.Fatalf("wrote %d fragments, want %d", len(written), tt.wantWritten)
+			}
+			for i, time := range written {
+				if time != uint64(i)*1000 {
+					t.Fatalf("wrote fragments %v, want them in order", written)
+				}
+			}
+			if maxInFlight != tt.wantInFlight {
+				t.Errorf("%d requests in flight, want %d", maxInFlight, tt.wantInFlight)
+			}
+		})
+	}
+}
+
+func TestDownloaderCache(t *testing.T) {
+	fragment := testFragment(t, nil)
+	var mu sync.Mutex
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/movie.ism/Manifest" {
+			io.WriteString(w, testManifest)
+			return
+		}
+		mu.Lock()
+		requests++
+		first := requests <= 3
+		mu.Unlock()
+		if r.URL.Path == "/movie.ism/QualityLevels(1000)/Fragments(video=40000000)" && first {
+			// a fragment that does not parse is not cached
+			io.WriteString(w, "not a fragment")
+			return
+		}
+		w.Write(fragment)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewSegmentCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Downloader{OutputDir: t.TempDir(), Cache: cache}
+	if _, err = d.Download(context.Background(), u); !errors.Is(err, ErrInvalidParam) {
+		t.Fatalf("Download() error = %v, want %v", err, ErrInvalidParam)
+	}
+	if _, err = d.Download(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 4 {
+		t.Errorf("sent %d fragment requests, want 4", requests)
+	}
+	if _, err = d.Download(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 4 {
+		t.Errorf("sent %d fragment requests after downloading the cached fragments, want 4", requests)
+	}
+}
+
+func TestDownloaderDownloadTo(t *testing.T) {
+	fragment := testFragment(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/movie.ism/Manifest" {
+			io.WriteString(w, testManifest)
+			return
+		}
+		w.Write(fragment)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	d := Downloader{OutputDir: dir, StateFile: filepath.Join(dir, "state.json"), Layout: SegmentedLayout}
+	track, err := d.DownloadTo(context.Background(), u, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Path != "" || track.Track.Bitrate != 1000 {
+		t.Errorf("track %+v, want the track of bitrate 1000 without a path", track)
+	}
+	if _, err = ParseInitSegment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for ; buf.Len() > 0; n++ {
+		if _, err = ParseFragment(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 3 {
+		t.Errorf("streamed %d fragments, want 3", n)
+	}
+	// neither an output nor a state file is written
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("output directory holds %d files, %v, want none", len(entries), err)
+	}
+}
+
+func TestDownloaderDownloadToSeveralTracks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Replace(testManifest, `</SmoothStreamingMedia>`,
+			`<StreamIndex Type="audio" Name="audio" Url="QualityLevels({bitrate})/Fragments(audio={start time})">`+
+				`<QualityLevel Index="0" Bitrate="128000" FourCC="AACL" SamplingRate="48000" Channels="2" CodecPrivateData="1190"/>`+
+				`<c t="0" d="20000000"/></StreamIndex></SmoothStreamingMedia>`, 1))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err = (Downloader{}).DownloadTo(context.Background(), u, &buf); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("DownloadTo() error = %v, want %v", err, ErrInvalidParam)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("streamed %d bytes, want none", buf.Len())
+	}
+}
@@ -0,0 +1,64 @@
+package smoothstreaming
+
+import (
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+)
+
+// dtsChannelLayouts maps the channel count of a DTS track to the core audio
+// channel arrangement of ETSI TS 102 114 Table 5-4, the presence of the LFE
+// channel and the channel mask of Table C-11, assuming the conventional
+// layouts from mono up to 7.1.
+var dtsChannelLayouts = map[uint16]struct {
+	coreLayout    uint8
+	lfe           bool
+	channelLayout uint16
+}{
+	1: {0, false, 0x0001}, // C
+	2: {2, false, 0x0002}, // L R
+	3: {5, false, 0x0003}, // C L R
+	4: {8, false, 0x0006}, // L R Ls Rs
+	5: {9, false, 0x0007}, // C L R Ls Rs
+	6: {9, true, 0x000f},  // C L R Ls Rs LFE1
+	8: {9, true, 0x004f},  // C L R Ls Rs LFE1 Lsr Rsr
+}
+
+// NewDTSSpecificBox creates the ddts box of a DTS stream of the given sample
+// entry type with the given sampling rate, channel count, bit depth and bit
+// rate in bit/s. Only the core substream of 'dtsc' streams is described by the
+// core fields; the frames of 'dtse' (DTS Express) streams hold 4096 samples
+// and those of the other types 512.
+func NewDTSSpecificBox(codec mp4.FourCC, samplingRate uint32, channels uint16, bitsPerSample uint16, bitrate uint32) (ddts *DTSSpecificBox, err error) {
+	if channels == 0 {
+		channels = 2
+	}
+	layout, ok := dtsChannelLayouts[channels]
+	if !ok {
+		err = fmt.Errorf("DTS channel count %d: %w", channels, ErrInvalidParam)
+		return
+	}
+	if samplingRate == 0 {
+		err = fmt.Errorf("DTS sampling rate unknown: %w", ErrInvalidParam)
+		return
+	}
+	depth := uint8(bitsPerSample)
+	if depth != 24 {
+		depth = 16
+	}
+	ddts = &DTSSpecificBox{
+		DTSSamplingFrequency: samplingRate,
+		MaxBitrate:           bitrate,
+		AvgBitrate:           bitrate,
+		PCMSampleDepth:       depth,
+		ChannelLayout:        layout.channelLayout,
+	}
+	switch codec {
+	case DtscFourCC:
+		ddts.CoreLayout = layout.coreLayout
+		ddts.CoreLFEPresent = layout.lfe
+	case DtseFourCC:
+		ddts.FrameDuration = 3 // 4096 samples
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestNewDTSSpecificBox(t *testing.T) {
+	tests := []struct {
+		name          string
+		codec         mp4.FourCC
+		samplingRate  uint32
+		channels      uint16
+		bitsPerSample uint16
+		want          DTSSpecificBox
+		wantErr       error
+	}{
+		{
+			name:         "core 5.1",
+			codec:        DtscFourCC,
+			samplingRate: 48000, channels: 6, bitsPerSample: 24,
+			want: DTSSpecificBox{
+				DTSSamplingFrequency: 48000, MaxBitrate: 1509000, AvgBitrate: 1509000, PCMSampleDepth: 24,
+				CoreLayout: 9, CoreLFEPresent: true, ChannelLayout: 0x000f,
+			},
+		},
+		{
+			name:         "HD 7.1 without the core fields",
+			codec:        DtshFourCC,
+			samplingRate: 48000, channels: 8, bitsPerSample: 16,
+			want: DTSSpecificBox{
+				DTSSamplingFrequency: 48000, MaxBitrate: 1509000, AvgBitrate: 1509000, PCMSampleDepth: 16,
+				ChannelLayout: 0x004f,
+			},
+		},
+		{
+			name:         "express with 4096 sample frames and default stereo",
+			codec:        DtseFourCC,
+			samplingRate: 48000, bitsPerSample: 20,
+			want: DTSSpecificBox{
+				DTSSamplingFrequency: 48000, MaxBitrate: 1509000, AvgBitrate: 1509000, PCMSampleDepth: 16,
+				FrameDuration: 3, ChannelLayout: 0x0002,
+			},
+		},
+		{name: "unsupported channel count", codec: DtscFourCC, samplingRate: 48000, channels: 7, wantErr: ErrInvalidParam},
+		{name: "unknown sampling rate", codec: DtscFourCC, channels: 2, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDTSSpecificBox(tt.codec, tt.samplingRate, tt.channels, tt.bitsPerSample, 1509000)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewDTSSpecificBox() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("NewDTSSpecificBox() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
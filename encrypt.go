@@ -0,0 +1,213 @@
+package smoothstreaming
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+	"github.com/google/uuid"
+)
+
+// Encrypter encrypts the samples of clear fragments with the content key of
+// the KID of their track, the reverse of Decrypter. Together they re-encrypt
+// fragments with a new key, see ReencryptFragment.
+type Encrypter struct {
+	// the 16-byte AES content keys by KID.
+	Keys map[[16]byte][]byte
+
+	// the source of the per-sample IVs, crypto/rand.Reader if nil.
+	Rand io.Reader
+}
+
+// EncryptFragment encrypts in place the samples of a clear fragment of the
+// protected track p with the key of p.KID, following the scheme of p, and adds
+// to each track fragment a senc box with the saiz and saio boxes locating it.
+// The IVs are 8 bytes for 'cenc', and 16 bytes for 'cbcs' unless p has a
+// ConstantIV. The NAL units of AVC and HEVC samples, with the 4-byte lengths
+// of Smooth Streaming, are encrypted as subsamples leaving their length and
+// header clear. Other samples are encrypted whole.
+//
+// The encrypted fragments follow the init segment of p, whose tenc box
+// declares the same parameters.
+func (e Encrypter) EncryptFragment(f *Fragment, p MoovProcessor) (err error) {
+	for i := range f.Tracks {
+		if err = e.encryptFragmentTrack(f.Data, &f.Tracks[i], p); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ReencryptFragment decrypts a fragment of the track from with d, and
+// encrypts it for the track to with e, for key rotation. The init segment of
+// to, usually from.WithProtection with the new KID, replaces the one of from.
+func (e Encrypter) ReencryptFragment(f *Fragment, d Decrypter, from, to MoovProcessor) (err error) {
+	if err = d.DecryptFragment(f, from); err != nil {
+		return
+	}
+	err = e.EncryptFragment(f, to)
+	return
+}
+
+func (e Encrypter) encryptFragmentTrack(data []byte, t *FragmentTrack, p MoovProcessor) (err error) {
+	if t.SampleEncryption != nil {
+		err = fmt.Errorf("track %d is already encrypted: %w", t.Header.TrackID, ErrInvalidParam)
+		return
+	}
+	scheme, err := p.encryptionScheme()
+	if err != nil {
+		return
+	}
+	cryptByteBlock, skipByteBlock, err := p.encryptionPattern()
+	if err != nil {
+		return
+	}
+	key, ok := e.Keys[p.KID]
+	if !ok {
+		err = fmt.Errorf("KID %x: %w", p.KID, ErrKeyNotFound)
+		return
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = fmt.Errorf("key of KID %x: %w", p.KID, ErrInvalidParam)
+		return
+	}
+	ranges, err := t.sampleRanges()
+	if err != nil {
+		return
+	}
+	random := e.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+
+	senc := &SampleEncryptionBox{IVSize: 8}
+	senc.Type = mp4.SencBoxType
+	if scheme == CbcsFourCC {
+		senc.IVSize = 16
+		if len(p.ConstantIV) > 0 {
+			senc.IVSize = 0
+		}
+	}
+	nalHeaderSize := nalUnitHeaderSize(p.Codec)
+	if nalHeaderSize > 0 {
+		senc.Mp4BoxSetFlags(mp4.FLAG_SENC_USE_SUBSAMPLE_ENCRYPTION)
+	}
+	senc.Samples = make([]mp4.SampleEncryptionSampleEntry, len(ranges))
+	for i, r := range ranges {
+		sample := data[r.offset : r.offset+r.size]
+		entry := &senc.Samples[i]
+		entry.InitializationVector = make([]byte, senc.IVSize)
+		if _, err = io.ReadFull(random, entry.InitializationVector); err != nil {
+			return
+		}
+		if nalHeaderSize > 0 {
+			if entry.Subsamples, err = nalUnitSubsamples(sample, nalHeaderSize); err != nil {
+				err = fmt.Errorf("track %d sample %d: %w", t.Header.TrackID, i, err)
+				return
+			}
+		}
+		switch scheme {
+		case mp4.CencFourCC:
+			err = cryptSampleCTR(block, entry.InitializationVector, entry.Subsamples, sample)
+		case CbcsFourCC:
+			iv := entry.InitializationVector
+			if len(iv) == 0 {
+				iv = p.ConstantIV
+			}
+			err = cryptSampleCBC(cipher.NewCBCEncrypter, block, iv, entry.Subsamples, sample, cryptByteBlock, skipByteBlock, false)
+		}
+		if err != nil {
+			err = fmt.Errorf("track %d sample %d: %w", t.Header.TrackID, i, err)
+			return
+		}
+	}
+
+	if err = t.Traf.Mp4BoxReplaceChildren(append(t.Traf.Mp4BoxChildren(), senc)); err != nil {
+		return
+	}
+	t.SampleEncryption = senc
+	err = t.locateSampleEncryption()
+	return
+}
+
+// nalUnitHeaderSize returns the size of the NAL unit header of the codec, 0 if
+// its samples are not made of NAL units.
+func nalUnitHeaderSize(codec mp4.FourCC) int {
+	switch codec {
+	case mp4.Avc1FourCC:
+		return 1
+	case mp4.Hvc1FourCC, mp4.Hev1FourCC, mp4.Dvh1FourCC, mp4.DvheFourCC:
+		return 2
+	}
+	return 0
+}
+
+// nalUnitSubsamples splits a sample of NAL units with 4-byte lengths into
+// subsamples that leave the length and the header of each NAL unit clear and
+// protect the whole 16-byte blocks of its payload, ISO/IEC 23001-7 10.2.
+func nalUnitSubsamples(sample []byte, nalHeaderSize int) (subsamples []mp4.SampleEncryptionSubsampleEntry, err error) {
+	nalus, err := splitNALUnits(sample)
+	if err != nil {
+		return
+	}
+	for _, nalu := range nalus {
+		size := 4 + len(nalu)
+		var protected int
+		if len(nalu) > nalHeaderSize {
+			protected = (len(nalu) - nalHeaderSize) / aes.BlockSize * aes.BlockSize
+		}
+		clear := size - protected
+		// the clear data extends the preceding subsample if it has no
+		// protected data
+		if n := len(subsamples); n > 0 && subsamples[n-1].BytesOfProtectedData == 0 && int(subsamples[n-1].BytesOfClearData)+clear <= 0xffff {
+			subsamples[n-1].BytesOfClearData += uint16(clear)
+			subsamples[n-1].BytesOfProtectedData = uint32(protected)
+			continue
+		}
+		subsamples = append(subsamples, mp4.SampleEncryptionSubsampleEntry{
+			BytesOfClearData:     uint16(clear),
+			BytesOfProtectedData: uint32(protected),
+		})
+	}
+	return
+}
+
+// splitNALUnits returns the NAL units of a sample of NAL units with the
+// 4-byte lengths of Smooth Streaming, without their lengths. The NAL units
+// alias the sample.
+func splitNALUnits(sample []byte) (nalus [][]byte, err error) {
+	for offset := 0; offset < len(sample); {
+		if len(sample)-offset < 4 {
+			err = fmt.Errorf("NAL unit length truncated: %w", ErrInvalidParam)
+			return
+		}
+		size := int(binary.BigEndian.Uint32(sample[offset:]))
+		offset += 4
+		if size > len(sample)-offset {
+			err = fmt.Errorf("NAL unit of %d bytes exceeds the sample: %w", size, ErrInvalidParam)
+			return
+		}
+		nalus = append(nalus, sample[offset:offset+size])
+		offset += size
+	}
+	return
+}
+
+// WithProtection returns the track protected by kid with the scheme, 'cenc'
+// or 'cbcs', whose init segment declares the tenc parameters of the fragments
+// encrypted by EncryptFragment. The protection systems of the previous key
+// do not apply to the new one and are removed.
+func (p MoovProcessor) WithProtection(kid [16]byte, scheme mp4.FourCC) MoovProcessor {
+	p.Protected = true
+	p.KID = kid
+	p.EncryptionScheme = scheme
+	p.SystemID = uuid.Nil
+	p.ProtectionInitData = nil
+	p.ProtectionSystems = nil
+	return p
+}
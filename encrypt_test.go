@@ -0,0 +1,241 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+	"github.com/google/uuid"
+)
+
+// testNALUnitFragment returns a clear fragment of two AVC samples of NAL units
+// with 4-byte lengths, and its sample data.
+func testNALUnitFragment(t *testing.T) (f Fragment, data []byte) {
+	t.Helper()
+	nalUnit := func(payloadSize int) []byte {
+		return append([]byte{0, 0, 0, byte(1 + payloadSize), 0x65}, bytes.Repeat([]byte{0x5a}, payloadSize)...)
+	}
+	sample1 := nalUnit(32)
+	sample2 := append(nalUnit(5), nalUnit(20)...)
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := f.Tracks[0].Runs[0]
+	run.Samples[0].SampleSize, run.Samples[1].SampleSize = uint32(len(sample1)), uint32(len(sample2))
+	f.Data = append(sample1, sample2...)
+	return f, append([]byte{}, f.Data...)
+}
+
+func TestEncrypterEncryptFragment(t *testing.T) {
+	kid := [16]byte(testPlayReadyKID)
+	keys := map[[16]byte][]byte{kid: testKey}
+	tests := []struct {
+		name           string
+		processor      MoovProcessor
+		nalUnits       bool
+		wantIVSize     uint8
+		wantSubsamples bool
+	}{
+		{name: "cenc audio", processor: MoovProcessor{Codec: Mp4aFourCC, StreamType: AudioStream}, wantIVSize: 8},
+		{name: "cenc video", processor: MoovProcessor{Codec: mp4.Avc1FourCC, StreamType: VideoStream}, nalUnits: true, wantIVSize: 8, wantSubsamples: true},
+		{name: "cbcs video", processor: MoovProcessor{Codec: mp4.Avc1FourCC, StreamType: VideoStream, EncryptionScheme: CbcsFourCC}, nalUnits: true, wantIVSize: 16, wantSubsamples: true},
+		{
+			name:       "cbcs audio with a constant IV",
+			processor:  MoovProcessor{Codec: Mp4aFourCC, StreamType: AudioStream, EncryptionScheme: CbcsFourCC, ConstantIV: bytes.Repeat([]byte{7}, 16)},
+			nalUnits:   true,
+			wantIVSize: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.nalUnits {
+				f, _ = testNALUnitFragment(t)
+			}
+			clear := append([]byte{}, f.Data...)
+			p := tt.processor.WithProtection(kid, tt.processor.EncryptionScheme)
+			e := Encrypter{Keys: keys, Rand: bytes.NewReader(bytes.Repeat([]byte{0x11}, 64))}
+			if err = e.EncryptFragment(&f, p); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err = f.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if f, err = ParseFragment(&buf); err != nil {
+				t.Fatal(err)
+			}
+			track := f.Tracks[0]
+			senc := track.SampleEncryption
+			if senc == nil || track.AuxInfoSizes == nil || track.AuxInfoOffsets == nil {
+				t.Fatalf("senc %v, saiz %v, saio %v, want all three", senc, track.AuxInfoSizes, track.AuxInfoOffsets)
+			}
+			if senc.IsPIFF() || senc.IVSize != tt.wantIVSize || senc.hasSubsamples() != tt.wantSubsamples || len(senc.Samples) != 2 {
+				t.Errorf("senc PIFF %v, IV size %d, subsamples %v, %d samples, want false, %d, %v, 2",
+					senc.IsPIFF(), senc.IVSize, senc.hasSubsamples(), len(senc.Samples), tt.wantIVSize, tt.wantSubsamples)
+			}
+			if bytes.Equal(f.Data, clear) {
+				t.Error("Data not encrypted")
+			}
+			if err = (Decrypter{Keys: keys}).DecryptFragment(&f, p); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(f.Data, clear) {
+				t.Errorf("decrypted Data = %x, want %x", f.Data, clear)
+			}
+		})
+	}
+}
+
+func TestEncrypterEncryptFragmentNALUnitHeadersClear(t *testing.T) {
+	f, clear := testNALUnitFragment(t)
+	p := MoovProcessor{Codec: mp4.Avc1FourCC, StreamType: VideoStream}.WithProtection([16]byte(testPlayReadyKID), mp4.CencFourCC)
+	e := Encrypter{Keys: map[[16]byte][]byte{[16]byte(testPlayReadyKID): testKey}}
+	if err := e.EncryptFragment(&f, p); err != nil {
+		t.Fatal(err)
+	}
+	// the lengths and headers of the NAL units, the partial blocks before
+	// their protected payloads and NAL units too short to protect are clear
+	for _, r := range [][2]int{{0, 5}, {37, 37 + 19}} {
+		if !bytes.Equal(f.Data[r[0]:r[1]], clear[r[0]:r[1]]) {
+			t.Errorf("bytes %d to %d = %x, want clear %x", r[0], r[1], f.Data[r[0]:r[1]], clear[r[0]:r[1]])
+		}
+	}
+	want := [][]mp4.SampleEncryptionSubsampleEntry{
+		{{BytesOfClearData: 5, BytesOfProtectedData: 32}},
+		{{BytesOfClearData: 19, BytesOfProtectedData: 16}},
+	}
+	for i, sample := range f.Tracks[0].SampleEncryption.Samples {
+		if !reflect.DeepEqual(sample.Subsamples, want[i]) {
+			t.Errorf("sample %d subsamples %+v, want %+v", i, sample.Subsamples, want[i])
+		}
+	}
+}
+
+func TestNALUnitSubsamples(t *testing.T) {
+	tests := []struct {
+		name          string
+		sample        []byte
+		nalHeaderSize int
+		want          []mp4.SampleEncryptionSubsampleEntry
+		wantErr       error
+	}{
+		{
+			name:          "AVC NAL unit with a partial block",
+			sample:        append([]byte{0, 0, 0, 20, 0x65}, make([]byte, 19)...),
+			nalHeaderSize: 1,
+			want:          []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 8, BytesOfProtectedData: 16}},
+		},
+		{
+			name:          "HEVC header",
+			sample:        append([]byte{0, 0, 0, 18, 0x26, 0x01}, make([]byte, 16)...),
+			nalHeaderSize: 2,
+			want:          []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 6, BytesOfProtectedData: 16}},
+		},
+		{
+			name:          "clear NAL units merge",
+			sample:        []byte{0, 0, 0, 2, 0x09, 0xf0, 0, 0, 0, 1, 0x0c},
+			nalHeaderSize: 1,
+			want:          []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 11}},
+		},
+		{name: "truncated length", sample: []byte{0, 0, 1}, nalHeaderSize: 1, wantErr: ErrInvalidParam},
+		{name: "NAL unit beyond the sample", sample: []byte{0, 0, 0, 9, 0x65}, nalHeaderSize: 1, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nalUnitSubsamples(tt.sample, tt.nalHeaderSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("nalUnitSubsamples() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nalUnitSubsamples() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncrypterEncryptFragmentErrors(t *testing.T) {
+	kid := [16]byte(testPlayReadyKID)
+	tests := []struct {
+		name    string
+		senc    *SampleEncryptionBox
+		keys    map[[16]byte][]byte
+		wantErr error
+	}{
+		{name: "already encrypted", senc: testSampleEncryptionBox(8, false), keys: map[[16]byte][]byte{kid: testKey}, wantErr: ErrInvalidParam},
+		{name: "no key", keys: map[[16]byte][]byte{}, wantErr: ErrKeyNotFound},
+		{name: "short key", keys: map[[16]byte][]byte{kid: {1}}, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, tt.senc)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := MoovProcessor{Codec: Mp4aFourCC}.WithProtection(kid, mp4.CencFourCC)
+			if err = (Encrypter{Keys: tt.keys}).EncryptFragment(&f, p); !errors.Is(err, tt.wantErr) {
+				t.Errorf("EncryptFragment() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncrypterReencryptFragment(t *testing.T) {
+	oldKID, newKID := [16]byte(testPlayReadyKID), [16]byte{0xff}
+	newKey := bytes.Repeat([]byte{0x99}, 16)
+	f, clear := testNALUnitFragment(t)
+	from := MoovProcessor{Codec: mp4.Avc1FourCC, StreamType: VideoStream}.WithProtection(oldKID, mp4.CencFourCC)
+	to := from.WithProtection(newKID, CbcsFourCC)
+	if err := (Encrypter{Keys: map[[16]byte][]byte{oldKID: testKey}}).EncryptFragment(&f, from); err != nil {
+		t.Fatal(err)
+	}
+	d := Decrypter{Keys: map[[16]byte][]byte{oldKID: testKey, newKID: newKey}}
+	e := Encrypter{Keys: map[[16]byte][]byte{newKID: newKey}}
+	if err := e.ReencryptFragment(&f, d, from, to); err != nil {
+		t.Fatal(err)
+	}
+	if senc := f.Tracks[0].SampleEncryption; senc == nil || senc.IVSize != 16 {
+		t.Fatalf("senc %+v, want 16-byte cbcs IVs", senc)
+	}
+	if err := d.DecryptFragment(&f, to); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.Data, clear) {
+		t.Errorf("Data = %x, want %x", f.Data, clear)
+	}
+}
+
+func TestWithProtection(t *testing.T) {
+	p := MoovProcessor{
+		SystemID:           PlayReadySystemID,
+		ProtectionInitData: []byte{1},
+		ProtectionSystems:  []ProtectionSystem{{SystemID: WidevineSystemID}},
+	}
+	got := p.WithProtection([16]byte{2}, CbcsFourCC)
+	if !got.Protected || got.KID != [16]byte{2} || got.EncryptionScheme != CbcsFourCC {
+		t.Errorf("protected %v, KID %x, scheme %s, want true, 02, cbcs", got.Protected, got.KID, got.EncryptionScheme)
+	}
+	if got.SystemID != uuid.Nil || got.ProtectionInitData != nil || got.ProtectionSystems != nil {
+		t.Errorf("protection systems %v, %x, %v kept", got.SystemID, got.ProtectionInitData, got.ProtectionSystems)
+	}
+}
+
+func TestSplitNALUnits(t *testing.T) {
+	sample := []byte{0, 0, 0, 2, 0x09, 0xf0, 0, 0, 0, 0, 0, 0, 0, 1, 0x0c}
+	nalus, err := splitNALUnits(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]byte{{0x09, 0xf0}, {}, {0x0c}}; !reflect.DeepEqual(nalus, want) {
+		t.Errorf("splitNALUnits() = %x, want %x", nalus, want)
+	}
+	if _, err = splitNALUnits(sample[:len(sample)-1]); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("splitNALUnits() of a truncated sample error = %v, want %v", err, ErrInvalidParam)
+	}
+}
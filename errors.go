@@ -4,3 +4,9 @@ import "errors"
 
 var ErrUnknownCodec = errors.New("codec not supported")
 var ErrInvalidParam = errors.New("invalid parameter")
+var ErrUnresolvedPlaceholder = errors.New("unresolved url pattern placeholder")
+var ErrInvalidTimeline = errors.New("invalid fragment timeline")
+var ErrKeyNotFound = errors.New("decryption key not found")
+var ErrFragmentMismatch = errors.New("fragment does not match the manifest")
+var ErrUnexpectedStatus = errors.New("unexpected http response status")
+var ErrUnsupportedSystem = errors.New("protection system not supported")
@@ -0,0 +1,156 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-webdl/mp4"
+)
+
+// SparseEvent is a sample of a sparse stream, such as an ad insertion marker
+// or a text event, carried either by the ManifestOutputSample field of the
+// manifest or by a sparse fragment.
+type SparseEvent struct {
+	// the presentation time of the event, in Timescale.
+	Time uint64
+
+	// the duration of the event, in Timescale, 0 if it is unknown.
+	Duration uint64
+
+	// the timescale of the sparse stream.
+	Timescale uint64
+
+	// identifies the event, the lower 32 bits of its time for the events of
+	// ManifestOutputEvents and SparseFragmentEvents, which is stable across
+	// manifest refreshes.
+	ID uint32
+
+	// the sample data of the event.
+	Data []byte
+}
+
+// ManifestOutputEvents returns the events of the track of a sparse stream
+// whose samples the manifest carries in the ManifestOutputSample fields, one
+// per fragment of the timeline that has a sample for the track.
+func (m *SmoothStreamingMedia) ManifestOutputEvents(s *StreamIndex, track *Track) (events []SparseEvent, err error) {
+	if !s.ManifestOutput {
+		err = fmt.Errorf("stream has no manifest output: %w", ErrInvalidParam)
+		return
+	}
+	timeline, err := m.StreamTimeline(s)
+	if err != nil {
+		return
+	}
+	timescale := m.StreamTimeScale(s)
+	// the fragments of the timeline follow the StreamFragmentElement fields,
+	// each expanded by its repeat count
+	var next int
+	for _, c := range s.Fragments {
+		fragment := timeline[next]
+		if c.Repeat != nil && *c.Repeat > 1 {
+			next += int(*c.Repeat)
+		} else {
+			next++
+		}
+		for _, tf := range c.TrackFragments {
+			if tf.Index != track.Index || len(tf.ManifestOutputSample) == 0 {
+				continue
+			}
+			events = append(events, SparseEvent{
+				Time:      fragment.Time,
+				Duration:  fragment.Duration,
+				Timescale: timescale,
+				ID:        uint32(fragment.Time),
+				Data:      tf.ManifestOutputSample,
+			})
+		}
+	}
+	return
+}
+
+// SparseFragmentEvents returns the events of a fragment of the sparse track
+// p, one per non-empty sample.
+func SparseFragmentEvents(f Fragment, p MoovProcessor) (events []SparseEvent, err error) {
+	samples, err := f.Samples(p)
+	if err != nil {
+		return
+	}
+	for _, sample := range samples {
+		if len(sample.Data) == 0 {
+			continue
+		}
+		var time uint64
+		if t := sample.PresentationTime(); t > 0 {
+			time = uint64(t)
+		}
+		events = append(events, SparseEvent{
+			Time:      time,
+			Duration:  uint64(sample.Duration),
+			Timescale: p.Timescale,
+			ID:        uint32(time),
+			Data:      sample.Data,
+		})
+	}
+	return
+}
+
+// EventInserter carries the events of a sparse stream into the media segments
+// of DASH output as version 1 emsg boxes, preserving the ad and marker
+// signaling that Smooth Streaming carries in a separate stream.
+type EventInserter struct {
+	// the scheme and value of the emsg boxes, e.g.
+	// urn:scte:scte35:2013:bin for SCTE-35 splice information.
+	SchemeIDURI string
+	Value       string
+
+	// the events to insert, in any order.
+	Events []SparseEvent
+}
+
+// Insert adds to the fragment f of the media track p an emsg box for each
+// event whose presentation time falls within the fragment, from its decode
+// time declared by a tfdt box or TfxdBox to the end of its samples. Events
+// keep their own timescale. Inserting the events into every fragment of the
+// track delivers each event exactly once.
+func (ei EventInserter) Insert(f *Fragment, p MoovProcessor) (err error) {
+	if ei.SchemeIDURI == "" {
+		err = fmt.Errorf("event scheme not set: %w", ErrInvalidParam)
+		return
+	}
+	if len(f.Tracks) == 0 {
+		return
+	}
+	t := f.Tracks[0]
+	start, ok := t.DecodeTime()
+	if !ok {
+		err = fmt.Errorf("track %d fragment has no decode time: %w", t.Header.TrackID, ErrInvalidParam)
+		return
+	}
+	end := start + t.Duration()
+	for _, event := range ei.Events {
+		time := rescaleTime(event.Time, event.Timescale, p.Timescale)
+		if time < start || time >= end {
+			continue
+		}
+		if event.Timescale > math.MaxUint32 {
+			err = fmt.Errorf("event timescale %d exceeds 32 bits: %w", event.Timescale, ErrInvalidParam)
+			return
+		}
+		emsg := &EventMessageBox{
+			SchemeIDURI:      mp4.NullTerminatedString(ei.SchemeIDURI),
+			Value:            mp4.NullTerminatedString(ei.Value),
+			Timescale:        uint32(event.Timescale),
+			PresentationTime: event.Time,
+			EventDuration:    math.MaxUint32,
+			ID:               event.ID,
+			MessageData:      event.Data,
+		}
+		emsg.Version = 1
+		if event.Duration > 0 && event.Duration < math.MaxUint32 {
+			emsg.EventDuration = uint32(event.Duration)
+		}
+		emsg.Mp4BoxUpdate()
+		f.Events = append(f.Events, emsg)
+	}
+	return
+}
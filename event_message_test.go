@@ -0,0 +1,132 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestManifestOutputEvents(t *testing.T) {
+	const manifest = `<SmoothStreamingMedia Duration="70000">` +
+		`<StreamIndex Type="text" Name="ad" TimeScale="1000" ManifestOutput="true" Url="QualityLevels({bitrate})/Fragments(ad={start time})">` +
+		`<QualityLevel Index="0" Bitrate="0" FourCC=""/><QualityLevel Index="1" Bitrate="0" FourCC=""/>` +
+		`<c t="0" d="2000" r="2"><f i="0">aGVsbG8=</f></c><c d="2000"><f i="1">eA==</f></c><c d="1000"><f i="0">d29ybGQ=</f></c>` +
+		`</StreamIndex></SmoothStreamingMedia>`
+	var m SmoothStreamingMedia
+	if err := xml.Unmarshal([]byte(manifest), &m); err != nil {
+		t.Fatal(err)
+	}
+	s := m.Streams[0]
+	events, err := m.ManifestOutputEvents(s, s.Tracks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SparseEvent{
+		{Time: 0, Duration: 2000, Timescale: 1000, ID: 0, Data: []byte("hello")},
+		{Time: 6000, Duration: 1000, Timescale: 1000, ID: 6000, Data: []byte("world")},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("ManifestOutputEvents() = %+v, want %+v", events, want)
+	}
+
+	s.ManifestOutput = false
+	if _, err = m.ManifestOutputEvents(s, s.Tracks[0]); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ManifestOutputEvents() without manifest output error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestSparseFragmentEvents(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), 10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second sample is empty
+	f.Tracks[0].Runs[0].Samples[1].SampleSize = 0
+	f.Data = []byte("0123456789")
+	events, err := SparseFragmentEvents(f, MoovProcessor{Timescale: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SparseEvent{{Time: 10000, Duration: 1000, Timescale: 1000, ID: 10000, Data: []byte("0123456789")}}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("SparseFragmentEvents() = %+v, want %+v", events, want)
+	}
+}
+
+func TestEventInserter(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), 10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ei := EventInserter{
+		SchemeIDURI: "urn:scte:scte35:2013:bin",
+		Value:       "1",
+		Events: []SparseEvent{
+			{Time: 1100, Timescale: 1000, ID: 2, Data: []byte{2}},
+			{Time: 900, Duration: 100, Timescale: 1000, ID: 0, Data: []byte{0}},
+			{Time: 1000, Duration: 100, Timescale: 1000, ID: 1, Data: []byte{1}},
+			{Time: 1200, Duration: 100, Timescale: 1000, ID: 3, Data: []byte{3}},
+		},
+	}
+	// the fragment spans 10000 to 12000 in the timescale of the track, 1000
+	// to 1200 in the timescale of the events
+	if err = ei.Insert(&f, MoovProcessor{Timescale: 10000}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = ParseFragment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Events) != 2 {
+		t.Fatalf("got %d emsg boxes, want 2", len(f.Events))
+	}
+	for i, want := range []struct {
+		time     uint64
+		duration uint32
+		id       uint32
+	}{{time: 1100, duration: math.MaxUint32, id: 2}, {time: 1000, duration: 100, id: 1}} {
+		emsg := f.Events[i]
+		if emsg.Version != 1 || emsg.SchemeIDURI != "urn:scte:scte35:2013:bin" || emsg.Value != "1" || emsg.Timescale != 1000 {
+			t.Errorf("emsg %d version %d, scheme %q, value %q, timescale %d", i, emsg.Version, emsg.SchemeIDURI, emsg.Value, emsg.Timescale)
+		}
+		if emsg.PresentationTime != want.time || emsg.EventDuration != want.duration || emsg.ID != want.id || !bytes.Equal(emsg.MessageData, []byte{byte(want.id)}) {
+			t.Errorf("emsg %d at %d lasting %d with ID %d, want at %d lasting %d with ID %d", i, emsg.PresentationTime, emsg.EventDuration, emsg.ID, want.time, want.duration, want.id)
+		}
+	}
+	if len(f.Data) != 16 || f.Tracks[0].SampleCount() != 2 {
+		t.Errorf("fragment of %d bytes with %d samples after the emsg boxes, want 16 bytes with 2", len(f.Data), f.Tracks[0].SampleCount())
+	}
+}
+
+func TestEventInserterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		ei   EventInserter
+	}{
+		{name: "no scheme", data: withTfxd(t, testFragment(t, nil), 10000)},
+		{name: "no decode time", data: testFragment(t, nil), ei: EventInserter{SchemeIDURI: "urn:a"}},
+		{
+			name: "64-bit timescale",
+			data: withTfxd(t, testFragment(t, nil), 10000),
+			ei:   EventInserter{SchemeIDURI: "urn:a", Events: []SparseEvent{{Time: 1 << 33, Timescale: 1 << 33}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = tt.ei.Insert(&f, MoovProcessor{Timescale: 10000}); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("Insert() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
@@ -0,0 +1,553 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// Fragment is the response to a Fragment Request: a moof box describing the
+// samples of the fragment and the mdat box holding them. It is the
+// counterpart of MoovProcessor for the media segments of a track.
+//
+// The typed fields point into the Moof box tree, so changes made through them
+// are reflected when the moof box is written.
+type Fragment struct {
+	// the styp box written before the moof box when the fragment is stored
+	// as a segment file, nil if the fragment has none.
+	SegmentType *SegmentTypeBox
+
+	// the prft box written before the moof box, relating the decode time of
+	// the fragment to the wall-clock time of its production, nil if the
+	// fragment has none, see CMAFChunker.
+	ProducerReferenceTime *ProducerReferenceTimeBox
+
+	// the emsg boxes written before the moof box, carrying the events of the
+	// segment in DASH, see EventInserter.
+	Events []*EventMessageBox
+
+	Moof mp4.Box
+
+	// the movie fragment header, carrying the sequence number of the
+	// fragment.
+	Header *mp4.MovieFragmentHeaderBox
+
+	// the track fragments in the order of the moof box. Smooth Streaming
+	// fragments carry exactly one.
+	Tracks []FragmentTrack
+
+	// the payload of the mdat box.
+	Data []byte
+
+	mdatHeaderSize uint32
+}
+
+// FragmentTrack is a traf box of a Fragment.
+type FragmentTrack struct {
+	Traf mp4.Box
+
+	Header *mp4.TrackFragmentHeaderBox
+
+	// the track runs in the order of the traf box.
+	Runs []*mp4.TrackRunBox
+
+	// the sample encryption data of the track fragment, either the PIFF uuid
+	// box of Smooth Streaming or a senc box, or nil if the samples are not
+	// encrypted.
+	SampleEncryption *SampleEncryptionBox
+
+	// the saiz and saio boxes locating the senc box of Common Encryption, nil
+	// for PIFF fragments.
+	AuxInfoSizes   *SampleAuxiliaryInformationSizesBox
+	AuxInfoOffsets *SampleAuxiliaryInformationOffsetsBox
+
+	// the absolute time and duration of the fragment, nil if the fragment has
+	// no TfxdBox.
+	Tfxd *TfxdBox
+
+	// the fragments that follow the fragment in a live presentation, nil if
+	// the fragment has no TfrfBox.
+	Tfrf *TfrfBox
+
+	// the offset in the Data of the Fragment of the samples of each run.
+	runOffsets []int64
+}
+
+// ParseFragment reads a fragment response, a moof box followed by an mdat
+// box, optionally preceded by a styp box, a prft box and emsg boxes. Free,
+// skip and uuid boxes found before the moof or mdat box, which some servers
+// and proxies interleave, are skipped. The sample data is read into memory.
+func ParseFragment(r io.Reader) (f Fragment, err error) {
+	header, err := mp4.ReadHeader(r)
+	if err != nil {
+		return
+	}
+	for header.Type != mp4.MoofBoxType {
+		switch {
+		case isStrayMp4Box(header):
+			if _, err = skipMp4Box(r, header); err != nil {
+				return
+			}
+		case header.Type == StypBoxType || header.Type == PrftBoxType || header.Type == EmsgBoxType:
+			var box mp4.Box
+			if box, err = mp4.ReadBoxAfterHeader(r, header); err != nil {
+				return
+			}
+			switch box := box.(type) {
+			case *SegmentTypeBox:
+				f.SegmentType = box
+			case *ProducerReferenceTimeBox:
+				f.ProducerReferenceTime = box
+			case *EventMessageBox:
+				f.Events = append(f.Events, box)
+			}
+		default:
+			err = fmt.Errorf("fragment starts with a %s box instead of moof: %w", header.Type, ErrInvalidParam)
+			return
+		}
+		if header, err = mp4.ReadHeader(r); err != nil {
+			return
+		}
+	}
+	if f.Moof, err = readMp4Box(r, header); err != nil {
+		return
+	}
+	if err = f.parseMoofMp4Box(); err != nil {
+		return
+	}
+	if f.Data, f.mdatHeaderSize, err = readMdatPayload(r); err != nil {
+		return
+	}
+	err = f.locateRuns()
+	return
+}
+
+func (f *Fragment) parseMoofMp4Box() (err error) {
+	for _, child := range f.Moof.Mp4BoxChildren() {
+		switch box := child.(type) {
+		case *mp4.MovieFragmentHeaderBox:
+			f.Header = box
+		case *mp4.TrackFragmentBox:
+			var traf FragmentTrack
+			if traf, err = parseTrafMp4Box(box); err != nil {
+				return
+			}
+			f.Tracks = append(f.Tracks, traf)
+		}
+	}
+	if f.Header == nil {
+		err = fmt.Errorf("moof box has no mfhd: %w", ErrInvalidParam)
+		return
+	}
+	if len(f.Tracks) == 0 {
+		err = fmt.Errorf("moof box has no traf: %w", ErrInvalidParam)
+	}
+	return
+}
+
+func parseTrafMp4Box(box mp4.Box) (traf FragmentTrack, err error) {
+	traf.Traf = box
+	for _, child := range box.Mp4BoxChildren() {
+		switch box := child.(type) {
+		case *mp4.TrackFragmentHeaderBox:
+			traf.Header = box
+		case *mp4.TrackRunBox:
+			traf.Runs = append(traf.Runs, box)
+		case *SampleEncryptionBox:
+			traf.SampleEncryption = box
+		case *SampleAuxiliaryInformationSizesBox:
+			traf.AuxInfoSizes = box
+		case *SampleAuxiliaryInformationOffsetsBox:
+			traf.AuxInfoOffsets = box
+		case *TfxdBox:
+			traf.Tfxd = box
+		case *TfrfBox:
+			traf.Tfrf = box
+		}
+	}
+	if traf.Header == nil {
+		err = fmt.Errorf("traf box has no tfhd: %w", ErrInvalidParam)
+	}
+	return
+}
+
+// readMdatPayload reads an mdat box and returns its payload and the size of
+// its header, including the stray boxes skipped before it, as the data
+// offsets of the track runs account for them.
+func readMdatPayload(r io.Reader) (data []byte, headerSize uint32, err error) {
+	var header *mp4.Header
+	for {
+		if header, err = mp4.ReadHeader(r); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("fragment has no mdat box: %w", ErrInvalidParam)
+			}
+			return
+		}
+		if !isStrayMp4Box(header) {
+			break
+		}
+		var size int64
+		if size, err = skipMp4Box(r, header); err != nil {
+			return
+		}
+		headerSize += uint32(size)
+	}
+	if header.Type != mp4.MdatBoxType {
+		err = fmt.Errorf("moof box followed by a %s box instead of mdat: %w", header.Type, ErrInvalidParam)
+		return
+	}
+	headerSize += header.HeaderSize()
+	size := int64(header.Size) - int64(header.HeaderSize())
+	switch header.Size {
+	case 0:
+		// the box extends to the end of the response
+		data, err = io.ReadAll(r)
+		return
+	case 1:
+		var largeSize uint64
+		if err = binary.Read(r, binary.BigEndian, &largeSize); err != nil {
+			return
+		}
+		headerSize += 8
+		size = int64(largeSize) - int64(header.HeaderSize()) - 8
+	}
+	if size < 0 {
+		err = fmt.Errorf("mdat box of %d bytes: %w", header.Size, ErrInvalidParam)
+		return
+	}
+	// the data is read incrementally rather than allocated upfront, so that a
+	// corrupt size fails on the short read
+	if data, err = io.ReadAll(io.LimitReader(r, size)); err != nil {
+		return
+	}
+	if int64(len(data)) < size {
+		err = fmt.Errorf("mdat box truncated at %d of %d bytes: %w", len(data), size, io.ErrUnexpectedEOF)
+	}
+	return
+}
+
+// isStrayMp4Box reports whether a top-level box of a response carries nothing
+// of use and can be skipped: free and skip boxes, which servers and proxies
+// use as padding, and uuid boxes of unknown extensions.
+func isStrayMp4Box(header *mp4.Header) bool {
+	return header.Type == mp4.FreeBoxType || header.Type == SkipBoxType || header.Type == mp4.UuidBoxType
+}
+
+// skipMp4Box discards the payload of a box whose header was read, and returns
+// the size of the box.
+func skipMp4Box(r io.Reader, header *mp4.Header) (size int64, err error) {
+	size = int64(header.Size)
+	payloadSize := size - int64(header.HeaderSize())
+	switch header.Size {
+	case 0:
+		// the box extends to the end of the stream
+		err = fmt.Errorf("%s box of unknown size: %w", header.Type, ErrInvalidParam)
+		return
+	case 1:
+		var largeSize uint64
+		if err = binary.Read(r, binary.BigEndian, &largeSize); err != nil {
+			return
+		}
+		size = int64(largeSize)
+		payloadSize = size - int64(header.HeaderSize()) - 8
+	}
+	if payloadSize < 0 {
+		err = fmt.Errorf("%s box of %d bytes: %w", header.Type, size, ErrInvalidParam)
+		return
+	}
+	_, err = io.CopyN(io.Discard, r, payloadSize)
+	return
+}
+
+// locateRuns resolves the data offsets of the track runs, which are relative
+// to the moof box, the base data offset of the track fragment or the end of
+// the preceding run, to offsets in the Data of the fragment. A base data
+// offset is taken relative to the moof box as well, since the response is not
+// part of a file.
+func (f *Fragment) locateRuns() (err error) {
+	var end int64 // the end of the data of the preceding track fragment
+	for i := range f.Tracks {
+		t := &f.Tracks[i]
+		flags := t.Header.Mp4BoxFlags()
+		base := end
+		switch {
+		case flags&mp4.FLAG_TFHD_BASE_DATA_OFFSET > 0:
+			base = int64(t.Header.BaseDataOffset) - int64(f.dataOffset())
+		case flags&mp4.FLAG_TFHD_DEFAULT_BASE_IS_MOOF > 0 || i == 0:
+			base = -int64(f.dataOffset())
+		}
+		t.runOffsets = make([]int64, len(t.Runs))
+		offset := base
+		for j, run := range t.Runs {
+			if run.Mp4BoxFlags()&mp4.FLAG_TRUN_DATA_OFFSET > 0 {
+				offset = base + int64(run.DataOffset)
+			}
+			size := t.runSize(run)
+			if offset < 0 || offset+size > int64(len(f.Data)) {
+				err = fmt.Errorf("track %d run %d of %d bytes at %d outside the mdat of %d bytes: %w", t.Header.TrackID, j, size, offset, len(f.Data), ErrInvalidParam)
+				return
+			}
+			t.runOffsets[j] = offset
+			offset += size
+		}
+		end = offset
+	}
+	return
+}
+
+// runSize returns the size of the samples of a track run. The sample sizes
+// default to the default sample size of the track fragment header; the
+// default of the trex box is not known to the fragment and taken as 0.
+func (t FragmentTrack) runSize(run *mp4.TrackRunBox) (size int64) {
+	if run.Mp4BoxFlags()&mp4.FLAG_TRUN_SAMPLE_SIZE == 0 {
+		if t.Header.Mp4BoxFlags()&mp4.FLAG_TFHD_DEFAULT_SAMPLE_SIZE > 0 {
+			size = int64(t.Header.DefaultSampleSize) * int64(run.SampleCount)
+		}
+		return
+	}
+	for _, sample := range run.Samples {
+		size += int64(sample.SampleSize)
+	}
+	return
+}
+
+// sampleRange is the location of a sample in the Data of a Fragment.
+type sampleRange struct {
+	offset int64
+	size   int64
+}
+
+// sampleRanges returns the location of each sample of the track fragment in
+// the Data of the fragment, in decode order.
+func (t FragmentTrack) sampleRanges() (ranges []sampleRange, err error) {
+	if len(t.runOffsets) != len(t.Runs) {
+		err = fmt.Errorf("track %d runs not located in the fragment data: %w", t.Header.TrackID, ErrInvalidParam)
+		return
+	}
+	for i, run := range t.Runs {
+		offset := t.runOffsets[i]
+		perSample := run.Mp4BoxFlags()&mp4.FLAG_TRUN_SAMPLE_SIZE > 0
+		if !perSample && t.Header.Mp4BoxFlags()&mp4.FLAG_TFHD_DEFAULT_SAMPLE_SIZE == 0 {
+			err = fmt.Errorf("track %d run %d has no sample sizes: %w", t.Header.TrackID, i, ErrInvalidParam)
+			return
+		}
+		for j := uint32(0); j < run.SampleCount; j++ {
+			size := int64(t.Header.DefaultSampleSize)
+			if perSample {
+				size = int64(run.Samples[j].SampleSize)
+			}
+			ranges = append(ranges, sampleRange{offset, size})
+			offset += size
+		}
+	}
+	return
+}
+
+// WriteTo writes the styp, prft and emsg boxes of the fragment, if any, and
+// its moof and mdat boxes to w and returns the number of bytes written. The
+// data offsets of the track runs are rewritten relative to the moof box, as
+// the sizes of its boxes may have changed, so the fragment can follow any
+// init segment or fragment in a file.
+func (f Fragment) WriteTo(w io.Writer) (n int64, err error) {
+	mdatHeaderSize, err := f.update()
+	if err != nil {
+		return
+	}
+	cw := &countingWriter{w: w}
+	defer func() { n = cw.n }()
+	for _, box := range append(f.segmentBoxes(), f.Moof) {
+		if err = box.Mp4BoxWrite(cw); err != nil {
+			return
+		}
+	}
+	if mdatHeaderSize == 8 {
+		err = binary.Write(cw, binary.BigEndian, uint32(mdatHeaderSize)+uint32(len(f.Data)))
+	} else {
+		err = binary.Write(cw, binary.BigEndian, uint32(1))
+	}
+	if err != nil {
+		return
+	}
+	if _, err = cw.Write(mp4.MdatBoxType[:]); err != nil {
+		return
+	}
+	if mdatHeaderSize == 16 {
+		if err = binary.Write(cw, binary.BigEndian, mdatHeaderSize+uint64(len(f.Data))); err != nil {
+			return
+		}
+	}
+	_, err = cw.Write(f.Data)
+	return
+}
+
+// update sets the flags and the data offsets of the track runs and the sizes
+// of the boxes of the moof box as written by WriteTo, and returns the size of
+// the header of the mdat box.
+func (f Fragment) update() (mdatHeaderSize uint64, err error) {
+	for _, t := range f.Tracks {
+		if len(t.runOffsets) != len(t.Runs) {
+			err = fmt.Errorf("track %d runs not located in the fragment data: %w", t.Header.TrackID, ErrInvalidParam)
+			return
+		}
+		flags := t.Header.Mp4BoxFlags()&^mp4.FLAG_TFHD_BASE_DATA_OFFSET | mp4.FLAG_TFHD_DEFAULT_BASE_IS_MOOF
+		t.Header.Mp4BoxSetFlags(flags)
+		for _, run := range t.Runs {
+			run.Mp4BoxSetFlags(run.Mp4BoxFlags() | mp4.FLAG_TRUN_DATA_OFFSET)
+		}
+	}
+	for _, box := range f.segmentBoxes() {
+		box.Mp4BoxUpdate()
+	}
+	mdatHeaderSize = 8
+	if uint64(len(f.Data))+mdatHeaderSize > 0xffffffff {
+		mdatHeaderSize += 8 // largesize
+	}
+	dataOffset := uint64(f.Moof.Mp4BoxUpdate()) + mdatHeaderSize
+	f.updateAuxInfoOffsets()
+	for _, t := range f.Tracks {
+		for i, run := range t.Runs {
+			offset := dataOffset + uint64(t.runOffsets[i])
+			if offset > 0x7fffffff {
+				err = fmt.Errorf("track %d run %d data offset %d exceeds 31 bits: %w", t.Header.TrackID, i, offset, ErrInvalidParam)
+				return
+			}
+			run.DataOffset = int32(offset)
+		}
+	}
+	return
+}
+
+// Size returns the size of the fragment as written by WriteTo.
+func (f Fragment) Size() (size uint64, err error) {
+	mdatHeaderSize, err := f.update()
+	if err != nil {
+		return
+	}
+	size = uint64(f.Moof.Mp4BoxSize()) + mdatHeaderSize + uint64(len(f.Data))
+	for _, box := range f.segmentBoxes() {
+		size += uint64(box.Mp4BoxSize())
+	}
+	return
+}
+
+// segmentBoxes returns the boxes of the fragment that precede its moof box,
+// in the order they are written.
+func (f Fragment) segmentBoxes() (boxes []mp4.Box) {
+	if f.SegmentType != nil {
+		boxes = append(boxes, f.SegmentType)
+	}
+	if f.ProducerReferenceTime != nil {
+		boxes = append(boxes, f.ProducerReferenceTime)
+	}
+	for _, emsg := range f.Events {
+		boxes = append(boxes, emsg)
+	}
+	return
+}
+
+// dataOffset returns the offset of Data from the first byte of the moof box,
+// the base of the data offsets of the track runs.
+func (f Fragment) dataOffset() uint64 {
+	return uint64(f.Moof.Mp4BoxSize()) + uint64(f.mdatHeaderSize)
+}
+
+// SequenceNumber returns the sequence number of the movie fragment header.
+func (f Fragment) SequenceNumber() uint32 {
+	return f.Header.SequenceNumber
+}
+
+// SampleCount returns the number of samples of the track runs of the track
+// fragment.
+func (t FragmentTrack) SampleCount() (count uint32) {
+	for _, run := range t.Runs {
+		count += run.SampleCount
+	}
+	return
+}
+
+// Duration returns the sum of the sample durations of the track runs of the
+// track fragment. The sample durations default to the default sample duration
+// of the track fragment header; the default of the trex box is not known to
+// the fragment and taken as 0.
+func (t FragmentTrack) Duration() (duration uint64) {
+	for _, run := range t.Runs {
+		if run.Mp4BoxFlags()&mp4.FLAG_TRUN_SAMPLE_DURATION == 0 {
+			if t.Header.Mp4BoxFlags()&mp4.FLAG_TFHD_DEFAULT_SAMPLE_DURATION > 0 {
+				duration += uint64(t.Header.DefaultSampleDuration) * uint64(run.SampleCount)
+			}
+			continue
+		}
+		for _, sample := range run.Samples {
+			duration += uint64(sample.SampleDuration)
+		}
+	}
+	return
+}
+
+// fragmentSample is a sample of a track fragment, with the defaults of the
+// track fragment header and of the trex box resolved.
+type fragmentSample struct {
+	sampleRange
+	duration              uint32
+	flags                 uint32
+	compositionTimeOffset int64
+}
+
+// samples returns the samples of the track fragment in decode order. The
+// defaults of the trex box are the ones of the init segment of p.
+func (t FragmentTrack) samples(p MoovProcessor) (samples []fragmentSample, err error) {
+	ranges, err := t.sampleRanges()
+	if err != nil {
+		return
+	}
+	headerFlags := t.Header.Mp4BoxFlags()
+	defaultDuration := p.defaultSampleDuration()
+	if headerFlags&mp4.FLAG_TFHD_DEFAULT_SAMPLE_DURATION > 0 {
+		defaultDuration = t.Header.DefaultSampleDuration
+	}
+	defaultFlags := p.defaultSampleFlags()
+	if headerFlags&mp4.FLAG_TFHD_DEFAULT_SAMPLE_FLAGS > 0 {
+		defaultFlags = t.Header.DefaultSampleFlags
+	}
+	samples = make([]fragmentSample, 0, len(ranges))
+	for _, run := range t.Runs {
+		flags := run.Mp4BoxFlags()
+		for i := uint32(0); i < run.SampleCount; i++ {
+			sample := fragmentSample{
+				sampleRange: ranges[len(samples)],
+				duration:    defaultDuration,
+				flags:       defaultFlags,
+			}
+			if flags&mp4.FLAG_TRUN_SAMPLE_DURATION > 0 {
+				sample.duration = run.Samples[i].SampleDuration
+			}
+			if flags&mp4.FLAG_TRUN_SAMPLE_FLAGS > 0 {
+				sample.flags = run.Samples[i].SampleFlags
+			} else if i == 0 && flags&mp4.FLAG_TRUN_FIRST_SAMPLE_FLAGS > 0 {
+				sample.flags = run.FirstSampleFlags
+			}
+			if flags&mp4.FLAG_TRUN_SAMPLE_COMPOSITION_TIME_OFFSET > 0 {
+				sample.compositionTimeOffset = run.Samples[i].SampleCompositionTimeOffset
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return
+}
+
+// DecodeTime returns the decode time of the first sample of the track
+// fragment from its tfdt box, or from its TfxdBox if it has none. ok is false
+// if the fragment has neither.
+func (t FragmentTrack) DecodeTime() (decodeTime uint64, ok bool) {
+	for _, child := range t.Traf.Mp4BoxChildren() {
+		if tfdt, isTfdt := child.(*TrackFragmentDecodeTimeBox); isTfdt {
+			return tfdt.BaseMediaDecodeTime, true
+		}
+	}
+	if t.Tfxd != nil {
+		return t.Tfxd.FragmentAbsoluteTime, true
+	}
+	return
+}
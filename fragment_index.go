@@ -0,0 +1,79 @@
+package smoothstreaming
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// indexFragments reads the fragmented MP4 file r of size bytes, the init
+// segment of the track p followed by its fragments, and returns the mfra box
+// listing the first sample of every fragment, a sync sample in Smooth
+// Streaming, along with the size of the init segment.
+func indexFragments(r io.ReaderAt, size int64, p MoovProcessor) (mfra mp4.Box, initSize int64, err error) {
+	tfra := &TrackFragmentRandomAccessBox{TrackID: p.TrackID}
+	for offset := int64(0); offset < size; {
+		section := io.NewSectionReader(r, offset, size-offset)
+		var header *mp4.Header
+		if header, err = mp4.ReadHeader(section); err != nil {
+			return
+		}
+		if header.Type != mp4.MoofBoxType {
+			var n int64
+			if n, err = skipMp4Box(section, header); err != nil {
+				return
+			}
+			offset += n
+			if header.Type == mp4.MoovBoxType {
+				initSize = offset
+			}
+			continue
+		}
+
+		cr := &countingReader{r: bufio.NewReader(io.NewSectionReader(r, offset, size-offset))}
+		var f Fragment
+		if f, err = ParseFragment(cr); err != nil {
+			err = fmt.Errorf("fragment at %d: %w", offset, err)
+			return
+		}
+		entry := TfraEntry{MoofOffset: uint64(offset), TrafNumber: 1, TrunNumber: 1, SampleNumber: 1}
+		if len(f.Tracks) > 0 {
+			t := f.Tracks[0]
+			entry.Time, _ = t.DecodeTime()
+			var samples []fragmentSample
+			if samples, err = t.samples(p); err != nil {
+				return
+			}
+			if len(samples) > 0 {
+				if time := int64(entry.Time) + samples[0].compositionTimeOffset; time > 0 {
+					entry.Time = uint64(time)
+				}
+			}
+		}
+		tfra.Entries = append(tfra.Entries, entry)
+		offset += cr.n
+	}
+
+	box := &MovieFragmentRandomAccessBox{}
+	mfro := &MovieFragmentRandomAccessOffsetBox{}
+	if err = box.Mp4BoxReplaceChildren([]mp4.Box{tfra, mfro}); err != nil {
+		return
+	}
+	mfro.MfraSize = box.Mp4BoxUpdate()
+	mfra = box
+	return
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.r.Read(p)
+	cr.n += int64(n)
+	return
+}
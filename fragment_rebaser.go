@@ -0,0 +1,100 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"time"
+)
+
+// FragmentRebaser rewrites the decode times of the fragments of the tracks of
+// a recording so that it starts at 0, as live presentations time their
+// fragments from a distant origin, and repairs the discontinuities of the
+// timeline left by fragments missing on the server.
+//
+// The origin is the decode time of the first rebased fragment, converted to
+// the timescale of each track, so that the tracks of a muxed recording stay
+// in sync; the first fragments of the tracks should be rebased first, the
+// earliest one first. A track that starts before the origin starts at 0.
+type FragmentRebaser struct {
+	// the largest discontinuity closed by moving the following fragments of
+	// the track back, or the largest overlap removed by moving them forward,
+	// 0 to keep the timeline as it is. Larger discontinuities remain gaps in
+	// the decode times of the track.
+	MaxGap time.Duration
+
+	originTime      uint64
+	originTimescale uint64
+
+	tracks map[uint32]*rebasedTrack
+}
+
+// rebasedTrack is the state of a track of a FragmentRebaser.
+type rebasedTrack struct {
+	// the amount subtracted from the decode times of the track.
+	shift uint64
+
+	// the rebased decode time of the next fragment if the timeline is
+	// contiguous.
+	next uint64
+}
+
+// Rebase rewrites in place the tfdt box, adding one if the fragment has
+// none, and the TfxdBox and TfrfBox of the track fragments of f, a fragment
+// of the track p.
+func (rb *FragmentRebaser) Rebase(f *Fragment, p MoovProcessor) (err error) {
+	for i := range f.Tracks {
+		if err = rb.rebaseFragmentTrack(&f.Tracks[i], p); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (rb *FragmentRebaser) rebaseFragmentTrack(t *FragmentTrack, p MoovProcessor) (err error) {
+	decodeTime, ok := t.DecodeTime()
+	if !ok {
+		err = fmt.Errorf("track %d fragment has no decode time: %w", t.Header.TrackID, ErrInvalidParam)
+		return
+	}
+	if rb.tracks == nil {
+		rb.tracks = make(map[uint32]*rebasedTrack)
+		rb.originTime, rb.originTimescale = decodeTime, p.Timescale
+	}
+	track, ok := rb.tracks[t.Header.TrackID]
+	if !ok {
+		track = &rebasedTrack{shift: rescaleTime(rb.originTime, rb.originTimescale, p.Timescale)}
+		if track.shift > decodeTime {
+			track.shift = decodeTime
+		}
+		track.next = decodeTime - track.shift
+		rb.tracks[t.Header.TrackID] = track
+	}
+
+	if decodeTime < track.shift {
+		err = fmt.Errorf("track %d fragment at %d precedes the start of the track: %w", t.Header.TrackID, decodeTime, ErrInvalidTimeline)
+		return
+	}
+	maxGap := rescaleTime(uint64(rb.MaxGap), uint64(time.Second), p.Timescale)
+	rebased := decodeTime - track.shift
+	switch {
+	case rebased > track.next && rebased-track.next <= maxGap:
+		track.shift += rebased - track.next
+		rebased = track.next
+	case rebased < track.next && track.next-rebased <= maxGap && track.next-rebased <= track.shift:
+		track.shift -= track.next - rebased
+		rebased = track.next
+	}
+	track.next = rebased + t.Duration()
+
+	if _, err = t.setDecodeTime(rebased); err != nil {
+		return
+	}
+	if t.Tfxd != nil {
+		t.Tfxd.FragmentAbsoluteTime = rebased
+	}
+	if t.Tfrf != nil {
+		for i := range t.Tfrf.Fragments {
+			t.Tfrf.Fragments[i].FragmentAbsoluteTime -= decodeTime - rebased
+		}
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testRebasedFragment returns testFragment of the track with a TfxdBox of the
+// given time, lasting 2000.
+func testRebasedFragment(t *testing.T, trackID uint32, time uint64) Fragment {
+	t.Helper()
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), time)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Tracks[0].Header.TrackID = trackID
+	return f
+}
+
+func TestFragmentRebaser(t *testing.T) {
+	audio := MoovProcessor{TrackID: 1, Timescale: 1000}
+	video := MoovProcessor{TrackID: 2, Timescale: 10000}
+	tests := []struct {
+		name    string
+		p       MoovProcessor
+		time    uint64
+		want    uint64
+		wantErr error
+	}{
+		{name: "origin", p: audio, time: 100000, want: 0},
+		{name: "contiguous", p: audio, time: 102000, want: 2000},
+		{name: "gap closed", p: audio, time: 104500, want: 4000},
+		{name: "after the closed gap", p: audio, time: 106500, want: 6000},
+		{name: "gap kept", p: audio, time: 120000, want: 19500},
+		{name: "overlap removed", p: audio, time: 121800, want: 21500},
+		{name: "other timescale", p: video, time: 1000000, want: 0},
+		{name: "before the start of the track", p: audio, time: 99000, wantErr: ErrInvalidTimeline},
+	}
+	rb := &FragmentRebaser{MaxGap: time.Second}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := testRebasedFragment(t, tt.p.TrackID, tt.time)
+			err := rb.Rebase(&f, tt.p)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rebase() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			track := f.Tracks[0]
+			if decodeTime, _ := track.DecodeTime(); decodeTime != tt.want || track.Tfxd.FragmentAbsoluteTime != tt.want {
+				t.Errorf("decode time %d, tfxd time %d, want %d", decodeTime, track.Tfxd.FragmentAbsoluteTime, tt.want)
+			}
+		})
+	}
+}
+
+func TestFragmentRebaserLateTrack(t *testing.T) {
+	rb := &FragmentRebaser{}
+	f := testRebasedFragment(t, 1, 100000)
+	if err := rb.Rebase(&f, MoovProcessor{Timescale: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	// a track starting before the origin starts at 0, and its later fragments
+	// keep their distance
+	for _, tt := range []struct{ time, want uint64 }{{time: 999000, want: 0}, {time: 1001000, want: 2000}} {
+		f = testRebasedFragment(t, 2, tt.time)
+		f.Tracks[0].Tfrf = &TfrfBox{Fragments: []TfrfFragment{{FragmentAbsoluteTime: tt.time + 2000, FragmentDuration: 2000}}}
+		if err := rb.Rebase(&f, MoovProcessor{Timescale: 10000}); err != nil {
+			t.Fatal(err)
+		}
+		track := f.Tracks[0]
+		if decodeTime, _ := track.DecodeTime(); decodeTime != tt.want {
+			t.Errorf("fragment at %d rebased to %d, want %d", tt.time, decodeTime, tt.want)
+		}
+		if got := track.Tfrf.Fragments[0].FragmentAbsoluteTime; got != tt.want+2000 {
+			t.Errorf("tfrf fragment rebased to %d, want %d", got, tt.want+2000)
+		}
+	}
+}
+
+func TestFragmentRebaserNoDecodeTime(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = (&FragmentRebaser{}).Rebase(&f, MoovProcessor{Timescale: 1000}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Rebase() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
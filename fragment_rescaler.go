@@ -0,0 +1,116 @@
+package smoothstreaming
+
+import (
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+)
+
+// FragmentRescaler converts the times of fragments from the timescale of
+// their track, 10 MHz for most Smooth Streaming tracks, to another timescale,
+// to harmonize the tracks of a muxed file. The init segment of the rescaled
+// fragments must declare the new Timescale.
+//
+// Rather than rescaling each duration, which would accumulate the rounding
+// errors over the presentation, the decode time of every sample is rescaled
+// and the durations are the differences of the rescaled times. The rescaled
+// fragments thus stay contiguous and never drift by more than one tick from
+// the source timeline.
+type FragmentRescaler struct {
+	// the target timescale.
+	Timescale uint64
+}
+
+// Rescale rescales in place the decode time, the sample durations and the
+// composition time offsets of the track fragments of f, and the times of
+// their TfxdBox and TfrfBox, from the Timescale of p, which also provides the
+// trex defaults of the samples, to the timescale of rs.
+func (rs FragmentRescaler) Rescale(f *Fragment, p MoovProcessor) (err error) {
+	if rs.Timescale == 0 || p.Timescale == 0 {
+		err = fmt.Errorf("rescaling from timescale %d to %d: %w", p.Timescale, rs.Timescale, ErrInvalidParam)
+		return
+	}
+	for i := range f.Tracks {
+		if err = rs.rescaleFragmentTrack(&f.Tracks[i], p); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (rs FragmentRescaler) rescaleFragmentTrack(t *FragmentTrack, p MoovProcessor) (err error) {
+	samples, err := t.samples(p)
+	if err != nil {
+		return
+	}
+	from, to := p.Timescale, rs.Timescale
+	rescale := func(time uint64) uint64 {
+		return rescaleTime(time, from, to)
+	}
+
+	decodeTime, _ := t.DecodeTime()
+	time := decodeTime
+	for _, run := range t.Runs {
+		if run.Samples == nil {
+			run.Samples = make([]mp4.TrackRunSampleEntry, run.SampleCount)
+		}
+		flags := run.Mp4BoxFlags() | mp4.FLAG_TRUN_SAMPLE_DURATION
+		hasOffsets := flags&mp4.FLAG_TRUN_SAMPLE_COMPOSITION_TIME_OFFSET > 0
+		for j := range run.Samples {
+			sample := samples[0]
+			samples = samples[1:]
+			start, end := rescale(time), rescale(time+uint64(sample.duration))
+			if end-start > 0xffffffff {
+				err = fmt.Errorf("track %d sample duration %d exceeds 32 bits: %w", t.Header.TrackID, end-start, ErrInvalidParam)
+				return
+			}
+			run.Samples[j].SampleDuration = uint32(end - start)
+			if hasOffsets {
+				offset := rescaleOffset(time, sample.compositionTimeOffset, from, to)
+				run.Samples[j].SampleCompositionTimeOffset = offset
+				if offset < 0 {
+					run.Version = 1
+				}
+			}
+			time += uint64(sample.duration)
+		}
+		run.Mp4BoxSetFlags(flags)
+	}
+
+	if t.Header.Mp4BoxFlags()&mp4.FLAG_TFHD_DEFAULT_SAMPLE_DURATION > 0 {
+		t.Header.DefaultSampleDuration = uint32(rescale(uint64(t.Header.DefaultSampleDuration)))
+	}
+	for _, child := range t.Traf.Mp4BoxChildren() {
+		if tfdt, ok := child.(*TrackFragmentDecodeTimeBox); ok {
+			tfdt.BaseMediaDecodeTime = rescale(tfdt.BaseMediaDecodeTime)
+		}
+	}
+	if t.Tfxd != nil {
+		start := t.Tfxd.FragmentAbsoluteTime
+		t.Tfxd.FragmentAbsoluteTime = rescale(start)
+		t.Tfxd.FragmentDuration = rescale(start+t.Tfxd.FragmentDuration) - t.Tfxd.FragmentAbsoluteTime
+	}
+	if t.Tfrf != nil {
+		for i, fragment := range t.Tfrf.Fragments {
+			t.Tfrf.Fragments[i] = TfrfFragment{
+				FragmentAbsoluteTime: rescale(fragment.FragmentAbsoluteTime),
+				FragmentDuration:     rescale(fragment.FragmentAbsoluteTime+fragment.FragmentDuration) - rescale(fragment.FragmentAbsoluteTime),
+			}
+		}
+	}
+	return
+}
+
+// rescaleOffset rescales the composition time offset of a sample decoded at
+// time as the difference of its rescaled composition and decode times.
+func rescaleOffset(time uint64, offset int64, from, to uint64) int64 {
+	decodeTime := rescaleTime(time, from, to)
+	if offset >= 0 {
+		return int64(rescaleTime(time+uint64(offset), from, to) - decodeTime)
+	}
+	magnitude := uint64(-offset)
+	if magnitude > time {
+		return -int64(rescaleTime(magnitude, from, to))
+	}
+	return -int64(decodeTime - rescaleTime(time-magnitude, from, to))
+}
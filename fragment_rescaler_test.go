@@ -0,0 +1,114 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestFragmentRescaler(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), 10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	track := f.Tracks[0]
+	run := track.Runs[0]
+	run.Mp4BoxSetFlags(run.Mp4BoxFlags() | mp4.FLAG_TRUN_SAMPLE_COMPOSITION_TIME_OFFSET)
+	run.Samples[0].SampleCompositionTimeOffset = 500
+	run.Samples[1].SampleCompositionTimeOffset = -1500
+	tfrf := &TfrfBox{Fragments: []TfrfFragment{{FragmentAbsoluteTime: 12000, FragmentDuration: 2000}}}
+	f.Tracks[0].Tfrf = tfrf
+
+	if err = (FragmentRescaler{Timescale: 1000}).Rescale(&f, MoovProcessor{Timescale: 3000}); err != nil {
+		t.Fatal(err)
+	}
+	// the samples at 10000, 11000 and ending at 12000 are rescaled to 3333,
+	// 3666 and 4000
+	var durations []uint32
+	var offsets []int64
+	for _, sample := range run.Samples {
+		durations = append(durations, sample.SampleDuration)
+		offsets = append(offsets, sample.SampleCompositionTimeOffset)
+	}
+	if !reflect.DeepEqual(durations, []uint32{333, 334}) || !reflect.DeepEqual(offsets, []int64{167, -500}) || run.Version != 1 {
+		t.Errorf("durations %v, offsets %v, version %d, want [333 334], [167 -500], 1", durations, offsets, run.Version)
+	}
+	if track.Tfxd.FragmentAbsoluteTime != 3333 || track.Tfxd.FragmentDuration != 667 {
+		t.Errorf("tfxd at %d lasting %d, want 3333 lasting 667", track.Tfxd.FragmentAbsoluteTime, track.Tfxd.FragmentDuration)
+	}
+	if want := []TfrfFragment{{FragmentAbsoluteTime: 4000, FragmentDuration: 666}}; !reflect.DeepEqual(tfrf.Fragments, want) {
+		t.Errorf("tfrf fragments %+v, want %+v", tfrf.Fragments, want)
+	}
+}
+
+func TestFragmentRescalerDefaults(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	traf := f.Tracks[0].Traf
+	if err = traf.Mp4BoxReplaceChildren(append(traf.Mp4BoxChildren(), &TrackFragmentDecodeTimeBox{BaseMediaDecodeTime: 30000})); err != nil {
+		t.Fatal(err)
+	}
+	track := f.Tracks[0]
+	run := track.Runs[0]
+	run.Mp4BoxSetFlags(run.Mp4BoxFlags() &^ mp4.FLAG_TRUN_SAMPLE_DURATION)
+	run.Samples[0].SampleDuration, run.Samples[1].SampleDuration = 0, 0
+	track.Header.Mp4BoxSetFlags(track.Header.Mp4BoxFlags() | mp4.FLAG_TFHD_DEFAULT_SAMPLE_DURATION)
+	track.Header.DefaultSampleDuration = 3000
+
+	if err = (FragmentRescaler{Timescale: 1000}).Rescale(&f, MoovProcessor{Timescale: 3000}); err != nil {
+		t.Fatal(err)
+	}
+	if decodeTime, _ := track.DecodeTime(); decodeTime != 10000 {
+		t.Errorf("decode time %d, want 10000", decodeTime)
+	}
+	if track.Header.DefaultSampleDuration != 1000 {
+		t.Errorf("default sample duration %d, want 1000", track.Header.DefaultSampleDuration)
+	}
+	if run.Mp4BoxFlags()&mp4.FLAG_TRUN_SAMPLE_DURATION == 0 || len(run.Samples) != 2 || run.Samples[0].SampleDuration != 1000 || run.Samples[1].SampleDuration != 1000 {
+		t.Errorf("run flags %#x with samples %+v, want 2 samples lasting 1000", run.Mp4BoxFlags(), run.Samples)
+	}
+}
+
+func TestFragmentRescalerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		to   uint64
+		from uint64
+	}{
+		{name: "no target timescale", from: 10000000},
+		{name: "no source timescale", to: 90000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Fragment{}
+			if err := (FragmentRescaler{Timescale: tt.to}).Rescale(&f, MoovProcessor{Timescale: tt.from}); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("Rescale() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestRescaleOffset(t *testing.T) {
+	tests := []struct {
+		name   string
+		time   uint64
+		offset int64
+		want   int64
+	}{
+		{name: "positive", time: 10000, offset: 500, want: 167},
+		{name: "negative", time: 11000, offset: -1500, want: -500},
+		{name: "before time 0", time: 100, offset: -200, want: -66},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rescaleOffset(tt.time, tt.offset, 3000, 1000); got != tt.want {
+				t.Errorf("rescaleOffset() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
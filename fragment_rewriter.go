@@ -0,0 +1,112 @@
+package smoothstreaming
+
+import (
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+)
+
+// FragmentRewriter turns Smooth Streaming fragments into fragments that
+// concatenate cleanly after the init segment of a MoovProcessor, as required
+// by CMAF and ISO BMFF players: the track ID of the fragment is set to the one
+// of the init segment, and the decode time of the fragment is declared by a
+// tfdt box. Writing the fragment with Fragment.WriteTo then recalculates the
+// data offsets of its track runs.
+//
+// Fragments stored as individual segment files, as in DASH and CMAF, can be
+// given a styp box declaring the brands of the segment, which some origins and
+// validators require.
+type FragmentRewriter struct {
+	// the track ID of the init segment, 0 to keep the track ID of the
+	// fragment.
+	TrackID uint32
+
+	// the brands of the styp box prepended to the fragment, e.g. msdh or
+	// cmfs, no styp box if SegmentMajorBrand is not set. The compatible
+	// brands default to the major brand.
+	SegmentMajorBrand       mp4.FourCC
+	SegmentCompatibleBrands []mp4.FourCC
+
+	// the version of the tfdt boxes of the rewritten fragments, 0 for 32-bit
+	// decode times, which some hardware players require, or 1 for 64-bit
+	// ones, nil to use version 1 only for decode times exceeding 32 bits. A
+	// decode time exceeding 32 bits cannot be written in version 0, see
+	// FragmentRebaser.
+	TfdtVersion *uint8
+}
+
+// Rewrite rewrites the track fragments of f in place. The decode time of the
+// fragment is taken from its TfxdBox, or is fragmentTime, the time of the
+// fragment in the manifest, if it has none. Both are in the timescale of the
+// track.
+func (rw FragmentRewriter) Rewrite(f *Fragment, fragmentTime uint64) (err error) {
+	for i := range f.Tracks {
+		t := &f.Tracks[i]
+		if rw.TrackID != 0 {
+			t.Header.TrackID = rw.TrackID
+		}
+		decodeTime := fragmentTime
+		if t.Tfxd != nil {
+			decodeTime = t.Tfxd.FragmentAbsoluteTime
+		}
+		var tfdt *TrackFragmentDecodeTimeBox
+		if tfdt, err = t.setDecodeTime(decodeTime); err != nil {
+			return
+		}
+		if rw.TfdtVersion != nil {
+			switch {
+			case *rw.TfdtVersion > 1:
+				err = fmt.Errorf("tfdt version %d: %w", *rw.TfdtVersion, ErrInvalidParam)
+				return
+			case *rw.TfdtVersion == 0 && decodeTime > 0xffffffff:
+				err = fmt.Errorf("track %d decode time %d exceeds the 32 bits of a version 0 tfdt: %w", t.Header.TrackID, decodeTime, ErrInvalidParam)
+				return
+			}
+			tfdt.Version = *rw.TfdtVersion
+		}
+	}
+	if rw.SegmentMajorBrand != (mp4.FourCC{}) {
+		f.SegmentType = rw.createStypMp4Box()
+	}
+	return
+}
+
+// createStypMp4Box returns the styp box of the rewritten fragments.
+func (rw FragmentRewriter) createStypMp4Box() (styp *SegmentTypeBox) {
+	compatibleBrands := rw.SegmentCompatibleBrands
+	if len(compatibleBrands) == 0 {
+		compatibleBrands = []mp4.FourCC{rw.SegmentMajorBrand}
+	}
+	styp = &SegmentTypeBox{}
+	styp.MajorBrand = rw.SegmentMajorBrand
+	styp.CompatibleBrands = compatibleBrands
+	styp.Mp4BoxUpdate()
+	return
+}
+
+// setDecodeTime sets the time of the tfdt box of the track fragment, adding
+// the box after the tfhd box if there is none.
+func (t FragmentTrack) setDecodeTime(decodeTime uint64) (tfdt *TrackFragmentDecodeTimeBox, err error) {
+	children := t.Traf.Mp4BoxChildren()
+	for _, child := range children {
+		var ok bool
+		if tfdt, ok = child.(*TrackFragmentDecodeTimeBox); ok {
+			tfdt.BaseMediaDecodeTime = decodeTime
+			return
+		}
+	}
+	tfdt = &TrackFragmentDecodeTimeBox{BaseMediaDecodeTime: decodeTime}
+	updated := make([]mp4.Box, 0, len(children)+1)
+	for _, child := range children {
+		updated = append(updated, child)
+		if child == mp4.Box(t.Header) {
+			updated = append(updated, tfdt)
+		}
+	}
+	if len(updated) == len(children) {
+		err = fmt.Errorf("tfhd box not in the traf box: %w", ErrInvalidParam)
+		return
+	}
+	err = t.Traf.Mp4BoxReplaceChildren(updated)
+	return
+}
@@ -0,0 +1,187 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+// withTfxd returns the fragment with a TfxdBox of the given time in its track
+// fragment.
+func withTfxd(t *testing.T, data []byte, time uint64) []byte {
+	t.Helper()
+	f, err := ParseFragment(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	traf := f.Tracks[0].Traf
+	children := append(traf.Mp4BoxChildren(), &TfxdBox{FragmentAbsoluteTime: time, FragmentDuration: 2000})
+	if err = traf.Mp4BoxReplaceChildren(children); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFragmentRewriter(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		rewriter     FragmentRewriter
+		fragmentTime uint64
+		wantTrackID  uint32
+		wantTime     uint64
+	}{
+		{name: "manifest time", data: testFragment(t, nil), rewriter: FragmentRewriter{TrackID: 2}, fragmentTime: 4000, wantTrackID: 2, wantTime: 4000},
+		{name: "tfxd time", data: withTfxd(t, testFragment(t, nil), 6000), rewriter: FragmentRewriter{TrackID: 3}, fragmentTime: 4000, wantTrackID: 3, wantTime: 6000},
+		{name: "64-bit time", data: testFragment(t, testSampleEncryptionBox(8, true)), fragmentTime: 160000000000, wantTrackID: 1, wantTime: 160000000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = tt.rewriter.Rewrite(&f, tt.fragmentTime); err != nil {
+				t.Fatal(err)
+			}
+			// rewriting twice updates the tfdt box rather than adding another
+			if err = tt.rewriter.Rewrite(&f, tt.fragmentTime); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err = f.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseFragment(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			track := got.Tracks[0]
+			if track.Header.TrackID != tt.wantTrackID {
+				t.Errorf("track ID %d, want %d", track.Header.TrackID, tt.wantTrackID)
+			}
+			children := track.Traf.Mp4BoxChildren()
+			var tfdts []*TrackFragmentDecodeTimeBox
+			for _, child := range children {
+				if tfdt, ok := child.(*TrackFragmentDecodeTimeBox); ok {
+					tfdts = append(tfdts, tfdt)
+				}
+			}
+			if len(tfdts) != 1 || children[1] != mp4.Box(tfdts[0]) {
+				t.Fatalf("got %d tfdt boxes, want one after the tfhd box", len(tfdts))
+			}
+			if tfdts[0].BaseMediaDecodeTime != tt.wantTime {
+				t.Errorf("decode time %d, want %d", tfdts[0].BaseMediaDecodeTime, tt.wantTime)
+			}
+			if !bytes.Equal(got.Data, f.Data) {
+				t.Errorf("Data = %x, want %x", got.Data, f.Data)
+			}
+		})
+	}
+}
+
+func TestFragmentRewriterSegmentType(t *testing.T) {
+	msdh := mp4.FourCC{'m', 's', 'd', 'h'}
+	tests := []struct {
+		name     string
+		rewriter FragmentRewriter
+		want     []mp4.FourCC
+	}{
+		{name: "no styp box", rewriter: FragmentRewriter{}},
+		{name: "default compatible brands", rewriter: FragmentRewriter{SegmentMajorBrand: msdh}, want: []mp4.FourCC{msdh}},
+		{
+			name:     "compatible brands",
+			rewriter: FragmentRewriter{SegmentMajorBrand: msdh, SegmentCompatibleBrands: []mp4.FourCC{msdh, {'m', 's', 'i', 'x'}}},
+			want:     []mp4.FourCC{msdh, {'m', 's', 'i', 'x'}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = tt.rewriter.Rewrite(&f, 4000); err != nil {
+				t.Fatal(err)
+			}
+			size, err := f.Size()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err = f.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if size != uint64(buf.Len()) {
+				t.Errorf("Size() = %d, want the %d bytes written", size, buf.Len())
+			}
+			if f, err = ParseFragment(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if f.SegmentType != nil {
+					t.Errorf("styp box %+v, want none", f.SegmentType)
+				}
+				return
+			}
+			if f.SegmentType == nil || f.SegmentType.MajorBrand != msdh || !reflect.DeepEqual(f.SegmentType.CompatibleBrands, tt.want) {
+				t.Errorf("styp box %+v, want msdh compatible with %v", f.SegmentType, tt.want)
+			}
+			if len(f.Data) != 16 || f.Tracks[0].SampleCount() != 2 {
+				t.Errorf("fragment of %d bytes with %d samples after the styp box, want 16 bytes with 2", len(f.Data), f.Tracks[0].SampleCount())
+			}
+		})
+	}
+}
+
+func TestFragmentRewriterTfdtVersion(t *testing.T) {
+	version := func(v uint8) *uint8 { return &v }
+	tests := []struct {
+		name         string
+		version      *uint8
+		fragmentTime uint64
+		wantVersion  uint8
+		wantErr      error
+	}{
+		{name: "default 32-bit time", fragmentTime: 4000, wantVersion: 0},
+		{name: "default 64-bit time", fragmentTime: 160000000000, wantVersion: 1},
+		{name: "version 0", version: version(0), fragmentTime: 4000, wantVersion: 0},
+		{name: "version 1", version: version(1), fragmentTime: 4000, wantVersion: 1},
+		{name: "version 0 with a 64-bit time", version: version(0), fragmentTime: 160000000000, wantErr: ErrInvalidParam},
+		{name: "version 2", version: version(2), fragmentTime: 4000, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = FragmentRewriter{TfdtVersion: tt.version}.Rewrite(&f, tt.fragmentTime)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rewrite() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var buf bytes.Buffer
+			if _, err = f.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseFragment(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tfdt, ok := got.Tracks[0].Traf.Mp4BoxChildren()[1].(*TrackFragmentDecodeTimeBox)
+			if !ok || tfdt.Version != tt.wantVersion || tfdt.BaseMediaDecodeTime != tt.fragmentTime {
+				t.Errorf("tfdt box %+v, want version %d with time %d", tfdt, tt.wantVersion, tt.fragmentTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package smoothstreaming
+
+// FragmentSequencer assigns the sequence numbers of the movie fragment
+// headers of output fragments, which ISO BMFF requires to increase in the
+// order of the fragments of a file. Smooth Streaming servers number fragments
+// from 1 for each request, or restart when a presentation is stitched from
+// several sources, so their numbers collide once the fragments are
+// concatenated.
+type FragmentSequencer struct {
+	// PerTrack numbers the fragments of each track separately, by the track
+	// ID of their first track fragment, as for tracks written to separate
+	// files. Otherwise the fragments of all tracks share a single sequence,
+	// as in a muxed file.
+	PerTrack bool
+
+	// the sequence number of the first fragment, 1 if 0.
+	First uint32
+
+	next   uint32
+	tracks map[uint32]uint32
+}
+
+// Assign sets the sequence number of f to the next number of its sequence,
+// and returns it.
+func (fs *FragmentSequencer) Assign(f *Fragment) (sequenceNumber uint32) {
+	first := fs.First
+	if first == 0 {
+		first = 1
+	}
+	if fs.PerTrack && len(f.Tracks) > 0 {
+		if fs.tracks == nil {
+			fs.tracks = make(map[uint32]uint32)
+		}
+		trackID := f.Tracks[0].Header.TrackID
+		if next, ok := fs.tracks[trackID]; ok {
+			sequenceNumber = next
+		} else {
+			sequenceNumber = first
+		}
+		fs.tracks[trackID] = sequenceNumber + 1
+	} else {
+		if fs.next == 0 {
+			fs.next = first
+		}
+		sequenceNumber = fs.next
+		fs.next++
+	}
+	f.Header.SequenceNumber = sequenceNumber
+	return
+}
@@ -0,0 +1,39 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestFragmentSequencer(t *testing.T) {
+	trackIDs := []uint32{1, 2, 1, 2, 2}
+	tests := []struct {
+		name      string
+		sequencer FragmentSequencer
+		want      []uint32
+	}{
+		{name: "single sequence", want: []uint32{1, 2, 3, 4, 5}},
+		{name: "first", sequencer: FragmentSequencer{First: 10}, want: []uint32{10, 11, 12, 13, 14}},
+		{name: "per track", sequencer: FragmentSequencer{PerTrack: true}, want: []uint32{1, 1, 2, 2, 3}},
+		{name: "per track from first", sequencer: FragmentSequencer{PerTrack: true, First: 5}, want: []uint32{5, 5, 6, 6, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := tt.sequencer
+			var got, written []uint32
+			for _, trackID := range trackIDs {
+				f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.Tracks[0].Header.TrackID = trackID
+				got = append(got, fs.Assign(&f))
+				written = append(written, f.Header.SequenceNumber)
+			}
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(written, tt.want) {
+				t.Errorf("Assign() = %v, mfhd sequence numbers %v, want %v", got, written, tt.want)
+			}
+		})
+	}
+}
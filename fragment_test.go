@@ -0,0 +1,413 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+// testFragment returns a fragment of track 1 numbered 7 with two samples of
+// 10 and 6 bytes lasting 1000 each, and the sample encryption box senc if it
+// is not nil.
+func testFragment(t *testing.T, senc *SampleEncryptionBox) []byte {
+	t.Helper()
+	tfhd := &mp4.TrackFragmentHeaderBox{TrackID: 1}
+	tfhd.Mp4BoxSetFlags(mp4.FLAG_TFHD_DEFAULT_SAMPLE_FLAGS | mp4.FLAG_TFHD_DEFAULT_BASE_IS_MOOF)
+	trun := &mp4.TrackRunBox{SampleCount: 2, Samples: []mp4.TrackRunSampleEntry{{SampleDuration: 1000, SampleSize: 10}, {SampleDuration: 1000, SampleSize: 6}}}
+	trun.Mp4BoxSetFlags(mp4.FLAG_TRUN_DATA_OFFSET | mp4.FLAG_TRUN_SAMPLE_DURATION | mp4.FLAG_TRUN_SAMPLE_SIZE)
+	traf := &mp4.TrackFragmentBox{}
+	children := []mp4.Box{tfhd, trun}
+	if senc != nil {
+		children = append(children, senc)
+	}
+	if err := traf.Mp4BoxReplaceChildren(children); err != nil {
+		t.Fatal(err)
+	}
+	moof := &mp4.MovieFragmentBox{}
+	if err := moof.Mp4BoxReplaceChildren([]mp4.Box{&mp4.MovieFragmentHeaderBox{SequenceNumber: 7}, traf}); err != nil {
+		t.Fatal(err)
+	}
+	trun.DataOffset = int32(moof.Mp4BoxUpdate() + 8)
+	var buf bytes.Buffer
+	if err := moof.Mp4BoxWrite(&buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write([]byte{0, 0, 0, 24, 'm', 'd', 'a', 't'})
+	buf.Write(bytes.Repeat([]byte{0xaa}, 16))
+	return buf.Bytes()
+}
+
+// testSampleEncryptionBox returns the sample encryption data of the samples
+// of testFragment with IVs of ivSize bytes, as a PIFF uuid box if piff.
+func testSampleEncryptionBox(ivSize uint8, piff bool) *SampleEncryptionBox {
+	senc := &SampleEncryptionBox{IVSize: ivSize, Samples: []mp4.SampleEncryptionSampleEntry{
+		{InitializationVector: make([]byte, ivSize), Subsamples: []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 2, BytesOfProtectedData: 8}}},
+		{InitializationVector: bytes.Repeat([]byte{1}, int(ivSize)), Subsamples: []mp4.SampleEncryptionSubsampleEntry{{BytesOfClearData: 6}}},
+	}}
+	senc.Type = mp4.SencBoxType
+	if piff {
+		senc.Type = mp4.UuidBoxType
+	}
+	senc.Mp4BoxSetFlags(mp4.FLAG_SENC_USE_SUBSAMPLE_ENCRYPTION)
+	return senc
+}
+
+func TestParseFragment(t *testing.T) {
+	tests := []struct {
+		name string
+		senc *SampleEncryptionBox
+	}{
+		{name: "clear"},
+		{name: "PIFF 8-byte IVs", senc: testSampleEncryptionBox(8, true)},
+		{name: "PIFF 16-byte IVs", senc: testSampleEncryptionBox(16, true)},
+		{name: "senc 8-byte IVs", senc: testSampleEncryptionBox(8, false)},
+		{name: "senc 16-byte IVs", senc: testSampleEncryptionBox(16, false)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testFragment(t, tt.senc)
+			f, err := ParseFragment(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.SequenceNumber(); got != 7 {
+				t.Errorf("SequenceNumber() = %d, want 7", got)
+			}
+			if len(f.Tracks) != 1 {
+				t.Fatalf("got %d tracks, want 1", len(f.Tracks))
+			}
+			track := f.Tracks[0]
+			if track.Header.TrackID != 1 || track.SampleCount() != 2 || track.Duration() != 2000 {
+				t.Errorf("track %d with %d samples of %d, want track 1 with 2 samples of 2000", track.Header.TrackID, track.SampleCount(), track.Duration())
+			}
+			if !bytes.Equal(f.Data, bytes.Repeat([]byte{0xaa}, 16)) {
+				t.Errorf("Data = %x", f.Data)
+			}
+			if tt.senc == nil {
+				if track.SampleEncryption != nil {
+					t.Errorf("SampleEncryption = %+v, want nil", track.SampleEncryption)
+				}
+			} else {
+				senc := track.SampleEncryption
+				if senc == nil {
+					t.Fatal("SampleEncryption is nil")
+				}
+				if senc.IVSize != tt.senc.IVSize || senc.IsPIFF() != tt.senc.IsPIFF() || !reflect.DeepEqual(senc.Samples, tt.senc.Samples) {
+					t.Errorf("SampleEncryption = %+v, want %+v", senc, tt.senc)
+				}
+			}
+
+			var buf bytes.Buffer
+			if _, err = f.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Errorf("WriteTo() = %x, want %x", buf.Bytes(), data)
+			}
+		})
+	}
+}
+
+func TestParseFragmentErrors(t *testing.T) {
+	fragment := testFragment(t, nil)
+	moofSize := len(fragment) - 24
+	box := func(boxType string, payload ...[]byte) []byte {
+		data := bytes.Join(payload, nil)
+		size := 8 + len(data)
+		return append([]byte{0, 0, byte(size >> 8), byte(size), boxType[0], boxType[1], boxType[2], boxType[3]}, data...)
+	}
+	mfhd := box("mfhd", make([]byte, 8))
+	tfhd := box("tfhd", []byte{0, 0, 0, 0, 0, 0, 0, 1})
+	mdat := box("mdat")
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "not a moof", data: mdat, wantErr: ErrInvalidParam},
+		{name: "no mdat", data: fragment[:moofSize], wantErr: ErrInvalidParam},
+		{name: "moof followed by free", data: append(fragment[:moofSize:moofSize], box("free")...), wantErr: ErrInvalidParam},
+		{name: "no mfhd", data: append(box("moof", box("traf", tfhd)), mdat...), wantErr: ErrInvalidParam},
+		{name: "no traf", data: append(box("moof", mfhd), mdat...), wantErr: ErrInvalidParam},
+		{name: "traf without tfhd", data: append(box("moof", mfhd, box("traf")), mdat...), wantErr: ErrInvalidParam},
+		{name: "truncated mdat", data: fragment[:len(fragment)-1], wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFragment(bytes.NewReader(tt.data)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseFragment() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFragmentMdatSizes(t *testing.T) {
+	payload := bytes.Repeat([]byte{0xaa}, 16)
+	tests := []struct {
+		name           string
+		mdat           []byte
+		wantHeaderSize uint32
+	}{
+		{name: "to the end of the response", mdat: append([]byte{0, 0, 0, 0, 'm', 'd', 'a', 't'}, payload...), wantHeaderSize: 8},
+		{name: "largesize", mdat: append([]byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0, 0, 0, 0, 0, 0, 32}, payload...), wantHeaderSize: 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the data offset of the run follows the larger mdat header
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Tracks[0].Runs[0].DataOffset += int32(tt.wantHeaderSize) - 8
+			var moof bytes.Buffer
+			f.Moof.Mp4BoxUpdate()
+			if err = f.Moof.Mp4BoxWrite(&moof); err != nil {
+				t.Fatal(err)
+			}
+			if f, err = ParseFragment(io.MultiReader(&moof, bytes.NewReader(tt.mdat))); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(f.Data, payload) || f.mdatHeaderSize != tt.wantHeaderSize {
+				t.Errorf("Data = %x with a %d-byte header, want %x with a %d-byte header", f.Data, f.mdatHeaderSize, payload, tt.wantHeaderSize)
+			}
+			if got, want := f.dataOffset(), uint64(f.Moof.Mp4BoxSize())+uint64(tt.wantHeaderSize); got != want {
+				t.Errorf("dataOffset() = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestFragmentWriteToDataOffsets(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a larger moof box moves the samples further from it
+	if err = (FragmentRewriter{}).Rewrite(&f, 0x100000000); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo() = %d, wrote %d bytes", n, buf.Len())
+	}
+	run := f.Tracks[0].Runs[0]
+	if want := int32(f.Moof.Mp4BoxSize()) + 8; run.DataOffset != want {
+		t.Errorf("data offset %d, want %d", run.DataOffset, want)
+	}
+	if !bytes.Equal(buf.Bytes()[run.DataOffset:], f.Data) {
+		t.Errorf("samples at the data offset %x, want %x", buf.Bytes()[run.DataOffset:], f.Data)
+	}
+}
+
+func TestFragmentWriteToUnlocatedRuns(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Tracks[0].runOffsets = nil
+	if _, err = f.WriteTo(&bytes.Buffer{}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("WriteTo() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestParseFragmentRunOutsideMdat(t *testing.T) {
+	data := testFragment(t, nil)
+	// the mdat of 8 rather than 16 bytes of samples
+	data = append(data[:len(data)-24], 0, 0, 0, 16, 'm', 'd', 'a', 't', 0, 1, 2, 3, 4, 5, 6, 7)
+	if _, err := ParseFragment(bytes.NewReader(data)); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ParseFragment() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestParseFragmentLiveBoxes(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	traf := f.Tracks[0].Traf
+	tfxd := &TfxdBox{FragmentAbsoluteTime: 20000000, FragmentDuration: 20000000}
+	tfrf := &TfrfBox{Fragments: []TfrfFragment{{40000000, 20000000}}}
+	if err = traf.Mp4BoxReplaceChildren(append(traf.Mp4BoxChildren(), tfxd, tfrf)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = ParseFragment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	track := f.Tracks[0]
+	if track.Tfxd == nil || track.Tfxd.FragmentAbsoluteTime != tfxd.FragmentAbsoluteTime || track.Tfxd.FragmentDuration != tfxd.FragmentDuration {
+		t.Errorf("Tfxd = %+v, want %+v", track.Tfxd, tfxd)
+	}
+	if track.Tfrf == nil || !reflect.DeepEqual(track.Tfrf.Fragments, tfrf.Fragments) {
+		t.Errorf("Tfrf = %+v, want %+v", track.Tfrf, tfrf)
+	}
+}
+
+func TestFragmentTrackSamples(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	track := f.Tracks[0]
+	run := track.Runs[0]
+	run.Mp4BoxSetFlags(run.Mp4BoxFlags()&^mp4.FLAG_TRUN_SAMPLE_DURATION | mp4.FLAG_TRUN_FIRST_SAMPLE_FLAGS | mp4.FLAG_TRUN_SAMPLE_COMPOSITION_TIME_OFFSET)
+	run.FirstSampleFlags = DefaultSyncSampleFlags
+	run.Samples[1].SampleCompositionTimeOffset = 500
+	track.Header.DefaultSampleFlags = DefaultVideoSampleFlags
+	samples, err := track.samples(MoovProcessor{StreamType: VideoStream, DefaultSampleDuration: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fragmentSample{
+		{sampleRange: sampleRange{offset: 0, size: 10}, duration: 400, flags: DefaultSyncSampleFlags},
+		{sampleRange: sampleRange{offset: 10, size: 6}, duration: 400, flags: DefaultVideoSampleFlags, compositionTimeOffset: 500},
+	}
+	if !reflect.DeepEqual(samples, want) {
+		t.Errorf("samples() = %+v, want %+v", samples, want)
+	}
+}
+
+func TestFragmentSize(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, testSampleEncryptionBox(8, false))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := f.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if size != uint64(buf.Len()) {
+		t.Errorf("Size() = %d, want the %d bytes written", size, buf.Len())
+	}
+	if _, err = (Fragment{Tracks: []FragmentTrack{{Header: &mp4.TrackFragmentHeaderBox{}, Runs: []*mp4.TrackRunBox{{}}}}}).Size(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Size() of unlocated runs error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestFragmentTrackDecodeTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		children []mp4.Box
+		want     uint64
+		wantOK   bool
+	}{
+		{name: "none"},
+		{name: "tfxd", children: []mp4.Box{&TfxdBox{FragmentAbsoluteTime: 5000}}, want: 5000, wantOK: true},
+		{name: "tfdt before tfxd", children: []mp4.Box{&TfxdBox{FragmentAbsoluteTime: 5000}, &TrackFragmentDecodeTimeBox{BaseMediaDecodeTime: 7000}}, want: 7000, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			traf := f.Tracks[0].Traf
+			if err = traf.Mp4BoxReplaceChildren(append(traf.Mp4BoxChildren(), tt.children...)); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err = f.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if f, err = ParseFragment(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := f.Tracks[0].DecodeTime(); got != tt.want || ok != tt.wantOK {
+				t.Errorf("DecodeTime() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseFragmentStrayBoxes(t *testing.T) {
+	free := []byte{0, 0, 0, 10, 'f', 'r', 'e', 'e', 0, 0}
+	skip := []byte{0, 0, 0, 12, 's', 'k', 'i', 'p', 0, 0, 0, 0}
+	// beforeMdat returns testFragment with the box inserted between the moof
+	// and mdat boxes, the data offset of the run accounting for it.
+	beforeMdat := func(box []byte) []byte {
+		f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Tracks[0].Runs[0].DataOffset += int32(len(box))
+		var buf bytes.Buffer
+		f.Moof.Mp4BoxUpdate()
+		if err = f.Moof.Mp4BoxWrite(&buf); err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(box)
+		buf.Write([]byte{0, 0, 0, 24, 'm', 'd', 'a', 't'})
+		buf.Write(f.Data)
+		return buf.Bytes()
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "before the moof box", data: append(append([]byte{}, free...), testFragment(t, nil)...)},
+		{name: "between the moof and mdat boxes", data: beforeMdat(skip)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(f.Data, bytes.Repeat([]byte{0xaa}, 16)) {
+				t.Errorf("Data = %x, want 16 bytes of aa", f.Data)
+			}
+			samples, err := f.Tracks[0].samples(MoovProcessor{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(samples) != 2 || samples[0].sampleRange != (sampleRange{offset: 0, size: 10}) || samples[1].sampleRange != (sampleRange{offset: 10, size: 6}) {
+				t.Errorf("samples() = %+v, want samples of 10 and 6 bytes at the start of the data", samples)
+			}
+		})
+	}
+}
+
+func TestSkipMp4Box(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		wantSize int64
+		wantErr  error
+	}{
+		{name: "compact size", data: []byte{0, 0, 0, 10, 'f', 'r', 'e', 'e', 0, 0, 0xff}, wantSize: 10},
+		{name: "largesize", data: []byte{0, 0, 0, 1, 'f', 'r', 'e', 'e', 0, 0, 0, 0, 0, 0, 0, 18, 0, 0, 0xff}, wantSize: 18},
+		{name: "to the end of the stream", data: []byte{0, 0, 0, 0, 'f', 'r', 'e', 'e'}, wantErr: ErrInvalidParam},
+		{name: "shorter than its header", data: []byte{0, 0, 0, 1, 'f', 'r', 'e', 'e', 0, 0, 0, 0, 0, 0, 0, 8}, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(tt.data)
+			header, err := mp4.ReadHeader(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size, err := skipMp4Box(r, header)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("skipMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (size != tt.wantSize || r.Len() != 1) {
+				t.Errorf("skipMp4Box() = %d leaving %d bytes, want %d leaving 1", size, r.Len(), tt.wantSize)
+			}
+		})
+	}
+}
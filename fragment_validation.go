@@ -0,0 +1,149 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/go-webdl/mp4"
+)
+
+// FragmentValidation is the comparison of a downloaded fragment with its entry
+// in the timeline of the manifest, catching fragments corrupted by a CDN or
+// served for the wrong time before they are muxed. All times are in the
+// timescale of the stream, which is the timescale of its tracks.
+type FragmentValidation struct {
+	// the entry of the fragment in the timeline of the stream.
+	Expected TimelineFragment
+
+	// the time of the fragment from its TfxdBox, or from its tfdt box if it
+	// has none, or the expected time if it has neither.
+	Time uint64
+
+	// the sum of the sample durations of the track runs, or the duration of
+	// the TfxdBox if the runs do not declare the durations of their samples.
+	Duration uint64
+
+	// the number of samples of the track runs.
+	SampleCount uint32
+
+	// the size of the payload of the mdat box, and the end of the samples of
+	// the track runs within it, 0 if the runs take the sizes of their samples
+	// from the trex box, unknown to the fragment.
+	DataSize, SampleDataEnd int64
+}
+
+// ValidateFragment compares the first track fragment of f, the only one of a
+// Smooth Streaming fragment, with its entry in the timeline of the stream.
+func ValidateFragment(f Fragment, expected TimelineFragment) (v FragmentValidation) {
+	v.Expected = expected
+	v.Time = expected.Time
+	if len(f.Tracks) == 0 {
+		return
+	}
+	t := f.Tracks[0]
+	v.SampleCount = t.SampleCount()
+	v.Duration = t.Duration()
+	if t.Tfxd != nil {
+		v.Time = t.Tfxd.FragmentAbsoluteTime
+		if v.Duration == 0 {
+			v.Duration = t.Tfxd.FragmentDuration
+		}
+	} else if decodeTime, ok := t.DecodeTime(); ok {
+		v.Time = decodeTime
+	}
+	v.DataSize = int64(len(f.Data))
+	v.SampleDataEnd = sampleDataEnd(f)
+	return
+}
+
+// sampleDataEnd returns the end of the samples of the track runs of f within
+// its Data, 0 if a run takes the sizes of its samples from the trex box.
+func sampleDataEnd(f Fragment) (end int64) {
+	for _, t := range f.Tracks {
+		for j, offset := range t.runOffsets {
+			run := t.Runs[j]
+			if run.Mp4BoxFlags()&mp4.FLAG_TRUN_SAMPLE_SIZE == 0 && t.Header.Mp4BoxFlags()&mp4.FLAG_TFHD_DEFAULT_SAMPLE_SIZE == 0 {
+				return 0
+			}
+			if runEnd := offset + t.runSize(run); runEnd > end {
+				end = runEnd
+			}
+		}
+	}
+	return
+}
+
+// End returns the time right after the last sample of the fragment.
+func (v FragmentValidation) End() uint64 {
+	return v.Time + v.Duration
+}
+
+// Drift returns how far the fragment starts after its expected time, negative
+// if it starts before.
+func (v FragmentValidation) Drift() int64 {
+	return int64(v.Time - v.Expected.Time)
+}
+
+// Overlap returns how far the fragment extends into the following fragment of
+// the timeline, 0 if it ends by its expected end or its expected duration is
+// not known.
+func (v FragmentValidation) Overlap() uint64 {
+	if v.Expected.Duration == 0 || v.End() <= v.Expected.End() {
+		return 0
+	}
+	return v.End() - v.Expected.End()
+}
+
+// Truncation returns how far the fragment ends before its expected end,
+// leaving a gap before the following fragment of the timeline, 0 if it does
+// not or its expected duration is not known.
+func (v FragmentValidation) Truncation() uint64 {
+	if v.Expected.Duration == 0 || v.End() >= v.Expected.End() {
+		return 0
+	}
+	return v.Expected.End() - v.End()
+}
+
+// Err returns an error wrapping ErrFragmentMismatch if the fragment has no
+// samples, if its mdat box holds data past its samples, the sizes of its
+// boxes being inconsistent, or if its drift, overlap or truncation exceeds
+// the tolerance. A tolerance of a few ticks absorbs the rounding of sample
+// durations by encoders.
+func (v FragmentValidation) Err(tolerance uint64) (err error) {
+	drift := v.Drift()
+	if drift < 0 {
+		drift = -drift
+	}
+	switch {
+	case v.SampleCount == 0:
+		err = fmt.Errorf("fragment %d at %d has no samples: %w", v.Expected.Number, v.Expected.Time, ErrFragmentMismatch)
+	case v.SampleDataEnd > 0 && v.SampleDataEnd < v.DataSize:
+		err = fmt.Errorf("fragment %d at %d has %d bytes of mdat past its samples: %w", v.Expected.Number, v.Expected.Time, v.DataSize-v.SampleDataEnd, ErrFragmentMismatch)
+	case uint64(drift) > tolerance:
+		err = fmt.Errorf("fragment %d at %d starts at %d: %w", v.Expected.Number, v.Expected.Time, v.Time, ErrFragmentMismatch)
+	case v.Overlap() > tolerance:
+		err = fmt.Errorf("fragment %d at %d overlaps the following fragment by %d: %w", v.Expected.Number, v.Expected.Time, v.Overlap(), ErrFragmentMismatch)
+	case v.Truncation() > tolerance:
+		err = fmt.Errorf("fragment %d at %d ends %d before the following fragment: %w", v.Expected.Number, v.Expected.Time, v.Truncation(), ErrFragmentMismatch)
+	}
+	return
+}
+
+// FragmentVerification verifies the fragments downloaded by a Downloader
+// against their requests, see ValidateFragment, since some CDNs serve stale
+// or wrong fragments under load. A fragment failing the verification, or
+// failing to parse, is requested again as decided by the RetryPolicy of the
+// Downloader, then fails the download, unless Flag is set.
+type FragmentVerification struct {
+	// whether the fragments are verified.
+	Enabled bool
+
+	// the tolerance of the verification, in the timescale of the stream, see
+	// FragmentValidation.Err.
+	Tolerance uint64
+
+	// called with the fragments that parse but still fail the verification
+	// after the retries, which are then written anyway, nil to fail the
+	// download on them.
+	Flag func(u *url.URL, err error)
+}
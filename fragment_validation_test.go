@@ -0,0 +1,203 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestValidateFragment(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), 10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		expected       TimelineFragment
+		tolerance      uint64
+		wantDrift      int64
+		wantOverlap    uint64
+		wantTruncation uint64
+		wantErr        error
+	}{
+		{name: "matching", expected: TimelineFragment{Number: 5, Time: 10000, Duration: 2000}},
+		{name: "unknown duration", expected: TimelineFragment{Number: 5, Time: 10000}},
+		{name: "late", expected: TimelineFragment{Number: 5, Time: 9000, Duration: 3000}, wantDrift: 1000, wantErr: ErrFragmentMismatch},
+		{name: "early", expected: TimelineFragment{Number: 5, Time: 10002, Duration: 1998}, tolerance: 2, wantDrift: -2},
+		{name: "overlapping", expected: TimelineFragment{Number: 5, Time: 10000, Duration: 1500}, wantOverlap: 500, wantErr: ErrFragmentMismatch},
+		{name: "truncated", expected: TimelineFragment{Number: 5, Time: 10000, Duration: 2500}, wantTruncation: 500, wantErr: ErrFragmentMismatch},
+		{name: "within tolerance", expected: TimelineFragment{Number: 5, Time: 10000, Duration: 2001}, tolerance: 1, wantTruncation: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := ValidateFragment(f, tt.expected)
+			if v.Time != 10000 || v.Duration != 2000 || v.SampleCount != 2 || v.End() != 12000 {
+				t.Errorf("time %d, duration %d, %d samples, end %d, want 10000, 2000, 2, 12000", v.Time, v.Duration, v.SampleCount, v.End())
+			}
+			if v.Drift() != tt.wantDrift || v.Overlap() != tt.wantOverlap || v.Truncation() != tt.wantTruncation {
+				t.Errorf("drift %d, overlap %d, truncation %d, want %d, %d, %d", v.Drift(), v.Overlap(), v.Truncation(), tt.wantDrift, tt.wantOverlap, tt.wantTruncation)
+			}
+			if err := v.Err(tt.tolerance); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Err() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFragmentWithoutSampleDurations(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), 10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := f.Tracks[0].Runs[0]
+	run.Mp4BoxSetFlags(run.Mp4BoxFlags() &^ mp4.FLAG_TRUN_SAMPLE_DURATION)
+	v := ValidateFragment(f, TimelineFragment{Time: 10000, Duration: 2000})
+	if v.Duration != 2000 {
+		t.Errorf("duration %d, want the tfxd duration 2000", v.Duration)
+	}
+
+	tfhd := f.Tracks[0].Header
+	tfhd.Mp4BoxSetFlags(tfhd.Mp4BoxFlags() | mp4.FLAG_TFHD_DEFAULT_SAMPLE_DURATION)
+	tfhd.DefaultSampleDuration = 900
+	if v = ValidateFragment(f, TimelineFragment{Time: 10000, Duration: 2000}); v.Duration != 1800 {
+		t.Errorf("duration %d, want the default sample durations 1800", v.Duration)
+	}
+}
+
+func TestValidateFragmentNoSamples(t *testing.T) {
+	v := ValidateFragment(Fragment{}, TimelineFragment{Time: 10000, Duration: 2000})
+	if v.Time != 10000 || v.SampleCount != 0 {
+		t.Errorf("time %d, %d samples, want the expected time 10000 and no samples", v.Time, v.SampleCount)
+	}
+	if err := v.Err(0); !errors.Is(err, ErrFragmentMismatch) {
+		t.Errorf("Err() = %v, want %v", err, ErrFragmentMismatch)
+	}
+}
+
+func TestValidateFragmentDecodeTime(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = (FragmentRewriter{}).Rewrite(&f, 6000); err != nil {
+		t.Fatal(err)
+	}
+	v := ValidateFragment(f, TimelineFragment{Time: 4000, Duration: 2000})
+	if v.Time != 6000 || v.Drift() != 2000 {
+		t.Errorf("time %d, drift %d, want the tfdt time 6000 and drift 2000", v.Time, v.Drift())
+	}
+}
+
+func TestValidateFragmentSampleData(t *testing.T) {
+	tests := []struct {
+		name        string
+		extra       int
+		noSizes     bool
+		wantDataEnd int64
+		wantErr     error
+	}{
+		{name: "samples filling the mdat box", wantDataEnd: 16},
+		{name: "data past the samples", extra: 4, wantDataEnd: 16, wantErr: ErrFragmentMismatch},
+		{name: "sample sizes from the trex box", extra: 4, noSizes: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), 10000)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Data = append(f.Data, make([]byte, tt.extra)...)
+			if tt.noSizes {
+				run := f.Tracks[0].Runs[0]
+				run.Mp4BoxSetFlags(run.Mp4BoxFlags() &^ mp4.FLAG_TRUN_SAMPLE_SIZE)
+			}
+			v := ValidateFragment(f, TimelineFragment{Time: 10000, Duration: 2000})
+			if v.DataSize != int64(16+tt.extra) || v.SampleDataEnd != tt.wantDataEnd {
+				t.Errorf("data size %d, sample data end %d, want %d, %d", v.DataSize, v.SampleDataEnd, 16+tt.extra, tt.wantDataEnd)
+			}
+			if err := v.Err(0); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Err() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDownloaderVerify(t *testing.T) {
+	const manifest = `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="4000" TimeScale="1000">` +
+		`<StreamIndex Type="video" Name="video" QualityLevels="1" Url="QualityLevels({bitrate})/Fragments(video={start time})">` +
+		`<QualityLevel Index="0" Bitrate="1000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+		`<c t="0" d="2000"/><c d="2000"/>` +
+		`</StreamIndex></SmoothStreamingMedia>`
+	tests := []struct {
+		name         string
+		stale        int
+		maxRetries   int
+		flag         bool
+		wantRequests int
+		wantFlagged  int
+		wantErr      error
+	}{
+		{name: "valid", wantRequests: 2},
+		{name: "stale fragment retried", stale: 1, maxRetries: 1, wantRequests: 3},
+		{name: "retries exhausted", stale: 2, maxRetries: 1, wantRequests: 3, wantErr: ErrFragmentMismatch},
+		{name: "flagged", stale: 2, maxRetries: 1, flag: true, wantRequests: 3, wantFlagged: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/Manifest") {
+					io.WriteString(w, manifest)
+					return
+				}
+				var fragmentTime uint64
+				if _, err := fmt.Sscanf(path.Base(r.URL.Path), "Fragments(video=%d)", &fragmentTime); err != nil {
+					t.Error(err)
+				}
+				// the first requests of the last fragment are served the
+				// previous one
+				if requests++; fragmentTime == 2000 && tt.stale > 0 {
+					tt.stale--
+					fragmentTime = 0
+				}
+				w.Write(withTfxd(t, testFragment(t, nil), fragmentTime))
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var flagged int
+			d := Downloader{
+				OutputDir: t.TempDir(),
+				Retry:     RetryPolicy{MaxRetries: tt.maxRetries, InitialDelay: time.Millisecond},
+				Verify:    FragmentVerification{Enabled: true},
+			}
+			if tt.flag {
+				d.Verify.Flag = func(u *url.URL, err error) {
+					if !errors.Is(err, ErrFragmentMismatch) {
+						t.Errorf("flagged %s with %v, want %v", u, err, ErrFragmentMismatch)
+					}
+					flagged++
+				}
+			}
+			if _, err = d.Download(context.Background(), u); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Download() error = %v, want %v", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests || flagged != tt.wantFlagged {
+				t.Errorf("sent %d fragment requests, flagged %d, want %d, %d", requests, flagged, tt.wantRequests, tt.wantFlagged)
+			}
+		})
+	}
+}
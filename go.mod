@@ -3,11 +3,10 @@ module github.com/go-webdl/smoothstreaming
 go 1.17
 
 require (
+	github.com/go-webdl/bits v0.0.0-20220528000000-0c191dce8c55
 	github.com/go-webdl/encodetype v0.0.0-20220528000000-fc69e406bb75
 	github.com/go-webdl/media-codec v0.0.0-20220528000000-28f35152e780
 	github.com/go-webdl/mp4 v0.0.0-20220528000000-6db12f959ab1
 	github.com/google/uuid v1.3.0
 	golang.org/x/text v0.3.7
 )
-
-require github.com/go-webdl/bits v0.0.0-20220528000000-0c191dce8c55 // indirect
@@ -0,0 +1,210 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-webdl/bits"
+	"github.com/go-webdl/media-codec/avc"
+)
+
+// AVCSPS carries the fields of an H.264 sequence parameter set, ISO/IEC
+// 14496-10 7.3.2.1.1, that describe the decoder configuration and the picture
+// size of the stream.
+type AVCSPS struct {
+	ProfileIdc           uint8
+	ConstraintFlags      uint8
+	LevelIdc             uint8
+	SPSID                uint
+	ChromaFormatIdc      uint8
+	SeparateColourPlane  bool
+	BitDepthLumaMinus8   uint8
+	BitDepthChromaMinus8 uint8
+	FrameMbsOnly         bool
+
+	// the picture size after cropping, in luma samples.
+	Width  uint32
+	Height uint32
+
+	// the sample aspect ratio of the VUI, 0:0 if unspecified.
+	SarWidth  uint16
+	SarHeight uint16
+
+	// the colour description of the VUI, nil if not present.
+	Colour *ColourDescription
+}
+
+// Profiles whose SPS carries the chroma format and bit depth, and whose
+// avcC carries them in its extension fields.
+func avcHighProfile(profileIdc uint8) bool {
+	switch profileIdc {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		return true
+	}
+	return false
+}
+
+// Sample aspect ratios of aspect_ratio_idc 1 to 16, ISO/IEC 14496-10 Table
+// E-1.
+var avcSampleAspectRatios = [...][2]uint16{
+	{1, 1}, {12, 11}, {10, 11}, {16, 11}, {40, 33}, {24, 11}, {20, 11}, {32, 11},
+	{80, 33}, {18, 11}, {15, 11}, {64, 33}, {160, 99}, {4, 3}, {3, 2}, {2, 1},
+}
+
+// ParseAVCSPS parses an SPS NAL unit, including its NAL unit header.
+func ParseAVCSPS(nalu []byte) (sps AVCSPS, err error) {
+	if len(nalu) < 4 || avc.GetNaluType(nalu[0]) != avc.NALU_SPS {
+		err = fmt.Errorf("not an H.264 SPS NAL unit: %w", ErrInvalidParam)
+		return
+	}
+	r := bits.NewAccErrEBSPReader(bytes.NewReader(nalu[1:]))
+	sps.ProfileIdc = uint8(r.Read(8))
+	sps.ConstraintFlags = uint8(r.Read(8))
+	sps.LevelIdc = uint8(r.Read(8))
+	sps.SPSID = r.ReadExpGolomb()
+	sps.ChromaFormatIdc = 1
+	if avcHighProfile(sps.ProfileIdc) {
+		sps.ChromaFormatIdc = uint8(r.ReadExpGolomb())
+		if sps.ChromaFormatIdc == 3 {
+			sps.SeparateColourPlane = r.ReadFlag()
+		}
+		sps.BitDepthLumaMinus8 = uint8(r.ReadExpGolomb())
+		sps.BitDepthChromaMinus8 = uint8(r.ReadExpGolomb())
+		r.ReadFlag()      // qpprime_y_zero_transform_bypass_flag
+		if r.ReadFlag() { // seq_scaling_matrix_present_flag
+			lists := 8
+			if sps.ChromaFormatIdc == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if !r.ReadFlag() { // seq_scaling_list_present_flag
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				skipAVCScalingList(r, size)
+			}
+		}
+	}
+	r.ReadExpGolomb()          // log2_max_frame_num_minus4
+	switch r.ReadExpGolomb() { // pic_order_cnt_type
+	case 0:
+		r.ReadExpGolomb() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.ReadFlag()               // delta_pic_order_always_zero_flag
+		r.ReadSignedGolomb()       // offset_for_non_ref_pic
+		r.ReadSignedGolomb()       // offset_for_top_to_bottom_field
+		cycle := r.ReadExpGolomb() // num_ref_frames_in_pic_order_cnt_cycle
+		for i := uint(0); i < cycle && r.AccError() == nil; i++ {
+			r.ReadSignedGolomb() // offset_for_ref_frame
+		}
+	}
+	r.ReadExpGolomb() // max_num_ref_frames
+	r.ReadFlag()      // gaps_in_frame_num_value_allowed_flag
+	widthInMbs := uint32(r.ReadExpGolomb()) + 1
+	heightInMapUnits := uint32(r.ReadExpGolomb()) + 1
+	sps.FrameMbsOnly = r.ReadFlag()
+	if !sps.FrameMbsOnly {
+		r.ReadFlag() // mb_adaptive_frame_field_flag
+	}
+	r.ReadFlag() // direct_8x8_inference_flag
+	var cropLeft, cropRight, cropTop, cropBottom uint32
+	if r.ReadFlag() { // frame_cropping_flag
+		cropLeft = uint32(r.ReadExpGolomb())
+		cropRight = uint32(r.ReadExpGolomb())
+		cropTop = uint32(r.ReadExpGolomb())
+		cropBottom = uint32(r.ReadExpGolomb())
+	}
+	vuiParametersPresent := r.ReadFlag()
+	if err = r.AccError(); err != nil {
+		err = fmt.Errorf("H.264 SPS truncated: %w", ErrInvalidParam)
+		return
+	}
+
+	frameHeightFactor := uint32(2)
+	if sps.FrameMbsOnly {
+		frameHeightFactor = 1
+	}
+	cropUnitX, cropUnitY := uint32(1), frameHeightFactor
+	if sps.ChromaFormatIdc != 0 && !sps.SeparateColourPlane {
+		subWidthC, subHeightC := uint32(2), uint32(2)
+		switch sps.ChromaFormatIdc {
+		case 2:
+			subHeightC = 1
+		case 3:
+			subWidthC, subHeightC = 1, 1
+		}
+		cropUnitX, cropUnitY = subWidthC, subHeightC*frameHeightFactor
+	}
+	sps.Width = widthInMbs*16 - cropUnitX*(cropLeft+cropRight)
+	sps.Height = frameHeightFactor*heightInMapUnits*16 - cropUnitY*(cropTop+cropBottom)
+
+	if vuiParametersPresent {
+		sps.readVUI(r)
+	}
+	return
+}
+
+// readVUI reads the sample aspect ratio and colour description at the start of
+// the VUI parameters. The VUI is optional information, so a truncated VUI is
+// not an error.
+func (sps *AVCSPS) readVUI(r *bits.AccErrEBSPReader) {
+	if r.ReadFlag() { // aspect_ratio_info_present_flag
+		aspectRatioIdc := r.Read(8)
+		switch {
+		case aspectRatioIdc == 255: // Extended_SAR
+			sps.SarWidth = uint16(r.Read(16))
+			sps.SarHeight = uint16(r.Read(16))
+		case aspectRatioIdc >= 1 && int(aspectRatioIdc) <= len(avcSampleAspectRatios):
+			sar := avcSampleAspectRatios[aspectRatioIdc-1]
+			sps.SarWidth, sps.SarHeight = sar[0], sar[1]
+		}
+	}
+	if r.ReadFlag() { // overscan_info_present_flag
+		r.ReadFlag() // overscan_appropriate_flag
+	}
+	if r.ReadFlag() { // video_signal_type_present_flag
+		r.Read(3) // video_format
+		fullRange := r.ReadFlag()
+		if r.ReadFlag() { // colour_description_present_flag
+			colour := &ColourDescription{
+				ColourPrimaries:         uint16(r.Read(8)),
+				TransferCharacteristics: uint16(r.Read(8)),
+				MatrixCoefficients:      uint16(r.Read(8)),
+				FullRange:               fullRange,
+			}
+			if r.AccError() == nil {
+				sps.Colour = colour
+			}
+		}
+	}
+	if r.AccError() != nil {
+		sps.SarWidth, sps.SarHeight = 0, 0
+	}
+}
+
+func skipAVCScalingList(r *bits.AccErrEBSPReader, size int) {
+	lastScale, nextScale := 8, 8
+	for j := 0; j < size && r.AccError() == nil; j++ {
+		if nextScale != 0 {
+			deltaScale := r.ReadSignedGolomb()
+			nextScale = (lastScale + deltaScale + 256) % 256
+		}
+		if nextScale != 0 {
+			lastScale = nextScale
+		}
+	}
+}
+
+// avcSPS parses the first SPS in the CodecPrivateData of an H.264 track.
+func (p MoovProcessor) avcSPS() (sps AVCSPS, err error) {
+	for _, nalu := range bytes.Split(p.CodecPrivateData, []byte{0, 0, 0, 1}) {
+		if len(nalu) > 0 && avc.GetNaluType(nalu[0]) == avc.NALU_SPS {
+			return ParseAVCSPS(nalu)
+		}
+	}
+	err = fmt.Errorf("no SPS in CodecPrivateData: %w", ErrInvalidParam)
+	return
+}
@@ -0,0 +1,105 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestParseAVCSPS(t *testing.T) {
+	tests := []struct {
+		name string
+		sps  string
+		want AVCSPS
+	}{
+		{
+			name: "High profile",
+			sps:  "6764001facd9405005bb011000000300100000030320f1831960",
+			want: AVCSPS{
+				ProfileIdc: 100, LevelIdc: 31, ChromaFormatIdc: 1, FrameMbsOnly: true,
+				Width: 1280, Height: 720, SarWidth: 1, SarHeight: 1,
+			},
+		},
+		{
+			name: "Baseline profile with cropping and a colour description",
+			sps:  "6742c028da01e0089f97ff000400036e12201204",
+			want: AVCSPS{
+				ProfileIdc: 66, ConstraintFlags: 0xc0, LevelIdc: 40, ChromaFormatIdc: 1, FrameMbsOnly: true,
+				Width: 1920, Height: 1080, SarWidth: 4, SarHeight: 3,
+				Colour: &ColourDescription{ColourPrimaries: 9, TransferCharacteristics: 16, MatrixCoefficients: 9, FullRange: true},
+			},
+		},
+		{
+			name: "High 4:2:2 10-bit interlaced",
+			sps:  "677a0029b6cec0780447ca80",
+			want: AVCSPS{
+				ProfileIdc: 122, LevelIdc: 41, ChromaFormatIdc: 2, BitDepthLumaMinus8: 2, BitDepthChromaMinus8: 2,
+				Width: 1920, Height: 1080,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sps, err := ParseAVCSPS(decodeHex(t, tt.sps))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(sps, tt.want) {
+				t.Errorf("ParseAVCSPS() = %+v, want %+v", sps, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAVCSPSErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		nalu string
+	}{
+		{name: "empty", nalu: ""},
+		{name: "PPS", nalu: "68ebe3cb22c0"},
+		{name: "truncated header", nalu: "676400"},
+		{name: "truncated", nalu: "6764001facd9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if sps, err := ParseAVCSPS(decodeHex(t, tt.nalu)); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("ParseAVCSPS() = %+v, %v, want error %v", sps, err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestPictureSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		processor  MoovProcessor
+		wantWidth  uint32
+		wantHeight uint32
+	}{
+		{
+			name:      "from the SPS",
+			processor: MoovProcessor{Codec: mp4.Avc1FourCC, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)},
+			wantWidth: 1280, wantHeight: 720,
+		},
+		{
+			name:      "set by the manifest",
+			processor: MoovProcessor{Codec: mp4.Avc1FourCC, Width: 640, Height: 360, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)},
+			wantWidth: 640, wantHeight: 360,
+		},
+		{
+			name:      "no SPS",
+			processor: MoovProcessor{Codec: mp4.Avc1FourCC, Width: 640, CodecPrivateData: decodeHex(t, "0000000168ebe3cb22c0")},
+			wantWidth: 640,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if width, height := tt.processor.pictureSize(); width != tt.wantWidth || height != tt.wantHeight {
+				t.Errorf("pictureSize() = %dx%d, want %dx%d", width, height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
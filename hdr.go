@@ -0,0 +1,102 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/go-webdl/bits"
+	"github.com/go-webdl/media-codec/hevc"
+)
+
+// ColourDescription carries the colour primaries, transfer characteristics
+// and matrix coefficients of a video stream as defined by ISO/IEC 23091-2,
+// e.g. 9, 16 and 9 for BT.2020 PQ video.
+type ColourDescription struct {
+	ColourPrimaries         uint16
+	TransferCharacteristics uint16
+	MatrixCoefficients      uint16
+	FullRange               bool
+}
+
+// MasteringDisplayColourVolume describes the colour volume of the display
+// used to master the content, with the semantics of the mastering display
+// colour volume SEI message of ISO/IEC 23008-2 D.3.28: chromaticity
+// coordinates in increments of 0.00002 and luminances in increments of
+// 0.0001 cd/m².
+type MasteringDisplayColourVolume struct {
+	DisplayPrimariesX            [3]uint16
+	DisplayPrimariesY            [3]uint16
+	WhitePointX                  uint16
+	WhitePointY                  uint16
+	MaxDisplayMasteringLuminance uint32
+	MinDisplayMasteringLuminance uint32
+}
+
+// ContentLightLevel describes the light level of the content in cd/m², with
+// the semantics of the content light level information SEI message of
+// ISO/IEC 23008-2 D.3.35.
+type ContentLightLevel struct {
+	MaxContentLightLevel    uint16
+	MaxPicAverageLightLevel uint16
+}
+
+// SEI payload types of the HDR metadata messages.
+const (
+	seiMasteringDisplayColourVolume = 137
+	seiContentLightLevelInfo        = 144
+)
+
+// hevcHDRMetadata extracts the mastering display colour volume and content
+// light level from the prefix SEI NAL units carried in the CodecPrivateData
+// of an HEVC track, if present.
+func hevcHDRMetadata(codecPrivateData []byte) (mdcv *MasteringDisplayColourVolume, clli *ContentLightLevel) {
+	for _, nalu := range bytes.Split(codecPrivateData, []byte{0, 0, 0, 1}) {
+		if len(nalu) < 3 || hevc.GetNaluType(nalu[0]) != hevc.NALU_SEI_PREFIX {
+			continue
+		}
+		rbsp := bits.EBSP2rbsp(nalu[2:])
+		for len(rbsp) > 2 {
+			payloadType, n := readSEIValue(rbsp)
+			rbsp = rbsp[n:]
+			payloadSize, n := readSEIValue(rbsp)
+			rbsp = rbsp[n:]
+			if payloadSize > len(rbsp) {
+				break
+			}
+			payload := rbsp[:payloadSize]
+			rbsp = rbsp[payloadSize:]
+			switch {
+			case payloadType == seiMasteringDisplayColourVolume && len(payload) >= 24:
+				mdcv = &MasteringDisplayColourVolume{}
+				for c := 0; c < 3; c++ {
+					mdcv.DisplayPrimariesX[c] = binary.BigEndian.Uint16(payload[4*c:])
+					mdcv.DisplayPrimariesY[c] = binary.BigEndian.Uint16(payload[4*c+2:])
+				}
+				mdcv.WhitePointX = binary.BigEndian.Uint16(payload[12:])
+				mdcv.WhitePointY = binary.BigEndian.Uint16(payload[14:])
+				mdcv.MaxDisplayMasteringLuminance = binary.BigEndian.Uint32(payload[16:])
+				mdcv.MinDisplayMasteringLuminance = binary.BigEndian.Uint32(payload[20:])
+			case payloadType == seiContentLightLevelInfo && len(payload) >= 4:
+				clli = &ContentLightLevel{
+					MaxContentLightLevel:    binary.BigEndian.Uint16(payload[0:]),
+					MaxPicAverageLightLevel: binary.BigEndian.Uint16(payload[2:]),
+				}
+			}
+		}
+	}
+	return
+}
+
+// readSEIValue reads the payload type or payload size of an SEI message,
+// coded as a run of 0xff bytes followed by a last byte.
+func readSEIValue(data []byte) (value, n int) {
+	for n < len(data) {
+		b := data[n]
+		n++
+		value += int(b)
+		if b != 0xff {
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package smoothstreaming
+
+import "testing"
+
+// testHEVCHDRSEI is a prefix SEI NAL unit with the mastering display colour
+// volume of a BT.2020 display of 1000 cd/m² and a content light level of 1000
+// and 400 cd/m², with an emulation prevention byte.
+const testHEVCHDRSEI = "4e01891821349baa199608fc8a4839083d134042009896800000030032900403e8019080"
+
+var (
+	testMasteringDisplay = MasteringDisplayColourVolume{
+		DisplayPrimariesX:            [3]uint16{8500, 6550, 35400},
+		DisplayPrimariesY:            [3]uint16{39850, 2300, 14600},
+		WhitePointX:                  15635,
+		WhitePointY:                  16450,
+		MaxDisplayMasteringLuminance: 10000000,
+		MinDisplayMasteringLuminance: 50,
+	}
+	testContentLightLevel = ContentLightLevel{MaxContentLightLevel: 1000, MaxPicAverageLightLevel: 400}
+)
+
+func TestHEVCHDRMetadata(t *testing.T) {
+	tests := []struct {
+		name             string
+		codecPrivateData string
+		wantMdcv         bool
+		wantClli         bool
+	}{
+		{
+			name:             "SEI after the parameter sets",
+			codecPrivateData: testHEVCCodecPrivateData + "00000001" + testHEVCHDRSEI,
+			wantMdcv:         true,
+			wantClli:         true,
+		},
+		{
+			name:             "no SEI",
+			codecPrivateData: testHEVCCodecPrivateData,
+		},
+		{
+			name:             "truncated payload",
+			codecPrivateData: "00000001" + testHEVCHDRSEI[:20],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mdcv, clli := hevcHDRMetadata(decodeHex(t, tt.codecPrivateData))
+			if (mdcv != nil) != tt.wantMdcv || (clli != nil) != tt.wantClli {
+				t.Fatalf("hevcHDRMetadata() = %+v, %+v, want mdcv %v, clli %v", mdcv, clli, tt.wantMdcv, tt.wantClli)
+			}
+			if mdcv != nil && *mdcv != testMasteringDisplay {
+				t.Errorf("mdcv = %+v, want %+v", *mdcv, testMasteringDisplay)
+			}
+			if clli != nil && *clli != testContentLightLevel {
+				t.Errorf("clli = %+v, want %+v", *clli, testContentLightLevel)
+			}
+		})
+	}
+}
+
+func TestReadSEIValue(t *testing.T) {
+	tests := []struct {
+		data  []byte
+		value int
+		n     int
+	}{
+		{data: []byte{0x89, 0x18}, value: 137, n: 1},
+		{data: []byte{0xff, 0xff, 0x05}, value: 515, n: 3},
+		{data: []byte{0xff}, value: 255, n: 1},
+	}
+	for _, tt := range tests {
+		if value, n := readSEIValue(tt.data); value != tt.value || n != tt.n {
+			t.Errorf("readSEIValue(%x) = %d, %d, want %d, %d", tt.data, value, n, tt.value, tt.n)
+		}
+	}
+}
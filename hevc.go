@@ -0,0 +1,121 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-webdl/bits"
+	"github.com/go-webdl/media-codec/hevc"
+)
+
+// HEVCSPS carries the fields of an HEVC sequence parameter set, ISO/IEC
+// 23008-2 7.3.2.2, up to the bit depth of the samples. Unlike
+// hevc.ParseSPSNALUnit it parses the sub-layer profile and level information,
+// so the fields are also available for streams with temporal sub-layers.
+type HEVCSPS struct {
+	ProfileSpace              uint8
+	TierFlag                  bool
+	ProfileIdc                uint8
+	ProfileCompatibilityFlags uint32
+	ConstraintIndicatorFlags  uint64 // 48 bits
+	LevelIdc                  uint8
+	MaxSubLayersMinus1        uint8
+	TemporalIDNesting         bool
+	SPSID                     uint
+	ChromaFormatIdc           uint8
+	SeparateColourPlane       bool
+	BitDepthLumaMinus8        uint8
+	BitDepthChromaMinus8      uint8
+
+	// the picture size after applying the conformance window, in luma
+	// samples.
+	Width  uint32
+	Height uint32
+}
+
+// ParseHEVCSPS parses an SPS NAL unit, including its NAL unit header.
+func ParseHEVCSPS(nalu []byte) (sps HEVCSPS, err error) {
+	if len(nalu) < 3 || hevc.GetNaluType(nalu[0]) != hevc.NALU_SPS {
+		err = fmt.Errorf("not an HEVC SPS NAL unit: %w", ErrInvalidParam)
+		return
+	}
+	r := bits.NewAccErrEBSPReader(bytes.NewReader(nalu[2:]))
+	r.Read(4) // sps_video_parameter_set_id
+	sps.MaxSubLayersMinus1 = uint8(r.Read(3))
+	sps.TemporalIDNesting = r.ReadFlag()
+
+	// profile_tier_level(1, sps_max_sub_layers_minus1)
+	sps.ProfileSpace = uint8(r.Read(2))
+	sps.TierFlag = r.ReadFlag()
+	sps.ProfileIdc = uint8(r.Read(5))
+	sps.ProfileCompatibilityFlags = uint32(r.Read(32))
+	sps.ConstraintIndicatorFlags = uint64(r.Read(16))<<32 | uint64(r.Read(32))
+	sps.LevelIdc = uint8(r.Read(8))
+	subLayerProfilePresent := make([]bool, sps.MaxSubLayersMinus1)
+	subLayerLevelPresent := make([]bool, sps.MaxSubLayersMinus1)
+	for i := range subLayerProfilePresent {
+		subLayerProfilePresent[i] = r.ReadFlag()
+		subLayerLevelPresent[i] = r.ReadFlag()
+	}
+	if sps.MaxSubLayersMinus1 > 0 {
+		for i := sps.MaxSubLayersMinus1; i < 8; i++ {
+			r.Read(2) // reserved_zero_2bits
+		}
+	}
+	for i := range subLayerProfilePresent {
+		if subLayerProfilePresent[i] {
+			// sub_layer_profile_space to sub_layer_inbld_flag
+			r.Read(32)
+			r.Read(32)
+			r.Read(24)
+		}
+		if subLayerLevelPresent[i] {
+			r.Read(8) // sub_layer_level_idc
+		}
+	}
+
+	sps.SPSID = r.ReadExpGolomb()
+	sps.ChromaFormatIdc = uint8(r.ReadExpGolomb())
+	if sps.ChromaFormatIdc == 3 {
+		sps.SeparateColourPlane = r.ReadFlag()
+	}
+	width := uint32(r.ReadExpGolomb())  // pic_width_in_luma_samples
+	height := uint32(r.ReadExpGolomb()) // pic_height_in_luma_samples
+	var left, right, top, bottom uint32
+	if r.ReadFlag() { // conformance_window_flag
+		left = uint32(r.ReadExpGolomb())
+		right = uint32(r.ReadExpGolomb())
+		top = uint32(r.ReadExpGolomb())
+		bottom = uint32(r.ReadExpGolomb())
+	}
+	sps.BitDepthLumaMinus8 = uint8(r.ReadExpGolomb())
+	sps.BitDepthChromaMinus8 = uint8(r.ReadExpGolomb())
+	if err = r.AccError(); err != nil {
+		err = fmt.Errorf("HEVC SPS truncated: %w", ErrInvalidParam)
+		return
+	}
+
+	subWidthC, subHeightC := uint32(1), uint32(1)
+	if !sps.SeparateColourPlane {
+		switch sps.ChromaFormatIdc {
+		case 1:
+			subWidthC, subHeightC = 2, 2
+		case 2:
+			subWidthC = 2
+		}
+	}
+	sps.Width = width - subWidthC*(left+right)
+	sps.Height = height - subHeightC*(top+bottom)
+	return
+}
+
+// hevcSPS parses the first SPS in the CodecPrivateData of an HEVC track.
+func (p MoovProcessor) hevcSPS() (sps HEVCSPS, err error) {
+	for _, nalu := range bytes.Split(p.CodecPrivateData, []byte{0, 0, 0, 1}) {
+		if len(nalu) > 0 && hevc.GetNaluType(nalu[0]) == hevc.NALU_SPS {
+			return ParseHEVCSPS(nalu)
+		}
+	}
+	err = fmt.Errorf("no SPS in CodecPrivateData: %w", ErrInvalidParam)
+	return
+}
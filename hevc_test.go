@@ -0,0 +1,86 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+// An SPS of the Main 10 profile, high tier, with one temporal sub-layer and a
+// conformance window cropping 1920x1088 to 1920x1080.
+const testHEVCSubLayerSPS = "420103222000000300900000030000030099c000220000030000900000030000030096a003c0801107cadc"
+
+func TestParseHEVCSPS(t *testing.T) {
+	tests := []struct {
+		name string
+		sps  string
+		want HEVCSPS
+	}{
+		{
+			name: "Main profile",
+			sps:  "420101016000000300900000030000030078a005020171f2e596a4932bc05a70808080820000030002000003003210",
+			want: HEVCSPS{
+				ProfileIdc: 1, ProfileCompatibilityFlags: 0x60000000, ConstraintIndicatorFlags: 0x900000000000,
+				LevelIdc: 120, TemporalIDNesting: true, ChromaFormatIdc: 1, Width: 640, Height: 360,
+			},
+		},
+		{
+			name: "temporal sub-layers",
+			sps:  testHEVCSubLayerSPS,
+			want: HEVCSPS{
+				TierFlag: true, ProfileIdc: 2, ProfileCompatibilityFlags: 0x20000000, ConstraintIndicatorFlags: 0x900000000000,
+				LevelIdc: 153, MaxSubLayersMinus1: 1, TemporalIDNesting: true, ChromaFormatIdc: 1,
+				BitDepthLumaMinus8: 2, BitDepthChromaMinus8: 2, Width: 1920, Height: 1080,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sps, err := ParseHEVCSPS(decodeHex(t, tt.sps))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sps != tt.want {
+				t.Errorf("ParseHEVCSPS() = %+v, want %+v", sps, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHEVCSPSErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		nalu string
+	}{
+		{name: "empty", nalu: ""},
+		{name: "VPS", nalu: "40010c01ffff016000000300900000030000030078959809"},
+		{name: "truncated", nalu: "4201010160000003009000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if sps, err := ParseHEVCSPS(decodeHex(t, tt.nalu)); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("ParseHEVCSPS() = %+v, %v, want error %v", sps, err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestCreateHvcCMp4BoxSubLayers(t *testing.T) {
+	p := MoovProcessor{
+		Codec: mp4.Hvc1FourCC,
+		CodecPrivateData: decodeHex(t, "00000001"+"40010c01ffff016000000300900000030000030078959809"+
+			"00000001"+testHEVCSubLayerSPS+"00000001"+"4401c172b46240"),
+	}
+	box, err := p.CreateHvcCMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := box.(*mp4.HEVCConfigurationBox).HEVCConfig
+	if config.ChromaFormatIndicator != 1 || config.BitDepthLumaMinus8 != 2 || config.BitDepthChromaMinus8 != 2 {
+		t.Errorf("chroma format %d, bit depths %d %d, want 1, 2 2", config.ChromaFormatIndicator, config.BitDepthLumaMinus8, config.BitDepthChromaMinus8)
+	}
+	if config.NumTemporalLayers != 2 || config.TemporalIDNested != 1 {
+		t.Errorf("temporal layers %d, nested %d, want 2, 1", config.NumTemporalLayers, config.TemporalIDNested)
+	}
+}
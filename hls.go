@@ -0,0 +1,326 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+// HLSOptions are the options of the conversion of a Smooth Streaming
+// presentation to HLS, see SmoothStreamingMedia.HLS.
+type HLSOptions struct {
+	// the prefix of the URIs of the segments and of the init segments, e.g.
+	// the url of the directory of the manifest for the fragments to be
+	// requested from the Smooth Streaming origin, relative to the media
+	// playlists if empty.
+	BaseURL string
+
+	// the URI of the init segments, relative to the BaseURL, {id} being
+	// replaced by the id of the track, "{id}/init.mp4" if empty.
+	Map string
+
+	// the URI of the media playlists, relative to the multivariant playlist,
+	// {id} being replaced by the id of the track, "{id}.m3u8" if empty.
+	MediaPlaylist string
+
+	// the FairPlay key of the protected tracks, e.g. "skd://key", added to
+	// the keys of the protection systems of the Protection element, none if
+	// empty.
+	FairPlayKeyURI string
+
+	// the nominal duration of the fragments of live presentations, from
+	// which the media sequence number of each segment is derived, its time
+	// divided by the duration, so that it stays the same as the window of
+	// the playlist slides; the most frequent duration of the fragments of
+	// each stream if 0. The fragment numbers of the manifest are used instead
+	// if the manifest has them.
+	LiveFragmentDuration time.Duration
+}
+
+// HLSPlaylists are the playlists of a presentation converted to HLS.
+type HLSPlaylists struct {
+	// the multivariant playlist.
+	Multivariant []byte
+
+	// the media playlist of each track by the id of the track, the id of its
+	// Representation in DASH, see SmoothStreamingMedia.DASHRepresentation.
+	Media map[string][]byte
+}
+
+// HLS formats.
+const (
+	hlsDefaultMap           = "{id}/init.mp4"
+	hlsDefaultMediaPlaylist = "{id}.m3u8"
+	hlsKeyFormatFairPlay    = "com.apple.streamingkeydelivery"
+	hlsKeyFormatPlayReady   = "com.microsoft.playready"
+	hlsAudioGroup           = "audio"
+)
+
+// HLS converts the presentation to HLS playlists of fMP4 segments, so that a
+// Smooth Streaming origin can be played by Apple devices through a converting
+// proxy. Each video and audio track gets a media playlist listing its
+// fragments, requested with the url pattern of its stream, after the
+// EXT-X-MAP of its init segment. The multivariant playlist lists a variant
+// per video track, with the audio streams as the renditions of its audio
+// group, each by its track of the highest bitrate; a variant per audio track
+// if there is no video. Protected tracks have an EXT-X-KEY per protection
+// system, SAMPLE-AES for the 'cbcs' scheme, which Apple devices require, and
+// SAMPLE-AES-CTR for 'cenc'. Text streams are left out, HLS requiring IMSC1
+// subtitles which Smooth Streaming TTML need not be.
+//
+// Like for DASH, the proxy serves the init segments, building them with the
+// MoovProcessor of the track of DASHRepresentation, and rewrites the
+// fragments with a FragmentRewriter.
+func (m *SmoothStreamingMedia) HLS(opts HLSOptions) (playlists HLSPlaylists, err error) {
+	if opts.Map == "" {
+		opts.Map = hlsDefaultMap
+	}
+	if opts.MediaPlaylist == "" {
+		opts.MediaPlaylist = hlsDefaultMediaPlaylist
+	}
+	var keys []string
+	if keys, err = m.hlsKeys(opts); err != nil {
+		return
+	}
+
+	// the variants and the audio renditions
+	type rendition struct {
+		s        *StreamIndex
+		t        *Track
+		codecs   string
+		uri      string
+		width    uint32
+		height   uint32
+		channels uint16
+	}
+	var videos, audios, audioTracks []rendition
+	playlists.Media = make(map[string][]byte)
+	for _, s := range m.Streams {
+		if !isDASHStream(s) || (s.Type != VideoStream && s.Type != AudioStream) {
+			continue
+		}
+		best := -1
+		for _, t := range s.Tracks {
+			var p MoovProcessor
+			if p, err = m.MoovProcessor(s, t); err != nil {
+				return
+			}
+			id := dashRepresentationID(s, t)
+			r := rendition{s: s, t: t, uri: strings.ReplaceAll(opts.MediaPlaylist, "{id}", id), width: p.Width, height: p.Height, channels: p.Channels}
+			if r.codecs, err = p.CodecString(); err != nil {
+				err = fmt.Errorf("track %d of stream %s: %w", t.Index, s.streamName(), err)
+				return
+			}
+			var media []byte
+			if media, err = m.hlsMediaPlaylist(s, t, p, opts, keys); err != nil {
+				return
+			}
+			playlists.Media[id] = media
+			if s.Type == VideoStream {
+				videos = append(videos, r)
+				continue
+			}
+			if best < 0 || t.Bitrate > audioTracks[best].t.Bitrate {
+				best = len(audioTracks)
+			}
+			audioTracks = append(audioTracks, r)
+		}
+		if best >= 0 {
+			audios = append(audios, audioTracks[best])
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	if len(videos) == 0 {
+		// the audio tracks are the variants
+		for _, a := range audioTracks {
+			fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"\n%s\n", a.t.Bitrate, a.codecs, a.uri)
+		}
+		playlists.Multivariant = b.Bytes()
+		return
+	}
+	var audioBitrate uint32
+	var audioCodecs []string
+	for i, a := range audios {
+		fmt.Fprintf(&b, "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"%s\",NAME=\"%s\"", hlsAudioGroup, hlsQuote(a.s.streamName()))
+		if a.s.Language != nil {
+			fmt.Fprintf(&b, ",LANGUAGE=\"%s\"", hlsQuote(*a.s.Language))
+		}
+		if i == 0 {
+			b.WriteString(",DEFAULT=YES")
+		}
+		b.WriteString(",AUTOSELECT=YES")
+		if a.channels > 0 {
+			fmt.Fprintf(&b, ",CHANNELS=\"%d\"", a.channels)
+		}
+		fmt.Fprintf(&b, ",URI=\"%s\"\n", a.uri)
+		if a.t.Bitrate > audioBitrate {
+			audioBitrate = a.t.Bitrate
+		}
+		if !containsString(audioCodecs, a.codecs) {
+			audioCodecs = append(audioCodecs, a.codecs)
+		}
+	}
+	sort.SliceStable(videos, func(i, j int) bool {
+		return videos[i].t.Bitrate > videos[j].t.Bitrate
+	})
+	for _, v := range videos {
+		codecs := strings.Join(append([]string{v.codecs}, audioCodecs...), ",")
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"", v.t.Bitrate+audioBitrate, codecs)
+		if v.width > 0 && v.height > 0 {
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", v.width, v.height)
+		}
+		if len(audios) > 0 {
+			fmt.Fprintf(&b, ",AUDIO=\"%s\"", hlsAudioGroup)
+		}
+		fmt.Fprintf(&b, "\n%s\n", v.uri)
+	}
+	playlists.Multivariant = b.Bytes()
+	return
+}
+
+// hlsMediaPlaylist returns the media playlist of the track t of the stream s,
+// configured by p.
+func (m *SmoothStreamingMedia) hlsMediaPlaylist(s *StreamIndex, t *Track, p MoovProcessor, opts HLSOptions, keys []string) (playlist []byte, err error) {
+	timeline, err := m.StreamTimeline(s)
+	if err != nil {
+		return
+	}
+	// the fragments whose duration is not known yet, at the live edge, are
+	// listed once a later manifest gives their duration
+	fragments := make([]TimelineFragment, 0, len(timeline))
+	for _, f := range timeline {
+		if f.Duration > 0 {
+			fragments = append(fragments, f)
+		}
+	}
+	timescale := m.StreamTimeScale(s)
+	target := 1
+	for _, f := range fragments {
+		if d := int(math.Round(mediaDuration(f.Duration, timescale).Seconds())); d > target {
+			target = d
+		}
+	}
+	live := m.IsLive != nil && *m.IsLive
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:%d\n", target)
+	if len(fragments) > 0 {
+		fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", hlsMediaSequence(s, fragments, timescale, live, opts))
+	}
+	if !live {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	if p.Protected {
+		for _, key := range keys {
+			b.WriteString(key)
+		}
+	}
+	fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s%s\"\n", opts.BaseURL, strings.ReplaceAll(opts.Map, "{id}", dashRepresentationID(s, t)))
+	for _, f := range fragments {
+		fmt.Fprintf(&b, "#EXTINF:%.6f,\n%s%s\n", mediaDuration(f.Duration, timescale).Seconds(), opts.BaseURL, expandURLPattern(*s.URL, t, f.Time))
+	}
+	if !live {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	playlist = b.Bytes()
+	return
+}
+
+// hlsMediaSequence returns the media sequence number of the first of the
+// fragments of the stream s: its number if the manifest numbers the
+// fragments or the presentation is on demand, or else its time divided by
+// the duration of the fragments, as live manifests number the fragments of
+// their window from 0.
+func hlsMediaSequence(s *StreamIndex, fragments []TimelineFragment, timescale uint64, live bool, opts HLSOptions) uint64 {
+	first := fragments[0]
+	if !live || len(s.Fragments) > 0 && s.Fragments[0].Number != nil {
+		return uint64(first.Number)
+	}
+	duration := rescaleTime(uint64(opts.LiveFragmentDuration), uint64(time.Second), timescale)
+	if duration == 0 {
+		count := make(map[uint64]int)
+		for _, f := range fragments {
+			if count[f.Duration]++; count[f.Duration] > count[duration] || count[f.Duration] == count[duration] && f.Duration > duration {
+				duration = f.Duration
+			}
+		}
+	}
+	if duration == 0 {
+		return uint64(first.Number)
+	}
+	return first.Time / duration
+}
+
+// hlsKeys returns the EXT-X-KEY tags of the protected tracks: one per
+// protection system of the Protection element, and the FairPlay key of the
+// options.
+func (m *SmoothStreamingMedia) hlsKeys(opts HLSOptions) (keys []string, err error) {
+	if m.Protection == nil || len(m.Protection.ProtectionHeaders) == 0 {
+		return
+	}
+	var p MoovProcessor
+	if err = m.setProtection(&p); err != nil {
+		return
+	}
+	scheme, err := p.encryptionScheme()
+	if err != nil {
+		return
+	}
+	method := "SAMPLE-AES"
+	if scheme == mp4.CencFourCC {
+		method = "SAMPLE-AES-CTR"
+	}
+	key := func(uri, format string) string {
+		return fmt.Sprintf("#EXT-X-KEY:METHOD=%s,URI=\"%s\",KEYFORMAT=\"%s\",KEYFORMATVERSIONS=\"1\"\n", method, uri, format)
+	}
+	if opts.FairPlayKeyURI != "" {
+		keys = append(keys, key(opts.FairPlayKeyURI, hlsKeyFormatFairPlay))
+	}
+	for _, system := range p.protectionSystems() {
+		switch system.SystemID {
+		case PlayReadySystemID:
+			// the PlayReady Object holds a UTF-16 header, hence the charset
+			keys = append(keys, key("data:text/plain;charset=UTF-16;base64,"+base64.StdEncoding.EncodeToString(system.Data), hlsKeyFormatPlayReady))
+		default:
+			pssh := &mp4.ProtectionSystemSpecificHeaderBox{SystemID: system.SystemID, Data: system.Data}
+			pssh.Mp4BoxUpdate()
+			var data bytes.Buffer
+			if err = pssh.Mp4BoxWrite(&data); err != nil {
+				return
+			}
+			keys = append(keys, key("data:text/plain;base64,"+base64.StdEncoding.EncodeToString(data.Bytes()), "urn:uuid:"+system.SystemID.String()))
+		}
+	}
+	return
+}
+
+// hlsQuote returns s for a quoted-string attribute, which cannot hold double
+// quotes nor line breaks.
+func hlsQuote(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '"', '\r', '\n':
+			return '\''
+		}
+		return r
+	}, s)
+}
+
+// containsString reports whether ss contains s.
+func containsString(ss []string, s string) bool {
+	for _, c := range ss {
+		if c == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package smoothstreaming
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+const testAACCodecPrivateData = "1190"
+
+func TestHLS(t *testing.T) {
+	tests := []struct {
+		name         string
+		manifest     string
+		opts         HLSOptions
+		multivariant string
+		media        map[string]string
+	}{
+		{
+			name: "on demand",
+			manifest: `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+				`<StreamIndex Type="video" Name="video" Chunks="3" QualityLevels="2" Url="QualityLevels({bitrate})/Fragments(video={start time})">` +
+				`<QualityLevel Index="0" Bitrate="1000000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+				`<QualityLevel Index="1" Bitrate="500000" FourCC="H264" MaxWidth="640" MaxHeight="360" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+				`<c t="0" d="20000000" r="2"/><c/>` +
+				`</StreamIndex>` +
+				`<StreamIndex Type="audio" Name="audio" Language="en" Chunks="2" QualityLevels="1" Url="QualityLevels({bitrate})/Fragments(audio={start time})">` +
+				`<QualityLevel Index="0" Bitrate="128000" FourCC="AACL" SamplingRate="48000" Channels="2" BitsPerSample="16" PacketSize="4" AudioTag="255" CodecPrivateData="` + testAACCodecPrivateData + `"/>` +
+				`<c t="0" d="30000000"/><c d="30000000"/>` +
+				`</StreamIndex></SmoothStreamingMedia>`,
+			multivariant: `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="audio",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="audio_0.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1128000,CODECS="avc1.64001F,mp4a.40.2",RESOLUTION=1280x720,AUDIO="audio"
+video_0.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=628000,CODECS="avc1.64001F,mp4a.40.2",RESOLUTION=640x360,AUDIO="audio"
+video_1.m3u8
+`,
+			media: map[string]string{
+				"video_0": `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="video_0/init.mp4"
+#EXTINF:2.000000,
+QualityLevels(1000000)/Fragments(video=0)
+#EXTINF:2.000000,
+QualityLevels(1000000)/Fragments(video=20000000)
+#EXTINF:2.000000,
+QualityLevels(1000000)/Fragments(video=40000000)
+#EXT-X-ENDLIST
+`,
+				"video_1": `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="video_1/init.mp4"
+#EXTINF:2.000000,
+QualityLevels(500000)/Fragments(video=0)
+#EXTINF:2.000000,
+QualityLevels(500000)/Fragments(video=20000000)
+#EXTINF:2.000000,
+QualityLevels(500000)/Fragments(video=40000000)
+#EXT-X-ENDLIST
+`,
+				"audio_0": `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:3
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="audio_0/init.mp4"
+#EXTINF:3.000000,
+QualityLevels(128000)/Fragments(audio=0)
+#EXTINF:3.000000,
+QualityLevels(128000)/Fragments(audio=30000000)
+#EXT-X-ENDLIST
+`,
+			},
+		},
+		{
+			name: "live",
+			manifest: `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="0" IsLive="TRUE" LookaheadCount="2" DVRWindowLength="0">` +
+				`<StreamIndex Type="video" Name="video" Chunks="0" QualityLevels="1" Url="QualityLevels({bitrate})/Fragments(video={start time})">` +
+				`<QualityLevel Index="0" Bitrate="1000000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+				`<c t="1000000000" d="20000000" r="2"/><c/>` +
+				`</StreamIndex></SmoothStreamingMedia>`,
+			opts: HLSOptions{BaseURL: "http://origin/live.isml/"},
+			multivariant: `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001F",RESOLUTION=1280x720
+video_0.m3u8
+`,
+			media: map[string]string{
+				"video_0": `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:50
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="http://origin/live.isml/video_0/init.mp4"
+#EXTINF:2.000000,
+http://origin/live.isml/QualityLevels(1000000)/Fragments(video=1000000000)
+#EXTINF:2.000000,
+http://origin/live.isml/QualityLevels(1000000)/Fragments(video=1020000000)
+`,
+			},
+		},
+		{
+			name: "protected",
+			manifest: `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="40000000">` +
+				`<StreamIndex Type="video" Name="video" Chunks="2" QualityLevels="1" Url="QualityLevels({bitrate})/Fragments(video={start time})">` +
+				`<QualityLevel Index="0" Bitrate="1000000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+				`<c d="20000000" r="2"/>` +
+				`</StreamIndex>` +
+				`<Protection><ProtectionHeader SystemID="edef8ba9-79d6-4ace-a3c8-27dcd51d21ed">AAAA</ProtectionHeader></Protection>` +
+				`</SmoothStreamingMedia>`,
+			opts: HLSOptions{FairPlayKeyURI: "skd://key"},
+			multivariant: `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001F",RESOLUTION=1280x720
+video_0.m3u8
+`,
+			media: map[string]string{
+				"video_0": `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-KEY:METHOD=SAMPLE-AES-CTR,URI="skd://key",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXT-X-KEY:METHOD=SAMPLE-AES-CTR,URI="data:text/plain;base64,AAAAI3Bzc2gAAAAA7e+LqXnWSs6jyCfc1R0h7QAAAAMAAAA=",KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXT-X-MAP:URI="video_0/init.mp4"
+#EXTINF:2.000000,
+QualityLevels(1000000)/Fragments(video=0)
+#EXTINF:2.000000,
+QualityLevels(1000000)/Fragments(video=20000000)
+#EXT-X-ENDLIST
+`,
+			},
+		},
+		{
+			name: "audio only",
+			manifest: `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="40000000">` +
+				`<StreamIndex Type="audio" Name="audio" Chunks="2" QualityLevels="2" Url="QualityLevels({bitrate})/Fragments(audio={start time})">` +
+				`<QualityLevel Index="0" Bitrate="128000" FourCC="AACL" SamplingRate="48000" Channels="2" BitsPerSample="16" PacketSize="4" AudioTag="255" CodecPrivateData="` + testAACCodecPrivateData + `"/>` +
+				`<QualityLevel Index="1" Bitrate="64000" FourCC="AACL" SamplingRate="48000" Channels="2" BitsPerSample="16" PacketSize="4" AudioTag="255" CodecPrivateData="` + testAACCodecPrivateData + `"/>` +
+				`<c d="20000000" r="2"/>` +
+				`</StreamIndex></SmoothStreamingMedia>`,
+			multivariant: `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-STREAM-INF:BANDWIDTH=128000,CODECS="mp4a.40.2"
+audio_0.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=64000,CODECS="mp4a.40.2"
+audio_1.m3u8
+`,
+			media: map[string]string{
+				"audio_0": `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="audio_0/init.mp4"
+#EXTINF:2.000000,
+QualityLevels(128000)/Fragments(audio=0)
+#EXTINF:2.000000,
+QualityLevels(128000)/Fragments(audio=20000000)
+#EXT-X-ENDLIST
+`,
+				"audio_1": `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-INDEPENDENT-SEGMENTS
+#EXT-X-MAP:URI="audio_1/init.mp4"
+#EXTINF:2.000000,
+QualityLevels(64000)/Fragments(audio=0)
+#EXTINF:2.000000,
+QualityLevels(64000)/Fragments(audio=20000000)
+#EXT-X-ENDLIST
+`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseManifest([]byte(tt.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			playlists, err := m.HLS(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(playlists.Multivariant); got != tt.multivariant {
+				t.Errorf("multivariant playlist:\n%s\nwant:\n%s", got, tt.multivariant)
+			}
+			if len(playlists.Media) != len(tt.media) {
+				t.Errorf("got %d media playlists, want %d", len(playlists.Media), len(tt.media))
+			}
+			for id, want := range tt.media {
+				if got := string(playlists.Media[id]); got != want {
+					t.Errorf("media playlist %s:\n%s\nwant:\n%s", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHLSMediaSequence(t *testing.T) {
+	number := uint32(7)
+	numbered := &StreamIndex{Fragments: []*StreamFragment{{Number: &number}}}
+	fragments := []TimelineFragment{{Number: 7, Time: 1000000000, Duration: 20000000}, {Number: 8, Time: 1020000000, Duration: 20000000}, {Number: 9, Time: 1040000000, Duration: 19000000}}
+	tests := []struct {
+		name string
+		s    *StreamIndex
+		live bool
+		opts HLSOptions
+		want uint64
+	}{
+		{name: "on demand", s: &StreamIndex{}, want: 7},
+		{name: "live numbered fragments", s: numbered, live: true, want: 7},
+		{name: "live most frequent duration", s: &StreamIndex{}, live: true, want: 50},
+		{name: "live fragment duration", s: &StreamIndex{}, live: true, opts: HLSOptions{LiveFragmentDuration: 4 * time.Second}, want: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hlsMediaSequence(tt.s, fragments, 10000000, tt.live, tt.opts); got != tt.want {
+				t.Errorf("hlsMediaSequence() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHLSKeysPlayReady(t *testing.T) {
+	pro := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(testWRMHeader40)}}}.Bytes()
+	m, err := ParseManifest([]byte(`<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="0">` +
+		`<Protection><ProtectionHeader SystemID="9a04f079-9840-4286-ab92-e65be0885f95">` + base64.StdEncoding.EncodeToString(pro) + `</ProtectionHeader></Protection>` +
+		`</SmoothStreamingMedia>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := m.hlsKeys(HLSOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `#EXT-X-KEY:METHOD=SAMPLE-AES-CTR,URI="data:text/plain;charset=UTF-16;base64,` + base64.StdEncoding.EncodeToString(pro) + `",KEYFORMAT="com.microsoft.playready",KEYFORMATVERSIONS="1"` + "\n"
+	if len(keys) != 1 || keys[0] != want {
+		t.Errorf("hlsKeys() = %q, want %q", keys, want)
+	}
+}
+
+func TestHLSQuote(t *testing.T) {
+	if got := hlsQuote("director's \"cut\"\r\n"); got != "director's 'cut'''" {
+		t.Errorf("hlsQuote() = %q", got)
+	}
+}
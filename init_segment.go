@@ -0,0 +1,468 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// WriteInitSegment writes the ftyp and moov boxes of the init segment of the
+// track to w and returns the number of bytes written.
+func (p MoovProcessor) WriteInitSegment(w io.Writer) (n int64, err error) {
+	ftyp, moov, err := p.CreateInitMp4Box()
+	if err != nil {
+		return
+	}
+	return writeMp4Boxes(w, ftyp, moov)
+}
+
+// WriteInitSegment writes the ftyp and moov boxes of the init segment of the
+// tracks to w and returns the number of bytes written.
+func (m MultiTrackMoovProcessor) WriteInitSegment(w io.Writer) (n int64, err error) {
+	ftyp, moov, err := m.CreateInitMp4Box()
+	if err != nil {
+		return
+	}
+	return writeMp4Boxes(w, ftyp, moov)
+}
+
+// writeMp4Boxes updates the sizes of the boxes and writes them to w.
+func writeMp4Boxes(w io.Writer, boxes ...mp4.Box) (n int64, err error) {
+	cw := &countingWriter{w: w}
+	for _, box := range boxes {
+		box.Mp4BoxUpdate()
+		if err = box.Mp4BoxWrite(cw); err != nil {
+			break
+		}
+	}
+	n = cw.n
+	return
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.n += int64(n)
+	return
+}
+
+// ParseInitSegment reads an init segment, or any MP4 file such as an ismv
+// file, up to its moov box and returns the configuration of its tracks, see
+// ParseMoovMp4Box, including the brands of the ftyp box. Other boxes
+// preceding the moov box are skipped.
+func ParseInitSegment(r io.Reader) (m MultiTrackMoovProcessor, err error) {
+	var ftyp *mp4.FileTypeBox
+	for {
+		var header *mp4.Header
+		if header, err = mp4.ReadHeader(r); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("no moov box: %w", ErrInvalidParam)
+			}
+			return
+		}
+		if header.Type == mp4.MoovBoxType {
+			var moov mp4.Box
+			if moov, err = readMp4Box(r, header); err != nil {
+				return
+			}
+			if m, err = ParseMoovMp4Box(moov); err != nil {
+				return
+			}
+			if ftyp != nil {
+				for i := range m.Tracks {
+					m.Tracks[i].MajorBrand = ftyp.MajorBrand
+					m.Tracks[i].CompatibleBrands = ftyp.CompatibleBrands
+				}
+			}
+			return
+		}
+		if header.Type == mp4.FtypBoxType {
+			var box mp4.Box
+			if box, err = mp4.ReadBoxAfterHeader(r, header); err != nil {
+				return
+			}
+			ftyp, _ = box.(*mp4.FileTypeBox)
+			continue
+		}
+		if header.Size == 0 {
+			// the box extends to the end of the file
+			err = fmt.Errorf("no moov box before %s box: %w", header.Type, ErrInvalidParam)
+			return
+		}
+		if _, err = skipMp4Box(r, header); err != nil {
+			return
+		}
+	}
+}
+
+// ParseMoovMp4Box extracts the configuration of each track of a moov box: its
+// track ID, codec, picture size or audio format, timescale and duration,
+// language, protection and CodecPrivateData in the form accepted by
+// MoovProcessor. Writing the init segment of the result reproduces an
+// equivalent moov box, and TrackInfo and CodecString describe the tracks.
+func ParseMoovMp4Box(moov mp4.Box) (m MultiTrackMoovProcessor, err error) {
+	if moov.Mp4BoxType() != mp4.MoovBoxType {
+		err = fmt.Errorf("%s box is not a moov box: %w", moov.Mp4BoxType(), ErrInvalidParam)
+		return
+	}
+	var nextTrackID uint32
+	if mvhd, ok := findMp4Box(moov, mp4.MvhdBoxType).(*mp4.MovieHeaderBox); ok {
+		m.Timescale = uint64(mvhd.Timescale)
+		nextTrackID = mvhd.NextTrackID
+	}
+	var fragmentDuration uint64
+	if mehd, ok := findMp4Box(moov, mp4.MvexBoxType, MehdBoxType).(*MovieExtendsHeaderBox); ok {
+		fragmentDuration = mehd.FragmentDuration
+	}
+	title, hasTitle := metadataTitle(moov)
+	trexes := make(map[uint32]*mp4.TrackExtendsBox)
+	if mvex := findMp4Box(moov, mp4.MvexBoxType); mvex != nil {
+		for _, child := range mvex.Mp4BoxChildren() {
+			if trex, ok := child.(*mp4.TrackExtendsBox); ok {
+				trexes[trex.TrackID] = trex
+			}
+		}
+	}
+	var systems []ProtectionSystem
+	for _, child := range moov.Mp4BoxChildren() {
+		if system, ok := psshProtectionSystem(child); ok {
+			systems = append(systems, system)
+		}
+	}
+	for _, child := range moov.Mp4BoxChildren() {
+		if child.Mp4BoxType() != mp4.TrakBoxType {
+			continue
+		}
+		var p MoovProcessor
+		if p, err = parseTrakMp4Box(child); err != nil {
+			return
+		}
+		p.NextTrackID = nextTrackID
+		if m.Timescale > 0 && m.Timescale != p.Timescale {
+			p.MovieTimescale = m.Timescale
+		}
+		if fragmentDuration > 0 {
+			p.EmitMehd = true
+			if p.Duration == 0 {
+				p.Duration = rescaleTime(fragmentDuration, m.Timescale, p.Timescale)
+			}
+		}
+		if hasTitle {
+			p.EmitUserData = true
+			p.Title = title
+		}
+		if trex := trexes[p.TrackID]; trex != nil {
+			flags := trex.DefaultSampleFlags
+			p.DefaultSampleDuration = trex.DefaultSampleDuration
+			p.DefaultSampleFlags = &flags
+		}
+		if p.Protected && len(systems) > 0 {
+			p.SystemID, p.ProtectionInitData = systems[0].SystemID, systems[0].Data
+			p.ProtectionSystems = systems[1:]
+		}
+		m.Tracks = append(m.Tracks, p)
+	}
+	if len(m.Tracks) == 0 {
+		err = fmt.Errorf("moov box has no tracks: %w", ErrInvalidParam)
+	}
+	return
+}
+
+func parseTrakMp4Box(trak mp4.Box) (p MoovProcessor, err error) {
+	tkhd, ok := findMp4Box(trak, mp4.TkhdBoxType).(*mp4.TrackHeaderBox)
+	if !ok {
+		err = fmt.Errorf("trak box has no tkhd: %w", ErrInvalidParam)
+		return
+	}
+	p.TrackID = tkhd.TrackID
+
+	mdhd, ok := findMp4Box(trak, mp4.MdiaBoxType, mp4.MdhdBoxType).(*MediaHeaderBox)
+	if !ok {
+		err = fmt.Errorf("track %d has no mdhd: %w", p.TrackID, ErrInvalidParam)
+		return
+	}
+	p.Timescale = uint64(mdhd.Timescale)
+	p.DurationInTimescale = true
+	if mdhd.Duration != 0xffffffffffffffff {
+		p.Duration = mdhd.Duration
+	}
+	p.Language = mdhd.Language
+
+	if hdlr, ok := findMp4Box(trak, mp4.MdiaBoxType, mp4.HdlrBoxType).(*mp4.HandlerBox); ok {
+		switch hdlr.HandlerType {
+		case mp4.VideFourCC:
+			p.StreamType = VideoStream
+		case mp4.SounFourCC:
+			p.StreamType = AudioStream
+		case SubtFourCC, mp4.MetaFourCC, mp4.FourCC{'t', 'e', 'x', 't'}:
+			p.StreamType = TextStream
+		}
+		p.HandlerName = string(hdlr.Name)
+	}
+
+	if name, ok := metadataTitle(trak); ok {
+		p.EmitUserData = true
+		p.StreamName = name
+	}
+
+	if elst, ok := findMp4Box(trak, EdtsBoxType, ElstBoxType).(*EditListBox); ok {
+		for _, entry := range elst.Entries {
+			if entry.MediaTime < 0 {
+				p.EditEmptyDuration += entry.SegmentDuration
+				continue
+			}
+			p.EditMediaTime = uint64(entry.MediaTime)
+			break
+		}
+	}
+
+	stsd := findMp4Box(trak, mp4.MdiaBoxType, mp4.MinfBoxType, mp4.StblBoxType, mp4.StsdBoxType)
+	if stsd == nil || len(stsd.Mp4BoxChildren()) == 0 {
+		err = fmt.Errorf("track %d has no sample description: %w", p.TrackID, ErrInvalidParam)
+		return
+	}
+	err = p.parseSampleEntry(stsd.Mp4BoxChildren()[0])
+	return
+}
+
+// parseSampleEntry extracts the codec, its configuration and the protection of
+// the track from its sample entry.
+func (p *MoovProcessor) parseSampleEntry(sampleEntry mp4.Box) (err error) {
+	p.Codec = mp4.FourCC(sampleEntry.Mp4BoxType())
+	switch entry := sampleEntry.(type) {
+	case *mp4.VisualSampleEntryBox:
+		p.Width, p.Height = uint32(entry.Width), uint32(entry.Height)
+		p.CompressorName = entry.CompressorName
+	case *AudioSampleEntryBox:
+		p.Channels = entry.ChannelCount
+		p.BitsPerSample = entry.SampleSize
+		p.SamplingRate = entry.SampleRate
+	case *TextMetaDataSampleEntryBox:
+		p.MetadataMIMEType = string(entry.MIMEFormat)
+	}
+	for _, child := range sampleEntry.Mp4BoxChildren() {
+		switch box := child.(type) {
+		case *mp4.AVCConfigurationBox:
+			for _, sps := range box.AVCConfig.SequenceParameterSets {
+				p.CodecPrivateData = appendAnnexB(p.CodecPrivateData, sps.NALUnit)
+			}
+			for _, pps := range box.AVCConfig.PictureParameterSets {
+				p.CodecPrivateData = appendAnnexB(p.CodecPrivateData, pps.NALUnit)
+			}
+		case *mp4.HEVCConfigurationBox:
+			for _, array := range box.HEVCConfig.NaluArrays {
+				for _, nalu := range array.NALUs {
+					p.CodecPrivateData = appendAnnexB(p.CodecPrivateData, nalu)
+				}
+			}
+		case *mp4.DOVIConfigurationBox:
+			config := box.DOVIConfig
+			p.DolbyVision = &config
+		case *ESDBox:
+			p.CodecPrivateData = box.DecoderSpecificInfo
+			p.Bitrate = box.AvgBitrate
+			if p.SamplingRate == 0 {
+				if asc, ascErr := ParseAudioSpecificConfig(box.DecoderSpecificInfo); ascErr == nil {
+					p.SamplingRate = asc.OutputSamplingFrequency()
+				}
+			}
+		case *VC1SpecificBox:
+			p.CodecPrivateData = box.SequenceHeader
+		case *WaveFormatExBox:
+			p.CodecPrivateData = box.WaveFormatEx
+			var wfx WaveFormatEx
+			if wfx, err = ParseWaveFormatEx(box.WaveFormatEx); err != nil {
+				return
+			}
+			p.AudioTag = wfx.FormatTag
+			p.PacketSize = uint32(wfx.BlockAlign)
+			p.Bitrate = wfx.AvgBytesPerSec * 8
+		case *AV1CodecConfigurationBox, *VPCodecConfigurationBox, *AC3SpecificBox, *EC3SpecificBox, *DTSSpecificBox:
+			if p.CodecPrivateData, err = configurationBoxPayload(box); err != nil {
+				return
+			}
+		case *URIBox:
+			p.MetadataURI = string(box.TheURI)
+		case *URIInitBox:
+			p.CodecPrivateData = box.URIInitializationData
+		case *mp4.ColourInformationBox:
+			if box.ColourType == mp4.NclxFourCC {
+				p.Colour = &ColourDescription{
+					ColourPrimaries:         box.ColourPrimaries,
+					TransferCharacteristics: box.TransferCharacteristics,
+					MatrixCoefficients:      box.MatrixCoefficients,
+					FullRange:               box.FullRange,
+				}
+			}
+		case *MasteringDisplayColourVolumeBox:
+			mdcv := box.MasteringDisplayColourVolume
+			p.MasteringDisplay = &mdcv
+		case *ContentLightLevelBox:
+			clli := box.ContentLightLevel
+			p.ContentLightLevel = &clli
+		case *ChannelLayoutBox:
+			p.ChannelLayout = box.DefinedLayout
+		case *mp4.BitRateBox:
+			p.EmitBitRateBox = true
+			p.Bitrate = box.AvgBitrate
+			p.MaxBitrate = box.MaxBitrate
+		case *mp4.ProtectionSchemeInfoBox:
+			p.parseSinfMp4Box(box)
+		}
+	}
+	return
+}
+
+// parseSinfMp4Box extracts the original format, protection scheme and default
+// key of a protected sample entry.
+func (p *MoovProcessor) parseSinfMp4Box(sinf mp4.Box) {
+	p.Protected = true
+	if frma, ok := findMp4Box(sinf, mp4.FrmaBoxType).(*mp4.OriginalFormatBox); ok {
+		p.Codec = frma.DataFormat
+	}
+	if schm, ok := findMp4Box(sinf, mp4.SchmBoxType).(*mp4.SchemeTypeBox); ok {
+		p.EncryptionScheme = schm.SchemeType
+	}
+	var tenc *TrackEncryptionBox
+	switch box := findMp4Box(sinf, mp4.SchiBoxType, mp4.TencBoxType).(type) {
+	case *mp4.TrackEncryptionBox:
+		tenc = newTrackEncryptionBox(box)
+	case *TrackEncryptionBox:
+		// a moov box built by a MoovProcessor rather than read
+		tenc = box
+	}
+	if tenc != nil {
+		p.KID = tenc.DefaultKID
+		p.CryptByteBlock = tenc.DefaultCryptByteBlock
+		p.SkipByteBlock = tenc.DefaultSkipByteBlock
+		p.ConstantIV = tenc.DefaultConstantIV
+	}
+}
+
+// metadataTitle returns the '©nam' item of the iTunes metadata in the user
+// data of a moov or trak box.
+func metadataTitle(box mp4.Box) (title string, ok bool) {
+	data, ok := findMp4Box(box, UdtaBoxType, MetaBoxType, IlstBoxType, NamBoxType, DataBoxType).(*MetadataDataBox)
+	if ok {
+		title = string(data.Value)
+	}
+	return
+}
+
+// localMp4Boxes and localMp4UUIDBoxes are the boxes read with the types of
+// this package rather than those of the mp4 package, which fail to read them.
+// They are not registered in mp4.BoxRegistry, but read by readMp4Box.
+var (
+	localMp4Boxes = map[mp4.BoxType]func() mp4.Box{
+		mp4.MdhdBoxType: func() mp4.Box { return &MediaHeaderBox{} },
+		mp4.StszBoxType: func() mp4.Box { return &SampleSizeBox{} },
+		mp4.SencBoxType: func() mp4.Box { return &SampleEncryptionBox{} },
+	}
+	localMp4UUIDBoxes = map[mp4.UserType]func() mp4.Box{
+		mp4.SampleEncryptionBoxUserType: func() mp4.Box { return &SampleEncryptionBox{} },
+	}
+)
+
+// localMp4BoxContainers are the containers of the mp4 package holding the
+// localMp4Boxes, which have no other fields than their children.
+var localMp4BoxContainers = map[mp4.BoxType]bool{
+	mp4.MoovBoxType: true,
+	mp4.TrakBoxType: true,
+	mp4.MdiaBoxType: true,
+	mp4.MinfBoxType: true,
+	mp4.StblBoxType: true,
+	mp4.MoofBoxType: true,
+	mp4.TrafBoxType: true,
+}
+
+// readMp4Box reads the box of header from r as mp4.ReadBoxAfterHeader does,
+// but reads the localMp4Boxes found in it with the types of this package.
+func readMp4Box(r io.Reader, header *mp4.Header) (box mp4.Box, err error) {
+	newBox := localMp4Boxes[header.Type]
+	if header.Type == mp4.UuidBoxType {
+		newBox = localMp4UUIDBoxes[header.UserType]
+	}
+	if newBox == nil {
+		if !localMp4BoxContainers[header.Type] {
+			return mp4.ReadBoxAfterHeader(r, header)
+		}
+		return readMp4ContainerBox(r, header)
+	}
+	box = newBox()
+	err = box.Mp4BoxRead(r, header)
+	return
+}
+
+// readMp4ContainerBox reads a box of localMp4BoxContainers, reading its
+// children with readMp4Box.
+func readMp4ContainerBox(r io.Reader, header *mp4.Header) (box mp4.Box, err error) {
+	box = mp4.NewBox(header.Type)
+	// the containers only have the header, which is copied without reading r
+	if err = box.(interface {
+		ReadHeader(r io.Reader, header *mp4.Header) error
+	}).ReadHeader(r, header); err != nil {
+		return
+	}
+	for size := int64(header.Size) - int64(header.HeaderSize()); size > 0; {
+		var childHeader *mp4.Header
+		if childHeader, err = mp4.ReadHeader(r); err != nil {
+			return
+		}
+		var child mp4.Box
+		if child, err = readMp4Box(r, childHeader); err != nil {
+			return
+		}
+		if size -= int64(child.Mp4BoxSize()); size < 0 {
+			err = fmt.Errorf("%s box exceeds its %s box: %w", child.Mp4BoxType(), header.Type, ErrInvalidParam)
+			return
+		}
+		if err = box.Mp4BoxAppend(child); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// findMp4Box returns the first box found by descending into the first child
+// of each of the given types, or nil if there is none.
+func findMp4Box(box mp4.Box, path ...mp4.BoxType) mp4.Box {
+	for _, boxType := range path {
+		var found mp4.Box
+		for _, child := range box.Mp4BoxChildren() {
+			if child.Mp4BoxType() == boxType {
+				found = child
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		box = found
+	}
+	return box
+}
+
+// configurationBoxPayload returns the payload of a decoder configuration box
+// without its box header, the inverse of readConfigurationBox.
+func configurationBoxPayload(box mp4.Box) (payload []byte, err error) {
+	box.Mp4BoxUpdate()
+	var buf bytes.Buffer
+	if err = box.Mp4BoxWrite(&buf); err != nil {
+		return
+	}
+	payload = buf.Bytes()[8:]
+	return
+}
+
+// appendAnnexB appends a NAL unit with a four-byte start code, the format of
+// H.264 and HEVC CodecPrivateData.
+func appendAnnexB(data []byte, nalu []byte) []byte {
+	data = append(data, 0, 0, 0, 1)
+	return append(data, nalu...)
+}
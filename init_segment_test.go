@@ -0,0 +1,398 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+	"golang.org/x/text/language"
+)
+
+func TestWriteInitSegment(t *testing.T) {
+	p := MoovProcessor{
+		TrackID: 1, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Timescale: 10000000, Duration: 60,
+		Language:         language.MustParseBase("eng"),
+		CodecPrivateData: decodeHex(t, testH264CodecPrivateData),
+	}
+	var buf bytes.Buffer
+	n, err := p.WriteInitSegment(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteInitSegment() = %d, wrote %d bytes", n, buf.Len())
+	}
+	for _, want := range []mp4.BoxType{mp4.FtypBoxType, mp4.MoovBoxType} {
+		box, err := mp4.ReadBox(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if box.Mp4BoxType() != want {
+			t.Errorf("read %s box, want %s", box.Mp4BoxType(), want)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%d bytes left after the init segment", buf.Len())
+	}
+
+	m := MultiTrackMoovProcessor{Tracks: []MoovProcessor{p}}
+	var multi bytes.Buffer
+	if n, err = m.WriteInitSegment(&multi); err != nil || n != int64(multi.Len()) || n == 0 {
+		t.Errorf("MultiTrackMoovProcessor.WriteInitSegment() = %d, %v, wrote %d bytes", n, err, multi.Len())
+	}
+}
+
+type failingWriter struct {
+	limit int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (n int, err error) {
+	if len(p) > w.limit {
+		n, err = w.limit, errWriteFailed
+	} else {
+		n = len(p)
+	}
+	w.limit -= n
+	return
+}
+
+func TestWriteInitSegmentWriteError(t *testing.T) {
+	p := MoovProcessor{
+		TrackID: 1, Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 2, Timescale: 48000,
+		CodecPrivateData: []byte{0x11, 0x90},
+	}
+	n, err := p.WriteInitSegment(&failingWriter{limit: 10})
+	if !errors.Is(err, errWriteFailed) {
+		t.Errorf("WriteInitSegment() error = %v, want %v", err, errWriteFailed)
+	}
+	if n != 10 {
+		t.Errorf("WriteInitSegment() = %d, want the 10 bytes written", n)
+	}
+	if _, err = (MoovProcessor{Codec: mp4.Avc1FourCC}).WriteInitSegment(&bytes.Buffer{}); err == nil {
+		t.Error("WriteInitSegment() of a track without CodecPrivateData succeeded")
+	}
+}
+
+func TestParseInitSegment(t *testing.T) {
+	kid := [16]byte{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
+	playReady := ProtectionSystem{SystemID: PlayReadySystemID, Data: []byte{1, 2, 3}}
+	widevine := ProtectionSystem{SystemID: WidevineSystemID, Data: []byte{0x08, 0x01}}
+	video := MoovProcessor{
+		TrackID: 1, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Width: 1280, Height: 720,
+		Timescale: 10000000, Duration: 60, Language: language.MustParseBase("und"),
+		CodecPrivateData: decodeHex(t, testH264CodecPrivateData),
+		Protected:        true, KID: kid, EncryptionScheme: CbcsFourCC, ConstantIV: []byte{0, 1, 2, 3, 4, 5, 6, 7},
+		ProtectionSystems: []ProtectionSystem{playReady, widevine},
+	}
+	audio := MoovProcessor{
+		TrackID: 2, Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 2, BitsPerSample: 16,
+		Timescale: 48000, Duration: 60, Language: language.MustParseBase("fr"), Bitrate: 128000,
+		CodecPrivateData: []byte{0x11, 0x90},
+	}
+	hevc := MoovProcessor{
+		TrackID: 1, Codec: mp4.Hvc1FourCC, StreamType: VideoStream, Width: 640, Height: 360,
+		Timescale: 90000, Duration: 30, EmitMehd: true,
+		CodecPrivateData: decodeHex(t, testHEVCCodecPrivateData),
+	}
+	tests := []struct {
+		name   string
+		tracks []MoovProcessor
+		prefix []byte
+	}{
+		{name: "protected H.264", tracks: []MoovProcessor{video}},
+		{name: "AAC", tracks: []MoovProcessor{audio}},
+		{name: "HEVC with mehd", tracks: []MoovProcessor{hevc}},
+		{name: "muxed", tracks: []MoovProcessor{video, audio}},
+		// a free box before the init segment is skipped
+		{name: "leading free box", tracks: []MoovProcessor{audio}, prefix: []byte{0, 0, 0, 12, 'f', 'r', 'e', 'e', 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(append([]byte(nil), tt.prefix...))
+			if _, err := (MultiTrackMoovProcessor{Tracks: tt.tracks}).WriteInitSegment(buf); err != nil {
+				t.Fatal(err)
+			}
+			m, err := ParseInitSegment(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Tracks) != len(tt.tracks) {
+				t.Fatalf("parsed %d tracks, want %d", len(m.Tracks), len(tt.tracks))
+			}
+			for i, got := range m.Tracks {
+				want := tt.tracks[i]
+				if got.TrackID != want.TrackID || got.Codec != want.Codec || got.StreamType != want.StreamType ||
+					got.Timescale != want.Timescale || got.mediaDuration() != want.mediaDuration() || got.Language != want.Language {
+					t.Errorf("track %d = %d %s %v %d %d %s, want %d %s %v %d %d %s", i,
+						got.TrackID, got.Codec, got.StreamType, got.Timescale, got.mediaDuration(), got.Language,
+						want.TrackID, want.Codec, want.StreamType, want.Timescale, want.mediaDuration(), want.Language)
+				}
+				if !bytes.Equal(got.CodecPrivateData, want.CodecPrivateData) {
+					t.Errorf("track %d CodecPrivateData = %x, want %x", i, got.CodecPrivateData, want.CodecPrivateData)
+				}
+				if got.Width != want.Width || got.Height != want.Height ||
+					got.SamplingRate != want.SamplingRate || got.Channels != want.Channels || got.BitsPerSample != want.BitsPerSample {
+					t.Errorf("track %d format = %dx%d %d Hz %d channels %d bits, want %dx%d %d Hz %d channels %d bits", i,
+						got.Width, got.Height, got.SamplingRate, got.Channels, got.BitsPerSample,
+						want.Width, want.Height, want.SamplingRate, want.Channels, want.BitsPerSample)
+				}
+				if !got.DurationInTimescale {
+					t.Errorf("track %d Duration is not in its timescale", i)
+				}
+				if got.EmitMehd != want.EmitMehd {
+					t.Errorf("track %d EmitMehd = %v, want %v", i, got.EmitMehd, want.EmitMehd)
+				}
+				if got.Protected != want.Protected {
+					t.Fatalf("track %d Protected = %v, want %v", i, got.Protected, want.Protected)
+				}
+				if !want.Protected {
+					continue
+				}
+				if got.KID != want.KID || got.EncryptionScheme != want.EncryptionScheme ||
+					got.CryptByteBlock != 1 || got.SkipByteBlock != 9 || !bytes.Equal(got.ConstantIV, want.ConstantIV) {
+					t.Errorf("track %d protection = %x %s %d:%d %x", i, got.KID, got.EncryptionScheme, got.CryptByteBlock, got.SkipByteBlock, got.ConstantIV)
+				}
+				systems := got.protectionSystems()
+				if len(systems) != 2 || systems[0].SystemID != PlayReadySystemID || systems[1].SystemID != WidevineSystemID {
+					t.Errorf("track %d protection systems = %+v, want PlayReady and Widevine", i, systems)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInitSegmentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "no moov box", data: []byte{0, 0, 0, 8, 'f', 'r', 'e', 'e'}},
+		{name: "box to the end of the file", data: []byte{0, 0, 0, 0, 'm', 'd', 'a', 't'}},
+		{name: "box smaller than its header", data: []byte{0, 0, 0, 4, 'f', 'r', 'e', 'e'}},
+		{name: "moov box without tracks", data: []byte{0, 0, 0, 8, 'm', 'o', 'o', 'v'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseInitSegment(bytes.NewReader(tt.data)); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("ParseInitSegment() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+	if _, err := ParseMoovMp4Box(&mp4.FileTypeBox{}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ParseMoovMp4Box() of an ftyp box error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestParseMoovMp4BoxCreatedTenc(t *testing.T) {
+	p := MoovProcessor{
+		TrackID: 1, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Timescale: 10000000,
+		CodecPrivateData: decodeHex(t, testH264CodecPrivateData),
+		Protected:        true, KID: [16]byte{1}, EncryptionScheme: CbcsFourCC, CryptByteBlock: 2, SkipByteBlock: 8,
+	}
+	// the moov box as built, with the tenc box of this package
+	moov, err := p.CreateMoovMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseMoovMp4Box(moov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Tracks[0]; got.KID != p.KID || got.CryptByteBlock != 2 || got.SkipByteBlock != 8 {
+		t.Errorf("parsed KID %x, pattern %d:%d, want %x, 2:8", got.KID, got.CryptByteBlock, got.SkipByteBlock, p.KID)
+	}
+}
+
+func TestParseInitSegmentBrandsAndNames(t *testing.T) {
+	p := MoovProcessor{
+		TrackID: 1, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Timescale: 10000000,
+		CodecPrivateData: decodeHex(t, testH264CodecPrivateData),
+		MajorBrand:       CmfcFourCC, CompatibleBrands: []mp4.FourCC{mp4.Iso6FourCC, CmfcFourCC},
+		HandlerName: "VideoHandler", CompressorName: "x264",
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteInitSegment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseInitSegment(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Tracks[0]
+	if got.MajorBrand != p.MajorBrand || len(got.CompatibleBrands) != 2 || got.CompatibleBrands[1] != CmfcFourCC {
+		t.Errorf("brands = %s %v, want %s %v", got.MajorBrand, got.CompatibleBrands, p.MajorBrand, p.CompatibleBrands)
+	}
+	if got.HandlerName != p.HandlerName || got.CompressorName != p.CompressorName {
+		t.Errorf("handler name %q, compressor name %q, want %q, %q", got.HandlerName, got.CompressorName, p.HandlerName, p.CompressorName)
+	}
+}
+
+func TestParseInitSegmentTrackDefaults(t *testing.T) {
+	flags := SampleFlagsDependsOnNone
+	p := MoovProcessor{
+		TrackID: 7, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Timescale: 90000,
+		CodecPrivateData:      decodeHex(t, testH264CodecPrivateData),
+		DefaultSampleDuration: 3000, DefaultSampleFlags: &flags,
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteInitSegment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseInitSegment(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Tracks[0]
+	if got.DefaultSampleDuration != 3000 || got.DefaultSampleFlags == nil || *got.DefaultSampleFlags != flags {
+		t.Errorf("defaults = %d, %v, want 3000, %#x", got.DefaultSampleDuration, got.DefaultSampleFlags, flags)
+	}
+}
+
+func TestParseInitSegmentUserData(t *testing.T) {
+	video := MoovProcessor{
+		TrackID: 1, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Timescale: 10000000,
+		CodecPrivateData: decodeHex(t, testH264CodecPrivateData),
+		EmitUserData:     true, Title: "Big Buck Bunny", StreamName: "video",
+	}
+	audio := MoovProcessor{
+		TrackID: 2, Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 2, Timescale: 48000,
+		CodecPrivateData: []byte{0x11, 0x90}, Language: language.MustParseBase("fr"),
+		EmitUserData: true, StreamName: "audio_fr",
+	}
+	var buf bytes.Buffer
+	if _, err := (MultiTrackMoovProcessor{Tracks: []MoovProcessor{video, audio}}).WriteInitSegment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseInitSegment(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []MoovProcessor{video, audio} {
+		got := m.Tracks[i]
+		if !got.EmitUserData || got.StreamName != want.StreamName || got.Title != "Big Buck Bunny" {
+			t.Errorf("track %d user data %v, stream name %q, title %q, want true, %q, \"Big Buck Bunny\"",
+				i, got.EmitUserData, got.StreamName, got.Title, want.StreamName)
+		}
+	}
+}
+
+func TestParseInitSegmentChannelLayout(t *testing.T) {
+	p := MoovProcessor{
+		TrackID: 1, Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 6, Timescale: 48000,
+		CodecPrivateData: []byte{0x11, 0xb0}, Language: language.MustParseBase("eng"),
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteInitSegment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseInitSegment(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Tracks[0]; got.ChannelLayout != 6 || got.Channels != 6 {
+		t.Errorf("channel layout %d, channels %d, want 6, 6", got.ChannelLayout, got.Channels)
+	}
+}
+
+func TestParseInitSegmentDTS(t *testing.T) {
+	p := MoovProcessor{
+		TrackID: 1, Codec: DtshFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 8, BitsPerSample: 24,
+		Bitrate: 3018000, Timescale: 48000, Language: language.MustParseBase("eng"),
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteInitSegment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseInitSegment(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Tracks[0]
+	if got.Codec != DtshFourCC || got.Channels != 8 || got.SamplingRate != 48000 {
+		t.Errorf("codec %s, channels %d, sampling rate %d, want dtsh, 8, 48000", got.Codec, got.Channels, got.SamplingRate)
+	}
+	ddts, err := got.CreateDdtsMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := NewDTSSpecificBox(DtshFourCC, 48000, 8, 24, 3018000)
+	if config := ddts.(*DTSSpecificBox); config.ChannelLayout != want.ChannelLayout || config.PCMSampleDepth != 24 || config.AvgBitrate != 3018000 {
+		t.Errorf("ddts %+v, want %+v", *config, *want)
+	}
+}
+
+func TestParseInitSegmentTimedMetadata(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+	}{
+		{name: "mett", processor: MoovProcessor{Codec: MettFourCC, MetadataMIMEType: "text/xml"}},
+		{name: "urim", processor: MoovProcessor{Codec: UrimFourCC, MetadataURI: "urn:scte:scte35:2013:bin", CodecPrivateData: []byte{1, 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.processor
+			p.TrackID, p.StreamType, p.Timescale = 3, TextStream, 10000000
+			p.Language = language.MustParseBase("eng")
+			var buf bytes.Buffer
+			if _, err := p.WriteInitSegment(&buf); err != nil {
+				t.Fatal(err)
+			}
+			m, err := ParseInitSegment(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := m.Tracks[0]
+			if got.Codec != p.Codec || got.StreamType != TextStream || got.MetadataMIMEType != p.MetadataMIMEType ||
+				got.MetadataURI != p.MetadataURI || !bytes.Equal(got.CodecPrivateData, p.CodecPrivateData) {
+				t.Errorf("parsed %s, %v, %q, %q, %x, want %s, %v, %q, %q, %x",
+					got.Codec, got.StreamType, got.MetadataMIMEType, got.MetadataURI, got.CodecPrivateData,
+					p.Codec, TextStream, p.MetadataMIMEType, p.MetadataURI, p.CodecPrivateData)
+			}
+		})
+	}
+}
+
+func TestParseInitSegmentProtectedAudio(t *testing.T) {
+	p := MoovProcessor{
+		TrackID: 2, Codec: Ec3FourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 6, Bitrate: 384000,
+		Timescale: 48000, Language: language.MustParseBase("eng"), Protected: true, KID: [16]byte(testPlayReadyKID),
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteInitSegment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseInitSegment(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Tracks[0]
+	if got.Codec != Ec3FourCC || !got.Protected || got.KID != [16]byte(testPlayReadyKID) || got.Channels != 6 {
+		t.Errorf("parsed codec %s, protected %v, KID %x, channels %d, want ec-3, true, %x, 6",
+			got.Codec, got.Protected, got.KID, got.Channels, testPlayReadyKID)
+	}
+}
+
+func TestParseInitSegmentNextTrackID(t *testing.T) {
+	tracks := []MoovProcessor{
+		{TrackID: 1, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Timescale: 10000000, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)},
+		{TrackID: 1, Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 2, Timescale: 48000,
+			CodecPrivateData: []byte{0x11, 0x90}, Language: language.MustParseBase("eng")},
+	}
+	AssignTrackIDs(tracks)
+	var buf bytes.Buffer
+	if _, err := tracks[1].WriteInitSegment(&buf); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseInitSegment(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Tracks[0]; got.TrackID != 2 || got.NextTrackID != 3 {
+		t.Errorf("track ID %d, next track ID %d, want 2, 3", got.TrackID, got.NextTrackID)
+	}
+}
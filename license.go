@@ -0,0 +1,86 @@
+package smoothstreaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// LicenseClient acquires the content keys of protected content from the
+// license server of a content protection system, e.g. through a PlayReady or
+// Widevine CDM, so that a Downloader decrypts the protected tracks it
+// downloads without knowing about the DRM clients.
+type LicenseClient interface {
+	// AcquireKeys returns the content keys, by KID, licensed for the data of
+	// the pssh box of a protection system of a track, e.g. its PlayReady
+	// header or its Widevine PSSH data. It returns an error wrapping
+	// ErrUnsupportedSystem for the systems it does not handle, the next
+	// protection system of the track being tried then. It is called
+	// concurrently by the Downloader.
+	AcquireKeys(ctx context.Context, system ProtectionSystem) (keys map[[16]byte][]byte, err error)
+}
+
+// keyring holds the content keys acquired from the LicenseClient of a
+// download, so that the tracks sharing a KID, and the refreshes of a live
+// recording, acquire its license once.
+type keyring struct {
+	mu   sync.Mutex
+	keys map[[16]byte][]byte
+}
+
+// decrypter returns the decrypter of the protected track p: the Decrypter of
+// the download with the keys acquired from its License, those of the
+// protection systems of p being acquired unless the key of its KID already
+// is. It is nil if neither is set.
+func (d Downloader) decrypter(ctx context.Context, p MoovProcessor) (decrypter *Decrypter, err error) {
+	if d.License == nil {
+		return d.Decrypter, nil
+	}
+	decrypter = &Decrypter{}
+	if d.Decrypter != nil {
+		*decrypter = *d.Decrypter
+	}
+	if _, ok := decrypter.Keys[p.KID]; !ok {
+		var keys map[[16]byte][]byte
+		if keys, err = d.keys.acquire(ctx, d.License, p); err != nil {
+			return
+		}
+		for kid, key := range decrypter.Keys {
+			keys[kid] = key
+		}
+		decrypter.Keys = keys
+	}
+	return
+}
+
+// acquire returns a copy of the keys acquired so far, once the key of the KID
+// of the protected track p is, acquiring the keys of the protection systems of
+// p in turn from client until one is supported.
+func (k *keyring) acquire(ctx context.Context, client LicenseClient, p MoovProcessor) (keys map[[16]byte][]byte, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[p.KID]; !ok {
+		var acquired map[[16]byte][]byte
+		for _, system := range p.protectionSystems() {
+			if acquired, err = client.AcquireKeys(ctx, system); err == nil || !errors.Is(err, ErrUnsupportedSystem) {
+				break
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("acquire license of KID %x: %w", p.KID, err)
+			return
+		}
+		if k.keys == nil {
+			k.keys = make(map[[16]byte][]byte)
+		}
+		for kid, key := range acquired {
+			k.keys[kid] = key
+		}
+	}
+	keys = make(map[[16]byte][]byte, len(k.keys))
+	for kid, key := range k.keys {
+		keys[kid] = key
+	}
+	return
+}
@@ -0,0 +1,200 @@
+package smoothstreaming
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// testLicenseClient licenses keys for the protection systems it supports,
+// counting the acquisitions.
+type testLicenseClient struct {
+	systems map[uuid.UUID]map[[16]byte][]byte
+	err     error
+
+	mu       sync.Mutex
+	acquired []uuid.UUID
+}
+
+func (c *testLicenseClient) AcquireKeys(ctx context.Context, system ProtectionSystem) (keys map[[16]byte][]byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.acquired = append(c.acquired, system.SystemID)
+	if c.err != nil {
+		return nil, c.err
+	}
+	keys, ok := c.systems[system.SystemID]
+	if !ok {
+		err = ErrUnsupportedSystem
+		return
+	}
+	// the keyring owns the keys returned
+	copied := make(map[[16]byte][]byte, len(keys))
+	for kid, key := range keys {
+		copied[kid] = key
+	}
+	return copied, nil
+}
+
+func TestKeyringAcquire(t *testing.T) {
+	kid := [16]byte(testPlayReadyKID)
+	other := [16]byte{0xff}
+	p := MoovProcessor{Protected: true, KID: kid, ProtectionSystems: []ProtectionSystem{{SystemID: WidevineSystemID}, {SystemID: PlayReadySystemID}}}
+	errLicense := errors.New("license denied")
+	tests := []struct {
+		name         string
+		client       *testLicenseClient
+		wantAcquired []uuid.UUID
+		wantKeys     map[[16]byte][]byte
+		wantErr      error
+	}{
+		{
+			name:         "first system",
+			client:       &testLicenseClient{systems: map[uuid.UUID]map[[16]byte][]byte{WidevineSystemID: {kid: testKey}}},
+			wantAcquired: []uuid.UUID{WidevineSystemID},
+			wantKeys:     map[[16]byte][]byte{kid: testKey},
+		},
+		{
+			name:         "next system",
+			client:       &testLicenseClient{systems: map[uuid.UUID]map[[16]byte][]byte{PlayReadySystemID: {kid: testKey, other: testKey}}},
+			wantAcquired: []uuid.UUID{WidevineSystemID, PlayReadySystemID},
+			wantKeys:     map[[16]byte][]byte{kid: testKey, other: testKey},
+		},
+		{
+			name:         "no supported system",
+			client:       &testLicenseClient{},
+			wantAcquired: []uuid.UUID{WidevineSystemID, PlayReadySystemID},
+			wantErr:      ErrUnsupportedSystem,
+		},
+		{
+			name:         "license error",
+			client:       &testLicenseClient{err: errLicense},
+			wantAcquired: []uuid.UUID{WidevineSystemID},
+			wantErr:      errLicense,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &keyring{}
+			keys, err := k.acquire(context.Background(), tt.client, p)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("acquire() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.client.acquired, tt.wantAcquired) {
+				t.Errorf("acquired %v, want %v", tt.client.acquired, tt.wantAcquired)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("acquire() = %x, want %x", keys, tt.wantKeys)
+			}
+
+			// the license of a KID is acquired once, the keys returned being
+			// copies
+			keys[[16]byte{0xee}] = testKey
+			if keys, err = k.acquire(context.Background(), tt.client, p); err != nil || !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("second acquire() = %x, %v, want %x", keys, err, tt.wantKeys)
+			}
+			if !reflect.DeepEqual(tt.client.acquired, tt.wantAcquired) {
+				t.Errorf("acquired %v after the second acquire, want %v", tt.client.acquired, tt.wantAcquired)
+			}
+		})
+	}
+}
+
+func TestDownloaderDecrypter(t *testing.T) {
+	kid := [16]byte(testPlayReadyKID)
+	other := [16]byte{0xff}
+	p := MoovProcessor{Protected: true, KID: kid, ProtectionSystems: []ProtectionSystem{{SystemID: PlayReadySystemID}}}
+	keyFunc := func(k [16]byte) ([]byte, error) { return nil, ErrKeyNotFound }
+	tests := []struct {
+		name         string
+		decrypter    *Decrypter
+		client       *testLicenseClient
+		wantKeys     map[[16]byte][]byte
+		wantAcquired int
+	}{
+		{name: "none"},
+		{name: "decrypter", decrypter: &Decrypter{Keys: map[[16]byte][]byte{kid: testKey}}, wantKeys: map[[16]byte][]byte{kid: testKey}},
+		{
+			name:         "license",
+			client:       &testLicenseClient{systems: map[uuid.UUID]map[[16]byte][]byte{PlayReadySystemID: {kid: testKey}}},
+			wantKeys:     map[[16]byte][]byte{kid: testKey},
+			wantAcquired: 1,
+		},
+		{
+			name:      "key of the decrypter",
+			decrypter: &Decrypter{Keys: map[[16]byte][]byte{kid: testKey}},
+			client:    &testLicenseClient{systems: map[uuid.UUID]map[[16]byte][]byte{PlayReadySystemID: {kid: testKey}}},
+			wantKeys:  map[[16]byte][]byte{kid: testKey},
+		},
+		{
+			name:         "keys of the decrypter and of the license",
+			decrypter:    &Decrypter{Keys: map[[16]byte][]byte{other: testKey}, KeyFunc: keyFunc},
+			client:       &testLicenseClient{systems: map[uuid.UUID]map[[16]byte][]byte{PlayReadySystemID: {kid: testKey}}},
+			wantKeys:     map[[16]byte][]byte{kid: testKey, other: testKey},
+			wantAcquired: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Downloader{Decrypter: tt.decrypter, keys: &keyring{}}
+			if tt.client != nil {
+				d.License = tt.client
+			}
+			decrypter, err := d.decrypter(context.Background(), p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantKeys == nil {
+				if decrypter != nil {
+					t.Errorf("decrypter() = %+v, want nil", decrypter)
+				}
+				return
+			}
+			if decrypter == nil || !reflect.DeepEqual(decrypter.Keys, tt.wantKeys) {
+				t.Fatalf("decrypter() = %+v, want the keys %x", decrypter, tt.wantKeys)
+			}
+			if tt.decrypter != nil && tt.decrypter.KeyFunc != nil && decrypter.KeyFunc == nil {
+				t.Error("the KeyFunc of the Decrypter is lost")
+			}
+			if tt.client != nil && len(tt.client.acquired) != tt.wantAcquired {
+				t.Errorf("acquired %d licenses, want %d", len(tt.client.acquired), tt.wantAcquired)
+			}
+			// the Decrypter of the Downloader is left unmodified
+			if tt.decrypter != nil && len(tt.decrypter.Keys) != 1 {
+				t.Errorf("the Decrypter of the Downloader has the keys %x", tt.decrypter.Keys)
+			}
+		})
+	}
+}
+
+func TestDownloaderLicense(t *testing.T) {
+	srv, u := testProtectedServer(t)
+	defer srv.Close()
+	client := &testLicenseClient{systems: map[uuid.UUID]map[[16]byte][]byte{PlayReadySystemID: {[16]byte(testPlayReadyKID): testKey}}}
+	// both tracks share the KID, whose license is acquired once
+	tracks, err := Downloader{OutputDir: t.TempDir(), Tracks: TrackSelector{VideoVariants: true}, License: client}.Download(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 {
+		t.Fatalf("downloaded %d tracks, want 2", len(tracks))
+	}
+	for _, track := range tracks {
+		checkDecryptedOutput(t, track.Path)
+	}
+	if len(client.acquired) != 1 {
+		t.Errorf("acquired %d licenses, want 1", len(client.acquired))
+	}
+
+	unsupported := &testLicenseClient{}
+	if _, err = (Downloader{OutputDir: t.TempDir(), License: unsupported}).Download(context.Background(), u); !errors.Is(err, ErrUnsupportedSystem) {
+		t.Errorf("Download() error = %v, want %v", err, ErrUnsupportedSystem)
+	}
+}
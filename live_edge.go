@@ -0,0 +1,126 @@
+package smoothstreaming
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// liveEdge estimates when the fragments of a track of a live presentation
+// become available, so that a fragment requested before the server has it is
+// retried when it is due rather than after the delays of the RetryPolicy.
+//
+// Fragments are published in real time: the fragments following the last
+// fragment known to be available, the anchor, become available once their
+// media is produced, each one its duration after the previous one. The anchor
+// is the last fragment of the manifest fetched that is not in the server
+// buffer of LookaheadCount fragments, or the start of the first one if all
+// are, then the last fragment fetched. The durations of the fragments missing
+// from the timeline, e.g. the last one of the manifest, are taken from the
+// TfrfBox of the fragments fetched, which announce the fragments following
+// them.
+type liveEdge struct {
+	timescale uint64
+
+	mu sync.Mutex
+	// the wall-clock time at which the fragments up to edge were available.
+	available time.Time
+	// the end time of the anchor, in the timescale of the stream.
+	edge uint64
+	// the durations of the fragments announced by the TfrfBox of the
+	// fragments fetched, by time.
+	announced map[uint64]uint64
+}
+
+// newLiveEdge returns the live edge of a track of the stream s of the
+// manifest m, fetched at the given time.
+func newLiveEdge(m *SmoothStreamingMedia, s *StreamIndex, fetched time.Time) (e *liveEdge, err error) {
+	e = &liveEdge{timescale: m.StreamTimeScale(s), announced: make(map[uint64]uint64)}
+	err = e.refresh(m, s, fetched)
+	return
+}
+
+// refresh anchors the live edge to the manifest m, fetched at the given time.
+func (e *liveEdge) refresh(m *SmoothStreamingMedia, s *StreamIndex, fetched time.Time) (err error) {
+	fragments, err := m.StreamTimeline(s)
+	if err != nil {
+		return
+	}
+	var lookahead int
+	if m.LookaheadCount != nil {
+		lookahead = int(*m.LookaheadCount)
+	}
+	switch i := len(fragments) - 1 - lookahead; {
+	case i >= 0:
+		e.advance(fetched, e.end(fragments[i]))
+	case len(fragments) > 0:
+		// all fragments are in the server buffer
+		e.advance(fetched, fragments[0].Time)
+	}
+	return
+}
+
+// fetched advances the live edge to the fragment f fetched at the given time,
+// recording the fragments announced by its TfrfBox.
+func (e *liveEdge) fetched(at time.Time, fragment TimelineFragment, f Fragment) {
+	e.mu.Lock()
+	for _, t := range f.Tracks {
+		if t.Tfrf == nil {
+			continue
+		}
+		for _, announced := range t.Tfrf.Fragments {
+			e.announced[announced.FragmentAbsoluteTime] = announced.FragmentDuration
+		}
+	}
+	e.mu.Unlock()
+	e.advance(at, e.end(fragment))
+}
+
+// advance moves the anchor to the fragments ending at end, available at the
+// given time, unless it is already past them.
+func (e *liveEdge) advance(at time.Time, end uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.available.IsZero() || end > e.edge {
+		e.available, e.edge = at, end
+	}
+}
+
+// end returns the end time of the fragment f, completing its duration with
+// the one announced for it if it is unknown.
+func (e *liveEdge) end(f TimelineFragment) uint64 {
+	if f.Duration == 0 {
+		e.mu.Lock()
+		f.Duration = e.announced[f.Time]
+		e.mu.Unlock()
+	}
+	return f.End()
+}
+
+// due returns the time at which the fragment f is expected to become
+// available, ok reporting whether it is after the anchor. The fragments up to
+// the anchor are already due.
+func (e *liveEdge) due(f TimelineFragment) (at time.Time, ok bool) {
+	end := e.end(f)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.available.IsZero() || end <= e.edge {
+		return
+	}
+	return e.available.Add(time.Duration(rescaleTime(end-e.edge, e.timescale, uint64(time.Second)))), true
+}
+
+// retryDelay returns the delay before the given retry of the request of the
+// fragment f, whose last response was resp, following the policy p: the
+// fragments not yet available are retried when due, others after the delay
+// of p.
+func (e *liveEdge) retryDelay(p RetryPolicy, f TimelineFragment, retry int, resp *http.Response) time.Duration {
+	if e != nil && resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusPreconditionFailed) {
+		if at, ok := e.due(f); ok {
+			if delay := time.Until(at); delay > 0 {
+				return p.capDelay(delay)
+			}
+		}
+	}
+	return p.delay(retry, resp)
+}
@@ -0,0 +1,113 @@
+package smoothstreaming
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveEdgeDue(t *testing.T) {
+	fetched := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	manifest := func(lookahead string, n int) *SmoothStreamingMedia {
+		m, err := ParseManifest([]byte(strings.Replace(testLiveManifest(n), `IsLive="TRUE"`, `IsLive="TRUE"`+lookahead, 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	fragment := func(i int) TimelineFragment {
+		return TimelineFragment{Time: uint64(i) * 20000000, Duration: 20000000}
+	}
+	tests := []struct {
+		name     string
+		m        *SmoothStreamingMedia
+		fragment TimelineFragment
+		want     time.Time
+		wantOK   bool
+	}{
+		{name: "last fragment of the manifest", m: manifest("", 3), fragment: fragment(2)},
+		{name: "next fragment", m: manifest("", 3), fragment: fragment(3), want: fetched.Add(2 * time.Second), wantOK: true},
+		{name: "later fragment", m: manifest("", 3), fragment: fragment(5), want: fetched.Add(6 * time.Second), wantOK: true},
+		{name: "fragment before the lookahead", m: manifest(` LookaheadCount="2"`, 5), fragment: fragment(2)},
+		{name: "fragment in the lookahead", m: manifest(` LookaheadCount="2"`, 5), fragment: fragment(3), want: fetched.Add(2 * time.Second), wantOK: true},
+		{name: "all fragments in the lookahead", m: manifest(` LookaheadCount="2"`, 1), fragment: fragment(0), want: fetched.Add(2 * time.Second), wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := newLiveEdge(tt.m, tt.m.Streams[0], fetched)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at, ok := e.due(tt.fragment)
+			if ok != tt.wantOK || !at.Equal(tt.want) {
+				t.Errorf("due() = %v, %v, want %v, %v", at, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLiveEdgeFetched(t *testing.T) {
+	m, err := ParseManifest([]byte(testLiveManifest(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetched := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	e, err := newLiveEdge(m, m.Streams[0], fetched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fragment announces the next one, whose duration the timeline of a
+	// refreshed manifest lacks
+	f := Fragment{Tracks: []FragmentTrack{{Tfrf: &TfrfBox{Fragments: []TfrfFragment{{FragmentAbsoluteTime: 20000000, FragmentDuration: 30000000}}}}}}
+	e.fetched(fetched.Add(time.Second), TimelineFragment{Time: 0, Duration: 20000000}, f)
+	next := TimelineFragment{Time: 20000000}
+	if at, ok := e.due(next); !ok || !at.Equal(fetched.Add(3*time.Second)) {
+		t.Errorf("due() = %v, %v, want %v, true", at, ok, fetched.Add(3*time.Second))
+	}
+	// a fragment fetched late does not move the anchor back
+	e.fetched(fetched.Add(time.Hour), TimelineFragment{Time: 0, Duration: 20000000}, Fragment{})
+	if at, ok := e.due(next); !ok || !at.Equal(fetched.Add(3*time.Second)) {
+		t.Errorf("due() after fetching an earlier fragment = %v, %v, want %v, true", at, ok, fetched.Add(3*time.Second))
+	}
+	e.fetched(fetched.Add(5*time.Second), next, Fragment{})
+	if _, ok := e.due(next); ok {
+		t.Error("due() of a fetched fragment reports it ahead of the anchor")
+	}
+}
+
+func TestLiveEdgeRetryDelay(t *testing.T) {
+	m, err := ParseManifest([]byte(testLiveManifest(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := newLiveEdge(m, m.Streams[0], time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := RetryPolicy{InitialDelay: 10 * time.Millisecond}
+	next := TimelineFragment{Time: 20000000, Duration: 20000000}
+	tests := []struct {
+		name     string
+		edge     *liveEdge
+		policy   RetryPolicy
+		fragment TimelineFragment
+		status   int
+		wantMin  time.Duration
+		wantMax  time.Duration
+	}{
+		{name: "fragment not yet available", edge: e, policy: policy, fragment: next, status: http.StatusNotFound, wantMin: time.Second, wantMax: 2 * time.Second},
+		{name: "capped", edge: e, policy: RetryPolicy{MaxDelay: 500 * time.Millisecond}, fragment: next, status: http.StatusPreconditionFailed, wantMin: 500 * time.Millisecond, wantMax: 500 * time.Millisecond},
+		{name: "server error", edge: e, policy: policy, fragment: next, status: http.StatusServiceUnavailable, wantMin: 5 * time.Millisecond, wantMax: 10 * time.Millisecond},
+		{name: "available fragment", edge: e, policy: policy, fragment: TimelineFragment{Duration: 20000000}, status: http.StatusNotFound, wantMin: 5 * time.Millisecond, wantMax: 10 * time.Millisecond},
+		{name: "not live", policy: policy, fragment: next, status: http.StatusNotFound, wantMin: 5 * time.Millisecond, wantMax: 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if delay := tt.edge.retryDelay(tt.policy, tt.fragment, 0, resp); delay < tt.wantMin || delay > tt.wantMax {
+				t.Errorf("retryDelay() = %v, want between %v and %v", delay, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
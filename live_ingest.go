@@ -0,0 +1,480 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webdl/mp4"
+	"golang.org/x/text/language"
+)
+
+// LivePublisher pushes the tracks of a live presentation to a Smooth
+// Streaming publishing point, e.g. of IIS Media Services, Azure Media
+// Services or Unified Origin, by the fragmented MP4 live ingest of Smooth
+// Streaming. Each track is sent by its own long-running POST request of
+// chunked transfer encoding to {publishing point}/Streams({stream}), whose
+// body is the header of the stream, its ftyp box, a LiveServerManifestBox
+// describing the track and its moov box, followed by the fragments of the
+// track as they are produced, each with a TfxdBox declaring its time and
+// duration.
+//
+// A stream whose request fails is reconnected as decided by the Retry policy:
+// the header of the stream is sent again by a new request, followed by the
+// last fragment sent before the failure, which may not have reached the
+// publishing point, and the fragment whose sending failed; the publishing
+// point discards the fragments it already has. The timeline of each track
+// continues across the requests; a fragment whose decode time goes back,
+// e.g. after the encoder restarted, is moved to the end of the previous
+// fragment of the track along with the following ones, as publishing points
+// reject a timeline going back.
+type LivePublisher struct {
+	// the HTTP client of the requests, http.DefaultClient if nil. Its Timeout
+	// must be 0, the requests lasting as long as the publication.
+	Client *http.Client
+
+	// the headers added to every request, e.g. User-Agent or Authorization.
+	Header http.Header
+
+	// the policy of the reconnection of the streams, none if zero. The
+	// retries of a stream are counted from the last fragment sent.
+	Retry RetryPolicy
+
+	// the number of following fragments announced by the TfrfBox of each
+	// fragment, no TfrfBox if 0. Each fragment is held back until the ones it
+	// announces are added.
+	Lookahead int
+}
+
+// LivePublication is a presentation pushed to a publishing point by a
+// LivePublisher. Each track is fed its fragments in decode order with
+// AddFragment, the tracks possibly from different goroutines, each from one
+// at a time, and the publication is ended by Close.
+type LivePublication struct {
+	ctx       context.Context
+	publisher LivePublisher
+	streams   []*liveStream
+}
+
+// liveStream is the stream of a track of a LivePublication.
+type liveStream struct {
+	url *url.URL
+
+	// the configuration of the track.
+	moov MoovProcessor
+
+	// the ftyp box, the LiveServerManifestBox and the moov box sent at the
+	// start of each request.
+	header []byte
+
+	// the body of the current request, nil if not connected, and the
+	// outcome of the request once it ended.
+	body *io.PipeWriter
+	done chan liveResponse
+
+	// the fragments held back for the TfrfBox of the preceding ones.
+	pending []Fragment
+
+	// the last fragment sent, sent again after a reconnection as it may not
+	// have reached the publishing point.
+	last []byte
+
+	// the amount added to the decode times of the fragments, and the decode
+	// time following the last fragment, continuing the timeline.
+	shift   uint64
+	next    uint64
+	started bool
+
+	ended bool
+}
+
+// liveResponse is the outcome of a request of a liveStream: its response,
+// whose body is closed, and the error that ended it, if any.
+type liveResponse struct {
+	resp *http.Response
+	err  error
+}
+
+// Publish starts the publication of the tracks to the publishing point, e.g.
+// http://server/live.isml, and returns it to be fed with the fragments of
+// the tracks. The tracks get distinct track IDs, see AssignTrackIDs, and each
+// is sent as the stream named after its stream and its track ID, e.g.
+// Streams(video_1). An empty POST request is sent first, so that a
+// publishing point that rejects the publication fails Publish.
+func (lp LivePublisher) Publish(ctx context.Context, publishingPoint *url.URL, tracks []MoovProcessor) (pub *LivePublication, err error) {
+	if len(tracks) == 0 {
+		err = fmt.Errorf("no tracks: %w", ErrInvalidParam)
+		return
+	}
+	tracks = append([]MoovProcessor(nil), tracks...)
+	AssignTrackIDs(tracks)
+	pub = &LivePublication{ctx: ctx, publisher: lp}
+	for _, p := range tracks {
+		s := &liveStream{moov: p}
+		name := fmt.Sprintf("%s_%d", strings.Join(strings.Fields(p.streamName()), "_"), p.TrackID)
+		s.url = liveStreamURL(publishingPoint, name)
+		if s.header, err = liveStreamHeader(p, name); err != nil {
+			pub = nil
+			err = fmt.Errorf("track %d: %w", p.TrackID, err)
+			return
+		}
+		pub.streams = append(pub.streams, s)
+	}
+
+	for retry := 0; ; retry++ {
+		var resp *http.Response
+		if resp, err = lp.post(ctx, pub.streams[0].url, http.NoBody); err == nil {
+			break
+		}
+		if !lp.retry(ctx, retry, resp) {
+			pub = nil
+			return
+		}
+	}
+	return
+}
+
+// liveStreamURL returns the URL of the stream of the given name of the
+// publishing point.
+func liveStreamURL(publishingPoint *url.URL, name string) *url.URL {
+	u := *publishingPoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/Streams(" + name + ")"
+	u.RawPath = ""
+	return &u
+}
+
+// liveStreamHeader returns the header of the stream of the given name of the
+// track p: the ftyp box of the isml brand, the LiveServerManifestBox and the
+// moov box of the track, without duration.
+func liveStreamHeader(p MoovProcessor, name string) (header []byte, err error) {
+	if p.MajorBrand == (mp4.FourCC{}) {
+		p.MajorBrand = IsmlFourCC
+		p.CompatibleBrands = []mp4.FourCC{PiffFourCC, mp4.Iso2FourCC}
+	}
+	p.Duration, p.EmitMehd = 0, false
+	ftyp, moov, err := p.CreateInitMp4Box()
+	if err != nil {
+		return
+	}
+	manifest, err := liveServerManifest(p, name)
+	if err != nil {
+		return
+	}
+	var b bytes.Buffer
+	if _, err = writeMp4Boxes(&b, ftyp, &LiveServerManifestBox{Manifest: manifest}, moov); err != nil {
+		return
+	}
+	header = b.Bytes()
+	return
+}
+
+// liveServerManifest returns the SMIL document of the LiveServerManifestBox
+// of the stream of the given name of the track p, declaring the track with
+// the attributes of its QualityLevel element in a client manifest.
+func liveServerManifest(p MoovProcessor, name string) (data []byte, err error) {
+	element, ok := smilTrackElement(p.StreamType)
+	if !ok {
+		err = fmt.Errorf("stream type %q: %w", p.StreamType, ErrInvalidParam)
+		return
+	}
+	t, err := smoothTrack(0, p.Bitrate, p)
+	if err != nil {
+		return
+	}
+	track := &ServerManifestTrack{XMLName: xml.Name{Local: element}, Src: name, SystemBitrate: p.Bitrate}
+	if p.Language != (language.Base{}) {
+		track.SystemLanguage = p.Language.String()
+	}
+	param := func(name, value string) {
+		track.Params = append(track.Params, ServerManifestParam{Name: name, Value: value, ValueType: smilParamValueTypeData})
+	}
+	param(smilTrackIDParam, strconv.FormatUint(uint64(p.TrackID), 10))
+	param(smilTrackNameParam, p.streamName())
+	param("timescale", strconv.FormatUint(p.Timescale, 10))
+	param("FourCC", *t.FourCC)
+	param("CodecPrivateData", strings.ToUpper(hex.EncodeToString(p.CodecPrivateData)))
+	if t.MaxWidth != nil {
+		param("MaxWidth", strconv.FormatUint(uint64(*t.MaxWidth), 10))
+		param("MaxHeight", strconv.FormatUint(uint64(*t.MaxHeight), 10))
+	}
+	if t.SamplingRate != nil {
+		param("SamplingRate", strconv.FormatUint(uint64(*t.SamplingRate), 10))
+		param("Channels", strconv.FormatUint(uint64(*t.Channels), 10))
+		param("BitsPerSample", strconv.FormatUint(uint64(*t.BitsPerSample), 10))
+		param("PacketSize", strconv.FormatUint(uint64(*t.PacketSize), 10))
+		param("AudioTag", strconv.FormatUint(uint64(*t.AudioTag), 10))
+	}
+	if p.StreamType == TextStream {
+		param("Subtype", "SUBT")
+	}
+	m := &ServerManifest{XMLNS: smilNamespace}
+	m.Switch.Tracks = []*ServerManifestTrack{track}
+	return m.Bytes()
+}
+
+// AddFragment adds a fragment of the track of the given index in the tracks
+// of Publish, and sends the fragments that are due. The fragment must hold
+// the track alone, have a decode time, see FragmentRewriter, and follow the
+// preceding fragments of the track. Its TfxdBox and TfrfBox, if any, are
+// replaced. A failed AddFragment may be followed by further fragments, the
+// stream being reconnected.
+func (pub *LivePublication) AddFragment(track int, f Fragment) (err error) {
+	s, err := pub.stream(track)
+	if err != nil {
+		return
+	}
+	if len(f.Tracks) != 1 {
+		err = fmt.Errorf("track %d fragment of %d tracks: %w", track, len(f.Tracks), ErrInvalidParam)
+		return
+	}
+	t := &f.Tracks[0]
+	decodeTime, ok := t.DecodeTime()
+	if !ok {
+		err = fmt.Errorf("track %d fragment has no decode time: %w", track, ErrInvalidParam)
+		return
+	}
+	t.Header.TrackID = s.moov.TrackID
+	samples, err := t.samples(s.moov)
+	if err != nil {
+		return
+	}
+	if s.started && decodeTime+s.shift < s.next {
+		s.shift = s.next - decodeTime
+	}
+	decodeTime += s.shift
+	if _, err = t.setDecodeTime(decodeTime); err != nil {
+		return
+	}
+	tfxd := &TfxdBox{FragmentAbsoluteTime: decodeTime}
+	for _, sample := range samples {
+		tfxd.FragmentDuration += uint64(sample.duration)
+	}
+	if err = t.setLiveTiming(tfxd, nil); err != nil {
+		return
+	}
+	s.started, s.next = true, decodeTime+tfxd.FragmentDuration
+	s.pending = append(s.pending, f)
+	err = pub.flush(s, false)
+	return
+}
+
+// EndTrack sends the held back fragments of the track of the given index,
+// followed by an empty mfra box marking the end of its stream, and ends its
+// request. A request failing at its end is reconnected to send the end of
+// the stream again.
+func (pub *LivePublication) EndTrack(track int) (err error) {
+	s, err := pub.stream(track)
+	if err != nil {
+		return
+	}
+	s.ended = true
+	if err = pub.flush(s, true); err != nil || !s.started {
+		return
+	}
+	mfra := &MovieFragmentRandomAccessBox{}
+	mfro := &MovieFragmentRandomAccessOffsetBox{}
+	if err = mfra.Mp4BoxReplaceChildren([]mp4.Box{mfro}); err != nil {
+		return
+	}
+	mfro.MfraSize = mfra.Mp4BoxUpdate()
+	var b bytes.Buffer
+	if _, err = writeMp4Boxes(&b, mfra); err != nil {
+		return
+	}
+	for retry := 0; ; retry++ {
+		if err = pub.send(s, b.Bytes()); err != nil {
+			return
+		}
+		s.body.Close()
+		r := <-s.done
+		s.body, s.done = nil, nil
+		if err = r.err; err == nil || !pub.publisher.retry(pub.ctx, retry, r.resp) {
+			return
+		}
+	}
+}
+
+// Close ends the tracks that have not ended, see EndTrack, and returns the
+// first error.
+func (pub *LivePublication) Close() (err error) {
+	for i, s := range pub.streams {
+		if s.ended {
+			continue
+		}
+		if endErr := pub.EndTrack(i); err == nil {
+			err = endErr
+		}
+	}
+	return
+}
+
+// stream returns the stream of the track of the given index, which must not
+// have ended.
+func (pub *LivePublication) stream(track int) (s *liveStream, err error) {
+	if track < 0 || track >= len(pub.streams) {
+		err = fmt.Errorf("track %d of %d: %w", track, len(pub.streams), ErrInvalidParam)
+		return
+	}
+	if s = pub.streams[track]; s.ended {
+		err = fmt.Errorf("track %d ended: %w", track, ErrInvalidParam)
+	}
+	return
+}
+
+// flush sends the pending fragments of the stream whose following fragments
+// are known, or all of them, each with the TfrfBox announcing the following
+// ones.
+func (pub *LivePublication) flush(s *liveStream, all bool) (err error) {
+	lookahead := pub.publisher.Lookahead
+	for len(s.pending) > lookahead || all && len(s.pending) > 0 {
+		f := s.pending[0]
+		if lookahead > 0 && len(s.pending) > 1 {
+			tfrf := &TfrfBox{}
+			for _, next := range s.pending[1:] {
+				if len(tfrf.Fragments) == lookahead {
+					break
+				}
+				tfxd := next.Tracks[0].Tfxd
+				tfrf.Fragments = append(tfrf.Fragments, TfrfFragment{FragmentAbsoluteTime: tfxd.FragmentAbsoluteTime, FragmentDuration: tfxd.FragmentDuration})
+			}
+			if err = f.Tracks[0].setLiveTiming(f.Tracks[0].Tfxd, tfrf); err != nil {
+				return
+			}
+		}
+		var b bytes.Buffer
+		if _, err = f.WriteTo(&b); err != nil {
+			return
+		}
+		if err = pub.send(s, b.Bytes()); err != nil {
+			return
+		}
+		s.pending, s.last = s.pending[1:], b.Bytes()
+	}
+	return
+}
+
+// send sends data on the stream, connecting it first if need be, and
+// reconnecting it as decided by the Retry policy if its request fails.
+func (pub *LivePublication) send(s *liveStream, data []byte) (err error) {
+	lp := pub.publisher
+	for retry := 0; ; retry++ {
+		if s.body == nil {
+			pub.connect(s)
+			if _, err = s.body.Write(s.header); err == nil && s.last != nil {
+				_, err = s.body.Write(s.last)
+			}
+		}
+		if err == nil {
+			if _, err = s.body.Write(data); err == nil {
+				return
+			}
+		}
+		// the request failed, its response tells why
+		s.body.CloseWithError(err)
+		r := <-s.done
+		s.body, s.done = nil, nil
+		if r.err != nil {
+			err = r.err
+		}
+		if !lp.retry(pub.ctx, retry, r.resp) {
+			return
+		}
+	}
+}
+
+// connect starts the request of the stream, whose body is then written to
+// s.body.
+func (pub *LivePublication) connect(s *liveStream) {
+	r, w := io.Pipe()
+	done := make(chan liveResponse, 1)
+	s.body, s.done = w, done
+	go func() {
+		resp, err := pub.publisher.post(pub.ctx, s.url, r)
+		if err != nil {
+			r.CloseWithError(err)
+			done <- liveResponse{resp: resp, err: err}
+			return
+		}
+		// a request ended by the server before the end of its body is
+		// reconnected like a broken connection
+		r.CloseWithError(fmt.Errorf("POST %s: ended by the server: %w", s.url, ErrUnexpectedStatus))
+		done <- liveResponse{}
+	}()
+}
+
+// post sends a POST request of the given body to u with the Header, and
+// returns its response, whose body is read and closed. A response of a status
+// other than 200 is returned along with an error.
+func (lp LivePublisher) post(ctx context.Context, u *url.URL, body io.Reader) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), body)
+	if err != nil {
+		return
+	}
+	if body != http.NoBody {
+		// of unknown length, sent in chunks
+		req.ContentLength = -1
+	}
+	for name, values := range lp.Header {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	if host := lp.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	client := lp.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if resp, err = client.Do(req); err != nil {
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("POST %s: %s: %w", u, resp.Status, ErrUnexpectedStatus)
+	}
+	return
+}
+
+// retry waits before the given retry, counted from 0, of a request whose last
+// response, nil after a network error, was resp, and reports whether the
+// request is retried.
+func (lp LivePublisher) retry(ctx context.Context, retry int, resp *http.Response) bool {
+	if retry >= lp.Retry.MaxRetries || ctx.Err() != nil || resp != nil && !lp.Retry.retryStatus(resp.StatusCode) {
+		return false
+	}
+	timer := time.NewTimer(lp.Retry.delay(retry, resp))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// setLiveTiming sets the TfxdBox and the TfrfBox of the track fragment,
+// replacing the ones it has; no TfrfBox if tfrf is nil.
+func (t *FragmentTrack) setLiveTiming(tfxd *TfxdBox, tfrf *TfrfBox) error {
+	var children []mp4.Box
+	for _, child := range t.Traf.Mp4BoxChildren() {
+		switch child.(type) {
+		case *TfxdBox, *TfrfBox:
+			continue
+		}
+		children = append(children, child)
+	}
+	children = append(children, tfxd)
+	if tfrf != nil {
+		children = append(children, tfrf)
+	}
+	t.Tfxd, t.Tfrf = tfxd, tfrf
+	return t.Traf.Mp4BoxReplaceChildren(children)
+}
@@ -0,0 +1,335 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/mp4"
+	"golang.org/x/text/language"
+)
+
+// testPublishingPoint records the bodies of the stream requests pushed to it.
+type testPublishingPoint struct {
+	mu sync.Mutex
+	// the status of every request, 200 if 0.
+	status int
+	// the number of stream requests of each path answered 503 once their
+	// body is read.
+	fail map[string]int
+	// the bodies of the stream requests of each path, and the number of
+	// empty requests.
+	bodies map[string][][]byte
+	probes int
+}
+
+func (pp *testPublishingPoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if pp.status != 0 {
+		w.WriteHeader(pp.status)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if err != nil || len(body) == 0 {
+		pp.probes++
+		return
+	}
+	if pp.bodies == nil {
+		pp.bodies = make(map[string][][]byte)
+	}
+	pp.bodies[r.URL.Path] = append(pp.bodies[r.URL.Path], body)
+	if pp.fail[r.URL.Path] > 0 {
+		pp.fail[r.URL.Path]--
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// testLiveStream is a stream request body pushed to a testPublishingPoint.
+type testLiveStream struct {
+	brand     string
+	manifest  *ServerManifest
+	moov      MultiTrackMoovProcessor
+	fragments []Fragment
+	ended     bool
+}
+
+func parseTestLiveStream(t *testing.T, data []byte) (s testLiveStream) {
+	t.Helper()
+	for offset := 0; offset < len(data); {
+		r := bytes.NewReader(data[offset:])
+		header, err := mp4.ReadHeader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := int(header.Size)
+		switch header.Type {
+		case mp4.FtypBoxType:
+			s.brand = string(data[offset+8 : offset+12])
+		case mp4.UuidBoxType:
+			box, err := readMp4Box(r, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lsm, ok := box.(*LiveServerManifestBox)
+			if !ok {
+				t.Fatalf("uuid box %T, want *LiveServerManifestBox", box)
+			}
+			if s.manifest, err = ParseServerManifest(lsm.Manifest); err != nil {
+				t.Fatal(err)
+			}
+		case mp4.MoovBoxType:
+			box, err := readMp4Box(r, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.moov, err = ParseMoovMp4Box(box); err != nil {
+				t.Fatal(err)
+			}
+		case mp4.MoofBoxType:
+			// the fragment ends with the following mdat box
+			mdat, err := mp4.ReadHeader(bytes.NewReader(data[offset+size:]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			size += int(mdat.Size)
+			f, err := ParseFragment(bytes.NewReader(data[offset : offset+size]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.fragments = append(s.fragments, f)
+		case MfraBoxType:
+			s.ended = true
+		}
+		offset += size
+	}
+	return
+}
+
+// testLiveFragment returns a testFragment with a TfxdBox of the given time.
+func testLiveFragment(t *testing.T, time uint64) Fragment {
+	t.Helper()
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), time)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// testLiveTracks returns a video track and an English audio track.
+func testLiveTracks(t *testing.T) []MoovProcessor {
+	return []MoovProcessor{
+		{Timescale: 10000000, StreamType: VideoStream, Codec: mp4.Avc1FourCC, Width: 1280, Height: 720, Bitrate: 1000000, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)},
+		{Timescale: 48000, StreamType: AudioStream, Codec: Mp4aFourCC, SamplingRate: 48000, Channels: 2, Bitrate: 128000, Language: language.MustParseBase("en"), CodecPrivateData: decodeHex(t, testAACCodecPrivateData)},
+	}
+}
+
+func TestLivePublisherPublish(t *testing.T) {
+	pp := &testPublishingPoint{}
+	srv := httptest.NewServer(pp)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/live.isml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := LivePublisher{Lookahead: 1}.Publish(context.Background(), u, testLiveTracks(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the encoder restarts before the third video fragment
+	for _, time := range []uint64{0, 2000, 0} {
+		if err = pub.AddFragment(0, testLiveFragment(t, time)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, time := range []uint64{96000, 98000} {
+		if err = pub.AddFragment(1, testLiveFragment(t, time)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = pub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = pub.AddFragment(0, testLiveFragment(t, 6000)); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("AddFragment() to an ended track error = %v, want %v", err, ErrInvalidParam)
+	}
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if pp.probes != 1 || len(pp.bodies) != 2 {
+		t.Fatalf("got %d empty requests and the streams %v, want 1 and 2", pp.probes, len(pp.bodies))
+	}
+	tests := []struct {
+		path      string
+		element   string
+		trackID   uint32
+		language  string
+		params    map[string]string
+		wantTimes []uint64
+	}{
+		{
+			path:      "/live.isml/Streams(video_1)",
+			element:   "video",
+			trackID:   1,
+			params:    map[string]string{"trackName": "video", "timescale": "10000000", "FourCC": "H264", "MaxWidth": "1280"},
+			wantTimes: []uint64{0, 2000, 4000},
+		},
+		{
+			path:      "/live.isml/Streams(audio_en_2)",
+			element:   "audio",
+			trackID:   2,
+			language:  "en",
+			params:    map[string]string{"trackName": "audio_en", "FourCC": "AACL", "SamplingRate": "48000", "CodecPrivateData": "1190", "AudioTag": "255"},
+			wantTimes: []uint64{96000, 98000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if len(pp.bodies[tt.path]) != 1 {
+				t.Fatalf("got %d requests, want 1", len(pp.bodies[tt.path]))
+			}
+			s := parseTestLiveStream(t, pp.bodies[tt.path][0])
+			if s.brand != "isml" || !s.ended || s.manifest == nil || len(s.moov.Tracks) != 1 || s.moov.Tracks[0].TrackID != tt.trackID {
+				t.Fatalf("stream of brand %s, ended %v, manifest %v, moov %+v", s.brand, s.ended, s.manifest, s.moov)
+			}
+			if len(s.manifest.Switch.Tracks) != 1 {
+				t.Fatalf("LiveServerManifestBox of %d tracks, want 1", len(s.manifest.Switch.Tracks))
+			}
+			track := s.manifest.Switch.Tracks[0]
+			if track.XMLName.Local != tt.element || track.SystemLanguage != tt.language {
+				t.Errorf("manifest track %+v", track)
+			}
+			if trackID, _ := track.TrackID(); trackID != tt.trackID {
+				t.Errorf("manifest trackID %d, want %d", trackID, tt.trackID)
+			}
+			for name, want := range tt.params {
+				if got, _ := track.Param(name); got != want {
+					t.Errorf("param %s = %q, want %q", name, got, want)
+				}
+			}
+
+			if len(s.fragments) != len(tt.wantTimes) {
+				t.Fatalf("got %d fragments, want %d", len(s.fragments), len(tt.wantTimes))
+			}
+			for i, f := range s.fragments {
+				ft := f.Tracks[0]
+				if decodeTime, _ := ft.DecodeTime(); ft.Header.TrackID != tt.trackID || decodeTime != tt.wantTimes[i] {
+					t.Errorf("fragment %d of track %d at %d, want %d", i, ft.Header.TrackID, decodeTime, tt.wantTimes[i])
+				}
+				if ft.Tfxd == nil || ft.Tfxd.FragmentAbsoluteTime != tt.wantTimes[i] || ft.Tfxd.FragmentDuration != 2000 {
+					t.Errorf("fragment %d tfxd %+v", i, ft.Tfxd)
+				}
+				// each fragment announces the following one
+				if i+1 < len(tt.wantTimes) {
+					if ft.Tfrf == nil || len(ft.Tfrf.Fragments) != 1 || ft.Tfrf.Fragments[0].FragmentAbsoluteTime != tt.wantTimes[i+1] {
+						t.Errorf("fragment %d tfrf %+v, want the next fragment", i, ft.Tfrf)
+					}
+				} else if ft.Tfrf != nil {
+					t.Errorf("last fragment tfrf %+v, want none", ft.Tfrf)
+				}
+			}
+		})
+	}
+}
+
+func TestLivePublisherReconnect(t *testing.T) {
+	const path = "/live.isml/Streams(video_1)"
+	pp := &testPublishingPoint{fail: map[string]int{path: 1}}
+	srv := httptest.NewServer(pp)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/live.isml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := LivePublisher{Retry: RetryPolicy{MaxRetries: 1, InitialDelay: time.Millisecond}}
+	pub, err := lp.Publish(context.Background(), u, testLiveTracks(t)[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, time := range []uint64{0, 2000} {
+		if err = pub.AddFragment(0, testLiveFragment(t, time)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = pub.EndTrack(0); err != nil {
+		t.Fatal(err)
+	}
+
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if len(pp.bodies[path]) != 2 {
+		t.Fatalf("got %d requests, want 2", len(pp.bodies[path]))
+	}
+	// the stream fails at its end: the header and the last fragment are sent
+	// again before the end of the stream
+	s := parseTestLiveStream(t, pp.bodies[path][1])
+	if s.manifest == nil || len(s.moov.Tracks) != 1 || !s.ended {
+		t.Fatalf("reconnected stream manifest %v, moov %+v, ended %v", s.manifest, s.moov, s.ended)
+	}
+	if len(s.fragments) != 1 || s.fragments[0].Tracks[0].Tfxd.FragmentAbsoluteTime != 2000 {
+		t.Errorf("reconnected stream of %d fragments, want the last one", len(s.fragments))
+	}
+}
+
+func TestLivePublisherPublishErrors(t *testing.T) {
+	pp := &testPublishingPoint{status: http.StatusForbidden}
+	srv := httptest.NewServer(pp)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/live.isml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := LivePublisher{Retry: RetryPolicy{MaxRetries: 2, InitialDelay: time.Millisecond}}
+	if pub, err := lp.Publish(context.Background(), u, testLiveTracks(t)); !errors.Is(err, ErrUnexpectedStatus) || pub != nil {
+		t.Errorf("Publish() to a rejecting publishing point = %v, %v, want %v", pub, err, ErrUnexpectedStatus)
+	}
+	if _, err = lp.Publish(context.Background(), u, nil); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Publish() of no tracks error = %v, want %v", err, ErrInvalidParam)
+	}
+	tracks := testLiveTracks(t)
+	tracks[0].StreamType = ""
+	if _, err = lp.Publish(context.Background(), u, tracks); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Publish() of a track without stream type error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestLivePublicationAddFragmentErrors(t *testing.T) {
+	srv := httptest.NewServer(&testPublishingPoint{})
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/live.isml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := LivePublisher{}.Publish(context.Background(), u, testLiveTracks(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+	untimed, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		track int
+		f     Fragment
+	}{
+		{name: "track index", track: 2, f: testLiveFragment(t, 0)},
+		{name: "no decode time", track: 0, f: untimed},
+		{name: "no tracks", track: 0, f: Fragment{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pub.AddFragment(tt.track, tt.f); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("AddFragment() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
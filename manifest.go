@@ -0,0 +1,60 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// ParseManifest parses a Manifest Response message. Servers send the manifest
+// in UTF-8 or, as IIS does, in UTF-16 with a byte order mark.
+func ParseManifest(data []byte) (m *SmoothStreamingMedia, err error) {
+	m = &SmoothStreamingMedia{}
+	if err = decodeManifest(data, m); err != nil {
+		m = nil
+	}
+	return
+}
+
+// decodeManifest decodes the XML document of a manifest into v, in UTF-8 or in
+// UTF-16 with a byte order mark.
+func decodeManifest(data []byte, v interface{}) (err error) {
+	var text string
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		text, err = decodeUTF16(data[2:], binary.LittleEndian)
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		text, err = decodeUTF16(data[2:], binary.BigEndian)
+	default:
+		text = strings.TrimPrefix(string(data), "\ufeff")
+	}
+	if err != nil {
+		return
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	// the text is already decoded, regardless of the encoding declaration
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err = decoder.Decode(v); err != nil {
+		err = fmt.Errorf("invalid manifest: %v: %w", err, ErrInvalidParam)
+	}
+	return
+}
+
+// decodeUTF16 decodes UTF-16 text of the given byte order.
+func decodeUTF16(data []byte, order binary.ByteOrder) (text string, err error) {
+	if len(data)%2 != 0 {
+		err = fmt.Errorf("UTF-16 text of odd length: %w", ErrInvalidParam)
+		return
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	text = string(utf16.Decode(units))
+	return
+}
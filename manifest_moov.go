@@ -0,0 +1,98 @@
+package smoothstreaming
+
+import (
+	"fmt"
+
+	"golang.org/x/text/language"
+)
+
+// MoovProcessor returns the configuration of the init segment of a track of
+// the given stream of the presentation: its codec, picture size or audio
+// format, timescale and duration taken from the manifest, and its protection
+// taken from the Protection element. The default KID of a protected track is
+// the first KID of its PlayReady header, if it has one, see
+// DiscoverProtection otherwise. The track ID is 1.
+func (m *SmoothStreamingMedia) MoovProcessor(s *StreamIndex, t *Track) (p MoovProcessor, err error) {
+	if s == nil || t == nil {
+		err = fmt.Errorf("stream or track is nil: %w", ErrInvalidParam)
+		return
+	}
+	p = MoovProcessor{
+		TrackID:             1,
+		Timescale:           m.StreamTimeScale(s),
+		DurationInTimescale: true,
+		CodecPrivateData:    t.CodecPrivateData,
+		Bitrate:             t.Bitrate,
+		CustomAttributes:    t.CustomAttributes,
+		StreamType:          s.Type,
+		StreamName:          s.streamName(),
+	}
+	if p.Codec, err = s.SampleEntryCodec(t); err != nil {
+		return
+	}
+	p.Duration = rescaleTime(m.Duration, m.PresentationTimeScale(), p.Timescale)
+	if s.Language != nil {
+		// an unknown language is left undetermined rather than failing
+		if base, err := language.ParseBase(*s.Language); err == nil {
+			p.Language = base
+		}
+	}
+	if t.FourCC != nil {
+		p.AudioObjectType = AudioObjectTypeForFourCC(*t.FourCC)
+	}
+	switch {
+	case t.MaxWidth != nil && t.MaxHeight != nil:
+		p.Width, p.Height = *t.MaxWidth, *t.MaxHeight
+	case s.MaxWidth != nil && s.MaxHeight != nil:
+		p.Width, p.Height = *s.MaxWidth, *s.MaxHeight
+	}
+	if t.SamplingRate != nil {
+		p.SamplingRate = *t.SamplingRate
+	}
+	if t.Channels != nil {
+		p.Channels = *t.Channels
+	}
+	if t.BitsPerSample != nil {
+		p.BitsPerSample = *t.BitsPerSample
+	}
+	if t.AudioTag != nil {
+		p.AudioTag = uint16(*t.AudioTag)
+	}
+	if t.PacketSize != nil {
+		p.PacketSize = *t.PacketSize
+	}
+	if m.Protection != nil && len(m.Protection.ProtectionHeaders) > 0 {
+		err = m.setProtection(&p)
+	}
+	return
+}
+
+// setProtection marks the track p as protected by the protection systems of
+// the Protection element.
+func (m *SmoothStreamingMedia) setProtection(p *MoovProcessor) (err error) {
+	systems, err := m.Protection.ProtectionSystems()
+	if err != nil {
+		return
+	}
+	p.Protected = true
+	p.SystemID, p.ProtectionInitData = systems[0].SystemID, systems[0].Data
+	p.ProtectionSystems = systems[1:]
+	for _, h := range m.Protection.ProtectionHeaders {
+		if h.SystemID != PlayReadySystemID {
+			continue
+		}
+		var header PlayReadyHeader
+		if header, err = h.PlayReadyHeader(); err != nil {
+			return
+		}
+		if len(header.KIDs) == 0 {
+			continue
+		}
+		p.KID = header.KIDs[0].KID
+		if header.KIDs[0].AlgID == PlayReadyAlgIDAESCBC {
+			p.EncryptionScheme = CbcsFourCC
+		}
+		return
+	}
+	return
+}
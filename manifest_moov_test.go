@@ -0,0 +1,137 @@
+package smoothstreaming
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+	"golang.org/x/text/language"
+)
+
+func TestSmoothStreamingMediaMoovProcessor(t *testing.T) {
+	protection := func(header string) string {
+		pro := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(header)}}}.Bytes()
+		return `<Protection><ProtectionHeader SystemID="9a04f079-9840-4286-ab92-e65be0885f95">` + base64.StdEncoding.EncodeToString(pro) + `</ProtectionHeader></Protection>`
+	}
+	manifest := func(protection string) string {
+		return `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+			`<StreamIndex Type="video" Name="video" TimeScale="90000" MaxWidth="1920" MaxHeight="1080">` +
+			`<QualityLevel Index="0" Bitrate="1000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+			`<QualityLevel Index="1" Bitrate="500" FourCC="H264" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+			`</StreamIndex>` +
+			`<StreamIndex Type="audio" Name="audio_eng" Language="en">` +
+			`<QualityLevel Index="0" Bitrate="128000" FourCC="AACL" SamplingRate="48000" Channels="2" BitsPerSample="16" PacketSize="4" AudioTag="255" CodecPrivateData="1190"/>` +
+			`</StreamIndex>` +
+			protection + `</SmoothStreamingMedia>`
+	}
+	tests := []struct {
+		name     string
+		manifest string
+		stream   int
+		track    int
+		check    func(t *testing.T, p MoovProcessor)
+	}{
+		{
+			name:     "video track size",
+			manifest: manifest(""),
+			check: func(t *testing.T, p MoovProcessor) {
+				if p.TrackID != 1 || p.Codec != mp4.Avc1FourCC || p.StreamType != VideoStream || p.StreamName != "video" || p.Bitrate != 1000 {
+					t.Errorf("MoovProcessor() = %+v, want track 1 of an avc1 video stream at 1000", p)
+				}
+				if p.Timescale != 90000 || p.Duration != 540000 || !p.DurationInTimescale {
+					t.Errorf("duration %d in timescale %d, want 540000 in timescale 90000", p.Duration, p.Timescale)
+				}
+				if p.Width != 1280 || p.Height != 720 || p.Protected {
+					t.Errorf("clear track of %dx%d, want a clear track of 1280x720", p.Width, p.Height)
+				}
+			},
+		},
+		{
+			name:     "video stream size",
+			manifest: manifest(""),
+			track:    1,
+			check: func(t *testing.T, p MoovProcessor) {
+				if p.Width != 1920 || p.Height != 1080 {
+					t.Errorf("track of %dx%d, want 1920x1080", p.Width, p.Height)
+				}
+			},
+		},
+		{
+			name:     "audio track",
+			manifest: manifest(""),
+			stream:   1,
+			check: func(t *testing.T, p MoovProcessor) {
+				if p.Codec != Mp4aFourCC || p.AudioObjectType != AudioObjectTypeAACLC || p.Timescale != 10000000 || p.Duration != 60000000 {
+					t.Errorf("MoovProcessor() = %+v, want an AAC-LC track of 60000000 in timescale 10000000", p)
+				}
+				if p.Language != language.MustParseBase("en") {
+					t.Errorf("language %v, want en", p.Language)
+				}
+				if p.SamplingRate != 48000 || p.Channels != 2 || p.BitsPerSample != 16 || p.PacketSize != 4 || p.AudioTag != 255 {
+					t.Errorf("MoovProcessor() = %+v, want the audio attributes of the track", p)
+				}
+			},
+		},
+		{
+			name:     "protected with AES-CTR",
+			manifest: manifest(protection(testWRMHeader40)),
+			check: func(t *testing.T, p MoovProcessor) {
+				if !p.Protected || p.SystemID != PlayReadySystemID || p.KID != [16]byte(testPlayReadyKID) || p.EncryptionScheme != (mp4.FourCC{}) {
+					t.Errorf("MoovProcessor() = %+v, want a PlayReady protected cenc track of the header KID", p)
+				}
+			},
+		},
+		{
+			name:     "protected with AES-CBC",
+			manifest: manifest(protection(strings.Replace(testWRMHeader40, "AESCTR", "AESCBC", 1))),
+			check: func(t *testing.T, p MoovProcessor) {
+				if !p.Protected || p.KID != [16]byte(testPlayReadyKID) || p.EncryptionScheme != CbcsFourCC {
+					t.Errorf("MoovProcessor() = %+v, want a PlayReady protected cbcs track of the header KID", p)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseManifest([]byte(tt.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := m.Streams[tt.stream]
+			p, err := m.MoovProcessor(s, s.Tracks[tt.track])
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, p)
+		})
+	}
+}
+
+func TestSmoothStreamingMediaMoovProcessorErrors(t *testing.T) {
+	m, err := ParseManifest([]byte(`<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+		`<StreamIndex Type="video" Name="video"><QualityLevel Index="0" Bitrate="1000" FourCC="XXXX"/></StreamIndex>` +
+		`</SmoothStreamingMedia>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := m.Streams[0]
+	tests := []struct {
+		name    string
+		stream  *StreamIndex
+		track   *Track
+		wantErr error
+	}{
+		{name: "nil stream", track: s.Tracks[0], wantErr: ErrInvalidParam},
+		{name: "nil track", stream: s, wantErr: ErrInvalidParam},
+		{name: "unknown codec", stream: s, track: s.Tracks[0], wantErr: ErrUnknownCodec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.MoovProcessor(tt.stream, tt.track); !errors.Is(err, tt.wantErr) {
+				t.Errorf("MoovProcessor() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
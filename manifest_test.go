@@ -0,0 +1,50 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestParseManifest(t *testing.T) {
+	const text = `<?xml version="1.0" encoding="utf-16"?><SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000"><StreamIndex Type="video" Name="vidéo"/></SmoothStreamingMedia>`
+	utf16Text := func(order binary.ByteOrder, bom []byte) []byte {
+		units := utf16.Encode([]rune(text))
+		data := make([]byte, len(bom)+2*len(units))
+		copy(data, bom)
+		for i, unit := range units {
+			order.PutUint16(data[len(bom)+2*i:], unit)
+		}
+		return data
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "UTF-8", data: []byte(text)},
+		{name: "UTF-8 with a byte order mark", data: append([]byte("\ufeff"), text...)},
+		{name: "UTF-16LE", data: utf16Text(binary.LittleEndian, []byte{0xff, 0xfe})},
+		{name: "UTF-16BE", data: utf16Text(binary.BigEndian, []byte{0xfe, 0xff})},
+		{name: "UTF-16 of odd length", data: utf16Text(binary.LittleEndian, []byte{0xff, 0xfe})[:11], wantErr: ErrInvalidParam},
+		{name: "invalid XML", data: []byte("<SmoothStreamingMedia"), wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseManifest(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseManifest() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if m != nil {
+					t.Errorf("ParseManifest() = %+v, want nil", m)
+				}
+				return
+			}
+			if m.Duration != 60000000 || len(m.Streams) != 1 || m.Streams[0].streamName() != "vidéo" {
+				t.Errorf("ParseManifest() = %+v, want a stream named vidéo of 60000000", m)
+			}
+		})
+	}
+}
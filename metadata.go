@@ -0,0 +1,42 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-webdl/mp4"
+)
+
+// IsTimedMetadata reports whether the stream is a text stream of application
+// events, i.e. of Subtype DATA, SCMD or CTRL, rather than of subtitles or
+// captions. Such streams are preserved as timed metadata tracks.
+func (s *StreamIndex) IsTimedMetadata() bool {
+	if s.Type != TextStream || s.Subtype == nil {
+		return false
+	}
+	switch strings.ToUpper(*s.Subtype) {
+	case "DATA", "SCMD", "CTRL":
+		return true
+	}
+	return false
+}
+
+// SampleEntryCodec returns the FourCC of the MP4 sample entry that carries a
+// track of the stream. The tracks of timed metadata streams are carried by a
+// 'mett' sample entry unless their FourCC selects 'urim', in which case the
+// MetadataURI of the MoovProcessor must be set; other tracks by the sample
+// entry of their FourCC, see SampleEntryCodec.
+func (s *StreamIndex) SampleEntryCodec(t *Track) (codec mp4.FourCC, err error) {
+	if s.IsTimedMetadata() {
+		codec = MettFourCC
+		if t.FourCC != nil && strings.ToUpper(*t.FourCC) == "URIM" {
+			codec = UrimFourCC
+		}
+		return
+	}
+	if t.FourCC == nil {
+		err = fmt.Errorf("track %d has no FourCC: %w", t.Index, ErrInvalidParam)
+		return
+	}
+	return SampleEntryCodec(*t.FourCC)
+}
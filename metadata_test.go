@@ -0,0 +1,43 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestStreamIndexSampleEntryCodec(t *testing.T) {
+	tests := []struct {
+		name         string
+		streamType   StreamType
+		subtype      *string
+		fourCC       *string
+		wantMetadata bool
+		want         mp4.FourCC
+		wantErr      error
+	}{
+		{name: "DATA", streamType: TextStream, subtype: stringPtr("DATA"), wantMetadata: true, want: MettFourCC},
+		{name: "scmd", streamType: TextStream, subtype: stringPtr("scmd"), fourCC: stringPtr("SCMD"), wantMetadata: true, want: MettFourCC},
+		{name: "CTRL as urim", streamType: TextStream, subtype: stringPtr("CTRL"), fourCC: stringPtr("urim"), wantMetadata: true, want: UrimFourCC},
+		{name: "subtitles", streamType: TextStream, subtype: stringPtr("SUBT"), fourCC: stringPtr("TTML"), want: StppFourCC},
+		{name: "no subtype", streamType: TextStream, fourCC: stringPtr("TTML"), want: StppFourCC},
+		{name: "audio", streamType: AudioStream, subtype: stringPtr("DATA"), fourCC: stringPtr("AACL"), want: Mp4aFourCC},
+		{name: "no FourCC", streamType: VideoStream, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &StreamIndex{Type: tt.streamType, Subtype: tt.subtype}
+			if got := s.IsTimedMetadata(); got != tt.wantMetadata {
+				t.Errorf("IsTimedMetadata() = %v, want %v", got, tt.wantMetadata)
+			}
+			got, err := s.SampleEntryCodec(&Track{FourCC: tt.fourCC})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SampleEntryCodec() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SampleEntryCodec() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
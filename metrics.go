@@ -0,0 +1,86 @@
+package smoothstreaming
+
+import (
+	"io"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Metrics receives measurements of the requests and the progress of a
+// Downloader, e.g. to feed them to Prometheus or StatsD. Its methods are
+// called concurrently by the requests in flight, and should return quickly.
+type Metrics interface {
+	// RequestStarted is called before each attempt of a request of u, retry
+	// counting the attempts before it.
+	RequestStarted(u *url.URL, retry int)
+
+	// RequestFinished is called after each attempt of a request, once its
+	// response is read and closed, or failed.
+	RequestFinished(r RequestMetrics)
+
+	// LiveEdgeLag is called for each recorded track of a live presentation
+	// whenever its manifest is fetched, with the media duration between the
+	// last fragment of the track written, or the first one published if none
+	// is, and the last one published, the live edge.
+	LiveEdgeLag(track SelectedTrack, lag time.Duration)
+}
+
+// RequestMetrics are the measurements of an attempt of a request.
+type RequestMetrics struct {
+	// the url of the request.
+	URL *url.URL
+
+	// the attempt of the request, 0 for the first one.
+	Retry int
+
+	// the status of the response, 0 if the request failed without one.
+	Status int
+
+	// the number of bytes of the response body read.
+	Bytes int64
+
+	// the time until the headers of the response were received.
+	Latency time.Duration
+
+	// the time until the response was read and closed.
+	Duration time.Duration
+
+	// the error of the attempt, nil if it succeeded.
+	Err error
+}
+
+// noMetrics discards the measurements of a Downloader without Metrics.
+type noMetrics struct{}
+
+func (noMetrics) RequestStarted(u *url.URL, retry int)               {}
+func (noMetrics) RequestFinished(r RequestMetrics)                   {}
+func (noMetrics) LiveEdgeLag(track SelectedTrack, lag time.Duration) {}
+
+// metricsReader counts the bytes read from the body of a response and
+// reports the request to its Metrics when closed.
+type metricsReader struct {
+	r       io.ReadCloser
+	metrics Metrics
+	request RequestMetrics
+	start   time.Time
+	once    sync.Once
+}
+
+func (mr *metricsReader) Read(p []byte) (n int, err error) {
+	n, err = mr.r.Read(p)
+	mr.request.Bytes += int64(n)
+	if err != nil && err != io.EOF {
+		mr.request.Err = err
+	}
+	return
+}
+
+func (mr *metricsReader) Close() (err error) {
+	err = mr.r.Close()
+	mr.once.Do(func() {
+		mr.request.Duration = time.Since(mr.start)
+		mr.metrics.RequestFinished(mr.request)
+	})
+	return
+}
@@ -0,0 +1,109 @@
+package smoothstreaming
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testMetrics records the measurements of a Downloader.
+type testMetrics struct {
+	mu       sync.Mutex
+	started  []int
+	finished []RequestMetrics
+	lags     []time.Duration
+}
+
+func (m *testMetrics) RequestStarted(u *url.URL, retry int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = append(m.started, retry)
+}
+
+func (m *testMetrics) RequestFinished(r RequestMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finished = append(m.finished, r)
+}
+
+func (m *testMetrics) LiveEdgeLag(track SelectedTrack, lag time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lags = append(m.lags, lag)
+}
+
+func TestDownloaderMetricsRequests(t *testing.T) {
+	var failed bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, testManifest)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := &testMetrics{}
+	d := Downloader{Retry: RetryPolicy{MaxRetries: 1, InitialDelay: time.Millisecond}, Metrics: metrics}
+	if _, err = d.FetchManifest(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(metrics.started, []int{0, 1}) {
+		t.Errorf("started attempts %v, want [0 1]", metrics.started)
+	}
+	if len(metrics.finished) != 2 {
+		t.Fatalf("finished %d attempts, want 2", len(metrics.finished))
+	}
+	if r := metrics.finished[0]; r.Retry != 0 || r.Status != http.StatusServiceUnavailable || !errors.Is(r.Err, ErrUnexpectedStatus) || r.URL != u {
+		t.Errorf("first attempt %+v, want a 503 failure", r)
+	}
+	if r := metrics.finished[1]; r.Retry != 1 || r.Status != http.StatusOK || r.Err != nil || r.Bytes != int64(len(testManifest)) || r.Duration < r.Latency {
+		t.Errorf("second attempt %+v, want a 200 response of %d bytes", r, len(testManifest))
+	}
+}
+
+func TestDownloaderMetricsLiveEdgeLag(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fragment := testFragment(t, nil)
+	var manifests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/live.isml/Manifest" {
+			w.Write(fragment)
+			return
+		}
+		// the second refresh publishes two fragments at once
+		if manifests++; manifests > 2 {
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		io.WriteString(w, testLiveManifest(2*manifests-1))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/live.isml/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := &testMetrics{}
+	d := Downloader{OutputDir: t.TempDir(), RefreshInterval: 5 * time.Millisecond, Metrics: metrics}
+	if _, err = d.Download(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	// the first fragment is at the live edge, then the track lags behind
+	// the two fragments published since
+	if want := []time.Duration{0, 4 * time.Second}; !reflect.DeepEqual(metrics.lags, want) {
+		t.Errorf("live edge lags %v, want %v", metrics.lags, want)
+	}
+}
@@ -3,8 +3,11 @@ package smoothstreaming
 import (
 	"bytes"
 	"fmt"
+	"strconv"
+	"unicode/utf8"
 
 	"github.com/go-webdl/media-codec/avc"
+	"github.com/go-webdl/media-codec/dovi"
 	"github.com/go-webdl/media-codec/hevc"
 	"github.com/go-webdl/mp4"
 
@@ -13,31 +16,79 @@ import (
 )
 
 type MoovProcessor struct {
-	TrackID            uint32
-	Codec              mp4.FourCC
-	Width              uint32
-	Height             uint32
-	Duration           uint64
-	Timescale          uint64
-	Language           language.Base
-	CodecPrivateData   []byte
-	StreamType         StreamType
-	StreamName         string
-	Protected          bool
-	KID                [16]byte
-	SystemID           uuid.UUID
-	ProtectionInitData []byte
+	TrackID               uint32
+	NextTrackID           uint32
+	Codec                 mp4.FourCC
+	Width                 uint32
+	Height                uint32
+	Duration              uint64
+	Timescale             uint64
+	DurationInTimescale   bool
+	Language              language.Base
+	CodecPrivateData      []byte
+	SamplingRate          uint32
+	Channels              uint16
+	BitsPerSample         uint16
+	ChannelLayout         uint8
+	MetadataMIMEType      string
+	MetadataURI           string
+	Bitrate               uint32
+	AudioObjectType       uint8
+	AudioTag              uint16
+	PacketSize            uint32
+	CustomAttributes      *CustomAttributes
+	DolbyVision           *dovi.DOVIDecoderConfigurationRecord
+	MovieTimescale        uint64
+	EditEmptyDuration     uint64
+	EditMediaTime         uint64
+	EmitBitRateBox        bool
+	MaxBitrate            uint32
+	EmitMehd              bool
+	EmitUserData          bool
+	Title                 string
+	DefaultSampleDuration uint32
+	DefaultSampleFlags    *uint32
+	Colour                *ColourDescription
+	MasteringDisplay      *MasteringDisplayColourVolume
+	ContentLightLevel     *ContentLightLevel
+	StreamType            StreamType
+	StreamName            string
+	HandlerName           string
+	CompressorName        string
+	MajorBrand            mp4.FourCC
+	CompatibleBrands      []mp4.FourCC
+	Protected             bool
+	KID                   [16]byte
+	EncryptionScheme      mp4.FourCC
+	CryptByteBlock        uint8
+	SkipByteBlock         uint8
+	ConstantIV            []byte
+	SystemID              uuid.UUID
+	ProtectionInitData    []byte
+	ProtectionSystems     []ProtectionSystem
 }
 
+// CreateFtypMp4Box returns the ftyp box of the init segment. The brands default
+// to the major brand iso6 compatible with isom, iso6 and msdh; tools that key
+// on other brands, e.g. cmfc or dash, require MajorBrand and CompatibleBrands
+// to be set.
 func (p MoovProcessor) CreateFtypMp4Box() (ftyp mp4.Box, err error) {
-	ftyp = &mp4.FileTypeBox{
-		MajorBrand:   mp4.Iso6FourCC,
-		MinorVersion: 1,
-		CompatibleBrands: []mp4.FourCC{
+	majorBrand := p.MajorBrand
+	if majorBrand == (mp4.FourCC{}) {
+		majorBrand = mp4.Iso6FourCC
+	}
+	compatibleBrands := p.CompatibleBrands
+	if len(compatibleBrands) == 0 {
+		compatibleBrands = []mp4.FourCC{
 			mp4.IsomFourCC,
 			mp4.Iso6FourCC,
 			mp4.MsdhFourCC,
-		},
+		}
+	}
+	ftyp = &mp4.FileTypeBox{
+		MajorBrand:       majorBrand,
+		MinorVersion:     1,
+		CompatibleBrands: compatibleBrands,
 	}
 	ftyp.Mp4BoxUpdate()
 	return
@@ -61,12 +112,20 @@ func (p MoovProcessor) CreateMoovMp4Box() (moov mp4.Box, err error) {
 
 	children := []mp4.Box{mvhd, trak, mvex}
 
+	udta, err := p.CreateUdtaMp4Box()
+	if err != nil {
+		return
+	}
+	if udta != nil {
+		children = append(children, udta)
+	}
+
 	if p.Protected {
-		var pssh mp4.Box
-		if pssh, err = p.CreatePsshMp4Box(); err != nil {
+		var psshs []mp4.Box
+		if psshs, err = p.CreatePsshMp4Boxes(); err != nil {
 			return
 		}
-		children = append(children, pssh)
+		children = append(children, psshs...)
 	}
 
 	moov = &mp4.MovieBox{}
@@ -77,21 +136,32 @@ func (p MoovProcessor) CreateMoovMp4Box() (moov mp4.Box, err error) {
 	return
 }
 
+// CreateMvhdMp4Box returns the movie header. Its next_track_ID follows the
+// TrackID, or is NextTrackID if that is higher, so the init segments of the
+// tracks of a presentation can declare the IDs of the whole presentation, see
+// AssignTrackIDs.
 func (p MoovProcessor) CreateMvhdMp4Box() (mvhd mp4.Box, err error) {
 	mvhd = &mp4.MovieHeaderBox{
 		FullHeader: mp4.FullHeader{Version: 1}, // in order to have 64bits duration value
-		Timescale:  uint32(p.Timescale),
-		Duration:   p.Duration * p.Timescale,
+		Timescale:  uint32(p.movieTimescale()),
+		Duration:   p.movieDuration(),
 		Rate:       0x00010000, // typically 1.0
 		Volume:     0x0100,     // typically, full volume
 		Matrix: [9]int32{ // Unity matrix
 			0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000,
 		},
-		NextTrackID: p.TrackID + 1,
+		NextTrackID: p.nextTrackID(),
 	}
 	return
 }
 
+func (p MoovProcessor) nextTrackID() uint32 {
+	if p.NextTrackID > p.TrackID {
+		return p.NextTrackID
+	}
+	return p.TrackID + 1
+}
+
 func (p MoovProcessor) CreatePsshMp4Box() (pssh mp4.Box, err error) {
 	pssh = &mp4.ProtectionSystemSpecificHeaderBox{
 		SystemID: p.SystemID,
@@ -101,27 +171,109 @@ func (p MoovProcessor) CreatePsshMp4Box() (pssh mp4.Box, err error) {
 }
 
 func (p MoovProcessor) CreateMvexMp4Box() (mvex mp4.Box, err error) {
-	trex := &mp4.TrackExtendsBox{
-		TrackID:                      p.TrackID,
-		DefaultSampleDescrptionIndex: 1,
+	var children []mp4.Box
+	mehd, err := p.CreateMehdMp4Box()
+	if err != nil {
+		return
+	}
+	if mehd != nil {
+		children = append(children, mehd)
+	}
+	trex, err := p.CreateTrexMp4Box()
+	if err != nil {
+		return
 	}
+	children = append(children, trex)
 	mvex = &mp4.MovieExtendsBox{}
-	if err = mvex.Mp4BoxReplaceChildren([]mp4.Box{trex}); err != nil {
+	if err = mvex.Mp4BoxReplaceChildren(children); err != nil {
 		return
 	}
 	return
 }
 
+// CreateMehdMp4Box returns the mehd box carrying the duration of the
+// presentation in the movie timescale if EmitMehd is set, or nil otherwise or
+// when the duration is unknown, as for live presentations.
+func (p MoovProcessor) CreateMehdMp4Box() (mehd mp4.Box, err error) {
+	if !p.EmitMehd || p.Duration == 0 {
+		return
+	}
+	mehd = &MovieExtendsHeaderBox{
+		FragmentDuration: p.movieDuration(),
+	}
+	return
+}
+
+// CreateTrexMp4Box returns the trex box of the track, whose defaults apply to
+// the samples of fragments that do not signal their own duration or flags.
+// Unless set by DefaultSampleFlags, video samples default to non-sync samples
+// and other samples to sync samples. Unless set by DefaultSampleDuration, the
+// duration of the frames of AAC, AC-3 and E-AC-3 tracks is used when it is a
+// whole number of Timescale units, and 0 otherwise.
+func (p MoovProcessor) CreateTrexMp4Box() (trex mp4.Box, err error) {
+	trex = &mp4.TrackExtendsBox{
+		TrackID:                      p.TrackID,
+		DefaultSampleDescrptionIndex: 1,
+		DefaultSampleDuration:        p.defaultSampleDuration(),
+		DefaultSampleFlags:           p.defaultSampleFlags(),
+	}
+	return
+}
+
+// defaultSampleFlags returns the sample flags of the trex box.
+func (p MoovProcessor) defaultSampleFlags() uint32 {
+	if p.DefaultSampleFlags != nil {
+		return *p.DefaultSampleFlags
+	}
+	if p.StreamType == VideoStream {
+		return DefaultVideoSampleFlags
+	}
+	return DefaultSyncSampleFlags
+}
+
+// defaultSampleDuration returns the sample duration of the trex box.
+func (p MoovProcessor) defaultSampleDuration() uint32 {
+	if p.DefaultSampleDuration > 0 {
+		return p.DefaultSampleDuration
+	}
+	return p.audioFrameDuration()
+}
+
+// audioFrameDuration returns the duration of the audio frames of the track in
+// its Timescale, or 0 if it is unknown or not a whole number.
+func (p MoovProcessor) audioFrameDuration() uint32 {
+	var samples uint64
+	switch p.Codec {
+	case Mp4aFourCC:
+		// HE-AAC frames hold 2048 samples at the output sampling rate, which
+		// may or may not be the SamplingRate of the track
+		if p.AudioObjectType == AudioObjectTypeSBR || p.AudioObjectType == AudioObjectTypePS {
+			return 0
+		}
+		samples = 1024
+	case Ac3FourCC, Ec3FourCC:
+		samples = 1536
+	default:
+		return 0
+	}
+	rate := uint64(p.SamplingRate)
+	if rate == 0 || samples*p.Timescale%rate != 0 {
+		return 0
+	}
+	return uint32(samples * p.Timescale / rate)
+}
+
 func (p MoovProcessor) CreateTrakMp4Box() (trak mp4.Box, err error) {
+	width, height := p.pictureSize()
 	tkhd := &mp4.TrackHeaderBox{
 		TrackID:  p.TrackID,
-		Duration: p.Duration * p.Timescale,
+		Duration: p.movieDuration(),
 		Volume:   0x0100,
 		Matrix: [9]int32{ // Unity matrix
 			0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000,
 		},
-		Width:  p.Width,
-		Height: p.Height,
+		Width:  width,
+		Height: height,
 	}
 	tkhd.Version = 1
 	tkhd.Mp4BoxSetFlags(mp4.FLAG_TKHD_TRACK_ENABLED | mp4.FLAG_TKHD_TRACK_IN_MOVIE | mp4.FLAG_TKHD_TRACK_IN_PREVIEW)
@@ -131,31 +283,186 @@ func (p MoovProcessor) CreateTrakMp4Box() (trak mp4.Box, err error) {
 		return
 	}
 
+	children := []mp4.Box{tkhd}
+	edts, err := p.CreateEdtsMp4Box()
+	if err != nil {
+		return
+	}
+	if edts != nil {
+		children = append(children, edts)
+	}
+	children = append(children, mdia)
+
+	udta, err := p.CreateTrackUdtaMp4Box()
+	if err != nil {
+		return
+	}
+	if udta != nil {
+		children = append(children, udta)
+	}
+
 	trak = &mp4.TrackBox{}
-	if err = trak.Mp4BoxReplaceChildren([]mp4.Box{tkhd, mdia}); err != nil {
+	if err = trak.Mp4BoxReplaceChildren(children); err != nil {
 		return
 	}
 
 	return
 }
 
+// CreateUdtaMp4Box returns the user data of the movie if EmitUserData is set,
+// carrying the Title in an iTunes metadata item list, or nil otherwise or when
+// there is no Title.
+func (p MoovProcessor) CreateUdtaMp4Box() (udta mp4.Box, err error) {
+	if !p.EmitUserData || p.Title == "" {
+		return
+	}
+	return createMetadataUdtaMp4Box(p.Title, language.Base{})
+}
+
+// CreateTrackUdtaMp4Box returns the user data of the track if EmitUserData is
+// set, carrying the StreamName in the Language of the track in an iTunes
+// metadata item list, or nil otherwise or when there is no StreamName.
+func (p MoovProcessor) CreateTrackUdtaMp4Box() (udta mp4.Box, err error) {
+	if !p.EmitUserData || p.StreamName == "" {
+		return
+	}
+	return createMetadataUdtaMp4Box(p.StreamName, p.Language)
+}
+
+// createMetadataUdtaMp4Box returns a udta box holding the title of a movie or
+// track in the '©nam' item of an iTunes metadata item list.
+func createMetadataUdtaMp4Box(title string, lang language.Base) (udta mp4.Box, err error) {
+	data := &MetadataDataBox{
+		DataType: MetadataTypeUTF8,
+		Language: lang,
+		Value:    []byte(title),
+	}
+	name := &MetadataItemBox{Header: mp4.Header{Type: NamBoxType}}
+	if err = name.Mp4BoxReplaceChildren([]mp4.Box{data}); err != nil {
+		return
+	}
+	ilst := &MetadataItemListBox{}
+	if err = ilst.Mp4BoxReplaceChildren([]mp4.Box{name}); err != nil {
+		return
+	}
+	meta := &MetaBox{}
+	if err = meta.Mp4BoxReplaceChildren([]mp4.Box{
+		&mp4.HandlerBox{HandlerType: MdirFourCC},
+		ilst,
+	}); err != nil {
+		return
+	}
+	udta = &UserDataBox{}
+	if err = udta.Mp4BoxReplaceChildren([]mp4.Box{meta}); err != nil {
+		return
+	}
+	return
+}
+
+// pictureSize returns the Width and Height of the track, or the picture size
+// of the SPS in CodecPrivateData when they are not set.
+func (p MoovProcessor) pictureSize() (width, height uint32) {
+	if p.Width > 0 && p.Height > 0 {
+		return p.Width, p.Height
+	}
+	return p.codedSize()
+}
+
+// codedSize returns the picture size of the SPS in CodecPrivateData, or the
+// Width and Height of the track when there is none.
+func (p MoovProcessor) codedSize() (width, height uint32) {
+	info, err := p.TrackInfo()
+	if err != nil || info.Width == 0 || info.Height == 0 {
+		return p.Width, p.Height
+	}
+	return info.Width, info.Height
+}
+
+func (p MoovProcessor) movieTimescale() uint64 {
+	if p.MovieTimescale > 0 {
+		return p.MovieTimescale
+	}
+	return p.Timescale
+}
+
+// mediaDuration returns the duration of the track in its Timescale. Duration
+// is in seconds unless DurationInTimescale is set, in which case it is already
+// in the Timescale, as the durations of the manifest are.
+func (p MoovProcessor) mediaDuration() uint64 {
+	if p.DurationInTimescale {
+		return p.Duration
+	}
+	return p.Duration * p.Timescale
+}
+
+// movieDuration returns the duration of the track in the movie timescale.
+func (p MoovProcessor) movieDuration() uint64 {
+	if p.DurationInTimescale {
+		return rescaleTime(p.Duration, p.Timescale, p.movieTimescale())
+	}
+	return p.Duration * p.movieTimescale()
+}
+
+// CreateEdtsMp4Box returns the edit list of the track, which is nil unless
+// EditEmptyDuration or EditMediaTime is set. EditEmptyDuration, in the movie
+// timescale, delays the presentation of the track with an empty edit.
+// EditMediaTime, in the track timescale, skips the start of the media, e.g.
+// the priming samples of an AAC encoder or a non-zero first fragment time.
+func (p MoovProcessor) CreateEdtsMp4Box() (edts mp4.Box, err error) {
+	if p.EditEmptyDuration == 0 && p.EditMediaTime == 0 {
+		return
+	}
+	elst := &EditListBox{}
+	elst.Version = 1
+	if p.EditEmptyDuration > 0 {
+		elst.Entries = append(elst.Entries, EditListEntry{
+			SegmentDuration:  p.EditEmptyDuration,
+			MediaTime:        -1,
+			MediaRateInteger: 1,
+		})
+	}
+	// a segment duration of 0 spans the whole media of a fragmented track
+	var segmentDuration uint64
+	if duration := p.movieDuration(); duration > 0 {
+		skipped := rescaleTime(p.EditMediaTime, p.Timescale, p.movieTimescale())
+		if duration > skipped {
+			segmentDuration = duration - skipped
+		}
+	}
+	elst.Entries = append(elst.Entries, EditListEntry{
+		SegmentDuration:  segmentDuration,
+		MediaTime:        int64(p.EditMediaTime),
+		MediaRateInteger: 1,
+	})
+	edts = &EditBox{}
+	if err = edts.Mp4BoxReplaceChildren([]mp4.Box{elst}); err != nil {
+		return
+	}
+	return
+}
+
 func (p MoovProcessor) CreateMdiaMp4Box() (mdia mp4.Box, err error) {
-	mdhd := &mp4.MediaHeaderBox{
+	mdhd := &MediaHeaderBox{
 		Timescale: uint32(p.Timescale),
-		Duration:  p.Duration * p.Timescale,
+		Duration:  p.mediaDuration(),
 		Language:  p.Language,
 	}
 	mdhd.Version = 1
 
 	hdlr := &mp4.HandlerBox{
 		HandlerType: mp4.VideFourCC,
-		Name:        mp4.NullTerminatedString(p.StreamName),
+		Name:        mp4.NullTerminatedString(p.handlerName()),
 	}
 	switch p.StreamType {
 	case VideoStream:
 		hdlr.HandlerType = mp4.VideFourCC
 	case AudioStream:
 		hdlr.HandlerType = mp4.SounFourCC
+	case TextStream:
+		hdlr.HandlerType = mp4.MetaFourCC
+		if p.Codec == StppFourCC {
+			hdlr.HandlerType = SubtFourCC
+		}
 	default:
 		hdlr.HandlerType = mp4.MetaFourCC
 	}
@@ -173,6 +480,15 @@ func (p MoovProcessor) CreateMdiaMp4Box() (mdia mp4.Box, err error) {
 	return
 }
 
+// handlerName returns the name of the hdlr box, the HandlerName of the track or
+// its StreamName if it is not set.
+func (p MoovProcessor) handlerName() string {
+	if p.HandlerName != "" {
+		return p.HandlerName
+	}
+	return p.StreamName
+}
+
 func (p MoovProcessor) CreateMinfMp4Box() (minf mp4.Box, err error) {
 	mhd, err := p.CreateMhdMp4Box()
 	if err != nil {
@@ -213,7 +529,7 @@ func (p MoovProcessor) CreateStblMp4Box() (stbl mp4.Box, err error) {
 		&mp4.TimeToSampleBox{},
 		&mp4.SampleToChunkBox{},
 		&mp4.ChunkOffsetBox{},
-		&mp4.SampleSizeBox{},
+		&SampleSizeBox{},
 	}); err != nil {
 		return
 	}
@@ -237,26 +553,192 @@ func (p MoovProcessor) CreateSampleEntryMp4Box() (sampleEntry mp4.Box, err error
 	switch p.Codec {
 	case mp4.Avc1FourCC:
 		sampleEntry, err = p.CreateAvc1Mp4Box()
-	case mp4.Hvc1FourCC, mp4.Hev1FourCC:
+	case mp4.Hvc1FourCC, mp4.Hev1FourCC, mp4.Dvh1FourCC, mp4.DvheFourCC:
 		sampleEntry, err = p.CreateHvc1Mp4Box()
+	case Vc1FourCC:
+		sampleEntry, err = p.CreateVc1Mp4Box()
+	case Av01FourCC:
+		sampleEntry, err = p.CreateAv01Mp4Box()
+	case Vp09FourCC:
+		sampleEntry, err = p.CreateVp09Mp4Box()
+	case Mp4aFourCC:
+		sampleEntry, err = p.CreateMp4aMp4Box()
+	case Ac3FourCC, Ec3FourCC:
+		sampleEntry, err = p.CreateAc3Mp4Box()
+	case DtscFourCC, DtshFourCC, DtslFourCC, DtseFourCC:
+		sampleEntry, err = p.CreateDtsMp4Box()
+	case OwmaFourCC:
+		sampleEntry, err = p.CreateOwmaMp4Box()
+	case StppFourCC:
+		sampleEntry, err = p.CreateStppMp4Box()
+	case MettFourCC:
+		sampleEntry, err = p.CreateMettMp4Box()
+	case UrimFourCC:
+		sampleEntry, err = p.CreateUrimMp4Box()
 	default:
 		err = fmt.Errorf("codec %s not supported: %w", p.Codec, ErrUnknownCodec)
 	}
+	if err != nil {
+		return
+	}
+	var extra []mp4.Box
+	switch sampleEntry.(type) {
+	case *mp4.VisualSampleEntryBox:
+		if extra, err = p.CreateHDRMp4Boxes(); err != nil {
+			return
+		}
+	case *AudioSampleEntryBox:
+		var chnl mp4.Box
+		if chnl, err = p.CreateChnlMp4Box(); err != nil {
+			return
+		}
+		if chnl != nil {
+			extra = append(extra, chnl)
+		}
+	}
+	btrt, err := p.CreateBtrtMp4Box()
+	if err != nil {
+		return
+	}
+	if btrt != nil {
+		extra = append(extra, btrt)
+	}
+	if len(extra) == 0 {
+		return
+	}
+	// the additional boxes follow the decoder configuration, ahead of the
+	// protection scheme information of encrypted sample entries
+	var children []mp4.Box
+	for _, child := range sampleEntry.Mp4BoxChildren() {
+		if child.Mp4BoxType() == mp4.SinfBoxType {
+			children = append(children, extra...)
+			extra = nil
+		}
+		children = append(children, child)
+	}
+	children = append(children, extra...)
+	err = sampleEntry.Mp4BoxReplaceChildren(children)
 	return
 }
 
+// CreateHDRMp4Boxes returns the colr, mdcv and clli boxes of a video sample
+// entry. The mastering display colour volume and content light level are
+// taken from the SEI messages in the CodecPrivateData of HEVC tracks unless
+// set explicitly.
+func (p MoovProcessor) CreateHDRMp4Boxes() (boxes []mp4.Box, err error) {
+	mdcv, clli := p.MasteringDisplay, p.ContentLightLevel
+	switch p.Codec {
+	case mp4.Hvc1FourCC, mp4.Hev1FourCC, mp4.Dvh1FourCC, mp4.DvheFourCC:
+		seiMdcv, seiClli := hevcHDRMetadata(p.CodecPrivateData)
+		if mdcv == nil {
+			mdcv = seiMdcv
+		}
+		if clli == nil {
+			clli = seiClli
+		}
+	}
+	if p.Colour != nil {
+		boxes = append(boxes, &mp4.ColourInformationBox{
+			ColourType:              mp4.NclxFourCC,
+			ColourPrimaries:         p.Colour.ColourPrimaries,
+			TransferCharacteristics: p.Colour.TransferCharacteristics,
+			MatrixCoefficients:      p.Colour.MatrixCoefficients,
+			FullRange:               p.Colour.FullRange,
+		})
+	}
+	if mdcv != nil {
+		boxes = append(boxes, &MasteringDisplayColourVolumeBox{MasteringDisplayColourVolume: *mdcv})
+	}
+	if clli != nil {
+		boxes = append(boxes, &ContentLightLevelBox{ContentLightLevel: *clli})
+	}
+	return
+}
+
+// CreateChnlMp4Box returns the channel layout box of an audio track, or nil
+// for mono and stereo tracks unless a layout is set. The layout is the
+// ISO/IEC 23091-3 ChannelConfiguration of ChannelLayout or of the
+// "ChannelLayout" custom attribute if either is set, otherwise the common
+// layout for the number of Channels, e.g. 5.1 for 6 and 7.1 for 8 channels.
+func (p MoovProcessor) CreateChnlMp4Box() (chnl mp4.Box, err error) {
+	layout := p.ChannelLayout
+	if value, ok := p.CustomAttributes.Value("ChannelLayout"); ok && layout == 0 {
+		var parsed uint64
+		if parsed, err = strconv.ParseUint(value, 10, 8); err != nil {
+			err = fmt.Errorf("ChannelLayout custom attribute %q: %w", value, ErrInvalidParam)
+			return
+		}
+		layout = uint8(parsed)
+	}
+	if layout == 0 && p.Channels > 2 && int(p.Channels) < len(channelLayouts) {
+		layout = channelLayouts[p.Channels]
+	}
+	if layout == 0 {
+		return
+	}
+	chnl = &ChannelLayoutBox{
+		StreamStructure: ChannelStructured,
+		DefinedLayout:   layout,
+	}
+	return
+}
+
+// The ISO/IEC 23091-3 ChannelConfiguration of the common layout of each
+// number of channels: 3.0, 4.0 (3/1), 5.0, 5.1, 6.1 and 7.1 (3/4.1).
+var channelLayouts = [...]uint8{0, 1, 2, 3, 4, 5, 6, 11, 12}
+
+// CreateBtrtMp4Box returns the bitrate box of the sample entry if
+// EmitBitRateBox is set, nil otherwise. The maximum bitrate defaults to the
+// average bitrate when MaxBitrate is 0.
+func (p MoovProcessor) CreateBtrtMp4Box() (btrt mp4.Box, err error) {
+	if !p.EmitBitRateBox {
+		return
+	}
+	maxBitrate := p.MaxBitrate
+	if maxBitrate < p.Bitrate {
+		maxBitrate = p.Bitrate
+	}
+	btrt = &mp4.BitRateBox{
+		MaxBitrate: maxBitrate,
+		AvgBitrate: p.Bitrate,
+	}
+	return
+}
+
+// compressorName returns the compressor name of a visual sample entry, the
+// CompressorName of the track or name if it is not set, truncated to the 31
+// bytes of the field on a UTF-8 character boundary.
+func (p MoovProcessor) compressorName(name string) string {
+	if p.CompressorName != "" {
+		name = p.CompressorName
+	}
+	if len(name) <= maxCompressorNameSize {
+		return name
+	}
+	n := maxCompressorNameSize
+	for n > 0 && !utf8.RuneStart(name[n]) {
+		n--
+	}
+	return name[:n]
+}
+
+// maxCompressorNameSize is the size of the longest compressor name of a visual
+// sample entry, the first of the 32 bytes of the field holding its length.
+const maxCompressorNameSize = 31
+
 func (p MoovProcessor) CreateHvc1Mp4Box() (hvc1 mp4.Box, err error) {
+	width, height := p.codedSize()
 	hvc1 = &mp4.VisualSampleEntryBox{
 		SampleEntry: mp4.SampleEntry{
 			Header:             mp4.Header{Type: mp4.BoxType(p.Codec)},
 			DataReferenceIndex: 1,
 		},
-		Width:           uint16(p.Width),
-		Height:          uint16(p.Height),
+		Width:           uint16(width),
+		Height:          uint16(height),
 		HorizResolution: 72, // 72 dpi
 		VertResolution:  72, // 72 dpi,
 		FrameCount:      1,
-		CompressorName:  "HEVC Coding",
+		CompressorName:  p.compressorName("HEVC Coding"),
 		Depth:           0x0018, // 0x0018 – images are in colour with no alpha.
 	}
 	hvcC, err := p.CreateHvcCMp4Box()
@@ -264,6 +746,13 @@ func (p MoovProcessor) CreateHvc1Mp4Box() (hvc1 mp4.Box, err error) {
 		return
 	}
 	children := []mp4.Box{hvcC}
+	dvcC, err := p.CreateDvcCMp4Box()
+	if err != nil {
+		return
+	}
+	if dvcC != nil {
+		children = append(children, dvcC)
+	}
 	if p.Protected {
 		hvc1.Mp4BoxSetType(mp4.EncvBoxType)
 
@@ -280,18 +769,33 @@ func (p MoovProcessor) CreateHvc1Mp4Box() (hvc1 mp4.Box, err error) {
 	return
 }
 
+// CreateDvcCMp4Box returns the Dolby Vision configuration box of the track,
+// which is nil for tracks without Dolby Vision.
+func (p MoovProcessor) CreateDvcCMp4Box() (dvcC mp4.Box, err error) {
+	config, err := p.DolbyVisionConfig()
+	if err != nil || config == nil {
+		return
+	}
+	dvcC = &mp4.DOVIConfigurationBox{
+		Header:     mp4.Header{Type: dolbyVisionConfigurationBoxType(config.Profile)},
+		DOVIConfig: *config,
+	}
+	return
+}
+
 func (p MoovProcessor) CreateAvc1Mp4Box() (avc1 mp4.Box, err error) {
+	width, height := p.codedSize()
 	avc1 = &mp4.VisualSampleEntryBox{
 		SampleEntry: mp4.SampleEntry{
 			Header:             mp4.Header{Type: mp4.BoxType(mp4.Avc1FourCC)},
 			DataReferenceIndex: 1,
 		},
-		Width:           uint16(p.Width),
-		Height:          uint16(p.Height),
+		Width:           uint16(width),
+		Height:          uint16(height),
 		HorizResolution: 72, // 72 dpi
 		VertResolution:  72, // 72 dpi,
 		FrameCount:      1,
-		CompressorName:  "AVC Coding",
+		CompressorName:  p.compressorName("AVC Coding"),
 		Depth:           0x0018, // 0x0018 – images are in colour with no alpha.
 	}
 	avcC, err := p.CreateAvcCMp4Box()
@@ -315,14 +819,465 @@ func (p MoovProcessor) CreateAvc1Mp4Box() (avc1 mp4.Box, err error) {
 	return
 }
 
+func (p MoovProcessor) CreateVc1Mp4Box() (vc1 mp4.Box, err error) {
+	config, err := ParseVC1Config(p.CodecPrivateData)
+	if err != nil {
+		return
+	}
+	width, height := p.Width, p.Height
+	if width == 0 || height == 0 {
+		width, height = config.Width, config.Height
+	}
+	vc1 = &mp4.VisualSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: Vc1BoxType},
+			DataReferenceIndex: 1,
+		},
+		Width:           uint16(width),
+		Height:          uint16(height),
+		HorizResolution: 72, // 72 dpi
+		VertResolution:  72, // 72 dpi,
+		FrameCount:      1,
+		CompressorName:  p.compressorName("VC-1 Coding"),
+		Depth:           0x0018, // 0x0018 – images are in colour with no alpha.
+	}
+	dvc1 := &VC1SpecificBox{
+		Profile:     VC1ProfileAdvanced,
+		Level:       config.Level,
+		NoInterlace: !config.Interlace,
+		// multiple sequence and entry point headers are not precluded since
+		// they are carried in band by Smooth Streaming fragments
+		Framerate:      config.Framerate(),
+		SequenceHeader: config.SequenceHeader,
+	}
+	children := []mp4.Box{dvc1}
+	if p.Protected {
+		vc1.Mp4BoxSetType(mp4.EncvBoxType)
+
+		var sinf mp4.Box
+		if sinf, err = p.CreateSinfMp4Box(); err != nil {
+			return
+		}
+
+		children = append(children, sinf)
+	}
+	if err = vc1.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	return
+}
+
+func (p MoovProcessor) CreateAv01Mp4Box() (av01 mp4.Box, err error) {
+	av1C, header, err := NewAV1CodecConfigurationBox(p.CodecPrivateData)
+	if err != nil {
+		return
+	}
+	width, height := p.Width, p.Height
+	if width == 0 || height == 0 {
+		width, height = header.MaxFrameWidth, header.MaxFrameHeight
+	}
+	av01 = &mp4.VisualSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: Av01BoxType},
+			DataReferenceIndex: 1,
+		},
+		Width:           uint16(width),
+		Height:          uint16(height),
+		HorizResolution: 72, // 72 dpi
+		VertResolution:  72, // 72 dpi,
+		FrameCount:      1,
+		CompressorName:  p.compressorName("AV1 Coding"),
+		Depth:           0x0018, // 0x0018 – images are in colour with no alpha.
+	}
+	children := []mp4.Box{av1C}
+	if p.Protected {
+		av01.Mp4BoxSetType(mp4.EncvBoxType)
+
+		var sinf mp4.Box
+		if sinf, err = p.CreateSinfMp4Box(); err != nil {
+			return
+		}
+
+		children = append(children, sinf)
+	}
+	if err = av01.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	return
+}
+
+func (p MoovProcessor) CreateVp09Mp4Box() (vp09 mp4.Box, err error) {
+	vpcC, err := NewVPCodecConfigurationBox(p.CodecPrivateData, p.CustomAttributes, p.Width, p.Height)
+	if err != nil {
+		return
+	}
+	vp09 = &mp4.VisualSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: Vp09BoxType},
+			DataReferenceIndex: 1,
+		},
+		Width:           uint16(p.Width),
+		Height:          uint16(p.Height),
+		HorizResolution: 72, // 72 dpi
+		VertResolution:  72, // 72 dpi,
+		FrameCount:      1,
+		CompressorName:  p.compressorName("VPC Coding"),
+		Depth:           0x0018, // 0x0018 – images are in colour with no alpha.
+	}
+	children := []mp4.Box{vpcC}
+	if p.Protected {
+		vp09.Mp4BoxSetType(mp4.EncvBoxType)
+
+		var sinf mp4.Box
+		if sinf, err = p.CreateSinfMp4Box(); err != nil {
+			return
+		}
+
+		children = append(children, sinf)
+	}
+	if err = vp09.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	return
+}
+
+func (p MoovProcessor) CreateMp4aMp4Box() (mp4a mp4.Box, err error) {
+	channels := p.Channels
+	if channels == 0 {
+		channels = 2
+	}
+	sampleSize := p.BitsPerSample
+	if sampleSize == 0 {
+		sampleSize = 16
+	}
+	mp4a = &AudioSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: Mp4aBoxType},
+			DataReferenceIndex: 1,
+		},
+		ChannelCount: channels,
+		SampleSize:   sampleSize,
+		SampleRate:   p.SamplingRate,
+	}
+	esds, err := p.CreateEsdsMp4Box()
+	if err != nil {
+		return
+	}
+	children := []mp4.Box{esds}
+	if p.Protected {
+		mp4a.Mp4BoxSetType(mp4.EncaBoxType)
+
+		var sinf mp4.Box
+		if sinf, err = p.CreateSinfMp4Box(); err != nil {
+			return
+		}
+
+		children = append(children, sinf)
+	}
+	if err = mp4a.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	return
+}
+
+func (p MoovProcessor) CreateEsdsMp4Box() (esds mp4.Box, err error) {
+	decoderSpecificInfo, err := p.CreateAudioSpecificConfig()
+	if err != nil {
+		return
+	}
+	esds = &ESDBox{
+		ObjectTypeIndication: ObjectTypeAudioISO14496,
+		StreamType:           StreamTypeAudio,
+		MaxBitrate:           p.Bitrate,
+		AvgBitrate:           p.Bitrate,
+		DecoderSpecificInfo:  decoderSpecificInfo,
+	}
+	return
+}
+
+// CreateAudioSpecificConfig returns the AAC AudioSpecificConfig of the track.
+// It is synthesized from the sampling rate and channel count for AACL tracks
+// whose CodecPrivateData is empty as permitted by the manifest specification.
+// If AudioObjectType declares HE-AAC or HE-AAC v2 and the configuration only
+// describes the AAC core, the SBR and PS extensions are signaled explicitly.
+func (p MoovProcessor) CreateAudioSpecificConfig() (config []byte, err error) {
+	heaac := p.AudioObjectType == AudioObjectTypeSBR || p.AudioObjectType == AudioObjectTypePS
+	var asc AudioSpecificConfig
+	if len(p.CodecPrivateData) > 0 {
+		var parseErr error
+		if asc, parseErr = ParseAudioSpecificConfig(p.CodecPrivateData); parseErr != nil || !heaac || asc.SBRPresent {
+			config = p.CodecPrivateData
+			return
+		}
+	} else if asc, err = NewAACAudioSpecificConfig(p.SamplingRate, p.Channels); err != nil {
+		return
+	}
+	if heaac {
+		asc = asc.WithSBR(p.AudioObjectType == AudioObjectTypePS, p.SamplingRate)
+	}
+	return asc.Bytes()
+}
+
+func (p MoovProcessor) CreateAc3Mp4Box() (ac3 mp4.Box, err error) {
+	channels := p.Channels
+	if channels == 0 {
+		channels = 2
+	}
+	ac3 = &AudioSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: mp4.BoxType(p.Codec)},
+			DataReferenceIndex: 1,
+		},
+		ChannelCount: channels,
+		SampleSize:   16,
+		SampleRate:   p.SamplingRate,
+	}
+	var config mp4.Box
+	if p.Codec == Ec3FourCC {
+		config, err = p.CreateDec3Mp4Box()
+	} else {
+		config, err = p.CreateDac3Mp4Box()
+	}
+	if err != nil {
+		return
+	}
+	children := []mp4.Box{config}
+	if p.Protected {
+		ac3.Mp4BoxSetType(mp4.EncaBoxType)
+
+		var sinf mp4.Box
+		if sinf, err = p.CreateSinfMp4Box(); err != nil {
+			return
+		}
+
+		children = append(children, sinf)
+	}
+	if err = ac3.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	return
+}
+
+func (p MoovProcessor) CreateDac3Mp4Box() (dac3 mp4.Box, err error) {
+	if data := dolbySpecificData(p.CodecPrivateData); len(data) > 0 {
+		return readConfigurationBox(&AC3SpecificBox{}, Dac3BoxType, data)
+	}
+	return NewAC3SpecificBox(p.SamplingRate, p.Channels, p.Bitrate)
+}
+
+func (p MoovProcessor) CreateDec3Mp4Box() (dec3 mp4.Box, err error) {
+	if data := dolbySpecificData(p.CodecPrivateData); len(data) > 0 {
+		return readConfigurationBox(&EC3SpecificBox{}, Dec3BoxType, data)
+	}
+	return NewEC3SpecificBox(p.SamplingRate, p.Channels, p.Bitrate)
+}
+
+func (p MoovProcessor) CreateDtsMp4Box() (dts mp4.Box, err error) {
+	ddts, err := p.CreateDdtsMp4Box()
+	if err != nil {
+		return
+	}
+	config := ddts.(*DTSSpecificBox)
+	channels := p.Channels
+	if channels == 0 {
+		channels = 2
+	}
+	dts = &AudioSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: mp4.BoxType(p.Codec)},
+			DataReferenceIndex: 1,
+		},
+		ChannelCount: channels,
+		SampleSize:   uint16(config.PCMSampleDepth),
+		SampleRate:   config.DTSSamplingFrequency,
+	}
+	children := []mp4.Box{ddts}
+	if p.Protected {
+		dts.Mp4BoxSetType(mp4.EncaBoxType)
+
+		var sinf mp4.Box
+		if sinf, err = p.CreateSinfMp4Box(); err != nil {
+			return
+		}
+
+		children = append(children, sinf)
+	}
+	if err = dts.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	return
+}
+
+// CreateDdtsMp4Box returns the DTS specific box of the track. CodecPrivateData
+// holding the payload of the box is used as is, otherwise the box is created
+// from the track attributes.
+func (p MoovProcessor) CreateDdtsMp4Box() (ddts mp4.Box, err error) {
+	if len(p.CodecPrivateData) > 0 {
+		return readConfigurationBox(&DTSSpecificBox{}, DdtsBoxType, p.CodecPrivateData)
+	}
+	return NewDTSSpecificBox(p.Codec, p.SamplingRate, p.Channels, p.BitsPerSample, p.Bitrate)
+}
+
+func (p MoovProcessor) CreateOwmaMp4Box() (owma mp4.Box, err error) {
+	wfex, err := p.CreateWfexMp4Box()
+	if err != nil {
+		return
+	}
+	wfx, err := ParseWaveFormatEx(wfex.(*WaveFormatExBox).WaveFormatEx)
+	if err != nil {
+		return
+	}
+	owma = &AudioSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: OwmaBoxType},
+			DataReferenceIndex: 1,
+		},
+		ChannelCount: wfx.Channels,
+		SampleSize:   wfx.BitsPerSample,
+		SampleRate:   wfx.SamplesPerSec,
+	}
+	children := []mp4.Box{wfex}
+	if p.Protected {
+		owma.Mp4BoxSetType(mp4.EncaBoxType)
+
+		var sinf mp4.Box
+		if sinf, err = p.CreateSinfMp4Box(); err != nil {
+			return
+		}
+
+		children = append(children, sinf)
+	}
+	if err = owma.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	return
+}
+
+// CreateWfexMp4Box returns the WAVEFORMATEX of a WMA track. CodecPrivateData
+// holding a complete structure, as required for AudioTag 65534, is used as
+// is. Otherwise the structure is built from the track attributes with
+// CodecPrivateData as its extra data.
+func (p MoovProcessor) CreateWfexMp4Box() (wfex mp4.Box, err error) {
+	if isWaveFormatEx(p.CodecPrivateData, p.AudioTag) {
+		wfex = &WaveFormatExBox{WaveFormatEx: p.CodecPrivateData}
+		return
+	}
+	if p.AudioTag == WaveFormatExtensible {
+		err = fmt.Errorf("CodecPrivateData of AudioTag %d is not a WAVEFORMATEX: %w", p.AudioTag, ErrInvalidParam)
+		return
+	}
+	if len(p.CodecPrivateData) > 0xffff {
+		err = fmt.Errorf("CodecPrivateData too large for WAVEFORMATEX: %w", ErrInvalidParam)
+		return
+	}
+	formatTag := p.AudioTag
+	if formatTag == 0 {
+		formatTag = WaveFormatWMAPro
+	}
+	wfx := WaveFormatEx{
+		FormatTag:      formatTag,
+		Channels:       p.Channels,
+		SamplesPerSec:  p.SamplingRate,
+		AvgBytesPerSec: p.Bitrate / 8,
+		BlockAlign:     uint16(p.PacketSize),
+		BitsPerSample:  p.BitsPerSample,
+		ExtraData:      p.CodecPrivateData,
+	}
+	wfex = &WaveFormatExBox{WaveFormatEx: wfx.Bytes()}
+	return
+}
+
+func (p MoovProcessor) CreateStppMp4Box() (stpp mp4.Box, err error) {
+	if p.Protected {
+		err = fmt.Errorf("protected %s sample entry not supported: %w", p.Codec, ErrUnknownCodec)
+		return
+	}
+	stpp = &XMLSubtitleSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: StppBoxType},
+			DataReferenceIndex: 1,
+		},
+		Namespace: TTMLNamespace,
+	}
+	return
+}
+
+// CreateMettMp4Box returns the sample entry of a timed metadata track whose
+// samples are of the MetadataMIMEType, by default application/octet-stream as
+// the events of DATA, SCMD and CTRL streams are opaque to this package.
+func (p MoovProcessor) CreateMettMp4Box() (mett mp4.Box, err error) {
+	if p.Protected {
+		err = fmt.Errorf("protected %s sample entry not supported: %w", p.Codec, ErrUnknownCodec)
+		return
+	}
+	mimeFormat := p.MetadataMIMEType
+	if mimeFormat == "" {
+		mimeFormat = "application/octet-stream"
+	}
+	mett = &TextMetaDataSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: MettBoxType},
+			DataReferenceIndex: 1,
+		},
+		MIMEFormat: mp4.NullTerminatedString(mimeFormat),
+	}
+	return
+}
+
+// CreateUrimMp4Box returns the sample entry of a timed metadata track whose
+// sample format is identified by the MetadataURI, e.g. the scheme of the
+// events. CodecPrivateData, if any, is carried as the initialization data of
+// the format.
+func (p MoovProcessor) CreateUrimMp4Box() (urim mp4.Box, err error) {
+	if p.Protected {
+		err = fmt.Errorf("protected %s sample entry not supported: %w", p.Codec, ErrUnknownCodec)
+		return
+	}
+	if p.MetadataURI == "" {
+		err = fmt.Errorf("%s sample entry requires a MetadataURI: %w", p.Codec, ErrInvalidParam)
+		return
+	}
+	children := []mp4.Box{&URIBox{TheURI: mp4.NullTerminatedString(p.MetadataURI)}}
+	if len(p.CodecPrivateData) > 0 {
+		children = append(children, &URIInitBox{URIInitializationData: p.CodecPrivateData})
+	}
+	urim = &URIMetaSampleEntryBox{
+		SampleEntry: mp4.SampleEntry{
+			Header:             mp4.Header{Type: UrimBoxType},
+			DataReferenceIndex: 1,
+		},
+	}
+	if err = urim.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	return
+}
+
+// readConfigurationBox parses the payload of a decoder configuration box
+// that is carried without its box header in CodecPrivateData.
+func readConfigurationBox(box mp4.Box, boxType mp4.BoxType, payload []byte) (config mp4.Box, err error) {
+	header := mp4.Header{Size: 8 + uint32(len(payload)), Type: boxType}
+	if err = box.Mp4BoxRead(bytes.NewReader(payload), &header); err != nil {
+		err = fmt.Errorf("invalid CodecPrivateData for %s: %w", boxType, ErrInvalidParam)
+		return
+	}
+	box.Mp4BoxUpdate()
+	config = box
+	return
+}
+
 func (p MoovProcessor) CreateSinfMp4Box() (sinf mp4.Box, err error) {
+	scheme, err := p.encryptionScheme()
+	if err != nil {
+		return
+	}
 	sinf = &mp4.ProtectionSchemeInfoBox{}
 	frmt := &mp4.OriginalFormatBox{
 		DataFormat: p.Codec,
 	}
 	schm := &mp4.SchemeTypeBox{
-		SchemeType:    mp4.CencFourCC, // 'cenc' => common encryption
-		SchemeVersion: 0x00010000,     // version set to 0x00010000 (Major version 1, Minor version 0)
+		SchemeType:    scheme,     // 'cenc' => common encryption, 'cbcs' => AES-CBC pattern encryption
+		SchemeVersion: 0x00010000, // version set to 0x00010000 (Major version 1, Minor version 0)
 	}
 	schi, err := p.CreateSchiMp4Box()
 	if err != nil {
@@ -334,12 +1289,36 @@ func (p MoovProcessor) CreateSinfMp4Box() (sinf mp4.Box, err error) {
 	return
 }
 
+// CreateSchiMp4Box returns the scheme information of the track. The 'cenc'
+// scheme uses 8-byte per-sample IVs. The 'cbcs' scheme uses a version 1 tenc
+// carrying the protection pattern, 1:9 for video unless set, and the
+// ConstantIV if one is given, otherwise 16-byte per-sample IVs.
 func (p MoovProcessor) CreateSchiMp4Box() (schi mp4.Box, err error) {
-	tenc := &mp4.TrackEncryptionBox{
+	scheme, err := p.encryptionScheme()
+	if err != nil {
+		return
+	}
+	tenc := &TrackEncryptionBox{
 		DefaultIsProtected:     1,
 		DefaultPerSampleIVSize: 8,
 		DefaultKID:             p.KID,
 	}
+	if scheme == CbcsFourCC {
+		tenc.Version = 1
+		if tenc.DefaultCryptByteBlock, tenc.DefaultSkipByteBlock, err = p.encryptionPattern(); err != nil {
+			return
+		}
+		switch len(p.ConstantIV) {
+		case 0:
+			tenc.DefaultPerSampleIVSize = 16
+		case 8, 16:
+			tenc.DefaultPerSampleIVSize = 0
+			tenc.DefaultConstantIV = p.ConstantIV
+		default:
+			err = fmt.Errorf("constant IV of %d bytes: %w", len(p.ConstantIV), ErrInvalidParam)
+			return
+		}
+	}
 	schi = &mp4.SchemeInformationBox{}
 	if err = schi.Mp4BoxReplaceChildren([]mp4.Box{tenc}); err != nil {
 		return
@@ -347,6 +1326,33 @@ func (p MoovProcessor) CreateSchiMp4Box() (schi mp4.Box, err error) {
 	return
 }
 
+// encryptionPattern returns the crypt and skip byte blocks of the 'cbcs'
+// protection pattern of the track, 1:9 for video unless set.
+func (p MoovProcessor) encryptionPattern() (cryptByteBlock, skipByteBlock uint8, err error) {
+	cryptByteBlock, skipByteBlock = p.CryptByteBlock, p.SkipByteBlock
+	if cryptByteBlock == 0 && skipByteBlock == 0 && p.StreamType == VideoStream {
+		cryptByteBlock, skipByteBlock = 1, 9
+	}
+	if cryptByteBlock > 0x0f || skipByteBlock > 0x0f {
+		err = fmt.Errorf("cbcs pattern %d:%d exceeds 4 bits: %w", cryptByteBlock, skipByteBlock, ErrInvalidParam)
+	}
+	return
+}
+
+// encryptionScheme returns the protection scheme of the track, 'cenc' unless
+// EncryptionScheme is set.
+func (p MoovProcessor) encryptionScheme() (scheme mp4.FourCC, err error) {
+	switch p.EncryptionScheme {
+	case mp4.FourCC{}, mp4.CencFourCC:
+		scheme = mp4.CencFourCC
+	case CbcsFourCC:
+		scheme = CbcsFourCC
+	default:
+		err = fmt.Errorf("encryption scheme %s: %w", p.EncryptionScheme, ErrInvalidParam)
+	}
+	return
+}
+
 func (p MoovProcessor) CreateAvcCMp4Box() (avcC mp4.Box, err error) {
 	nalus := bytes.Split(p.CodecPrivateData, []byte{0, 0, 0, 1})
 	if len(nalus) < 1 {
@@ -356,6 +1362,10 @@ func (p MoovProcessor) CreateAvcCMp4Box() (avcC mp4.Box, err error) {
 	var sps []avc.AVCSequenceParameterSet
 	var pps []avc.AVCPictureParameterSet
 	for _, nalu := range nalus[1:] {
+		// consecutive start codes leave empty NAL units
+		if len(nalu) == 0 {
+			continue
+		}
 		naluType := avc.GetNaluType(nalu[0])
 		switch naluType {
 		case avc.NALU_SPS:
@@ -364,22 +1374,29 @@ func (p MoovProcessor) CreateAvcCMp4Box() (avcC mp4.Box, err error) {
 			pps = append(pps, avc.AVCPictureParameterSet{NALUnit: nalu})
 		}
 	}
-	var avcProfile, avcProfileCompatibility, avcLevel uint8
-	if len(sps) > 0 {
-		avcProfile = sps[0].NALUnit[1]
-		avcProfileCompatibility = sps[0].NALUnit[2]
-		avcLevel = sps[0].NALUnit[3]
+	if len(sps) == 0 {
+		err = fmt.Errorf("no SPS in CodecPrivateData for avcC: %w", ErrInvalidParam)
+		return
+	}
+	parsed, err := ParseAVCSPS(sps[0].NALUnit)
+	if err != nil {
+		err = fmt.Errorf("invalid CodecPrivateData for avcC: %w", err)
+		return
+	}
+	config := avc.AVCDecoderConfigurationRecord{
+		ConfigurationVersion:  1,
+		AVCProfileIndication:  parsed.ProfileIdc,
+		ProfileCompatibility:  parsed.ConstraintFlags,
+		AVCLevelIndication:    parsed.LevelIdc,
+		LengthSizeMinusOne:    3,
+		SequenceParameterSets: sps,
+		PictureParameterSets:  pps,
+		ChromaFormat:          parsed.ChromaFormatIdc,
+		BitDepthLumaMinus8:    parsed.BitDepthLumaMinus8,
+		BitDepthChromaMinus8:  parsed.BitDepthChromaMinus8,
 	}
 	avcC = &mp4.AVCConfigurationBox{
-		AVCConfig: avc.AVCDecoderConfigurationRecord{
-			ConfigurationVersion:  1,
-			AVCProfileIndication:  avcProfile,
-			ProfileCompatibility:  avcProfileCompatibility,
-			AVCLevelIndication:    avcLevel,
-			LengthSizeMinusOne:    3,
-			SequenceParameterSets: sps,
-			PictureParameterSets:  pps,
-		},
+		AVCConfig: config,
 	}
 	return
 }
@@ -392,6 +1409,9 @@ func (p MoovProcessor) CreateHvcCMp4Box() (hvcC mp4.Box, err error) {
 	}
 	var vpsNalus, spsNalus, ppsNalus [][]byte
 	for _, nalu := range nalus[1:] {
+		if len(nalu) == 0 {
+			continue
+		}
 		naluType := hevc.GetNaluType(nalu[0])
 		switch naluType {
 		case hevc.NALU_VPS:
@@ -403,13 +1423,24 @@ func (p MoovProcessor) CreateHvcCMp4Box() (hvcC mp4.Box, err error) {
 		}
 	}
 	if len(spsNalus) == 0 {
-		err = fmt.Errorf("cannot find hevc sps nalu")
+		err = fmt.Errorf("no SPS in CodecPrivateData for hvcC: %w", ErrInvalidParam)
 		return
 	}
 	conf, err := hevc.CreateHEVCDecoderConfigurationRecord(vpsNalus, spsNalus, ppsNalus, true, true, true)
 	if err != nil {
 		return
 	}
+	// hevc.ParseSPSNALUnit stops at the profile of SPSs with temporal
+	// sub-layers, leaving the chroma format and bit depths unset
+	if sps, spsErr := ParseHEVCSPS(spsNalus[0]); spsErr == nil {
+		conf.ChromaFormatIndicator = sps.ChromaFormatIdc
+		conf.BitDepthLumaMinus8 = sps.BitDepthLumaMinus8
+		conf.BitDepthChromaMinus8 = sps.BitDepthChromaMinus8
+		conf.NumTemporalLayers = sps.MaxSubLayersMinus1 + 1
+		if sps.TemporalIDNesting {
+			conf.TemporalIDNested = 1
+		}
+	}
 	hvcC = &mp4.HEVCConfigurationBox{
 		HEVCConfig: conf,
 	}
@@ -444,6 +1475,13 @@ func (p MoovProcessor) CreateMhdMp4Box() (mhd mp4.Box, err error) {
 		mhd = &mp4.VideoMediaHeaderBox{}
 	case AudioStream:
 		mhd = &mp4.SoundMediaHeaderBox{}
+	case TextStream:
+		switch p.Codec {
+		case StppFourCC:
+			mhd = &SubtitleMediaHeaderBox{}
+		case MettFourCC, UrimFourCC:
+			mhd = &mp4.NullMediaHeaderBox{}
+		}
 	}
 	return
 }
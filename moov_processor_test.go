@@ -0,0 +1,1489 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+// roundTripBox writes box and reads it back.
+func roundTripBox(t *testing.T, box mp4.Box) mp4.Box {
+	t.Helper()
+	size := box.Mp4BoxUpdate()
+	var buf bytes.Buffer
+	if err := box.Mp4BoxWrite(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if uint32(buf.Len()) != size {
+		t.Fatalf("wrote %d bytes, Mp4BoxUpdate() = %d", buf.Len(), size)
+	}
+	read, err := mp4.ReadBox(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("%d bytes left after reading the box", buf.Len())
+	}
+	return read
+}
+
+func TestESDBoxRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		info []byte
+	}{
+		{name: "AudioSpecificConfig", info: []byte{0x12, 0x10}},
+		{name: "no DecoderSpecificInfo"},
+		{name: "multi-byte descriptor sizes", info: bytes.Repeat([]byte{0x5a}, 300)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			esds := &ESDBox{
+				ObjectTypeIndication: ObjectTypeAudioISO14496,
+				StreamType:           StreamTypeAudio,
+				BufferSizeDB:         1536,
+				MaxBitrate:           128000,
+				AvgBitrate:           96000,
+				DecoderSpecificInfo:  tt.info,
+			}
+			got, ok := roundTripBox(t, esds).(*ESDBox)
+			if !ok {
+				t.Fatalf("read %T, want *ESDBox", got)
+			}
+			if got.ObjectTypeIndication != esds.ObjectTypeIndication || got.StreamType != esds.StreamType ||
+				got.BufferSizeDB != esds.BufferSizeDB || got.MaxBitrate != esds.MaxBitrate || got.AvgBitrate != esds.AvgBitrate {
+				t.Errorf("read %+v, want %+v", got, esds)
+			}
+			if !bytes.Equal(got.DecoderSpecificInfo, tt.info) {
+				t.Errorf("DecoderSpecificInfo = %x, want %x", got.DecoderSpecificInfo, tt.info)
+			}
+		})
+	}
+}
+
+func TestCreateMp4aMp4Box(t *testing.T) {
+	tests := []struct {
+		name           string
+		processor      MoovProcessor
+		wantChannels   uint16
+		wantSampleSize uint16
+		wantSampleRate uint32
+		wantConfig     []byte
+		wantErr        error
+	}{
+		{
+			name:           "defaults",
+			processor:      MoovProcessor{SamplingRate: 44100, Bitrate: 128000, CodecPrivateData: []byte{0x12, 0x10}},
+			wantChannels:   2,
+			wantSampleSize: 16,
+			wantSampleRate: 44100,
+			wantConfig:     []byte{0x12, 0x10},
+		},
+		{
+			name:           "explicit format",
+			processor:      MoovProcessor{SamplingRate: 48000, Channels: 6, BitsPerSample: 24, CodecPrivateData: []byte{0x11, 0xb0}},
+			wantChannels:   6,
+			wantSampleSize: 24,
+			wantSampleRate: 48000,
+			wantConfig:     []byte{0x11, 0xb0},
+		},
+		{
+			name:           "sampling rate beyond the sample entry",
+			processor:      MoovProcessor{SamplingRate: 96000, CodecPrivateData: []byte{0x10, 0x10}},
+			wantChannels:   2,
+			wantSampleSize: 16,
+			wantConfig:     []byte{0x10, 0x10},
+		},
+		{
+			name:           "synthesized AudioSpecificConfig",
+			processor:      MoovProcessor{SamplingRate: 48000, Channels: 1},
+			wantChannels:   1,
+			wantSampleSize: 16,
+			wantSampleRate: 48000,
+			wantConfig:     []byte{0x11, 0x88},
+		},
+		{
+			name:      "no CodecPrivateData nor channels",
+			processor: MoovProcessor{SamplingRate: 44100},
+			wantErr:   ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.Codec = Mp4aFourCC
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateSampleEntryMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			mp4a, ok := roundTripBox(t, box).(*AudioSampleEntryBox)
+			if !ok {
+				t.Fatalf("read %T, want *AudioSampleEntryBox", mp4a)
+			}
+			if mp4a.Type != Mp4aBoxType || mp4a.DataReferenceIndex != 1 {
+				t.Errorf("type %s, data reference index %d, want mp4a, 1", mp4a.Type, mp4a.DataReferenceIndex)
+			}
+			if mp4a.ChannelCount != tt.wantChannels || mp4a.SampleSize != tt.wantSampleSize || mp4a.SampleRate != tt.wantSampleRate {
+				t.Errorf("channels %d, sample size %d, sample rate %d, want %d, %d, %d",
+					mp4a.ChannelCount, mp4a.SampleSize, mp4a.SampleRate, tt.wantChannels, tt.wantSampleSize, tt.wantSampleRate)
+			}
+			children := mp4a.Mp4BoxChildren()
+			if len(children) == 0 {
+				t.Fatal("got no children, want the esds box")
+			}
+			for _, child := range children[1:] {
+				if _, ok := child.(*ChannelLayoutBox); !ok {
+					t.Errorf("child %T after the esds box, want *ChannelLayoutBox", child)
+				}
+			}
+			esds, ok := children[0].(*ESDBox)
+			if !ok {
+				t.Fatalf("child %T, want *ESDBox", children[0])
+			}
+			if !bytes.Equal(esds.DecoderSpecificInfo, tt.wantConfig) {
+				t.Errorf("DecoderSpecificInfo = %x, want %x", esds.DecoderSpecificInfo, tt.wantConfig)
+			}
+			if esds.MaxBitrate != tt.processor.Bitrate || esds.AvgBitrate != tt.processor.Bitrate {
+				t.Errorf("bitrates %d, %d, want %d", esds.MaxBitrate, esds.AvgBitrate, tt.processor.Bitrate)
+			}
+		})
+	}
+}
+
+func TestCreateAudioSpecificConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		want      []byte
+	}{
+		{
+			name:      "AAC LC",
+			processor: MoovProcessor{AudioObjectType: AudioObjectTypeAACLC, SamplingRate: 44100, CodecPrivateData: []byte{0x12, 0x10}},
+			want:      []byte{0x12, 0x10},
+		},
+		{
+			name:      "HE-AAC core configuration",
+			processor: MoovProcessor{AudioObjectType: AudioObjectTypeSBR, SamplingRate: 48000, CodecPrivateData: []byte{0x13, 0x10}},
+			want:      []byte{0x2b, 0x11, 0x88, 0x00},
+		},
+		{
+			name:      "HE-AAC configuration",
+			processor: MoovProcessor{AudioObjectType: AudioObjectTypeSBR, SamplingRate: 48000, CodecPrivateData: []byte{0x2b, 0x11, 0x88, 0x00}},
+			want:      []byte{0x2b, 0x11, 0x88, 0x00},
+		},
+		{
+			name:      "HE-AAC v2 without CodecPrivateData",
+			processor: MoovProcessor{AudioObjectType: AudioObjectTypePS, SamplingRate: 48000, Channels: 2},
+			want:      []byte{0xeb, 0x09, 0x88, 0x00},
+		},
+		{
+			name:      "unparsed configuration",
+			processor: MoovProcessor{AudioObjectType: AudioObjectTypeSBR, SamplingRate: 48000, CodecPrivateData: []byte{0x32, 0x10}},
+			want:      []byte{0x32, 0x10},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.processor.CreateAudioSpecificConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("CreateAudioSpecificConfig() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAc3Mp4Box(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		want      mp4.Box
+		wantErr   error
+	}{
+		{
+			name:      "dac3 from CodecPrivateData",
+			processor: MoovProcessor{Codec: Ac3FourCC, SamplingRate: 48000, Channels: 6, CodecPrivateData: []byte{0x10, 0x3d, 0xe0}},
+			want:      &AC3SpecificBox{Fscod: 0, Bsid: 8, Acmod: 7, Lfeon: true, BitRateCode: 15},
+		},
+		{
+			name:      "synthesized dac3",
+			processor: MoovProcessor{Codec: Ac3FourCC, SamplingRate: 48000, Channels: 2, Bitrate: 192000},
+			want:      &AC3SpecificBox{Fscod: 0, Bsid: 8, Acmod: 2, BitRateCode: 10},
+		},
+		{
+			name:      "dec3 from WAVEFORMATEXTENSIBLE",
+			processor: MoovProcessor{Codec: Ec3FourCC, SamplingRate: 48000, Channels: 6, CodecPrivateData: append(append([]byte{}, ec3SubFormat...), 0x0c, 0x00, 0x20, 0x0f, 0x00)},
+			want:      &EC3SpecificBox{DataRate: 384, IndependentSubstreams: []EC3IndependentSubstream{{Bsid: 16, Acmod: 7, Lfeon: true}}},
+		},
+		{
+			name:      "synthesized dec3",
+			processor: MoovProcessor{Codec: Ec3FourCC, SamplingRate: 48000, Channels: 6, Bitrate: 384000},
+			want:      &EC3SpecificBox{DataRate: 384, IndependentSubstreams: []EC3IndependentSubstream{{Bsid: 16, Acmod: 7, Lfeon: true}}},
+		},
+		{
+			name:      "truncated dec3",
+			processor: MoovProcessor{Codec: Ec3FourCC, SamplingRate: 48000, Channels: 6, CodecPrivateData: []byte{0x0c}},
+			wantErr:   ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateSampleEntryMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			entry, ok := roundTripBox(t, box).(*AudioSampleEntryBox)
+			if !ok {
+				t.Fatalf("read %T, want *AudioSampleEntryBox", entry)
+			}
+			if entry.Type != mp4.BoxType(tt.processor.Codec) || entry.ChannelCount != tt.processor.Channels || entry.SampleRate != tt.processor.SamplingRate {
+				t.Errorf("type %s, channels %d, sample rate %d, want %s, %d, %d",
+					entry.Type, entry.ChannelCount, entry.SampleRate, tt.processor.Codec, tt.processor.Channels, tt.processor.SamplingRate)
+			}
+			children := entry.Mp4BoxChildren()
+			if len(children) == 0 {
+				t.Fatal("got no children, want the configuration box")
+			}
+			for _, child := range children[1:] {
+				if _, ok := child.(*ChannelLayoutBox); !ok {
+					t.Errorf("child %T after the configuration box, want *ChannelLayoutBox", child)
+				}
+			}
+			tt.want.Mp4BoxUpdate()
+			if !reflect.DeepEqual(children[0], tt.want) {
+				t.Errorf("configuration %+v, want %+v", children[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateVc1Mp4Box(t *testing.T) {
+	tests := []struct {
+		name       string
+		processor  MoovProcessor
+		wantWidth  uint16
+		wantHeight uint16
+		wantDvc1   VC1SpecificBox
+	}{
+		{
+			name:       "size from the sequence header",
+			processor:  MoovProcessor{CodecPrivateData: decodeHex(t, testVC1SequenceHeader)},
+			wantWidth:  1280,
+			wantHeight: 720,
+			wantDvc1:   VC1SpecificBox{Profile: VC1ProfileAdvanced, Level: 3, NoInterlace: true, Framerate: 0xffffffff},
+		},
+		{
+			name:       "size of the track",
+			processor:  MoovProcessor{Width: 960, Height: 540, CodecPrivateData: testVC1VideoInfoHeader(t, 333667)},
+			wantWidth:  960,
+			wantHeight: 540,
+			wantDvc1:   VC1SpecificBox{Profile: VC1ProfileAdvanced, Level: 3, NoInterlace: true, Framerate: 29},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.Codec = Vc1FourCC
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			vc1, ok := roundTripBox(t, box).(*mp4.VisualSampleEntryBox)
+			if !ok {
+				t.Fatalf("read %T, want *mp4.VisualSampleEntryBox", vc1)
+			}
+			if vc1.Type != Vc1BoxType || vc1.Width != tt.wantWidth || vc1.Height != tt.wantHeight {
+				t.Errorf("type %s, size %dx%d, want %s, %dx%d", vc1.Type, vc1.Width, vc1.Height, Vc1BoxType, tt.wantWidth, tt.wantHeight)
+			}
+			children := vc1.Mp4BoxChildren()
+			if len(children) != 1 {
+				t.Fatalf("got %d children, want the dvc1 box", len(children))
+			}
+			dvc1, ok := children[0].(*VC1SpecificBox)
+			if !ok {
+				t.Fatalf("child %T, want *VC1SpecificBox", children[0])
+			}
+			if !bytes.Equal(dvc1.SequenceHeader, decodeHex(t, testVC1SequenceHeader)) {
+				t.Errorf("SequenceHeader = %x, want %s", dvc1.SequenceHeader, testVC1SequenceHeader)
+			}
+			tt.wantDvc1.Header, tt.wantDvc1.SequenceHeader = dvc1.Header, dvc1.SequenceHeader
+			if !reflect.DeepEqual(*dvc1, tt.wantDvc1) {
+				t.Errorf("dvc1 = %+v, want %+v", *dvc1, tt.wantDvc1)
+			}
+		})
+	}
+	if _, err := (MoovProcessor{Codec: Vc1FourCC}).CreateSampleEntryMp4Box(); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("CreateSampleEntryMp4Box() without CodecPrivateData error = %v, want %v", err, ErrUnknownCodec)
+	}
+}
+
+func TestCreateOwmaMp4Box(t *testing.T) {
+	extraData := []byte{0x10, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	wfx := WaveFormatEx{FormatTag: WaveFormatWMAPro, Channels: 6, SamplesPerSec: 48000, AvgBytesPerSec: 48000, BlockAlign: 8192, BitsPerSample: 24, ExtraData: extraData}
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		want      WaveFormatEx
+		wantErr   error
+	}{
+		{
+			name:      "WAVEFORMATEX",
+			processor: MoovProcessor{AudioTag: WaveFormatWMAPro, SamplingRate: 44100, Channels: 2, CodecPrivateData: wfx.Bytes()},
+			want:      wfx,
+		},
+		{
+			name:      "extra data",
+			processor: MoovProcessor{AudioTag: WaveFormatWMAPro, SamplingRate: 48000, Channels: 6, BitsPerSample: 24, Bitrate: 384000, PacketSize: 8192, CodecPrivateData: extraData},
+			want:      wfx,
+		},
+		{
+			name:      "default format tag",
+			processor: MoovProcessor{SamplingRate: 48000, Channels: 6, BitsPerSample: 24, Bitrate: 384000, PacketSize: 8192, CodecPrivateData: extraData},
+			want:      wfx,
+		},
+		{
+			name:      "WAVEFORMATEXTENSIBLE without the structure",
+			processor: MoovProcessor{AudioTag: WaveFormatExtensible, CodecPrivateData: extraData},
+			wantErr:   ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.Codec = OwmaFourCC
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateSampleEntryMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			owma, ok := roundTripBox(t, box).(*AudioSampleEntryBox)
+			if !ok {
+				t.Fatalf("read %T, want *AudioSampleEntryBox", owma)
+			}
+			if owma.Type != OwmaBoxType || owma.ChannelCount != tt.want.Channels || owma.SampleSize != tt.want.BitsPerSample || owma.SampleRate != tt.want.SamplesPerSec {
+				t.Errorf("type %s, channels %d, sample size %d, sample rate %d, want owma, %d, %d, %d",
+					owma.Type, owma.ChannelCount, owma.SampleSize, owma.SampleRate, tt.want.Channels, tt.want.BitsPerSample, tt.want.SamplesPerSec)
+			}
+			children := owma.Mp4BoxChildren()
+			if len(children) == 0 {
+				t.Fatal("got no children, want the wfex box")
+			}
+			for _, child := range children[1:] {
+				if _, ok := child.(*ChannelLayoutBox); !ok {
+					t.Errorf("child %T after the wfex box, want *ChannelLayoutBox", child)
+				}
+			}
+			wfex, ok := children[0].(*WaveFormatExBox)
+			if !ok {
+				t.Fatalf("child %T, want *WaveFormatExBox", children[0])
+			}
+			if !bytes.Equal(wfex.WaveFormatEx, tt.want.Bytes()) {
+				t.Errorf("WAVEFORMATEX = %x, want %x", wfex.WaveFormatEx, tt.want.Bytes())
+			}
+		})
+	}
+}
+
+func TestCreateStppMp4Box(t *testing.T) {
+	p := MoovProcessor{Codec: StppFourCC, StreamType: TextStream, Timescale: 10000000}
+	box, err := p.CreateSampleEntryMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stpp, ok := roundTripBox(t, box).(*XMLSubtitleSampleEntryBox)
+	if !ok {
+		t.Fatalf("read %T, want *XMLSubtitleSampleEntryBox", stpp)
+	}
+	if stpp.Namespace != TTMLNamespace || stpp.SchemaLocation != "" || stpp.AuxiliaryMIMETypes != "" || stpp.DataReferenceIndex != 1 {
+		t.Errorf("stpp = %+v, want namespace %s", stpp, TTMLNamespace)
+	}
+
+	mdia, err := p.CreateMdiaMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hdlr *mp4.HandlerBox
+	var sthd *SubtitleMediaHeaderBox
+	for _, child := range mdia.Mp4BoxChildren() {
+		switch child := child.(type) {
+		case *mp4.HandlerBox:
+			hdlr = child
+		case *mp4.MediaInformationBox:
+			for _, child := range child.Mp4BoxChildren() {
+				if child, ok := child.(*SubtitleMediaHeaderBox); ok {
+					sthd = child
+				}
+			}
+		}
+	}
+	if hdlr == nil || hdlr.HandlerType != SubtFourCC {
+		t.Errorf("handler %+v, want subt", hdlr)
+	}
+	if sthd == nil {
+		t.Error("no sthd box in the media information box")
+	}
+
+	p.Protected = true
+	if _, err = p.CreateSampleEntryMp4Box(); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("CreateSampleEntryMp4Box() of a protected track error = %v, want %v", err, ErrUnknownCodec)
+	}
+}
+
+func TestCreateAv01Mp4Box(t *testing.T) {
+	codecPrivateData := decodeHex(t, testAV1OBU(OBUSequenceHeader, testAV1SequenceHeader))
+	tests := []struct {
+		name       string
+		processor  MoovProcessor
+		wantWidth  uint16
+		wantHeight uint16
+	}{
+		{name: "size from the sequence header", processor: MoovProcessor{CodecPrivateData: codecPrivateData}, wantWidth: 1920, wantHeight: 1080},
+		{name: "size of the track", processor: MoovProcessor{Width: 1280, Height: 720, CodecPrivateData: codecPrivateData}, wantWidth: 1280, wantHeight: 720},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.Codec = Av01FourCC
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			av01, ok := roundTripBox(t, box).(*mp4.VisualSampleEntryBox)
+			if !ok {
+				t.Fatalf("read %T, want *mp4.VisualSampleEntryBox", av01)
+			}
+			if av01.Type != Av01BoxType || av01.Width != tt.wantWidth || av01.Height != tt.wantHeight {
+				t.Errorf("type %s, size %dx%d, want %s, %dx%d", av01.Type, av01.Width, av01.Height, Av01BoxType, tt.wantWidth, tt.wantHeight)
+			}
+			children := av01.Mp4BoxChildren()
+			if len(children) != 1 {
+				t.Fatalf("got %d children, want the av1C box", len(children))
+			}
+			av1C, ok := children[0].(*AV1CodecConfigurationBox)
+			if !ok {
+				t.Fatalf("child %T, want *AV1CodecConfigurationBox", children[0])
+			}
+			if av1C.SeqLevelIdx0 != 8 || !av1C.ChromaSubsamplingX || !av1C.ChromaSubsamplingY || !bytes.Equal(av1C.ConfigOBUs, codecPrivateData) {
+				t.Errorf("av1C = %+v", av1C)
+			}
+		})
+	}
+	if _, err := (MoovProcessor{Codec: Av01FourCC, CodecPrivateData: []byte{0x12, 0x00}}).CreateSampleEntryMp4Box(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("CreateSampleEntryMp4Box() without sequence header error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestCreateVp09Mp4Box(t *testing.T) {
+	p := MoovProcessor{
+		Codec:            Vp09FourCC,
+		Width:            3840,
+		Height:           2160,
+		CustomAttributes: &CustomAttributes{Attributes: []*Attribute{{Name: "codecs", Value: "vp09.02.50.10.01.09.16.09.01"}}},
+	}
+	box, err := p.CreateSampleEntryMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vp09, ok := roundTripBox(t, box).(*mp4.VisualSampleEntryBox)
+	if !ok {
+		t.Fatalf("read %T, want *mp4.VisualSampleEntryBox", vp09)
+	}
+	if vp09.Type != Vp09BoxType || vp09.Width != 3840 || vp09.Height != 2160 {
+		t.Errorf("type %s, size %dx%d, want vp09, 3840x2160", vp09.Type, vp09.Width, vp09.Height)
+	}
+	children := vp09.Mp4BoxChildren()
+	if len(children) != 1 {
+		t.Fatalf("got %d children, want the vpcC box", len(children))
+	}
+	vpcC, ok := children[0].(*VPCodecConfigurationBox)
+	if !ok {
+		t.Fatalf("child %T, want *VPCodecConfigurationBox", children[0])
+	}
+	want := VPCodecConfigurationBox{Profile: 2, Level: 50, BitDepth: 10, ChromaSubsampling: 1, ColourPrimaries: 9, TransferCharacteristics: 16, MatrixCoefficients: 9, VideoFullRangeFlag: true, CodecInitializationData: []byte{}}
+	want.FullHeader = vpcC.FullHeader
+	if !reflect.DeepEqual(*vpcC, want) {
+		t.Errorf("vpcC = %+v, want %+v", *vpcC, want)
+	}
+	if vpcC.Version != 1 {
+		t.Errorf("vpcC version %d, want 1", vpcC.Version)
+	}
+}
+
+// testH264CodecPrivateData is the CodecPrivateData of a 1280x720 High profile
+// H.264 track holding its SPS and PPS.
+const testH264CodecPrivateData = "000000016764001facd9405005bb011000000300100000030320f18319600000000168ebe3cb22c0"
+
+// testHEVCCodecPrivateData is the CodecPrivateData of a 1920x1080 Main
+// profile HEVC track holding its VPS, SPS and PPS.
+const testHEVCCodecPrivateData = "00000001" + "40010c01ffff016000000300900000030000030078959809" +
+	"00000001" + "420101016000000300900000030000030078a005020171f2e596a4932bc05a70808080820000030002000003003210" +
+	"00000001" + "4401c172b46240"
+
+func TestCreateHvc1Mp4BoxDolbyVision(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    mp4.FourCC
+		wantType mp4.BoxType
+		wantDv   bool
+	}{
+		{name: "HEVC", codec: mp4.Hvc1FourCC, wantType: mp4.Hvc1BoxType},
+		{name: "dvh1", codec: mp4.Dvh1FourCC, wantType: mp4.Dvh1BoxType, wantDv: true},
+		{name: "dvhe", codec: mp4.DvheFourCC, wantType: mp4.DvheBoxType, wantDv: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := MoovProcessor{Codec: tt.codec, Width: 1920, Height: 1080, CodecPrivateData: decodeHex(t, testHEVCCodecPrivateData)}
+			box, err := p.CreateSampleEntryMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := box.Mp4BoxType(); got != tt.wantType {
+				t.Errorf("sample entry %s, want %s", got, tt.wantType)
+			}
+			var dvcC *mp4.DOVIConfigurationBox
+			for _, child := range box.Mp4BoxChildren() {
+				if child, ok := child.(*mp4.DOVIConfigurationBox); ok {
+					dvcC = child
+				}
+			}
+			if !tt.wantDv {
+				if dvcC != nil {
+					t.Errorf("unexpected %s box", dvcC.Type)
+				}
+				return
+			}
+			if dvcC == nil {
+				t.Fatal("no Dolby Vision configuration box")
+			}
+			if dvcC.Type != mp4.DvcCBoxType || dvcC.DOVIConfig.Profile != 5 || dvcC.DOVIConfig.Level != 4 {
+				t.Errorf("%s box of profile %d level %d, want dvcC of profile 5 level 4", dvcC.Type, dvcC.DOVIConfig.Profile, dvcC.DOVIConfig.Level)
+			}
+		})
+	}
+}
+
+func TestEditListBoxRoundTrip(t *testing.T) {
+	for _, version := range []uint8{0, 1} {
+		elst := &EditListBox{Entries: []EditListEntry{
+			{SegmentDuration: 2000, MediaTime: -1, MediaRateInteger: 1},
+			{SegmentDuration: 0, MediaTime: 1024, MediaRateInteger: 1},
+		}}
+		elst.Version = version
+		edts := &EditBox{}
+		if err := edts.Mp4BoxReplaceChildren([]mp4.Box{elst}); err != nil {
+			t.Fatal(err)
+		}
+		children := roundTripBox(t, edts).Mp4BoxChildren()
+		if len(children) != 1 {
+			t.Fatalf("version %d: got %d children, want the elst box", version, len(children))
+		}
+		got, ok := children[0].(*EditListBox)
+		if !ok {
+			t.Fatalf("version %d: child %T, want *EditListBox", version, children[0])
+		}
+		if got.Version != version || !reflect.DeepEqual(got.Entries, elst.Entries) {
+			t.Errorf("version %d: read version %d entries %+v, want %+v", version, got.Version, got.Entries, elst.Entries)
+		}
+	}
+}
+
+func TestCreateEdtsMp4Box(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		want      []EditListEntry
+	}{
+		{
+			name:      "no edits",
+			processor: MoovProcessor{Timescale: 48000, Duration: 10},
+		},
+		{
+			name:      "encoder delay",
+			processor: MoovProcessor{Timescale: 48000, Duration: 10, EditMediaTime: 2112},
+			want:      []EditListEntry{{SegmentDuration: 480000 - 2112, MediaTime: 2112, MediaRateInteger: 1}},
+		},
+		{
+			name:      "encoder delay in the movie timescale",
+			processor: MoovProcessor{Timescale: 48000, MovieTimescale: 1000, Duration: 10, EditMediaTime: 2112},
+			want:      []EditListEntry{{SegmentDuration: 10000 - 44, MediaTime: 2112, MediaRateInteger: 1}},
+		},
+		{
+			name:      "presentation offset of unknown duration",
+			processor: MoovProcessor{Timescale: 10000000, MovieTimescale: 1000, EditEmptyDuration: 500},
+			want:      []EditListEntry{{SegmentDuration: 500, MediaTime: -1, MediaRateInteger: 1}, {MediaRateInteger: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edts, err := tt.processor.CreateEdtsMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if edts != nil {
+					t.Errorf("CreateEdtsMp4Box() = %+v, want nil", edts)
+				}
+				return
+			}
+			elst := edts.Mp4BoxChildren()[0].(*EditListBox)
+			if !reflect.DeepEqual(elst.Entries, tt.want) {
+				t.Errorf("elst entries %+v, want %+v", elst.Entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSampleEntryMp4BoxBitRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		wantTypes []mp4.BoxType
+		wantMax   uint32
+	}{
+		{
+			name:      "no bitrate box",
+			processor: MoovProcessor{Bitrate: 3000000},
+			wantTypes: []mp4.BoxType{mp4.AvcCBoxType},
+		},
+		{
+			name:      "bitrate box",
+			processor: MoovProcessor{Bitrate: 3000000, MaxBitrate: 4500000, EmitBitRateBox: true},
+			wantTypes: []mp4.BoxType{mp4.AvcCBoxType, mp4.BtrtBoxType},
+			wantMax:   4500000,
+		},
+		{
+			name:      "maximum bitrate below the average",
+			processor: MoovProcessor{Bitrate: 3000000, MaxBitrate: 1000, EmitBitRateBox: true},
+			wantTypes: []mp4.BoxType{mp4.AvcCBoxType, mp4.BtrtBoxType},
+			wantMax:   3000000,
+		},
+		{
+			name:      "protected",
+			processor: MoovProcessor{Bitrate: 3000000, EmitBitRateBox: true, Protected: true, KID: [16]byte{1}},
+			wantTypes: []mp4.BoxType{mp4.AvcCBoxType, mp4.BtrtBoxType, mp4.SinfBoxType},
+			wantMax:   3000000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.Codec = mp4.Avc1FourCC
+			tt.processor.Width, tt.processor.Height = 1280, 720
+			tt.processor.CodecPrivateData = decodeHex(t, testH264CodecPrivateData)
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var types []mp4.BoxType
+			for _, child := range box.Mp4BoxChildren() {
+				types = append(types, child.Mp4BoxType())
+				if btrt, ok := child.(*mp4.BitRateBox); ok {
+					if btrt.MaxBitrate != tt.wantMax || btrt.AvgBitrate != tt.processor.Bitrate {
+						t.Errorf("btrt bitrates %d, %d, want %d, %d", btrt.MaxBitrate, btrt.AvgBitrate, tt.wantMax, tt.processor.Bitrate)
+					}
+				}
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("children %v, want %v", types, tt.wantTypes)
+			}
+		})
+	}
+}
+
+func TestCreateHDRMp4Boxes(t *testing.T) {
+	pq := &ColourDescription{ColourPrimaries: 9, TransferCharacteristics: 16, MatrixCoefficients: 9}
+	brighter := &ContentLightLevel{MaxContentLightLevel: 4000, MaxPicAverageLightLevel: 1000}
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		wantTypes []mp4.BoxType
+		wantClli  ContentLightLevel
+	}{
+		{
+			name:      "SEI of the CodecPrivateData",
+			processor: MoovProcessor{Codec: mp4.Hvc1FourCC, Colour: pq, CodecPrivateData: decodeHex(t, testHEVCCodecPrivateData+"00000001"+testHEVCHDRSEI)},
+			wantTypes: []mp4.BoxType{mp4.HvcCBoxType, mp4.ColrBoxType, MdcvBoxType, ClliBoxType},
+			wantClli:  testContentLightLevel,
+		},
+		{
+			name:      "explicit content light level",
+			processor: MoovProcessor{Codec: mp4.Hvc1FourCC, ContentLightLevel: brighter, CodecPrivateData: decodeHex(t, testHEVCCodecPrivateData+"00000001"+testHEVCHDRSEI)},
+			wantTypes: []mp4.BoxType{mp4.HvcCBoxType, MdcvBoxType, ClliBoxType},
+			wantClli:  *brighter,
+		},
+		{
+			name:      "protected with bitrate box",
+			processor: MoovProcessor{Codec: mp4.Hvc1FourCC, Colour: pq, MasteringDisplay: &testMasteringDisplay, EmitBitRateBox: true, Protected: true, CodecPrivateData: decodeHex(t, testHEVCCodecPrivateData)},
+			wantTypes: []mp4.BoxType{mp4.HvcCBoxType, mp4.ColrBoxType, MdcvBoxType, mp4.BtrtBoxType, mp4.SinfBoxType},
+		},
+		{
+			name:      "SDR",
+			processor: MoovProcessor{Codec: mp4.Hvc1FourCC, CodecPrivateData: decodeHex(t, testHEVCCodecPrivateData)},
+			wantTypes: []mp4.BoxType{mp4.HvcCBoxType},
+		},
+		{
+			name:      "audio",
+			processor: MoovProcessor{Codec: Mp4aFourCC, Colour: pq, SamplingRate: 48000, CodecPrivateData: []byte{0x11, 0x90}},
+			wantTypes: []mp4.BoxType{EsdsBoxType},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.Width, tt.processor.Height = 1920, 1080
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var types []mp4.BoxType
+			for _, child := range box.Mp4BoxChildren() {
+				types = append(types, child.Mp4BoxType())
+				switch child := child.(type) {
+				case *mp4.ColourInformationBox:
+					if child.ColourType != mp4.NclxFourCC || child.ColourPrimaries != 9 || child.TransferCharacteristics != 16 || child.MatrixCoefficients != 9 {
+						t.Errorf("colr = %+v, want nclx 9, 16, 9", child)
+					}
+				case *MasteringDisplayColourVolumeBox:
+					if child.MasteringDisplayColourVolume != testMasteringDisplay {
+						t.Errorf("mdcv = %+v, want %+v", child.MasteringDisplayColourVolume, testMasteringDisplay)
+					}
+				case *ContentLightLevelBox:
+					if child.ContentLightLevel != tt.wantClli {
+						t.Errorf("clli = %+v, want %+v", child.ContentLightLevel, tt.wantClli)
+					}
+				}
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("children %v, want %v", types, tt.wantTypes)
+			}
+		})
+	}
+}
+
+func TestHDRBoxesRoundTrip(t *testing.T) {
+	mdcv, ok := roundTripBox(t, &MasteringDisplayColourVolumeBox{MasteringDisplayColourVolume: testMasteringDisplay}).(*MasteringDisplayColourVolumeBox)
+	if !ok || mdcv.MasteringDisplayColourVolume != testMasteringDisplay {
+		t.Errorf("mdcv = %+v, want %+v", mdcv, testMasteringDisplay)
+	}
+	clli, ok := roundTripBox(t, &ContentLightLevelBox{ContentLightLevel: testContentLightLevel}).(*ContentLightLevelBox)
+	if !ok || clli.ContentLightLevel != testContentLightLevel {
+		t.Errorf("clli = %+v, want %+v", clli, testContentLightLevel)
+	}
+}
+
+func TestCreateSinfMp4Box(t *testing.T) {
+	constantIV := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	tests := []struct {
+		name       string
+		processor  MoovProcessor
+		wantScheme mp4.FourCC
+		wantTenc   TrackEncryptionBox
+		wantErr    error
+	}{
+		{
+			name:       "cenc by default",
+			processor:  MoovProcessor{StreamType: VideoStream},
+			wantScheme: mp4.CencFourCC,
+			wantTenc:   TrackEncryptionBox{DefaultIsProtected: 1, DefaultPerSampleIVSize: 8},
+		},
+		{
+			name:       "cbcs video pattern",
+			processor:  MoovProcessor{StreamType: VideoStream, EncryptionScheme: CbcsFourCC},
+			wantScheme: CbcsFourCC,
+			wantTenc: TrackEncryptionBox{
+				FullHeader:            mp4.FullHeader{Version: 1},
+				DefaultCryptByteBlock: 1, DefaultSkipByteBlock: 9,
+				DefaultIsProtected: 1, DefaultPerSampleIVSize: 16,
+			},
+		},
+		{
+			name:       "cbcs audio with a constant IV",
+			processor:  MoovProcessor{StreamType: AudioStream, EncryptionScheme: CbcsFourCC, ConstantIV: constantIV},
+			wantScheme: CbcsFourCC,
+			wantTenc: TrackEncryptionBox{
+				FullHeader:         mp4.FullHeader{Version: 1},
+				DefaultIsProtected: 1, DefaultConstantIV: constantIV,
+			},
+		},
+		{
+			name:      "pattern over 4 bits",
+			processor: MoovProcessor{StreamType: VideoStream, EncryptionScheme: CbcsFourCC, CryptByteBlock: 16},
+			wantErr:   ErrInvalidParam,
+		},
+		{
+			name:      "constant IV size",
+			processor: MoovProcessor{StreamType: VideoStream, EncryptionScheme: CbcsFourCC, ConstantIV: []byte{1, 2, 3}},
+			wantErr:   ErrInvalidParam,
+		},
+		{
+			name:      "unknown scheme",
+			processor: MoovProcessor{StreamType: VideoStream, EncryptionScheme: mp4.FourCC{'c', 'b', 'c', '1'}},
+			wantErr:   ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.Codec = mp4.Avc1FourCC
+			sinf, err := tt.processor.CreateSinfMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateSinfMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			read := roundTripBox(t, sinf)
+			schm, ok := findMp4Box(read, mp4.SchmBoxType).(*mp4.SchemeTypeBox)
+			if !ok || schm.SchemeType != tt.wantScheme {
+				t.Errorf("schm = %+v, want scheme %s", schm, tt.wantScheme)
+			}
+			box, ok := findMp4Box(read, mp4.SchiBoxType, mp4.TencBoxType).(*mp4.TrackEncryptionBox)
+			if !ok {
+				t.Fatal("no tenc box")
+			}
+			tenc := newTrackEncryptionBox(box)
+			if tenc.Version != tt.wantTenc.Version ||
+				tenc.DefaultCryptByteBlock != tt.wantTenc.DefaultCryptByteBlock ||
+				tenc.DefaultSkipByteBlock != tt.wantTenc.DefaultSkipByteBlock ||
+				tenc.DefaultPerSampleIVSize != tt.wantTenc.DefaultPerSampleIVSize ||
+				!bytes.Equal(tenc.DefaultConstantIV, tt.wantTenc.DefaultConstantIV) {
+				t.Errorf("tenc = %+v, want %+v", tenc, tt.wantTenc)
+			}
+		})
+	}
+}
+
+func TestCreateMvexMp4BoxMehd(t *testing.T) {
+	tests := []struct {
+		name         string
+		processor    MoovProcessor
+		wantDuration uint64
+	}{
+		{name: "not requested", processor: MoovProcessor{TrackID: 1, Timescale: 48000, Duration: 60}},
+		{name: "unknown duration", processor: MoovProcessor{TrackID: 1, Timescale: 48000, EmitMehd: true}},
+		{name: "track timescale", processor: MoovProcessor{TrackID: 1, Timescale: 48000, Duration: 60, EmitMehd: true}, wantDuration: 2880000},
+		{name: "movie timescale", processor: MoovProcessor{TrackID: 1, Timescale: 48000, MovieTimescale: 1000, Duration: 60, EmitMehd: true}, wantDuration: 60000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mvex, err := tt.processor.CreateMvexMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			children := roundTripBox(t, mvex).Mp4BoxChildren()
+			wantChildren := 1
+			if tt.wantDuration > 0 {
+				wantChildren = 2
+			}
+			if len(children) != wantChildren {
+				t.Fatalf("mvex has %d children, want %d", len(children), wantChildren)
+			}
+			if tt.wantDuration == 0 {
+				return
+			}
+			mehd, ok := children[0].(*MovieExtendsHeaderBox)
+			if !ok {
+				t.Fatalf("first child of mvex is %T, want mehd", children[0])
+			}
+			if mehd.FragmentDuration != tt.wantDuration {
+				t.Errorf("mehd duration = %d, want %d", mehd.FragmentDuration, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestCreateAvcCMp4Box(t *testing.T) {
+	tests := []struct {
+		name             string
+		codecPrivateData string
+		wantErr          error
+	}{
+		{
+			name:             "SPS and PPS",
+			codecPrivateData: "000000016764001facd9405005bb011000000300100000030320f18319600000000168ebe3cb22c0",
+		},
+		{
+			name:             "consecutive start codes",
+			codecPrivateData: "00000001000000016764001facd9405005bb011000000300100000030320f1831960000000010000000168ebe3cb22c0",
+		},
+		{
+			name:             "empty",
+			codecPrivateData: "",
+			wantErr:          ErrInvalidParam,
+		},
+		{
+			name:             "start code only",
+			codecPrivateData: "00000001",
+			wantErr:          ErrInvalidParam,
+		},
+		{
+			name:             "truncated SPS",
+			codecPrivateData: "000000016764",
+			wantErr:          ErrInvalidParam,
+		},
+		{
+			name:             "truncated SPS before the PPS",
+			codecPrivateData: "00000001670000000168ebe3cb22c0",
+			wantErr:          ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := MoovProcessor{Codec: mp4.Avc1FourCC, CodecPrivateData: decodeHex(t, tt.codecPrivateData)}
+			box, err := p.CreateAvcCMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateAvcCMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			config := box.(*mp4.AVCConfigurationBox).AVCConfig
+			if config.AVCProfileIndication != 0x64 || config.ProfileCompatibility != 0 || config.AVCLevelIndication != 0x1f {
+				t.Errorf("profile %d, compatibility %d, level %d, want 100, 0, 31", config.AVCProfileIndication, config.ProfileCompatibility, config.AVCLevelIndication)
+			}
+			if config.ChromaFormat != 1 {
+				t.Errorf("chroma format %d, want 1", config.ChromaFormat)
+			}
+			if len(config.SequenceParameterSets) != 1 || len(config.PictureParameterSets) != 1 {
+				t.Errorf("got %d SPS and %d PPS, want 1 and 1", len(config.SequenceParameterSets), len(config.PictureParameterSets))
+			}
+		})
+	}
+}
+
+func TestCreateHvcCMp4Box(t *testing.T) {
+	const (
+		vps = "40010c01ffff016000000300900000030000030078959809"
+		sps = "420101016000000300900000030000030078a005020171f2e596a4932bc05a70808080820000030002000003003210"
+		pps = "4401c172b46240"
+	)
+	tests := []struct {
+		name             string
+		codecPrivateData string
+		wantErr          error
+	}{
+		{
+			name:             "VPS, SPS and PPS",
+			codecPrivateData: "00000001" + vps + "00000001" + sps + "00000001" + pps,
+		},
+		{
+			name:             "consecutive start codes",
+			codecPrivateData: "0000000100000001" + vps + "00000001" + sps + "0000000100000001" + pps,
+		},
+		{
+			name:             "empty",
+			codecPrivateData: "",
+			wantErr:          ErrInvalidParam,
+		},
+		{
+			name:             "start code only",
+			codecPrivateData: "00000001",
+			wantErr:          ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := MoovProcessor{Codec: mp4.Hvc1FourCC, CodecPrivateData: decodeHex(t, tt.codecPrivateData)}
+			box, err := p.CreateHvcCMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateHvcCMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			config := box.(*mp4.HEVCConfigurationBox).HEVCConfig
+			if config.GenertalProfileIndicator != 1 || config.GeneralLevelIndicator != 120 {
+				t.Errorf("profile %d, level %d, want 1, 120", config.GenertalProfileIndicator, config.GeneralLevelIndicator)
+			}
+			if len(config.NaluArrays) != 3 {
+				t.Errorf("got %d NAL unit arrays, want 3", len(config.NaluArrays))
+			}
+		})
+	}
+}
+
+func TestCreateAvcCMp4BoxHighProfileFields(t *testing.T) {
+	p := MoovProcessor{Codec: mp4.Avc1FourCC, CodecPrivateData: decodeHex(t, "00000001677a0029b6cec0780447ca800000000168ebe3cb22c0")}
+	box, err := p.CreateAvcCMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := box.(*mp4.AVCConfigurationBox).AVCConfig
+	if config.AVCProfileIndication != 122 || config.AVCLevelIndication != 41 {
+		t.Errorf("profile %d, level %d, want 122, 41", config.AVCProfileIndication, config.AVCLevelIndication)
+	}
+	if config.ChromaFormat != 2 || config.BitDepthLumaMinus8 != 2 || config.BitDepthChromaMinus8 != 2 {
+		t.Errorf("chroma format %d, bit depths %d %d, want 2, 2 2", config.ChromaFormat, config.BitDepthLumaMinus8, config.BitDepthChromaMinus8)
+	}
+}
+
+func TestCreateFtypMp4Box(t *testing.T) {
+	tests := []struct {
+		name           string
+		processor      MoovProcessor
+		wantMajor      mp4.FourCC
+		wantCompatible []mp4.FourCC
+	}{
+		{
+			name:           "default brands",
+			wantMajor:      mp4.Iso6FourCC,
+			wantCompatible: []mp4.FourCC{mp4.IsomFourCC, mp4.Iso6FourCC, mp4.MsdhFourCC},
+		},
+		{
+			name:           "CMAF brands",
+			processor:      MoovProcessor{MajorBrand: CmfcFourCC, CompatibleBrands: []mp4.FourCC{mp4.Iso6FourCC, CmfcFourCC}},
+			wantMajor:      CmfcFourCC,
+			wantCompatible: []mp4.FourCC{mp4.Iso6FourCC, CmfcFourCC},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := tt.processor.CreateFtypMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ftyp := roundTripBox(t, box).(*mp4.FileTypeBox)
+			if ftyp.MajorBrand != tt.wantMajor || !reflect.DeepEqual(ftyp.CompatibleBrands, tt.wantCompatible) {
+				t.Errorf("ftyp brands = %s %v, want %s %v", ftyp.MajorBrand, ftyp.CompatibleBrands, tt.wantMajor, tt.wantCompatible)
+			}
+		})
+	}
+}
+
+func TestHandlerName(t *testing.T) {
+	tests := []struct {
+		processor MoovProcessor
+		want      string
+	}{
+		{processor: MoovProcessor{StreamName: "video"}, want: "video"},
+		{processor: MoovProcessor{StreamName: "video", HandlerName: "VideoHandler"}, want: "VideoHandler"},
+	}
+	for _, tt := range tests {
+		if got := tt.processor.handlerName(); got != tt.want {
+			t.Errorf("handlerName() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestCompressorName(t *testing.T) {
+	tests := []struct {
+		name           string
+		compressorName string
+		want           string
+	}{
+		{name: "default", want: "AVC Coding"},
+		{name: "custom", compressorName: "x264", want: "x264"},
+		{name: "truncated", compressorName: strings.Repeat("a", 40), want: strings.Repeat("a", 31)},
+		// the two-byte rune crossing the 31st byte is dropped whole
+		{name: "truncated at a rune", compressorName: strings.Repeat("a", 30) + "éa", want: strings.Repeat("a", 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := MoovProcessor{Codec: mp4.Avc1FourCC, CompressorName: tt.compressorName, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+			box, err := p.CreateAvc1Mp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := roundTripBox(t, box).(*mp4.VisualSampleEntryBox).CompressorName; got != tt.want {
+				t.Errorf("compressor name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationInTimescale(t *testing.T) {
+	tests := []struct {
+		name          string
+		processor     MoovProcessor
+		wantMedia     uint64
+		wantMovie     uint64
+		wantMvhdScale uint32
+	}{
+		{
+			name:      "seconds",
+			processor: MoovProcessor{TrackID: 1, Timescale: 48000, Duration: 60},
+			wantMedia: 2880000, wantMovie: 2880000, wantMvhdScale: 48000,
+		},
+		{
+			name:      "seconds in the movie timescale",
+			processor: MoovProcessor{TrackID: 1, Timescale: 48000, MovieTimescale: 1000, Duration: 60},
+			wantMedia: 2880000, wantMovie: 60000, wantMvhdScale: 1000,
+		},
+		{
+			name:      "track timescale",
+			processor: MoovProcessor{TrackID: 1, Timescale: 10000000, Duration: 600500000, DurationInTimescale: true},
+			wantMedia: 600500000, wantMovie: 600500000, wantMvhdScale: 10000000,
+		},
+		{
+			name:      "track timescale in the movie timescale",
+			processor: MoovProcessor{TrackID: 1, Timescale: 10000000, MovieTimescale: 1000, Duration: 600500000, DurationInTimescale: true},
+			wantMedia: 600500000, wantMovie: 60050, wantMvhdScale: 1000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.processor.mediaDuration(); got != tt.wantMedia {
+				t.Errorf("mediaDuration() = %d, want %d", got, tt.wantMedia)
+			}
+			if got := tt.processor.movieDuration(); got != tt.wantMovie {
+				t.Errorf("movieDuration() = %d, want %d", got, tt.wantMovie)
+			}
+			box, err := tt.processor.CreateMvhdMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mvhd := box.(*mp4.MovieHeaderBox); mvhd.Timescale != tt.wantMvhdScale || mvhd.Duration != tt.wantMovie {
+				t.Errorf("mvhd = %d in %d, want %d in %d", mvhd.Duration, mvhd.Timescale, tt.wantMovie, tt.wantMvhdScale)
+			}
+		})
+	}
+}
+
+func TestCreateTrexMp4Box(t *testing.T) {
+	custom := uint32(0)
+	tests := []struct {
+		name         string
+		processor    MoovProcessor
+		wantDuration uint32
+		wantFlags    uint32
+	}{
+		{
+			name:      "video",
+			processor: MoovProcessor{Codec: mp4.Avc1FourCC, StreamType: VideoStream, Timescale: 10000000},
+			wantFlags: DefaultVideoSampleFlags,
+		},
+		{
+			name:         "AAC in the sampling rate",
+			processor:    MoovProcessor{Codec: Mp4aFourCC, StreamType: AudioStream, AudioObjectType: AudioObjectTypeAACLC, SamplingRate: 48000, Timescale: 48000},
+			wantDuration: 1024, wantFlags: DefaultSyncSampleFlags,
+		},
+		{
+			name:         "AAC in 100 ns units",
+			processor:    MoovProcessor{Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 32000, Timescale: 10000000},
+			wantDuration: 320000, wantFlags: DefaultSyncSampleFlags,
+		},
+		{
+			name:      "AAC of a fractional duration",
+			processor: MoovProcessor{Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 44100, Timescale: 10000000},
+			wantFlags: DefaultSyncSampleFlags,
+		},
+		{
+			name:      "HE-AAC",
+			processor: MoovProcessor{Codec: Mp4aFourCC, StreamType: AudioStream, AudioObjectType: AudioObjectTypeSBR, SamplingRate: 48000, Timescale: 48000},
+			wantFlags: DefaultSyncSampleFlags,
+		},
+		{
+			name:         "E-AC-3",
+			processor:    MoovProcessor{Codec: Ec3FourCC, StreamType: AudioStream, SamplingRate: 48000, Timescale: 10000000},
+			wantDuration: 320000, wantFlags: DefaultSyncSampleFlags,
+		},
+		{
+			name:         "explicit defaults",
+			processor:    MoovProcessor{Codec: mp4.Avc1FourCC, StreamType: VideoStream, Timescale: 90000, DefaultSampleDuration: 3750, DefaultSampleFlags: &custom},
+			wantDuration: 3750, wantFlags: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.TrackID = 3
+			box, err := tt.processor.CreateTrexMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			trex := roundTripBox(t, box).(*mp4.TrackExtendsBox)
+			if trex.TrackID != 3 || trex.DefaultSampleDescrptionIndex != 1 {
+				t.Errorf("trex track %d, sample description %d, want 3, 1", trex.TrackID, trex.DefaultSampleDescrptionIndex)
+			}
+			if trex.DefaultSampleDuration != tt.wantDuration || trex.DefaultSampleFlags != tt.wantFlags {
+				t.Errorf("trex duration %d, flags %#x, want %d, %#x", trex.DefaultSampleDuration, trex.DefaultSampleFlags, tt.wantDuration, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestCreateUdtaMp4Box(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		wantMovie string
+		wantTrack string
+	}{
+		{name: "not requested", processor: MoovProcessor{Title: "title", StreamName: "video"}},
+		{name: "no names", processor: MoovProcessor{EmitUserData: true}},
+		{name: "title and stream name", processor: MoovProcessor{EmitUserData: true, Title: "title", StreamName: "video"}, wantMovie: "title", wantTrack: "video"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			udta, err := tt.processor.CreateUdtaMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if title, _ := metadataTitleOf(udta); title != tt.wantMovie {
+				t.Errorf("movie title = %q, want %q", title, tt.wantMovie)
+			}
+			if udta, err = tt.processor.CreateTrackUdtaMp4Box(); err != nil {
+				t.Fatal(err)
+			}
+			if title, _ := metadataTitleOf(udta); title != tt.wantTrack {
+				t.Errorf("track title = %q, want %q", title, tt.wantTrack)
+			}
+		})
+	}
+}
+
+// metadataTitleOf returns the title of a udta box, or "" for nil.
+func metadataTitleOf(udta mp4.Box) (title string, ok bool) {
+	if udta == nil {
+		return
+	}
+	parent := &mp4.TrackBox{}
+	if err := parent.Mp4BoxReplaceChildren([]mp4.Box{udta}); err != nil {
+		return
+	}
+	return metadataTitle(parent)
+}
+
+func TestCreateChnlMp4Box(t *testing.T) {
+	layoutAttribute := func(value string) *CustomAttributes {
+		return &CustomAttributes{Attributes: []*Attribute{{Name: "ChannelLayout", Value: value}}}
+	}
+	tests := []struct {
+		name       string
+		processor  MoovProcessor
+		wantLayout uint8
+		wantErr    error
+	}{
+		{name: "stereo", processor: MoovProcessor{Channels: 2}},
+		{name: "5.1", processor: MoovProcessor{Channels: 6}, wantLayout: 6},
+		{name: "7.1", processor: MoovProcessor{Channels: 8}, wantLayout: 12},
+		{name: "uncommon channel count", processor: MoovProcessor{Channels: 24}},
+		{name: "explicit layout", processor: MoovProcessor{Channels: 6, ChannelLayout: 13}, wantLayout: 13},
+		{name: "custom attribute", processor: MoovProcessor{Channels: 2, CustomAttributes: layoutAttribute("3")}, wantLayout: 3},
+		{
+			name:       "explicit layout over custom attribute",
+			processor:  MoovProcessor{Channels: 6, ChannelLayout: 7, CustomAttributes: layoutAttribute("3")},
+			wantLayout: 7,
+		},
+		{name: "invalid custom attribute", processor: MoovProcessor{Channels: 6, CustomAttributes: layoutAttribute("5.1")}, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := tt.processor.CreateChnlMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateChnlMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantLayout == 0 {
+				if box != nil {
+					t.Errorf("CreateChnlMp4Box() = %+v, want nil", box)
+				}
+				return
+			}
+			chnl, ok := box.(*ChannelLayoutBox)
+			if !ok {
+				t.Fatalf("CreateChnlMp4Box() = %T, want *ChannelLayoutBox", box)
+			}
+			if chnl.StreamStructure != ChannelStructured || chnl.DefinedLayout != tt.wantLayout {
+				t.Errorf("structure %d, layout %d, want %d, %d", chnl.StreamStructure, chnl.DefinedLayout, ChannelStructured, tt.wantLayout)
+			}
+		})
+	}
+}
+
+func TestCreateDtsMp4Box(t *testing.T) {
+	tests := []struct {
+		name           string
+		processor      MoovProcessor
+		wantChannels   uint16
+		wantSampleSize uint16
+		wantChnl       bool
+		wantErr        error
+	}{
+		{
+			name:         "core 5.1",
+			processor:    MoovProcessor{Codec: DtscFourCC, SamplingRate: 48000, Channels: 6, BitsPerSample: 24, Bitrate: 1509000},
+			wantChannels: 6, wantSampleSize: 24, wantChnl: true,
+		},
+		{
+			name:         "express stereo by default",
+			processor:    MoovProcessor{Codec: DtseFourCC, SamplingRate: 48000},
+			wantChannels: 2, wantSampleSize: 16,
+		},
+		{
+			name:      "unsupported channel count",
+			processor: MoovProcessor{Codec: DtscFourCC, SamplingRate: 48000, Channels: 7},
+			wantErr:   ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateSampleEntryMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			entry, ok := roundTripBox(t, box).(*AudioSampleEntryBox)
+			if !ok {
+				t.Fatalf("read %T, want *AudioSampleEntryBox", entry)
+			}
+			if entry.Type != mp4.BoxType(tt.processor.Codec) || entry.ChannelCount != tt.wantChannels ||
+				entry.SampleSize != tt.wantSampleSize || entry.SampleRate != tt.processor.SamplingRate {
+				t.Errorf("type %s, channels %d, sample size %d, sample rate %d, want %s, %d, %d, %d",
+					entry.Type, entry.ChannelCount, entry.SampleSize, entry.SampleRate,
+					tt.processor.Codec, tt.wantChannels, tt.wantSampleSize, tt.processor.SamplingRate)
+			}
+			children := entry.Mp4BoxChildren()
+			if len(children) == 0 {
+				t.Fatal("got no children, want the ddts box")
+			}
+			if _, ok := children[0].(*DTSSpecificBox); !ok {
+				t.Errorf("child %T, want *DTSSpecificBox", children[0])
+			}
+			if hasChnl := len(children) > 1; hasChnl != tt.wantChnl {
+				t.Errorf("chnl present %v, want %v", hasChnl, tt.wantChnl)
+			}
+		})
+	}
+}
+
+func TestCreateMettMp4Box(t *testing.T) {
+	tests := []struct {
+		name       string
+		processor  MoovProcessor
+		wantFormat mp4.NullTerminatedString
+		wantErr    error
+	}{
+		{name: "default MIME type", processor: MoovProcessor{}, wantFormat: "application/octet-stream"},
+		{name: "MIME type", processor: MoovProcessor{MetadataMIMEType: "text/xml"}, wantFormat: "text/xml"},
+		{name: "protected", processor: MoovProcessor{Protected: true}, wantErr: ErrUnknownCodec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.Codec = MettFourCC
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateSampleEntryMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			mett, ok := box.(*TextMetaDataSampleEntryBox)
+			if !ok {
+				t.Fatalf("CreateSampleEntryMp4Box() = %T, want *TextMetaDataSampleEntryBox", box)
+			}
+			if mett.MIMEFormat != tt.wantFormat || mett.ContentEncoding != "" || mett.DataReferenceIndex != 1 {
+				t.Errorf("format %q, encoding %q, index %d, want %q, \"\", 1", mett.MIMEFormat, mett.ContentEncoding, mett.DataReferenceIndex, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestCreateUrimMp4Box(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		wantTypes []mp4.BoxType
+		wantErr   error
+	}{
+		{name: "URI", processor: MoovProcessor{MetadataURI: "urn:scte:scte35:2013:bin"}, wantTypes: []mp4.BoxType{UriBoxType}},
+		{
+			name:      "URI and initialization data",
+			processor: MoovProcessor{MetadataURI: "urn:scte:scte35:2013:bin", CodecPrivateData: []byte{1}},
+			wantTypes: []mp4.BoxType{UriBoxType, UriIBoxType},
+		},
+		{name: "no URI", processor: MoovProcessor{}, wantErr: ErrInvalidParam},
+		{name: "protected", processor: MoovProcessor{MetadataURI: "urn:x", Protected: true}, wantErr: ErrUnknownCodec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.Codec = UrimFourCC
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateSampleEntryMp4Box() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var types []mp4.BoxType
+			for _, child := range box.Mp4BoxChildren() {
+				types = append(types, child.Mp4BoxType())
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("children %v, want %v", types, tt.wantTypes)
+			}
+		})
+	}
+}
+
+func TestCreateEncaMp4Box(t *testing.T) {
+	wfx := WaveFormatEx{FormatTag: WaveFormatWMAPro, Channels: 2, SamplesPerSec: 48000, AvgBytesPerSec: 24000, BlockAlign: 4096, BitsPerSample: 16}
+	tests := []struct {
+		name       string
+		processor  MoovProcessor
+		wantConfig mp4.BoxType
+	}{
+		{name: "mp4a", processor: MoovProcessor{Codec: Mp4aFourCC, SamplingRate: 44100, CodecPrivateData: []byte{0x12, 0x10}}, wantConfig: EsdsBoxType},
+		{name: "ac-3", processor: MoovProcessor{Codec: Ac3FourCC, SamplingRate: 48000, Channels: 2, Bitrate: 192000}, wantConfig: Dac3BoxType},
+		{name: "ec-3", processor: MoovProcessor{Codec: Ec3FourCC, SamplingRate: 48000, Channels: 2, Bitrate: 192000}, wantConfig: Dec3BoxType},
+		{name: "dtsc", processor: MoovProcessor{Codec: DtscFourCC, SamplingRate: 48000, Channels: 2, Bitrate: 768000}, wantConfig: DdtsBoxType},
+		{name: "owma", processor: MoovProcessor{Codec: OwmaFourCC, CodecPrivateData: wfx.Bytes()}, wantConfig: WfexBoxType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.processor.StreamType = AudioStream
+			tt.processor.Protected = true
+			tt.processor.KID = [16]byte(testPlayReadyKID)
+			box, err := tt.processor.CreateSampleEntryMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			read := roundTripBox(t, box)
+			if read.Mp4BoxType() != mp4.EncaBoxType {
+				t.Errorf("type %s, want enca", read.Mp4BoxType())
+			}
+			children := read.Mp4BoxChildren()
+			if len(children) != 2 || children[0].Mp4BoxType() != tt.wantConfig || children[1].Mp4BoxType() != mp4.SinfBoxType {
+				var types []mp4.BoxType
+				for _, child := range children {
+					types = append(types, child.Mp4BoxType())
+				}
+				t.Fatalf("children %v, want [%s sinf]", types, tt.wantConfig)
+			}
+			frma, ok := findMp4Box(children[1], mp4.FrmaBoxType).(*mp4.OriginalFormatBox)
+			if !ok || frma.DataFormat != tt.processor.Codec {
+				t.Errorf("frma = %+v, want %s", frma, tt.processor.Codec)
+			}
+		})
+	}
+}
+
+func TestCreateMvhdMp4BoxNextTrackID(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		want      uint32
+	}{
+		{name: "after the track", processor: MoovProcessor{TrackID: 2}, want: 3},
+		{name: "presentation-wide", processor: MoovProcessor{TrackID: 2, NextTrackID: 5}, want: 5},
+		{name: "stale NextTrackID", processor: MoovProcessor{TrackID: 6, NextTrackID: 5}, want: 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := tt.processor.CreateMvhdMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := box.(*mp4.MovieHeaderBox).NextTrackID; got != tt.want {
+				t.Errorf("NextTrackID = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
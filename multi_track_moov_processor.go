@@ -0,0 +1,214 @@
+package smoothstreaming
+
+import (
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+)
+
+// MultiTrackMoovProcessor creates the init segment of a file that carries
+// several tracks, e.g. the video, audio and text streams of a presentation
+// muxed together. Each track is described by its own MoovProcessor, including
+// its protection.
+type MultiTrackMoovProcessor struct {
+	Tracks []MoovProcessor
+
+	// the timescale of the movie header. The timescale of the first track is
+	// used if it is 0.
+	Timescale uint64
+}
+
+func (m MultiTrackMoovProcessor) validate() (err error) {
+	if len(m.Tracks) == 0 {
+		err = fmt.Errorf("no tracks: %w", ErrInvalidParam)
+		return
+	}
+	trackIDs := make(map[uint32]bool, len(m.Tracks))
+	for _, track := range m.Tracks {
+		if track.TrackID == 0 {
+			err = fmt.Errorf("track ID 0: %w", ErrInvalidParam)
+			return
+		}
+		if trackIDs[track.TrackID] {
+			err = fmt.Errorf("duplicate track ID %d: %w", track.TrackID, ErrInvalidParam)
+			return
+		}
+		trackIDs[track.TrackID] = true
+	}
+	return
+}
+
+func (m MultiTrackMoovProcessor) CreateFtypMp4Box() (ftyp mp4.Box, err error) {
+	if err = m.validate(); err != nil {
+		return
+	}
+	return m.Tracks[0].CreateFtypMp4Box()
+}
+
+func (m MultiTrackMoovProcessor) CreateMoovMp4Box() (moov mp4.Box, err error) {
+	if err = m.validate(); err != nil {
+		return
+	}
+
+	mvhd, err := m.CreateMvhdMp4Box()
+	if err != nil {
+		return
+	}
+
+	children := []mp4.Box{mvhd}
+	for _, track := range m.Tracks {
+		track.MovieTimescale = m.timescale()
+		var trak mp4.Box
+		if trak, err = track.CreateTrakMp4Box(); err != nil {
+			return
+		}
+		children = append(children, trak)
+	}
+
+	mvex, err := m.CreateMvexMp4Box()
+	if err != nil {
+		return
+	}
+	children = append(children, mvex)
+
+	udta, err := m.CreateUdtaMp4Box()
+	if err != nil {
+		return
+	}
+	if udta != nil {
+		children = append(children, udta)
+	}
+
+	psshs, err := m.CreatePsshMp4Boxes()
+	if err != nil {
+		return
+	}
+	children = append(children, psshs...)
+
+	moov = &mp4.MovieBox{}
+	if err = moov.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	moov.Mp4BoxUpdate()
+	return
+}
+
+func (m MultiTrackMoovProcessor) timescale() uint64 {
+	if m.Timescale > 0 {
+		return m.Timescale
+	}
+	return m.Tracks[0].Timescale
+}
+
+// movieDuration returns the duration of the longest track in the movie
+// timescale.
+func (m MultiTrackMoovProcessor) movieDuration() (duration uint64) {
+	for _, track := range m.Tracks {
+		track.MovieTimescale = m.timescale()
+		if d := track.movieDuration(); d > duration {
+			duration = d
+		}
+	}
+	return
+}
+
+func (m MultiTrackMoovProcessor) CreateMvhdMp4Box() (mvhd mp4.Box, err error) {
+	var nextTrackID uint32
+	for _, track := range m.Tracks {
+		if id := track.nextTrackID(); id > nextTrackID {
+			nextTrackID = id
+		}
+	}
+	p := MoovProcessor{
+		NextTrackID:         nextTrackID,
+		Timescale:           m.timescale(),
+		Duration:            m.movieDuration(),
+		DurationInTimescale: true,
+	}
+	return p.CreateMvhdMp4Box()
+}
+
+func (m MultiTrackMoovProcessor) CreateMvexMp4Box() (mvex mp4.Box, err error) {
+	children := make([]mp4.Box, 0, len(m.Tracks)+1)
+	mehd, err := m.CreateMehdMp4Box()
+	if err != nil {
+		return
+	}
+	if mehd != nil {
+		children = append(children, mehd)
+	}
+	for _, track := range m.Tracks {
+		var trex mp4.Box
+		if trex, err = track.CreateTrexMp4Box(); err != nil {
+			return
+		}
+		children = append(children, trex)
+	}
+	mvex = &mp4.MovieExtendsBox{}
+	if err = mvex.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	return
+}
+
+// CreateMehdMp4Box returns the mehd box carrying the duration of the longest
+// track if EmitMehd is set on any track, or nil otherwise.
+func (m MultiTrackMoovProcessor) CreateMehdMp4Box() (mehd mp4.Box, err error) {
+	p := MoovProcessor{
+		Timescale:           m.timescale(),
+		Duration:            m.movieDuration(),
+		DurationInTimescale: true,
+	}
+	for _, track := range m.Tracks {
+		p.EmitMehd = p.EmitMehd || track.EmitMehd
+	}
+	return p.CreateMehdMp4Box()
+}
+
+// CreateUdtaMp4Box returns the user data of the movie of the first track that
+// has any, see MoovProcessor.CreateUdtaMp4Box.
+func (m MultiTrackMoovProcessor) CreateUdtaMp4Box() (udta mp4.Box, err error) {
+	for _, track := range m.Tracks {
+		if udta, err = track.CreateUdtaMp4Box(); err != nil || udta != nil {
+			return
+		}
+	}
+	return
+}
+
+// CreatePsshMp4Boxes returns the pssh boxes of the protected tracks, once for
+// each distinct protection system and data.
+func (m MultiTrackMoovProcessor) CreatePsshMp4Boxes() (psshs []mp4.Box, err error) {
+	type psshKey struct {
+		systemID [16]byte
+		data     string
+	}
+	seen := make(map[psshKey]bool)
+	for _, track := range m.Tracks {
+		if !track.Protected {
+			continue
+		}
+		for _, system := range track.protectionSystems() {
+			key := psshKey{system.SystemID, string(system.Data)}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			psshs = append(psshs, &mp4.ProtectionSystemSpecificHeaderBox{
+				SystemID: system.SystemID,
+				Data:     system.Data,
+			})
+		}
+	}
+	return
+}
+
+func (m MultiTrackMoovProcessor) CreateInitMp4Box() (ftyp, moov mp4.Box, err error) {
+	if ftyp, err = m.CreateFtypMp4Box(); err != nil {
+		return
+	}
+	if moov, err = m.CreateMoovMp4Box(); err != nil {
+		return
+	}
+	return
+}
@@ -0,0 +1,202 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestMultiTrackMoovProcessor(t *testing.T) {
+	systemID := [16]byte{0xed, 0xef, 0x8b, 0xa9, 0x79, 0xd6, 0x4a, 0xce, 0xa3, 0xc8, 0x27, 0xdc, 0xd5, 0x1d, 0x21, 0xed}
+	video := MoovProcessor{
+		TrackID: 1, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Width: 1280, Height: 720, Timescale: 10000000, Duration: 60,
+		CodecPrivateData: decodeHex(t, testH264CodecPrivateData),
+		Protected:        true, KID: [16]byte{1}, SystemID: systemID, ProtectionInitData: []byte{0x08, 0x01},
+	}
+	audio := MoovProcessor{
+		TrackID: 2, Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 2, Timescale: 48000, Duration: 61,
+		CodecPrivateData: []byte{0x11, 0x90},
+	}
+	hevc := MoovProcessor{
+		TrackID: 5, Codec: mp4.Hvc1FourCC, StreamType: VideoStream, Width: 1920, Height: 1080, Timescale: 10000000, Duration: 60,
+		CodecPrivateData: decodeHex(t, testHEVCCodecPrivateData),
+		Protected:        true, KID: [16]byte{1}, SystemID: systemID, ProtectionInitData: []byte{0x08, 0x01},
+	}
+	otherKey := hevc
+	otherKey.ProtectionInitData = []byte{0x08, 0x02}
+	tests := []struct {
+		name      string
+		processor MultiTrackMoovProcessor
+		wantPssh  int
+	}{
+		{name: "shared protection", processor: MultiTrackMoovProcessor{Tracks: []MoovProcessor{video, audio, hevc}}, wantPssh: 1},
+		{name: "distinct protection", processor: MultiTrackMoovProcessor{Tracks: []MoovProcessor{video, audio, otherKey}}, wantPssh: 2},
+		{name: "clear", processor: MultiTrackMoovProcessor{Tracks: []MoovProcessor{audio}, Timescale: 1000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ftyp, moov, err := tt.processor.CreateInitMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := ftyp.(*mp4.FileTypeBox); !ok {
+				t.Errorf("ftyp %T, want *mp4.FileTypeBox", ftyp)
+			}
+			timescale := tt.processor.Timescale
+			if timescale == 0 {
+				timescale = tt.processor.Tracks[0].Timescale
+			}
+			var mvhd *mp4.MovieHeaderBox
+			var traks, trexs, psshs int
+			for _, child := range moov.Mp4BoxChildren() {
+				switch child := child.(type) {
+				case *mp4.MovieHeaderBox:
+					mvhd = child
+				case *mp4.TrackBox:
+					tkhd := child.Mp4BoxChildren()[0].(*mp4.TrackHeaderBox)
+					if want := tt.processor.Tracks[traks].Duration * timescale; tkhd.Duration != want {
+						t.Errorf("tkhd duration %d, want %d in the movie timescale", tkhd.Duration, want)
+					}
+					traks++
+				case *mp4.MovieExtendsBox:
+					for i, trex := range child.Mp4BoxChildren() {
+						if id := trex.(*mp4.TrackExtendsBox).TrackID; id != tt.processor.Tracks[i].TrackID {
+							t.Errorf("trex %d of track %d, want %d", i, id, tt.processor.Tracks[i].TrackID)
+						}
+						trexs++
+					}
+				case *mp4.ProtectionSystemSpecificHeaderBox:
+					psshs++
+				}
+			}
+			tracks := tt.processor.Tracks
+			if traks != len(tracks) || trexs != len(tracks) || psshs != tt.wantPssh {
+				t.Errorf("got %d trak, %d trex and %d pssh boxes, want %d, %d and %d", traks, trexs, psshs, len(tracks), len(tracks), tt.wantPssh)
+			}
+			if mvhd == nil {
+				t.Fatal("no mvhd box")
+			}
+			var duration uint64
+			for _, track := range tracks {
+				if track.Duration > duration {
+					duration = track.Duration
+				}
+			}
+			if mvhd.Timescale != uint32(timescale) || mvhd.Duration != duration*timescale || mvhd.NextTrackID != tracks[len(tracks)-1].TrackID+1 {
+				t.Errorf("mvhd timescale %d, duration %d, next track ID %d, want %d, %d, %d",
+					mvhd.Timescale, mvhd.Duration, mvhd.NextTrackID, timescale, duration*timescale, tracks[len(tracks)-1].TrackID+1)
+			}
+		})
+	}
+}
+
+func TestMultiTrackMoovProcessorInvalidTracks(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []MoovProcessor
+	}{
+		{name: "no tracks"},
+		{name: "track ID 0", tracks: []MoovProcessor{{TrackID: 1}, {}}},
+		{name: "duplicate track ID", tracks: []MoovProcessor{{TrackID: 1}, {TrackID: 2}, {TrackID: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := (MultiTrackMoovProcessor{Tracks: tt.tracks}).CreateInitMp4Box(); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("CreateInitMp4Box() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestMultiTrackMoovProcessorProtectionSystems(t *testing.T) {
+	playReady := ProtectionSystem{SystemID: PlayReadySystemID, Data: []byte{1, 2, 3}}
+	widevine := ProtectionSystem{SystemID: WidevineSystemID, Data: []byte{0x08, 0x01}}
+	video := MoovProcessor{
+		TrackID: 1, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Width: 1280, Height: 720, Timescale: 10000000, Duration: 60,
+		CodecPrivateData:  decodeHex(t, testH264CodecPrivateData),
+		Protected:         true,
+		ProtectionSystems: []ProtectionSystem{playReady, widevine},
+	}
+	audio := MoovProcessor{
+		TrackID: 2, Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 2, Timescale: 48000, Duration: 61,
+		CodecPrivateData:  []byte{0x11, 0x90},
+		Protected:         true,
+		ProtectionSystems: []ProtectionSystem{widevine},
+	}
+	psshs, err := MultiTrackMoovProcessor{Tracks: []MoovProcessor{video, audio}}.CreatePsshMp4Boxes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(psshs) != 2 {
+		t.Fatalf("got %d pssh boxes, want 2", len(psshs))
+	}
+	for i, want := range []ProtectionSystem{playReady, widevine} {
+		if pssh := psshs[i].(*mp4.ProtectionSystemSpecificHeaderBox); pssh.SystemID != want.SystemID {
+			t.Errorf("pssh %d of system %s, want %s", i, pssh.SystemID, want.SystemID)
+		}
+	}
+}
+
+func TestMultiTrackMoovProcessorMehd(t *testing.T) {
+	video := MoovProcessor{TrackID: 1, Timescale: 10000000, Duration: 60}
+	audio := MoovProcessor{TrackID: 2, Timescale: 48000, Duration: 61, EmitMehd: true}
+	m := MultiTrackMoovProcessor{Tracks: []MoovProcessor{video, audio}, Timescale: 1000}
+	box, err := m.CreateMehdMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mehd, ok := box.(*MovieExtendsHeaderBox)
+	if !ok {
+		t.Fatalf("CreateMehdMp4Box() = %T, want mehd", box)
+	}
+	if mehd.FragmentDuration != 61000 {
+		t.Errorf("mehd duration = %d, want 61000", mehd.FragmentDuration)
+	}
+	m.Tracks[1].EmitMehd = false
+	if box, err = m.CreateMehdMp4Box(); err != nil || box != nil {
+		t.Errorf("CreateMehdMp4Box() without EmitMehd = %v, %v, want nil", box, err)
+	}
+}
+
+func TestMultiTrackMoovProcessorDurationUnits(t *testing.T) {
+	// a track with its duration in seconds and one in its own timescale
+	video := MoovProcessor{TrackID: 1, Timescale: 10000000, Duration: 605000000, DurationInTimescale: true}
+	audio := MoovProcessor{TrackID: 2, Timescale: 48000, Duration: 60}
+	m := MultiTrackMoovProcessor{Tracks: []MoovProcessor{video, audio}, Timescale: 1000}
+	if got := m.movieDuration(); got != 60500 {
+		t.Errorf("movieDuration() = %d, want 60500", got)
+	}
+	box, err := m.CreateMvhdMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mvhd := box.(*mp4.MovieHeaderBox); mvhd.Duration != 60500 || mvhd.Timescale != 1000 || mvhd.NextTrackID != 3 {
+		t.Errorf("mvhd duration %d, timescale %d, next track %d, want 60500, 1000, 3", mvhd.Duration, mvhd.Timescale, mvhd.NextTrackID)
+	}
+}
+
+func TestMultiTrackMoovProcessorNextTrackID(t *testing.T) {
+	tracks := []MoovProcessor{{TrackID: 1}, {TrackID: 1}, {TrackID: 1}}
+	AssignTrackIDs(tracks)
+	tests := []struct {
+		name   string
+		tracks []MoovProcessor
+		want   uint32
+	}{
+		{name: "all tracks", tracks: tracks, want: 4},
+		{name: "subset of the presentation", tracks: tracks[:1], want: 4},
+		{name: "unassigned", tracks: []MoovProcessor{{TrackID: 1}, {TrackID: 4}}, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := (MultiTrackMoovProcessor{Tracks: tt.tracks}).CreateMvhdMp4Box()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := box.(*mp4.MovieHeaderBox).NextTrackID; got != tt.want {
+				t.Errorf("NextTrackID = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
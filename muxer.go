@@ -0,0 +1,127 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Muxer interleaves the fragments of the tracks of a presentation, e.g. a
+// video, one or more audio and a text stream, into a single fragmented MP4
+// file, in the order of their decode times on the presentation timeline.
+//
+// Each track is fed its fragments in decode order with AddFragment. A
+// fragment is written once every track that has not ended has a fragment
+// queued, so that the earliest one is known; a track that has no further
+// fragments, such as a text stream that stops early, must be ended with
+// EndTrack lest the fragments of the other tracks pile up in memory.
+type Muxer struct {
+	w         io.Writer
+	tracks    []MoovProcessor
+	queues    [][]Fragment
+	ended     []bool
+	sequencer FragmentSequencer
+}
+
+// NewMuxer writes the init segment of the tracks to w. The tracks get
+// distinct track IDs, see AssignTrackIDs, and the fragments of each track
+// are remapped to its ID as they are added.
+func NewMuxer(w io.Writer, tracks []MoovProcessor) (m *Muxer, err error) {
+	m = &Muxer{
+		w:      w,
+		tracks: append([]MoovProcessor(nil), tracks...),
+		queues: make([][]Fragment, len(tracks)),
+		ended:  make([]bool, len(tracks)),
+	}
+	AssignTrackIDs(m.tracks)
+	_, err = MultiTrackMoovProcessor{Tracks: m.tracks}.WriteInitSegment(w)
+	return
+}
+
+// AddFragment queues a fragment of the track of the given index in the tracks
+// of NewMuxer, and writes the fragments that are due. The fragment must have
+// a decode time, see FragmentRewriter, and follow the preceding fragments of
+// the track.
+func (m *Muxer) AddFragment(track int, f Fragment) (err error) {
+	if track < 0 || track >= len(m.tracks) {
+		err = fmt.Errorf("track %d of %d: %w", track, len(m.tracks), ErrInvalidParam)
+		return
+	}
+	if m.ended[track] {
+		err = fmt.Errorf("track %d ended: %w", track, ErrInvalidParam)
+		return
+	}
+	for _, t := range f.Tracks {
+		if _, ok := t.DecodeTime(); !ok {
+			err = fmt.Errorf("track %d fragment has no decode time: %w", t.Header.TrackID, ErrInvalidParam)
+			return
+		}
+		t.Header.TrackID = m.tracks[track].TrackID
+	}
+	if len(f.Tracks) == 0 {
+		return
+	}
+	m.queues[track] = append(m.queues[track], f)
+	err = m.flush()
+	return
+}
+
+// EndTrack declares that the track of the given index has no further
+// fragments, and writes the fragments that are due.
+func (m *Muxer) EndTrack(track int) (err error) {
+	if track < 0 || track >= len(m.tracks) {
+		err = fmt.Errorf("track %d of %d: %w", track, len(m.tracks), ErrInvalidParam)
+		return
+	}
+	m.ended[track] = true
+	err = m.flush()
+	return
+}
+
+// Close ends every track and writes the queued fragments.
+func (m *Muxer) Close() (err error) {
+	for i := range m.ended {
+		m.ended[i] = true
+	}
+	err = m.flush()
+	return
+}
+
+// flush writes the queued fragment with the earliest decode time while every
+// track that has not ended has a fragment queued.
+func (m *Muxer) flush() (err error) {
+	for {
+		next := -1
+		for i, queue := range m.queues {
+			if len(queue) == 0 {
+				if !m.ended[i] {
+					return
+				}
+				continue
+			}
+			if next < 0 || m.precedes(i, next) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return
+		}
+		f := m.queues[next][0]
+		m.queues[next] = m.queues[next][1:]
+		m.sequencer.Assign(&f)
+		if _, err = f.WriteTo(m.w); err != nil {
+			return
+		}
+	}
+}
+
+// precedes reports whether the first queued fragment of track a starts
+// before the one of track b on the presentation timeline.
+func (m *Muxer) precedes(a, b int) bool {
+	timeA, _ := m.queues[a][0].Tracks[0].DecodeTime()
+	timeB, _ := m.queues[b][0].Tracks[0].DecodeTime()
+	// timeA/timescaleA < timeB/timescaleB, on 128 bits
+	hiA, loA := bits.Mul64(timeA, m.tracks[b].Timescale)
+	hiB, loB := bits.Mul64(timeB, m.tracks[a].Timescale)
+	return hiA < hiB || hiA == hiB && loA < loB
+}
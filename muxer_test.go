@@ -0,0 +1,130 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+// testMuxerTracks returns a video track in 10 kHz and an audio track in 1 kHz
+// that both have the track ID 1.
+func testMuxerTracks(t *testing.T) []MoovProcessor {
+	t.Helper()
+	return []MoovProcessor{
+		{
+			TrackID: 1, Codec: mp4.Avc1FourCC, StreamType: VideoStream, Width: 1280, Height: 720, Timescale: 10000,
+			CodecPrivateData: decodeHex(t, testH264CodecPrivateData),
+		},
+		{TrackID: 1, Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 2, Timescale: 1000, CodecPrivateData: []byte{0x11, 0x90}},
+	}
+}
+
+func TestMuxer(t *testing.T) {
+	var buf bytes.Buffer
+	m, err := NewMuxer(&buf, testMuxerTracks(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fragments of the audio track at 0, 2 and 4 seconds and of the video
+	// track at 1 and 3 seconds
+	adds := []struct {
+		track int
+		time  uint64
+	}{{1, 0}, {0, 10000}, {1, 2000}, {1, 4000}, {0, 30000}}
+	for _, add := range adds {
+		f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), add.time)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = m.AddFragment(add.track, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// skip the ftyp and moov boxes of the init segment
+	for i := 0; i < 2; i++ {
+		header, err := mp4.ReadHeader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.CopyN(io.Discard, &buf, int64(header.Size)-int64(header.HeaderSize())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var trackIDs, sequenceNumbers []uint32
+	var times []uint64
+	for buf.Len() > 0 {
+		f, err := ParseFragment(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decodeTime, _ := f.Tracks[0].DecodeTime()
+		trackIDs = append(trackIDs, f.Tracks[0].Header.TrackID)
+		sequenceNumbers = append(sequenceNumbers, f.SequenceNumber())
+		times = append(times, decodeTime)
+	}
+	if want := []uint32{2, 1, 2, 1, 2}; !reflect.DeepEqual(trackIDs, want) {
+		t.Errorf("track IDs %v, want %v", trackIDs, want)
+	}
+	if want := []uint64{0, 10000, 2000, 30000, 4000}; !reflect.DeepEqual(times, want) {
+		t.Errorf("decode times %v, want %v", times, want)
+	}
+	if want := []uint32{1, 2, 3, 4, 5}; !reflect.DeepEqual(sequenceNumbers, want) {
+		t.Errorf("sequence numbers %v, want %v", sequenceNumbers, want)
+	}
+}
+
+func TestMuxerEndTrack(t *testing.T) {
+	var buf bytes.Buffer
+	m, err := NewMuxer(&buf, testMuxerTracks(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	initSize := buf.Len()
+	f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.AddFragment(1, f); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != initSize {
+		t.Fatal("fragment written before the other track has one queued")
+	}
+	if err = m.EndTrack(0); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == initSize {
+		t.Error("fragment not written once the other track ended")
+	}
+	if err = m.AddFragment(0, f); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("AddFragment() to an ended track error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestMuxerErrors(t *testing.T) {
+	m, err := NewMuxer(&bytes.Buffer{}, testMuxerTracks(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.AddFragment(0, f); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("AddFragment() without decode time error = %v, want %v", err, ErrInvalidParam)
+	}
+	if err = m.AddFragment(2, f); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("AddFragment() to track 2 error = %v, want %v", err, ErrInvalidParam)
+	}
+	if err = m.EndTrack(-1); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("EndTrack() of track -1 error = %v, want %v", err, ErrInvalidParam)
+	}
+}
@@ -0,0 +1,98 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultNameTemplate is the NameTemplate of a Downloader by default.
+//
+// The NameTemplate names the output of a track, relative to the OutputDir,
+// with the following placeholders:
+//
+//   - {StreamName}: the name of the stream, or its type if it has none;
+//   - {Language}: the language of the stream, "und" if undetermined;
+//   - {Bitrate}: the bitrate of the track;
+//   - {Height}: the height of the video of the track, 0 if unknown;
+//   - {FourCC}: the FourCC of the track, e.g. H264 or AACL;
+//   - {Time}: the time at which the download started, in UTC, e.g.
+//     20060102T150405Z, kept when the download resumes.
+//
+// Characters that are not allowed in file names are replaced by underscores
+// in the substituted values, so that the names are valid on any filesystem.
+// Slashes in the template itself name subdirectories, which are created as
+// needed. The extension .mp4 is appended to the name of the output file in
+// the SingleFileLayout, or the extension of the Subtitles format to the name
+// of a subtitle sidecar file. The names of the tracks of a download must
+// differ.
+const DefaultNameTemplate = "{StreamName}_{Bitrate}"
+
+// nameTemplatePlaceholder matches the placeholders of a NameTemplate.
+var nameTemplatePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// outputPath returns the path of the output of a track, a file or, in the
+// SegmentedLayout, a directory, named after the NameTemplate.
+func (d Downloader) outputPath(state *DownloadState, selected SelectedTrack) (path string, err error) {
+	template := d.NameTemplate
+	if template == "" {
+		template = DefaultNameTemplate
+	}
+	s, t := selected.Stream, selected.Track
+	language := "und"
+	if s.Language != nil && *s.Language != "" {
+		language = *s.Language
+	}
+	var height uint32
+	switch {
+	case t.MaxHeight != nil:
+		height = *t.MaxHeight
+	case s.MaxHeight != nil:
+		height = *s.MaxHeight
+	}
+	var fourCC string
+	if t.FourCC != nil {
+		fourCC = *t.FourCC
+	}
+	values := map[string]string{
+		"{StreamName}": s.streamName(),
+		"{Language}":   language,
+		"{Bitrate}":    strconv.FormatUint(uint64(t.Bitrate), 10),
+		"{Height}":     strconv.FormatUint(uint64(height), 10),
+		"{FourCC}":     fourCC,
+		"{Time}":       state.Started.UTC().Format("20060102T150405Z"),
+	}
+	name := nameTemplatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value, ok := values[placeholder]
+		if !ok {
+			err = fmt.Errorf("name template %q contains %s: %w", template, placeholder, ErrInvalidParam)
+		}
+		return sanitizeFileName(value)
+	})
+	if err != nil {
+		return
+	}
+	switch {
+	case d.isSidecar(selected):
+		name += d.Subtitles.ext()
+	case d.Layout != SegmentedLayout:
+		name += ".mp4"
+	}
+	path = filepath.Join(d.OutputDir, filepath.FromSlash(name))
+	return
+}
+
+// sanitizeFileName replaces the characters of name that are not allowed in
+// file names on Windows or Unix, and the control characters, by underscores,
+// and trims the trailing dots and spaces Windows drops.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return strings.TrimRight(name, ". ")
+}
@@ -0,0 +1,84 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloaderOutputPath(t *testing.T) {
+	m, err := ParseManifest([]byte(`<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+		`<StreamIndex Type="video" Name="video" MaxHeight="1080"><QualityLevel Index="0" Bitrate="1000" FourCC="H264"/><QualityLevel Index="1" Bitrate="500" FourCC="H264" MaxHeight="360"/></StreamIndex>` +
+		`<StreamIndex Type="audio" Name="audio: director's &lt;commentary&gt;" Language="en"><QualityLevel Index="0" Bitrate="128000" FourCC="AACL"/></StreamIndex>` +
+		`</SmoothStreamingMedia>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	video := SelectedTrack{Stream: m.Streams[0], Track: m.Streams[0].Tracks[0]}
+	video360 := SelectedTrack{Stream: m.Streams[0], Track: m.Streams[0].Tracks[1]}
+	audio := SelectedTrack{Stream: m.Streams[1], Track: m.Streams[1].Tracks[0]}
+	state := &DownloadState{Started: time.Date(2024, 3, 1, 12, 30, 5, 0, time.FixedZone("CET", 3600))}
+	tests := []struct {
+		name       string
+		downloader Downloader
+		selected   SelectedTrack
+		want       string
+		wantErr    error
+	}{
+		{name: "default", downloader: Downloader{OutputDir: "out"}, selected: video, want: "out/video_1000.mp4"},
+		{name: "segmented", downloader: Downloader{Layout: SegmentedLayout}, selected: video, want: "video_1000"},
+		{name: "stream height", downloader: Downloader{NameTemplate: "{Height}p_{FourCC}"}, selected: video, want: "1080p_H264.mp4"},
+		{name: "track height", downloader: Downloader{NameTemplate: "{Height}p_{FourCC}"}, selected: video360, want: "360p_H264.mp4"},
+		{name: "undetermined language", downloader: Downloader{NameTemplate: "{Language}"}, selected: video, want: "und.mp4"},
+		{name: "language", downloader: Downloader{NameTemplate: "{Language}_{Bitrate}"}, selected: audio, want: "en_128000.mp4"},
+		{name: "time", downloader: Downloader{NameTemplate: "{Time}/{StreamName}"}, selected: video, want: "20240301T113005Z/video.mp4"},
+		{name: "sanitized value", downloader: Downloader{NameTemplate: "{StreamName}"}, selected: audio, want: "audio_ director's _commentary_.mp4"},
+		{name: "unknown placeholder", downloader: Downloader{NameTemplate: "{Codec}"}, selected: video, wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := tt.downloader.outputPath(state, tt.selected)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("outputPath() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && path != filepath.FromSlash(tt.want) {
+				t.Errorf("outputPath() = %q, want %q", path, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloaderSelectTracksSamePath(t *testing.T) {
+	m, err := ParseManifest([]byte(`<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+		`<StreamIndex Type="audio" Name="audio_eng"><QualityLevel Index="0" Bitrate="128000"/></StreamIndex>` +
+		`<StreamIndex Type="audio" Name="audio_deu"><QualityLevel Index="0" Bitrate="128000"/></StreamIndex>` +
+		`</SmoothStreamingMedia>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (Downloader{}).selectTracks(m, &DownloadState{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (Downloader{NameTemplate: "audio_{Bitrate}"}).selectTracks(m, &DownloadState{}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("selectTracks() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "video_1000", want: "video_1000"},
+		{name: `a<b>c:d"e/f\g|h?i*j`, want: "a_b_c_d_e_f_g_h_i_j"},
+		{name: "tab\tnewline\n\x7f", want: "tab_newline__"},
+		{name: "trailing. .", want: "trailing"},
+		{name: "Kommentar für Hörgeschädigte", want: "Kommentar für Hörgeschädigte"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFileName(tt.name); got != tt.want {
+			t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+// DefaultFragmentDuration is the target duration of the fragments of a
+// Packager.
+const DefaultFragmentDuration = 2 * time.Second
+
+// Packager packages progressive MP4 files, one per bitrate, into an on-demand
+// Smooth Streaming presentation served by IIS or Unified Origin: an ismv,
+// isma or ismt file per track, along with the client manifest and the server
+// manifest of the presentation.
+type Packager struct {
+	// the directory of the outputs, the working directory if empty.
+	OutputDir string
+
+	// the name of the manifests, {Name}.ism and {Name}.ismc; the name of the
+	// first input without its extension if empty.
+	Name string
+
+	// the target duration of the fragments, DefaultFragmentDuration if 0.
+	// Each fragment starts with a sync sample, the first one at least
+	// FragmentDuration after the start of the previous fragment, so that the
+	// fragments of inputs encoded with aligned sync samples are aligned.
+	FragmentDuration time.Duration
+}
+
+// packagedTrack is a track of an input of a Packager.
+type packagedTrack struct {
+	progressiveTrack
+
+	// the input holding the samples of the track.
+	file *os.File
+
+	// the samples of each fragment of the track.
+	fragments [][]progressiveSample
+}
+
+// Package packages the tracks of the progressive MP4 files inputs, and
+// returns the client manifest of the presentation. The tracks are grouped
+// into streams as by NewClientManifest, the video tracks of different inputs
+// being the bitrates of a stream, and the tracks found in several inputs,
+// e.g. the same audio muxed with each video bitrate, are packaged once. Each
+// track is written as track 1 of its own file, named as by the default
+// TrackFile of ServerManifestOptions. Protected inputs are not supported.
+func (pk Packager) Package(ctx context.Context, inputs ...string) (m *SmoothStreamingMedia, err error) {
+	if len(inputs) == 0 {
+		err = fmt.Errorf("no inputs: %w", ErrInvalidParam)
+		return
+	}
+	target := pk.FragmentDuration
+	if target <= 0 {
+		target = DefaultFragmentDuration
+	}
+	name := pk.Name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(inputs[0]), filepath.Ext(inputs[0]))
+	}
+
+	var packaged []packagedTrack
+	var tracks []FragmentedTrack
+	for _, input := range inputs {
+		var file *os.File
+		if file, err = os.Open(input); err != nil {
+			return
+		}
+		defer file.Close()
+		var info os.FileInfo
+		if info, err = file.Stat(); err != nil {
+			return
+		}
+		var inputTracks []progressiveTrack
+		if inputTracks, err = readProgressiveMP4(file, info.Size()); err != nil {
+			err = fmt.Errorf("%s: %w", input, err)
+			return
+		}
+		for _, track := range inputTracks {
+			if track.moov.Protected {
+				err = fmt.Errorf("%s: track %d is protected: %w", input, track.moov.TrackID, ErrInvalidParam)
+				return
+			}
+			if track.moov.StreamType == "" || len(track.samples) == 0 || containsProgressiveTrack(packaged, track) {
+				continue
+			}
+			t := packagedTrack{progressiveTrack: track, file: file}
+			t.fragments = t.split(rescaleTime(uint64(target), uint64(time.Second), track.moov.Timescale))
+			packaged = append(packaged, t)
+			tracks = append(tracks, t.fragmentedTrack(input))
+		}
+	}
+
+	m, sources, err := newClientManifest(tracks)
+	if err != nil {
+		return
+	}
+	for _, s := range m.Streams {
+		for _, t := range s.Tracks {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+			src, trackID := defaultTrackFile(s, t)
+			if err = packaged[sources[t]].write(filepath.Join(pk.OutputDir, src), trackID); err != nil {
+				err = fmt.Errorf("%s: %w", src, err)
+				return
+			}
+		}
+	}
+
+	err = writeFile(filepath.Join(pk.OutputDir, name+ClientManifestExt), func(w io.Writer) (err error) {
+		data, err := m.Bytes()
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	sm, err := m.ServerManifest(ServerManifestOptions{ClientManifest: name + ClientManifestExt})
+	if err != nil {
+		return
+	}
+	err = writeFile(filepath.Join(pk.OutputDir, name+ServerManifestExt), func(w io.Writer) (err error) {
+		data, err := sm.Bytes()
+		if err == nil {
+			_, err = w.Write(data)
+		}
+		return
+	})
+	return
+}
+
+// containsProgressiveTrack reports whether the tracks hold the media of the
+// track t: the same configuration and samples of the same sizes.
+func containsProgressiveTrack(tracks []packagedTrack, t progressiveTrack) bool {
+	for _, track := range tracks {
+		a, b := track.moov, t.moov
+		if a.StreamType != b.StreamType || a.Codec != b.Codec || a.Language != b.Language ||
+			a.Timescale != b.Timescale || a.Width != b.Width || a.Height != b.Height ||
+			a.SamplingRate != b.SamplingRate || a.Channels != b.Channels || !bytes.Equal(a.CodecPrivateData, b.CodecPrivateData) ||
+			len(track.samples) != len(t.samples) {
+			continue
+		}
+		same := true
+		for i, sample := range track.samples {
+			if sample.size != t.samples[i].size || sample.duration != t.samples[i].duration {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+// split splits the samples of the track into fragments starting with a sync
+// sample, each lasting at least target in the timescale of the track but the
+// last one.
+func (t packagedTrack) split(target uint64) (fragments [][]progressiveSample) {
+	start := 0
+	for i, sample := range t.samples {
+		if i > start && sample.sync && sample.decodeTime >= t.samples[start].decodeTime+target {
+			fragments = append(fragments, t.samples[start:i])
+			start = i
+		}
+	}
+	fragments = append(fragments, t.samples[start:])
+	return
+}
+
+// fragmentedTrack returns the track as packaged from the input at path.
+func (t packagedTrack) fragmentedTrack(path string) (track FragmentedTrack) {
+	track = FragmentedTrack{Path: path, Moov: t.moov, Bitrate: t.moov.Bitrate}
+	var size uint64
+	for i, samples := range t.fragments {
+		fragment := TimelineFragment{Number: uint32(i), Time: samples[0].decodeTime}
+		for _, sample := range samples {
+			fragment.Duration += uint64(sample.duration)
+			size += uint64(sample.size)
+		}
+		track.Fragments = append(track.Fragments, fragment)
+	}
+	if end := track.Fragments[len(track.Fragments)-1].End(); track.Bitrate == 0 && end > 0 {
+		track.Bitrate = uint32(size * 8 * t.moov.Timescale / end)
+	}
+	return
+}
+
+// write writes the track to a fragmented MP4 file at path as the track
+// trackID, each fragment with a TfxdBox, followed by an mfra box.
+func (t packagedTrack) write(path string, trackID uint32) (err error) {
+	p := t.moov
+	p.TrackID, p.NextTrackID = trackID, trackID+1
+	p.Duration, p.DurationInTimescale, p.EmitMehd = 0, true, true
+	for _, sample := range t.samples {
+		p.Duration += uint64(sample.duration)
+	}
+
+	tw, err := openFileTrackWriter(&TrackDownloadState{Path: path})
+	if err != nil {
+		return
+	}
+	defer tw.Close()
+	if _, err = tw.writeInit(p); err != nil {
+		return
+	}
+	for i, samples := range t.fragments {
+		var f Fragment
+		if f, err = t.fragment(samples, trackID, uint32(i+1)); err != nil {
+			return
+		}
+		if _, err = tw.writeFragment(&f); err != nil {
+			return
+		}
+	}
+	err = tw.finalize(p)
+	return
+}
+
+// fragment returns the fragment of the samples of the track as the track
+// trackID, numbered sequenceNumber.
+func (t packagedTrack) fragment(samples []progressiveSample, trackID, sequenceNumber uint32) (f Fragment, err error) {
+	var size int64
+	for _, sample := range samples {
+		size += int64(sample.size)
+	}
+	src := Fragment{
+		Data:   make([]byte, size),
+		Tracks: []FragmentTrack{{Header: &mp4.TrackFragmentHeaderBox{TrackID: trackID}}},
+	}
+	fragmentSamples := make([]fragmentSample, len(samples))
+	var offset, duration int64
+	for i, sample := range samples {
+		if _, err = t.file.ReadAt(src.Data[offset:offset+int64(sample.size)], sample.offset); err != nil {
+			err = fmt.Errorf("sample at %d: %w", sample.decodeTime, err)
+			return
+		}
+		flags := DefaultVideoSampleFlags
+		if sample.sync {
+			flags = SampleFlagsDependsOnNone
+		}
+		fragmentSamples[i] = fragmentSample{
+			sampleRange:           sampleRange{offset: offset, size: int64(sample.size)},
+			duration:              sample.duration,
+			flags:                 flags,
+			compositionTimeOffset: sample.compositionTimeOffset,
+		}
+		offset += int64(sample.size)
+		duration += int64(sample.duration)
+	}
+	if f, err = createChunk(src, fragmentSamples, samples[0].decodeTime, sequenceNumber); err != nil {
+		return
+	}
+
+	// the absolute time of the fragment for the Smooth Streaming clients
+	tfxd := &TfxdBox{FragmentAbsoluteTime: samples[0].decodeTime, FragmentDuration: uint64(duration)}
+	traf := f.Tracks[0].Traf
+	if err = traf.Mp4BoxReplaceChildren(append(traf.Mp4BoxChildren(), tfxd)); err != nil {
+		return
+	}
+	f.Tracks[0].Tfxd = tfxd
+	return
+}
@@ -0,0 +1,159 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestPackagerPackage(t *testing.T) {
+	dir := t.TempDir()
+	video := MoovProcessor{TrackID: 1, Timescale: 1000, StreamType: VideoStream, Codec: mp4.Avc1FourCC, Width: 1280, Height: 720, Bitrate: 2000000, EmitBitRateBox: true, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+	audio := MoovProcessor{TrackID: 2, Timescale: 48000, StreamType: AudioStream, Codec: Mp4aFourCC, SamplingRate: 48000, Channels: 2, Bitrate: 128000, EmitBitRateBox: true, CodecPrivateData: decodeHex(t, testAACCodecPrivateData)}
+	// the low bitrate is an HEVC encoding of the same pictures
+	low := video
+	low.Codec, low.CodecPrivateData, low.Bitrate = mp4.Hvc1FourCC, decodeHex(t, testHEVCCodecPrivateData), 500000
+	high := filepath.Join(dir, "movie_high.mp4")
+	data := writeTestProgressiveMP4(t, high, MultiTrackMoovProcessor{Tracks: []MoovProcessor{video, audio}}, 3)
+	// the same audio is muxed with each video bitrate
+	writeTestProgressiveMP4(t, filepath.Join(dir, "movie_low.mp4"), MultiTrackMoovProcessor{Tracks: []MoovProcessor{low, audio}}, 3)
+
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0777); err != nil {
+		t.Fatal(err)
+	}
+	pk := Packager{OutputDir: out}
+	m, err := pk.Package(context.Background(), high, filepath.Join(dir, "movie_low.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Streams) != 2 || len(m.Streams[0].Tracks) != 2 || len(m.Streams[1].Tracks) != 1 {
+		t.Fatalf("manifest of %d streams, want the video bitrates and the audio packaged once", len(m.Streams))
+	}
+	if b := m.Streams[0].Tracks; b[0].Bitrate != 500000 || b[1].Bitrate != 2000000 {
+		t.Errorf("video bitrates %d and %d, want 500000 and 2000000", b[0].Bitrate, b[1].Bitrate)
+	}
+	// six video samples of a second, in fragments of two seconds
+	if fragments, _ := m.Streams[0].Timeline(); len(fragments) != 3 || fragments[1].Time != 2000 || fragments[1].Duration != 2000 {
+		t.Errorf("video timeline %+v, want 3 fragments of 2000", fragments)
+	}
+	if m.Duration != 60000000 {
+		t.Errorf("duration %d, want 60000000", m.Duration)
+	}
+
+	// the client manifest and server manifest are named after the first input
+	clientManifest, err := os.ReadFile(filepath.Join(out, "movie_high.ismc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseManifest(clientManifest); err != nil {
+		t.Errorf("ParseManifest() of the client manifest error = %v", err)
+	}
+	serverManifest, err := os.ReadFile(filepath.Join(out, "movie_high.ism"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := ParseServerManifest(serverManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.ClientManifest() != "movie_high.ismc" || len(sm.Switch.Tracks) != 3 {
+		t.Fatalf("server manifest %+v", sm)
+	}
+
+	// each track file holds the fragments of the samples of its input
+	for _, track := range sm.Switch.Tracks {
+		tracks, err := ScanTrackFile(filepath.Join(out, track.Src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tracks) != 1 || tracks[0].Moov.TrackID != 1 || tracks[0].Bitrate != track.SystemBitrate {
+			t.Errorf("%s: tracks %+v", track.Src, tracks)
+		}
+	}
+	file, err := os.Open(filepath.Join(out, "video_2000000.ismv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	init, err := ParseInitSegment(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for i := 0; i < 3; i++ {
+		f, err := ParseFragment(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Tracks[0].Tfxd == nil || f.Tracks[0].Tfxd.FragmentAbsoluteTime != uint64(2000*i) {
+			t.Errorf("fragment %d tfxd %+v, want the time of the fragment", i, f.Tracks[0].Tfxd)
+		}
+		samples, err := f.Samples(init.Tracks[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sample := range samples {
+			got = append(got, sample.Data...)
+		}
+	}
+	if !bytes.Equal(got, data[0]) {
+		t.Errorf("packaged video samples %q, want %q", got, data[0])
+	}
+}
+
+func TestPackagerPackageFragmentDuration(t *testing.T) {
+	dir := t.TempDir()
+	video := MoovProcessor{TrackID: 1, Timescale: 1000, StreamType: VideoStream, Codec: mp4.Avc1FourCC, Width: 640, Height: 360, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+	input := filepath.Join(dir, "movie.mp4")
+	writeTestProgressiveMP4(t, input, MultiTrackMoovProcessor{Tracks: []MoovProcessor{video}}, 3)
+	m, err := Packager{OutputDir: dir, Name: "presentation", FragmentDuration: 3 * time.Second}.Package(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last fragment is shorter
+	if fragments, _ := m.Streams[0].Timeline(); len(fragments) != 2 || fragments[0].Duration != 3000 || fragments[1].Duration != 3000 {
+		t.Errorf("timeline %+v, want 2 fragments of 3000", fragments)
+	}
+	for _, name := range []string{"presentation.ism", "presentation.ismc"} {
+		if _, err = os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestPackagerPackageErrors(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "movie.mp4")
+	writeTestProgressiveMP4(t, input, MultiTrackMoovProcessor{Tracks: []MoovProcessor{{TrackID: 1, Timescale: 1000, StreamType: VideoStream, Codec: mp4.Avc1FourCC, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}}}, 1)
+	noMoov := filepath.Join(dir, "fragmented.mp4")
+	if err := os.WriteFile(noMoov, testFragment(t, nil), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		inputs  []string
+		wantErr error
+	}{
+		{name: "no inputs", ctx: context.Background(), wantErr: ErrInvalidParam},
+		{name: "missing input", ctx: context.Background(), inputs: []string{filepath.Join(dir, "missing.mp4")}, wantErr: os.ErrNotExist},
+		{name: "no moov", ctx: context.Background(), inputs: []string{noMoov}, wantErr: ErrInvalidParam},
+		{name: "canceled", ctx: ctx, inputs: []string{input}, wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (Packager{OutputDir: dir}).Package(tt.ctx, tt.inputs...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Package() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
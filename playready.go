@@ -0,0 +1,245 @@
+package smoothstreaming
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/google/uuid"
+)
+
+// PlayReadySystemID identifies the PlayReady content protection system.
+var PlayReadySystemID = uuid.MustParse("9a04f079-9840-4286-ab92-e65be0885f95")
+
+// PlayReady Object record types.
+const (
+	PlayReadyRightsManagementHeader uint16 = 0x0001
+	PlayReadyEmbeddedLicenseStore   uint16 = 0x0003
+)
+
+// PlayReady content encryption algorithms of the ALGID field.
+const (
+	PlayReadyAlgIDAESCTR   = "AESCTR"
+	PlayReadyAlgIDAESCBC   = "AESCBC"
+	PlayReadyAlgIDCOCKTAIL = "COCKTAIL"
+)
+
+// PlayReadyObject is the PlayReady Object carried in the ProtectionHeader of
+// PlayReady protected presentations and in PlayReady pssh boxes.
+type PlayReadyObject struct {
+	Records []PlayReadyRecord
+}
+
+// PlayReadyRecord is a record of a PlayReady Object. The value of a rights
+// management header record is a UTF-16LE encoded WRMHEADER XML document.
+type PlayReadyRecord struct {
+	Type  uint16
+	Value []byte
+}
+
+// PlayReadyHeader carries the fields of a WRMHEADER, versions 4.0.0.0 to
+// 4.3.0.0.
+type PlayReadyHeader struct {
+	Version string
+	KIDs    []PlayReadyKID
+	LAURL   string
+	LUIURL  string
+	DSID    string
+
+	// the inner XML of the CUSTOMATTRIBUTES element.
+	CustomAttributes string
+}
+
+// PlayReadyKID identifies a content key of a PlayReady header. KID is the key
+// identifier in the byte order of CENC and the default_KID of tenc, not the
+// little-endian GUID layout of the WRMHEADER.
+type PlayReadyKID struct {
+	KID      uuid.UUID
+	AlgID    string
+	Checksum []byte
+}
+
+// ParsePlayReadyObject parses a PlayReady Object.
+func ParsePlayReadyObject(data []byte) (pro PlayReadyObject, err error) {
+	if len(data) < 6 {
+		err = fmt.Errorf("PlayReady Object truncated: %w", ErrInvalidParam)
+		return
+	}
+	length := binary.LittleEndian.Uint32(data[0:])
+	if length < 6 || int(length) > len(data) {
+		err = fmt.Errorf("PlayReady Object length %d exceeds %d bytes: %w", length, len(data), ErrInvalidParam)
+		return
+	}
+	count := binary.LittleEndian.Uint16(data[4:])
+	data = data[6:length]
+	for i := 0; i < int(count); i++ {
+		if len(data) < 4 {
+			err = fmt.Errorf("PlayReady Object record %d truncated: %w", i, ErrInvalidParam)
+			return
+		}
+		recordType := binary.LittleEndian.Uint16(data[0:])
+		recordLength := binary.LittleEndian.Uint16(data[2:])
+		if int(recordLength) > len(data)-4 {
+			err = fmt.Errorf("PlayReady Object record %d truncated: %w", i, ErrInvalidParam)
+			return
+		}
+		pro.Records = append(pro.Records, PlayReadyRecord{
+			Type:  recordType,
+			Value: data[4 : 4+recordLength],
+		})
+		data = data[4+recordLength:]
+	}
+	return
+}
+
+// Bytes serializes the PlayReady Object.
+func (pro PlayReadyObject) Bytes() []byte {
+	length := 6
+	for _, record := range pro.Records {
+		length += 4 + len(record.Value)
+	}
+	data := make([]byte, length)
+	binary.LittleEndian.PutUint32(data[0:], uint32(length))
+	binary.LittleEndian.PutUint16(data[4:], uint16(len(pro.Records)))
+	offset := 6
+	for _, record := range pro.Records {
+		binary.LittleEndian.PutUint16(data[offset:], record.Type)
+		binary.LittleEndian.PutUint16(data[offset+2:], uint16(len(record.Value)))
+		offset += 4 + copy(data[offset+4:], record.Value)
+	}
+	return data
+}
+
+// Header parses the first rights management header record of the PlayReady
+// Object.
+func (pro PlayReadyObject) Header() (header PlayReadyHeader, err error) {
+	for _, record := range pro.Records {
+		if record.Type == PlayReadyRightsManagementHeader {
+			return ParsePlayReadyHeader(record.Value)
+		}
+	}
+	err = fmt.Errorf("PlayReady Object has no rights management header: %w", ErrInvalidParam)
+	return
+}
+
+// PlayReadyHeader decodes the content of a PlayReady protection header.
+func (h *ProtectionHeader) PlayReadyHeader() (header PlayReadyHeader, err error) {
+	if h.SystemID != PlayReadySystemID {
+		err = fmt.Errorf("protection header of system %s is not PlayReady: %w", h.SystemID, ErrInvalidParam)
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(h.Content))
+	if err != nil {
+		err = fmt.Errorf("PlayReady protection header is not base64: %w", ErrInvalidParam)
+		return
+	}
+	pro, err := ParsePlayReadyObject(data)
+	if err != nil {
+		return
+	}
+	return pro.Header()
+}
+
+type wrmHeader struct {
+	Version string `xml:"version,attr"`
+	Data    struct {
+		ProtectInfo struct {
+			AlgID string         `xml:"ALGID"`
+			KID   []wrmHeaderKID `xml:"KID"`
+			KIDs  []wrmHeaderKID `xml:"KIDS>KID"`
+		} `xml:"PROTECTINFO"`
+		KID              string `xml:"KID"`
+		Checksum         string `xml:"CHECKSUM"`
+		LAURL            string `xml:"LA_URL"`
+		LUIURL           string `xml:"LUI_URL"`
+		DSID             string `xml:"DS_ID"`
+		CustomAttributes struct {
+			InnerXML string `xml:",innerxml"`
+		} `xml:"CUSTOMATTRIBUTES"`
+	} `xml:"DATA"`
+}
+
+type wrmHeaderKID struct {
+	AlgID    string `xml:"ALGID,attr"`
+	Checksum string `xml:"CHECKSUM,attr"`
+	Value    string `xml:"VALUE,attr"`
+}
+
+// ParsePlayReadyHeader parses the UTF-16LE encoded WRMHEADER of a rights
+// management header record.
+func ParsePlayReadyHeader(data []byte) (header PlayReadyHeader, err error) {
+	if len(data)%2 != 0 {
+		err = fmt.Errorf("WRMHEADER is not UTF-16 encoded: %w", ErrInvalidParam)
+		return
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	text := strings.TrimPrefix(string(utf16.Decode(units)), "\ufeff")
+
+	var wrm wrmHeader
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	// the text is already decoded, regardless of the encoding declaration
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err = decoder.Decode(&wrm); err != nil {
+		err = fmt.Errorf("invalid WRMHEADER: %v: %w", err, ErrInvalidParam)
+		return
+	}
+	header = PlayReadyHeader{
+		Version:          wrm.Version,
+		LAURL:            strings.TrimSpace(wrm.Data.LAURL),
+		LUIURL:           strings.TrimSpace(wrm.Data.LUIURL),
+		DSID:             strings.TrimSpace(wrm.Data.DSID),
+		CustomAttributes: wrm.Data.CustomAttributes.InnerXML,
+	}
+	if wrm.Data.KID != "" {
+		// version 4.0.0.0 carries a single KID and its ALGID and CHECKSUM in
+		// separate elements
+		wrm.Data.ProtectInfo.KID = append(wrm.Data.ProtectInfo.KID, wrmHeaderKID{
+			AlgID:    wrm.Data.ProtectInfo.AlgID,
+			Checksum: wrm.Data.Checksum,
+			Value:    wrm.Data.KID,
+		})
+	}
+	for _, kid := range append(wrm.Data.ProtectInfo.KID, wrm.Data.ProtectInfo.KIDs...) {
+		var key PlayReadyKID
+		if key.KID, err = decodePlayReadyKID(kid.Value); err != nil {
+			return
+		}
+		key.AlgID = strings.TrimSpace(kid.AlgID)
+		if checksum := strings.TrimSpace(kid.Checksum); checksum != "" {
+			if key.Checksum, err = base64.StdEncoding.DecodeString(checksum); err != nil {
+				err = fmt.Errorf("invalid WRMHEADER checksum %q: %w", checksum, ErrInvalidParam)
+				return
+			}
+		}
+		header.KIDs = append(header.KIDs, key)
+	}
+	return
+}
+
+// decodePlayReadyKID decodes the base64 encoded little-endian GUID of a
+// WRMHEADER into a CENC key identifier.
+func decodePlayReadyKID(value string) (kid uuid.UUID, err error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(data) != 16 {
+		err = fmt.Errorf("invalid WRMHEADER KID %q: %w", value, ErrInvalidParam)
+		return
+	}
+	copy(kid[:], data)
+	swapGUIDByteOrder(&kid)
+	return
+}
+
+// swapGUIDByteOrder converts between the little-endian layout of a Microsoft
+// GUID and the big-endian layout of a UUID.
+func swapGUIDByteOrder(id *uuid.UUID) {
+	id[0], id[1], id[2], id[3] = id[3], id[2], id[1], id[0]
+	id[4], id[5] = id[5], id[4]
+	id[6], id[7] = id[7], id[6]
+}
@@ -0,0 +1,183 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/uuid"
+)
+
+var testPlayReadyKID = uuid.MustParse("01020304-0506-0708-090a-0b0c0d0e0f10")
+
+const testWRMHeader40 = `<WRMHEADER xmlns="http://schemas.microsoft.com/DRM/2007/03/PlayReadyHeader" version="4.0.0.0">` +
+	`<DATA><PROTECTINFO><KEYLEN>16</KEYLEN><ALGID>AESCTR</ALGID></PROTECTINFO>` +
+	`<KID>BAMCAQYFCAcJCgsMDQ4PEA==</KID><CHECKSUM>AQIDBAUGBwg=</CHECKSUM>` +
+	`<LA_URL>https://license.example.com/rightsmanager.asmx</LA_URL>` +
+	`<CUSTOMATTRIBUTES><IIS_DRM_VERSION>8.1</IIS_DRM_VERSION></CUSTOMATTRIBUTES></DATA></WRMHEADER>`
+
+const testWRMHeader42 = `<?xml version="1.0" encoding="utf-16"?>` +
+	`<WRMHEADER xmlns="http://schemas.microsoft.com/DRM/2007/03/PlayReadyHeader" version="4.2.0.0">` +
+	`<DATA><PROTECTINFO><KIDS>` +
+	`<KID ALGID="AESCTR" VALUE="BAMCAQYFCAcJCgsMDQ4PEA==" CHECKSUM="AQIDBAUGBwg="></KID>` +
+	`<KID ALGID="AESCBC" VALUE="AAAAAAAAAAAAAAAAAAAAAA=="></KID>` +
+	`</KIDS></PROTECTINFO><LUI_URL>https://example.com/ui</LUI_URL><DS_ID>AH+03juKbUGbHl1V/QIwRA==</DS_ID></DATA></WRMHEADER>`
+
+// encodeWRMHeader returns the UTF-16LE encoding of a WRMHEADER document.
+func encodeWRMHeader(text string) []byte {
+	units := utf16.Encode([]rune(text))
+	data := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(data[2*i:], unit)
+	}
+	return data
+}
+
+func TestParsePlayReadyHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want PlayReadyHeader
+	}{
+		{
+			name: "version 4.0.0.0",
+			data: encodeWRMHeader(testWRMHeader40),
+			want: PlayReadyHeader{
+				Version:          "4.0.0.0",
+				KIDs:             []PlayReadyKID{{KID: testPlayReadyKID, AlgID: PlayReadyAlgIDAESCTR, Checksum: []byte{1, 2, 3, 4, 5, 6, 7, 8}}},
+				LAURL:            "https://license.example.com/rightsmanager.asmx",
+				CustomAttributes: "<IIS_DRM_VERSION>8.1</IIS_DRM_VERSION>",
+			},
+		},
+		{
+			name: "version 4.2.0.0 with a byte order mark",
+			data: encodeWRMHeader("\ufeff" + testWRMHeader42),
+			want: PlayReadyHeader{
+				Version: "4.2.0.0",
+				KIDs: []PlayReadyKID{
+					{KID: testPlayReadyKID, AlgID: PlayReadyAlgIDAESCTR, Checksum: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+					{AlgID: PlayReadyAlgIDAESCBC},
+				},
+				LUIURL: "https://example.com/ui",
+				DSID:   "AH+03juKbUGbHl1V/QIwRA==",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := ParsePlayReadyHeader(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(header, tt.want) {
+				t.Errorf("ParsePlayReadyHeader() = %+v, want %+v", header, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlayReadyHeaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "odd length", data: []byte{'<', 0, 'W'}},
+		{name: "not xml", data: encodeWRMHeader("WRMHEADER")},
+		{name: "short kid", data: encodeWRMHeader(`<WRMHEADER version="4.0.0.0"><DATA><KID>AQID</KID></DATA></WRMHEADER>`)},
+		{name: "invalid checksum", data: encodeWRMHeader(`<WRMHEADER version="4.2.0.0"><DATA><PROTECTINFO><KIDS><KID VALUE="BAMCAQYFCAcJCgsMDQ4PEA==" CHECKSUM="!"></KID></KIDS></PROTECTINFO></DATA></WRMHEADER>`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePlayReadyHeader(tt.data); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("ParsePlayReadyHeader() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestPlayReadyObjectRoundTrip(t *testing.T) {
+	pro := PlayReadyObject{Records: []PlayReadyRecord{
+		{Type: PlayReadyEmbeddedLicenseStore, Value: make([]byte, 10)},
+		{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(testWRMHeader40)},
+	}}
+	data := pro.Bytes()
+	if got := binary.LittleEndian.Uint32(data); int(got) != len(data) {
+		t.Errorf("length field = %d, want %d", got, len(data))
+	}
+	read, err := ParsePlayReadyObject(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Records) != len(pro.Records) {
+		t.Fatalf("got %d records, want %d", len(read.Records), len(pro.Records))
+	}
+	for i, record := range read.Records {
+		if record.Type != pro.Records[i].Type || !bytes.Equal(record.Value, pro.Records[i].Value) {
+			t.Errorf("record %d = %+v, want %+v", i, record, pro.Records[i])
+		}
+	}
+	header, err := read.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(header.KIDs) != 1 || header.KIDs[0].KID != testPlayReadyKID {
+		t.Errorf("Header() KIDs = %+v, want %s", header.KIDs, testPlayReadyKID)
+	}
+}
+
+func TestParsePlayReadyObjectErrors(t *testing.T) {
+	valid := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: []byte{1, 2}}}}.Bytes()
+	truncatedRecord := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint16(truncatedRecord[8:], 3)
+	missingRecord := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint16(missingRecord[4:], 2)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated", data: valid[:4]},
+		{name: "length exceeds data", data: valid[:len(valid)-1]},
+		{name: "truncated record", data: truncatedRecord},
+		{name: "record count exceeds records", data: missingRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePlayReadyObject(tt.data); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("ParsePlayReadyObject() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+	if _, err := (PlayReadyObject{}).Header(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Header() of no records error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestProtectionHeaderPlayReadyHeader(t *testing.T) {
+	pro := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(testWRMHeader40)}}}
+	content := "\n" + base64.StdEncoding.EncodeToString(pro.Bytes()) + "\n"
+	header, err := (&ProtectionHeader{SystemID: PlayReadySystemID, Content: content}).PlayReadyHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.LAURL != "https://license.example.com/rightsmanager.asmx" {
+		t.Errorf("LAURL = %q", header.LAURL)
+	}
+	tests := []struct {
+		name   string
+		header *ProtectionHeader
+	}{
+		{name: "other system", header: &ProtectionHeader{SystemID: WidevineSystemID, Content: content}},
+		{name: "not base64", header: &ProtectionHeader{SystemID: PlayReadySystemID, Content: "!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.header.PlayReadyHeader(); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("PlayReadyHeader() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// progressiveTrack is a track of a progressive MP4 file, whose moov box
+// indexes every sample in the sample tables of the track.
+type progressiveTrack struct {
+	// the configuration of the track.
+	moov MoovProcessor
+
+	// the samples of the track in decode order.
+	samples []progressiveSample
+}
+
+// progressiveSample is a sample of a progressive MP4 file.
+type progressiveSample struct {
+	// the location of the sample in the file.
+	offset int64
+	size   uint32
+
+	decodeTime            uint64
+	duration              uint32
+	compositionTimeOffset int64
+	sync                  bool
+}
+
+// readProgressiveMP4 reads the moov box of the progressive MP4 file r of size
+// bytes, and returns its tracks with their samples. The samples of a track
+// must share its first sample description.
+func readProgressiveMP4(r io.ReaderAt, size int64) (tracks []progressiveTrack, err error) {
+	var moov mp4.Box
+	for offset := int64(0); offset < size; {
+		section := io.NewSectionReader(r, offset, size-offset)
+		var header *mp4.Header
+		if header, err = mp4.ReadHeader(section); err != nil {
+			return
+		}
+		if header.Type == mp4.MoovBoxType {
+			if moov, err = readMp4Box(section, header); err != nil {
+				return
+			}
+			break
+		}
+		var n int64
+		if n, err = skipMp4Box(section, header); err != nil {
+			return
+		}
+		offset += n
+	}
+	if moov == nil {
+		err = fmt.Errorf("no moov box: %w", ErrInvalidParam)
+		return
+	}
+	m, err := ParseMoovMp4Box(moov)
+	if err != nil {
+		return
+	}
+	var traks []mp4.Box
+	for _, child := range moov.Mp4BoxChildren() {
+		if child.Mp4BoxType() == mp4.TrakBoxType {
+			traks = append(traks, child)
+		}
+	}
+	for i, trak := range traks {
+		stbl := findMp4Box(trak, mp4.MdiaBoxType, mp4.MinfBoxType, mp4.StblBoxType)
+		if stbl == nil {
+			err = fmt.Errorf("track %d has no stbl box: %w", m.Tracks[i].TrackID, ErrInvalidParam)
+			return
+		}
+		track := progressiveTrack{moov: m.Tracks[i]}
+		if track.samples, err = readSampleTables(stbl); err != nil {
+			err = fmt.Errorf("track %d: %w", track.moov.TrackID, err)
+			return
+		}
+		tracks = append(tracks, track)
+	}
+	return
+}
+
+// readSampleTables returns the samples indexed by the sample tables of an
+// stbl box.
+func readSampleTables(stbl mp4.Box) (samples []progressiveSample, err error) {
+	var stts *mp4.TimeToSampleBox
+	var ctts *mp4.CompositionOffsetBox
+	var stss *mp4.SyncSampleBox
+	var stsz *SampleSizeBox
+	var stsc *mp4.SampleToChunkBox
+	var chunkOffsets []uint64
+	for _, child := range stbl.Mp4BoxChildren() {
+		switch box := child.(type) {
+		case *mp4.TimeToSampleBox:
+			stts = box
+		case *mp4.CompositionOffsetBox:
+			ctts = box
+		case *mp4.SyncSampleBox:
+			stss = box
+		case *SampleSizeBox:
+			stsz = box
+		case *mp4.SampleToChunkBox:
+			stsc = box
+		case *mp4.ChunkOffsetBox:
+			for _, entry := range box.Entries {
+				chunkOffsets = append(chunkOffsets, uint64(entry.ChunkOffset))
+			}
+		case *ChunkLargeOffsetBox:
+			chunkOffsets = box.ChunkOffsets
+		}
+	}
+	if stts == nil || stsz == nil || stsc == nil {
+		err = fmt.Errorf("stbl box without stts, stsz or stsc box: %w", ErrInvalidParam)
+		return
+	}
+
+	count := stsz.SampleCount
+	if stsz.SampleSize == 0 {
+		count = uint32(len(stsz.EntrySizes))
+	}
+	samples = make([]progressiveSample, count)
+	for i := range samples {
+		samples[i].size = stsz.SampleSize
+		if stsz.SampleSize == 0 {
+			samples[i].size = stsz.EntrySizes[i]
+		}
+		samples[i].sync = stss == nil
+	}
+
+	// the decode times and durations
+	var i int
+	var decodeTime uint64
+	for _, entry := range stts.Entries {
+		for n := uint32(0); n < entry.SampleCount && i < len(samples); n++ {
+			samples[i].decodeTime, samples[i].duration = decodeTime, entry.SampleDelta
+			decodeTime += uint64(entry.SampleDelta)
+			i++
+		}
+	}
+	if i < len(samples) {
+		err = fmt.Errorf("stts box of %d samples instead of %d: %w", i, len(samples), ErrInvalidParam)
+		return
+	}
+	if ctts != nil {
+		i = 0
+		for _, entry := range ctts.Entries {
+			for n := uint32(0); n < entry.SampleCount && i < len(samples); n++ {
+				samples[i].compositionTimeOffset = entry.SampleOffset
+				i++
+			}
+		}
+	}
+	if stss != nil {
+		for _, number := range stss.SampleNumbers {
+			if number >= 1 && int(number) <= len(samples) {
+				samples[number-1].sync = true
+			}
+		}
+	}
+
+	// the offsets, the samples of each chunk following each other from the
+	// offset of the chunk
+	i = 0
+	for e, entry := range stsc.Entries {
+		if entry.SampleDescrptionIndex != 1 {
+			err = fmt.Errorf("sample description %d: %w", entry.SampleDescrptionIndex, ErrInvalidParam)
+			return
+		}
+		last := uint32(len(chunkOffsets))
+		if e+1 < len(stsc.Entries) {
+			last = stsc.Entries[e+1].FirstChunk - 1
+		}
+		for chunk := entry.FirstChunk; chunk >= 1 && chunk <= last && int(chunk) <= len(chunkOffsets); chunk++ {
+			offset := int64(chunkOffsets[chunk-1])
+			for n := uint32(0); n < entry.SamplesPerChunk && i < len(samples); n++ {
+				samples[i].offset = offset
+				offset += int64(samples[i].size)
+				i++
+			}
+		}
+	}
+	if i < len(samples) {
+		err = fmt.Errorf("chunks of %d samples instead of %d: %w", i, len(samples), ErrInvalidParam)
+	}
+	return
+}
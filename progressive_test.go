@@ -0,0 +1,160 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+// writeTestProgressiveMP4 writes a progressive MP4 file of the tracks with n
+// testFragments of each, returning the sample data of each track. The data
+// of the samples of the fragments of a track are numbered after the fragment
+// and its track.
+func writeTestProgressiveMP4(t *testing.T, path string, tracks MultiTrackMoovProcessor, n int) (data [][]byte) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	d, err := NewDefragmenter(file, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = make([][]byte, len(tracks.Tracks))
+	for i := 0; i < n; i++ {
+		for j, p := range tracks.Tracks {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Tracks[0].Header.TrackID = p.TrackID
+			copy(f.Data, fmt.Sprintf("%d%d23456789abcdef", i, p.TrackID))
+			data[j] = append(data[j], f.Data...)
+			if err = d.WriteFragment(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestReadProgressiveMP4(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "movie.mp4")
+	video := MoovProcessor{TrackID: 1, Timescale: 1000, StreamType: VideoStream, Codec: mp4.Avc1FourCC, Width: 1280, Height: 720, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)}
+	audio := MoovProcessor{TrackID: 2, Timescale: 48000, StreamType: AudioStream, Codec: Mp4aFourCC, SamplingRate: 48000, Channels: 2}
+	data := writeTestProgressiveMP4(t, path, MultiTrackMoovProcessor{Tracks: []MoovProcessor{video, audio}}, 2)
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracks, err := readProgressiveMP4(file, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 || tracks[0].moov.TrackID != 1 || tracks[1].moov.StreamType != AudioStream {
+		t.Fatalf("tracks %+v, want the video and audio tracks", tracks)
+	}
+	for i, track := range tracks {
+		if len(track.samples) != 4 {
+			t.Fatalf("track %d of %d samples, want 4", i, len(track.samples))
+		}
+		var got []byte
+		for j, sample := range track.samples {
+			if sample.decodeTime != uint64(1000*j) || sample.duration != 1000 || !sample.sync {
+				t.Errorf("sample %d of track %d %+v", j, i, sample)
+			}
+			b := make([]byte, sample.size)
+			if _, err = file.ReadAt(b, sample.offset); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, b...)
+		}
+		if !bytes.Equal(got, data[i]) {
+			t.Errorf("samples of track %d %q, want %q", i, got, data[i])
+		}
+	}
+
+	if _, err = readProgressiveMP4(bytes.NewReader(nil), 0); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("readProgressiveMP4() of an empty file error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestReadSampleTables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audio.mp4")
+	audio := MoovProcessor{TrackID: 1, Timescale: 48000, StreamType: AudioStream, Codec: Mp4aFourCC, SamplingRate: 48000, Channels: 2}
+	writeTestProgressiveMP4(t, path, MultiTrackMoovProcessor{Tracks: []MoovProcessor{audio}}, 2)
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	boxes, moov := readDefragmentedMp4(t, file)
+	if len(boxes) == 0 || moov == nil {
+		t.Fatal("no moov box")
+	}
+	stbl := findMp4Box(moov, mp4.TrakBoxType, mp4.MdiaBoxType, mp4.MinfBoxType, mp4.StblBoxType)
+	children := stbl.Mp4BoxChildren()
+
+	tests := []struct {
+		name   string
+		modify func(box mp4.Box) mp4.Box
+	}{
+		{name: "no stts", modify: func(box mp4.Box) mp4.Box {
+			if box.Mp4BoxType() == mp4.SttsBoxType {
+				return nil
+			}
+			return box
+		}},
+		{name: "short stts", modify: func(box mp4.Box) mp4.Box {
+			if stts, ok := box.(*mp4.TimeToSampleBox); ok {
+				return &mp4.TimeToSampleBox{Entries: []mp4.TimeToSampleEntry{{SampleCount: 3, SampleDelta: stts.Entries[0].SampleDelta}}}
+			}
+			return box
+		}},
+		{name: "sample description", modify: func(box mp4.Box) mp4.Box {
+			if stsc, ok := box.(*mp4.SampleToChunkBox); ok {
+				entries := append([]mp4.SampleToChunkEntry(nil), stsc.Entries...)
+				entries[0].SampleDescrptionIndex = 2
+				return &mp4.SampleToChunkBox{Entries: entries}
+			}
+			return box
+		}},
+		{name: "missing chunk", modify: func(box mp4.Box) mp4.Box {
+			if stco, ok := box.(*mp4.ChunkOffsetBox); ok {
+				return &mp4.ChunkOffsetBox{Entries: stco.Entries[:1]}
+			}
+			return box
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var modified []mp4.Box
+			for _, child := range children {
+				if box := tt.modify(child); box != nil {
+					modified = append(modified, box)
+				}
+			}
+			box := &mp4.SampleTableBox{}
+			if err := box.Mp4BoxReplaceChildren(modified); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readSampleTables(box); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("readSampleTables() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package smoothstreaming
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+	"github.com/google/uuid"
+)
+
+// ProtectionSystem pairs a content protection system with the data that is
+// carried in its pssh box.
+type ProtectionSystem struct {
+	SystemID uuid.UUID
+	Data     []byte
+}
+
+// ProtectionSystem decodes the base64 encoded content of the header into the
+// pssh data of its content protection system.
+func (h *ProtectionHeader) ProtectionSystem() (system ProtectionSystem, err error) {
+	data, err := base64.StdEncoding.DecodeString(h.Content)
+	if err != nil {
+		err = fmt.Errorf("protection header of system %s: %w", h.SystemID, ErrInvalidParam)
+		return
+	}
+	system = ProtectionSystem{SystemID: h.SystemID, Data: data}
+	return
+}
+
+// ProtectionSystems decodes all protection headers of the presentation.
+func (p *Protection) ProtectionSystems() (systems []ProtectionSystem, err error) {
+	for _, header := range p.ProtectionHeaders {
+		var system ProtectionSystem
+		if system, err = header.ProtectionSystem(); err != nil {
+			return
+		}
+		systems = append(systems, system)
+	}
+	return
+}
+
+// protectionSystems returns the protection systems of the track: the one set
+// by SystemID and ProtectionInitData followed by ProtectionSystems.
+func (p MoovProcessor) protectionSystems() (systems []ProtectionSystem) {
+	if p.SystemID != uuid.Nil || len(p.ProtectionInitData) > 0 || len(p.ProtectionSystems) == 0 {
+		systems = append(systems, ProtectionSystem{SystemID: p.SystemID, Data: p.ProtectionInitData})
+	}
+	systems = append(systems, p.ProtectionSystems...)
+	return
+}
+
+// CreatePsshMp4Boxes returns one pssh box for each protection system of the
+// track, e.g. PlayReady and Widevine for multi-DRM content.
+func (p MoovProcessor) CreatePsshMp4Boxes() (psshs []mp4.Box, err error) {
+	for _, system := range p.protectionSystems() {
+		psshs = append(psshs, &mp4.ProtectionSystemSpecificHeaderBox{
+			SystemID: system.SystemID,
+			Data:     system.Data,
+		})
+	}
+	return
+}
+
+// psshProtectionSystem returns the protection system of a pssh box or of a
+// PIFF ProtectionSystemSpecificHeaderBox.
+func psshProtectionSystem(box mp4.Box) (system ProtectionSystem, ok bool) {
+	switch pssh := box.(type) {
+	case *mp4.ProtectionSystemSpecificHeaderBox:
+		return ProtectionSystem{SystemID: pssh.SystemID, Data: pssh.Data}, true
+	case *PiffProtectionSystemSpecificHeaderBox:
+		return ProtectionSystem{SystemID: pssh.SystemID, Data: pssh.Data}, true
+	}
+	return
+}
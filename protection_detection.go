@@ -0,0 +1,151 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/mp4"
+)
+
+// ProtectionInfo describes the encryption of a track as detected from its
+// media rather than from the Protection element of the manifest, which some
+// servers omit for encrypted content.
+type ProtectionInfo struct {
+	// whether the samples of the track are encrypted.
+	Encrypted bool
+
+	// the protection scheme, 'cenc', 'cbcs' or 'cbc1' for the AES-CBC mode
+	// of PIFF.
+	Scheme mp4.FourCC
+
+	// the size of the per-sample IVs, 0 if the track uses a constant IV or
+	// the size is not known.
+	IVSize uint8
+
+	// the KID of the samples, zero if it is not known.
+	KID [16]byte
+
+	// the protection systems of the pssh boxes of the media, set by
+	// DiscoverProtection.
+	Systems []ProtectionSystem
+}
+
+// IsEncrypted reports whether the fragment carries sample encryption data,
+// a senc box or a PIFF sample encryption box, for any of its tracks.
+func (f Fragment) IsEncrypted() bool {
+	for _, t := range f.Tracks {
+		if t.SampleEncryption != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// InspectProtection detects the encryption of a track from its init segment,
+// as parsed by ParseInitSegment, whose encv or enca sample entry declares the
+// scheme and the default KID, and from a fragment of the track, nil if none
+// was downloaded, whose sample encryption data declares the IV size and may
+// override the KID and the algorithm. Without a protected sample entry, the
+// samples of a PIFF fragment are taken to be encrypted with AES-CTR, the
+// algorithm of PlayReady protected Smooth Streaming.
+func InspectProtection(init MoovProcessor, f *Fragment) (info ProtectionInfo) {
+	if init.Protected {
+		info.Encrypted = true
+		info.Scheme = init.EncryptionScheme
+		if info.Scheme == (mp4.FourCC{}) {
+			info.Scheme = mp4.CencFourCC
+		}
+		info.KID = init.KID
+	}
+	if f == nil {
+		return
+	}
+	for _, t := range f.Tracks {
+		senc := t.SampleEncryption
+		if senc == nil {
+			continue
+		}
+		info.Encrypted = true
+		info.IVSize = senc.IVSize
+		if info.Scheme == (mp4.FourCC{}) {
+			info.Scheme = mp4.CencFourCC
+		}
+		if senc.Mp4BoxFlags()&mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS > 0 {
+			info.KID = senc.KID
+			switch senc.AlgorithmID {
+			case mp4.PiffNotEncrypted:
+				info.Encrypted = false
+			case mp4.PiffAES128CTR:
+				info.Scheme = mp4.CencFourCC
+			case mp4.PiffAES128CBC:
+				info.Scheme = Cbc1FourCC
+			}
+		}
+		break
+	}
+	return
+}
+
+// DiscoverProtection detects the encryption of a track whose manifest has no
+// Protection element from the media served for it, so that license requests
+// can still be made: its init segment, or ismv file, and its first fragment,
+// either of which may be nil. The KID is taken from the encv or enca sample
+// entry, from the PIFF sample encryption box of the fragment, or else from the
+// PlayReady header of the pssh boxes, including PIFF ones, of the moov or moof
+// box. An encrypted track whose KID cannot be found is an error.
+func DiscoverProtection(init, fragment io.Reader) (info ProtectionInfo, err error) {
+	var track MoovProcessor
+	if init != nil {
+		var m MultiTrackMoovProcessor
+		if m, err = ParseInitSegment(init); err != nil {
+			return
+		}
+		track = m.Tracks[0]
+		for _, p := range m.Tracks {
+			if p.Protected {
+				track = p
+				break
+			}
+		}
+		if track.Protected {
+			info.Systems = track.protectionSystems()
+		}
+	}
+	var f *Fragment
+	if fragment != nil {
+		f = &Fragment{}
+		if *f, err = ParseFragment(fragment); err != nil {
+			return
+		}
+		for _, child := range f.Moof.Mp4BoxChildren() {
+			if system, ok := psshProtectionSystem(child); ok {
+				info.Systems = append(info.Systems, system)
+			}
+		}
+	}
+	systems := info.Systems
+	info = InspectProtection(track, f)
+	info.Systems = systems
+	if !info.Encrypted || info.KID != ([16]byte{}) {
+		return
+	}
+	for _, system := range info.Systems {
+		if system.SystemID != PlayReadySystemID {
+			continue
+		}
+		var pro PlayReadyObject
+		if pro, err = ParsePlayReadyObject(system.Data); err != nil {
+			return
+		}
+		var header PlayReadyHeader
+		if header, err = pro.Header(); err != nil {
+			return
+		}
+		if len(header.KIDs) > 0 {
+			info.KID = header.KIDs[0].KID
+			return
+		}
+	}
+	err = fmt.Errorf("no KID found for encrypted track: %w", ErrInvalidParam)
+	return
+}
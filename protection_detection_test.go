@@ -0,0 +1,161 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestInspectProtection(t *testing.T) {
+	kid := [16]byte(testPlayReadyKID)
+	piff := func(algorithmID mp4.PiffAlgorithmID) *SampleEncryptionBox {
+		senc := testSampleEncryptionBox(8, true)
+		senc.AlgorithmID = algorithmID
+		senc.KID = [16]byte{9}
+		senc.Mp4BoxSetFlags(senc.Mp4BoxFlags() | mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS)
+		return senc
+	}
+	tests := []struct {
+		name string
+		init MoovProcessor
+		senc *SampleEncryptionBox
+		// whether a fragment is inspected
+		fragment bool
+		want     ProtectionInfo
+	}{
+		{name: "clear", fragment: true},
+		{name: "clear without fragment"},
+		{
+			name: "protected init segment without fragment",
+			init: MoovProcessor{Protected: true, KID: kid, EncryptionScheme: CbcsFourCC},
+			want: ProtectionInfo{Encrypted: true, Scheme: CbcsFourCC, KID: kid},
+		},
+		{
+			name:     "protected init segment",
+			init:     MoovProcessor{Protected: true, KID: kid},
+			senc:     testSampleEncryptionBox(8, false),
+			fragment: true,
+			want:     ProtectionInfo{Encrypted: true, Scheme: mp4.CencFourCC, IVSize: 8, KID: kid},
+		},
+		{
+			name:     "PIFF fragment of a clear init segment",
+			senc:     testSampleEncryptionBox(16, true),
+			fragment: true,
+			want:     ProtectionInfo{Encrypted: true, Scheme: mp4.CencFourCC, IVSize: 16},
+		},
+		{
+			name:     "PIFF override of AES-CBC",
+			init:     MoovProcessor{Protected: true, KID: kid},
+			senc:     piff(mp4.PiffAES128CBC),
+			fragment: true,
+			want:     ProtectionInfo{Encrypted: true, Scheme: Cbc1FourCC, IVSize: 8, KID: [16]byte{9}},
+		},
+		{
+			name:     "PIFF override of clear samples",
+			init:     MoovProcessor{Protected: true, KID: kid},
+			senc:     piff(mp4.PiffNotEncrypted),
+			fragment: true,
+			want:     ProtectionInfo{Scheme: mp4.CencFourCC, IVSize: 8, KID: [16]byte{9}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f *Fragment
+			if tt.fragment {
+				parsed, err := ParseFragment(bytes.NewReader(testFragment(t, tt.senc)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if parsed.IsEncrypted() != (tt.senc != nil) {
+					t.Errorf("IsEncrypted() = %v, want %v", parsed.IsEncrypted(), tt.senc != nil)
+				}
+				f = &parsed
+			}
+			if got := InspectProtection(tt.init, f); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("InspectProtection() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverProtection(t *testing.T) {
+	pro := PlayReadyObject{Records: []PlayReadyRecord{{Type: PlayReadyRightsManagementHeader, Value: encodeWRMHeader(testWRMHeader40)}}}.Bytes()
+	audio := MoovProcessor{TrackID: 1, Codec: Mp4aFourCC, StreamType: AudioStream, SamplingRate: 48000, Channels: 2, Timescale: 48000, CodecPrivateData: []byte{0x11, 0x90}}
+	initSegment := func(p MoovProcessor) []byte {
+		var buf bytes.Buffer
+		if _, err := p.WriteInitSegment(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	// fragment returns testFragment with a PIFF sample encryption box and a
+	// PIFF pssh box carrying the PlayReady Object in its moof box.
+	fragment := func() []byte {
+		f, err := ParseFragment(bytes.NewReader(testFragment(t, testSampleEncryptionBox(8, true))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pssh := &PiffProtectionSystemSpecificHeaderBox{SystemID: PlayReadySystemID, Data: pro}
+		if err = f.Moof.Mp4BoxReplaceChildren(append(f.Moof.Mp4BoxChildren(), pssh)); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err = f.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	protected := audio
+	protected.Protected, protected.KID, protected.SystemID, protected.ProtectionInitData = true, [16]byte{7}, PlayReadySystemID, pro
+	noKID := protected
+	noKID.KID = [16]byte{}
+	tests := []struct {
+		name     string
+		init     []byte
+		fragment []byte
+		want     ProtectionInfo
+		wantErr  error
+	}{
+		{name: "clear", init: initSegment(audio), fragment: testFragment(t, nil)},
+		{
+			name:    "tenc KID",
+			init:    initSegment(protected),
+			want:    ProtectionInfo{Encrypted: true, Scheme: mp4.CencFourCC, KID: [16]byte{7}, Systems: []ProtectionSystem{{SystemID: PlayReadySystemID, Data: pro}}},
+			wantErr: nil,
+		},
+		{
+			name: "PlayReady header of the moov box",
+			init: initSegment(noKID),
+			want: ProtectionInfo{Encrypted: true, Scheme: mp4.CencFourCC, KID: [16]byte(testPlayReadyKID), Systems: []ProtectionSystem{{SystemID: PlayReadySystemID, Data: pro}}},
+		},
+		{
+			name:     "PlayReady header of a PIFF pssh box of the fragment",
+			fragment: fragment(),
+			want:     ProtectionInfo{Encrypted: true, Scheme: mp4.CencFourCC, IVSize: 8, KID: [16]byte(testPlayReadyKID), Systems: []ProtectionSystem{{SystemID: PlayReadySystemID, Data: pro}}},
+		},
+		{name: "no KID", fragment: testFragment(t, testSampleEncryptionBox(8, true)), wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var init, fragment io.Reader
+			if tt.init != nil {
+				init = bytes.NewReader(tt.init)
+			}
+			if tt.fragment != nil {
+				fragment = bytes.NewReader(tt.fragment)
+			}
+			info, err := DiscoverProtection(init, fragment)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DiscoverProtection() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(info, tt.want) {
+				t.Errorf("DiscoverProtection() = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestProtectionSystems(t *testing.T) {
+	protection := &Protection{ProtectionHeaders: []*ProtectionHeader{
+		{SystemID: PlayReadySystemID, Content: "AQID"},
+		{SystemID: WidevineSystemID, Content: "CAE="},
+	}}
+	systems, err := protection.ProtectionSystems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ProtectionSystem{
+		{SystemID: PlayReadySystemID, Data: []byte{1, 2, 3}},
+		{SystemID: WidevineSystemID, Data: []byte{0x08, 0x01}},
+	}
+	if len(systems) != len(want) {
+		t.Fatalf("got %d systems, want %d", len(systems), len(want))
+	}
+	for i, system := range systems {
+		if system.SystemID != want[i].SystemID || !bytes.Equal(system.Data, want[i].Data) {
+			t.Errorf("system %d = %+v, want %+v", i, system, want[i])
+		}
+	}
+
+	protection.ProtectionHeaders = append(protection.ProtectionHeaders, &ProtectionHeader{SystemID: WidevineSystemID, Content: "not base64"})
+	if _, err = protection.ProtectionSystems(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ProtectionSystems() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestCreatePsshMp4Boxes(t *testing.T) {
+	playReady := ProtectionSystem{SystemID: PlayReadySystemID, Data: []byte{1, 2, 3}}
+	widevine := ProtectionSystem{SystemID: WidevineSystemID, Data: []byte{0x08, 0x01}}
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		want      []ProtectionSystem
+	}{
+		{
+			name:      "single system",
+			processor: MoovProcessor{SystemID: playReady.SystemID, ProtectionInitData: playReady.Data},
+			want:      []ProtectionSystem{playReady},
+		},
+		{
+			name:      "multi-DRM",
+			processor: MoovProcessor{ProtectionSystems: []ProtectionSystem{playReady, widevine}},
+			want:      []ProtectionSystem{playReady, widevine},
+		},
+		{
+			name:      "single system followed by the list",
+			processor: MoovProcessor{SystemID: playReady.SystemID, ProtectionInitData: playReady.Data, ProtectionSystems: []ProtectionSystem{widevine}},
+			want:      []ProtectionSystem{playReady, widevine},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psshs, err := tt.processor.CreatePsshMp4Boxes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(psshs) != len(tt.want) {
+				t.Fatalf("got %d pssh boxes, want %d", len(psshs), len(tt.want))
+			}
+			for i, box := range psshs {
+				pssh, ok := roundTripBox(t, box).(*mp4.ProtectionSystemSpecificHeaderBox)
+				if !ok {
+					t.Fatalf("pssh %d is %T", i, box)
+				}
+				if pssh.SystemID != tt.want[i].SystemID || !bytes.Equal(pssh.Data, tt.want[i].Data) {
+					t.Errorf("pssh %d = %s %x, want %s %x", i, pssh.SystemID, pssh.Data, tt.want[i].SystemID, tt.want[i].Data)
+				}
+			}
+		})
+	}
+}
+
+func TestPsshProtectionSystem(t *testing.T) {
+	want := ProtectionSystem{SystemID: PlayReadySystemID, Data: []byte{1, 2, 3}}
+	tests := []struct {
+		name   string
+		box    mp4.Box
+		wantOK bool
+	}{
+		{name: "pssh", box: &mp4.ProtectionSystemSpecificHeaderBox{SystemID: want.SystemID, Data: want.Data}, wantOK: true},
+		{name: "PIFF pssh", box: &PiffProtectionSystemSpecificHeaderBox{SystemID: want.SystemID, Data: want.Data}, wantOK: true},
+		{name: "other box", box: &mp4.MovieHeaderBox{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system, ok := psshProtectionSystem(tt.box)
+			if ok != tt.wantOK {
+				t.Fatalf("psshProtectionSystem() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (system.SystemID != want.SystemID || !bytes.Equal(system.Data, want.Data)) {
+				t.Errorf("psshProtectionSystem() = %+v, want %+v", system, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package smoothstreaming
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate at which data is transferred. It may be shared
+// by several readers, e.g. the responses of the concurrent requests of a
+// Downloader, or of several Downloaders, to limit their total rate.
+type RateLimiter struct {
+	bytesPerSecond int64
+
+	mu sync.Mutex
+	// the time at which the data reserved so far is transferred at the rate
+	// of the limiter.
+	next time.Time
+}
+
+// NewRateLimiter returns a RateLimiter of the given rate.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	return &RateLimiter{bytesPerSecond: bytesPerSecond}
+}
+
+// Wait reserves n bytes and blocks until they are due at the rate of the
+// limiter, or until ctx is done. A limiter of rate 0 does not limit.
+func (l *RateLimiter) Wait(ctx context.Context, n int) (err error) {
+	if l == nil || l.bytesPerSecond <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		// the time spent idle is not credited
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSecond))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+	return
+}
+
+// rateLimitedReader limits the rate of reads from r by all of its limiters.
+type rateLimitedReader struct {
+	ctx      context.Context
+	r        io.ReadCloser
+	limiters []*RateLimiter
+}
+
+// rateLimitedReadSize is the largest read of a rateLimitedReader, so that the
+// data arrives smoothly rather than in bursts of the size of the buffers of
+// its callers.
+const rateLimitedReadSize = 16 * 1024
+
+func (rr *rateLimitedReader) Read(p []byte) (n int, err error) {
+	if len(p) > rateLimitedReadSize {
+		p = p[:rateLimitedReadSize]
+	}
+	n, err = rr.r.Read(p)
+	for _, l := range rr.limiters {
+		if waitErr := l.Wait(rr.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return
+}
+
+func (rr *rateLimitedReader) Close() error {
+	return rr.r.Close()
+}
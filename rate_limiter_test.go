@@ -0,0 +1,72 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(1000000)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background(), 50000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// each wait is due 50ms after the previous one
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("150000 bytes at 1000000 bytes per second waited %v, want 150ms", elapsed)
+	}
+}
+
+func TestRateLimiterWaitUnlimited(t *testing.T) {
+	for _, l := range []*RateLimiter{nil, NewRateLimiter(0)} {
+		start := time.Now()
+		if err := l.Wait(context.Background(), 1<<30); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("unlimited Wait() took %v", elapsed)
+		}
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewRateLimiter(1000).Wait(ctx, 1000000); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRateLimitedReader(t *testing.T) {
+	data := bytes.Repeat([]byte{0xaa}, 3*rateLimitedReadSize)
+	start := time.Now()
+	r := &rateLimitedReader{ctx: context.Background(), r: io.NopCloser(bytes.NewReader(data)), limiters: []*RateLimiter{NewRateLimiter(1000000), nil}}
+	buf := make([]byte, len(data))
+	n, err := r.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != rateLimitedReadSize {
+		t.Errorf("Read() of %d bytes read %d, want %d", len(buf), n, rateLimitedReadSize)
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(buf[:n], rest...), data) {
+		t.Error("read data differs from the response")
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 49152 bytes at 1000000 bytes per second take 49ms
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("read the response in %v, want 49ms", elapsed)
+	}
+}
@@ -0,0 +1,112 @@
+package smoothstreaming
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides whether and when a failed request of a Downloader is
+// retried. Requests failing with a network error or a retryable status are
+// retried after a delay growing exponentially with each attempt, randomized
+// so that concurrent clients do not retry in lockstep, or after the delay
+// requested by the Retry-After header of the response.
+type RetryPolicy struct {
+	// the number of retries of a request, none if 0.
+	MaxRetries int
+
+	// the delay before the first retry, 1 second if 0. Each following retry
+	// doubles it, up to MaxDelay.
+	InitialDelay time.Duration
+
+	// the longest delay before a retry, 30 seconds if 0. It also caps the
+	// delay requested by a Retry-After header.
+	MaxDelay time.Duration
+
+	// the response statuses retried, DefaultRetryStatuses if nil. Live
+	// origins answer 404 or 412 for fragments not yet available at the live
+	// edge.
+	RetryStatuses []int
+}
+
+// DefaultRetryStatuses are the response statuses retried by a RetryPolicy
+// by default.
+var DefaultRetryStatuses = []int{
+	http.StatusNotFound,
+	http.StatusRequestTimeout,
+	http.StatusPreconditionFailed,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// retryStatus reports whether a response of the given status is retried.
+func (p RetryPolicy) retryStatus(status int) bool {
+	statuses := p.RetryStatuses
+	if statuses == nil {
+		statuses = DefaultRetryStatuses
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the delay before the given retry, counted from 0, of a
+// request whose last response, nil after a network error, was resp.
+func (p RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return p.capDelay(after)
+		}
+	}
+	delay := p.InitialDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	for i := 0; i < retry && delay < p.maxDelay(); i++ {
+		delay *= 2
+	}
+	delay = p.capDelay(delay)
+	// equal jitter: between half the delay and the full delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// maxDelay returns the longest delay before a retry.
+func (p RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay <= 0 {
+		return 30 * time.Second
+	}
+	return p.MaxDelay
+}
+
+// capDelay returns delay, at most the longest delay before a retry.
+func (p RetryPolicy) capDelay(delay time.Duration) time.Duration {
+	if maxDelay := p.maxDelay(); delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// retryAfter parses the value of a Retry-After header, a number of seconds or
+// an HTTP date.
+func retryAfter(value string) (after time.Duration, ok bool) {
+	if value == "" {
+		return
+	}
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if after = time.Until(t); after < 0 {
+			after = 0
+		}
+		return after, true
+	}
+	return
+}
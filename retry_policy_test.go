@@ -0,0 +1,85 @@
+package smoothstreaming
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		retry      int
+		retryAfter string
+		wantMin    time.Duration
+		wantMax    time.Duration
+	}{
+		{name: "first retry", retry: 0, wantMin: 500 * time.Millisecond, wantMax: time.Second},
+		{name: "third retry", policy: RetryPolicy{InitialDelay: 100 * time.Millisecond}, retry: 2, wantMin: 200 * time.Millisecond, wantMax: 400 * time.Millisecond},
+		{name: "maximum delay", policy: RetryPolicy{InitialDelay: time.Second, MaxDelay: 5 * time.Second}, retry: 10, wantMin: 2500 * time.Millisecond, wantMax: 5 * time.Second},
+		{name: "default maximum delay", retry: 100, wantMin: 15 * time.Second, wantMax: 30 * time.Second},
+		{name: "Retry-After seconds", retry: 3, retryAfter: "2", wantMin: 2 * time.Second, wantMax: 2 * time.Second},
+		{name: "Retry-After above the maximum delay", policy: RetryPolicy{MaxDelay: 5 * time.Second}, retryAfter: "120", wantMin: 5 * time.Second, wantMax: 5 * time.Second},
+		{name: "invalid Retry-After", retryAfter: "soon", wantMin: 500 * time.Millisecond, wantMax: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			for i := 0; i < 20; i++ {
+				if delay := tt.policy.delay(tt.retry, resp); delay < tt.wantMin || delay > tt.wantMax {
+					t.Fatalf("delay(%d) = %v, want between %v and %v", tt.retry, delay, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantMin time.Duration
+		wantMax time.Duration
+		wantOK  bool
+	}{
+		{name: "empty"},
+		{name: "seconds", value: "120", wantMin: 2 * time.Minute, wantMax: 2 * time.Minute, wantOK: true},
+		{name: "date", value: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), wantMin: 58 * time.Second, wantMax: time.Minute, wantOK: true},
+		{name: "past date", value: "Wed, 21 Oct 2015 07:28:00 GMT", wantOK: true},
+		{name: "negative seconds", value: "-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after, ok := retryAfter(tt.value)
+			if ok != tt.wantOK || after < tt.wantMin || after > tt.wantMax {
+				t.Errorf("retryAfter(%q) = %v, %v, want between %v and %v, %v", tt.value, after, ok, tt.wantMin, tt.wantMax, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRetryStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		status int
+		want   bool
+	}{
+		{name: "default live edge", status: http.StatusPreconditionFailed, want: true},
+		{name: "default server error", status: http.StatusServiceUnavailable, want: true},
+		{name: "default forbidden", status: http.StatusForbidden, want: false},
+		{name: "custom", policy: RetryPolicy{RetryStatuses: []int{http.StatusForbidden}}, status: http.StatusForbidden, want: true},
+		{name: "custom excluding defaults", policy: RetryPolicy{RetryStatuses: []int{http.StatusForbidden}}, status: http.StatusNotFound, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.retryStatus(tt.status); got != tt.want {
+				t.Errorf("retryStatus(%d) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
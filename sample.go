@@ -0,0 +1,80 @@
+package smoothstreaming
+
+import (
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+)
+
+// Sample is a view of a sample of a Fragment, with the box math of the track
+// runs and of the sample encryption data done, for analysis tools and custom
+// transmuxers.
+type Sample struct {
+	// the track ID of the track fragment of the sample.
+	TrackID uint32
+
+	// the data of the sample, a slice of the Data of the fragment.
+	Data []byte
+
+	// the decode time of the sample, counted from the decode time of the
+	// track fragment, or from 0 if the fragment has no tfdt box or TfxdBox.
+	DecodeTime uint64
+
+	Duration              uint32
+	CompositionTimeOffset int64
+
+	// the sample flags, ISO/IEC 14496-12 8.8.3.1, see IsSync.
+	Flags uint32
+
+	// the IV and subsamples of the sample, nil if it is not encrypted.
+	Encryption *mp4.SampleEncryptionSampleEntry
+}
+
+// IsSync reports whether the sample is a sync sample, which decoding can
+// start from.
+func (s Sample) IsSync() bool {
+	return s.Flags&SampleFlagsIsNonSync == 0
+}
+
+// PresentationTime returns the composition time of the sample.
+func (s Sample) PresentationTime() int64 {
+	return int64(s.DecodeTime) + s.CompositionTimeOffset
+}
+
+// Samples returns the samples of the track fragments of the fragment, in the
+// order of the track fragments and in decode order. The values that neither
+// the track runs nor the track fragment header declare default to the ones
+// of the trex box of p, the init segment of the track.
+func (f Fragment) Samples(p MoovProcessor) (samples []Sample, err error) {
+	for _, t := range f.Tracks {
+		var resolved []fragmentSample
+		if resolved, err = t.samples(p); err != nil {
+			return
+		}
+		var entries []mp4.SampleEncryptionSampleEntry
+		if t.SampleEncryption != nil {
+			entries = t.SampleEncryption.Samples
+			if len(entries) != len(resolved) {
+				err = fmt.Errorf("track %d has %d samples but sample encryption data for %d: %w", t.Header.TrackID, len(resolved), len(entries), ErrInvalidParam)
+				return
+			}
+		}
+		decodeTime, _ := t.DecodeTime()
+		for i, r := range resolved {
+			sample := Sample{
+				TrackID:               t.Header.TrackID,
+				Data:                  f.Data[r.offset : r.offset+r.size],
+				DecodeTime:            decodeTime,
+				Duration:              r.duration,
+				CompositionTimeOffset: r.compositionTimeOffset,
+				Flags:                 r.flags,
+			}
+			if entries != nil {
+				sample.Encryption = &entries[i]
+			}
+			samples = append(samples, sample)
+			decodeTime += uint64(r.duration)
+		}
+	}
+	return
+}
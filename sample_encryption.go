@@ -0,0 +1,137 @@
+package smoothstreaming
+
+import (
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+)
+
+// ConvertToCENC replaces the PIFF uuid sample encryption boxes of the
+// fragment by the senc, saiz and saio boxes of Common Encryption, ISO/IEC
+// 23001-7 7, so that the fragment can be decrypted by tools that do not know
+// PIFF. The saio offsets are set when the fragment is written. The PIFF
+// override of the tenc parameters has no equivalent and is dropped, so the
+// tenc box of the init segment must declare the parameters of the fragment.
+func (f *Fragment) ConvertToCENC() (err error) {
+	for i := range f.Tracks {
+		t := &f.Tracks[i]
+		senc := t.SampleEncryption
+		if senc == nil || !senc.IsPIFF() {
+			continue
+		}
+		senc.Type = mp4.SencBoxType
+		senc.UserType = mp4.UserType{}
+		senc.Mp4BoxSetFlags(senc.Mp4BoxFlags() &^ mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS)
+		if err = t.locateSampleEncryption(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// locateSampleEncryption adds the saiz and saio boxes locating the sample
+// encryption data of the senc box of the track fragment before the senc box.
+func (t *FragmentTrack) locateSampleEncryption() (err error) {
+	senc := t.SampleEncryption
+	saiz := &SampleAuxiliaryInformationSizesBox{SampleCount: uint32(len(senc.Samples))}
+	for i, sample := range senc.Samples {
+		size := len(sample.InitializationVector)
+		if senc.hasSubsamples() {
+			size += 2 + 6*len(sample.Subsamples)
+		}
+		if size > 0xff {
+			err = fmt.Errorf("sample encryption data of sample %d exceeds 255 bytes: %w", i, ErrInvalidParam)
+			return
+		}
+		saiz.SampleInfoSizes = append(saiz.SampleInfoSizes, uint8(size))
+	}
+	if len(saiz.SampleInfoSizes) > 0 && allEqual(saiz.SampleInfoSizes) {
+		saiz.DefaultSampleInfoSize = saiz.SampleInfoSizes[0]
+		saiz.SampleInfoSizes = nil
+	}
+	saio := &SampleAuxiliaryInformationOffsetsBox{Offsets: []uint64{0}}
+
+	// the saiz and saio boxes precede the senc box they locate
+	var children []mp4.Box
+	for _, child := range t.Traf.Mp4BoxChildren() {
+		if child == mp4.Box(senc) {
+			children = append(children, saiz, saio)
+		}
+		children = append(children, child)
+	}
+	if err = t.Traf.Mp4BoxReplaceChildren(children); err != nil {
+		return
+	}
+	t.AuxInfoSizes, t.AuxInfoOffsets = saiz, saio
+	return
+}
+
+// ConvertToPIFF is the reverse of ConvertToCENC: the senc boxes of the
+// fragment become PIFF uuid sample encryption boxes and the saiz and saio
+// boxes locating them are removed, as expected by Smooth Streaming clients.
+func (f *Fragment) ConvertToPIFF() (err error) {
+	for i := range f.Tracks {
+		t := &f.Tracks[i]
+		senc := t.SampleEncryption
+		if senc == nil || senc.IsPIFF() {
+			continue
+		}
+		senc.Type = mp4.UuidBoxType
+		senc.UserType = mp4.SampleEncryptionBoxUserType
+
+		var children []mp4.Box
+		for _, child := range t.Traf.Mp4BoxChildren() {
+			if child == mp4.Box(t.AuxInfoSizes) || child == mp4.Box(t.AuxInfoOffsets) {
+				continue
+			}
+			children = append(children, child)
+		}
+		if err = t.Traf.Mp4BoxReplaceChildren(children); err != nil {
+			return
+		}
+		t.AuxInfoSizes, t.AuxInfoOffsets = nil, nil
+	}
+	return
+}
+
+// updateAuxInfoOffsets points the saio box of each track fragment at the
+// sample encryption data of its senc box, relative to the moof box. The sizes
+// of the boxes of the moof box must be up to date.
+func (f Fragment) updateAuxInfoOffsets() {
+	offset := uint64(8) // the moof box header
+	for _, child := range f.Moof.Mp4BoxChildren() {
+		for _, t := range f.Tracks {
+			if child == t.Traf && t.AuxInfoOffsets != nil && t.SampleEncryption != nil {
+				t.AuxInfoOffsets.Offsets = []uint64{offset + t.sampleEncryptionOffset()}
+			}
+		}
+		offset += uint64(child.Mp4BoxSize())
+	}
+}
+
+// sampleEncryptionOffset returns the offset of the sample entries of the senc
+// box from the start of the traf box.
+func (t FragmentTrack) sampleEncryptionOffset() (offset uint64) {
+	offset = 8 // the traf box header
+	for _, box := range t.Traf.Mp4BoxChildren() {
+		if box == mp4.Box(t.SampleEncryption) {
+			break
+		}
+		offset += uint64(box.Mp4BoxSize())
+	}
+	senc := t.SampleEncryption
+	offset += uint64(senc.HeaderSize()) + 4 // version and flags
+	if senc.Mp4BoxFlags()&mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS > 0 {
+		offset += 20
+	}
+	return offset + 4 // sample_count
+}
+
+func allEqual(sizes []uint8) bool {
+	for _, size := range sizes[1:] {
+		if size != sizes[0] {
+			return false
+		}
+	}
+	return true
+}
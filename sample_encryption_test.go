@@ -0,0 +1,117 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestFragmentConvertToCENC(t *testing.T) {
+	varying := testSampleEncryptionBox(16, true)
+	varying.Samples[1].Subsamples = append(varying.Samples[1].Subsamples, mp4.SampleEncryptionSubsampleEntry{BytesOfClearData: 1})
+	override := testSampleEncryptionBox(8, true)
+	override.KID = [16]byte(testPlayReadyKID)
+	override.Mp4BoxSetFlags(override.Mp4BoxFlags() | mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS)
+	tests := []struct {
+		name        string
+		senc        *SampleEncryptionBox
+		wantDefault uint8
+		wantSizes   []uint8
+	}{
+		{name: "PIFF 8-byte IVs", senc: testSampleEncryptionBox(8, true), wantDefault: 16},
+		{name: "varying subsample counts", senc: varying, wantSizes: []uint8{24, 30}},
+		{name: "override of the tenc parameters", senc: override, wantDefault: 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFragment(bytes.NewReader(testFragment(t, tt.senc)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = f.ConvertToCENC(); err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err = f.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			data := buf.Bytes()
+			got, err := ParseFragment(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			track := got.Tracks[0]
+			var types []mp4.BoxType
+			for _, child := range track.Traf.Mp4BoxChildren() {
+				types = append(types, child.Mp4BoxType())
+			}
+			wantTypes := []mp4.BoxType{mp4.TfhdBoxType, mp4.TrunBoxType, SaizBoxType, SaioBoxType, mp4.SencBoxType}
+			if !reflect.DeepEqual(types, wantTypes) {
+				t.Fatalf("traf children %v, want %v", types, wantTypes)
+			}
+			senc := track.SampleEncryption
+			if senc.IsPIFF() || senc.Mp4BoxFlags()&mp4.FLAG_SENC_OVERRIDE_TRACK_ENCRYPTION_BOX_PARAMS > 0 || !reflect.DeepEqual(senc.Samples, tt.senc.Samples) {
+				t.Errorf("senc %+v, want the samples of %+v", senc, tt.senc)
+			}
+			saiz := track.AuxInfoSizes
+			if saiz.SampleCount != 2 || saiz.DefaultSampleInfoSize != tt.wantDefault || !bytes.Equal(saiz.SampleInfoSizes, tt.wantSizes) {
+				t.Errorf("saiz %+v, want default %d, sizes %v", saiz, tt.wantDefault, tt.wantSizes)
+			}
+			// the saio offset locates the IV of the first sample
+			saio := track.AuxInfoOffsets
+			if len(saio.Offsets) != 1 {
+				t.Fatalf("saio offsets %v, want one", saio.Offsets)
+			}
+			iv := tt.senc.Samples[0].InitializationVector
+			if offset := saio.Offsets[0]; !bytes.Equal(data[offset:offset+uint64(len(iv))+2], append(append([]byte{}, iv...), 0, 1)) {
+				t.Errorf("data at the saio offset %d = %x, want the IV %x and one subsample", offset, data[offset:offset+uint64(len(iv))+2], iv)
+			}
+		})
+	}
+}
+
+func TestFragmentConvertToPIFF(t *testing.T) {
+	data := testFragment(t, testSampleEncryptionBox(8, true))
+	f, err := ParseFragment(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.ConvertToCENC(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.ConvertToPIFF(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Tracks[0].AuxInfoSizes != nil || f.Tracks[0].AuxInfoOffsets != nil {
+		t.Errorf("saiz %v, saio %v, want nil", f.Tracks[0].AuxInfoSizes, f.Tracks[0].AuxInfoOffsets)
+	}
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("WriteTo() = %x, want %x", buf.Bytes(), data)
+	}
+}
+
+func TestFragmentConvertClear(t *testing.T) {
+	data := testFragment(t, nil)
+	f, err := ParseFragment(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, convert := range []func() error{f.ConvertToCENC, f.ConvertToPIFF} {
+		if err = convert(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err = f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("WriteTo() = %x, want %x", buf.Bytes(), data)
+	}
+}
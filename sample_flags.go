@@ -0,0 +1,24 @@
+package smoothstreaming
+
+// Bits of the sample flags of the trex, tfhd and trun boxes, ISO/IEC 14496-12
+// 8.8.3.1.
+const (
+	// sample_depends_on = 1, the sample is not an I picture.
+	SampleFlagsDependsOnOthers uint32 = 0x01000000
+
+	// sample_depends_on = 2, the sample is an I picture or does not depend on
+	// other samples, as audio samples.
+	SampleFlagsDependsOnNone uint32 = 0x02000000
+
+	// sample_is_non_sync_sample.
+	SampleFlagsIsNonSync uint32 = 0x00010000
+)
+
+// Default sample flags of the samples of a track fragment: video samples are
+// non-sync samples that depend on other samples, so only samples flagged
+// otherwise in trun or by first_sample_flags are sync samples. Audio and text
+// samples are sync samples.
+const (
+	DefaultVideoSampleFlags = SampleFlagsDependsOnOthers | SampleFlagsIsNonSync
+	DefaultSyncSampleFlags  = SampleFlagsDependsOnNone
+)
@@ -0,0 +1,83 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestFragmentSamples(t *testing.T) {
+	data := withTfxd(t, testFragment(t, testSampleEncryptionBox(8, false)), 10000)
+	f, err := ParseFragment(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(f.Data, "0123456789abcdef")
+	run := f.Tracks[0].Runs[0]
+	run.Mp4BoxSetFlags(run.Mp4BoxFlags() | mp4.FLAG_TRUN_SAMPLE_COMPOSITION_TIME_OFFSET)
+	run.Samples[0].SampleCompositionTimeOffset = 1000
+	run.Samples[1].SampleCompositionTimeOffset = -1000
+	f.Tracks[0].Header.DefaultSampleFlags = DefaultVideoSampleFlags
+
+	samples, err := f.Samples(MoovProcessor{StreamType: VideoStream})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want 2", len(samples))
+	}
+	tests := []struct {
+		data             string
+		decodeTime       uint64
+		presentationTime int64
+		ivByte           byte
+	}{
+		{data: "0123456789", decodeTime: 10000, presentationTime: 11000, ivByte: 0},
+		{data: "abcdef", decodeTime: 11000, presentationTime: 10000, ivByte: 1},
+	}
+	for i, tt := range tests {
+		s := samples[i]
+		if s.TrackID != 1 || string(s.Data) != tt.data || s.Duration != 1000 {
+			t.Errorf("sample %d of track %d = %q lasting %d, want track 1 %q lasting 1000", i, s.TrackID, s.Data, s.Duration, tt.data)
+		}
+		if s.DecodeTime != tt.decodeTime || s.PresentationTime() != tt.presentationTime {
+			t.Errorf("sample %d decoded at %d, presented at %d, want %d, %d", i, s.DecodeTime, s.PresentationTime(), tt.decodeTime, tt.presentationTime)
+		}
+		if s.IsSync() {
+			t.Errorf("sample %d is a sync sample, want non-sync", i)
+		}
+		if s.Encryption == nil || !bytes.Equal(s.Encryption.InitializationVector, bytes.Repeat([]byte{tt.ivByte}, 8)) {
+			t.Errorf("sample %d encryption %+v, want IV of %#x bytes", i, s.Encryption, tt.ivByte)
+		}
+	}
+}
+
+func TestFragmentSamplesClear(t *testing.T) {
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples, err := f.Samples(MoovProcessor{StreamType: AudioStream})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range samples {
+		if s.Encryption != nil || !s.IsSync() || s.DecodeTime != uint64(i*1000) {
+			t.Errorf("sample %d encryption %+v, sync %v, decoded at %d, want clear sync sample at %d", i, s.Encryption, s.IsSync(), s.DecodeTime, i*1000)
+		}
+	}
+}
+
+func TestFragmentSamplesEncryptionMismatch(t *testing.T) {
+	senc := testSampleEncryptionBox(8, false)
+	senc.Samples = senc.Samples[:1]
+	f, err := ParseFragment(bytes.NewReader(testFragment(t, senc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Samples(MoovProcessor{}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("Samples() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
@@ -0,0 +1,400 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/go-webdl/bits"
+)
+
+// SCTE-35 splice command types, ANSI/SCTE 35 Table 7.
+const (
+	SpliceNullCommand           uint8 = 0x00
+	SpliceScheduleCommand       uint8 = 0x04
+	SpliceInsertCommand         uint8 = 0x05
+	TimeSignalCommand           uint8 = 0x06
+	BandwidthReservationCommand uint8 = 0x07
+	PrivateCommand              uint8 = 0xff
+)
+
+// spliceInfoTableID is the table_id of a splice_info_section.
+const spliceInfoTableID = 0xfc
+
+// SpliceInfoSection is an SCTE-35 splice_info_section, ANSI/SCTE 35 9.6, the
+// cue of an ad break or of a program boundary carried by the sparse signal
+// streams of live channels. Times are 33-bit PTS values in 90 kHz ticks.
+type SpliceInfoSection struct {
+	ProtocolVersion uint8
+
+	// the splice command and the descriptors of an encrypted section cannot
+	// be decoded and are left empty.
+	EncryptedPacket bool
+
+	// the offset added to the PTS values of the section, modulo 2^33.
+	PTSAdjustment uint64
+
+	Tier uint16
+
+	SpliceCommandType uint8
+
+	// the splice_insert command, nil unless SpliceCommandType is
+	// SpliceInsertCommand.
+	SpliceInsert *SpliceInsert
+
+	// the splice_time of the time_signal command, nil unless
+	// SpliceCommandType is TimeSignalCommand.
+	TimeSignal *SpliceTime
+
+	// the segmentation_descriptors of the section, which qualify the
+	// time_signal commands of SCTE 35 2013 and later.
+	SegmentationDescriptors []SegmentationDescriptor
+}
+
+// SpliceTime is a splice_time, ANSI/SCTE 35 9.4.1.
+type SpliceTime struct {
+	// the splice time, without the PTSAdjustment of the section, valid if
+	// TimeSpecified.
+	PTSTime       uint64
+	TimeSpecified bool
+}
+
+// SpliceInsert is a splice_insert command, ANSI/SCTE 35 9.7.3, signaling the
+// start or the end of a break out of the network.
+type SpliceInsert struct {
+	SpliceEventID uint32
+
+	// whether the command cancels the previously signaled event, in which
+	// case the other fields are not set.
+	Cancel bool
+
+	// true for the start of a break, false for the return to the network.
+	OutOfNetwork bool
+
+	// whether the whole program splices at SpliceTime, rather than each
+	// component at the time of its ComponentSplice.
+	ProgramSplice bool
+
+	// whether the splice happens at the earliest opportunity, without splice
+	// times.
+	SpliceImmediate bool
+
+	SpliceTime SpliceTime
+	Components []ComponentSplice
+
+	// the duration of the break, nil if not signaled.
+	BreakDuration *BreakDuration
+
+	UniqueProgramID uint16
+	AvailNum        uint8
+	AvailsExpected  uint8
+}
+
+// ComponentSplice is the splice time of an elementary stream of a
+// SpliceInsert that does not splice the whole program.
+type ComponentSplice struct {
+	ComponentTag uint8
+	SpliceTime   SpliceTime
+}
+
+// BreakDuration is a break_duration, ANSI/SCTE 35 9.4.2.
+type BreakDuration struct {
+	// whether the splice back to the network happens automatically at the
+	// end of the break, without a further splice_insert.
+	AutoReturn bool
+
+	// the duration of the break in 90 kHz ticks.
+	Duration uint64
+}
+
+// SegmentationDescriptor is a segmentation_descriptor, ANSI/SCTE 35 10.3.3,
+// identifying the kind of segment, e.g. a provider or distributor placement
+// opportunity, that starts or ends at the time of the command.
+type SegmentationDescriptor struct {
+	SegmentationEventID uint32
+
+	// whether the descriptor cancels the previously signaled segmentation
+	// event, in which case the other fields are not set.
+	Cancel bool
+
+	DeliveryNotRestricted bool
+
+	// the duration of the segment in 90 kHz ticks, nil if not signaled.
+	SegmentationDuration *uint64
+
+	// the type and the value of the segmentation unique program identifier.
+	UPIDType uint8
+	UPID     []byte
+
+	// the segmentation_type_id of ANSI/SCTE 35 Table 22, e.g. 0x34 for the
+	// start of a provider placement opportunity.
+	SegmentationTypeID uint8
+
+	SegmentNum       uint8
+	SegmentsExpected uint8
+}
+
+// ParseSpliceInfoSection parses a binary splice_info_section and verifies its
+// CRC_32.
+func ParseSpliceInfoSection(data []byte) (s SpliceInfoSection, err error) {
+	if len(data) < 3 || data[0] != spliceInfoTableID {
+		err = fmt.Errorf("not a splice_info_section: %w", ErrInvalidParam)
+		return
+	}
+	sectionLength := int(data[1]&0x0f)<<8 | int(data[2])
+	if sectionLength < 17 || 3+sectionLength > len(data) {
+		err = fmt.Errorf("splice_info_section of %d bytes truncated to %d: %w", 3+sectionLength, len(data), ErrInvalidParam)
+		return
+	}
+	data = data[:3+sectionLength]
+	if crc32MPEG2(data) != 0 {
+		err = fmt.Errorf("splice_info_section CRC_32 mismatch: %w", ErrInvalidParam)
+		return
+	}
+
+	br := bytes.NewReader(data[3 : len(data)-4])
+	r := bits.NewAccErrReader(br)
+	s.ProtocolVersion = uint8(r.Read(8))
+	s.EncryptedPacket = r.ReadFlag()
+	r.Read(6) // encryption_algorithm
+	s.PTSAdjustment = uint64(r.Read(33))
+	r.Read(8) // cw_index
+	s.Tier = uint16(r.Read(12))
+	spliceCommandLength := int(r.Read(12))
+	s.SpliceCommandType = uint8(r.Read(8))
+	if s.EncryptedPacket {
+		err = r.AccError()
+		return
+	}
+	commandStart := br.Len()
+	switch s.SpliceCommandType {
+	case SpliceInsertCommand:
+		s.SpliceInsert = readSpliceInsert(r)
+	case TimeSignalCommand:
+		spliceTime := readSpliceTime(r)
+		s.TimeSignal = &spliceTime
+	}
+	// the legacy splice_command_length 0xfff leaves the length of the command
+	// to its syntax
+	if spliceCommandLength != 0xfff {
+		if _, err = br.Seek(int64(br.Len()-(commandStart-spliceCommandLength)), io.SeekCurrent); err != nil {
+			err = fmt.Errorf("splice command truncated: %w", ErrInvalidParam)
+			return
+		}
+	}
+	descriptorLoopLength := int(r.Read(16))
+	if err = r.AccError(); err != nil || descriptorLoopLength > br.Len() {
+		err = fmt.Errorf("splice_info_section truncated: %w", ErrInvalidParam)
+		return
+	}
+	descriptors := make([]byte, descriptorLoopLength)
+	br.Read(descriptors)
+	for len(descriptors) >= 2 {
+		tag, length := descriptors[0], int(descriptors[1])
+		if 2+length > len(descriptors) {
+			err = fmt.Errorf("splice descriptor truncated: %w", ErrInvalidParam)
+			return
+		}
+		// segmentation_descriptor with the CUEI identifier
+		if tag == 0x02 && length >= 4 && string(descriptors[2:6]) == "CUEI" {
+			var descriptor SegmentationDescriptor
+			if descriptor, err = parseSegmentationDescriptor(descriptors[6 : 2+length]); err != nil {
+				return
+			}
+			s.SegmentationDescriptors = append(s.SegmentationDescriptors, descriptor)
+		}
+		descriptors = descriptors[2+length:]
+	}
+	return
+}
+
+func readSpliceTime(r *bits.AccErrReader) (t SpliceTime) {
+	t.TimeSpecified = r.ReadFlag()
+	if t.TimeSpecified {
+		r.Read(6) // reserved
+		t.PTSTime = uint64(r.Read(33))
+	} else {
+		r.Read(7) // reserved
+	}
+	return
+}
+
+func readSpliceInsert(r *bits.AccErrReader) (insert *SpliceInsert) {
+	insert = &SpliceInsert{}
+	insert.SpliceEventID = uint32(r.Read(32))
+	insert.Cancel = r.ReadFlag()
+	r.Read(7) // reserved
+	if insert.Cancel {
+		return
+	}
+	insert.OutOfNetwork = r.ReadFlag()
+	insert.ProgramSplice = r.ReadFlag()
+	durationFlag := r.ReadFlag()
+	insert.SpliceImmediate = r.ReadFlag()
+	r.Read(4) // event_id_compliance_flag and reserved
+	if insert.ProgramSplice && !insert.SpliceImmediate {
+		insert.SpliceTime = readSpliceTime(r)
+	}
+	if !insert.ProgramSplice {
+		componentCount := int(r.Read(8))
+		for i := 0; i < componentCount && r.AccError() == nil; i++ {
+			component := ComponentSplice{ComponentTag: uint8(r.Read(8))}
+			if !insert.SpliceImmediate {
+				component.SpliceTime = readSpliceTime(r)
+			}
+			insert.Components = append(insert.Components, component)
+		}
+	}
+	if durationFlag {
+		insert.BreakDuration = &BreakDuration{AutoReturn: r.ReadFlag()}
+		r.Read(6) // reserved
+		insert.BreakDuration.Duration = uint64(r.Read(33))
+	}
+	insert.UniqueProgramID = uint16(r.Read(16))
+	insert.AvailNum = uint8(r.Read(8))
+	insert.AvailsExpected = uint8(r.Read(8))
+	return
+}
+
+// parseSegmentationDescriptor parses the fields of a segmentation_descriptor
+// that follow its identifier.
+func parseSegmentationDescriptor(data []byte) (d SegmentationDescriptor, err error) {
+	br := bytes.NewReader(data)
+	r := bits.NewAccErrReader(br)
+	d.SegmentationEventID = uint32(r.Read(32))
+	d.Cancel = r.ReadFlag()
+	r.Read(7) // reserved
+	if !d.Cancel {
+		programSegmentation := r.ReadFlag()
+		durationFlag := r.ReadFlag()
+		d.DeliveryNotRestricted = r.ReadFlag()
+		r.Read(5) // restrictions or reserved
+		if !programSegmentation {
+			componentCount := int(r.Read(8))
+			for i := 0; i < componentCount && r.AccError() == nil; i++ {
+				r.Read(16) // component_tag and reserved
+				r.Read(33) // pts_offset
+			}
+		}
+		if durationFlag {
+			duration := uint64(r.Read(40))
+			d.SegmentationDuration = &duration
+		}
+		d.UPIDType = uint8(r.Read(8))
+		d.UPID = make([]byte, r.Read(8))
+		for i := range d.UPID {
+			d.UPID[i] = uint8(r.Read(8))
+		}
+		d.SegmentationTypeID = uint8(r.Read(8))
+		d.SegmentNum = uint8(r.Read(8))
+		d.SegmentsExpected = uint8(r.Read(8))
+	}
+	if err = r.AccError(); err != nil {
+		err = fmt.Errorf("segmentation_descriptor truncated: %w", ErrInvalidParam)
+	}
+	return
+}
+
+// SpliceTime returns the PTS of the splice signaled by the splice_insert or
+// time_signal command with the PTSAdjustment applied, false if the splice is
+// immediate or the command carries no time.
+func (s SpliceInfoSection) SpliceTime() (pts uint64, ok bool) {
+	var t SpliceTime
+	switch {
+	case s.SpliceInsert != nil:
+		t = s.SpliceInsert.SpliceTime
+	case s.TimeSignal != nil:
+		t = *s.TimeSignal
+	}
+	if !t.TimeSpecified {
+		return
+	}
+	return (t.PTSTime + s.PTSAdjustment) & (1<<33 - 1), true
+}
+
+// SpliceEvent is an SCTE-35 cue of a sparse signal stream.
+type SpliceEvent struct {
+	// the sparse sample carrying the cue, whose Time is the presentation
+	// time of the cue in the timescale of the stream.
+	Event SparseEvent
+
+	Section SpliceInfoSection
+}
+
+// SpliceEvents decodes the SCTE-35 cues of the events of a sparse signal
+// stream, usually of the SCMD or DATA subtype, see ManifestOutputEvents and
+// SparseFragmentEvents. The splice_info_section of an event is either its
+// binary data, its base64 encoding, or the Binary element of the SCTE 35 XML
+// schema. Events that carry none are skipped.
+func SpliceEvents(events []SparseEvent) (splices []SpliceEvent, err error) {
+	for _, event := range events {
+		data, ok := spliceInfoSectionData(event.Data)
+		if !ok {
+			continue
+		}
+		splice := SpliceEvent{Event: event}
+		if splice.Section, err = ParseSpliceInfoSection(data); err != nil {
+			err = fmt.Errorf("event at %d: %w", event.Time, err)
+			return
+		}
+		splices = append(splices, splice)
+	}
+	return
+}
+
+// spliceInfoSectionData returns the binary splice_info_section of a sparse
+// sample, false if the sample does not carry one.
+func spliceInfoSectionData(data []byte) (section []byte, ok bool) {
+	if len(data) > 0 && data[0] == spliceInfoTableID {
+		return data, true
+	}
+	text := bytes.TrimRight(bytes.TrimSpace(data), "\x00")
+	if bytes.HasPrefix(text, []byte("<")) {
+		if text, ok = scte35XMLBinary(text); !ok {
+			return
+		}
+	}
+	section, err := base64.StdEncoding.DecodeString(string(text))
+	if err != nil || len(section) == 0 || section[0] != spliceInfoTableID {
+		return nil, false
+	}
+	return section, true
+}
+
+// scte35XMLBinary returns the content of the first Binary element of an SCTE
+// 35 XML signal, the base64 encoding of its splice_info_section.
+func scte35XMLBinary(data []byte) (content []byte, ok bool) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		if start, isStart := token.(xml.StartElement); isStart && start.Name.Local == "Binary" {
+			var text string
+			if decoder.DecodeElement(&text, &start) != nil {
+				return
+			}
+			return bytes.TrimSpace([]byte(text)), true
+		}
+	}
+}
+
+// crc32MPEG2 returns the CRC-32 of MPEG-2 sections, ISO/IEC 13818-1 Annex A,
+// which is 0 over a section including its CRC_32 field.
+func crc32MPEG2(data []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
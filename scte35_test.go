@@ -0,0 +1,153 @@
+package smoothstreaming
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// SCTE-35 cues of ANSI/SCTE 35 14, a time_signal with a provider placement
+// opportunity start and a splice_insert of a break out of the network.
+const (
+	testTimeSignalCue   = "/DA0AAAAAAAA///wBQb+cr0AUAAeAhxDVUVJSAAAjn/PAAGlmbAICAAAAAAsoKGKNAIAmsnRfg=="
+	testSpliceInsertCue = "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo="
+)
+
+func decodeBase64(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseSpliceInfoSectionTimeSignal(t *testing.T) {
+	s, err := ParseSpliceInfoSection(decodeBase64(t, testTimeSignalCue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.SpliceCommandType != TimeSignalCommand || s.SpliceInsert != nil || s.Tier != 0xfff {
+		t.Errorf("command %d, splice insert %+v, tier %#x, want time_signal of tier 0xfff", s.SpliceCommandType, s.SpliceInsert, s.Tier)
+	}
+	if pts, ok := s.SpliceTime(); !ok || pts != 0x072bd0050 {
+		t.Errorf("SpliceTime() = %#x, %v, want 0x72bd0050", pts, ok)
+	}
+	if len(s.SegmentationDescriptors) != 1 {
+		t.Fatalf("got %d segmentation descriptors, want 1", len(s.SegmentationDescriptors))
+	}
+	d := s.SegmentationDescriptors[0]
+	if d.SegmentationEventID != 0x4800008e || d.Cancel || d.SegmentationDuration == nil || *d.SegmentationDuration != 27630000 {
+		t.Errorf("segmentation event %#x, cancel %v, duration %v, want 0x4800008e lasting 27630000", d.SegmentationEventID, d.Cancel, d.SegmentationDuration)
+	}
+	if d.UPIDType != 8 || string(d.UPID) != "\x00\x00\x00\x00\x2c\xa0\xa1\x8a" || d.SegmentationTypeID != 0x34 || d.SegmentNum != 2 || d.SegmentsExpected != 0 {
+		t.Errorf("segmentation UPID %d %x, type %#x, segment %d of %d, want 8 000000002ca0a18a, 0x34, 2 of 0",
+			d.UPIDType, d.UPID, d.SegmentationTypeID, d.SegmentNum, d.SegmentsExpected)
+	}
+}
+
+func TestParseSpliceInfoSectionSpliceInsert(t *testing.T) {
+	s, err := ParseSpliceInfoSection(decodeBase64(t, testSpliceInsertCue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	insert := s.SpliceInsert
+	if s.SpliceCommandType != SpliceInsertCommand || insert == nil {
+		t.Fatalf("command %d, want splice_insert", s.SpliceCommandType)
+	}
+	if insert.SpliceEventID != 0x4800008f || insert.Cancel || !insert.OutOfNetwork || !insert.ProgramSplice || insert.SpliceImmediate {
+		t.Errorf("splice insert %+v, want out of network program splice 0x4800008f", insert)
+	}
+	if insert.BreakDuration == nil || !insert.BreakDuration.AutoReturn || insert.BreakDuration.Duration != 5426421 {
+		t.Errorf("break duration %+v, want auto return after 5426421", insert.BreakDuration)
+	}
+	if pts, ok := s.SpliceTime(); !ok || pts != 1936310318 {
+		t.Errorf("SpliceTime() = %d, %v, want 1936310318", pts, ok)
+	}
+	// the avail_descriptor is not a segmentation_descriptor
+	if len(s.SegmentationDescriptors) != 0 {
+		t.Errorf("got %d segmentation descriptors, want none", len(s.SegmentationDescriptors))
+	}
+}
+
+func TestSpliceInfoSectionSpliceTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		section SpliceInfoSection
+		want    uint64
+		wantOK  bool
+	}{
+		{name: "splice null", section: SpliceInfoSection{SpliceCommandType: SpliceNullCommand}},
+		{name: "immediate", section: SpliceInfoSection{SpliceInsert: &SpliceInsert{SpliceImmediate: true}}},
+		{
+			name:    "adjusted modulo 2^33",
+			section: SpliceInfoSection{PTSAdjustment: 100, TimeSignal: &SpliceTime{PTSTime: 1<<33 - 50, TimeSpecified: true}},
+			want:    50,
+			wantOK:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := tt.section.SpliceTime(); got != tt.want || ok != tt.wantOK {
+				t.Errorf("SpliceTime() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseSpliceInfoSectionErrors(t *testing.T) {
+	cue := decodeBase64(t, testSpliceInsertCue)
+	corrupted := append([]byte{}, cue...)
+	corrupted[20] ^= 1
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty"},
+		{name: "not a splice_info_section", data: append([]byte{0xfd}, cue[1:]...)},
+		{name: "truncated", data: cue[:len(cue)-1]},
+		{name: "CRC mismatch", data: corrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseSpliceInfoSection(tt.data); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("ParseSpliceInfoSection() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestSpliceEvents(t *testing.T) {
+	xml := `<scte35:Signal xmlns:scte35="http://www.scte.org/schemas/35/2016"><scte35:Binary>` + testTimeSignalCue + `</scte35:Binary></scte35:Signal>`
+	events := []SparseEvent{
+		{Time: 1000, Data: decodeBase64(t, testSpliceInsertCue)},
+		{Time: 2000, Data: []byte(testTimeSignalCue + "\x00")},
+		{Time: 3000, Data: []byte(xml)},
+		{Time: 4000, Data: []byte("not a cue")},
+		{Time: 5000, Data: []byte("<Signal/>")},
+	}
+	splices, err := SpliceEvents(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(splices) != 3 {
+		t.Fatalf("got %d splice events, want 3", len(splices))
+	}
+	for i, want := range []uint8{SpliceInsertCommand, TimeSignalCommand, TimeSignalCommand} {
+		if splices[i].Event.Time != events[i].Time || splices[i].Section.SpliceCommandType != want {
+			t.Errorf("splice %d at %d of command %d, want at %d of command %d", i, splices[i].Event.Time, splices[i].Section.SpliceCommandType, events[i].Time, want)
+		}
+	}
+
+	corrupted := decodeBase64(t, testSpliceInsertCue)
+	corrupted[20] ^= 1
+	if _, err = SpliceEvents([]SparseEvent{{Data: corrupted}}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("SpliceEvents() of a corrupted cue error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestCRC32MPEG2(t *testing.T) {
+	if got := crc32MPEG2([]byte("123456789")); got != 0x0376e6e7 {
+		t.Errorf("crc32MPEG2() = %#x, want 0x0376e6e7", got)
+	}
+}
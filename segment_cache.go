@@ -0,0 +1,93 @@
+package smoothstreaming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SegmentCache is a cache of segments, e.g. the fragments fetched by a
+// Downloader, in a directory, so that repeated downloads of a presentation,
+// or of tracks sharing fragments, do not fetch them again. A segment is keyed
+// by its URL and its length: its file is named after the SHA-256 hash of the
+// URL followed by the length of the data, so that a file truncated by an
+// interruption is not mistaken for the segment. It may be shared by several
+// Downloaders, even across processes.
+type SegmentCache struct {
+	dir string
+}
+
+// NewSegmentCache returns a SegmentCache in dir, creating the directory if
+// needed.
+func NewSegmentCache(dir string) (c *SegmentCache, err error) {
+	if err = os.MkdirAll(dir, 0777); err != nil {
+		return
+	}
+	c = &SegmentCache{dir: dir}
+	return
+}
+
+// Get returns the data of the segment at u, ok reporting whether it is
+// cached.
+func (c *SegmentCache) Get(u *url.URL) (data []byte, ok bool, err error) {
+	key := c.key(u)
+	matches, err := filepath.Glob(filepath.Join(c.dir, key+"-*"))
+	if err != nil {
+		return
+	}
+	for _, path := range matches {
+		var length int64
+		if length, err = strconv.ParseInt(strings.TrimPrefix(filepath.Base(path), key+"-"), 10, 64); err != nil {
+			// not a segment, e.g. the temporary file of a Put
+			err = nil
+			continue
+		}
+		data, err = os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if int64(len(data)) == length {
+			ok = true
+			return
+		}
+	}
+	data = nil
+	return
+}
+
+// Put stores the data of the segment at u. The file is written atomically,
+// so that an interruption leaves no partial segment.
+func (c *SegmentCache) Put(u *url.URL, data []byte) (err error) {
+	path := filepath.Join(c.dir, fmt.Sprintf("%s-%d", c.key(u), len(data)))
+	tmp, err := os.CreateTemp(c.dir, filepath.Base(path)+".*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	err = os.Rename(tmp.Name(), path)
+	return
+}
+
+// key returns the prefix of the names of the files of the segment at u.
+func (c *SegmentCache) key(u *url.URL) string {
+	sum := sha256.Sum256([]byte(u.String()))
+	return hex.EncodeToString(sum[:])
+}
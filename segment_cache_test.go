@@ -0,0 +1,75 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSegmentCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := NewSegmentCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse("https://example.com/movie.ism/QualityLevels(1000)/Fragments(video=0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := c.Get(u); err != nil || ok {
+		t.Fatalf("Get() of an empty cache = %v, %v, want not cached", ok, err)
+	}
+	data := []byte("fragment data")
+	if err = c.Put(u, data); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := c.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !bytes.Equal(got, data) {
+		t.Errorf("Get() = %q, %v, want %q, true", got, ok, data)
+	}
+
+	other, err := url.Parse("https://example.com/movie.ism/QualityLevels(1000)/Fragments(video=20000000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err = c.Get(other); err != nil || ok {
+		t.Errorf("Get() of another URL = %v, %v, want not cached", ok, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("cache holds %d files, want 1", len(entries))
+	}
+}
+
+func TestSegmentCacheTruncated(t *testing.T) {
+	c, err := NewSegmentCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse("https://example.com/movie.ism/QualityLevels(1000)/Fragments(video=0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Put(u, []byte("fragment data")); err != nil {
+		t.Fatal(err)
+	}
+	// a file cut short, or a leftover temporary file, is not the segment
+	path := filepath.Join(c.dir, c.key(u)+"-13")
+	if err = os.Truncate(path, 8); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path+".123", []byte("fragment data"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok, err := c.Get(u); err != nil || ok {
+		t.Errorf("Get() of a truncated segment = %q, %v, %v, want not cached", data, ok, err)
+	}
+}
@@ -0,0 +1,86 @@
+package smoothstreaming
+
+import (
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+)
+
+// CreateSidxMp4Box returns the sidx box indexing the fragments of the track,
+// each a subsegment, for DASH on-demand files where the fragments follow the
+// sidx box as written by Fragment.WriteTo. The fragments must have been
+// rewritten with their final track ID and decode time, see FragmentRewriter,
+// as their sizes and earliest presentation times are taken as they are.
+//
+// A subsegment starts with a stream access point of type 1 if its first
+// sample is a sync sample.
+func (p MoovProcessor) CreateSidxMp4Box(fragments []Fragment) (sidx mp4.Box, err error) {
+	if len(fragments) > 0xffff {
+		err = fmt.Errorf("%d fragments exceed the sidx box: %w", len(fragments), ErrInvalidParam)
+		return
+	}
+	box := &SegmentIndexBox{
+		ReferenceID: p.TrackID,
+		Timescale:   uint32(p.Timescale),
+	}
+	for i, f := range fragments {
+		if len(f.Tracks) != 1 {
+			err = fmt.Errorf("fragment %d has %d track fragments: %w", i, len(f.Tracks), ErrInvalidParam)
+			return
+		}
+		t := f.Tracks[0]
+		var samples []fragmentSample
+		if samples, err = t.samples(p); err != nil {
+			return
+		}
+		var size uint64
+		if size, err = f.Size(); err != nil {
+			return
+		}
+		if size > 0x7fffffff {
+			err = fmt.Errorf("fragment %d of %d bytes exceeds 31 bits: %w", i, size, ErrInvalidParam)
+			return
+		}
+		ref := SegmentIndexReference{ReferencedSize: uint32(size)}
+		var duration uint64
+		for _, sample := range samples {
+			duration += uint64(sample.duration)
+		}
+		if duration > 0xffffffff {
+			err = fmt.Errorf("fragment %d duration %d exceeds 32 bits: %w", i, duration, ErrInvalidParam)
+			return
+		}
+		ref.SubsegmentDuration = uint32(duration)
+		if len(samples) > 0 && samples[0].flags&SampleFlagsIsNonSync == 0 {
+			ref.StartsWithSAP = true
+			ref.SAPType = 1
+		}
+		if i == 0 {
+			decodeTime, _ := t.DecodeTime()
+			box.EarliestPresentationTime = earliestPresentationTime(decodeTime, samples)
+		}
+		box.References = append(box.References, ref)
+	}
+	sidx = box
+	return
+}
+
+// earliestPresentationTime returns the composition time of the sample of
+// the fragment presented first.
+func earliestPresentationTime(decodeTime uint64, samples []fragmentSample) uint64 {
+	if len(samples) == 0 {
+		return decodeTime
+	}
+	earliest := int64(decodeTime) + samples[0].compositionTimeOffset
+	time := int64(decodeTime)
+	for _, sample := range samples {
+		if t := time + sample.compositionTimeOffset; t < earliest {
+			earliest = t
+		}
+		time += int64(sample.duration)
+	}
+	if earliest < 0 {
+		return 0
+	}
+	return uint64(earliest)
+}
@@ -0,0 +1,94 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestCreateSidxMp4Box(t *testing.T) {
+	var fragments []Fragment
+	var sizes []uint32
+	for i, time := range []uint64{10000, 12000} {
+		f, err := ParseFragment(bytes.NewReader(withTfxd(t, testFragment(t, nil), time)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			// the second fragment starts with a non-sync sample
+			run := f.Tracks[0].Runs[0]
+			run.Mp4BoxSetFlags(run.Mp4BoxFlags() | mp4.FLAG_TRUN_FIRST_SAMPLE_FLAGS)
+			run.FirstSampleFlags = DefaultVideoSampleFlags
+		}
+		var buf bytes.Buffer
+		if _, err = f.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		fragments = append(fragments, f)
+		sizes = append(sizes, uint32(buf.Len()))
+	}
+
+	p := MoovProcessor{TrackID: 1, Timescale: 10000000}
+	box, err := p.CreateSidxMp4Box(fragments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidx, ok := box.(*SegmentIndexBox)
+	if !ok {
+		t.Fatalf("CreateSidxMp4Box() = %T, want *SegmentIndexBox", box)
+	}
+	if sidx.ReferenceID != 1 || sidx.Timescale != 10000000 || sidx.EarliestPresentationTime != 10000 || sidx.FirstOffset != 0 {
+		t.Errorf("sidx %+v, want track 1 in 10000000 from 10000", sidx)
+	}
+	want := []SegmentIndexReference{
+		{ReferencedSize: sizes[0], SubsegmentDuration: 2000, StartsWithSAP: true, SAPType: 1},
+		{ReferencedSize: sizes[1], SubsegmentDuration: 2000},
+	}
+	if !reflect.DeepEqual(sidx.References, want) {
+		t.Errorf("references %+v, want %+v", sidx.References, want)
+	}
+}
+
+func TestCreateSidxMp4BoxErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		fragments []Fragment
+	}{
+		{name: "no track fragment", fragments: []Fragment{{}}},
+		{name: "too many fragments", fragments: make([]Fragment, 0x10000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (MoovProcessor{TrackID: 1}).CreateSidxMp4Box(tt.fragments); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("CreateSidxMp4Box() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestEarliestPresentationTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []fragmentSample
+		want    uint64
+	}{
+		{name: "no samples", want: 1000},
+		{name: "no offsets", samples: []fragmentSample{{duration: 100}, {duration: 100}}, want: 1000},
+		{
+			name:    "reordered samples",
+			samples: []fragmentSample{{duration: 100, compositionTimeOffset: 200}, {duration: 100, compositionTimeOffset: 0}, {duration: 100, compositionTimeOffset: -50}},
+			want:    1100,
+		},
+		{name: "negative time", samples: []fragmentSample{{duration: 100, compositionTimeOffset: -2000}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := earliestPresentationTime(1000, tt.samples); got != tt.want {
+				t.Errorf("earliestPresentationTime() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The extensions of the files of a Smooth Streaming presentation on an IIS
+// or Unified Origin server: the server manifest, the client manifest, and the
+// video, audio and text track files.
+const (
+	ServerManifestExt = ".ism"
+	ClientManifestExt = ".ismc"
+	VideoFileExt      = ".ismv"
+	AudioFileExt      = ".isma"
+	TextFileExt       = ".ismt"
+)
+
+// ServerManifest is the server manifest of a presentation, the .ism SMIL
+// document by which an IIS or Unified Origin server maps the tracks of its
+// client manifest to the track files holding their fragments.
+type ServerManifest struct {
+	XMLName xml.Name `xml:"smil"`
+	XMLNS   string   `xml:"xmlns,attr,omitempty"`
+
+	// the meta elements of the head, e.g. the clientManifestRelativePath.
+	Meta []ServerManifestMeta `xml:"head>meta"`
+
+	// the switch element of the body.
+	Switch ServerManifestSwitch `xml:"body>switch"`
+}
+
+// ServerManifestSwitch is the switch element of a server manifest, holding
+// its tracks.
+type ServerManifestSwitch struct {
+	Tracks []*ServerManifestTrack `xml:",any"`
+}
+
+// ServerManifestMeta is a meta element of the head of a server manifest.
+type ServerManifestMeta struct {
+	Name    string `xml:"name,attr"`
+	Content string `xml:"content,attr"`
+}
+
+// ServerManifestTrack is a track of a server manifest: a video, audio or
+// textstream element.
+type ServerManifestTrack struct {
+	// the name of the element, video, audio or textstream.
+	XMLName xml.Name
+
+	// the path of the track file, relative to the server manifest.
+	Src string `xml:"src,attr"`
+
+	// the bitrate of the track, in bits per second.
+	SystemBitrate uint32 `xml:"systemBitrate,attr"`
+
+	// the language of the track, an ISO 639 code such as "en" or "eng".
+	SystemLanguage string `xml:"systemLanguage,attr,omitempty"`
+
+	// the parameters of the track, e.g. its trackID and trackName.
+	Params []ServerManifestParam `xml:"param"`
+}
+
+// ServerManifestParam is a param element of a track of a server manifest.
+type ServerManifestParam struct {
+	Name      string `xml:"name,attr"`
+	Value     string `xml:"value,attr"`
+	ValueType string `xml:"valuetype,attr,omitempty"`
+}
+
+// ServerManifestOptions are the options of the generation of a server
+// manifest, see SmoothStreamingMedia.ServerManifest.
+type ServerManifestOptions struct {
+	// the path of the client manifest, relative to the server manifest.
+	ClientManifest string
+
+	// the path of the file of the track t of the stream s, relative to the
+	// server manifest, and the ID of the track in the file. If nil, each
+	// track is in its own file, named after its stream and its bitrate with
+	// the extension of the type of the stream, e.g. "video_2000000.ismv", as
+	// track 1.
+	TrackFile func(s *StreamIndex, t *Track) (src string, trackID uint32)
+}
+
+// Server manifest formats.
+const (
+	smilNamespace                = "http://www.w3.org/2001/SMIL20/Language"
+	smilClientManifestMeta       = "clientManifestRelativePath"
+	smilTrackIDParam             = "trackID"
+	smilTrackNameParam           = "trackName"
+	smilTextStreamElement        = "textstream"
+	smilParamValueTypeData       = "data"
+	defaultServerManifestTrackID = 1
+)
+
+// ParseServerManifest parses a server manifest, in UTF-8 or in UTF-16 with a
+// byte order mark.
+func ParseServerManifest(data []byte) (m *ServerManifest, err error) {
+	m = &ServerManifest{}
+	if err = decodeManifest(data, m); err != nil {
+		m = nil
+		return
+	}
+	for _, t := range m.Switch.Tracks {
+		// the namespace is that of the root, declared there when encoded
+		t.XMLName.Space = ""
+	}
+	return
+}
+
+// ServerManifest returns the server manifest of the presentation, mapping its
+// tracks to the files given by the options, each with the bitrate of the
+// track, the language of its stream, and the name of its stream as its
+// trackName. The tracks of the embedded streams, whose fragments are in the
+// client manifest, are left out.
+func (m *SmoothStreamingMedia) ServerManifest(opts ServerManifestOptions) (sm *ServerManifest, err error) {
+	trackFile := opts.TrackFile
+	if trackFile == nil {
+		trackFile = defaultTrackFile
+	}
+	sm = &ServerManifest{XMLNS: smilNamespace}
+	if opts.ClientManifest != "" {
+		sm.Meta = append(sm.Meta, ServerManifestMeta{Name: smilClientManifestMeta, Content: opts.ClientManifest})
+	}
+	for _, s := range m.Streams {
+		if s.ManifestOutput {
+			continue
+		}
+		name, ok := smilTrackElement(s.Type)
+		if !ok {
+			err = fmt.Errorf("stream %s of type %q: %w", s.streamName(), s.Type, ErrInvalidParam)
+			return
+		}
+		for _, t := range s.Tracks {
+			src, trackID := trackFile(s, t)
+			track := &ServerManifestTrack{XMLName: xml.Name{Local: name}, Src: src, SystemBitrate: t.Bitrate}
+			if s.Language != nil {
+				track.SystemLanguage = *s.Language
+			}
+			track.Params = append(track.Params, ServerManifestParam{Name: smilTrackIDParam, Value: strconv.FormatUint(uint64(trackID), 10), ValueType: smilParamValueTypeData})
+			if s.Name != nil {
+				track.Params = append(track.Params, ServerManifestParam{Name: smilTrackNameParam, Value: *s.Name, ValueType: smilParamValueTypeData})
+			}
+			sm.Switch.Tracks = append(sm.Switch.Tracks, track)
+		}
+	}
+	return
+}
+
+// smilTrackElement returns the name of the elements of the tracks of the
+// given type in a server manifest, and whether the type has one.
+func smilTrackElement(typ StreamType) (name string, ok bool) {
+	switch typ {
+	case VideoStream, AudioStream:
+		return string(typ), true
+	case TextStream:
+		return smilTextStreamElement, true
+	}
+	return
+}
+
+// defaultTrackFile names the file of the track t of the stream s after the
+// stream and the bitrate of the track, the track being the only one of its
+// file.
+func defaultTrackFile(s *StreamIndex, t *Track) (src string, trackID uint32) {
+	ext := VideoFileExt
+	switch s.Type {
+	case AudioStream:
+		ext = AudioFileExt
+	case TextStream:
+		ext = TextFileExt
+	}
+	name := strings.Join(strings.Fields(s.streamName()), "_")
+	return fmt.Sprintf("%s_%d%s", name, t.Bitrate, ext), defaultServerManifestTrackID
+}
+
+// ClientManifest returns the path of the client manifest, relative to the
+// server manifest, empty if the head has no clientManifestRelativePath.
+func (m *ServerManifest) ClientManifest() string {
+	for _, meta := range m.Meta {
+		if meta.Name == smilClientManifestMeta {
+			return meta.Content
+		}
+	}
+	return ""
+}
+
+// Bytes returns the XML document of the server manifest.
+func (m *ServerManifest) Bytes() (data []byte, err error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&b)
+	encoder.Indent("", "  ")
+	if err = encoder.Encode(m); err != nil {
+		return
+	}
+	b.WriteByte('\n')
+	data = b.Bytes()
+	return
+}
+
+// StreamType returns the type of the stream of the track.
+func (t *ServerManifestTrack) StreamType() (typ StreamType, err error) {
+	switch t.XMLName.Local {
+	case string(VideoStream), string(AudioStream):
+		typ = StreamType(t.XMLName.Local)
+	case smilTextStreamElement:
+		typ = TextStream
+	default:
+		err = fmt.Errorf("server manifest track %s: %w", t.XMLName.Local, ErrInvalidParam)
+	}
+	return
+}
+
+// Param returns the value of the param of the track of the given name, and
+// whether the track has it.
+func (t *ServerManifestTrack) Param(name string) (value string, ok bool) {
+	for _, param := range t.Params {
+		if param.Name == name {
+			return param.Value, true
+		}
+	}
+	return
+}
+
+// TrackID returns the ID of the track in its file, from its trackID param, 1
+// if it has none.
+func (t *ServerManifestTrack) TrackID() (id uint32, err error) {
+	value, ok := t.Param(smilTrackIDParam)
+	if !ok {
+		return defaultServerManifestTrackID, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		err = fmt.Errorf("trackID %q of %s: %w", value, t.Src, ErrInvalidParam)
+		return
+	}
+	id = uint32(n)
+	return
+}
+
+// TrackName returns the name of the stream of the track, from its trackName
+// param, empty if it has none.
+func (t *ServerManifestTrack) TrackName() string {
+	name, _ := t.Param(smilTrackNameParam)
+	return name
+}
@@ -0,0 +1,147 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testServerManifest = `<?xml version="1.0" encoding="utf-16"?>
+<smil xmlns="http://www.w3.org/2001/SMIL20/Language">
+  <head>
+    <meta name="clientManifestRelativePath" content="movie.ismc"/>
+  </head>
+  <body>
+    <switch>
+      <video src="movie.ismv" systemBitrate="2000000">
+        <param name="trackID" value="2" valuetype="data"/>
+      </video>
+      <audio src="movie.ismv" systemBitrate="128000" systemLanguage="eng">
+        <param name="trackID" value="1" valuetype="data"/>
+        <param name="trackName" value="audio_eng" valuetype="data"/>
+      </audio>
+      <textstream src="subtitles.ismt" systemBitrate="1000"/>
+    </switch>
+  </body>
+</smil>`
+
+func TestParseServerManifest(t *testing.T) {
+	m, err := ParseServerManifest(append([]byte{0xff, 0xfe}, encodeWRMHeader(testServerManifest)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.ClientManifest(); got != "movie.ismc" {
+		t.Errorf("ClientManifest() = %q, want movie.ismc", got)
+	}
+	tests := []struct {
+		src        string
+		bitrate    uint32
+		streamType StreamType
+		trackID    uint32
+		name       string
+		language   string
+	}{
+		{src: "movie.ismv", bitrate: 2000000, streamType: VideoStream, trackID: 2},
+		{src: "movie.ismv", bitrate: 128000, streamType: AudioStream, trackID: 1, name: "audio_eng", language: "eng"},
+		{src: "subtitles.ismt", bitrate: 1000, streamType: TextStream, trackID: 1},
+	}
+	if len(m.Switch.Tracks) != len(tests) {
+		t.Fatalf("got %d tracks, want %d", len(m.Switch.Tracks), len(tests))
+	}
+	for i, tt := range tests {
+		track := m.Switch.Tracks[i]
+		if track.XMLName.Space != "" || track.Src != tt.src || track.SystemBitrate != tt.bitrate || track.SystemLanguage != tt.language || track.TrackName() != tt.name {
+			t.Errorf("track %d = %+v", i, track)
+		}
+		if streamType, err := track.StreamType(); err != nil || streamType != tt.streamType {
+			t.Errorf("track %d StreamType() = %s, %v, want %s", i, streamType, err, tt.streamType)
+		}
+		if trackID, err := track.TrackID(); err != nil || trackID != tt.trackID {
+			t.Errorf("track %d TrackID() = %d, %v, want %d", i, trackID, err, tt.trackID)
+		}
+	}
+
+	if _, err = ParseServerManifest([]byte("<smil>")); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ParseServerManifest() of a truncated manifest error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestServerManifestTrackInvalid(t *testing.T) {
+	track := &ServerManifestTrack{Src: "movie.ismv", Params: []ServerManifestParam{{Name: "trackID", Value: "two"}}}
+	track.XMLName.Local = "ref"
+	if _, err := track.StreamType(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("StreamType() error = %v, want %v", err, ErrInvalidParam)
+	}
+	if _, err := track.TrackID(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("TrackID() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestSmoothStreamingMediaServerManifest(t *testing.T) {
+	m, err := ParseManifest([]byte(strings.Replace(testDASHManifest, `Name="events" Subtype="DATA"`, `Name="events" Subtype="DATA" ManifestOutput="TRUE"`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm, err := m.ServerManifest(ServerManifestOptions{ClientManifest: "movie.ismc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tracks of the embedded events stream are left out
+	wantSrc := []string{"video_1000.ismv", "video_500.ismv", "audio_eng_128000.isma", "subtitles_1000.ismt", "no_url_1000.ismv"}
+	if len(sm.Switch.Tracks) != len(wantSrc) {
+		t.Fatalf("got %d tracks, want %d", len(sm.Switch.Tracks), len(wantSrc))
+	}
+	for i, src := range wantSrc {
+		if got := sm.Switch.Tracks[i].Src; got != src {
+			t.Errorf("track %d src %s, want %s", i, got, src)
+		}
+	}
+	audio := sm.Switch.Tracks[2]
+	if audio.XMLName.Local != "audio" || audio.SystemBitrate != 128000 || audio.SystemLanguage != "en" || audio.TrackName() != "audio eng" {
+		t.Errorf("audio track %+v", audio)
+	}
+	if sm.Switch.Tracks[3].XMLName.Local != "textstream" {
+		t.Errorf("text track element %s, want textstream", sm.Switch.Tracks[3].XMLName.Local)
+	}
+
+	// the generated manifest parses back
+	data, err := sm.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseServerManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ClientManifest() != "movie.ismc" || len(parsed.Switch.Tracks) != len(wantSrc) {
+		t.Fatalf("parsed server manifest %+v", parsed)
+	}
+	for i, track := range parsed.Switch.Tracks {
+		want := sm.Switch.Tracks[i]
+		if track.XMLName != want.XMLName || track.Src != want.Src || track.SystemBitrate != want.SystemBitrate || track.TrackName() != want.TrackName() {
+			t.Errorf("parsed track %d = %+v, want %+v", i, track, want)
+		}
+		if trackID, err := track.TrackID(); err != nil || trackID != 1 {
+			t.Errorf("parsed track %d TrackID() = %d, %v, want 1", i, trackID, err)
+		}
+	}
+
+	// the tracks of a stream share the file given
+	sm, err = m.ServerManifest(ServerManifestOptions{TrackFile: func(s *StreamIndex, t *Track) (string, uint32) {
+		return "movie" + VideoFileExt, t.Index + 1
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sm.Meta) != 0 || sm.Switch.Tracks[1].Src != "movie.ismv" {
+		t.Errorf("server manifest %+v", sm)
+	}
+	if trackID, _ := sm.Switch.Tracks[1].TrackID(); trackID != 2 {
+		t.Errorf("TrackID() = %d, want 2", trackID)
+	}
+
+	m.Streams[0].Type = "image"
+	if _, err = m.ServerManifest(ServerManifestOptions{}); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ServerManifest() of an image stream error = %v, want %v", err, ErrInvalidParam)
+	}
+}
@@ -3,6 +3,7 @@
 package smoothstreaming
 
 import (
+	"fmt"
 	"net/url"
 	"path"
 	"strconv"
@@ -112,6 +113,11 @@ type StreamIndex struct {
 	// The name of the stream.
 	Name *string `xml:",attr"`
 
+	// The language of the stream, an ISO 639 code such as "en" or "eng". Not
+	// defined by [MS-SSTR], but written by IIS Media Services and most
+	// encoders.
+	Language *string `xml:",attr"`
+
 	// The number of fragments that are available for this stream.
 	NumberOfFragments *uint32 `xml:"Chunks,attr"`
 
@@ -400,6 +406,33 @@ type Attribute struct {
 	Value string `xml:",attr"`
 }
 
+// String formats the attributes as the CustomAttributesPredicate of a Fragment
+// Request message, i.e. comma separated Name=Value pairs in manifest order.
+func (a *CustomAttributes) String() string {
+	if a == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(a.Attributes))
+	for _, attr := range a.Attributes {
+		pairs = append(pairs, attr.Name+"="+attr.Value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Value returns the value of the attribute with the given name, compared case
+// insensitively.
+func (a *CustomAttributes) Value(name string) (value string, ok bool) {
+	if a == nil {
+		return
+	}
+	for _, attr := range a.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			return attr.Value, true
+		}
+	}
+	return
+}
+
 // An XML element that encapsulates metadata that is required by the client to
 // play back protected content.
 type Protection struct {
@@ -428,14 +461,154 @@ const (
 )
 
 func ChunkURL(baseURL *url.URL, stream *StreamIndex, level *Track, startTime uint64) *url.URL {
-	u := *baseURL
-	c := *stream.URL
+	c := expandURLPattern(*stream.URL, level, startTime)
+	u, err := resolveChunkURL(baseURL, c)
+	if err != nil {
+		// a pattern that cannot be resolved is joined as is, see BuildChunkURL
+		v := *baseURL
+		v.Path = path.Join(path.Dir(v.Path), c)
+		v.RawPath = ""
+		u = &v
+	}
+	return u
+}
+
+// BuildChunkURL is like ChunkURL but validates its input and reports an error
+// instead of producing a URL that can only fail once it is requested.
+func BuildChunkURL(baseURL *url.URL, stream *StreamIndex, level *Track, startTime uint64) (u *url.URL, err error) {
+	if baseURL == nil {
+		err = fmt.Errorf("base url is nil: %w", ErrInvalidParam)
+		return
+	}
+	if stream == nil || stream.URL == nil {
+		err = fmt.Errorf("stream has no url pattern: %w", ErrInvalidParam)
+		return
+	}
+	if level == nil {
+		err = fmt.Errorf("track is nil: %w", ErrInvalidParam)
+		return
+	}
+	c := expandURLPattern(*stream.URL, level, startTime)
+	if start := strings.IndexByte(c, '{'); start >= 0 {
+		placeholder := c[start:]
+		if end := strings.IndexByte(placeholder, '}'); end >= 0 {
+			placeholder = placeholder[:end+1]
+		}
+		err = fmt.Errorf("url pattern %q contains %s: %w", *stream.URL, placeholder, ErrUnresolvedPlaceholder)
+		return
+	}
+	if u, err = resolveChunkURL(baseURL, c); err != nil {
+		u = nil
+		return
+	}
+	return
+}
+
+// ChunkRequest is a fragment of a track together with the url it is requested
+// from.
+type ChunkRequest struct {
+	Fragment TimelineFragment
+	URL      *url.URL
+}
+
+// ChunkURLs resolves the fragment timeline of the stream and builds the
+// request url of every fragment of the given track, in timeline order.
+func ChunkURLs(baseURL *url.URL, stream *StreamIndex, track *Track) (requests []ChunkRequest, err error) {
+	if stream == nil {
+		err = fmt.Errorf("stream is nil: %w", ErrInvalidParam)
+		return
+	}
+	fragments, err := stream.Timeline()
+	if err != nil {
+		return
+	}
+	return chunkRequests(baseURL, stream, track, fragments)
+}
+
+// ChunkURLs is like the ChunkURLs function, with the fragment timeline of the
+// stream resolved within the presentation, see StreamTimeline.
+func (m *SmoothStreamingMedia) ChunkURLs(baseURL *url.URL, stream *StreamIndex, track *Track) (requests []ChunkRequest, err error) {
+	if stream == nil {
+		err = fmt.Errorf("stream is nil: %w", ErrInvalidParam)
+		return
+	}
+	fragments, err := m.StreamTimeline(stream)
+	if err != nil {
+		return
+	}
+	return chunkRequests(baseURL, stream, track, fragments)
+}
+
+// chunkRequests builds the request url of every fragment of the given track.
+func chunkRequests(baseURL *url.URL, stream *StreamIndex, track *Track, fragments []TimelineFragment) (requests []ChunkRequest, err error) {
+	requests = make([]ChunkRequest, 0, len(fragments))
+	for _, fragment := range fragments {
+		var u *url.URL
+		if u, err = BuildChunkURL(baseURL, stream, track, fragment.Time); err != nil {
+			requests = nil
+			return
+		}
+		requests = append(requests, ChunkRequest{Fragment: fragment, URL: u})
+	}
+	return
+}
+
+// resolveChunkURL places an expanded url pattern relative to the manifest url.
+// Fully qualified patterns are resolved as references on their own, as joining
+// them into the manifest path would mangle the scheme and host. The expanded
+// pattern is already escaped, so it is joined to the escaped manifest path.
+func resolveChunkURL(baseURL *url.URL, c string) (u *url.URL, err error) {
+	if isAbsoluteURLPattern(c) {
+		var ref *url.URL
+		if ref, err = url.Parse(c); err == nil {
+			u = baseURL.ResolveReference(ref)
+			return
+		}
+		err = fmt.Errorf("absolute url pattern %q: %w", c, err)
+		return
+	}
+	ref, query := c, ""
+	if i := strings.IndexByte(c, '?'); i >= 0 {
+		ref, query = c[:i], c[i+1:]
+	}
+	v := *baseURL
+	v.RawPath = path.Join(path.Dir(baseURL.EscapedPath()), ref)
+	if v.Path, err = url.PathUnescape(v.RawPath); err != nil {
+		return
+	}
+	if query != "" {
+		if v.RawQuery != "" {
+			query += "&" + v.RawQuery
+		}
+		v.RawQuery = query
+	}
+	u = &v
+	return
+}
+
+func isAbsoluteURLPattern(pattern string) bool {
+	lower := strings.ToLower(pattern)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// expandURLPattern substitutes the placeholders of a url pattern. The literal
+// parts of the pattern and the substituted values are percent-encoded, so the
+// result is an escaped url reference.
+func expandURLPattern(pattern string, level *Track, startTime uint64) string {
+	c := escapeURLPattern(pattern)
 	bitrateStr := strconv.FormatUint(uint64(level.Bitrate), 10)
 	starttimeStr := strconv.FormatUint(startTime, 10)
 	c = strings.ReplaceAll(c, "{bitrate}", bitrateStr)
 	c = strings.ReplaceAll(c, "{Bitrate}", bitrateStr)
 	c = strings.ReplaceAll(c, "{start time}", starttimeStr)
 	c = strings.ReplaceAll(c, "{start_time}", starttimeStr)
-	u.Path = path.Join(path.Dir(u.Path), c)
-	return &u
+	if customAttributesStr := escapeCustomAttributes(level.CustomAttributes); customAttributesStr != "" {
+		c = strings.ReplaceAll(c, "{CustomAttributes}", customAttributesStr)
+	} else {
+		// CustomAttributesPattern is always preceded by a comma in the
+		// QualityLevels predicate, drop both when there is nothing to expand.
+		c = strings.ReplaceAll(c, ",{CustomAttributes}", "")
+		c = strings.ReplaceAll(c, "{CustomAttributes}", "")
+	}
+	return c
 }
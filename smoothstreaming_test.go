@@ -0,0 +1,237 @@
+package smoothstreaming
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestChunkURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		manifest   string
+		pattern    string
+		attributes []*Attribute
+		want       string
+	}{
+		{
+			name:     "alternative placeholders",
+			manifest: "http://example.com/movie.ism/Manifest",
+			pattern:  "QualityLevels({Bitrate})/Fragments(video={start_time})",
+			want:     "http://example.com/movie.ism/QualityLevels(2000000)/Fragments(video=123)",
+		},
+		{
+			name:     "relative pattern",
+			manifest: "http://example.com/media/movie.ism/Manifest",
+			pattern:  "QualityLevels({bitrate})/Fragments(video={start time})",
+			want:     "http://example.com/media/movie.ism/QualityLevels(2000000)/Fragments(video=123)",
+		},
+		{
+			name:     "escaped manifest path",
+			manifest: "http://example.com/media/My%20Movie.ism/Manifest",
+			pattern:  "QualityLevels({bitrate})/Fragments(video={start time})",
+			want:     "http://example.com/media/My%20Movie.ism/QualityLevels(2000000)/Fragments(video=123)",
+		},
+		{
+			name:     "space in the track name",
+			manifest: "http://example.com/movie.ism/Manifest",
+			pattern:  "QualityLevels({bitrate})/Fragments(video track={start time})",
+			want:     "http://example.com/movie.ism/QualityLevels(2000000)/Fragments(video%20track=123)",
+		},
+		{
+			name:     "escaped pattern",
+			manifest: "http://example.com/movie.ism/Manifest",
+			pattern:  "QualityLevels({bitrate})/Fragments(video%20track={start time})",
+			want:     "http://example.com/movie.ism/QualityLevels(2000000)/Fragments(video%20track=123)",
+		},
+		{
+			name:       "custom attributes",
+			manifest:   "http://example.com/movie.ism/Manifest",
+			pattern:    "QualityLevels({bitrate},{CustomAttributes})/Fragments(video={start time})",
+			attributes: []*Attribute{{Name: "hardware profile", Value: "a=b"}},
+			want:       "http://example.com/movie.ism/QualityLevels(2000000,hardware%20profile=a%3Db)/Fragments(video=123)",
+		},
+		{
+			name:     "no custom attributes",
+			manifest: "http://example.com/movie.ism/Manifest",
+			pattern:  "QualityLevels({bitrate},{CustomAttributes})/Fragments(video={start time})",
+			want:     "http://example.com/movie.ism/QualityLevels(2000000)/Fragments(video=123)",
+		},
+		{
+			name:     "query of the pattern and the manifest",
+			manifest: "http://example.com/movie.ism/Manifest?auth=x%2By",
+			pattern:  "QualityLevels({bitrate})/Fragments(video={start time})?token=1",
+			want:     "http://example.com/movie.ism/QualityLevels(2000000)/Fragments(video=123)?token=1&auth=x%2By",
+		},
+		{
+			name:     "absolute pattern",
+			manifest: "http://example.com/movie.ism/Manifest",
+			pattern:  "https://cdn.example.com/movie.ism/QualityLevels({bitrate})/Fragments(video={start time})",
+			want:     "https://cdn.example.com/movie.ism/QualityLevels(2000000)/Fragments(video=123)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := url.Parse(tt.manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream := &StreamIndex{Type: VideoStream, URL: &tt.pattern}
+			track := &Track{Bitrate: 2000000}
+			if tt.attributes != nil {
+				track.CustomAttributes = &CustomAttributes{Attributes: tt.attributes}
+			}
+			if got := ChunkURL(base, stream, track, 123).String(); got != tt.want {
+				t.Errorf("ChunkURL() = %s, want %s", got, tt.want)
+			}
+			u, err := BuildChunkURL(base, stream, track, 123)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := u.String(); got != tt.want {
+				t.Errorf("BuildChunkURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCustomAttributesString(t *testing.T) {
+	var empty *CustomAttributes
+	if got := empty.String(); got != "" {
+		t.Errorf("String() of nil attributes = %q, want \"\"", got)
+	}
+	a := &CustomAttributes{Attributes: []*Attribute{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}}
+	if got, want := a.String(), "a=1,b=2"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestBuildChunkURLErrors(t *testing.T) {
+	base, err := url.Parse("http://example.com/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern := "QualityLevels({bitrate})/Fragments(video={start time})"
+	unknown := "QualityLevels({bitrate})/Fragments(video={start time},{unknown})"
+	tests := []struct {
+		name    string
+		base    *url.URL
+		stream  *StreamIndex
+		track   *Track
+		wantErr error
+	}{
+		{name: "no base url", stream: &StreamIndex{URL: &pattern}, track: &Track{}, wantErr: ErrInvalidParam},
+		{name: "no stream", base: base, track: &Track{}, wantErr: ErrInvalidParam},
+		{name: "no url pattern", base: base, stream: &StreamIndex{}, track: &Track{}, wantErr: ErrInvalidParam},
+		{name: "no track", base: base, stream: &StreamIndex{URL: &pattern}, wantErr: ErrInvalidParam},
+		{name: "unresolved placeholder", base: base, stream: &StreamIndex{URL: &unknown}, track: &Track{}, wantErr: ErrUnresolvedPlaceholder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if u, err := BuildChunkURL(tt.base, tt.stream, tt.track, 0); !errors.Is(err, tt.wantErr) {
+				t.Errorf("BuildChunkURL() = %v, %v, want error %v", u, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChunkURLAbsolutePattern(t *testing.T) {
+	base, err := url.Parse("http://example.com/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern := "HTTPS://cdn.example.com/movie.ism/QualityLevels({bitrate})/Fragments(video={start time})"
+	stream := &StreamIndex{Type: VideoStream, URL: &pattern}
+	track := &Track{Bitrate: 2000000}
+	u, err := BuildChunkURL(base, stream, track, 123)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []*url.URL{u, ChunkURL(base, stream, track, 123)} {
+		if u.Scheme != "https" || u.Host != "cdn.example.com" || u.Path != "/movie.ism/QualityLevels(2000000)/Fragments(video=123)" {
+			t.Errorf("chunk url = %s, want https://cdn.example.com/movie.ism/QualityLevels(2000000)/Fragments(video=123)", u)
+		}
+	}
+}
+
+func TestChunkURLInvalidAbsolutePattern(t *testing.T) {
+	base, err := url.Parse("http://example.com/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern := "http://[::1/QualityLevels({bitrate})/Fragments(video={start time})"
+	stream := &StreamIndex{Type: VideoStream, URL: &pattern}
+	track := &Track{Bitrate: 2000000}
+	if u := ChunkURL(base, stream, track, 123); u == nil {
+		t.Error("ChunkURL() = nil")
+	}
+	if u, err := BuildChunkURL(base, stream, track, 123); err == nil {
+		t.Errorf("BuildChunkURL() = %s, want an error", u)
+	}
+}
+
+func TestChunkURLs(t *testing.T) {
+	base, err := url.Parse("http://example.com/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m SmoothStreamingMedia
+	if err = xml.Unmarshal([]byte(`<SmoothStreamingMedia Duration="50">`+
+		`<StreamIndex Type="video" Url="QualityLevels({bitrate})/Fragments(video={start time})"><c t="0" d="20" r="2"/><c/></StreamIndex>`+
+		`</SmoothStreamingMedia>`), &m); err != nil {
+		t.Fatal(err)
+	}
+	stream, track := m.Streams[0], &Track{Bitrate: 1000}
+	want := []struct {
+		fragment TimelineFragment
+		path     string
+	}{
+		{TimelineFragment{0, 0, 20}, "/movie.ism/QualityLevels(1000)/Fragments(video=0)"},
+		{TimelineFragment{1, 20, 20}, "/movie.ism/QualityLevels(1000)/Fragments(video=20)"},
+		{TimelineFragment{2, 40, 10}, "/movie.ism/QualityLevels(1000)/Fragments(video=40)"},
+	}
+	requests, err := m.ChunkURLs(base, stream, track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(requests), len(want))
+	}
+	for i, r := range requests {
+		if r.Fragment != want[i].fragment || r.URL.Path != want[i].path {
+			t.Errorf("request %d = %v %s, want %v %s", i, r.Fragment, r.URL.Path, want[i].fragment, want[i].path)
+		}
+	}
+	// the stream alone does not carry the end of its last fragment
+	if _, err = ChunkURLs(base, stream, track); !errors.Is(err, ErrInvalidTimeline) {
+		t.Errorf("ChunkURLs() error = %v, want %v", err, ErrInvalidTimeline)
+	}
+	if _, err = ChunkURLs(base, nil, track); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ChunkURLs() of no stream error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestCustomAttributesValue(t *testing.T) {
+	a := &CustomAttributes{Attributes: []*Attribute{{Name: "Codecs", Value: "vp09.00.10.08"}}}
+	if value, ok := a.Value("codecs"); !ok || value != "vp09.00.10.08" {
+		t.Errorf("Value(codecs) = %q, %v, want vp09.00.10.08, true", value, ok)
+	}
+	if value, ok := a.Value("profile"); ok {
+		t.Errorf("Value(profile) = %q, %v, want \"\", false", value, ok)
+	}
+	var empty *CustomAttributes
+	if _, ok := empty.Value("codecs"); ok {
+		t.Error("Value() of nil attributes is found")
+	}
+}
@@ -0,0 +1,98 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// SparseStreamLink ties a sparse stream to the non-sparse stream, named by its
+// ParentStreamIndex field, that transmits its timing information.
+type SparseStreamLink struct {
+	Sparse          *StreamIndex
+	SparseTimeScale uint64
+	Parent          *StreamIndex
+	ParentTimeScale uint64
+
+	// the presentation of both streams.
+	Media *SmoothStreamingMedia
+}
+
+// IsSparse reports whether the stream is a sparse stream.
+func (s *StreamIndex) IsSparse() bool {
+	return s.ParentStreamIndex != nil && *s.ParentStreamIndex != ""
+}
+
+// ParentStream looks up the non-sparse stream named by the ParentStreamIndex
+// field of a sparse stream.
+func (m *SmoothStreamingMedia) ParentStream(s *StreamIndex) (parent *StreamIndex, err error) {
+	if !s.IsSparse() {
+		err = fmt.Errorf("stream is not a sparse stream: %w", ErrInvalidParam)
+		return
+	}
+	for _, stream := range m.Streams {
+		if stream == s || stream.Name == nil || *stream.Name != *s.ParentStreamIndex {
+			continue
+		}
+		if stream.IsSparse() {
+			err = fmt.Errorf("parent stream %s is itself sparse: %w", *s.ParentStreamIndex, ErrInvalidParam)
+			return
+		}
+		parent = stream
+		return
+	}
+	err = fmt.Errorf("parent stream %s not found: %w", *s.ParentStreamIndex, ErrInvalidParam)
+	return
+}
+
+// SparseStreamLink resolves the parent of a sparse stream together with the
+// timescales of both streams.
+func (m *SmoothStreamingMedia) SparseStreamLink(s *StreamIndex) (link SparseStreamLink, err error) {
+	parent, err := m.ParentStream(s)
+	if err != nil {
+		return
+	}
+	link = SparseStreamLink{
+		Sparse:          s,
+		SparseTimeScale: m.StreamTimeScale(s),
+		Parent:          parent,
+		ParentTimeScale: m.StreamTimeScale(parent),
+		Media:           m,
+	}
+	return
+}
+
+// SparseChunkURLs builds the Fragment Request urls of a sparse stream track.
+//
+// The fragments of a sparse stream are requested by their own start times,
+// which are taken from the sparse stream timeline when the manifest lists
+// them. A sparse stream without a timeline, as published by live servers that
+// only signal sparse fragments alongside the parent stream, is probed at every
+// fragment boundary of the parent timeline converted to the sparse timescale.
+func SparseChunkURLs(baseURL *url.URL, link SparseStreamLink, track *Track) (requests []ChunkRequest, err error) {
+	if link.Sparse == nil || link.Parent == nil || link.Media == nil {
+		err = fmt.Errorf("sparse stream link is incomplete: %w", ErrInvalidParam)
+		return
+	}
+	if len(link.Sparse.Fragments) > 0 {
+		return link.Media.ChunkURLs(baseURL, link.Sparse, track)
+	}
+	parentFragments, err := link.Media.StreamTimeline(link.Parent)
+	if err != nil {
+		return
+	}
+	requests = make([]ChunkRequest, 0, len(parentFragments))
+	for _, parentFragment := range parentFragments {
+		fragment := TimelineFragment{
+			Number:   parentFragment.Number,
+			Time:     rescaleTime(parentFragment.Time, link.ParentTimeScale, link.SparseTimeScale),
+			Duration: rescaleTime(parentFragment.Duration, link.ParentTimeScale, link.SparseTimeScale),
+		}
+		var u *url.URL
+		if u, err = BuildChunkURL(baseURL, link.Sparse, track, fragment.Time); err != nil {
+			requests = nil
+			return
+		}
+		requests = append(requests, ChunkRequest{Fragment: fragment, URL: u})
+	}
+	return
+}
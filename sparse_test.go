@@ -0,0 +1,109 @@
+package smoothstreaming
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+const testSparseManifest = `<SmoothStreamingMedia Duration="600000000">` +
+	`<StreamIndex Type="video" Name="video" Url="QualityLevels({bitrate})/Fragments(video={start time})"><c t="0" d="20000000" r="3"/></StreamIndex>` +
+	`<StreamIndex Type="text" Subtype="SCMD" Name="ad" ParentStreamIndex="video" TimeScale="1000" Url="QualityLevels({bitrate})/Fragments(ad={start time})">%s</StreamIndex>` +
+	`</SmoothStreamingMedia>`
+
+func TestSparseChunkURLs(t *testing.T) {
+	base, err := url.Parse("http://example.com/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		fragments string
+		want      []string
+	}{
+		{
+			name:      "sparse timeline",
+			fragments: `<c t="1500"/><c t="30000"/>`,
+			want: []string{
+				"/movie.ism/QualityLevels(0)/Fragments(ad=1500)",
+				"/movie.ism/QualityLevels(0)/Fragments(ad=30000)",
+			},
+		},
+		{
+			name: "parent timeline",
+			want: []string{
+				"/movie.ism/QualityLevels(0)/Fragments(ad=0)",
+				"/movie.ism/QualityLevels(0)/Fragments(ad=2000)",
+				"/movie.ism/QualityLevels(0)/Fragments(ad=4000)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m SmoothStreamingMedia
+			if err := xml.Unmarshal([]byte(fmt.Sprintf(testSparseManifest, tt.fragments)), &m); err != nil {
+				t.Fatal(err)
+			}
+			sparse := m.Streams[1]
+			if !sparse.IsSparse() || m.Streams[0].IsSparse() {
+				t.Fatal("IsSparse() does not tell the sparse stream")
+			}
+			link, err := m.SparseStreamLink(sparse)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if link.Parent != m.Streams[0] || link.SparseTimeScale != 1000 || link.ParentTimeScale != DefaultTimeScale {
+				t.Errorf("SparseStreamLink() = %+v", link)
+			}
+			requests, err := SparseChunkURLs(base, link, &Track{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, r := range requests {
+				got = append(got, r.URL.Path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SparseChunkURLs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParentStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  error
+	}{
+		{
+			name:     "not sparse",
+			manifest: `<SmoothStreamingMedia><StreamIndex Type="video" Name="video"/></SmoothStreamingMedia>`,
+			wantErr:  ErrInvalidParam,
+		},
+		{
+			name:     "parent not found",
+			manifest: `<SmoothStreamingMedia><StreamIndex Type="text" Name="ad" ParentStreamIndex="video"/></SmoothStreamingMedia>`,
+			wantErr:  ErrInvalidParam,
+		},
+		{
+			name:     "sparse parent",
+			manifest: `<SmoothStreamingMedia><StreamIndex Type="text" Name="ad" ParentStreamIndex="video"/><StreamIndex Type="text" Name="video" ParentStreamIndex="ad"/></SmoothStreamingMedia>`,
+			wantErr:  ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m SmoothStreamingMedia
+			if err := xml.Unmarshal([]byte(tt.manifest), &m); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := m.ParentStream(m.Streams[0]); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParentStream() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
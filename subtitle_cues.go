@@ -0,0 +1,352 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubtitleCue is a cue of a subtitle track: its text, displayed from Start
+// until End.
+type SubtitleCue struct {
+	Start time.Duration
+	End   time.Duration
+
+	// the lines of the text, separated by "\n", without markup.
+	Text string
+}
+
+// ParseTTML returns the cues of the p elements of a TTML or DFXP document, in
+// document order, their times offset by offset. The times of the body and div
+// elements apply to the p elements they contain, and the text of a p element
+// is that of its spans, br elements breaking lines, its white space collapsed.
+// The p elements without an end, or whose text is empty, are left out.
+func ParseTTML(doc []byte, offset time.Duration) (cues []SubtitleCue, err error) {
+	type element struct {
+		begin, end time.Duration
+		// whether the end is known
+		ended bool
+	}
+	stack := []element{{begin: offset}}
+	rates := ttmlRates{frameRate: 30, subFrameRate: 1, tickRate: 1}
+	var text *strings.Builder
+	p := 0
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		var token xml.Token
+		if token, err = d.Token(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if token.Name.Local == "tt" && len(stack) == 1 {
+				if err = rates.parse(token.Attr); err != nil {
+					return
+				}
+			}
+			parent := stack[len(stack)-1]
+			e := parent
+			var begin, end, dur time.Duration
+			var hasEnd, hasDur bool
+			for _, attr := range token.Attr {
+				if attr.Name.Space != "" && attr.Name.Space != ttmlNamespace {
+					continue
+				}
+				switch attr.Name.Local {
+				case "begin":
+					begin, err = rates.parseTime(attr.Value)
+				case "end":
+					end, err = rates.parseTime(attr.Value)
+					hasEnd = true
+				case "dur":
+					dur, err = rates.parseTime(attr.Value)
+					hasDur = true
+				}
+				if err != nil {
+					return
+				}
+			}
+			e.begin = parent.begin + begin
+			switch {
+			case hasEnd:
+				e.end, e.ended = parent.begin+end, true
+			case hasDur:
+				e.end, e.ended = e.begin+dur, true
+			}
+			if parent.ended && (!e.ended || e.end > parent.end) {
+				e.end, e.ended = parent.end, true
+			}
+			stack = append(stack, e)
+			switch {
+			case token.Name.Local == "p" && text == nil:
+				text, p = &strings.Builder{}, len(stack)
+			case token.Name.Local == "br" && text != nil:
+				text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if text != nil {
+				// line breaks in the source are white space, only br elements
+				// breaking lines
+				text.Write(bytes.ReplaceAll(token, []byte("\n"), []byte(" ")))
+			}
+		case xml.EndElement:
+			if len(stack) == p {
+				e := stack[p-1]
+				if s := collapseTTMLText(text.String()); s != "" && e.ended && e.end > e.begin {
+					cues = append(cues, SubtitleCue{Start: e.begin, End: e.end, Text: s})
+				}
+				text, p = nil, 0
+			}
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+// SubtitleCues returns the cues of the TTML documents of the samples of a
+// fragment of a text track configured by p, see ParseTTML. The times of the
+// documents are taken on the timeline of the presentation, like those of
+// Smooth Streaming; those of a document whose cues all end by the time of its
+// sample, timed from the start of the sample as in ISO/IEC 14496-30, are
+// offset by the time of the sample.
+func (f Fragment) SubtitleCues(p MoovProcessor) (cues []SubtitleCue, err error) {
+	samples, err := f.Samples(p)
+	if err != nil {
+		return
+	}
+	for _, sample := range samples {
+		if len(bytes.TrimSpace(sample.Data)) == 0 {
+			continue
+		}
+		var sampleCues []SubtitleCue
+		if sampleCues, err = ParseTTML(sample.Data, 0); err != nil {
+			err = fmt.Errorf("sample at %d: %w", sample.DecodeTime, err)
+			return
+		}
+		start := mediaDuration(sample.DecodeTime, p.Timescale)
+		relative := start > 0 && len(sampleCues) > 0
+		for _, cue := range sampleCues {
+			relative = relative && cue.End <= start
+		}
+		for _, cue := range sampleCues {
+			if relative {
+				cue.Start += start
+				cue.End += start
+			}
+			cues = append(cues, cue)
+		}
+	}
+	return
+}
+
+// MergeSubtitleCues merges the cues of successive documents, e.g. of the
+// fragments of a track, sorting them by their start: a cue carried over from
+// a document to the next, repeated with the same text over or right after
+// its previous time, is merged into one cue spanning both.
+func MergeSubtitleCues(cues []SubtitleCue) (merged []SubtitleCue) {
+	sorted := append([]SubtitleCue(nil), cues...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	for _, cue := range sorted {
+		if i := carriedCue(merged, cue); i >= 0 {
+			if cue.End > merged[i].End {
+				merged[i].End = cue.End
+			}
+			continue
+		}
+		merged = append(merged, cue)
+	}
+	return
+}
+
+// carriedCue returns the index of the cue of cues that cue carries over, -1
+// if none.
+func carriedCue(cues []SubtitleCue, cue SubtitleCue) int {
+	for i := len(cues) - 1; i >= 0; i-- {
+		if c := cues[i]; c.Text == cue.Text && c.Start <= cue.End && cue.Start <= c.End {
+			return i
+		}
+	}
+	return -1
+}
+
+// WriteWebVTT writes the cues to w as a WebVTT file.
+func WriteWebVTT(w io.Writer, cues []SubtitleCue) error {
+	return writeSubtitleCues(w, SubtitleWebVTT, cues)
+}
+
+// WriteSRT writes the cues to w as a SubRip file.
+func WriteSRT(w io.Writer, cues []SubtitleCue) error {
+	return writeSubtitleCues(w, SubtitleSRT, cues)
+}
+
+// writeSubtitleCues writes the cues to w in the format f, SubtitleWebVTT or
+// SubtitleSRT.
+func writeSubtitleCues(w io.Writer, f SubtitleFormat, cues []SubtitleCue) (err error) {
+	if _, err = w.Write(f.header()); err != nil {
+		return
+	}
+	for i, cue := range cues {
+		block, _ := f.cue(i+1, cue)
+		if _, err = w.Write(block); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// header returns the header of the files of the cue format f.
+func (f SubtitleFormat) header() []byte {
+	if f == SubtitleWebVTT {
+		return []byte("WEBVTT\n\n")
+	}
+	return nil
+}
+
+// cue returns the block of the cue numbered index, from 1, in the cue format
+// f, and the offset of its end time in the block.
+func (f SubtitleFormat) cue(index int, cue SubtitleCue) (block []byte, end int) {
+	var b bytes.Buffer
+	sep := byte(',')
+	text := cue.Text
+	if f == SubtitleWebVTT {
+		sep = '.'
+		text = webVTTEscaper.Replace(text)
+	} else {
+		fmt.Fprintf(&b, "%d\n", index)
+	}
+	b.WriteString(formatCueTime(cue.Start, sep))
+	b.WriteString(" --> ")
+	end = b.Len()
+	fmt.Fprintf(&b, "%s\n%s\n\n", formatCueTime(cue.End, sep), text)
+	return b.Bytes(), end
+}
+
+// webVTTEscaper escapes the text of a WebVTT cue.
+var webVTTEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// formatCueTime formats a time of a cue as hh:mm:ss followed by sep and the
+// milliseconds.
+func formatCueTime(t time.Duration, sep byte) string {
+	if t < 0 {
+		t = 0
+	}
+	ms := t.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// collapseTTMLText collapses the white space of the text of a p element, as
+// by the default xml:space, dropping the empty lines.
+func collapseTTMLText(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ttmlNamespace is the namespace of TTML, that of its unprefixed timing
+// attributes being empty.
+const ttmlNamespace = "http://www.w3.org/ns/ttml"
+
+// ttmlRates are the frame and tick rates of a TTML document, for its time
+// expressions in frames and ticks.
+type ttmlRates struct {
+	frameRate    float64
+	subFrameRate float64
+	tickRate     float64
+}
+
+// parse parses the ttp:frameRate, ttp:frameRateMultiplier, ttp:subFrameRate
+// and ttp:tickRate attributes of the root of a document.
+func (r *ttmlRates) parse(attrs []xml.Attr) (err error) {
+	multiplier, tickRate := 1.0, 0.0
+	framed := false
+	for _, attr := range attrs {
+		value := strings.TrimSpace(attr.Value)
+		switch attr.Name.Local {
+		case "frameRate":
+			r.frameRate, err = strconv.ParseFloat(value, 64)
+			framed = true
+		case "subFrameRate":
+			r.subFrameRate, err = strconv.ParseFloat(value, 64)
+		case "tickRate":
+			tickRate, err = strconv.ParseFloat(value, 64)
+		case "frameRateMultiplier":
+			var num, den float64
+			if _, err = fmt.Sscanf(value, "%g %g", &num, &den); err == nil && den != 0 {
+				multiplier = num / den
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("ttp:%s %q: %w", attr.Name.Local, attr.Value, ErrInvalidParam)
+			return
+		}
+	}
+	r.frameRate *= multiplier
+	// the tick rate defaults to the sub-frame rate if a frame rate is set
+	switch {
+	case tickRate > 0:
+		r.tickRate = tickRate
+	case framed:
+		r.tickRate = r.frameRate * r.subFrameRate
+	}
+	return
+}
+
+// ttmlClockTime and ttmlOffsetTime match the time expressions of TTML:
+// hh:mm:ss with a fraction or frames, and a count of a metric.
+var (
+	ttmlClockTime  = regexp.MustCompile(`^(\d+):(\d{2}):(\d{2}(?:\.\d+)?)(?::(\d+(?:\.\d+)?))?$`)
+	ttmlOffsetTime = regexp.MustCompile(`^(\d+(?:\.\d+)?)(h|ms|m|s|f|t)$`)
+)
+
+// parseTime parses a TTML time expression.
+func (r ttmlRates) parseTime(s string) (t time.Duration, err error) {
+	s = strings.TrimSpace(s)
+	var seconds float64
+	if m := ttmlClockTime.FindStringSubmatch(s); m != nil {
+		hours, _ := strconv.ParseFloat(m[1], 64)
+		minutes, _ := strconv.ParseFloat(m[2], 64)
+		seconds, _ = strconv.ParseFloat(m[3], 64)
+		seconds += hours*3600 + minutes*60
+		if m[4] != "" {
+			frames, _ := strconv.ParseFloat(m[4], 64)
+			seconds += frames / r.frameRate
+		}
+	} else if m := ttmlOffsetTime.FindStringSubmatch(s); m != nil {
+		count, _ := strconv.ParseFloat(m[1], 64)
+		switch m[2] {
+		case "h":
+			seconds = count * 3600
+		case "m":
+			seconds = count * 60
+		case "s":
+			seconds = count
+		case "ms":
+			seconds = count / 1000
+		case "f":
+			seconds = count / r.frameRate
+		case "t":
+			seconds = count / r.tickRate
+		}
+	} else {
+		err = fmt.Errorf("TTML time %q: %w", s, ErrInvalidParam)
+		return
+	}
+	t = time.Duration(seconds*float64(time.Second) + 0.5)
+	return
+}
@@ -0,0 +1,192 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		offset  time.Duration
+		want    []SubtitleCue
+		wantErr error
+	}{
+		{
+			name: "clock times",
+			doc:  `<tt xmlns="http://www.w3.org/ns/ttml"><body><div><p begin="00:00:01.000" end="00:00:02.500">Hello</p></div></body></tt>`,
+			want: []SubtitleCue{{Start: time.Second, End: 2500 * time.Millisecond, Text: "Hello"}},
+		},
+		{
+			name:   "offset and dur",
+			doc:    `<tt xmlns="http://www.w3.org/ns/ttml"><body><div begin="10s"><p begin="1s" dur="500ms">Hello</p></div></body></tt>`,
+			offset: time.Minute,
+			want:   []SubtitleCue{{Start: 71 * time.Second, End: 71500 * time.Millisecond, Text: "Hello"}},
+		},
+		{
+			name: "spans and line breaks",
+			doc: `<tt xmlns="http://www.w3.org/ns/ttml"><body><div><p begin="0s" end="1s">` + "\n" +
+				`  <span>first</span>   line<br/><span>second</span>` + "\n" + `line</p></div></body></tt>`,
+			want: []SubtitleCue{{Start: 0, End: time.Second, Text: "first line\nsecond line"}},
+		},
+		{
+			name: "ticks",
+			doc:  `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:tickRate="10000000"><body><p begin="10000000t" end="25000000t">Hi</p></body></tt>`,
+			want: []SubtitleCue{{Start: time.Second, End: 2500 * time.Millisecond, Text: "Hi"}},
+		},
+		{
+			name: "frames",
+			doc:  `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:frameRate="25"><body><p begin="00:00:01:05" end="50f">Hi</p></body></tt>`,
+			want: []SubtitleCue{{Start: 1200 * time.Millisecond, End: 2 * time.Second, Text: "Hi"}},
+		},
+		{
+			name: "end of the parent",
+			doc:  `<tt xmlns="http://www.w3.org/ns/ttml"><body><div begin="0s" end="3s"><p begin="1s">Hi</p><p begin="2s" end="5s">Bye</p></div></body></tt>`,
+			want: []SubtitleCue{{Start: time.Second, End: 3 * time.Second, Text: "Hi"}, {Start: 2 * time.Second, End: 3 * time.Second, Text: "Bye"}},
+		},
+		{
+			name: "without end or text",
+			doc:  `<tt xmlns="http://www.w3.org/ns/ttml"><body><div><p begin="1s">Hi</p><p begin="1s" end="2s">  </p></div></body></tt>`,
+		},
+		{
+			name:    "invalid time",
+			doc:     `<tt xmlns="http://www.w3.org/ns/ttml"><body><div><p begin="soon" end="2s">Hi</p></div></body></tt>`,
+			wantErr: ErrInvalidParam,
+		},
+		{
+			name:    "invalid tick rate",
+			doc:     `<tt xmlns="http://www.w3.org/ns/ttml" xmlns:ttp="http://www.w3.org/ns/ttml#parameter" ttp:tickRate="fast"></tt>`,
+			wantErr: ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTTML([]byte(tt.doc), tt.offset)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseTTML() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseTTML() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("cue %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFragmentSubtitleCues(t *testing.T) {
+	p := MoovProcessor{TrackID: 1, Timescale: 10000000, StreamType: TextStream, Codec: StppFourCC}
+	tests := []struct {
+		name  string
+		begin string
+		end   string
+		want  SubtitleCue
+	}{
+		// the times of Smooth Streaming documents are on the timeline
+		{name: "presentation times", begin: "00:00:21", end: "00:00:22", want: SubtitleCue{Start: 21 * time.Second, End: 22 * time.Second, Text: "Hi"}},
+		// those of documents ending by the time of their sample are relative
+		{name: "sample times", begin: "00:00:01", end: "00:00:02", want: SubtitleCue{Start: 21 * time.Second, End: 22 * time.Second, Text: "Hi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := `<tt xmlns="http://www.w3.org/ns/ttml"><body><div><p begin="` + tt.begin + `" end="` + tt.end + `">Hi</p></div></body></tt>`
+			f, err := ParseFragment(bytes.NewReader(withTfxd(t, testTTMLFragment(t, doc), 200000000)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cues, err := f.SubtitleCues(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cues) != 1 || cues[0] != tt.want {
+				t.Errorf("SubtitleCues() = %+v, want %+v", cues, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeSubtitleCues(t *testing.T) {
+	cues := []SubtitleCue{
+		{Start: 2 * time.Second, End: 4 * time.Second, Text: "carried"},
+		{Start: 0, End: 2 * time.Second, Text: "carried"},
+		{Start: time.Second, End: 3 * time.Second, Text: "other"},
+		{Start: 5 * time.Second, End: 6 * time.Second, Text: "carried"},
+	}
+	want := []SubtitleCue{
+		{Start: 0, End: 4 * time.Second, Text: "carried"},
+		{Start: time.Second, End: 3 * time.Second, Text: "other"},
+		{Start: 5 * time.Second, End: 6 * time.Second, Text: "carried"},
+	}
+	got := MergeSubtitleCues(cues)
+	if len(got) != len(want) {
+		t.Fatalf("MergeSubtitleCues() = %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if cues[0].Start != 2*time.Second {
+		t.Error("MergeSubtitleCues() sorted the cues in place")
+	}
+}
+
+func TestWriteSubtitleCues(t *testing.T) {
+	cues := []SubtitleCue{
+		{Start: 1500 * time.Millisecond, End: 3 * time.Second, Text: "Tom & Jerry"},
+		{Start: time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond, End: time.Hour + 2*time.Minute + 5*time.Second, Text: "<two>\nlines"},
+	}
+	tests := []struct {
+		name  string
+		write func(w *bytes.Buffer) error
+		want  string
+	}{
+		{
+			name:  "WebVTT",
+			write: func(w *bytes.Buffer) error { return WriteWebVTT(w, cues) },
+			want: "WEBVTT\n\n" +
+				"00:00:01.500 --> 00:00:03.000\nTom &amp; Jerry\n\n" +
+				"01:02:03.004 --> 01:02:05.000\n&lt;two&gt;\nlines\n\n",
+		},
+		{
+			name:  "SRT",
+			write: func(w *bytes.Buffer) error { return WriteSRT(w, cues) },
+			want: "1\n00:00:01,500 --> 00:00:03,000\nTom & Jerry\n\n" +
+				"2\n01:02:03,004 --> 01:02:05,000\n<two>\nlines\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.write(&b); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("wrote %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCueTime(t *testing.T) {
+	tests := []struct {
+		t    time.Duration
+		sep  byte
+		want string
+	}{
+		{0, '.', "00:00:00.000"},
+		{-time.Second, ',', "00:00:00,000"},
+		{100*time.Hour + 59*time.Minute + 59*time.Second + 999*time.Millisecond, ',', "100:59:59,999"},
+	}
+	for _, tt := range tests {
+		if got := formatCueTime(tt.t, tt.sep); got != tt.want {
+			t.Errorf("formatCueTime(%v) = %s, want %s", tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package smoothstreaming
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// SubtitleFormat is the format of the output of the subtitle tracks written
+// by a Downloader: the tracks of the text streams that are not timed
+// metadata, see StreamIndex.IsTimedMetadata.
+type SubtitleFormat int
+
+const (
+	// SubtitleMP4 writes the subtitle tracks in the Layout, like the other
+	// tracks.
+	SubtitleMP4 SubtitleFormat = iota
+
+	// SubtitleTTML writes each subtitle track to a TTML sidecar file, with
+	// the extension .ttml instead of .mp4, merging the TTML documents of its
+	// samples into one: the document of the first sample, its body holding
+	// the contents of the bodies of all documents in turn. The times of the
+	// documents are kept, Smooth Streaming documents being timed on the
+	// timeline of the presentation.
+	SubtitleTTML
+
+	// SubtitleWebVTT writes each subtitle track to a WebVTT sidecar file,
+	// with the extension .vtt, converting the cues of the TTML documents of
+	// its fragments, see Fragment.SubtitleCues, the cues carried over from a
+	// fragment to the next being merged as by MergeSubtitleCues.
+	SubtitleWebVTT
+
+	// SubtitleSRT writes each subtitle track to a SubRip sidecar file, with
+	// the extension .srt, converting the cues like SubtitleWebVTT.
+	SubtitleSRT
+)
+
+// ext returns the extension of the sidecar files of the format.
+func (f SubtitleFormat) ext() string {
+	switch f {
+	case SubtitleTTML:
+		return ".ttml"
+	case SubtitleWebVTT:
+		return ".vtt"
+	case SubtitleSRT:
+		return ".srt"
+	default:
+		return ".mp4"
+	}
+}
+
+// isSidecar reports whether the selected track is written to a sidecar file
+// in the Subtitles format rather than in the Layout.
+func (d Downloader) isSidecar(selected SelectedTrack) bool {
+	return d.Subtitles != SubtitleMP4 && d.stream == nil && selected.Stream.Type == TextStream && !selected.Stream.IsTimedMetadata()
+}
+
+// openSidecarTrackWriter opens the sidecar file of a subtitle track in the
+// format f for writing after the data recorded by its progress.
+func openSidecarTrackWriter(f SubtitleFormat, progress *TrackDownloadState) (tw trackWriter, err error) {
+	switch f {
+	case SubtitleTTML:
+		return openSubtitleTrackWriter(progress)
+	case SubtitleWebVTT, SubtitleSRT:
+		return openCueTrackWriter(f, progress)
+	default:
+		err = fmt.Errorf("subtitle format %d: %w", f, ErrInvalidParam)
+		return
+	}
+}
+
+// openSidecarFile opens the sidecar file of a subtitle track for writing
+// after the data recorded by its progress, discarding what follows. A file
+// shorter than recorded is downloaded again from the start.
+func openSidecarFile(progress *TrackDownloadState) (file *os.File, err error) {
+	if err = os.MkdirAll(filepath.Dir(progress.Path), 0777); err != nil {
+		return
+	}
+	if file, err = os.OpenFile(progress.Path, os.O_RDWR|os.O_CREATE, 0666); err != nil {
+		return
+	}
+	var info os.FileInfo
+	if info, err = file.Stat(); err == nil {
+		if info.Size() < progress.Size {
+			*progress = TrackDownloadState{Path: progress.Path}
+		}
+		if err = file.Truncate(progress.Size); err == nil {
+			_, err = file.Seek(progress.Size, io.SeekStart)
+		}
+	}
+	if err != nil {
+		file.Close()
+		file = nil
+	}
+	return
+}
+
+// subtitleTrackWriter writes a subtitle track to a TTML sidecar file. The
+// closing tags of the merged document are written when it is closed, after
+// the data recorded by the progress of the track, so that a resumed download
+// discards them before appending the following documents.
+type subtitleTrackWriter struct {
+	file *os.File
+	w    *bufio.Writer
+
+	// the configuration of the track, for the defaults of its samples.
+	p MoovProcessor
+	// the size of the documents written, without the closing tags.
+	size int64
+	// the closing tags of the merged document, nil until its head is written.
+	tail []byte
+}
+
+// openSubtitleTrackWriter opens the TTML sidecar file of a subtitle track,
+// see openSidecarFile.
+func openSubtitleTrackWriter(progress *TrackDownloadState) (tw *subtitleTrackWriter, err error) {
+	file, err := openSidecarFile(progress)
+	if err != nil {
+		return
+	}
+	tw = &subtitleTrackWriter{file: file, w: bufio.NewWriter(file), size: progress.Size}
+	if tw.size > 0 {
+		// the closing tags follow from the head of the document written
+		var head []byte
+		if head, err = io.ReadAll(io.NewSectionReader(file, 0, tw.size)); err == nil {
+			if loc := ttmlBodyStart.FindSubmatchIndex(head); loc != nil {
+				tw.tail = ttmlTail(head[:loc[1]], head[loc[2]:loc[3]])
+			}
+		}
+	}
+	if err != nil {
+		file.Close()
+		tw = nil
+	}
+	return
+}
+
+func (tw *subtitleTrackWriter) writeInit(p MoovProcessor) (n int64, err error) {
+	tw.p = p
+	return
+}
+
+func (tw *subtitleTrackWriter) writeFragment(f *Fragment) (n int64, err error) {
+	samples, err := f.Samples(tw.p)
+	if err != nil {
+		return
+	}
+	for _, sample := range samples {
+		head, body, ok := splitTTML(sample.Data)
+		if !ok {
+			continue
+		}
+		if tw.size+n == 0 {
+			var written int
+			if written, err = tw.w.Write(head); err != nil {
+				return
+			}
+			n += int64(written)
+			loc := ttmlBodyStart.FindSubmatchIndex(head)
+			tw.tail = ttmlTail(head, head[loc[2]:loc[3]])
+		}
+		var written int
+		if written, err = tw.w.Write(body); err != nil {
+			return
+		}
+		n += int64(written)
+	}
+	tw.size += n
+	err = tw.w.Flush()
+	return
+}
+
+// finalize does nothing, the document being completed when closed.
+func (tw *subtitleTrackWriter) finalize(p MoovProcessor) error {
+	return nil
+}
+
+func (tw *subtitleTrackWriter) Close() (err error) {
+	if tw.tail != nil {
+		if _, err = tw.w.Write(tw.tail); err == nil {
+			err = tw.w.Flush()
+		}
+	}
+	if closeErr := tw.file.Close(); err == nil {
+		err = closeErr
+	}
+	return
+}
+
+// cueTrackWriter writes a subtitle track to a WebVTT or SubRip sidecar file,
+// converting the cues of its fragments. A cue carried over from the previous
+// fragment is merged into the cue written, its end time being rewritten in
+// place; the cues written before a resumed download are not merged into.
+type cueTrackWriter struct {
+	file *os.File
+	w    *bufio.Writer
+
+	// the format of the file, SubtitleWebVTT or SubtitleSRT.
+	format SubtitleFormat
+	// the configuration of the track, for the defaults of its samples.
+	p MoovProcessor
+	// the size of the cues written.
+	size int64
+	// the number of cues written, numbering the SubRip cues.
+	count int
+	// the cues of the previous fragment, and the offsets of their end times
+	// in the file.
+	last []writtenCue
+}
+
+// writtenCue is a cue written by a cueTrackWriter.
+type writtenCue struct {
+	SubtitleCue
+	end int64
+}
+
+// openCueTrackWriter opens the sidecar file of a subtitle track in the format
+// f, SubtitleWebVTT or SubtitleSRT, see openSidecarFile.
+func openCueTrackWriter(f SubtitleFormat, progress *TrackDownloadState) (tw *cueTrackWriter, err error) {
+	file, err := openSidecarFile(progress)
+	if err != nil {
+		return
+	}
+	tw = &cueTrackWriter{file: file, w: bufio.NewWriter(file), format: f, size: progress.Size}
+	if tw.size > 0 {
+		// the SubRip cues are numbered on from those written
+		var written []byte
+		if written, err = io.ReadAll(io.NewSectionReader(file, 0, tw.size)); err != nil {
+			file.Close()
+			tw = nil
+			return
+		}
+		tw.count = bytes.Count(written, []byte(" --> "))
+	}
+	return
+}
+
+func (tw *cueTrackWriter) writeInit(p MoovProcessor) (n int64, err error) {
+	tw.p = p
+	return
+}
+
+func (tw *cueTrackWriter) writeFragment(f *Fragment) (n int64, err error) {
+	cues, err := f.SubtitleCues(tw.p)
+	if err != nil {
+		return
+	}
+	if tw.size == 0 {
+		var written int
+		if written, err = tw.w.Write(tw.format.header()); err != nil {
+			return
+		}
+		n += int64(written)
+	}
+	var last []writtenCue
+	for _, cue := range MergeSubtitleCues(cues) {
+		if i := tw.carried(cue); i >= 0 {
+			c := tw.last[i]
+			if cue.End > c.End {
+				// the end time is rewritten if it keeps its width, the cue
+				// being written again otherwise
+				if err = tw.extend(&c, cue.End); err != nil {
+					return
+				}
+			}
+			if c.End >= cue.End {
+				last = append(last, c)
+				continue
+			}
+		}
+		tw.count++
+		block, end := tw.format.cue(tw.count, cue)
+		last = append(last, writtenCue{SubtitleCue: cue, end: tw.size + n + int64(end)})
+		var written int
+		if written, err = tw.w.Write(block); err != nil {
+			return
+		}
+		n += int64(written)
+	}
+	tw.last = last
+	tw.size += n
+	err = tw.w.Flush()
+	return
+}
+
+// carried returns the index of the cue of the previous fragment that cue
+// carries over, -1 if none.
+func (tw *cueTrackWriter) carried(cue SubtitleCue) int {
+	for i, c := range tw.last {
+		if c.Text == cue.Text && c.Start <= cue.End && cue.Start <= c.End {
+			return i
+		}
+	}
+	return -1
+}
+
+// extend rewrites the end time of the written cue c to end, if it keeps its
+// width.
+func (tw *cueTrackWriter) extend(c *writtenCue, end time.Duration) (err error) {
+	sep := byte(',')
+	if tw.format == SubtitleWebVTT {
+		sep = '.'
+	}
+	old, t := formatCueTime(c.End, sep), formatCueTime(end, sep)
+	if len(t) != len(old) {
+		return
+	}
+	if _, err = tw.file.WriteAt([]byte(t), c.end); err == nil {
+		c.End = end
+	}
+	return
+}
+
+// finalize does nothing, the cues being complete once written.
+func (tw *cueTrackWriter) finalize(p MoovProcessor) error {
+	return nil
+}
+
+func (tw *cueTrackWriter) Close() error {
+	return tw.file.Close()
+}
+
+// ttmlRoot and ttmlBodyStart match the opening tags of the root and of the
+// body of a TTML document, with the optional prefix of their namespace.
+var (
+	ttmlRoot      = regexp.MustCompile(`<((?:[\w.-]+:)?tt)\b`)
+	ttmlBodyStart = regexp.MustCompile(`<((?:[\w.-]+:)?body)\b[^>]*?(/?)>`)
+)
+
+// splitTTML splits a TTML document into its head, up to the opening tag of
+// its body, and the content of its body. ok is false if the document has no
+// body.
+func splitTTML(doc []byte) (head, body []byte, ok bool) {
+	loc := ttmlBodyStart.FindSubmatchIndex(doc)
+	if loc == nil {
+		return
+	}
+	if loc[5] > loc[4] {
+		// an empty body, opened rather than closed in the head
+		head = append(append([]byte(nil), doc[:loc[4]]...), '>')
+		return head, nil, true
+	}
+	head = doc[:loc[1]]
+	name := doc[loc[2]:loc[3]]
+	end := bytes.LastIndex(doc, []byte(fmt.Sprintf("</%s", name)))
+	if end < loc[1] {
+		return
+	}
+	return head, doc[loc[1]:end], true
+}
+
+// ttmlTail returns the closing tags of the body named body and of the root of
+// the TTML document of the given head.
+func ttmlTail(head, body []byte) []byte {
+	root := []byte("tt")
+	if loc := ttmlRoot.FindSubmatchIndex(head); loc != nil {
+		root = head[loc[2]:loc[3]]
+	}
+	return []byte(fmt.Sprintf("</%s></%s>\n", body, root))
+}
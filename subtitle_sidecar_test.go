@@ -0,0 +1,344 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+// testTTMLDocument returns a TTML document of a paragraph of the given text.
+func testTTMLDocument(text string) string {
+	return `<?xml version="1.0" encoding="utf-8"?><tt xmlns="http://www.w3.org/ns/ttml" xml:lang="en">` +
+		`<head><styling/></head><body><div><p>` + text + `</p></div></body></tt>`
+}
+
+// testTTMLFragment returns a fragment of a sample holding the TTML document
+// doc.
+func testTTMLFragment(t *testing.T, doc string) []byte {
+	t.Helper()
+	tfhd := &mp4.TrackFragmentHeaderBox{TrackID: 1}
+	tfhd.Mp4BoxSetFlags(mp4.FLAG_TFHD_DEFAULT_BASE_IS_MOOF)
+	trun := &mp4.TrackRunBox{SampleCount: 1, Samples: []mp4.TrackRunSampleEntry{{SampleDuration: 20000000, SampleSize: uint32(len(doc))}}}
+	trun.Mp4BoxSetFlags(mp4.FLAG_TRUN_DATA_OFFSET | mp4.FLAG_TRUN_SAMPLE_DURATION | mp4.FLAG_TRUN_SAMPLE_SIZE)
+	traf := &mp4.TrackFragmentBox{}
+	if err := traf.Mp4BoxReplaceChildren([]mp4.Box{tfhd, trun}); err != nil {
+		t.Fatal(err)
+	}
+	moof := &mp4.MovieFragmentBox{}
+	if err := moof.Mp4BoxReplaceChildren([]mp4.Box{&mp4.MovieFragmentHeaderBox{SequenceNumber: 1}, traf}); err != nil {
+		t.Fatal(err)
+	}
+	trun.DataOffset = int32(moof.Mp4BoxUpdate() + 8)
+	var buf bytes.Buffer
+	if err := moof.Mp4BoxWrite(&buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write([]byte{0, 0, 0, byte(8 + len(doc)), 'm', 'd', 'a', 't'})
+	buf.WriteString(doc)
+	return buf.Bytes()
+}
+
+func TestSplitTTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		wantHead string
+		wantBody string
+		wantTail string
+		wantOK   bool
+	}{
+		{
+			name:     "document",
+			doc:      `<tt xmlns="http://www.w3.org/ns/ttml"><head/><body style="s1"><div/></body></tt>`,
+			wantHead: `<tt xmlns="http://www.w3.org/ns/ttml"><head/><body style="s1">`,
+			wantBody: `<div/>`,
+			wantTail: "</body></tt>\n",
+			wantOK:   true,
+		},
+		{
+			name:     "namespace prefix",
+			doc:      `<tt:tt xmlns:tt="http://www.w3.org/ns/ttml"><tt:body><tt:div/></tt:body></tt:tt>`,
+			wantHead: `<tt:tt xmlns:tt="http://www.w3.org/ns/ttml"><tt:body>`,
+			wantBody: `<tt:div/>`,
+			wantTail: "</tt:body></tt:tt>\n",
+			wantOK:   true,
+		},
+		{
+			name:     "empty body",
+			doc:      `<tt xmlns="http://www.w3.org/ns/ttml"><body/></tt>`,
+			wantHead: `<tt xmlns="http://www.w3.org/ns/ttml"><body>`,
+			wantTail: "</body></tt>\n",
+			wantOK:   true,
+		},
+		{name: "no body", doc: `<tt xmlns="http://www.w3.org/ns/ttml"><head/></tt>`},
+		{name: "unclosed body", doc: `<tt xmlns="http://www.w3.org/ns/ttml"><body><div/>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, body, ok := splitTTML([]byte(tt.doc))
+			if ok != tt.wantOK {
+				t.Fatalf("splitTTML() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if string(head) != tt.wantHead || string(body) != tt.wantBody {
+				t.Errorf("splitTTML() = %q, %q, want %q, %q", head, body, tt.wantHead, tt.wantBody)
+			}
+			loc := ttmlBodyStart.FindSubmatchIndex(head)
+			if tail := ttmlTail(head, head[loc[2]:loc[3]]); string(tail) != tt.wantTail {
+				t.Errorf("ttmlTail() = %q, want %q", tail, tt.wantTail)
+			}
+		})
+	}
+}
+
+const testSubtitleManifest = `<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+	`<StreamIndex Type="video" Name="video" Chunks="3" QualityLevels="1" Url="QualityLevels({bitrate})/Fragments(video={start time})">` +
+	`<QualityLevel Index="0" Bitrate="1000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+	`<c t="0" d="20000000" r="3"/>` +
+	`</StreamIndex>` +
+	`<StreamIndex Type="text" Name="subtitles" Subtype="SUBT" Chunks="3" QualityLevels="1" Url="QualityLevels({bitrate})/Fragments(subtitles={start time})">` +
+	`<QualityLevel Index="0" Bitrate="1000" FourCC="TTML"/>` +
+	`<c t="0" d="20000000" r="3"/>` +
+	`</StreamIndex></SmoothStreamingMedia>`
+
+func TestDownloaderSubtitleSidecar(t *testing.T) {
+	fragment := testFragment(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch name := path.Base(r.URL.Path); {
+		case name == "Manifest":
+			io.WriteString(w, testSubtitleManifest)
+		case strings.HasPrefix(name, "Fragments(subtitles="):
+			var fragmentTime uint64
+			if _, err := fmt.Sscanf(name, "Fragments(subtitles=%d)", &fragmentTime); err != nil {
+				t.Error(err)
+			}
+			w.Write(testTTMLFragment(t, testTTMLDocument(fmt.Sprint("line ", fragmentTime/20000000))))
+		default:
+			w.Write(fragment)
+		}
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tracks, err := Downloader{OutputDir: dir, Subtitles: SubtitleTTML, Checksums: true}.Download(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the video track is written in the Layout
+	if len(tracks) != 2 || tracks[0].Path != filepath.Join(dir, "video_1000.mp4") || tracks[1].Path != filepath.Join(dir, "subtitles_1000.ttml") {
+		t.Fatalf("tracks %+v, want video_1000.mp4 and subtitles_1000.ttml", tracks)
+	}
+	data, err := os.ReadFile(tracks[1].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="utf-8"?><tt xmlns="http://www.w3.org/ns/ttml" xml:lang="en"><head><styling/></head><body>` +
+		`<div><p>line 0</p></div><div><p>line 1</p></div><div><p>line 2</p></div></body></tt>` + "\n"
+	if string(data) != want {
+		t.Errorf("sidecar file %q, want %q", data, want)
+	}
+
+	// the checksum manifest of a sidecar file lists no fragments
+	m, err := ComputeChecksums(tracks[1].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Size != int64(len(want)) || len(m.Files[0].Fragments) != 0 {
+		t.Errorf("checksums %+v, want the sidecar file without fragments", m)
+	}
+}
+
+func TestSubtitleTrackWriterResume(t *testing.T) {
+	p := MoovProcessor{TrackID: 1, Timescale: 10000000, StreamType: TextStream, Codec: StppFourCC}
+	progress := &TrackDownloadState{Path: filepath.Join(t.TempDir(), "subtitles.ttml")}
+	tw, err := openSubtitleTrackWriter(progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, doc := range []string{testTTMLDocument("first"), testTTMLDocument("second")} {
+		f, err := ParseFragment(bytes.NewReader(testTTMLFragment(t, doc)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			tw.writeInit(p)
+		}
+		n, err := tw.writeFragment(&f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		progress.Size += n
+		if i == 0 {
+			// the download stops after the first document, leaving its tail
+			if err = tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if tw, err = openSubtitleTrackWriter(progress); err != nil {
+				t.Fatal(err)
+			}
+			tw.writeInit(p)
+		}
+	}
+	if err = tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(progress.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="utf-8"?><tt xmlns="http://www.w3.org/ns/ttml" xml:lang="en"><head><styling/></head><body>` +
+		`<div><p>first</p></div><div><p>second</p></div></body></tt>` + "\n"
+	if string(data) != want {
+		t.Errorf("resumed sidecar file %q, want %q", data, want)
+	}
+	if progress.Size != int64(len(want)-len("</body></tt>\n")) {
+		t.Errorf("progress size %d, want the documents without the closing tags", progress.Size)
+	}
+}
+
+// testCueDocument returns the TTML document of the subtitle fragment n of
+// testSubtitleManifest: a cue of its own, following a cue carried over the
+// first two fragments.
+func testCueDocument(n uint64) string {
+	var carried string
+	if n < 2 {
+		carried = fmt.Sprintf(`<p begin="%ds" end="%ds">carried</p>`, 2*n, 2*n+2)
+	}
+	return `<?xml version="1.0" encoding="utf-8"?><tt xmlns="http://www.w3.org/ns/ttml" xml:lang="en"><body><div>` + carried +
+		fmt.Sprintf(`<p begin="%ds" end="%ds">line %d</p>`, 2*n, 2*n+1, n) + `</div></body></tt>`
+}
+
+func TestDownloaderSubtitleCues(t *testing.T) {
+	fragment := testFragment(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch name := path.Base(r.URL.Path); {
+		case name == "Manifest":
+			io.WriteString(w, testSubtitleManifest)
+		case strings.HasPrefix(name, "Fragments(subtitles="):
+			var fragmentTime uint64
+			if _, err := fmt.Sscanf(name, "Fragments(subtitles=%d)", &fragmentTime); err != nil {
+				t.Error(err)
+			}
+			w.Write(testTTMLFragment(t, testCueDocument(fragmentTime/20000000)))
+		default:
+			w.Write(fragment)
+		}
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format SubtitleFormat
+		path   string
+		want   string
+	}{
+		{
+			format: SubtitleWebVTT,
+			path:   "subtitles_1000.vtt",
+			want: "WEBVTT\n\n" +
+				"00:00:00.000 --> 00:00:04.000\ncarried\n\n" +
+				"00:00:00.000 --> 00:00:01.000\nline 0\n\n" +
+				"00:00:02.000 --> 00:00:03.000\nline 1\n\n" +
+				"00:00:04.000 --> 00:00:05.000\nline 2\n\n",
+		},
+		{
+			format: SubtitleSRT,
+			path:   "subtitles_1000.srt",
+			want: "1\n00:00:00,000 --> 00:00:04,000\ncarried\n\n" +
+				"2\n00:00:00,000 --> 00:00:01,000\nline 0\n\n" +
+				"3\n00:00:02,000 --> 00:00:03,000\nline 1\n\n" +
+				"4\n00:00:04,000 --> 00:00:05,000\nline 2\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			dir := t.TempDir()
+			tracks, err := Downloader{OutputDir: dir, Subtitles: tt.format}.Download(context.Background(), u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tracks) != 2 || tracks[1].Path != filepath.Join(dir, tt.path) {
+				t.Fatalf("tracks %+v, want %s", tracks, tt.path)
+			}
+			data, err := os.ReadFile(tracks[1].Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("sidecar file %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestCueTrackWriterResume(t *testing.T) {
+	p := MoovProcessor{TrackID: 1, Timescale: 10000000, StreamType: TextStream, Codec: StppFourCC}
+	progress := &TrackDownloadState{Path: filepath.Join(t.TempDir(), "subtitles.srt")}
+	tw, err := openCueTrackWriter(SubtitleSRT, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(0); i < 2; i++ {
+		f, err := ParseFragment(bytes.NewReader(testTTMLFragment(t, testCueDocument(i))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tw.writeInit(p)
+		n, err := tw.writeFragment(&f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		progress.Size += n
+		if err = tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			// the download stops after the first fragment
+			if tw, err = openCueTrackWriter(SubtitleSRT, progress); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	data, err := os.ReadFile(progress.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the cues written before the download resumed are numbered on, but not
+	// merged into
+	want := "1\n00:00:00,000 --> 00:00:02,000\ncarried\n\n" +
+		"2\n00:00:00,000 --> 00:00:01,000\nline 0\n\n" +
+		"3\n00:00:02,000 --> 00:00:04,000\ncarried\n\n" +
+		"4\n00:00:02,000 --> 00:00:03,000\nline 1\n\n"
+	if string(data) != want {
+		t.Errorf("resumed sidecar file %q, want %q", data, want)
+	}
+	if progress.Size != int64(len(want)) {
+		t.Errorf("progress size %d, want %d", progress.Size, len(want))
+	}
+}
+
+func TestOpenSidecarTrackWriter(t *testing.T) {
+	progress := &TrackDownloadState{Path: filepath.Join(t.TempDir(), "subtitles.mp4")}
+	if _, err := openSidecarTrackWriter(SubtitleMP4, progress); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("openSidecarTrackWriter(SubtitleMP4) error = %v, want %v", err, ErrInvalidParam)
+	}
+}
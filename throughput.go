@@ -0,0 +1,86 @@
+package smoothstreaming
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ThroughputEstimator estimates the throughput of the downloads of a
+// Downloader over its last fragments: the bytes of their responses over the
+// time spent transferring them, the transfers of concurrent requests
+// overlapping. The time spent waiting between the requests, e.g. for the
+// fragments of a live presentation to be published, is not counted.
+type ThroughputEstimator struct {
+	window int
+
+	mu sync.Mutex
+	// the last transfers, at most window of them, the oldest replaced next.
+	transfers []transfer
+	next      int
+}
+
+// transfer is the transfer of the response of a request.
+type transfer struct {
+	bytes      int64
+	start, end time.Time
+}
+
+// NewThroughputEstimator returns a ThroughputEstimator over the given number
+// of fragments, 8 if 0.
+func NewThroughputEstimator(window int) *ThroughputEstimator {
+	if window <= 0 {
+		window = 8
+	}
+	return &ThroughputEstimator{window: window}
+}
+
+// observe records the transfer of bytes from start to end. A nil estimator
+// records nothing.
+func (e *ThroughputEstimator) observe(bytes int64, start, end time.Time) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	t := transfer{bytes: bytes, start: start, end: end}
+	if len(e.transfers) < e.window {
+		e.transfers = append(e.transfers, t)
+		return
+	}
+	e.transfers[e.next] = t
+	e.next = (e.next + 1) % e.window
+}
+
+// Estimate returns the estimated throughput, in bits per second, ok reporting
+// whether any transfer was recorded.
+func (e *ThroughputEstimator) Estimate() (bitsPerSecond int64, ok bool) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	transfers := append([]transfer(nil), e.transfers...)
+	e.mu.Unlock()
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].start.Before(transfers[j].start)
+	})
+	// the union of the transfer intervals
+	var bytes int64
+	var busy time.Duration
+	var end time.Time
+	for _, t := range transfers {
+		bytes += t.bytes
+		start := t.start
+		if start.Before(end) {
+			start = end
+		}
+		if t.end.After(start) {
+			busy += t.end.Sub(start)
+			end = t.end
+		}
+	}
+	if busy <= 0 {
+		return
+	}
+	return int64(float64(bytes*8) / busy.Seconds()), true
+}
@@ -0,0 +1,93 @@
+package smoothstreaming
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThroughputEstimatorEstimate(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+	type observation struct {
+		bytes      int64
+		start, end int
+	}
+	tests := []struct {
+		name         string
+		window       int
+		observations []observation
+		want         int64
+		wantOK       bool
+	}{
+		{name: "none"},
+		{name: "one transfer", observations: []observation{{125000, 0, 1000}}, want: 1000000, wantOK: true},
+		// the wait between the transfers is not counted
+		{name: "disjoint transfers", observations: []observation{{125000, 0, 500}, {125000, 2000, 2500}}, want: 2000000, wantOK: true},
+		{name: "overlapping transfers", observations: []observation{{125000, 0, 1000}, {125000, 500, 1000}}, want: 2000000, wantOK: true},
+		{name: "nested transfers", observations: []observation{{125000, 0, 1000}, {125000, 200, 400}}, want: 2000000, wantOK: true},
+		{name: "unordered transfers", observations: []observation{{125000, 2000, 2500}, {125000, 0, 500}}, want: 2000000, wantOK: true},
+		{name: "instantaneous transfer", observations: []observation{{125000, 0, 0}}},
+		// the oldest transfer is replaced
+		{name: "window", window: 2, observations: []observation{{1, 0, 1000}, {125000, 1000, 1500}, {125000, 2000, 2500}}, want: 2000000, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewThroughputEstimator(tt.window)
+			for _, o := range tt.observations {
+				e.observe(o.bytes, at(o.start), at(o.end))
+			}
+			if got, ok := e.Estimate(); got != tt.want || ok != tt.wantOK {
+				t.Errorf("Estimate() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestThroughputEstimatorNil(t *testing.T) {
+	var e *ThroughputEstimator
+	e.observe(1000, time.Now(), time.Now().Add(time.Second))
+	if got, ok := e.Estimate(); got != 0 || ok {
+		t.Errorf("Estimate() of a nil estimator = %d, %v, want 0, false", got, ok)
+	}
+	if e = NewThroughputEstimator(0); e.window != 8 {
+		t.Errorf("default window %d, want 8", e.window)
+	}
+}
+
+func TestDownloaderThroughput(t *testing.T) {
+	fragment := testFragment(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/Manifest") {
+			io.WriteString(w, testManifest)
+			return
+		}
+		w.Write(fragment)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewThroughputEstimator(0)
+	d := Downloader{OutputDir: t.TempDir(), Throughput: e}
+	if _, err = d.Download(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	// the three fragments are observed, not the manifest
+	if len(e.transfers) != 3 {
+		t.Fatalf("observed %d transfers, want 3", len(e.transfers))
+	}
+	for i, transfer := range e.transfers {
+		if transfer.bytes != int64(len(fragment)) || transfer.end.Before(transfer.start) {
+			t.Errorf("transfer %d = %+v, want %d bytes", i, transfer, len(fragment))
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// TimelineFragment is a single fragment of a stream with the implicit
+// FragmentTime and FragmentDuration fields resolved and repeated fragments
+// expanded. Time and Duration are expressed in the timescale of the containing
+// stream.
+type TimelineFragment struct {
+	// The ordinal of the fragment in the stream. Taken from the FragmentNumber
+	// field when present, otherwise counted from 0.
+	Number uint32
+
+	// The start time of the fragment.
+	Time uint64
+
+	// The duration of the fragment. It is 0 if the duration is not known yet,
+	// which only happens for the last fragment of a live presentation whose
+	// last StreamFragmentElement omits the FragmentDuration field.
+	Duration uint64
+}
+
+// End returns the time right after the last sample of the fragment.
+func (f TimelineFragment) End() uint64 {
+	return f.Time + f.Duration
+}
+
+// Timeline resolves the StreamFragmentElement fields of the stream into the
+// complete, ordered list of fragments. Both start-time coding and duration
+// coding are supported, as well as the repeat count of version 2.2 manifests.
+//
+// A last fragment without FragmentDuration lasts until the end of the
+// presentation, which the stream does not carry. Timeline returns
+// ErrInvalidTimeline for such a fragment, see
+// SmoothStreamingMedia.StreamTimeline.
+func (s *StreamIndex) Timeline() (fragments []TimelineFragment, err error) {
+	return s.timeline(0, false)
+}
+
+// StreamTimeline resolves the fragment timeline of a stream of the
+// presentation like StreamIndex.Timeline. A last fragment without
+// FragmentDuration lasts until the end of the presentation given by the
+// Duration field. If the Duration field is 0, the duration of such a fragment
+// is 0 in live presentations, where it is not known until a later manifest
+// lists the following fragment, and ErrInvalidTimeline is returned in
+// on-demand presentations.
+func (m *SmoothStreamingMedia) StreamTimeline(s *StreamIndex) (fragments []TimelineFragment, err error) {
+	end := rescaleTime(m.Duration, m.PresentationTimeScale(), m.StreamTimeScale(s))
+	return s.timeline(end, m.IsLive != nil && *m.IsLive)
+}
+
+// timeline resolves the fragments of the stream. The duration of a last
+// fragment without FragmentDuration is taken from end, the end of the
+// presentation in the stream timescale, unless end is 0.
+func (s *StreamIndex) timeline(end uint64, live bool) (fragments []TimelineFragment, err error) {
+	var number uint32
+	var next uint64
+	for i, c := range s.Fragments {
+		time := next
+		if c.Time != nil {
+			time = *c.Time
+		}
+		if i > 0 && time < next {
+			err = fmt.Errorf("fragment %d at time %d overlaps preceding fragment ending at %d: %w", i, time, next, ErrInvalidTimeline)
+			return
+		}
+
+		var duration uint64
+		switch {
+		case c.Duration != nil:
+			duration = *c.Duration
+		case i+1 < len(s.Fragments):
+			following := s.Fragments[i+1]
+			if following.Time == nil {
+				err = fmt.Errorf("fragment %d has neither an explicit duration nor a subsequent start time: %w", i, ErrInvalidTimeline)
+				return
+			}
+			if *following.Time < time {
+				err = fmt.Errorf("fragment %d starts after subsequent fragment: %w", i, ErrInvalidTimeline)
+				return
+			}
+			duration = *following.Time - time
+		case end > 0:
+			if end <= time {
+				err = fmt.Errorf("last fragment at time %d starts after the end of the presentation at %d: %w", time, end, ErrInvalidTimeline)
+				return
+			}
+			duration = end - time
+		case !live:
+			err = fmt.Errorf("last fragment has no explicit duration and the presentation duration is unknown: %w", ErrInvalidTimeline)
+			return
+		}
+
+		if c.Number != nil {
+			number = *c.Number
+		}
+
+		repeat := uint64(1)
+		if c.Repeat != nil && *c.Repeat > 1 {
+			repeat = *c.Repeat
+		}
+		for r := uint64(0); r < repeat; r++ {
+			fragments = append(fragments, TimelineFragment{
+				Number:   number,
+				Time:     time,
+				Duration: duration,
+			})
+			number++
+			time += duration
+		}
+		next = time
+	}
+	return
+}
+
+// DefaultTimeScale is the timescale of a presentation whose manifest omits the
+// TimeScale field.
+const DefaultTimeScale uint64 = 10000000
+
+// PresentationTimeScale returns the timescale of the Duration field.
+func (m *SmoothStreamingMedia) PresentationTimeScale() uint64 {
+	if m.TimeScale != nil && *m.TimeScale > 0 {
+		return *m.TimeScale
+	}
+	return DefaultTimeScale
+}
+
+// StreamTimeScale returns the timescale of the time and duration values of
+// the given stream, falling back to the presentation timescale.
+func (m *SmoothStreamingMedia) StreamTimeScale(s *StreamIndex) uint64 {
+	if s.TimeScale != nil && *s.TimeScale > 0 {
+		return *s.TimeScale
+	}
+	return m.PresentationTimeScale()
+}
+
+// rescaleTime converts t from timescale from to timescale to, rounding down.
+// The intermediate product is computed on 128 bits so that 10 MHz timestamps
+// of long running live presentations do not overflow.
+func rescaleTime(t, from, to uint64) uint64 {
+	if from == to || from == 0 {
+		return t
+	}
+	hi, lo := bits.Mul64(t, to)
+	if hi >= from {
+		return math.MaxUint64
+	}
+	q, _ := bits.Div64(hi, lo, from)
+	return q
+}
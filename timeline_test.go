@@ -0,0 +1,128 @@
+package smoothstreaming
+
+import (
+	"encoding/xml"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStreamIndexTimeline(t *testing.T) {
+	tests := []struct {
+		name      string
+		fragments string
+		want      []TimelineFragment
+		wantErr   error
+	}{
+		{
+			name:      "durations",
+			fragments: `<c d="20"/><c d="20"/><c d="10"/>`,
+			want:      []TimelineFragment{{0, 0, 20}, {1, 20, 20}, {2, 40, 10}},
+		},
+		{
+			name:      "implicit durations",
+			fragments: `<c t="100"/><c t="120"/><c t="150" d="10"/>`,
+			want:      []TimelineFragment{{0, 100, 20}, {1, 120, 30}, {2, 150, 10}},
+		},
+		{
+			name:      "repeat",
+			fragments: `<c t="10" d="20" r="3"/><c d="5"/>`,
+			want:      []TimelineFragment{{0, 10, 20}, {1, 30, 20}, {2, 50, 20}, {3, 70, 5}},
+		},
+		{
+			name:      "fragment numbers",
+			fragments: `<c n="5" d="10"/><c d="10"/><c n="9" d="10"/>`,
+			want:      []TimelineFragment{{5, 0, 10}, {6, 10, 10}, {9, 20, 10}},
+		},
+		{
+			name:      "gap",
+			fragments: `<c t="0" d="10"/><c t="30" d="10"/>`,
+			want:      []TimelineFragment{{0, 0, 10}, {1, 30, 10}},
+		},
+		{
+			name:      "overlap",
+			fragments: `<c t="0" d="20"/><c t="10" d="10"/>`,
+			wantErr:   ErrInvalidTimeline,
+		},
+		{
+			name:      "last fragment without duration",
+			fragments: `<c t="100"/><c t="120"/>`,
+			wantErr:   ErrInvalidTimeline,
+		},
+		{
+			name:      "neither duration nor subsequent time",
+			fragments: `<c t="0"/><c d="10"/>`,
+			wantErr:   ErrInvalidTimeline,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s StreamIndex
+			if err := xml.Unmarshal([]byte(`<StreamIndex Type="video">`+tt.fragments+`</StreamIndex>`), &s); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Timeline()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Timeline() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Timeline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamTimeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		media   string
+		want    []TimelineFragment
+		wantErr error
+	}{
+		{
+			name:  "single fragment without duration",
+			media: `<SmoothStreamingMedia Duration="600"><StreamIndex Type="video"><c t="0"/></StreamIndex></SmoothStreamingMedia>`,
+			want:  []TimelineFragment{{0, 0, 600}},
+		},
+		{
+			name:  "last fragment without duration",
+			media: `<SmoothStreamingMedia Duration="600"><StreamIndex Type="video"><c t="100"/><c t="300"/></StreamIndex></SmoothStreamingMedia>`,
+			want:  []TimelineFragment{{0, 100, 200}, {1, 300, 300}},
+		},
+		{
+			name:  "stream timescale",
+			media: `<SmoothStreamingMedia Duration="60" TimeScale="10"><StreamIndex Type="audio" TimeScale="48000"><c t="0" d="144000"/><c/></StreamIndex></SmoothStreamingMedia>`,
+			want:  []TimelineFragment{{0, 0, 144000}, {1, 144000, 144000}},
+		},
+		{
+			name:  "live presentation of unknown duration",
+			media: `<SmoothStreamingMedia Duration="0" IsLive="TRUE"><StreamIndex Type="video"><c t="100" d="20"/><c/></StreamIndex></SmoothStreamingMedia>`,
+			want:  []TimelineFragment{{0, 100, 20}, {1, 120, 0}},
+		},
+		{
+			name:    "on-demand presentation of unknown duration",
+			media:   `<SmoothStreamingMedia Duration="0"><StreamIndex Type="video"><c t="0"/></StreamIndex></SmoothStreamingMedia>`,
+			wantErr: ErrInvalidTimeline,
+		},
+		{
+			name:    "last fragment after the end of the presentation",
+			media:   `<SmoothStreamingMedia Duration="600"><StreamIndex Type="video"><c t="0" d="600"/><c/></StreamIndex></SmoothStreamingMedia>`,
+			wantErr: ErrInvalidTimeline,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m SmoothStreamingMedia
+			if err := xml.Unmarshal([]byte(tt.media), &m); err != nil {
+				t.Fatal(err)
+			}
+			got, err := m.StreamTimeline(m.Streams[0])
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StreamTimeline() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StreamTimeline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
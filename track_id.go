@@ -0,0 +1,114 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/go-webdl/mp4"
+)
+
+// AssignTrackIDs gives the tracks of a presentation distinct, non-zero track
+// IDs, whether they are written to one multi-track init segment or to an init
+// segment per track. Track IDs that are already distinct are kept, tracks with
+// ID 0 or the ID of a preceding track get the lowest unused IDs in order. The
+// NextTrackID of every track is set past the highest ID, so that every init
+// segment declares the IDs of the whole presentation.
+//
+// The fragments of a track whose ID changed must be rewritten to the new ID
+// with SetFragmentTrackID.
+func AssignTrackIDs(tracks []MoovProcessor) {
+	used := make(map[uint32]bool, len(tracks))
+	reassign := make([]bool, len(tracks))
+	for i, track := range tracks {
+		if track.TrackID == 0 || used[track.TrackID] {
+			reassign[i] = true
+			continue
+		}
+		used[track.TrackID] = true
+	}
+	trackID := uint32(1)
+	for i := range tracks {
+		if !reassign[i] {
+			continue
+		}
+		for used[trackID] {
+			trackID++
+		}
+		tracks[i].TrackID = trackID
+		used[trackID] = true
+	}
+	var nextTrackID uint32
+	for _, track := range tracks {
+		if track.TrackID >= nextTrackID {
+			nextTrackID = track.TrackID + 1
+		}
+	}
+	for i := range tracks {
+		tracks[i].NextTrackID = nextTrackID
+	}
+}
+
+// SetFragmentTrackID rewrites in place the track ID of the tfhd boxes of the
+// fragments in data, one or more moof boxes and their mdat boxes as delivered
+// by a Fragment Request, to match the TrackID of the init segment. Smooth
+// Streaming fragments carry a single track, so every tfhd is rewritten.
+func SetFragmentTrackID(data []byte, trackID uint32) (err error) {
+	return walkMp4Boxes(data, func(boxType mp4.BoxType, payload []byte) error {
+		if boxType != mp4.MoofBoxType {
+			return nil
+		}
+		return walkMp4Boxes(payload, func(boxType mp4.BoxType, payload []byte) error {
+			if boxType != mp4.TrafBoxType {
+				return nil
+			}
+			return walkMp4Boxes(payload, func(boxType mp4.BoxType, payload []byte) error {
+				if boxType != mp4.TfhdBoxType {
+					return nil
+				}
+				if len(payload) < 8 {
+					return fmt.Errorf("tfhd box truncated: %w", ErrInvalidParam)
+				}
+				binary.BigEndian.PutUint32(payload[4:], trackID) // after version and flags
+				return nil
+			})
+		})
+	})
+}
+
+// walkMp4Boxes calls fn with the type and the payload, following the header,
+// of each box in data. The payload aliases data.
+func walkMp4Boxes(data []byte, fn func(boxType mp4.BoxType, payload []byte) error) (err error) {
+	for len(data) > 0 {
+		if len(data) < 8 {
+			err = fmt.Errorf("box header truncated: %w", ErrInvalidParam)
+			return
+		}
+		size := uint64(binary.BigEndian.Uint32(data))
+		var boxType mp4.BoxType
+		copy(boxType[:], data[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 0: // the box extends to the end of the data
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				err = fmt.Errorf("%s box largesize truncated: %w", boxType, ErrInvalidParam)
+				return
+			}
+			size = binary.BigEndian.Uint64(data[8:])
+			headerSize = 16
+		}
+		if boxType == mp4.UuidBoxType {
+			headerSize += 16
+		}
+		if size < headerSize || size > uint64(len(data)) {
+			err = fmt.Errorf("%s box size %d invalid: %w", boxType, size, ErrInvalidParam)
+			return
+		}
+		if err = fn(boxType, data[headerSize:size]); err != nil {
+			return
+		}
+		data = data[size:]
+	}
+	return
+}
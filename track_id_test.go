@@ -0,0 +1,118 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestAssignTrackIDs(t *testing.T) {
+	tests := []struct {
+		name            string
+		ids             []uint32
+		want            []uint32
+		wantNextTrackID uint32
+	}{
+		{name: "distinct IDs kept", ids: []uint32{3, 1, 7}, want: []uint32{3, 1, 7}, wantNextTrackID: 8},
+		{name: "zero IDs", ids: []uint32{0, 0, 0}, want: []uint32{1, 2, 3}, wantNextTrackID: 4},
+		{name: "duplicates get the lowest unused IDs", ids: []uint32{2, 2, 0, 1}, want: []uint32{2, 3, 4, 1}, wantNextTrackID: 5},
+		{name: "Smooth Streaming default IDs", ids: []uint32{1, 1, 1}, want: []uint32{1, 2, 3}, wantNextTrackID: 4},
+		{name: "empty", ids: nil, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks := make([]MoovProcessor, len(tt.ids))
+			for i, id := range tt.ids {
+				tracks[i].TrackID = id
+			}
+			AssignTrackIDs(tracks)
+			var got []uint32
+			for _, track := range tracks {
+				got = append(got, track.TrackID)
+				if track.NextTrackID != tt.wantNextTrackID {
+					t.Errorf("track %d NextTrackID = %d, want %d", track.TrackID, track.NextTrackID, tt.wantNextTrackID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("track IDs %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testTrafFragment returns a fragment of a moof with a traf per track ID,
+// followed by an mdat.
+func testTrafFragment(t *testing.T, trackIDs ...uint32) []byte {
+	t.Helper()
+	box := func(boxType string, payload ...[]byte) []byte {
+		data := bytes.Join(payload, nil)
+		size := uint32(8 + len(data))
+		return append([]byte{byte(size >> 24), byte(size >> 16), byte(size >> 8), byte(size), boxType[0], boxType[1], boxType[2], boxType[3]}, data...)
+	}
+	mfhd := box("mfhd", []byte{0, 0, 0, 0, 0, 0, 0, 1})
+	var trafs [][]byte
+	for _, id := range trackIDs {
+		tfhd := box("tfhd", []byte{0, 0x02, 0, 0, byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)})
+		trun := box("trun", []byte{0, 0, 0, 0, 0, 0, 0, 0})
+		trafs = append(trafs, box("traf", tfhd, trun))
+	}
+	return append(box("moof", append([][]byte{mfhd}, trafs...)...), box("mdat", []byte{1, 2, 3, 4})...)
+}
+
+func TestSetFragmentTrackID(t *testing.T) {
+	data := append(testTrafFragment(t, 1), testTrafFragment(t, 1, 1)...)
+	if err := SetFragmentTrackID(data, 5); err != nil {
+		t.Fatal(err)
+	}
+	want := append(testTrafFragment(t, 5), testTrafFragment(t, 5, 5)...)
+	if !bytes.Equal(data, want) {
+		t.Errorf("SetFragmentTrackID() = %x, want %x", data, want)
+	}
+}
+
+func TestSetFragmentTrackIDErrors(t *testing.T) {
+	fragment := testTrafFragment(t, 1)
+	truncatedTfhd := decodeHex(t, "00000018"+"6d6f6f66"+"00000010"+"74726166"+"00000008"+"74666864")
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated header", data: fragment[:4]},
+		{name: "truncated box", data: fragment[:len(fragment)-1]},
+		{name: "size below the header", data: decodeHex(t, "00000004"+"6d646174")},
+		{name: "truncated largesize", data: decodeHex(t, "00000001"+"6d646174"+"0000")},
+		{name: "truncated tfhd", data: truncatedTfhd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetFragmentTrackID(tt.data, 2); !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("SetFragmentTrackID() error = %v, want %v", err, ErrInvalidParam)
+			}
+		})
+	}
+}
+
+func TestWalkMp4Boxes(t *testing.T) {
+	data := decodeHex(t, "0000000c"+"66726565"+"01020304"+ // free
+		"00000001"+"6d646174"+"0000000000000012"+"0506"+ // mdat with largesize
+		"00000000"+"736b6970"+"0708") // skip to the end of the data
+	type box struct {
+		boxType string
+		payload []byte
+	}
+	var got []box
+	err := walkMp4Boxes(data, func(boxType mp4.BoxType, payload []byte) error {
+		got = append(got, box{string(boxType[:]), payload})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []box{{"free", []byte{1, 2, 3, 4}}, {"mdat", []byte{5, 6}}, {"skip", []byte{7, 8}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walked %v, want %v", got, want)
+	}
+}
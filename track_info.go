@@ -0,0 +1,70 @@
+package smoothstreaming
+
+import (
+	"github.com/go-webdl/mp4"
+)
+
+// TrackInfo describes the coded video of a track as signaled by its decoder
+// configuration rather than by the manifest.
+type TrackInfo struct {
+	Codec mp4.FourCC
+
+	// the picture size in luma samples.
+	Width  uint32
+	Height uint32
+
+	// profile_idc, the constraint set flags of H.264 or the general profile
+	// compatibility flags of HEVC, the general constraint indicator flags of
+	// HEVC and level_idc.
+	ProfileSpace         uint8
+	Profile              uint8
+	ProfileCompatibility uint32
+	ConstraintFlags      uint64
+	HighTier             bool
+	Level                uint8
+
+	ChromaFormat   uint8
+	BitDepthLuma   uint8
+	BitDepthChroma uint8
+}
+
+// TrackInfo parses the SPS in CodecPrivateData of H.264 and HEVC tracks. For
+// other codecs, and for the fields that cannot be derived, it reports the
+// Width and Height of the MoovProcessor.
+func (p MoovProcessor) TrackInfo() (info TrackInfo, err error) {
+	info = TrackInfo{
+		Codec:  p.Codec,
+		Width:  p.Width,
+		Height: p.Height,
+	}
+	switch p.Codec {
+	case mp4.Avc1FourCC:
+		var sps AVCSPS
+		if sps, err = p.avcSPS(); err != nil {
+			return
+		}
+		info.Width, info.Height = sps.Width, sps.Height
+		info.Profile = sps.ProfileIdc
+		info.ProfileCompatibility = uint32(sps.ConstraintFlags)
+		info.Level = sps.LevelIdc
+		info.ChromaFormat = sps.ChromaFormatIdc
+		info.BitDepthLuma = 8 + sps.BitDepthLumaMinus8
+		info.BitDepthChroma = 8 + sps.BitDepthChromaMinus8
+	case mp4.Hvc1FourCC, mp4.Hev1FourCC, mp4.Dvh1FourCC, mp4.DvheFourCC:
+		var sps HEVCSPS
+		if sps, err = p.hevcSPS(); err != nil {
+			return
+		}
+		info.Width, info.Height = sps.Width, sps.Height
+		info.ProfileSpace = sps.ProfileSpace
+		info.Profile = sps.ProfileIdc
+		info.ProfileCompatibility = sps.ProfileCompatibilityFlags
+		info.ConstraintFlags = sps.ConstraintIndicatorFlags
+		info.HighTier = sps.TierFlag
+		info.Level = sps.LevelIdc
+		info.ChromaFormat = sps.ChromaFormatIdc
+		info.BitDepthLuma = 8 + sps.BitDepthLumaMinus8
+		info.BitDepthChroma = 8 + sps.BitDepthChromaMinus8
+	}
+	return
+}
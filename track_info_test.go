@@ -0,0 +1,61 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestTrackInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		processor MoovProcessor
+		want      TrackInfo
+	}{
+		{
+			name:      "H.264",
+			processor: MoovProcessor{Codec: mp4.Avc1FourCC, Width: 1920, Height: 1080, CodecPrivateData: decodeHex(t, testH264CodecPrivateData)},
+			want: TrackInfo{
+				Codec: mp4.Avc1FourCC, Width: 1280, Height: 720,
+				Profile: 100, Level: 31, ChromaFormat: 1, BitDepthLuma: 8, BitDepthChroma: 8,
+			},
+		},
+		{
+			name:      "HEVC",
+			processor: MoovProcessor{Codec: mp4.Hvc1FourCC, CodecPrivateData: decodeHex(t, testHEVCCodecPrivateData)},
+			want: TrackInfo{
+				Codec: mp4.Hvc1FourCC, Width: 640, Height: 360,
+				Profile: 1, ProfileCompatibility: 0x60000000, ConstraintFlags: 0x900000000000,
+				Level: 120, ChromaFormat: 1, BitDepthLuma: 8, BitDepthChroma: 8,
+			},
+		},
+		{
+			name:      "other codec",
+			processor: MoovProcessor{Codec: Av01FourCC, Width: 1920, Height: 1080},
+			want:      TrackInfo{Codec: Av01FourCC, Width: 1920, Height: 1080},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := tt.processor.TrackInfo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info != tt.want {
+				t.Errorf("TrackInfo() = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackInfoNoSPS(t *testing.T) {
+	p := MoovProcessor{Codec: mp4.Hvc1FourCC, Width: 1920, Height: 1080, CodecPrivateData: decodeHex(t, "000000014401c172b46240")}
+	if _, err := p.TrackInfo(); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("TrackInfo() error = %v, want %v", err, ErrInvalidParam)
+	}
+	// the sample entry keeps the size of the manifest
+	if width, height := p.codedSize(); width != 1920 || height != 1080 {
+		t.Errorf("codedSize() = %dx%d, want 1920x1080", width, height)
+	}
+}
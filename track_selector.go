@@ -0,0 +1,117 @@
+package smoothstreaming
+
+import (
+	"sort"
+	"strings"
+)
+
+// TrackSelector holds the rules choosing the tracks of a presentation to
+// download: from each stream matching the rules, the track of the highest
+// bitrate within the limits, or the one of the lowest bitrate if none is, or
+// with VideoVariants every video track within the limits.
+//
+// Sparse streams, whose fragments are requested along the timeline of their
+// parent stream, and streams whose samples are carried by the manifest are
+// not selected, see SparseChunkURLs and ManifestOutputEvents.
+type TrackSelector struct {
+	// the types of the streams to select, all types if empty.
+	StreamTypes []StreamType
+
+	// the names of the streams to select, compared case insensitively, all
+	// streams if empty.
+	StreamNames []string
+
+	// the highest bitrate, in bits per second, of the selected tracks, no
+	// limit if 0.
+	MaxBitrate uint32
+
+	// the highest picture height of the selected video tracks, no limit if
+	// 0.
+	MaxHeight uint32
+
+	// whether every track of the selected video streams within the limits is
+	// selected, from the highest bitrate down, rather than the highest one
+	// only, e.g. to archive all variants of the video at once. The audio
+	// tracks selected are downloaded once, shared by the variants.
+	VideoVariants bool
+}
+
+// SelectedTrack is a track chosen by a TrackSelector.
+type SelectedTrack struct {
+	Stream *StreamIndex
+	Track  *Track
+}
+
+// Select returns the selected tracks of the presentation, in the order of its
+// streams.
+func (ts TrackSelector) Select(m *SmoothStreamingMedia) (selected []SelectedTrack) {
+	for _, s := range m.Streams {
+		if s.IsSparse() || s.ManifestOutput || !ts.matchStream(s) {
+			continue
+		}
+		var best, lowest *Track
+		for _, t := range s.Tracks {
+			if lowest == nil || t.Bitrate < lowest.Bitrate {
+				lowest = t
+			}
+			if ts.withinLimits(s, t) && (best == nil || t.Bitrate > best.Bitrate) {
+				best = t
+			}
+		}
+		if best == nil {
+			best = lowest
+		}
+		if best == nil {
+			continue
+		}
+		if !ts.VideoVariants || s.Type != VideoStream {
+			selected = append(selected, SelectedTrack{Stream: s, Track: best})
+			continue
+		}
+		// the tracks are requested by bitrate, which tells them apart
+		variants := []SelectedTrack{{Stream: s, Track: best}}
+		bitrates := map[uint32]bool{best.Bitrate: true}
+		for _, t := range s.Tracks {
+			if ts.withinLimits(s, t) && !bitrates[t.Bitrate] {
+				bitrates[t.Bitrate] = true
+				variants = append(variants, SelectedTrack{Stream: s, Track: t})
+			}
+		}
+		sort.SliceStable(variants, func(i, j int) bool {
+			return variants[i].Track.Bitrate > variants[j].Track.Bitrate
+		})
+		selected = append(selected, variants...)
+	}
+	return
+}
+
+func (ts TrackSelector) matchStream(s *StreamIndex) bool {
+	if len(ts.StreamTypes) > 0 {
+		found := false
+		for _, streamType := range ts.StreamTypes {
+			found = found || streamType == s.Type
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(ts.StreamNames) > 0 {
+		for _, name := range ts.StreamNames {
+			if strings.EqualFold(name, s.streamName()) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (ts TrackSelector) withinLimits(s *StreamIndex, t *Track) bool {
+	if ts.MaxBitrate > 0 && t.Bitrate > ts.MaxBitrate {
+		return false
+	}
+	if ts.MaxHeight > 0 && s.Type == VideoStream && t.MaxHeight != nil && *t.MaxHeight > ts.MaxHeight {
+		return false
+	}
+	return true
+}
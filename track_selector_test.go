@@ -0,0 +1,59 @@
+package smoothstreaming
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrackSelectorSelect(t *testing.T) {
+	m, err := ParseManifest([]byte(`<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+		`<StreamIndex Type="video" Name="video" QualityLevels="3">` +
+		`<QualityLevel Index="0" Bitrate="3000" MaxWidth="1920" MaxHeight="1080"/>` +
+		`<QualityLevel Index="1" Bitrate="1000" MaxWidth="1280" MaxHeight="720"/>` +
+		`<QualityLevel Index="2" Bitrate="500" MaxWidth="640" MaxHeight="360"/>` +
+		`</StreamIndex>` +
+		`<StreamIndex Type="audio" Name="audio_eng" QualityLevels="2">` +
+		`<QualityLevel Index="0" Bitrate="128000"/>` +
+		`<QualityLevel Index="1" Bitrate="64000"/>` +
+		`</StreamIndex>` +
+		`<StreamIndex Type="audio" Name="AUDIO_DEU" QualityLevels="1">` +
+		`<QualityLevel Index="0" Bitrate="96000"/>` +
+		`</StreamIndex>` +
+		`<StreamIndex Type="text" Name="ad" ParentStreamIndex="video" QualityLevels="1"><QualityLevel Index="0" Bitrate="0"/></StreamIndex>` +
+		`<StreamIndex Type="text" Name="events" ManifestOutput="true" QualityLevels="1"><QualityLevel Index="0" Bitrate="0"/></StreamIndex>` +
+		`</SmoothStreamingMedia>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type selection struct {
+		stream  string
+		bitrate uint32
+	}
+	tests := []struct {
+		name     string
+		selector TrackSelector
+		want     []selection
+	}{
+		{name: "highest bitrates", want: []selection{{"video", 3000}, {"audio_eng", 128000}, {"AUDIO_DEU", 96000}}},
+		{name: "stream types", selector: TrackSelector{StreamTypes: []StreamType{VideoStream}}, want: []selection{{"video", 3000}}},
+		{name: "stream names ignoring case", selector: TrackSelector{StreamNames: []string{"audio_deu"}}, want: []selection{{"AUDIO_DEU", 96000}}},
+		{name: "maximum bitrate", selector: TrackSelector{StreamTypes: []StreamType{AudioStream}, MaxBitrate: 100000}, want: []selection{{"audio_eng", 64000}, {"AUDIO_DEU", 96000}}},
+		{name: "maximum height", selector: TrackSelector{MaxHeight: 720}, want: []selection{{"video", 1000}, {"audio_eng", 128000}, {"AUDIO_DEU", 96000}}},
+		{name: "lowest bitrate above the limits", selector: TrackSelector{StreamTypes: []StreamType{VideoStream}, MaxBitrate: 100}, want: []selection{{"video", 500}}},
+		{name: "video variants", selector: TrackSelector{VideoVariants: true}, want: []selection{{"video", 3000}, {"video", 1000}, {"video", 500}, {"audio_eng", 128000}, {"AUDIO_DEU", 96000}}},
+		{name: "video variants within the limits", selector: TrackSelector{StreamTypes: []StreamType{VideoStream}, MaxHeight: 720, VideoVariants: true}, want: []selection{{"video", 1000}, {"video", 500}}},
+		{name: "video variants above the limits", selector: TrackSelector{StreamTypes: []StreamType{VideoStream}, MaxBitrate: 100, VideoVariants: true}, want: []selection{{"video", 500}}},
+		{name: "no matching stream", selector: TrackSelector{StreamNames: []string{"fra"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []selection
+			for _, s := range tt.selector.Select(m) {
+				got = append(got, selection{s.Stream.streamName(), s.Track.Bitrate})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
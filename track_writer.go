@@ -0,0 +1,297 @@
+package smoothstreaming
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/go-webdl/mp4"
+)
+
+// OutputLayout is the layout of the output of a track written by a
+// Downloader.
+type OutputLayout int
+
+const (
+	// SingleFileLayout writes each track to a fragmented MP4 file, the init
+	// segment followed by the fragments.
+	SingleFileLayout OutputLayout = iota
+
+	// SegmentedLayout writes each track to a directory of CMAF segment files
+	// ready to be served by a DASH origin: the init segment init.mp4,
+	// compatible with the cmfc and dash brands, and one media segment per
+	// fragment numbered from 1, 1.m4s, 2.m4s and so on, each starting with a
+	// styp box of the msdh and cmfs brands. The segments match a DASH
+	// SegmentTemplate of initialization "init.mp4" and media "$Number$.m4s".
+	SegmentedLayout
+)
+
+// initSegmentName and mediaSegmentExt name the files of a track written in
+// the SegmentedLayout.
+const (
+	initSegmentName = "init.mp4"
+	mediaSegmentExt = ".m4s"
+)
+
+// trackWriter writes the init segment and the fragments of a track to the
+// output of the track.
+type trackWriter interface {
+	writeInit(p MoovProcessor) (n int64, err error)
+	writeFragment(f *Fragment) (n int64, err error)
+
+	// finalize completes the output once all fragments are written,
+	// rewriting the init segment of p with the final duration of the track,
+	// where possible.
+	finalize(p MoovProcessor) error
+
+	Close() error
+}
+
+// fileTrackWriter writes a track to a file in the SingleFileLayout. The file
+// is flushed after the init segment and each fragment, so that the recorded
+// size only ever covers data on disk.
+type fileTrackWriter struct {
+	streamTrackWriter
+	file *os.File
+}
+
+// openFileTrackWriter opens the output file of a track for writing after the
+// data recorded by its progress, discarding what follows. A file shorter than
+// recorded, e.g. replaced since, is downloaded again from the start.
+func openFileTrackWriter(progress *TrackDownloadState) (tw *fileTrackWriter, err error) {
+	if err = os.MkdirAll(filepath.Dir(progress.Path), 0777); err != nil {
+		return
+	}
+	file, err := os.OpenFile(progress.Path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return
+	}
+	var info os.FileInfo
+	if info, err = file.Stat(); err == nil {
+		if info.Size() < progress.Size {
+			*progress = TrackDownloadState{Path: progress.Path}
+		}
+		if err = file.Truncate(progress.Size); err == nil {
+			_, err = file.Seek(progress.Size, io.SeekStart)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return
+	}
+	tw = &fileTrackWriter{streamTrackWriter: streamTrackWriter{w: bufio.NewWriter(file)}, file: file}
+	return
+}
+
+// finalize rewrites the init segment in place, the duration fields of its
+// boxes being of fixed size, and appends an mfra box indexing the fragments.
+// The data written after the fragments is discarded by a resumed download.
+func (tw *fileTrackWriter) finalize(p MoovProcessor) (err error) {
+	if err = tw.w.Flush(); err != nil {
+		return
+	}
+	size, err := tw.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	mfra, initSize, err := indexFragments(tw.file, size, p)
+	if err != nil {
+		return
+	}
+	var init bytes.Buffer
+	if _, err = p.WriteInitSegment(&init); err != nil {
+		return
+	}
+	if int64(init.Len()) != initSize {
+		err = fmt.Errorf("init segment of %d bytes replacing %d bytes: %w", init.Len(), initSize, ErrInvalidParam)
+		return
+	}
+	if _, err = tw.file.WriteAt(init.Bytes(), 0); err != nil {
+		return
+	}
+	if _, err = writeMp4Boxes(tw.w, mfra); err != nil {
+		return
+	}
+	err = tw.w.Flush()
+	return
+}
+
+func (tw *fileTrackWriter) Close() error {
+	return tw.file.Close()
+}
+
+// segmentTrackWriter writes a track to a directory in the SegmentedLayout.
+type segmentTrackWriter struct {
+	dir string
+
+	// the number of the next media segment.
+	next uint32
+
+	// the init segments built for the tracks of the download.
+	inits *initSegmentCache
+}
+
+// openSegmentTrackWriter creates the output directory of a track and numbers
+// the next media segment after the fragments recorded by its progress. A
+// directory missing the recorded init segment is downloaded again from the
+// start.
+func openSegmentTrackWriter(progress *TrackDownloadState) (tw *segmentTrackWriter, err error) {
+	if err = os.MkdirAll(progress.Path, 0777); err != nil {
+		return
+	}
+	if progress.Size > 0 {
+		if _, err = os.Stat(filepath.Join(progress.Path, initSegmentName)); errors.Is(err, fs.ErrNotExist) {
+			*progress = TrackDownloadState{Path: progress.Path}
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+	tw = &segmentTrackWriter{dir: progress.Path, next: progress.Fragments + 1}
+	return
+}
+
+func (tw *segmentTrackWriter) writeInit(p MoovProcessor) (n int64, err error) {
+	p.MajorBrand = mp4.Iso6FourCC
+	p.CompatibleBrands = []mp4.FourCC{mp4.IsomFourCC, mp4.Iso6FourCC, CmfcFourCC, mp4.DashFourCC}
+	err = writeFile(filepath.Join(tw.dir, initSegmentName), func(w io.Writer) (err error) {
+		n, err = tw.inits.write(w, p)
+		return
+	})
+	return
+}
+
+func (tw *segmentTrackWriter) writeFragment(f *Fragment) (n int64, err error) {
+	rw := FragmentRewriter{
+		SegmentMajorBrand:       mp4.MsdhFourCC,
+		SegmentCompatibleBrands: []mp4.FourCC{mp4.MsdhFourCC, CmfsFourCC},
+	}
+	f.SegmentType = rw.createStypMp4Box()
+	err = writeFile(filepath.Join(tw.dir, fmt.Sprintf("%d%s", tw.next, mediaSegmentExt)), func(w io.Writer) (err error) {
+		n, err = f.WriteTo(w)
+		return
+	})
+	if err == nil {
+		tw.next++
+	}
+	return
+}
+
+func (tw *segmentTrackWriter) finalize(p MoovProcessor) (err error) {
+	_, err = tw.writeInit(p)
+	return
+}
+
+func (tw *segmentTrackWriter) Close() error {
+	return nil
+}
+
+// streamTrackWriter writes a track to a stream, flushed after the init
+// segment and each fragment.
+type streamTrackWriter struct {
+	w *bufio.Writer
+
+	// the init segments built for the tracks of the download.
+	inits *initSegmentCache
+}
+
+func (tw *streamTrackWriter) writeInit(p MoovProcessor) (n int64, err error) {
+	if n, err = tw.inits.write(tw.w, p); err != nil {
+		return
+	}
+	err = tw.w.Flush()
+	return
+}
+
+func (tw *streamTrackWriter) writeFragment(f *Fragment) (n int64, err error) {
+	if n, err = f.WriteTo(tw.w); err != nil {
+		return
+	}
+	err = tw.w.Flush()
+	return
+}
+
+// finalize does nothing, the data written to a stream being final.
+func (tw *streamTrackWriter) finalize(p MoovProcessor) error {
+	return nil
+}
+
+func (tw *streamTrackWriter) Close() error {
+	return nil
+}
+
+// writeFile creates the file at path and writes its content with write.
+func writeFile(path string, write func(w io.Writer) error) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(file)
+	if err = write(w); err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return
+}
+
+// initSegmentCache holds the init segments built for the tracks of a
+// download, so that the tracks of the same configuration, e.g. the quality
+// levels of a stream differing only by the bitrate of the samples, or the
+// tracks recorded in turn with AdaptiveQuality, reuse the init segment built
+// for the first one rather than building it again.
+type initSegmentCache struct {
+	mu      sync.Mutex
+	entries []initSegmentEntry
+}
+
+// initSegmentEntry is the init segment built for a configuration, without
+// the fields that do not change it.
+type initSegmentEntry struct {
+	key  MoovProcessor
+	data []byte
+}
+
+// write writes the init segment of p to w, built once for all identical
+// configurations. A nil cache builds it every time.
+func (c *initSegmentCache) write(w io.Writer, p MoovProcessor) (n int64, err error) {
+	if c == nil {
+		return p.WriteInitSegment(w)
+	}
+	key := p
+	if key.StreamType == VideoStream && !key.EmitBitRateBox {
+		// the bitrate of a video track is only written to its btrt box
+		key.Bitrate, key.MaxBitrate = 0, 0
+	}
+	c.mu.Lock()
+	var data []byte
+	for _, entry := range c.entries {
+		if reflect.DeepEqual(entry.key, key) {
+			data = entry.data
+			break
+		}
+	}
+	c.mu.Unlock()
+	if data == nil {
+		var buf bytes.Buffer
+		if _, err = p.WriteInitSegment(&buf); err != nil {
+			return
+		}
+		data = buf.Bytes()
+		c.mu.Lock()
+		c.entries = append(c.entries, initSegmentEntry{key: key, data: data})
+		c.mu.Unlock()
+	}
+	written, err := w.Write(data)
+	n = int64(written)
+	return
+}
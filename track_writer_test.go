@@ -0,0 +1,227 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+)
+
+func TestDownloaderSegmentedLayout(t *testing.T) {
+	fragment := testFragment(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/movie.ism/Manifest" {
+			io.WriteString(w, testManifest)
+			return
+		}
+		w.Write(fragment)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tracks, err := Downloader{OutputDir: dir, Layout: SegmentedLayout}.Download(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 || tracks[0].Path != filepath.Join(dir, "video_1000") {
+		t.Fatalf("tracks %+v, want one in the directory video_1000", tracks)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tracks[0].Path, "init.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ParseInitSegment(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBrands := []mp4.FourCC{mp4.IsomFourCC, mp4.Iso6FourCC, CmfcFourCC, mp4.DashFourCC}
+	if p := m.Tracks[0]; p.MajorBrand != mp4.Iso6FourCC || !reflect.DeepEqual(p.CompatibleBrands, wantBrands) {
+		t.Errorf("init segment of brands %v %v, want %v %v", p.MajorBrand, p.CompatibleBrands, mp4.Iso6FourCC, wantBrands)
+	}
+	for i := 0; i < 3; i++ {
+		data, err := os.ReadFile(filepath.Join(tracks[0].Path, fmt.Sprintf("%d.m4s", i+1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := ParseFragment(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.SegmentType == nil || f.SegmentType.MajorBrand != mp4.MsdhFourCC {
+			t.Errorf("segment %d of type %+v, want an msdh styp box", i+1, f.SegmentType)
+		}
+		if decodeTime, ok := f.Tracks[0].DecodeTime(); !ok || decodeTime != uint64(i)*20000000 {
+			t.Errorf("segment %d decoded at %d, want %d", i+1, decodeTime, i*20000000)
+		}
+	}
+	entries, err := os.ReadDir(tracks[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("output directory holds %d files, want 4", len(entries))
+	}
+}
+
+func TestOpenSegmentTrackWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress TrackDownloadState
+		init     bool
+		wantNext uint32
+		wantSize int64
+	}{
+		{name: "new track", wantNext: 1},
+		{name: "resumed", progress: TrackDownloadState{Size: 100, Fragments: 2, LastTime: 20000000}, init: true, wantNext: 3, wantSize: 100},
+		{name: "init segment missing", progress: TrackDownloadState{Size: 100, Fragments: 2, LastTime: 20000000}, wantNext: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := tt.progress
+			progress.Path = filepath.Join(t.TempDir(), "video_1000")
+			if tt.init {
+				if err := os.MkdirAll(progress.Path, 0777); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(progress.Path, initSegmentName), nil, 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tw, err := openSegmentTrackWriter(&progress)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tw.next != tt.wantNext || progress.Size != tt.wantSize {
+				t.Errorf("next segment %d with %d bytes recorded, want %d with %d", tw.next, progress.Size, tt.wantNext, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestDownloaderOpenTrackWriterInvalidLayout(t *testing.T) {
+	selected := SelectedTrack{Stream: &StreamIndex{Type: VideoStream}, Track: &Track{Bitrate: 1000}}
+	progress := &TrackDownloadState{Path: filepath.Join(t.TempDir(), "video_1000.mp4")}
+	if _, err := (Downloader{Layout: OutputLayout(9)}).openTrackWriter(selected, progress); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("openTrackWriter() error = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestInitSegmentCache(t *testing.T) {
+	m, err := ParseManifest([]byte(`<SmoothStreamingMedia MajorVersion="2" MinorVersion="2" Duration="60000000">` +
+		`<StreamIndex Type="video" Name="video" QualityLevels="2">` +
+		`<QualityLevel Index="0" Bitrate="1000" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+		`<QualityLevel Index="1" Bitrate="500" FourCC="H264" MaxWidth="1280" MaxHeight="720" CodecPrivateData="` + testH264CodecPrivateData + `"/>` +
+		`</StreamIndex>` +
+		`<StreamIndex Type="audio" Name="audio" QualityLevels="2">` +
+		`<QualityLevel Index="0" Bitrate="128000" FourCC="AACL" SamplingRate="48000" Channels="2" CodecPrivateData="1190"/>` +
+		`<QualityLevel Index="1" Bitrate="64000" FourCC="AACL" SamplingRate="48000" Channels="2" CodecPrivateData="1190"/>` +
+		`</StreamIndex></SmoothStreamingMedia>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var configs [2][2]MoovProcessor
+	for i, s := range m.Streams {
+		for j, track := range s.Tracks {
+			if configs[i][j], err = m.MoovProcessor(s, track); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	video, lower := configs[0][0], configs[0][1]
+	withBtrt := lower
+	withBtrt.EmitBitRateBox = true
+	audio, otherAudio := configs[1][0], configs[1][1]
+	tests := []struct {
+		name        string
+		configs     []MoovProcessor
+		wantEntries int
+	}{
+		{name: "same track", configs: []MoovProcessor{video, video}, wantEntries: 1},
+		{name: "video bitrates", configs: []MoovProcessor{video, lower}, wantEntries: 1},
+		{name: "video bitrates in btrt boxes", configs: []MoovProcessor{video, withBtrt}, wantEntries: 2},
+		{name: "audio bitrates", configs: []MoovProcessor{audio, otherAudio}, wantEntries: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &initSegmentCache{}
+			for _, p := range tt.configs {
+				var got, want bytes.Buffer
+				n, err := c.write(&got, p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err = (*initSegmentCache)(nil).write(&want, p); err != nil {
+					t.Fatal(err)
+				}
+				if n != int64(got.Len()) {
+					t.Errorf("write() = %d, wrote %d bytes", n, got.Len())
+				}
+				// the init segment of a video track differs only in the
+				// bitrate of its btrt box, if any
+				if p.StreamType != VideoStream && !bytes.Equal(got.Bytes(), want.Bytes()) {
+					t.Error("cached init segment differs from the one built")
+				}
+			}
+			if len(c.entries) != tt.wantEntries {
+				t.Errorf("cached %d init segments, want %d", len(c.entries), tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestDownloaderVideoVariants(t *testing.T) {
+	fragment := testFragment(t, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/movie.ism/Manifest" {
+			io.WriteString(w, testManifest)
+			return
+		}
+		w.Write(fragment)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL + "/movie.ism/Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	tracks, err := Downloader{OutputDir: dir, Tracks: TrackSelector{VideoVariants: true}}.Download(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 || tracks[0].Track.Bitrate != 1000 || tracks[1].Track.Bitrate != 500 {
+		t.Fatalf("tracks %+v, want the variants of 1000 and 500", tracks)
+	}
+	for _, track := range tracks {
+		data, err := os.ReadFile(track.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := bytes.NewReader(data)
+		if _, err = ParseInitSegment(r); err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for ; r.Len() > 0; n++ {
+			if _, err = ParseFragment(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n != 3 {
+			t.Errorf("%s has %d fragments, want 3", track.Path, n)
+		}
+	}
+}
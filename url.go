@@ -0,0 +1,244 @@
+package smoothstreaming
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ManifestURL derives the Manifest Request url from the url of a presentation,
+// e.g. http://example.com/video.ism or http://example.com/live.isml. Urls that
+// already address the manifest, with or without a trailing slash, are
+// normalized to the same form.
+//
+// If predicate is not empty it is appended to the Manifest noun, e.g.
+// "filter=name" produces .../video.ism/Manifest(filter=name). Otherwise a
+// predicate present in presentationURL is kept.
+func ManifestURL(presentationURL *url.URL, predicate string) (u *url.URL, err error) {
+	if presentationURL == nil {
+		err = fmt.Errorf("presentation url is nil: %w", ErrInvalidParam)
+		return
+	}
+	segments := strings.Split(strings.TrimRight(presentationURL.Path, "/"), "/")
+	presentation := -1
+	for i := len(segments) - 1; i >= 0; i-- {
+		if isPresentationSegment(segments[i]) {
+			presentation = i
+			break
+		}
+	}
+	if presentation < 0 {
+		err = fmt.Errorf("url %s does not address an .ism or .isml presentation: %w", presentationURL, ErrInvalidParam)
+		return
+	}
+	trailing := segments[presentation+1:]
+	if len(trailing) > 1 {
+		err = fmt.Errorf("url %s has unexpected segments after the presentation: %w", presentationURL, ErrInvalidParam)
+		return
+	}
+	manifest := "Manifest"
+	if len(trailing) == 1 {
+		noun, existing, ok := splitPredicate(trailing[0])
+		if !ok || !strings.EqualFold(noun, "Manifest") {
+			err = fmt.Errorf("url %s does not address a manifest: %w", presentationURL, ErrInvalidParam)
+			return
+		}
+		if existing != "" {
+			manifest += "(" + existing + ")"
+		}
+	}
+	if predicate != "" {
+		manifest = "Manifest(" + predicate + ")"
+	}
+	v := *presentationURL
+	setURLPath(&v, path.Join(append(segments[:presentation+1:presentation+1], manifest)...))
+	if strings.HasPrefix(presentationURL.Path, "/") && !strings.HasPrefix(v.Path, "/") {
+		setURLPath(&v, "/"+v.Path)
+	}
+	u = &v
+	return
+}
+
+// IsLivePresentationURL reports whether the url addresses a live publishing
+// point (.isml) rather than an on-demand presentation (.ism).
+func IsLivePresentationURL(u *url.URL) bool {
+	for _, segment := range strings.Split(u.Path, "/") {
+		if strings.HasSuffix(strings.ToLower(segment), ".isml") {
+			return true
+		}
+	}
+	return false
+}
+
+func isPresentationSegment(segment string) bool {
+	lower := strings.ToLower(segment)
+	return strings.HasSuffix(lower, ".ism") || strings.HasSuffix(lower, ".isml")
+}
+
+// splitPredicate splits a path segment of the form Noun(Predicate) into its
+// noun and predicate. A segment without parentheses is a bare noun.
+func splitPredicate(segment string) (noun, predicate string, ok bool) {
+	open := strings.IndexByte(segment, '(')
+	if open < 0 {
+		return segment, "", !strings.ContainsRune(segment, ')')
+	}
+	if !strings.HasSuffix(segment, ")") {
+		return
+	}
+	return segment[:open], segment[open+1 : len(segment)-1], true
+}
+
+// setURLPath sets the path of u while keeping the parentheses, commas and
+// equal signs of Smooth Streaming predicates unescaped in the request line.
+func setURLPath(u *url.URL, p string) {
+	u.Path = p
+	u.RawPath = escapePath(p)
+}
+
+var predicateUnescaper = strings.NewReplacer("%28", "(", "%29", ")", "%2C", ",")
+
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = predicateUnescaper.Replace(url.PathEscape(segment))
+	}
+	return strings.Join(segments, "/")
+}
+
+// URLPatternTrackName returns the TrackName of the Fragments noun embedded in
+// the url pattern of the stream, e.g. "audio_eng" for the pattern
+// QualityLevels({bitrate})/Fragments(audio_eng={start time}).
+func (s *StreamIndex) URLPatternTrackName() (name string, err error) {
+	_, name, _, err = s.splitURLPattern()
+	return
+}
+
+// ValidateURLPattern checks that the TrackName embedded in the url pattern
+// matches the Name of the stream, or its Type when the stream is unnamed.
+func (s *StreamIndex) ValidateURLPattern() (err error) {
+	name, err := s.URLPatternTrackName()
+	if err != nil {
+		return
+	}
+	if expected := s.streamName(); name != expected {
+		err = fmt.Errorf("url pattern names track %q but stream is %q: %w", name, expected, ErrInvalidParam)
+		return
+	}
+	return
+}
+
+// Rename changes the Name of the stream and substitutes the TrackName
+// embedded in its url pattern, so that fragment requests are addressed to the
+// renamed stream.
+func (s *StreamIndex) Rename(name string) (err error) {
+	if name == "" || strings.ContainsAny(name, "=()/") {
+		err = fmt.Errorf("invalid stream name %q: %w", name, ErrInvalidParam)
+		return
+	}
+	if s.URL != nil {
+		var prefix, suffix string
+		if prefix, _, suffix, err = s.splitURLPattern(); err != nil {
+			return
+		}
+		pattern := prefix + name + suffix
+		s.URL = &pattern
+	}
+	s.Name = &name
+	return
+}
+
+// RenameStream renames a stream of the presentation and updates the
+// ParentStreamIndex field of the sparse streams that refer to it.
+func (m *SmoothStreamingMedia) RenameStream(s *StreamIndex, name string) (err error) {
+	old := s.streamName()
+	if err = s.Rename(name); err != nil {
+		return
+	}
+	for _, stream := range m.Streams {
+		if stream != s && stream.IsSparse() && *stream.ParentStreamIndex == old {
+			stream.ParentStreamIndex = &name
+		}
+	}
+	return
+}
+
+func (s *StreamIndex) streamName() string {
+	if s.Name != nil {
+		return *s.Name
+	}
+	return string(s.Type)
+}
+
+// splitURLPattern splits the url pattern of the stream around the TrackName of
+// its Fragments noun.
+func (s *StreamIndex) splitURLPattern() (prefix, name, suffix string, err error) {
+	if s.URL == nil {
+		err = fmt.Errorf("stream has no url pattern: %w", ErrInvalidParam)
+		return
+	}
+	pattern := *s.URL
+	start := strings.LastIndexByte(pattern, '/') + 1
+	noun, predicate, ok := splitPredicate(pattern[start:])
+	if !ok || !strings.EqualFold(noun, "Fragments") {
+		err = fmt.Errorf("url pattern %q does not end with a Fragments noun: %w", pattern, ErrInvalidParam)
+		return
+	}
+	eq := strings.IndexByte(predicate, '=')
+	if eq < 0 {
+		err = fmt.Errorf("url pattern %q has no track name: %w", pattern, ErrInvalidParam)
+		return
+	}
+	nameStart := start + len(noun) + 1
+	prefix = pattern[:nameStart]
+	name = predicate[:eq]
+	suffix = pattern[nameStart+eq:]
+	return
+}
+
+var urlPatternPlaceholderRegexp = regexp.MustCompile(`\{[^{}]*\}`)
+
+// escapeURLPattern percent-encodes the literal parts of a url pattern while
+// leaving its placeholders intact. Patterns that are already escaped are not
+// escaped a second time.
+func escapeURLPattern(pattern string) string {
+	query := ""
+	if i := strings.IndexByte(pattern, '?'); i >= 0 {
+		pattern, query = pattern[:i], pattern[i:]
+	}
+	var b strings.Builder
+	last := 0
+	for _, loc := range urlPatternPlaceholderRegexp.FindAllStringIndex(pattern, -1) {
+		b.WriteString(escapeURLPatternLiteral(pattern[last:loc[0]]))
+		b.WriteString(pattern[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(escapeURLPatternLiteral(pattern[last:]))
+	b.WriteString(query)
+	return b.String()
+}
+
+func escapeURLPatternLiteral(s string) string {
+	if unescaped, err := url.PathUnescape(s); err == nil {
+		s = unescaped
+	}
+	return escapePath(s)
+}
+
+// escapePredicateValue percent-encodes a value substituted into a predicate,
+// including the characters that delimit predicates.
+func escapePredicateValue(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "=", "%3D")
+}
+
+func escapeCustomAttributes(a *CustomAttributes) string {
+	if a == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(a.Attributes))
+	for _, attr := range a.Attributes {
+		pairs = append(pairs, escapePredicateValue(attr.Name)+"="+escapePredicateValue(attr.Value))
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,173 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestManifestURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		presentation string
+		predicate    string
+		want         string
+		wantErr      error
+	}{
+		{
+			name:         "on-demand presentation",
+			presentation: "http://example.com/media/video.ism",
+			want:         "http://example.com/media/video.ism/Manifest",
+		},
+		{
+			name:         "live presentation with a trailing slash",
+			presentation: "http://example.com/live.isml/",
+			want:         "http://example.com/live.isml/Manifest",
+		},
+		{
+			name:         "manifest url",
+			presentation: "http://example.com/video.ism/manifest?token=1",
+			want:         "http://example.com/video.ism/Manifest?token=1",
+		},
+		{
+			name:         "predicate of the manifest url",
+			presentation: "http://example.com/video.ism/Manifest(filter=name)",
+			want:         "http://example.com/video.ism/Manifest(filter=name)",
+		},
+		{
+			name:         "predicate",
+			presentation: "http://example.com/video.ism/Manifest(filter=name)",
+			predicate:    "format=mpd-time-csf",
+			want:         "http://example.com/video.ism/Manifest(format=mpd-time-csf)",
+		},
+		{
+			name:         "not a presentation",
+			presentation: "http://example.com/video.mp4",
+			wantErr:      ErrInvalidParam,
+		},
+		{
+			name:         "not a manifest",
+			presentation: "http://example.com/video.ism/QualityLevels(1000)",
+			wantErr:      ErrInvalidParam,
+		},
+		{
+			name:         "segments after the manifest",
+			presentation: "http://example.com/video.ism/Manifest/more",
+			wantErr:      ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			presentation, err := url.Parse(tt.presentation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := ManifestURL(presentation, tt.predicate)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ManifestURL() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && u.String() != tt.want {
+				t.Errorf("ManifestURL() = %s, want %s", u, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsLivePresentationURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"http://example.com/live.isml/Manifest", true},
+		{"http://example.com/LIVE.ISML", true},
+		{"http://example.com/video.ism/Manifest", false},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := IsLivePresentationURL(u); got != tt.want {
+			t.Errorf("IsLivePresentationURL(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestStreamIndexValidateURLPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  StreamIndex
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "stream name",
+			stream: StreamIndex{Type: AudioStream, Name: stringPtr("audio_eng"), URL: stringPtr("QualityLevels({bitrate})/Fragments(audio_eng={start time})")},
+			want:   "audio_eng",
+		},
+		{
+			name:   "stream type",
+			stream: StreamIndex{Type: VideoStream, URL: stringPtr("QualityLevels({bitrate})/Fragments(video={start time})")},
+			want:   "video",
+		},
+		{
+			name:    "mismatched name",
+			stream:  StreamIndex{Type: AudioStream, Name: stringPtr("audio_fra"), URL: stringPtr("QualityLevels({bitrate})/Fragments(audio_eng={start time})")},
+			want:    "audio_eng",
+			wantErr: ErrInvalidParam,
+		},
+		{
+			name:    "no Fragments noun",
+			stream:  StreamIndex{Type: VideoStream, URL: stringPtr("QualityLevels({bitrate})/Chunks(video={start time})")},
+			wantErr: ErrInvalidParam,
+		},
+		{
+			name:    "no track name",
+			stream:  StreamIndex{Type: VideoStream, URL: stringPtr("QualityLevels({bitrate})/Fragments({start time})")},
+			wantErr: ErrInvalidParam,
+		},
+		{
+			name:    "no url pattern",
+			stream:  StreamIndex{Type: VideoStream},
+			wantErr: ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if name, err := tt.stream.URLPatternTrackName(); err == nil && name != tt.want {
+				t.Errorf("URLPatternTrackName() = %s, want %s", name, tt.want)
+			}
+			if err := tt.stream.ValidateURLPattern(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateURLPattern() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenameStream(t *testing.T) {
+	m := SmoothStreamingMedia{Streams: []*StreamIndex{
+		{Type: AudioStream, Name: stringPtr("audio"), URL: stringPtr("QualityLevels({bitrate})/Fragments(audio={start time})")},
+		{Type: TextStream, Name: stringPtr("events"), ParentStreamIndex: stringPtr("audio"), URL: stringPtr("QualityLevels({bitrate})/Fragments(events={start time})")},
+	}}
+	if err := m.RenameStream(m.Streams[0], "audio_eng"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := *m.Streams[0].URL, "QualityLevels({bitrate})/Fragments(audio_eng={start time})"; got != want {
+		t.Errorf("renamed url pattern = %s, want %s", got, want)
+	}
+	if err := m.Streams[0].ValidateURLPattern(); err != nil {
+		t.Errorf("ValidateURLPattern() of the renamed stream: %v", err)
+	}
+	if got := *m.Streams[1].ParentStreamIndex; got != "audio_eng" {
+		t.Errorf("ParentStreamIndex of the sparse stream = %s, want audio_eng", got)
+	}
+	for _, name := range []string{"", "a=b", "a(b)", "a/b"} {
+		if err := m.Streams[0].Rename(name); !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("Rename(%q) error = %v, want %v", name, err, ErrInvalidParam)
+		}
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,117 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/go-webdl/bits"
+)
+
+// VC-1 profiles as signaled by the dvc1 box.
+const (
+	VC1ProfileSimple   uint8 = 0
+	VC1ProfileMain     uint8 = 4
+	VC1ProfileAdvanced uint8 = 12
+)
+
+// VC1Config describes a VC-1 video track as found in the CodecPrivateData of
+// a WVC1 track.
+type VC1Config struct {
+	// the coded size from the BITMAPINFOHEADER, or the maximum coded size of
+	// the sequence header if the VIDEOINFOHEADER is absent.
+	Width  uint32
+	Height uint32
+
+	// the frame duration in 100 ns units, 0 if unknown.
+	AvgTimePerFrame uint64
+
+	Level     uint8
+	Interlace bool
+
+	// the sequence header and entry point header, with their start codes.
+	SequenceHeader []byte
+}
+
+var vc1SequenceHeaderStartCode = []byte{0x00, 0x00, 0x01, 0x0f}
+
+const (
+	videoInfoHeaderSize  = 48 // RECT rcSource, rcTarget; DWORD dwBitRate, dwBitErrorRate; REFERENCE_TIME AvgTimePerFrame;
+	bitmapInfoHeaderSize = 40
+)
+
+// ParseVC1Config parses the CodecPrivateData of a WVC1 track. Both the
+// VIDEOINFOHEADER structure required by the manifest specification and the
+// bare sequence header found in manifests of some encoders are accepted. Only
+// the advanced profile is supported.
+func ParseVC1Config(data []byte) (c VC1Config, err error) {
+	if len(data) >= videoInfoHeaderSize+bitmapInfoHeaderSize &&
+		binary.LittleEndian.Uint32(data[videoInfoHeaderSize:]) >= bitmapInfoHeaderSize {
+		c.AvgTimePerFrame = binary.LittleEndian.Uint64(data[40:48])
+		c.Width = binary.LittleEndian.Uint32(data[videoInfoHeaderSize+4:])
+		if height := int32(binary.LittleEndian.Uint32(data[videoInfoHeaderSize+8:])); height < 0 {
+			c.Height = uint32(-height)
+		} else {
+			c.Height = uint32(height)
+		}
+		data = data[videoInfoHeaderSize+binary.LittleEndian.Uint32(data[videoInfoHeaderSize:]):]
+	}
+	start := bytes.Index(data, vc1SequenceHeaderStartCode)
+	if start < 0 {
+		err = fmt.Errorf("VC-1 CodecPrivateData has no advanced profile sequence header: %w", ErrUnknownCodec)
+		return
+	}
+	c.SequenceHeader = data[start:]
+
+	r := bits.NewAccErrReader(bytes.NewReader(vc1Unescape(c.SequenceHeader[len(vc1SequenceHeaderStartCode):])))
+	if profile := r.Read(2); profile != 3 {
+		err = fmt.Errorf("VC-1 profile %d: %w", profile, ErrUnknownCodec)
+		return
+	}
+	c.Level = uint8(r.Read(3))
+	r.Read(2) // COLORDIFF_FORMAT
+	r.Read(3) // FRMRTQ_POSTPROC
+	r.Read(5) // BITRTQ_POSTPROC
+	r.Read(1) // POSTPROCFLAG
+	maxCodedWidth := uint32(r.Read(12))
+	maxCodedHeight := uint32(r.Read(12))
+	r.Read(1) // PULLDOWN
+	c.Interlace = r.ReadFlag()
+	if err = r.AccError(); err != nil {
+		err = fmt.Errorf("VC-1 sequence header truncated: %w", ErrInvalidParam)
+		return
+	}
+	if c.Width == 0 || c.Height == 0 {
+		c.Width = (maxCodedWidth + 1) * 2
+		c.Height = (maxCodedHeight + 1) * 2
+	}
+	return
+}
+
+// Framerate returns the frame rate rounded down, or 0xffffffff if unknown as
+// signaled by the dvc1 box.
+func (c VC1Config) Framerate() uint32 {
+	if c.AvgTimePerFrame == 0 {
+		return 0xffffffff
+	}
+	return uint32(10000000 / c.AvgTimePerFrame)
+}
+
+// vc1Unescape removes the emulation prevention bytes of a bitstream data unit.
+func vc1Unescape(data []byte) []byte {
+	unescaped := make([]byte, 0, len(data))
+	zeros := 0
+	for i, b := range data {
+		if zeros >= 2 && b == 0x03 && i+1 < len(data) && data[i+1] <= 0x03 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		unescaped = append(unescaped, b)
+	}
+	return unescaped
+}
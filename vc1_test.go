@@ -0,0 +1,102 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testVC1SequenceHeader is an advanced profile sequence header of level 3 and
+// a maximum coded size of 1280x720 followed by an entry point header.
+const testVC1SequenceHeader = "0000010fdbfe27f16780" + "0000010e5a67f840"
+
+// testVC1VideoInfoHeader returns a VIDEOINFOHEADER of a 1920x1080 top-down
+// bitmap with the given frame duration, followed by the sequence header.
+func testVC1VideoInfoHeader(t *testing.T, avgTimePerFrame uint64) []byte {
+	t.Helper()
+	data := make([]byte, videoInfoHeaderSize+bitmapInfoHeaderSize)
+	binary.LittleEndian.PutUint64(data[40:], avgTimePerFrame)
+	binary.LittleEndian.PutUint32(data[videoInfoHeaderSize:], bitmapInfoHeaderSize)
+	binary.LittleEndian.PutUint32(data[videoInfoHeaderSize+4:], 1920)
+	height := int32(-1080)
+	binary.LittleEndian.PutUint32(data[videoInfoHeaderSize+8:], uint32(height))
+	return append(data, decodeHex(t, testVC1SequenceHeader)...)
+}
+
+func TestParseVC1Config(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		want          VC1Config
+		wantFramerate uint32
+		wantErr       error
+	}{
+		{
+			name:          "sequence header",
+			data:          decodeHex(t, testVC1SequenceHeader),
+			want:          VC1Config{Width: 1280, Height: 720, Level: 3},
+			wantFramerate: 0xffffffff,
+		},
+		{
+			name:          "VIDEOINFOHEADER",
+			data:          testVC1VideoInfoHeader(t, 417083),
+			want:          VC1Config{Width: 1920, Height: 1080, AvgTimePerFrame: 417083, Level: 3},
+			wantFramerate: 23,
+		},
+		{
+			name:    "no sequence header",
+			data:    decodeHex(t, "0000010e5a67f840"),
+			wantErr: ErrUnknownCodec,
+		},
+		{
+			name:    "main profile",
+			data:    decodeHex(t, "0000010f4bfe27f16780"),
+			wantErr: ErrUnknownCodec,
+		},
+		{
+			name:    "truncated sequence header",
+			data:    decodeHex(t, "0000010fdbfe"),
+			wantErr: ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVC1Config(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseVC1Config() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(got.SequenceHeader, decodeHex(t, testVC1SequenceHeader)) {
+				t.Errorf("SequenceHeader = %x, want %s", got.SequenceHeader, testVC1SequenceHeader)
+			}
+			got.SequenceHeader = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseVC1Config() = %+v, want %+v", got, tt.want)
+			}
+			if framerate := got.Framerate(); framerate != tt.wantFramerate {
+				t.Errorf("Framerate() = %d, want %d", framerate, tt.wantFramerate)
+			}
+		})
+	}
+}
+
+func TestVC1Unescape(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{data: "0000030105", want: "00000105"},
+		{data: "000003", want: "000003"},
+		{data: "00000304", want: "00000304"},
+		{data: "0000030000030001", want: "000000000001"},
+	}
+	for _, tt := range tests {
+		if got := vc1Unescape(decodeHex(t, tt.data)); !bytes.Equal(got, decodeHex(t, tt.want)) {
+			t.Errorf("vc1Unescape(%s) = %x, want %s", tt.data, got, tt.want)
+		}
+	}
+}
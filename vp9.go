@@ -0,0 +1,87 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// vp9Levels lists the VP9 levels with the maximum luma picture size of each.
+var vp9Levels = []struct {
+	level       uint8
+	pictureSize uint32
+}{
+	{10, 36864}, {11, 73728}, {20, 122880}, {21, 245760}, {30, 552960},
+	{31, 983040}, {40, 2228224}, {50, 8912896}, {60, 35651584},
+}
+
+// ParseVPCodecString parses the codecs parameter of a VP9 track, e.g.
+// "vp09.00.10.08" or "vp09.02.10.10.01.09.16.09.01", into a vpcC box. Omitted
+// optional fields take the defaults of the VP codec ISO media file format
+// binding.
+func ParseVPCodecString(codec string) (vpcC *VPCodecConfigurationBox, err error) {
+	fields := strings.Split(codec, ".")
+	if len(fields) < 4 || len(fields) > 9 || !strings.EqualFold(fields[0], "vp09") {
+		err = fmt.Errorf("VP9 codec string %q: %w", codec, ErrInvalidParam)
+		return
+	}
+	values := []uint8{0, 0, 0, 1, 1, 1, 1, 0} // profile, level, bit depth, chroma, primaries, transfer, matrix, full range
+	for i, field := range fields[1:] {
+		var v uint64
+		if v, err = strconv.ParseUint(field, 10, 8); err != nil {
+			err = fmt.Errorf("VP9 codec string %q: %w", codec, ErrInvalidParam)
+			return
+		}
+		values[i] = uint8(v)
+	}
+	vpcC = &VPCodecConfigurationBox{
+		Profile:                 values[0],
+		Level:                   values[1],
+		BitDepth:                values[2],
+		ChromaSubsampling:       values[3],
+		ColourPrimaries:         values[4],
+		TransferCharacteristics: values[5],
+		MatrixCoefficients:      values[6],
+		VideoFullRangeFlag:      values[7] != 0,
+	}
+	return
+}
+
+// NewVPCodecConfigurationBox creates the vpcC box of a VP9 track. The
+// configuration is taken from CodecPrivateData holding a
+// VPCodecConfigurationRecord, with or without the version and flags of the
+// box, or from a "codecs" custom attribute. Otherwise an 8 bit 4:2:0 profile 0
+// stream is assumed, with the lowest level supporting the given picture size.
+func NewVPCodecConfigurationBox(codecPrivateData []byte, attributes *CustomAttributes, width, height uint32) (vpcC *VPCodecConfigurationBox, err error) {
+	if len(codecPrivateData) > 0 {
+		record := codecPrivateData
+		if len(record) >= 12 && record[0] == 1 && int(binary.BigEndian.Uint16(record[10:])) == len(record)-12 {
+			record = record[4:]
+		}
+		vpcC = &VPCodecConfigurationBox{}
+		if err = vpcC.readRecord(record); err != nil {
+			err = fmt.Errorf("invalid CodecPrivateData for vpcC: %w", ErrInvalidParam)
+		}
+		return
+	}
+	if codec, ok := attributes.Value("codecs"); ok {
+		return ParseVPCodecString(codec)
+	}
+	level := vp9Levels[len(vp9Levels)-1].level
+	for _, l := range vp9Levels {
+		if width*height <= l.pictureSize {
+			level = l.level
+			break
+		}
+	}
+	vpcC = &VPCodecConfigurationBox{
+		Level:                   level,
+		BitDepth:                8,
+		ChromaSubsampling:       1,
+		ColourPrimaries:         1,
+		TransferCharacteristics: 1,
+		MatrixCoefficients:      1,
+	}
+	return
+}
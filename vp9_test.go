@@ -0,0 +1,97 @@
+package smoothstreaming
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVPCodecString(t *testing.T) {
+	tests := []struct {
+		codec   string
+		want    VPCodecConfigurationBox
+		wantErr error
+	}{
+		{
+			codec: "vp09.00.10.08",
+			want:  VPCodecConfigurationBox{Level: 10, BitDepth: 8, ChromaSubsampling: 1, ColourPrimaries: 1, TransferCharacteristics: 1, MatrixCoefficients: 1},
+		},
+		{
+			codec: "vp09.02.10.10.01.09.16.09.01",
+			want:  VPCodecConfigurationBox{Profile: 2, Level: 10, BitDepth: 10, ChromaSubsampling: 1, ColourPrimaries: 9, TransferCharacteristics: 16, MatrixCoefficients: 9, VideoFullRangeFlag: true},
+		},
+		{codec: "vp09.00.10", wantErr: ErrInvalidParam},
+		{codec: "vp08.00.10.08", wantErr: ErrInvalidParam},
+		{codec: "vp09.00.10.08.01.01.01.01.00.00", wantErr: ErrInvalidParam},
+		{codec: "vp09.00.1x.08", wantErr: ErrInvalidParam},
+		{codec: "vp09.00.10.300", wantErr: ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			got, err := ParseVPCodecString(tt.codec)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseVPCodecString() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseVPCodecString() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewVPCodecConfigurationBox(t *testing.T) {
+	record := VPCodecConfigurationBox{Profile: 2, Level: 31, BitDepth: 10, ChromaSubsampling: 1, ColourPrimaries: 9, TransferCharacteristics: 16, MatrixCoefficients: 9, CodecInitializationData: []byte{}}
+	tests := []struct {
+		name             string
+		codecPrivateData string
+		attributes       *CustomAttributes
+		width, height    uint32
+		want             VPCodecConfigurationBox
+		wantErr          error
+	}{
+		{
+			name:             "VPCodecConfigurationRecord",
+			codecPrivateData: "021f a2 09 10 09 0000",
+			want:             record,
+		},
+		{
+			name:             "VPCodecConfigurationRecord with version and flags",
+			codecPrivateData: "01000000 021f a2 09 10 09 0000",
+			want:             record,
+		},
+		{
+			name:       "codecs attribute",
+			attributes: &CustomAttributes{Attributes: []*Attribute{{Name: "Codecs", Value: "vp09.02.31.10.01.09.16.09"}}},
+			want:       VPCodecConfigurationBox{Profile: 2, Level: 31, BitDepth: 10, ChromaSubsampling: 1, ColourPrimaries: 9, TransferCharacteristics: 16, MatrixCoefficients: 9},
+		},
+		{
+			name:   "level of the picture size",
+			width:  1920,
+			height: 1080,
+			want:   VPCodecConfigurationBox{Level: 40, BitDepth: 8, ChromaSubsampling: 1, ColourPrimaries: 1, TransferCharacteristics: 1, MatrixCoefficients: 1},
+		},
+		{
+			name:   "picture size beyond the levels",
+			width:  16384,
+			height: 8704,
+			want:   VPCodecConfigurationBox{Level: 60, BitDepth: 8, ChromaSubsampling: 1, ColourPrimaries: 1, TransferCharacteristics: 1, MatrixCoefficients: 1},
+		},
+		{
+			name:             "inconsistent codecIntializationDataSize",
+			codecPrivateData: "021f a2 09 10 09 0001",
+			wantErr:          ErrInvalidParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewVPCodecConfigurationBox(decodeHex(t, strings.ReplaceAll(tt.codecPrivateData, " ", "")), tt.attributes, tt.width, tt.height)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewVPCodecConfigurationBox() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("NewVPCodecConfigurationBox() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
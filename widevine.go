@@ -0,0 +1,99 @@
+package smoothstreaming
+
+import (
+	"github.com/go-webdl/mp4"
+	"github.com/google/uuid"
+)
+
+// WidevineSystemID identifies the Widevine content protection system.
+var WidevineSystemID = uuid.MustParse("edef8ba9-79d6-4ace-a3c8-27dcd51d21ed")
+
+// Widevine encryption algorithms of the algorithm field.
+const (
+	WidevineAlgorithmUnencrypted uint64 = 0
+	WidevineAlgorithmAESCTR      uint64 = 1
+)
+
+// WidevinePsshData carries the fields of the WidevinePsshData protobuf message
+// that is the data of Widevine pssh boxes.
+type WidevinePsshData struct {
+	KeyIDs           []uuid.UUID
+	Provider         string
+	ContentID        []byte
+	ProtectionScheme mp4.FourCC
+}
+
+// NewWidevinePsshData creates the Widevine pssh data for the keys of a
+// PlayReady header. PlayReady AES-CBC keys select the 'cbcs' protection scheme,
+// otherwise the 'cenc' scheme is assumed.
+func NewWidevinePsshData(header PlayReadyHeader, provider string, contentID []byte) (data WidevinePsshData) {
+	data.Provider = provider
+	data.ContentID = contentID
+	data.ProtectionScheme = mp4.CencFourCC
+	for _, kid := range header.KIDs {
+		data.KeyIDs = append(data.KeyIDs, kid.KID)
+		if kid.AlgID == PlayReadyAlgIDAESCBC {
+			data.ProtectionScheme = CbcsFourCC
+		}
+	}
+	return
+}
+
+// Bytes serializes the WidevinePsshData protobuf message.
+func (d WidevinePsshData) Bytes() []byte {
+	var data []byte
+	if d.ProtectionScheme == mp4.CencFourCC || d.ProtectionScheme == (mp4.FourCC{}) {
+		// the algorithm field is deprecated, but older CDMs require it for
+		// 'cenc' content
+		data = appendProtobufVarint(data, 1, WidevineAlgorithmAESCTR)
+	}
+	for _, kid := range d.KeyIDs {
+		data = appendProtobufBytes(data, 2, kid[:])
+	}
+	if d.Provider != "" {
+		data = appendProtobufBytes(data, 3, []byte(d.Provider))
+	}
+	if len(d.ContentID) > 0 {
+		data = appendProtobufBytes(data, 4, d.ContentID)
+	}
+	if d.ProtectionScheme != (mp4.FourCC{}) {
+		scheme := d.ProtectionScheme
+		data = appendProtobufVarint(data, 9, uint64(scheme[0])<<24|uint64(scheme[1])<<16|uint64(scheme[2])<<8|uint64(scheme[3]))
+	}
+	return data
+}
+
+// ProtectionSystem returns the Widevine protection system carrying the pssh
+// data, to be added to the ProtectionSystems of a MoovProcessor.
+func (d WidevinePsshData) ProtectionSystem() ProtectionSystem {
+	return ProtectionSystem{SystemID: WidevineSystemID, Data: d.Bytes()}
+}
+
+// CreateWidevinePsshMp4Box returns the Widevine pssh box of the pssh data.
+func (d WidevinePsshData) CreateWidevinePsshMp4Box() (pssh mp4.Box, err error) {
+	pssh = &mp4.ProtectionSystemSpecificHeaderBox{
+		SystemID: WidevineSystemID,
+		Data:     d.Bytes(),
+	}
+	pssh.Mp4BoxUpdate()
+	return
+}
+
+func appendProtobufVarint(data []byte, field int, value uint64) []byte {
+	data = appendVarint(data, uint64(field)<<3) // wire type 0
+	return appendVarint(data, value)
+}
+
+func appendProtobufBytes(data []byte, field int, value []byte) []byte {
+	data = appendVarint(data, uint64(field)<<3|2) // wire type 2
+	data = appendVarint(data, uint64(len(value)))
+	return append(data, value...)
+}
+
+func appendVarint(data []byte, value uint64) []byte {
+	for value >= 0x80 {
+		data = append(data, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(data, byte(value))
+}
@@ -0,0 +1,114 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/go-webdl/mp4"
+	"github.com/google/uuid"
+)
+
+func TestWidevinePsshDataBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		data WidevinePsshData
+		want string
+	}{
+		{
+			name: "cenc",
+			data: WidevinePsshData{
+				KeyIDs:           []uuid.UUID{testPlayReadyKID},
+				Provider:         "widevine_test",
+				ContentID:        []byte{0xab},
+				ProtectionScheme: mp4.CencFourCC,
+			},
+			want: "0801" + "1210" + "0102030405060708090a0b0c0d0e0f10" + "1a0d" + hex.EncodeToString([]byte("widevine_test")) + "2201ab" + "48e3dc959b06",
+		},
+		{
+			name: "cbcs",
+			data: WidevinePsshData{KeyIDs: []uuid.UUID{testPlayReadyKID}, ProtectionScheme: CbcsFourCC},
+			want: "1210" + "0102030405060708090a0b0c0d0e0f10" + "48f3c6899b06",
+		},
+		{
+			name: "no scheme",
+			data: WidevinePsshData{KeyIDs: []uuid.UUID{testPlayReadyKID, {}}},
+			want: "0801" + "1210" + "0102030405060708090a0b0c0d0e0f10" + "1210" + "00000000000000000000000000000000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(tt.data.Bytes()); got != tt.want {
+				t.Errorf("Bytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWidevinePsshData(t *testing.T) {
+	other := uuid.MustParse("11111111-2222-3333-4444-555555555555")
+	tests := []struct {
+		name       string
+		header     PlayReadyHeader
+		wantScheme mp4.FourCC
+	}{
+		{
+			name:       "AES-CTR keys",
+			header:     PlayReadyHeader{KIDs: []PlayReadyKID{{KID: testPlayReadyKID, AlgID: PlayReadyAlgIDAESCTR}, {KID: other}}},
+			wantScheme: mp4.CencFourCC,
+		},
+		{
+			name:       "AES-CBC key",
+			header:     PlayReadyHeader{KIDs: []PlayReadyKID{{KID: testPlayReadyKID, AlgID: PlayReadyAlgIDAESCBC}, {KID: other, AlgID: PlayReadyAlgIDAESCBC}}},
+			wantScheme: CbcsFourCC,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := NewWidevinePsshData(tt.header, "provider", []byte("content"))
+			if data.ProtectionScheme != tt.wantScheme {
+				t.Errorf("ProtectionScheme = %s, want %s", data.ProtectionScheme, tt.wantScheme)
+			}
+			if len(data.KeyIDs) != 2 || data.KeyIDs[0] != testPlayReadyKID || data.KeyIDs[1] != other {
+				t.Errorf("KeyIDs = %v, want [%s %s]", data.KeyIDs, testPlayReadyKID, other)
+			}
+			if data.Provider != "provider" || string(data.ContentID) != "content" {
+				t.Errorf("Provider, ContentID = %q, %q", data.Provider, data.ContentID)
+			}
+		})
+	}
+}
+
+func TestCreateWidevinePsshMp4Box(t *testing.T) {
+	data := WidevinePsshData{KeyIDs: []uuid.UUID{testPlayReadyKID}, ProtectionScheme: mp4.CencFourCC}
+	if system := data.ProtectionSystem(); system.SystemID != WidevineSystemID || !bytes.Equal(system.Data, data.Bytes()) {
+		t.Errorf("ProtectionSystem() = %+v", system)
+	}
+	box, err := data.CreateWidevinePsshMp4Box()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pssh, ok := roundTripBox(t, box).(*mp4.ProtectionSystemSpecificHeaderBox)
+	if !ok {
+		t.Fatalf("read %T, want a pssh box", box)
+	}
+	if pssh.SystemID != WidevineSystemID || !bytes.Equal(pssh.Data, data.Bytes()) {
+		t.Errorf("pssh = %s %x, want %s %x", pssh.SystemID, pssh.Data, WidevineSystemID, data.Bytes())
+	}
+}
+
+func TestAppendVarint(t *testing.T) {
+	tests := []struct {
+		value uint64
+		want  []byte
+	}{
+		{value: 0, want: []byte{0x00}},
+		{value: 0x7f, want: []byte{0x7f}},
+		{value: 300, want: []byte{0xac, 0x02}},
+	}
+	for _, tt := range tests {
+		if got := appendVarint(nil, tt.value); !bytes.Equal(got, tt.want) {
+			t.Errorf("appendVarint(%d) = %x, want %x", tt.value, got, tt.want)
+		}
+	}
+}
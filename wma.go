@@ -0,0 +1,86 @@
+package smoothstreaming
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Format tags of the WAVEFORMATEX structure, equal to the AudioTag field of
+// the manifest.
+const (
+	WaveFormatWMA2        uint16 = 0x0161
+	WaveFormatWMAPro      uint16 = 0x0162
+	WaveFormatWMALossless uint16 = 0x0163
+	WaveFormatExtensible  uint16 = 0xfffe
+)
+
+// waveFormatExSize is the size of the WAVEFORMATEX structure without its
+// extra data.
+const waveFormatExSize = 18
+
+// WaveFormatEx is the WAVEFORMATEX structure describing a Windows Media Audio
+// stream.
+type WaveFormatEx struct {
+	FormatTag      uint16
+	Channels       uint16
+	SamplesPerSec  uint32
+	AvgBytesPerSec uint32
+	BlockAlign     uint16
+	BitsPerSample  uint16
+
+	// the format specific data following the structure, whose size is given
+	// by the cbSize field.
+	ExtraData []byte
+}
+
+// ParseWaveFormatEx parses a WAVEFORMATEX structure.
+func ParseWaveFormatEx(data []byte) (wfx WaveFormatEx, err error) {
+	if len(data) < waveFormatExSize {
+		err = fmt.Errorf("WAVEFORMATEX truncated: %w", ErrInvalidParam)
+		return
+	}
+	cbSize := int(binary.LittleEndian.Uint16(data[16:]))
+	if len(data) < waveFormatExSize+cbSize {
+		err = fmt.Errorf("WAVEFORMATEX extra data truncated: %w", ErrInvalidParam)
+		return
+	}
+	wfx = WaveFormatEx{
+		FormatTag:      binary.LittleEndian.Uint16(data[0:]),
+		Channels:       binary.LittleEndian.Uint16(data[2:]),
+		SamplesPerSec:  binary.LittleEndian.Uint32(data[4:]),
+		AvgBytesPerSec: binary.LittleEndian.Uint32(data[8:]),
+		BlockAlign:     binary.LittleEndian.Uint16(data[12:]),
+		BitsPerSample:  binary.LittleEndian.Uint16(data[14:]),
+		ExtraData:      data[waveFormatExSize : waveFormatExSize+cbSize],
+	}
+	return
+}
+
+// Bytes serializes the WAVEFORMATEX structure.
+func (wfx WaveFormatEx) Bytes() []byte {
+	data := make([]byte, waveFormatExSize, waveFormatExSize+len(wfx.ExtraData))
+	binary.LittleEndian.PutUint16(data[0:], wfx.FormatTag)
+	binary.LittleEndian.PutUint16(data[2:], wfx.Channels)
+	binary.LittleEndian.PutUint32(data[4:], wfx.SamplesPerSec)
+	binary.LittleEndian.PutUint32(data[8:], wfx.AvgBytesPerSec)
+	binary.LittleEndian.PutUint16(data[12:], wfx.BlockAlign)
+	binary.LittleEndian.PutUint16(data[14:], wfx.BitsPerSample)
+	binary.LittleEndian.PutUint16(data[16:], uint16(len(wfx.ExtraData)))
+	return append(data, wfx.ExtraData...)
+}
+
+// isWaveFormatEx reports whether data holds a complete WAVEFORMATEX structure
+// rather than only its extra data, judging by a Windows Media Audio format
+// tag, or the given audioTag, and the consistency of the cbSize field.
+func isWaveFormatEx(data []byte, audioTag uint16) bool {
+	if len(data) < waveFormatExSize ||
+		int(binary.LittleEndian.Uint16(data[16:])) != len(data)-waveFormatExSize {
+		return false
+	}
+	switch formatTag := binary.LittleEndian.Uint16(data); formatTag {
+	case WaveFormatWMA2, WaveFormatWMAPro, WaveFormatWMALossless, WaveFormatExtensible:
+		return true
+	default:
+		return audioTag != 0 && formatTag == audioTag
+	}
+}
@@ -0,0 +1,66 @@
+package smoothstreaming
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWaveFormatExRoundTrip(t *testing.T) {
+	wfx := WaveFormatEx{
+		FormatTag:      WaveFormatWMAPro,
+		Channels:       2,
+		SamplesPerSec:  48000,
+		AvgBytesPerSec: 24000,
+		BlockAlign:     8917,
+		BitsPerSample:  24,
+		ExtraData:      []byte{0x18, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+	data := wfx.Bytes()
+	if len(data) != waveFormatExSize+len(wfx.ExtraData) {
+		t.Fatalf("Bytes() is %d bytes, want %d", len(data), waveFormatExSize+len(wfx.ExtraData))
+	}
+	got, err := ParseWaveFormatEx(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wfx) {
+		t.Errorf("ParseWaveFormatEx() = %+v, want %+v", got, wfx)
+	}
+	if !isWaveFormatEx(data, 0) {
+		t.Error("isWaveFormatEx() = false, want true")
+	}
+}
+
+func TestParseWaveFormatExErrors(t *testing.T) {
+	data := WaveFormatEx{FormatTag: WaveFormatWMA2, ExtraData: make([]byte, 10)}.Bytes()
+	for _, data := range [][]byte{data[:waveFormatExSize-1], data[:len(data)-1]} {
+		if _, err := ParseWaveFormatEx(data); !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("ParseWaveFormatEx() of %d bytes error = %v, want %v", len(data), err, ErrInvalidParam)
+		}
+	}
+}
+
+func TestIsWaveFormatEx(t *testing.T) {
+	pcm := WaveFormatEx{FormatTag: 1, Channels: 2}.Bytes()
+	tests := []struct {
+		name     string
+		data     []byte
+		audioTag uint16
+		want     bool
+	}{
+		{name: "WMA format tag", data: WaveFormatEx{FormatTag: WaveFormatWMALossless, ExtraData: []byte{1, 2}}.Bytes(), want: true},
+		{name: "format tag of the AudioTag", data: pcm, audioTag: 1, want: true},
+		{name: "unknown format tag", data: pcm},
+		{name: "inconsistent cbSize", data: append(WaveFormatEx{FormatTag: WaveFormatWMA2}.Bytes(), 0)},
+		{name: "extra data only", data: bytes.Repeat([]byte{0}, 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isWaveFormatEx(tt.data, tt.audioTag); got != tt.want {
+				t.Errorf("isWaveFormatEx() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}